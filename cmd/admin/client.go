@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"subscription-aggregator-service/internal/api/middlewares"
+	apiModels "subscription-aggregator-service/internal/api/models"
+)
+
+// client calls the admin API with the admin token. With an HMAC key it also signs requests,
+// the service requires it on the public API when app.auth.hmac.enabled is set.
+type client struct {
+	baseURL string
+	token   string
+	keyID   string
+	secret  string
+	http    *http.Client
+}
+
+func newClient(baseURL, token, keyID, secret string) *client {
+	return &client{baseURL: strings.TrimRight(baseURL, "/"), token: token, keyID: keyID, secret: secret, http: &http.Client{Timeout: time.Minute}}
+}
+
+// do sends body as JSON and decodes the response into out, non-2xx responses become errors with the server's message
+func (c *client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var payload []byte
+	var reader io.Reader
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.keyID != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(middlewares.HeaderKeyID, c.keyID)
+		req.Header.Set(middlewares.HeaderTimestamp, timestamp)
+		req.Header.Set(middlewares.HeaderSignature, middlewares.Sign(c.secret, method, req.URL.RequestURI(), timestamp, payload))
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e apiModels.ErrorResponse
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, e.Error)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,406 @@
+// Command admin is a client for the admin API of the service, so operators don't pass curl snippets around.
+// Credentials are read from a YAML file with "url" and "token" keys, EMTT_ADMIN_URL and EMTT_ADMIN_TOKEN
+// override it and flags override both. Bulk delete goes through the public API at "base_path", signed
+// with "key_id" and "secret" when those are set.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/viper"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/models"
+)
+
+const (
+	configPathEnv   = "EMTT_ADMIN_CONFIG"
+	defaultURL      = "http://localhost:8080"
+	defaultBasePath = "/api/v1"
+)
+
+const usage = `Usage: admin [flags] <command> [args]
+
+Commands:
+  findings                                    Anomalies found by the last integrity check
+  check                                       Run the integrity check now and print its findings
+  import [-allow checks] <file|->             Import subscriptions from a JSON file {"subscriptions": [...]}
+  plans create -service S -name N -price P    Add a plan to the catalog
+  plans set-price <id> <price>                Change the official price of a plan
+  services rename -from A -to B [-org-unit U] Rename a service across subscriptions
+  purge subscription -yes <id>                Permanently delete a subscription, for erasure requests
+  purge user -yes <id>                        Permanently delete all subscriptions and views of a user
+  bulk-delete start [-user U] [-service S] [-start MM-YYYY] [-end MM-YYYY]
+                                              Start deleting subscriptions by filter in the background
+  bulk-delete status <id>                     Progress of a bulk delete job
+
+Flags:
+`
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			_, _ = fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("admin", flag.ContinueOnError)
+	configPath := flags.String("config", "", "Path to credentials file (default $"+configPathEnv+" or ~/.config/emtt-admin.yaml)")
+	baseURL := flags.String("url", "", "Base URL of the service (default "+defaultURL+")")
+	token := flags.String("token", "", "Admin token, app.admin.token of the service")
+	output := flags.String("o", "table", "Output format: table or json")
+	flags.Usage = func() {
+		_, _ = fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *output != "table" && *output != "json" {
+		return fmt.Errorf("invalid output format '%s', must be table or json", *output)
+	}
+
+	creds, err := loadCredentials(*configPath)
+	if err != nil {
+		return err
+	}
+	if *baseURL != "" {
+		creds.Set("url", *baseURL)
+	}
+	if *token != "" {
+		creds.Set("token", *token)
+	}
+	if creds.GetString("token") == "" {
+		return errors.New("admin token is not set, put it into the credentials file or pass -token")
+	}
+
+	c := newClient(creds.GetString("url"), creds.GetString("token"), creds.GetString("key_id"), creds.GetString("secret"))
+	p := printer{out: stdout, json: *output == "json"}
+
+	cmd := flags.Args()
+	if len(cmd) == 0 {
+		flags.Usage()
+		return flag.ErrHelp
+	}
+	switch cmd[0] {
+	case "findings", "check":
+		method := http.MethodGet
+		path := "/admin/integrity/findings"
+		if cmd[0] == "check" {
+			method, path = http.MethodPost, "/admin/integrity/check"
+		}
+		var findings []models.IntegrityFinding
+		if err = c.do(ctx, method, path, nil, nil, &findings); err != nil {
+			return err
+		}
+		return p.print(findings, func(w io.Writer) {
+			_, _ = fmt.Fprintln(w, "CHECK\tSUBSCRIPTION\tDETAILS\tDETECTED\tCHECKED")
+			for _, f := range findings {
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", f.Check, f.SubscriptionID, f.Details,
+					f.DetectedAt.Format(time.DateTime), f.CheckedAt.Format(time.DateTime))
+			}
+		})
+	case "import":
+		return importSubscriptions(ctx, c, p, cmd[1:], stdin)
+	case "plans":
+		return plans(ctx, c, p, cmd[1:])
+	case "services":
+		return services(ctx, c, p, cmd[1:])
+	case "purge":
+		return purge(ctx, c, p, cmd[1:])
+	case "bulk-delete":
+		return bulkDelete(ctx, c, p, creds.GetString("base_path"), cmd[1:])
+	default:
+		return fmt.Errorf("unknown command '%s', see -h", cmd[0])
+	}
+}
+
+// loadCredentials reads the credentials file at path, falling back to $EMTT_ADMIN_CONFIG and then ~/.config/emtt-admin.yaml.
+// A missing default file is not an error, credentials may come from env and flags.
+func loadCredentials(path string) (*viper.Viper, error) {
+	creds := viper.New()
+	creds.SetDefault("url", defaultURL)
+	creds.SetDefault("base_path", defaultBasePath)
+	creds.SetEnvPrefix("EMTT_ADMIN")
+	creds.AutomaticEnv()
+
+	explicit := path != ""
+	if path == "" {
+		path, explicit = os.Getenv(configPathEnv), os.Getenv(configPathEnv) != ""
+	}
+	if path == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return creds, nil
+		}
+		path = filepath.Join(dir, "emtt-admin.yaml")
+	}
+
+	creds.SetConfigFile(path)
+	if err := creds.ReadInConfig(); err != nil && (explicit || !errors.Is(err, fs.ErrNotExist)) {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	return creds, nil
+}
+
+func importSubscriptions(ctx context.Context, c *client, p printer, args []string, stdin io.Reader) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	allow := flags.String("allow", "", "Validations to skip, comma-separated: historical_start, long_duration")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("import takes one file, - for stdin")
+	}
+
+	in := stdin
+	if name := flags.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		in = f
+	}
+	var req apiModels.ImportSubscriptionsRequest
+	if err := json.NewDecoder(in).Decode(&req); err != nil {
+		return fmt.Errorf("failed to parse import file: %w", err)
+	}
+
+	query := url.Values{}
+	if *allow != "" {
+		query.Set("allow", *allow)
+	}
+	var resp apiModels.ImportSubscriptionsResponse
+	if err := c.do(ctx, http.MethodPost, "/admin/subscriptions/import", query, &req, &resp); err != nil {
+		return err
+	}
+	return p.print(resp, func(w io.Writer) {
+		_, _ = fmt.Fprintln(w, "ID")
+		for _, id := range resp.IDs {
+			_, _ = fmt.Fprintln(w, id)
+		}
+	})
+}
+
+func plans(ctx context.Context, c *client, p printer, args []string) error {
+	if len(args) == 0 {
+		return errors.New("plans takes a subcommand: create or set-price")
+	}
+
+	switch args[0] {
+	case "create":
+		flags := flag.NewFlagSet("plans create", flag.ContinueOnError)
+		var req apiModels.CreatePlanRequest
+		flags.StringVar(&req.ServiceName, "service", "", "Name of the service")
+		flags.StringVar(&req.Name, "name", "", "Name of the plan, unique per service")
+		flags.IntVar(&req.Price, "price", 0, "Official monthly price in rubles")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if err := req.Validate(); err != nil {
+			return err
+		}
+		var plan models.Plan
+		if err := c.do(ctx, http.MethodPost, "/admin/plans", nil, &req, &plan); err != nil {
+			return err
+		}
+		return p.print(plan, func(w io.Writer) {
+			printPlans(w, plan)
+		})
+	case "set-price":
+		if len(args) != 3 {
+			return errors.New("set-price takes a plan ID and a price")
+		}
+		price, err := strconv.Atoi(args[2])
+		if err != nil {
+			return fmt.Errorf("invalid price '%s'", args[2])
+		}
+		req := apiModels.UpdatePlanPriceRequest{Price: &price}
+		if err = req.Validate(); err != nil {
+			return err
+		}
+		var resp apiModels.UpdatePlanPriceResponse
+		if err = c.do(ctx, http.MethodPut, "/admin/plans/"+url.PathEscape(args[1]), nil, &req, &resp); err != nil {
+			return err
+		}
+		return p.print(resp, func(w io.Writer) {
+			if resp.Plan != nil {
+				printPlans(w, *resp.Plan)
+			}
+			_, _ = fmt.Fprintf(w, "\nPropagated to %d subscription(s)\n", resp.Propagated)
+		})
+	default:
+		return fmt.Errorf("unknown plans subcommand '%s'", args[0])
+	}
+}
+
+func services(ctx context.Context, c *client, p printer, args []string) error {
+	if len(args) == 0 || args[0] != "rename" {
+		return errors.New("services takes a subcommand: rename")
+	}
+
+	flags := flag.NewFlagSet("services rename", flag.ContinueOnError)
+	var req apiModels.RenameServiceRequest
+	flags.StringVar(&req.From, "from", "", "Current service name, matched case-insensitively")
+	flags.StringVar(&req.To, "to", "", "New service name")
+	orgUnit := flags.String("org-unit", "", "Rename only in the org unit and its descendants")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *orgUnit != "" {
+		req.OrgUnitID = orgUnit
+	}
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	var rename models.ServiceRename
+	if err := c.do(ctx, http.MethodPost, "/admin/services/rename", nil, &req, &rename); err != nil {
+		return err
+	}
+	return p.print(rename, func(w io.Writer) {
+		_, _ = fmt.Fprintln(w, "ID\tFROM\tTO\tRENAMED\tFINISHED")
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", rename.ID, rename.FromName, rename.ToName, rename.Renamed, formatTime(rename.FinishedAt))
+	})
+}
+
+// purge permanently deletes a subscription or a user's data, it can't be undone, so it asks for -yes
+func purge(ctx context.Context, c *client, p printer, args []string) error {
+	if len(args) == 0 {
+		return errors.New("purge takes a subcommand: subscription or user")
+	}
+
+	var path string
+	flags := flag.NewFlagSet("purge "+args[0], flag.ContinueOnError)
+	yes := flags.Bool("yes", false, "Confirm the deletion, it's permanent")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("purge %s takes one ID", args[0])
+	}
+	switch id := url.PathEscape(flags.Arg(0)); args[0] {
+	case "subscription":
+		path = "/admin/subscriptions/" + id + "/purge"
+	case "user":
+		path = "/admin/users/" + id + "/data"
+	default:
+		return fmt.Errorf("unknown purge subcommand '%s'", args[0])
+	}
+	if !*yes {
+		return errors.New("purge can't be undone, pass -yes to confirm")
+	}
+
+	var record models.PurgeRecord
+	if err := c.do(ctx, http.MethodDelete, path, nil, nil, &record); err != nil {
+		return err
+	}
+	return p.print(record, func(w io.Writer) {
+		_, _ = fmt.Fprintln(w, "ID\tSUBJECT\tSUBJECT ID\tSUBSCRIPTIONS\tVIEWS\tPURGED")
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%s\n", record.ID, record.Subject, record.SubjectID,
+			record.Subscriptions, record.Views, record.PurgedAt.Format(time.DateTime))
+	})
+}
+
+// bulkDelete starts and follows bulk delete jobs, which live in the public API under basePath
+func bulkDelete(ctx context.Context, c *client, p printer, basePath string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("bulk-delete takes a subcommand: start or status")
+	}
+	path := strings.TrimRight(basePath, "/") + "/subscriptions/bulk-delete"
+
+	var job models.BulkDeleteJob
+	switch args[0] {
+	case "start":
+		flags := flag.NewFlagSet("bulk-delete start", flag.ContinueOnError)
+		userID := flags.String("user", "", "Only subscriptions of this user")
+		serviceName := flags.String("service", "", "Only subscriptions to this service, exact match")
+		startDate := flags.String("start", "", "MM-YYYY, only subscriptions starting in or after this month")
+		endDate := flags.String("end", "", "MM-YYYY, only subscriptions ending in or before this month, open-ended ones are kept")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		var req apiModels.BulkDeleteRequest
+		for _, f := range []struct {
+			value string
+			field **string
+		}{{*userID, &req.UserID}, {*serviceName, &req.ServiceName}, {*startDate, &req.StartDate}, {*endDate, &req.EndDate}} {
+			if f.value != "" {
+				*f.field = &f.value
+			}
+		}
+		if err := req.Validate(); err != nil {
+			return err
+		}
+		if err := c.do(ctx, http.MethodPost, path, nil, &req, &job); err != nil {
+			return err
+		}
+	case "status":
+		if len(args) != 2 {
+			return errors.New("bulk-delete status takes a job ID")
+		}
+		if err := c.do(ctx, http.MethodGet, path+"/"+url.PathEscape(args[1]), nil, nil, &job); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown bulk-delete subcommand '%s'", args[0])
+	}
+
+	return p.print(job, func(w io.Writer) {
+		_, _ = fmt.Fprintln(w, "ID\tSTATUS\tMATCHED\tDELETED\tFINISHED\tERROR")
+		var jobErr string
+		if job.Error != nil {
+			jobErr = *job.Error
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\n", job.ID, job.Status, job.Matched, job.Deleted, formatTime(job.FinishedAt), jobErr)
+	})
+}
+
+// formatTime prints a time that may not have come yet, as - until then
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.DateTime)
+}
+
+func printPlans(w io.Writer, plans ...models.Plan) {
+	_, _ = fmt.Fprintln(w, "ID\tSERVICE\tNAME\tPRICE")
+	for _, plan := range plans {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", plan.ID, plan.ServiceName, plan.Name, plan.Price)
+	}
+}
+
+// printer writes a response either as indented JSON or as an aligned table
+type printer struct {
+	out  io.Writer
+	json bool
+}
+
+func (p printer) print(v any, table func(w io.Writer)) error {
+	if p.json {
+		enc := json.NewEncoder(p.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	w := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
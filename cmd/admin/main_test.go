@@ -0,0 +1,194 @@
+package main
+
+import (
+	"testing"
+
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"subscription-aggregator-service/internal/api/middlewares"
+	"subscription-aggregator-service/internal/models"
+)
+
+func TestRun(t *testing.T) {
+	var gotAuth, gotPath, gotQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth, gotPath, gotQuery = r.Header.Get("Authorization"), r.URL.Path, r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/admin/integrity/findings":
+			_ = json.NewEncoder(w).Encode([]models.IntegrityFinding{{Check: "negative_price", Details: "price is -1"}})
+		case "/admin/subscriptions/import":
+			_, _ = w.Write([]byte(`{"ids":["7f1d6e4a-1c2b-4a5e-9f3d-2b8c9a0e1f2a"]}`))
+		case "/admin/users/60601fee-2bf1-4721-ae6f-7636e79a0cba/data":
+			_, _ = w.Write([]byte(`{"subject":"user","subject_id":"60601fee-2bf1-4721-ae6f-7636e79a0cba","subscriptions":3,"views":1}`))
+		case "/admin/services/rename":
+			_, _ = w.Write([]byte(`{"from":"HBO Max","to":"Max","renamed":12}`))
+		case "/api/v1/subscriptions/bulk-delete":
+			w.WriteHeader(http.StatusAccepted)
+			_, _ = w.Write([]byte(`{"id":"7f1d6e4a-1c2b-4a5e-9f3d-2b8c9a0e1f2a","status":"pending","matched":40}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"Plan not found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	creds := filepath.Join(t.TempDir(), "creds.yaml")
+	if err := os.WriteFile(creds, []byte("url: "+srv.URL+"\ntoken: from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		args      []string
+		stdin     string
+		wantErr   string
+		wantAuth  string
+		wantPath  string
+		wantQuery string
+		wantOut   []string
+	}{
+		{
+			name:     "table with token from file",
+			args:     []string{"-config", creds, "findings"},
+			wantAuth: "Bearer from-file",
+			wantPath: "/admin/integrity/findings",
+			wantOut:  []string{"CHECK", "negative_price", "price is -1"},
+		},
+		{
+			name:     "json with token from flag",
+			args:     []string{"-config", creds, "-token", "from-flag", "-o", "json", "findings"},
+			wantAuth: "Bearer from-flag",
+			wantPath: "/admin/integrity/findings",
+			wantOut:  []string{`"check": "negative_price"`},
+		},
+		{
+			name:      "import from stdin",
+			args:      []string{"-config", creds, "import", "-allow", "historical_start", "-"},
+			stdin:     `{"subscriptions":[{"service_name":"Netflix","price":500,"user_id":"60601fee-2bf1-4721-ae6f-7636e79a0cba","start_date":"01-2020"}]}`,
+			wantAuth:  "Bearer from-file",
+			wantPath:  "/admin/subscriptions/import",
+			wantQuery: "allow=historical_start",
+			wantOut:   []string{"7f1d6e4a-1c2b-4a5e-9f3d-2b8c9a0e1f2a"},
+		},
+		{
+			name:     "purge user data",
+			args:     []string{"-config", creds, "purge", "user", "-yes", "60601fee-2bf1-4721-ae6f-7636e79a0cba"},
+			wantAuth: "Bearer from-file",
+			wantPath: "/admin/users/60601fee-2bf1-4721-ae6f-7636e79a0cba/data",
+			wantOut:  []string{"SUBSCRIPTIONS", "user"},
+		},
+		{
+			name:    "purge needs confirmation",
+			args:    []string{"-config", creds, "purge", "subscription", "7f1d6e4a-1c2b-4a5e-9f3d-2b8c9a0e1f2a"},
+			wantErr: "pass -yes",
+		},
+		{
+			name:     "rename service",
+			args:     []string{"-config", creds, "services", "rename", "-from", "HBO Max", "-to", "Max"},
+			wantAuth: "Bearer from-file",
+			wantPath: "/admin/services/rename",
+			wantOut:  []string{"HBO Max", "12"},
+		},
+		{
+			name:     "start bulk delete in public API",
+			args:     []string{"-config", creds, "bulk-delete", "start", "-service", "Netflix"},
+			wantAuth: "Bearer from-file",
+			wantPath: "/api/v1/subscriptions/bulk-delete",
+			wantOut:  []string{"pending", "40"},
+		},
+		{
+			name:    "bulk delete filter checked locally",
+			args:    []string{"-config", creds, "bulk-delete", "start"},
+			wantErr: "at least one of",
+		},
+		{
+			name:    "server error message",
+			args:    []string{"-config", creds, "plans", "set-price", "7f1d6e4a-1c2b-4a5e-9f3d-2b8c9a0e1f2a", "549"},
+			wantErr: "Plan not found",
+		},
+		{
+			name:    "invalid price checked locally",
+			args:    []string{"-config", creds, "plans", "set-price", "7f1d6e4a-1c2b-4a5e-9f3d-2b8c9a0e1f2a", "-1"},
+			wantErr: "price cannot be negative",
+		},
+		{
+			name:    "missing explicit credentials file",
+			args:    []string{"-config", filepath.Join(t.TempDir(), "missing.yaml"), "findings"},
+			wantErr: "failed to read credentials file",
+		},
+	}
+
+	for _, tt := range tests {
+		gotAuth, gotPath, gotQuery = "", "", ""
+		var out bytes.Buffer
+		err := run(context.Background(), tt.args, strings.NewReader(tt.stdin), &out)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error = %v, want it to contain %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if gotAuth != tt.wantAuth || gotPath != tt.wantPath || gotQuery != tt.wantQuery {
+			t.Errorf("%s: request = %s %s?%s, want %s %s?%s", tt.name, gotAuth, gotPath, gotQuery, tt.wantAuth, tt.wantPath, tt.wantQuery)
+		}
+		for _, want := range tt.wantOut {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%s: output %q does not contain %q", tt.name, out.String(), want)
+			}
+		}
+	}
+}
+
+func TestClientSignsRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middlewares.HMACAuth(map[string]string{"ops": "secret"}, time.Minute))
+	r.POST("/api/v1/subscriptions/bulk-delete", func(c *gin.Context) {
+		c.JSON(http.StatusAccepted, models.BulkDeleteJob{Status: models.BulkDeleteStatusPending})
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		secret, wantErr string
+	}{
+		{secret: "wrong", wantErr: "401"},
+		{secret: "secret"},
+	} {
+		creds := filepath.Join(t.TempDir(), "creds.yaml")
+		content := "url: " + srv.URL + "\ntoken: t\nkey_id: ops\nsecret: " + tt.secret + "\n"
+		if err := os.WriteFile(creds, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		err := run(context.Background(), []string{"-config", creds, "bulk-delete", "start", "-service", "Netflix"}, nil, &out)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("secret %s: error = %v, want it to contain %q", tt.secret, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("secret %s: unexpected error: %v", tt.secret, err)
+		}
+		if !strings.Contains(out.String(), "pending") {
+			t.Errorf("secret %s: output %q does not contain pending", tt.secret, out.String())
+		}
+	}
+}
@@ -1,7 +1,26 @@
 package main
 
-import "subscription-aggregator-service/internal/app"
+import (
+	"flag"
+	"fmt"
+	"log"
+	_ "time/tzdata" // Scratch/distroless images ship without zoneinfo
+
+	"subscription-aggregator-service/internal/app"
+)
 
 func main() {
-	app.Load().Run()
+	configPath := flag.String("config", "", "Path to config file (default $CONFIG_PATH or ./config.yaml)")
+	selfTest := flag.Bool("self-test", false, "Run CRUD + total cost smoke sequence against a temporary schema and exit")
+	flag.Parse()
+
+	if *selfTest {
+		if err := app.SelfTest(*configPath); err != nil {
+			log.Fatalf("Fatal: self-test failed: %v", err)
+		}
+		fmt.Println("Self-test passed.")
+		return
+	}
+
+	app.Load(*configPath).Run()
 }
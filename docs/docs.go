@@ -15,38 +15,73 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/subscriptions": {
+        "/org-units": {
             "get": {
-                "description": "Returns a list of subscriptions with optional filtering by user ID and service name",
+                "description": "Returns all org units (company, departments, teams) ordered by name, the tree is given by parent_id.\nPass org_unit_id when creating or updating a subscription to attribute its cost to a unit.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "org-units"
                 ],
-                "summary": "List subscriptions",
+                "summary": "List org units",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.OrgUnit"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/org-units/{id}/total": {
+            "get": {
+                "description": "Calculates cost of subscriptions attributed to the unit for a period and rolls up costs of its descendants.\nReturns the subtree: every node has own_cost of its own subscriptions and total_cost including its children.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "org-units"
+                ],
+                "summary": "Get org unit cost",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User UUID",
-                        "name": "user_id",
-                        "in": "query"
+                        "description": "Org unit UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Service Name",
-                        "name": "service_name",
-                        "in": "query"
+                        "description": "Start Date (MM-YYYY)",
+                        "name": "start_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "End Date (MM-YYYY)",
+                        "name": "end_date",
+                        "in": "query",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.Subscription"
-                            }
+                            "$ref": "#/definitions/models.OrgUnitCost"
                         }
                     },
                     "400": {
@@ -55,6 +90,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -62,35 +103,73 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "post": {
-                "description": "Adds a new subscription record to the database with given details",
-                "consumes": [
+            }
+        },
+        "/plans": {
+            "get": {
+                "description": "Returns plans with official prices ordered by service and plan name. Pass plan_id when creating a subscription to prefill its service name and price.",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "plans"
+                ],
+                "summary": "List catalog plans",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Plan"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/services/suggest": {
+            "get": {
+                "description": "Returns distinct service names starting with given prefix (case-insensitive) for autocomplete",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "subscriptions"
+                    "services"
                 ],
-                "summary": "Create a new subscription",
+                "summary": "Suggest service names",
                 "parameters": [
                     {
-                        "description": "New subscription details",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.CreateSubscriptionRequest"
-                        }
+                        "type": "string",
+                        "description": "Service name prefix",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit (1-50, default 10)",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.Subscription"
+                            "$ref": "#/definitions/models.SuggestServicesResponse"
                         }
                     },
                     "400": {
@@ -108,16 +187,16 @@ const docTemplate = `{
                 }
             }
         },
-        "/subscriptions/total": {
+        "/subscriptions": {
             "get": {
-                "description": "Calculates total cost of subscriptions for a period",
+                "description": "Returns a page of subscriptions with optional filtering by user ID, service name and creation time and sorting, with total count and next page offset",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "subscriptions"
                 ],
-                "summary": "Get total cost",
+                "summary": "List subscriptions",
                 "parameters": [
                     {
                         "type": "string",
@@ -131,26 +210,84 @@ const docTemplate = `{
                         "name": "service_name",
                         "in": "query"
                     },
+                    {
+                        "type": "boolean",
+                        "description": "Match service_name case-insensitively",
+                        "name": "service_name_ci",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
-                        "description": "Start Date (MM-YYYY)",
-                        "name": "start_date",
-                        "in": "query",
-                        "required": true
+                        "description": "Case-insensitive match, * is any characters, without * a substring",
+                        "name": "service_name_like",
+                        "in": "query"
                     },
                     {
                         "type": "string",
-                        "description": "End Date (MM-YYYY)",
-                        "name": "end_date",
-                        "in": "query",
-                        "required": true
+                        "description": "Created after (RFC3339)",
+                        "name": "created_after",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created before (RFC3339)",
+                        "name": "created_before",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only subscriptions active in this month (MM-YYYY)",
+                        "name": "active_at",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum price",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum price",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Offset",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Saved view UUID, explicit filters take precedence",
+                        "name": "view",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort by price, start_date, service_name or created_at (default)",
+                        "name": "sort_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sort order, asc or desc (default)",
+                        "name": "order",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.TotalCostResponse"
+                            "$ref": "#/definitions/models.ListSubscriptionsResponse"
                         }
                     },
                     "400": {
@@ -159,6 +296,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
+                    "404": {
+                        "description": "View not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -166,32 +309,50 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/subscriptions/{id}": {
-            "get": {
-                "description": "Returns a single subscription record by its UUID",
+            },
+            "post": {
+                "description": "Adds a new subscription record to the database with given details.\nWith if_absent_by=external_id returns the user's existing subscription with the same external_id (200) instead of creating a duplicate.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "subscriptions"
                 ],
-                "summary": "Get a subscription by ID",
+                "summary": "Create a new subscription",
                 "parameters": [
                     {
+                        "description": "New subscription details",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateSubscriptionRequest"
+                        }
+                    },
+                    {
+                        "enum": [
+                            "external_id"
+                        ],
                         "type": "string",
-                        "description": "Subscription UUID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Deduplication key",
+                        "name": "if_absent_by",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "Existing subscription (if_absent_by only)",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/models.Subscription"
+                            "$ref": "#/definitions/models.SubscriptionResponse"
                         }
                     },
                     "400": {
@@ -200,10 +361,10 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "409": {
+                        "description": "Subscription with given ID or, with detect_duplicates enabled, an overlapping one to the same service already exists",
                         "schema": {
-                            "$ref": "#/definitions/models.ErrorResponse"
+                            "$ref": "#/definitions/models.SubscriptionResponse"
                         }
                     },
                     "500": {
@@ -213,9 +374,11 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "put": {
-                "description": "Updates an existing subscription record. Supports partial updates.",
+            }
+        },
+        "/subscriptions/batch": {
+            "post": {
+                "description": "Validates every subscription of the array and creates the valid ones in one transaction, up to 1000 per request.\n201 if all were created, 207 if some were rejected, 400 if all were. Every item gets its own status in results:\n400 if invalid, 409 if it overlaps an existing subscription or an earlier item with detect_duplicates enabled.",
                 "consumes": [
                     "application/json"
                 ],
@@ -225,40 +388,42 @@ const docTemplate = `{
                 "tags": [
                     "subscriptions"
                 ],
-                "summary": "Update a subscription",
+                "summary": "Create subscriptions in batch",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Subscription UUID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Subscription update data",
+                        "description": "New subscriptions",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.UpdateSubscriptionRequest"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.CreateSubscriptionRequest"
+                            }
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchCreateResponse"
+                        }
+                    },
+                    "207": {
+                        "description": "Some items are invalid, the valid ones are created",
                         "schema": {
-                            "$ref": "#/definitions/models.Subscription"
+                            "$ref": "#/definitions/models.BatchCreateResponse"
                         }
                     },
                     "400": {
-                        "description": "Bad Request",
+                        "description": "All items are invalid, or apiModels.ErrorResponse for a malformed batch",
                         "schema": {
-                            "$ref": "#/definitions/models.ErrorResponse"
+                            "$ref": "#/definitions/models.BatchCreateResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "409": {
+                        "description": "Subscription with given ID already exists, nothing is created",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -270,25 +435,38 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Marks a subscription record as (soft-)deleted in the database",
+            }
+        },
+        "/subscriptions/bulk-delete": {
+            "post": {
+                "description": "Starts a background job soft-deleting subscriptions matching the filter in batches, each batch in its own transaction.\nDates select subscriptions whose whole period lies within [start_date, end_date], open-ended ones are kept if end_date is set.\nResponds right away with the job, follow its progress at the Location URL. Deletions by the job can't be undone.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
                     "subscriptions"
                 ],
-                "summary": "Delete a subscription",
+                "summary": "Delete subscriptions by filter",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Subscription UUID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Filter, at least one criterion",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.BulkDeleteRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK"
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.BulkDeleteJob"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -296,9 +474,301 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
-                        "schema": {
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/bulk-delete/{id}": {
+            "get": {
+                "description": "Returns a bulk delete job: status (pending, running, done, failed), subscriptions matched when it started and deleted so far.\nA finished job has finished_at set, a failed one also has error; subscriptions deleted before the failure stay deleted.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Get bulk delete progress",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.BulkDeleteJob"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/chargeback": {
+            "get": {
+                "description": "Exports spend for a period grouped by allocation tag (cost_center or project_code) and month as CSV\nwith columns \u003cby\u003e,month,cost. Untagged spend has an empty tag, months without spend are omitted.",
+                "produces": [
+                    "text/csv"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Export chargeback",
+                "parameters": [
+                    {
+                        "enum": [
+                            "cost_center",
+                            "project_code"
+                        ],
+                        "type": "string",
+                        "description": "Allocation tag to group by",
+                        "name": "by",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start Date (MM-YYYY)",
+                        "name": "start_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "End Date (MM-YYYY)",
+                        "name": "end_date",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "CSV",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/expiring": {
+            "get": {
+                "description": "Lists recurring subscriptions ending from the current month through the month window days ahead, grouped by user.\nA subscription is renewed if the user has another one to the same service starting no later than the month after it ends.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "List expiring subscriptions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "How far ahead to look in days, e.g. 60d (default 30d, at most 366d)",
+                        "name": "window",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "false for subscriptions that will lapse, true for renewed ones, both if absent",
+                        "name": "auto_renew",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ExpiringSubscriptionsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/total": {
+            "get": {
+                "description": "Calculates total cost of subscriptions for a period",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Get total cost",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Service Name",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Match service_name case-insensitively",
+                        "name": "service_name_ci",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Case-insensitive match, * is any characters, without * a substring",
+                        "name": "service_name_like",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start Date (MM-YYYY)",
+                        "name": "start_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "End Date (MM-YYYY)",
+                        "name": "end_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "service_name",
+                            "month"
+                        ],
+                        "type": "string",
+                        "description": "Also return breakdown by",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Locale of formatted fields, ru (default) or en",
+                        "name": "X-Locale",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency of formatted prices, RUB (default) or one with a configured rate",
+                        "name": "X-Display-Currency",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TotalCostResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/total/batch": {
+            "post": {
+                "description": "Calculates total cost of subscriptions for a period per user, for up to 1000 users in one request",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Get total cost of many users",
+                "parameters": [
+                    {
+                        "description": "User IDs and period",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchTotalCostRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchTotalCostResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
@@ -310,24 +780,2231 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "models.CreateSubscriptionRequest": {
+        },
+        "/subscriptions/total/explain": {
+            "get": {
+                "description": "Breaks down total cost for a period per subscription: clipped interval, months counted, price and amount",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Explain total cost",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Service Name",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Match service_name case-insensitively",
+                        "name": "service_name_ci",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Case-insensitive match, * is any characters, without * a substring",
+                        "name": "service_name_like",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start Date (MM-YYYY)",
+                        "name": "start_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "End Date (MM-YYYY)",
+                        "name": "end_date",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Locale of formatted fields, ru (default) or en",
+                        "name": "X-Locale",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency of formatted prices, RUB (default) or one with a configured rate",
+                        "name": "X-Display-Currency",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.CostExplanationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}": {
+            "get": {
+                "description": "Returns a single subscription record by its UUID, ETag header carries its version for If-Match of updates",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Get a subscription by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Subscription version"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Updates an existing subscription record. Supports partial updates.\nIf-Match must carry the ETag from GET (required unless app.api.require_if_match is off), 412 means someone updated it since.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Update a subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the subscription version being updated, or *",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Subscription update data",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "New subscription version"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Marks a subscription record as (soft-)deleted in the database.\nReturns a token for POST /undo/{token} valid for app.undo.window, or 204 if undo is disabled.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Delete a subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.UndoToken"
+                        }
+                    },
+                    "204": {
+                        "description": "Deleted, undo is disabled"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "head": {
+                "description": "Cheap existence check for other services: 200 if the subscription exists, 404 if not, no body either way",
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Check that a subscription exists",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Subscription exists"
+                    },
+                    "400": {
+                        "description": "Invalid UUID"
+                    },
+                    "404": {
+                        "description": "Subscription not found"
+                    },
+                    "500": {
+                        "description": "Internal server error"
+                    }
+                }
+            },
+            "patch": {
+                "description": "Applies RFC 7386 JSON merge patch: absent fields are kept, null clears a field (only end_date can be cleared).\nIf-Match works as for PUT.",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Patch a subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the subscription version being updated, or *",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Merge patch, null clears end_date",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "New subscription version"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/cancel": {
+            "post": {
+                "description": "Ends the subscription with the current month or the given one and sets cancelled_at. Unlike deletion it stays listed and counts until its end.\nThe end month cannot precede the start or follow the current end, one-time purchases cannot be cancelled. If-Match is checked only when sent.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Cancel a subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the subscription version being cancelled, or *",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Last month, current one if omitted",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/models.CancelSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "New subscription version"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/credits": {
+            "get": {
+                "description": "Returns credits of the subscription ordered by start date",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "credits"
+                ],
+                "summary": "List subscription credits",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.CreditResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Attaches a discount subtracted from the subscription price every month of the credit period, total cost accounts for it.\nThe period must fit into the subscription and the discount cannot exceed its price.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "credits"
+                ],
+                "summary": "Add a credit to a subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Credit details",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateCreditRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.CreditResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/pause": {
+            "post": {
+                "description": "Stops charging the subscription from the current month or the given one until it's resumed, total cost leaves paused months out.\nThe month must fall within the subscription and after its earlier pauses, one-time purchases cannot be paused.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pauses"
+                ],
+                "summary": "Pause a subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "First paused month, current one if omitted",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/models.PauseSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.PausedPeriodResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/pauses": {
+            "get": {
+                "description": "Returns pauses of the subscription ordered by start date, an open one (without end_date) means it's paused now",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pauses"
+                ],
+                "summary": "List subscription pauses",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.PausedPeriodResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/renew": {
+            "post": {
+                "description": "Pushes the end date the given number of months forward. An open-ended subscription gets a fixed term ending that many months\nafter the current month, or after its start if it hasn't started yet. Renewal clears cancelled_at, one-time purchases cannot be renewed.\nIf-Match is checked only when sent.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Renew a subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the subscription version being renewed, or *",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Months to add",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.RenewSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "New subscription version"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/subscriptions/{id}/resume": {
+            "post": {
+                "description": "Charges the paused subscription again from the current month or the given one, the pause ends a month before.\nA pause resumed in or before its first month is dropped.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "pauses"
+                ],
+                "summary": "Resume a subscription",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Subscription UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "First charged month, current one if omitted",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/models.PauseSubscriptionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.PausedPeriodResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/undo/{token}": {
+            "post": {
+                "description": "Restores a subscription deleted with DELETE /subscriptions/{id}, while its undo token is valid. A token works once.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Undo a deletion",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Undo token",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SubscriptionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Token not found or expired",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Subscription with the same external_id was created since",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{id}/subscriptions": {
+            "put": {
+                "description": "Sets new_price on all user's subscriptions whose service name matches service_name_filter (case-insensitive, * is any characters)\nin one transaction, e.g. when a provider changes pricing for everyone. Updated subscriptions stop following their plan price.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Change price of user's subscriptions to a service",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Service name filter and new price",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.BulkUpdateSubscriptionsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.BulkUpdateSubscriptionsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{id}/subscriptions:sync": {
+            "put": {
+                "description": "Takes the full desired set of user's subscriptions keyed by external_id and reconciles the stored ones in one transaction:\nmissing ones are created, differing ones updated, ones absent from the set deleted. Subscriptions without external_id are left alone.\nReturns the diff, with dry_run=true only plans it without applying.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Sync user's subscriptions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only return the plan",
+                        "name": "dry_run",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Desired subscriptions",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SyncSubscriptionsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.SyncSubscriptionsResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Subscription with the same external_id created concurrently",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{id}/summary": {
+            "get": {
+                "description": "Returns number of active subscriptions, monthly cost and the most expensive service of active recurring ones\nand earliest/latest start dates of user's subscriptions. Active means covering the current month.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "subscriptions"
+                ],
+                "summary": "Get user summary",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Locale of formatted fields, ru (default) or en",
+                        "name": "X-Locale",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency of formatted prices, RUB (default) or one with a configured rate",
+                        "name": "X-Display-Currency",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.UserSummaryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/{id}/views": {
+            "get": {
+                "description": "Returns all saved list views of the user ordered by name",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "views"
+                ],
+                "summary": "List saved views",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.SavedView"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Saves a named set of list filters for the user, apply it with GET /subscriptions?view={id}",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "views"
+                ],
+                "summary": "Save a list view",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User UUID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "View name and filters",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateViewRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.SavedView"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "View with this name already exists",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/utils/parse-date": {
+            "post": {
+                "description": "Converts a human-style month, as found in spreadsheets and user input, to MM-YYYY the rest of the API expects.\nAccepts English and Russian month names, full or abbreviated (\"Jan 2024\", \"январь 2024\", \"января 2024 г.\"), and numeric forms (\"01.2024\", \"1/2024\", \"2024-01\").",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "utils"
+                ],
+                "summary": "Normalize a month",
+                "parameters": [
+                    {
+                        "description": "Month to normalize",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ParseDateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ParseDateResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "models.BatchCreateResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "description": "Subscriptions created",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 2
+                },
+                "failed": {
+                    "description": "Items rejected by validation",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 1
+                },
+                "results": {
+                    "description": "Outcome of every item in request order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BatchItemResult"
+                    }
+                }
+            }
+        },
+        "models.BatchItemResult": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "(Optional) Code of the error, as in ErrorResponse",
+                    "type": "string",
+                    "example": "VALIDATION_ERROR"
+                },
+                "error": {
+                    "description": "Why the item was rejected",
+                    "type": "string",
+                    "example": "Validation error: invalid price"
+                },
+                "fields": {
+                    "description": "(Optional) Invalid fields of the item",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldError"
+                    }
+                },
+                "status": {
+                    "description": "201 if created, 400 if invalid, 409 if a duplicate",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 201
+                },
+                "subscription": {
+                    "description": "Created subscription",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SubscriptionResponse"
+                        }
+                    ]
+                }
+            }
+        },
+        "models.BatchTotalCostRequest": {
+            "type": "object",
+            "required": [
+                "end_date",
+                "start_date",
+                "user_ids"
+            ],
+            "properties": {
+                "end_date": {
+                    "description": "End date in MM-YYYY format",
+                    "type": "string",
+                    "format": "string",
+                    "example": "12-2024"
+                },
+                "service_name": {
+                    "description": "(Optional) Filter by service name",
+                    "type": "string",
+                    "format": "string",
+                    "example": "Telegram Premium"
+                },
+                "service_name_ci": {
+                    "description": "(Optional) Match service_name case-insensitively",
+                    "type": "boolean",
+                    "format": "bool",
+                    "example": true
+                },
+                "service_name_like": {
+                    "description": "(Optional) Case-insensitive match, * is any characters, without * a substring",
+                    "type": "string",
+                    "format": "string",
+                    "example": "net*"
+                },
+                "start_date": {
+                    "description": "Start date in MM-YYYY format",
+                    "type": "string",
+                    "format": "string",
+                    "example": "01-2024"
+                },
+                "user_ids": {
+                    "description": "User UUIDs, up to 1000",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "550e8400-e29b-41d4-a716-446655440000"
+                    ]
+                }
+            }
+        },
+        "models.BatchTotalCostResponse": {
+            "type": "object",
+            "properties": {
+                "totals": {
+                    "description": "One per requested user, in request order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.UserTotalCost"
+                    }
+                }
+            }
+        },
+        "models.BulkDeleteJob": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "deleted": {
+                    "type": "integer"
+                },
+                "end_date": {
+                    "type": "string"
+                },
+                "error": {
+                    "description": "Why the job failed, subscriptions deleted before stay deleted",
+                    "type": "string"
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "matched": {
+                    "description": "Subscriptions matching the filter when the job was created",
+                    "type": "integer"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "start_date": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "One of BulkDeleteStatus* constants",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "description": "Bumped after every batch",
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.BulkDeleteRequest": {
+            "type": "object",
+            "properties": {
+                "end_date": {
+                    "description": "(Optional) MM-YYYY, only subscriptions ending in or before this month, open-ended ones are kept",
+                    "type": "string",
+                    "format": "string",
+                    "example": "12-2021"
+                },
+                "service_name": {
+                    "description": "(Optional) Only subscriptions to this service, exact match",
+                    "type": "string",
+                    "format": "string",
+                    "example": "Netflix"
+                },
+                "start_date": {
+                    "description": "(Optional) MM-YYYY, only subscriptions starting in or after this month",
+                    "type": "string",
+                    "format": "string",
+                    "example": "01-2020"
+                },
+                "user_id": {
+                    "description": "(Optional) Only subscriptions of this user",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                }
+            }
+        },
+        "models.BulkUpdateSubscriptionsRequest": {
+            "type": "object",
+            "properties": {
+                "new_price": {
+                    "description": "New monthly price in rubles",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 799
+                },
+                "service_name_filter": {
+                    "description": "Service name matched case-insensitively, * is any characters",
+                    "type": "string",
+                    "format": "string",
+                    "example": "Netflix*"
+                }
+            }
+        },
+        "models.BulkUpdateSubscriptionsResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "description": "Updated subscriptions with the new price",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SubscriptionResponse"
+                    }
+                },
+                "updated": {
+                    "description": "Subscriptions that got the new price, ones already at it or ended are left alone",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 3
+                }
+            }
+        },
+        "models.CancelSubscriptionRequest": {
+            "type": "object",
+            "properties": {
+                "end_date": {
+                    "description": "(Optional) Last paid month in MM-YYYY format, current month by default",
+                    "type": "string",
+                    "format": "string",
+                    "example": "06-2026"
+                }
+            }
+        },
+        "models.CostBreakdownItem": {
+            "type": "object",
+            "properties": {
+                "cost": {
+                    "description": "Cost of the group in y.e., credits included",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 2400
+                },
+                "formatted_cost": {
+                    "description": "Cost in the display locale and currency",
+                    "type": "string",
+                    "format": "string",
+                    "example": "2 400 ₽"
+                },
+                "formatted_month": {
+                    "description": "(Optional) Month with its localized name, when grouped by month",
+                    "type": "string",
+                    "format": "string",
+                    "example": "март 2024"
+                },
+                "month": {
+                    "description": "(Optional) Month in MM-YYYY format, when grouped by month",
+                    "type": "string",
+                    "format": "string",
+                    "example": "03-2024"
+                },
+                "months": {
+                    "description": "Subscription-months counted in the group",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 12
+                },
+                "service_name": {
+                    "description": "(Optional) Service, when grouped by service_name",
+                    "type": "string",
+                    "format": "string",
+                    "example": "Netflix"
+                }
+            }
+        },
+        "models.CostExplanationItem": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Months × price + credits + paused",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 1200
+                },
+                "credits": {
+                    "description": "(Optional) Credits applied within counted months, negative",
+                    "type": "integer",
+                    "format": "int",
+                    "example": -200
+                },
+                "formatted_amount": {
+                    "description": "Amount in the display locale and currency",
+                    "type": "string",
+                    "format": "string",
+                    "example": "1 200 ₽"
+                },
+                "formatted_price": {
+                    "description": "Price in the display locale and currency",
+                    "type": "string",
+                    "format": "string",
+                    "example": "200 ₽"
+                },
+                "from": {
+                    "description": "First counted month (subscription clipped to period)",
+                    "type": "string",
+                    "format": "string",
+                    "example": "06-2024"
+                },
+                "months": {
+                    "description": "Number of months counted",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 7
+                },
+                "paused": {
+                    "description": "(Optional) Price and credits of paused months taken off, negative",
+                    "type": "integer",
+                    "format": "int",
+                    "example": -400
+                },
+                "price": {
+                    "description": "Monthly price applied",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 200
+                },
+                "service_name": {
+                    "description": "Name of the service",
+                    "type": "string",
+                    "format": "string",
+                    "example": "Netflix"
+                },
+                "subscription_id": {
+                    "description": "UUID of subscription",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "beef4269-0a1b-0c1F-afce-e13873b7b23b"
+                },
+                "to": {
+                    "description": "Last counted month (subscription clipped to period)",
+                    "type": "string",
+                    "format": "string",
+                    "example": "12-2024"
+                }
+            }
+        },
+        "models.CostExplanationResponse": {
+            "type": "object",
+            "properties": {
+                "end_date": {
+                    "description": "Requested period end in MM-YYYY format",
+                    "type": "string",
+                    "format": "string",
+                    "example": "12-2024"
+                },
+                "formatted_end_date": {
+                    "description": "Period end with its localized month name",
+                    "type": "string",
+                    "format": "string",
+                    "example": "декабрь 2024"
+                },
+                "formatted_start_date": {
+                    "description": "Period start with its localized month name",
+                    "type": "string",
+                    "format": "string",
+                    "example": "январь 2024"
+                },
+                "formatted_total_cost": {
+                    "description": "Total cost in the display locale and currency",
+                    "type": "string",
+                    "format": "string",
+                    "example": "2 600 ₽"
+                },
+                "items": {
+                    "description": "Contributing subscriptions",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CostExplanationItem"
+                    }
+                },
+                "start_date": {
+                    "description": "Requested period start in MM-YYYY format",
+                    "type": "string",
+                    "format": "string",
+                    "example": "01-2024"
+                },
+                "total_cost": {
+                    "description": "Sum of item amounts, same as GET /subscriptions/total",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 2600
+                }
+            }
+        },
+        "models.CreateCreditRequest": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Discount per month in rubles, negative; either it or percent",
+                    "type": "integer",
+                    "format": "int",
+                    "example": -100
+                },
+                "description": {
+                    "description": "(Optional) Reason for the credit",
+                    "type": "string",
+                    "format": "string",
+                    "example": "Promo code SPRING"
+                },
+                "end_date": {
+                    "description": "(Optional) Last discounted month in MM-YYYY format, subscription end by default",
+                    "type": "string",
+                    "format": "string",
+                    "example": "03-2026"
+                },
+                "percent": {
+                    "description": "Discount per month as a share of the price, 1-100; either it or amount",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 50
+                },
+                "start_date": {
+                    "description": "First discounted month in MM-YYYY format",
+                    "type": "string",
+                    "format": "string",
+                    "example": "01-2026"
+                }
+            }
+        },
+        "models.CreateSubscriptionRequest": {
+            "type": "object",
+            "properties": {
+                "cost_center": {
+                    "description": "(Optional) Allocation tag for chargeback",
+                    "type": "string",
+                    "format": "string",
+                    "example": "CC-1042"
+                },
+                "end_date": {
+                    "description": "(Optional) End date in MM-YYYY format",
+                    "type": "string",
+                    "format": "string",
+                    "example": "02-2026"
+                },
+                "external_id": {
+                    "description": "(Optional) ID in the client's system, unique per user",
+                    "type": "string",
+                    "format": "string",
+                    "example": "crm-42"
+                },
+                "follow_plan_price": {
+                    "description": "(Optional) Keep price equal to the plan's official price as it changes",
+                    "type": "boolean",
+                    "format": "bool",
+                    "example": true
+                },
+                "id": {
+                    "description": "(Optional) Client-supplied subscription UUID",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "beef4269-0a1b-0c1F-afce-e13873b7b23b"
+                },
+                "org_unit_id": {
+                    "description": "(Optional) Department or team the cost is attributed to",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "beef4269-0a1b-0c1F-afce-e13873b7b23b"
+                },
+                "plan_id": {
+                    "description": "(Optional) Catalog plan, prefills omitted service name and price",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "beef4269-0a1b-0c1F-afce-e13873b7b23b"
+                },
+                "price": {
+                    "description": "Price in rubles, taken from the plan if omitted with plan_id",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 299
+                },
+                "project_code": {
+                    "description": "(Optional) Allocation tag for chargeback",
+                    "type": "string",
+                    "format": "string",
+                    "example": "APOLLO"
+                },
+                "service_name": {
+                    "description": "Name of the service",
+                    "type": "string",
+                    "format": "string",
+                    "example": "Telegram Premium"
+                },
+                "start_date": {
+                    "description": "Start date in MM-YYYY format",
+                    "type": "string",
+                    "format": "string",
+                    "example": "01-2026"
+                },
+                "type": {
+                    "description": "(Optional) \"one_time\" is charged only in its start month, \"recurring\" by default",
+                    "type": "string",
+                    "enum": [
+                        "recurring",
+                        "one_time"
+                    ],
+                    "example": "recurring"
+                },
+                "user_id": {
+                    "description": "User UUID",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "beef4269-0a1b-0c1F-afce-e13873b7b23b"
+                }
+            }
+        },
+        "models.CreateViewRequest": {
+            "type": "object",
+            "properties": {
+                "created_after": {
+                    "description": "(Optional) Only records created after this RFC3339 timestamp",
+                    "type": "string",
+                    "format": "date-time",
+                    "example": "2024-01-01T00:00:00Z"
+                },
+                "created_before": {
+                    "description": "(Optional) Only records created before this RFC3339 timestamp",
+                    "type": "string",
+                    "format": "date-time",
+                    "example": "2024-12-31T23:59:59Z"
+                },
+                "limit": {
+                    "description": "(Optional) Limit the number of results",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 50
+                },
+                "max_price": {
+                    "description": "(Optional) Only subscriptions costing at most this much",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 1000
+                },
+                "min_price": {
+                    "description": "(Optional) Only subscriptions costing at least this much",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 500
+                },
+                "name": {
+                    "description": "Name of the view, unique per user",
+                    "type": "string",
+                    "format": "string",
+                    "example": "Streaming"
+                },
+                "order": {
+                    "description": "(Optional) asc or desc",
+                    "type": "string",
+                    "format": "string",
+                    "example": "desc"
+                },
+                "service_name": {
+                    "description": "(Optional) Filter by service name",
+                    "type": "string",
+                    "format": "string",
+                    "example": "Netflix"
+                },
+                "sort_by": {
+                    "description": "(Optional) price, start_date, service_name or created_at",
+                    "type": "string",
+                    "format": "string",
+                    "example": "price"
+                }
+            }
+        },
+        "models.CreditResponse": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Discount per month in rubles, negative; 0 for percentage credits",
+                    "type": "integer",
+                    "format": "int",
+                    "example": -100
+                },
+                "created_at": {
+                    "description": "Creation time",
+                    "type": "string",
+                    "format": "date-time",
+                    "example": "2026-01-01T10:00:00Z"
+                },
+                "description": {
+                    "description": "Reason for the credit",
+                    "type": "string",
+                    "format": "string",
+                    "example": "Promo code SPRING"
+                },
+                "end_date": {
+                    "description": "(Optional) Last discounted month in MM-YYYY format",
+                    "type": "string",
+                    "format": "string",
+                    "example": "03-2026"
+                },
+                "id": {
+                    "description": "UUID of the credit",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "beef4269-0a1b-0c1F-afce-e13873b7b23b"
+                },
+                "percent": {
+                    "description": "(Optional) Discount per month as a share of the price",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 50
+                },
+                "start_date": {
+                    "description": "First discounted month in MM-YYYY format",
+                    "type": "string",
+                    "format": "string",
+                    "example": "01-2026"
+                },
+                "subscription_id": {
+                    "description": "UUID of discounted subscription",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "beef4269-0a1b-0c1F-afce-e13873b7b23b"
+                }
+            }
+        },
+        "models.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "One of Code* constants, stable for clients to branch on",
+                    "type": "string",
+                    "format": "string",
+                    "example": "SUBSCRIPTION_NOT_FOUND"
+                },
+                "error": {
+                    "description": "Returned error struct example",
+                    "type": "string",
+                    "format": "string",
+                    "example": "Subscription not found"
+                },
+                "fields": {
+                    "description": "(Optional) Invalid fields of the request, for validation errors",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FieldError"
+                    }
+                }
+            }
+        },
+        "models.ExpiringSubscriptionsResponse": {
+            "type": "object",
+            "properties": {
+                "from": {
+                    "description": "First month of the window in MM-YYYY format, the current one",
+                    "type": "string",
+                    "format": "string",
+                    "example": "03-2024"
+                },
+                "to": {
+                    "description": "Last month of the window in MM-YYYY format",
+                    "type": "string",
+                    "format": "string",
+                    "example": "05-2024"
+                },
+                "total_count": {
+                    "description": "Subscriptions over all users",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 12
+                },
+                "users": {
+                    "description": "Users with subscriptions ending in the window, ordered by user ID",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ExpiringUser"
+                    }
+                }
+            }
+        },
+        "models.ExpiringUser": {
+            "type": "object",
+            "properties": {
+                "subscriptions": {
+                    "description": "Subscriptions of the user ending in the window, soonest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SubscriptionResponse"
+                    }
+                },
+                "user_id": {
+                    "description": "User UUID",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                }
+            }
+        },
+        "models.FieldError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "description": "One of Code* constants",
+                    "type": "string",
+                    "format": "string",
+                    "example": "MUST_NOT_BE_NEGATIVE"
+                },
+                "field": {
+                    "description": "JSON name of the field, subscriptions[2].external_id for list items, empty if the request as a whole is invalid",
+                    "type": "string",
+                    "format": "string",
+                    "example": "price"
+                },
+                "message": {
+                    "description": "Human-readable description",
+                    "type": "string",
+                    "format": "string",
+                    "example": "price cannot be negative"
+                }
+            }
+        },
+        "models.ListSubscriptionsResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "description": "Page of subscriptions in requested order, newest first by default",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SubscriptionResponse"
+                    }
+                },
+                "limit": {
+                    "description": "Applied limit, null if unlimited",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 50
+                },
+                "next_offset": {
+                    "description": "Offset of the next page, null on the last one",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 50
+                },
+                "offset": {
+                    "description": "Applied offset",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 0
+                },
+                "total_count": {
+                    "description": "Subscriptions matching the filters regardless of paging",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 120
+                }
+            }
+        },
+        "models.OrgUnit": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "parent_id": {
+                    "description": "Nil for a root unit",
+                    "type": "string"
+                }
+            }
+        },
+        "models.OrgUnitCost": {
+            "type": "object",
+            "properties": {
+                "children": {
+                    "description": "Child units by name",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.OrgUnitCost"
+                    }
+                },
+                "id": {
+                    "description": "UUID of the unit",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "beef4269-0a1b-0c1F-afce-e13873b7b23b"
+                },
+                "name": {
+                    "description": "Name of the unit",
+                    "type": "string",
+                    "format": "string",
+                    "example": "Marketing"
+                },
+                "own_cost": {
+                    "description": "Cost of subscriptions attributed to the unit itself",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 1200
+                },
+                "total_cost": {
+                    "description": "Own cost plus total cost of all children",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 3600
+                }
+            }
+        },
+        "models.ParseDateRequest": {
+            "type": "object",
+            "required": [
+                "date"
+            ],
+            "properties": {
+                "date": {
+                    "description": "Month in MM-YYYY or human-style form, e.g. \"Jan 2024\", \"января 2024 г.\", \"01.2024\"",
+                    "type": "string",
+                    "format": "string",
+                    "example": "январь 2024"
+                }
+            }
+        },
+        "models.ParseDateResponse": {
+            "type": "object",
+            "properties": {
+                "date": {
+                    "description": "The month in MM-YYYY format",
+                    "type": "string",
+                    "format": "string",
+                    "example": "01-2024"
+                }
+            }
+        },
+        "models.PauseSubscriptionRequest": {
+            "type": "object",
+            "properties": {
+                "month": {
+                    "description": "(Optional) Month to pause from or resume in, MM-YYYY, current month by default",
+                    "type": "string",
+                    "format": "string",
+                    "example": "06-2026"
+                }
+            }
+        },
+        "models.PausedPeriodResponse": {
             "type": "object",
             "properties": {
+                "created_at": {
+                    "description": "Creation time",
+                    "type": "string",
+                    "format": "date-time",
+                    "example": "2026-06-01T10:00:00Z"
+                },
                 "end_date": {
-                    "description": "(Optional) End date in MM-YYYY format",
+                    "description": "(Optional) Last paused month in MM-YYYY format, absent while paused",
+                    "type": "string",
+                    "format": "string",
+                    "example": "08-2026"
+                },
+                "id": {
+                    "description": "UUID of the pause",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "beef4269-0a1b-0c1F-afce-e13873b7b23b"
+                },
+                "start_date": {
+                    "description": "First paused month in MM-YYYY format",
+                    "type": "string",
+                    "format": "string",
+                    "example": "06-2026"
+                },
+                "subscription_id": {
+                    "description": "UUID of paused subscription",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "beef4269-0a1b-0c1F-afce-e13873b7b23b"
+                }
+            }
+        },
+        "models.Plan": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "integer"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.RenewSubscriptionRequest": {
+            "type": "object",
+            "properties": {
+                "months": {
+                    "description": "Months to add to the end date, a fixed term for an open-ended subscription",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 12
+                }
+            }
+        },
+        "models.SavedView": {
+            "type": "object",
+            "properties": {
+                "created_after": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "created_before": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "limit": {
+                    "type": "integer"
+                },
+                "max_price": {
+                    "type": "integer"
+                },
+                "min_price": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "order": {
+                    "type": "string"
+                },
+                "service_name": {
+                    "type": "string"
+                },
+                "sort_by": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SubscriptionResponse": {
+            "type": "object",
+            "properties": {
+                "cancelled_at": {
+                    "description": "(Optional) When the subscription was cancelled",
+                    "type": "string",
+                    "format": "date-time",
+                    "example": "2026-01-15T10:00:00Z"
+                },
+                "cost_center": {
+                    "description": "(Optional) Allocation tag for chargeback",
+                    "type": "string",
+                    "format": "string",
+                    "example": "CC-1042"
+                },
+                "created_at": {
+                    "description": "Creation time",
+                    "type": "string",
+                    "format": "date-time",
+                    "example": "2026-01-01T10:00:00Z"
+                },
+                "end_date": {
+                    "description": "(Optional) End date in MM-YYYY format, absent if open-ended",
                     "type": "string",
                     "format": "string",
                     "example": "02-2026"
                 },
+                "external_id": {
+                    "description": "(Optional) ID in the client's system",
+                    "type": "string",
+                    "format": "string",
+                    "example": "crm-42"
+                },
+                "follow_plan_price": {
+                    "description": "(Optional) Price is kept equal to the plan's official price",
+                    "type": "boolean",
+                    "format": "bool",
+                    "example": true
+                },
+                "id": {
+                    "description": "UUID of subscription",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "beef4269-0a1b-0c1F-afce-e13873b7b23b"
+                },
+                "org_unit_id": {
+                    "description": "(Optional) Department or team the cost is attributed to",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "beef4269-0a1b-0c1F-afce-e13873b7b23b"
+                },
+                "plan_id": {
+                    "description": "(Optional) Plan the subscription is on",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "beef4269-0a1b-0c1F-afce-e13873b7b23b"
+                },
                 "price": {
                     "description": "Price in rubles",
                     "type": "integer",
                     "format": "int",
                     "example": 299
                 },
+                "project_code": {
+                    "description": "(Optional) Allocation tag for chargeback",
+                    "type": "string",
+                    "format": "string",
+                    "example": "APOLLO"
+                },
                 "service_name": {
                     "description": "Name of the service",
                     "type": "string",
@@ -340,51 +3017,199 @@ const docTemplate = `{
                     "format": "string",
                     "example": "01-2026"
                 },
+                "status": {
+                    "description": "Status in the current month",
+                    "type": "string",
+                    "enum": [
+                        "active",
+                        "upcoming",
+                        "expired",
+                        "cancelled",
+                        "deleted"
+                    ],
+                    "example": "active"
+                },
+                "type": {
+                    "description": "Subscription type",
+                    "type": "string",
+                    "enum": [
+                        "recurring",
+                        "one_time"
+                    ],
+                    "example": "recurring"
+                },
+                "updated_at": {
+                    "description": "Last change time",
+                    "type": "string",
+                    "format": "date-time",
+                    "example": "2026-01-01T10:00:00Z"
+                },
                 "user_id": {
-                    "description": "User UUID",
+                    "description": "UUID of the user",
                     "type": "string",
                     "format": "uuid",
-                    "example": "beef4269-0a1b-0c1F-afce-e13873b7b23b"
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                },
+                "version": {
+                    "description": "Same as ETag, incremented by every change",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 1
                 }
             }
         },
-        "models.ErrorResponse": {
+        "models.SuggestServicesResponse": {
             "type": "object",
             "properties": {
-                "error": {
-                    "description": "Returned error struct example",
+                "services": {
+                    "description": "Distinct matching service names in alphabetical order",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "Netflix",
+                        "Netflix Premium"
+                    ]
+                }
+            }
+        },
+        "models.SyncChange": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "description": "What sync does with the subscription",
+                    "type": "string",
+                    "enum": [
+                        "create",
+                        "update",
+                        "delete"
+                    ],
+                    "example": "update"
+                },
+                "after": {
+                    "description": "(Optional) Desired state, absent for delete",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SubscriptionResponse"
+                        }
+                    ]
+                },
+                "before": {
+                    "description": "(Optional) Current state, absent for create",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.SubscriptionResponse"
+                        }
+                    ]
+                },
+                "external_id": {
+                    "description": "ID in the client's system",
                     "type": "string",
                     "format": "string",
-                    "example": "Subscription not found"
+                    "example": "crm-42"
                 }
             }
         },
-        "models.Subscription": {
+        "models.SyncSubscriptionItem": {
             "type": "object",
             "properties": {
                 "end_date": {
-                    "type": "string"
+                    "description": "(Optional) End date in MM-YYYY format",
+                    "type": "string",
+                    "format": "string",
+                    "example": "02-2026"
                 },
-                "id": {
-                    "type": "string"
+                "external_id": {
+                    "description": "ID in the client's system, matches desired and existing subscriptions",
+                    "type": "string",
+                    "format": "string",
+                    "example": "crm-42"
                 },
                 "price": {
-                    "type": "integer"
+                    "description": "Price in rubles",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 299
                 },
                 "service_name": {
-                    "type": "string"
+                    "description": "Name of the service",
+                    "type": "string",
+                    "format": "string",
+                    "example": "Telegram Premium"
                 },
                 "start_date": {
-                    "type": "string"
+                    "description": "Start date in MM-YYYY format",
+                    "type": "string",
+                    "format": "string",
+                    "example": "01-2026"
                 },
-                "user_id": {
-                    "type": "string"
+                "type": {
+                    "description": "(Optional) Subscription type, \"recurring\" by default",
+                    "type": "string",
+                    "enum": [
+                        "recurring",
+                        "one_time"
+                    ],
+                    "example": "recurring"
+                }
+            }
+        },
+        "models.SyncSubscriptionsRequest": {
+            "type": "object",
+            "required": [
+                "subscriptions"
+            ],
+            "properties": {
+                "subscriptions": {
+                    "description": "Full desired set of user's subscriptions managed by sync, may be empty",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SyncSubscriptionItem"
+                    }
+                }
+            }
+        },
+        "models.SyncSubscriptionsResponse": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "description": "Planned or applied changes, creates first, then updates and deletes",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SyncChange"
+                    }
+                },
+                "dry_run": {
+                    "description": "True if changes were only planned",
+                    "type": "boolean",
+                    "format": "bool",
+                    "example": false
+                },
+                "unchanged": {
+                    "description": "Subscriptions already matching the desired state",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 3
                 }
             }
         },
         "models.TotalCostResponse": {
             "type": "object",
             "properties": {
+                "breakdown": {
+                    "description": "(Optional) Total split by group_by, sums up to total_cost",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CostBreakdownItem"
+                    }
+                },
+                "formatted_total_cost": {
+                    "description": "Total cost in the display locale and currency",
+                    "type": "string",
+                    "format": "string",
+                    "example": "3 600 ₽"
+                },
                 "total_cost": {
                     "description": "Total cost in y.e.",
                     "type": "integer",
@@ -393,21 +3218,50 @@ const docTemplate = `{
                 }
             }
         },
+        "models.UndoToken": {
+            "type": "object",
+            "properties": {
+                "undo_expires_at": {
+                    "type": "string"
+                },
+                "undo_token": {
+                    "type": "string"
+                }
+            }
+        },
         "models.UpdateSubscriptionRequest": {
             "type": "object",
             "properties": {
+                "cost_center": {
+                    "description": "(Optional) Updated cost center, send empty string (\"\") to clear",
+                    "type": "string",
+                    "format": "string",
+                    "example": "CC-1042"
+                },
                 "end_date": {
                     "description": "(Optional) Updated end date of subscription, send empty string (\"\") to clear",
                     "type": "string",
                     "format": "string",
                     "example": "02-2027"
                 },
+                "org_unit_id": {
+                    "description": "(Optional) Updated org unit, send empty string (\"\") to detach",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "beef4269-0a1b-0c1F-afce-e13873b7b23b"
+                },
                 "price": {
                     "description": "(Optional) Updated price of the subscription",
                     "type": "integer",
                     "format": "int",
                     "example": 299
                 },
+                "project_code": {
+                    "description": "(Optional) Updated project code, send empty string (\"\") to clear",
+                    "type": "string",
+                    "format": "string",
+                    "example": "APOLLO"
+                },
                 "service_name": {
                     "description": "(Optional) Updated name of the service",
                     "type": "string",
@@ -421,6 +3275,70 @@ const docTemplate = `{
                     "example": "02-2026"
                 }
             }
+        },
+        "models.UserSummaryResponse": {
+            "type": "object",
+            "properties": {
+                "active_subscriptions": {
+                    "description": "Subscriptions active in the current month",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 3
+                },
+                "earliest_start_date": {
+                    "description": "(Optional) Earliest start of any subscription in MM-YYYY format, absent if user has none",
+                    "type": "string",
+                    "format": "string",
+                    "example": "01-2022"
+                },
+                "formatted_monthly_cost": {
+                    "description": "Monthly cost in the display locale and currency",
+                    "type": "string",
+                    "format": "string",
+                    "example": "1 097 ₽"
+                },
+                "latest_start_date": {
+                    "description": "(Optional) Latest start of any subscription in MM-YYYY format, absent if user has none",
+                    "type": "string",
+                    "format": "string",
+                    "example": "06-2025"
+                },
+                "monthly_cost": {
+                    "description": "Sum of prices of active recurring subscriptions, before credits",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 1097
+                },
+                "most_expensive_service": {
+                    "description": "(Optional) Active recurring subscription with the highest price, absent if none",
+                    "type": "string",
+                    "format": "string",
+                    "example": "Netflix"
+                },
+                "user_id": {
+                    "description": "User UUID",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                }
+            }
+        },
+        "models.UserTotalCost": {
+            "type": "object",
+            "properties": {
+                "total_cost": {
+                    "description": "Total cost in y.e., 0 if user has no subscriptions in period",
+                    "type": "integer",
+                    "format": "int",
+                    "example": 3600
+                },
+                "user_id": {
+                    "description": "User UUID",
+                    "type": "string",
+                    "format": "uuid",
+                    "example": "550e8400-e29b-41d4-a716-446655440000"
+                }
+            }
         }
     }
 }`
@@ -1,8 +1,14 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
@@ -12,17 +18,39 @@ import (
 	"subscription-aggregator-service/docs"
 	ctrl "subscription-aggregator-service/internal/api/controllers"
 	"subscription-aggregator-service/internal/api/middlewares"
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/api/ui"
 	"subscription-aggregator-service/internal/config"
 	"subscription-aggregator-service/internal/logger"
+	"subscription-aggregator-service/internal/utils/display"
 	"subscription-aggregator-service/internal/utils/graceful"
 )
 
 type API struct {
-	engine *gin.Engine
-	ctrl   *ctrl.SubscriptionController
+	engine  *gin.Engine
+	ctrl    *ctrl.SubscriptionController
+	health  *ctrl.HealthController
+	admin   *ctrl.AdminController
+	metrics *ctrl.MetricsController
+
+	deprecations *middlewares.DeprecationTracker
+
+	server *graceful.Server // Set while started
+}
+
+func NewAPI(ctrl *ctrl.SubscriptionController, health *ctrl.HealthController, admin *ctrl.AdminController, metrics *ctrl.MetricsController) *API {
+	basePath := viper.GetString(config.ApiBasePath)
+	features, err := config.Deprecations()
+	if err != nil { // Validated on config load
+		log.Fatalf("Fatal: %v", err)
+	}
+	a := &API{engine: NewEngine(basePath, viper.GetString(config.ApiV2BasePath)), ctrl: ctrl, health: health, admin: admin, metrics: metrics, deprecations: middlewares.NewDeprecationTracker(features)}
+	a.registerRoutes()
+	return a
 }
 
-func NewAPI(ctrl *ctrl.SubscriptionController) *API {
+// NewEngine creates gin engine with global middlewares for API versions mounted at basePaths, empty ones are skipped
+func NewEngine(basePaths ...string) *gin.Engine {
 	if viper.GetBool(config.GinReleaseMode) && viper.GetString(config.LogLevel) != "DEBUG" {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -31,43 +59,172 @@ func NewAPI(ctrl *ctrl.SubscriptionController) *API {
 	e.Use(gin.Recovery())
 	e.Use(logger.GinLoggerMiddleware())
 	e.Use(middlewares.RequestID())
-	a := &API{engine: e, ctrl: ctrl}
-	a.registerRoutes()
-	return a
+	e.Use(logger.ContextMiddleware())
+	if viper.GetBool(config.ApiPublicEnabled) || config.ReadOnly() { // Engine-wide, so no route registered later can accept writes
+		var reads []string
+		for _, basePath := range basePaths {
+			for _, route := range ctrl.ReadRoutes {
+				method, path, _ := strings.Cut(route, " ")
+				reads = append(reads, method+" "+basePath+path)
+			}
+		}
+		e.Use(middlewares.ReadOnly(reads...))
+	}
+	e.Use(cachePolicy(basePaths...))
+	return e
+}
+
+// cachePolicy keeps subscription data out of any cache, while suggestions may be cached as long as the service caches them itself
+func cachePolicy(basePaths ...string) gin.HandlerFunc {
+	rules := []middlewares.CacheRule{ // First match wins
+		middlewares.Private("/swagger/"), // Behind admin auth
+		middlewares.Revalidate("/openapi.json"),
+		middlewares.NoStore("/status"),
+		middlewares.NoStore("/admin"),
+		middlewares.NoStore("/metrics"),
+	}
+	for _, basePath := range basePaths {
+		if basePath != "" {
+			rules = append(rules, middlewares.Public(basePath+"/services/suggest", 30*time.Second), middlewares.NoStore(basePath))
+		}
+	}
+	return middlewares.CachePolicy(rules...)
+}
+
+// Middlewares returns middlewares of the single API group under basePath enabled in config
+func Middlewares(basePath string) []gin.HandlerFunc {
+	return append(SharedMiddlewares(), GroupMiddlewares(basePath)...)
+}
+
+// SharedMiddlewares returns authentication and limits enabled in config. They keep state, so API versions must share
+// one set: a client gets the same limits and a signature can't be replayed whichever version it calls.
+func SharedMiddlewares() []gin.HandlerFunc {
+	var mws []gin.HandlerFunc
+	if viper.GetBool(config.ApiPublicEnabled) { // Anonymous demo access: no signatures, limited by IP instead
+		mws = append(mws, middlewares.RateLimit(viper.GetInt(config.ApiPublicRatePerMinute)))
+	} else if viper.GetBool(config.AuthHmacEnabled) {
+		mws = append(mws, middlewares.HMACAuth(viper.GetStringMapString(config.AuthHmacKeys), viper.GetDuration(config.AuthHmacMaxSkew)))
+	}
+	if limit := viper.GetInt(config.ApiConcurrencyPerKey); limit > 0 { // After HMAC, so signed clients are limited by key
+		mws = append(mws, middlewares.ConcurrencyLimit(limit))
+	}
+	return mws
+}
+
+// GroupMiddlewares returns stateless middlewares of the API group under basePath enabled in config, run after shared ones
+func GroupMiddlewares(basePath string) []gin.HandlerFunc {
+	var mws []gin.HandlerFunc
+	routes, err := config.RouteTimeouts()
+	if err != nil { // Validated on config load
+		log.Fatalf("Fatal: %v", err)
+	}
+	if def := viper.GetDuration(config.ApiTimeoutDefault); def > 0 || len(routes) > 0 {
+		mws = append(mws, middlewares.Timeout(basePath, def, routes))
+	}
+	rates, err := config.CurrencyRates()
+	if err != nil { // Validated on config load
+		log.Fatalf("Fatal: %v", err)
+	}
+	mws = append(mws, middlewares.DisplayPrefs(display.NewNegotiator(viper.GetString(config.DisplayLocale), viper.GetString(config.DisplayCurrency), rates)))
+	return mws
 }
 
 func (a *API) registerRoutes() {
-	// API
-	base := a.engine.Group(viper.GetString(config.ApiBasePath))
+	// API, versions share controllers, authentication and limits and differ in serialization of responses
+	shared := SharedMiddlewares()
+	version := func(basePath string, serialization apiModels.Serialization) *gin.RouterGroup {
+		mws := append(slices.Clone(shared), GroupMiddlewares(basePath)...)
+		mws = append(mws, middlewares.Serialization(serialization),
+			a.deprecations.Middleware(basePath), a.metrics.CountCalls()) // Last, so signed clients are counted by key
+		return a.engine.Group(basePath, mws...)
+	}
+	a.ctrl.RegisterRoutes(version(viper.GetString(config.ApiBasePath), apiModels.SerializationV1()))
+	if basePath := viper.GetString(config.ApiV2BasePath); basePath != "" {
+		a.ctrl.RegisterRoutes(version(basePath, apiModels.SerializationV2()))
+	}
+	// Health
 	{
-		//subscriptions := base.Group("/subscriptions")
-		{
-			base.POST("/subscriptions", a.ctrl.CreateSubscription)
-			base.GET("/subscriptions/total", a.ctrl.TotalSubscriptionsCost) // Must be above parameterized route to avoid conflict
-			base.GET("/subscriptions/:id", a.ctrl.GetSubscriptionByID)
-			base.PUT("/subscriptions/:id", a.ctrl.UpdateSubscriptionByID)
-			base.DELETE("/subscriptions/:id", a.ctrl.DeleteSubscriptionByID)
-			base.GET("/subscriptions", a.ctrl.ListSubscriptions)
+		a.engine.GET("/status", a.health.Status)
+	}
+	// Metrics
+	if tokens := viper.GetStringMapString(config.MetricsTokens); len(tokens) > 0 {
+		a.engine.GET("/metrics/org-units/:id", middlewares.MetricsAuth(tokens), a.metrics.OrgUnitMetrics)
+	}
+	if token := viper.GetString(config.AdminToken); token != "" {
+		a.engine.GET("/metrics/database", middlewares.AdminAuth(token), a.health.DatabaseMetrics)
+	}
+	// Admin
+	if token := viper.GetString(config.AdminToken); token != "" {
+		admin := a.engine.Group("/admin", middlewares.AdminAuth(token))
+		a.admin.RegisterRoutes(admin)
+		admin.GET("/deprecations", a.deprecationUsage)
+	}
+	// Dashboard
+	if viper.GetBool(config.ApiUiEnabled) {
+		if err := ui.Register(a.engine, viper.GetString(config.ApiBasePath)); err != nil {
+			log.Fatalf("Fatal: failed to load embedded UI: %v", err)
 		}
 	}
-	// Swagger
-	{
+	// Docs
+	if viper.GetBool(config.ApiDocsEnabled) {
 		{
 			docs.SwaggerInfo.Title = "Subscription Aggregator Service"
 			docs.SwaggerInfo.Description = "CRUD API for managing user subscriptions"
 			docs.SwaggerInfo.Version = "1.0"
-			docs.SwaggerInfo.Host = fmt.Sprintf("%s:%s", viper.GetString(config.ApiHost), viper.GetString(config.ApiPort))
 			docs.SwaggerInfo.BasePath = viper.GetString(config.ApiBasePath)
 		}
-		a.engine.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+		a.engine.GET("/openapi.json", openAPI)
+		if token := viper.GetString(config.AdminToken); token != "" {
+			a.engine.GET("/swagger/*any", middlewares.AdminAuth(token), ginSwagger.WrapHandler(swaggerFiles.Handler, ginSwagger.URL("/openapi.json")))
+		} else {
+			slog.Warn("Swagger UI is disabled, set admin token to enable it", "key", config.AdminToken)
+		}
+	}
+}
+
+// openAPI serves the spec with host and scheme of the incoming request, so it's right behind any proxy or port mapping
+func openAPI(c *gin.Context) {
+	spec := *docs.SwaggerInfo
+	spec.Host = c.Request.Host
+	spec.Schemes = []string{"http"}
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		spec.Schemes = []string{"https"}
 	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(spec.ReadDoc()))
+}
+
+// deprecationUsage shows who still calls features marked deprecated in config, to decide when they can be removed
+func (a *API) deprecationUsage(c *gin.Context) {
+	c.JSON(http.StatusOK, a.deprecations.Usage())
 }
 
-func (a *API) Run() {
+// Start serves the API in background, a busy address is reported right away
+func (a *API) Start() error {
 	address := fmt.Sprintf("%s:%s", viper.GetString(config.ApiHost), viper.GetString(config.ApiPort))
+	server, err := graceful.Serve(a.engine, address)
+	if err != nil {
+		return err
+	}
+	a.server = server
 	fmt.Printf("API server listening on %s... \n", address)
+	return nil
+}
+
+// Done is closed when the server stops on its own or after Stop, nil until Start
+func (a *API) Done() <-chan struct{} {
+	if a.server == nil {
+		return nil
+	}
+	return a.server.Done()
+}
 
-	if err := graceful.RunGin(a.engine, address, viper.GetDuration(config.ApiShutdownTimeout)); err != nil {
-		log.Printf("API server shutdown error: %v", err)
+// Stop waits for active requests until ctx is done and returns the error the server stopped with, if any.
+// It's a no-op if the API wasn't started.
+func (a *API) Stop(ctx context.Context) error {
+	if a.server == nil {
+		return nil
 	}
+	err := a.server.Shutdown(ctx)
+	a.server = nil
+	return err
 }
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"testing"
+
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+
+	ctrl "subscription-aggregator-service/internal/api/controllers"
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/config"
+	"subscription-aggregator-service/internal/models"
+	"subscription-aggregator-service/internal/service"
+)
+
+func TestOpenAPIUsesRequestHost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/openapi.json", openAPI)
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	req.Host = "subs.example.com:8443"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var spec struct {
+		Host    string   `json:"host"`
+		Schemes []string `json:"schemes"`
+		Paths   map[string]any
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("spec is not valid JSON: %v", err)
+	}
+	if spec.Host != "subs.example.com:8443" {
+		t.Errorf("host = %q, want the request host", spec.Host)
+	}
+	if len(spec.Schemes) != 1 || spec.Schemes[0] != "https" {
+		t.Errorf("schemes = %v, want [https]", spec.Schemes)
+	}
+	if len(spec.Paths) == 0 {
+		t.Error("spec has no paths")
+	}
+}
+
+func TestPublicModeIsReadOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	viper.Set(config.ApiPublicEnabled, true)
+	viper.Set(config.ApiPublicRatePerMinute, 100)
+	t.Cleanup(func() {
+		viper.Set(config.ApiPublicEnabled, false)
+		viper.Set(config.ApiPublicRatePerMinute, 0)
+	})
+
+	e := NewEngine("/api/v1")
+	base := e.Group("/api/v1", Middlewares("/api/v1")...)
+	base.GET("/subscriptions", func(c *gin.Context) { c.Status(http.StatusOK) })
+	base.POST("/subscriptions", func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	for method, want := range map[string]int{http.MethodGet: http.StatusOK, http.MethodPost: http.StatusMethodNotAllowed} {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(method, "/api/v1/subscriptions", nil))
+		if w.Code != want {
+			t.Errorf("%s /subscriptions status = %d, want %d", method, w.Code, want)
+		}
+	}
+}
+
+func TestReadOnlyProfileRejectsWrites(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	viper.Set(config.Profile, config.ProfileReadOnly)
+	t.Cleanup(func() { viper.Set(config.Profile, config.ProfileFull) })
+
+	e := NewEngine("/api/v1")
+	e.GET("/api/v1/subscriptions/total", func(c *gin.Context) { c.Status(http.StatusOK) })
+	e.DELETE("/api/v1/subscriptions/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	e.POST("/admin/integrity/check", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, tt := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/api/v1/subscriptions/total", http.StatusOK},
+		{http.MethodDelete, "/api/v1/subscriptions/1", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/admin/integrity/check", http.StatusMethodNotAllowed},
+	} {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s %s status = %d, want %d", tt.method, tt.path, w.Code, tt.want)
+		}
+	}
+}
+
+func TestReadOnlyProfileServesPostReads(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for key, value := range map[string]any{config.Profile: config.ProfileReadOnly, config.ApiBasePath: "/api/v1", config.ApiV2BasePath: "/api/v2"} {
+		prev := viper.Get(key)
+		viper.Set(key, value)
+		t.Cleanup(func() { viper.Set(key, prev) })
+	}
+	svc := &stubService{}
+	a := NewAPI(ctrl.NewSubscriptionController(svc), ctrl.NewHealthController(nil, nil), ctrl.NewAdminController(svc), ctrl.NewMetricsController(svc, nil))
+
+	userID := uuid.NewString()
+	for _, tt := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/api/v1/subscriptions/total/batch", `{"user_ids": ["` + userID + `"], "start_date": "01-2024", "end_date": "12-2024"}`, http.StatusOK},
+		{"/api/v2/subscriptions/total/batch", `{"user_ids": ["` + userID + `"], "start_date": "01-2024", "end_date": "12-2024"}`, http.StatusOK},
+		{"/api/v1/utils/parse-date", `{"date": "2024-03"}`, http.StatusOK},
+		{"/api/v1/subscriptions/batch", `{"subscriptions": []}`, http.StatusMethodNotAllowed},
+	} {
+		w := httptest.NewRecorder()
+		a.engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("POST %s status = %d, want %d, body: %s", tt.path, w.Code, tt.want, w.Body.String())
+		}
+	}
+}
+
+// stubService answers GetSubscriptionByID and TotalSubscriptionsCostBatch, other methods panic via the nil embedded interface
+type stubService struct {
+	service.SubscriptionService
+}
+
+func (s *stubService) GetSubscriptionByID(ctx context.Context, id apiModels.ItemByIDRequest) (*models.Subscription, error) {
+	return &models.Subscription{ID: uuid.MustParse(id.ID), ServiceName: "Netflix", UserID: uuid.New(), Type: models.TypeRecurring,
+		StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, nil
+}
+
+func (s *stubService) TotalSubscriptionsCostBatch(ctx context.Context, req apiModels.BatchTotalCostRequest) (*apiModels.BatchTotalCostResponse, error) {
+	resp := &apiModels.BatchTotalCostResponse{}
+	for _, id := range req.UserIDs {
+		resp.Totals = append(resp.Totals, apiModels.UserTotalCost{UserID: uuid.MustParse(id)})
+	}
+	return resp, nil
+}
+
+func TestVersionsShareControllers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for key, value := range map[string]any{config.ApiBasePath: "/api/v1", config.ApiV2BasePath: "/api/v2"} {
+		prev := viper.Get(key)
+		viper.Set(key, value)
+		t.Cleanup(func() { viper.Set(key, prev) })
+	}
+	svc := &stubService{}
+	a := NewAPI(ctrl.NewSubscriptionController(svc), ctrl.NewHealthController(nil, nil), ctrl.NewAdminController(svc), ctrl.NewMetricsController(svc, nil))
+
+	for _, tt := range []struct {
+		version, startDate string
+	}{
+		{"v1", "2024-01-01T00:00:00Z"}, // Stable without app.api.date_format
+		{"v2", "01-2024"},
+	} {
+		w := httptest.NewRecorder()
+		a.engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/"+tt.version+"/subscriptions/"+uuid.NewString(), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s status = %d, want 200, body: %s", tt.version, w.Code, w.Body.String())
+		}
+		var resp apiModels.SubscriptionResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s response is not valid JSON: %v", tt.version, err)
+		}
+		if resp.StartDate != tt.startDate {
+			t.Errorf("%s start_date = %q, want %q", tt.version, resp.StartDate, tt.startDate)
+		}
+		if got := w.Header().Get("API-Version"); got != tt.version {
+			t.Errorf("%s API-Version = %q", tt.version, got)
+		}
+		if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+			t.Errorf("%s Cache-Control = %q, want no-store", tt.version, cc)
+		}
+	}
+}
+
+func TestVersionsShareLimitsAndDeprecations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for key, value := range map[string]any{
+		config.ApiBasePath: "/api/v1", config.ApiV2BasePath: "/api/v2",
+		config.ApiPublicEnabled: true, config.ApiPublicRatePerMinute: 2,
+		config.ApiDeprecations: map[string]any{"get /subscriptions/:id": ""},
+	} {
+		prev := viper.Get(key)
+		viper.Set(key, value)
+		t.Cleanup(func() { viper.Set(key, prev) })
+	}
+	svc := &stubService{}
+	a := NewAPI(ctrl.NewSubscriptionController(svc), ctrl.NewHealthController(nil, nil), ctrl.NewAdminController(svc), ctrl.NewMetricsController(svc, nil))
+
+	for i, tt := range []struct {
+		version string
+		want    int
+	}{
+		{"v1", http.StatusOK},
+		{"v2", http.StatusOK},
+		{"v1", http.StatusTooManyRequests}, // Third request of the minute, whichever version it calls
+	} {
+		w := httptest.NewRecorder()
+		a.engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/"+tt.version+"/subscriptions/"+uuid.NewString(), nil))
+		if w.Code != tt.want {
+			t.Fatalf("request %d to %s status = %d, want %d", i+1, tt.version, w.Code, tt.want)
+		}
+		if tt.want == http.StatusOK && w.Header().Get("Deprecation") != "true" {
+			t.Errorf("%s Deprecation = %q, want true", tt.version, w.Header().Get("Deprecation"))
+		}
+	}
+	if usage := a.deprecations.Usage(); len(usage) != 1 || usage[0].Calls != 2 {
+		t.Errorf("deprecation usage = %+v, want 2 calls over both versions", usage)
+	}
+}
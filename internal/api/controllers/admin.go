@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/service"
+)
+
+// AdminController serves operator endpoints. Mounted under /admin behind the admin token, so they're not part of the Swagger spec.
+type AdminController struct {
+	subscriptionService service.SubscriptionService
+}
+
+func NewAdminController(ss service.SubscriptionService) *AdminController {
+	return &AdminController{subscriptionService: ss}
+}
+
+func (ctrl *AdminController) RegisterRoutes(r gin.IRoutes) {
+	r.GET("/integrity/findings", ctrl.ListIntegrityFindings)
+	r.POST("/integrity/check", ctrl.CheckIntegrity)
+	r.POST("/subscriptions/import", ctrl.ImportSubscriptions)
+	r.POST("/plans", ctrl.CreatePlan)
+	r.PUT("/plans/:id", ctrl.UpdatePlanPrice)
+	r.POST("/org-units", ctrl.CreateOrgUnit)
+	r.POST("/services/rename", ctrl.RenameService)
+	r.DELETE("/subscriptions/:id/purge", ctrl.PurgeSubscription)
+	r.DELETE("/users/:id/data", ctrl.PurgeUserData)
+}
+
+// ListIntegrityFindings returns anomalies found by the last integrity check
+func (ctrl *AdminController) ListIntegrityFindings(ctx *gin.Context) {
+	findings, err := ctrl.subscriptionService.ListIntegrityFindings(ctx.Request.Context())
+	if err != nil {
+		serverError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, findings)
+}
+
+// CheckIntegrity runs the integrity check now instead of waiting for the schedule and returns its findings
+func (ctrl *AdminController) CheckIntegrity(ctx *gin.Context) {
+	findings, err := ctrl.subscriptionService.CheckIntegrity(ctx.Request.Context())
+	if err != nil {
+		serverError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, findings)
+}
+
+// ImportSubscriptions creates subscriptions from a trusted source in one transaction.
+// Validations listed in ?allow= (historical_start, long_duration, month_names) are skipped, the rest still apply.
+func (ctrl *AdminController) ImportSubscriptions(ctx *gin.Context) {
+	var query apiModels.ImportSubscriptionsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	var req apiModels.ImportSubscriptionsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
+		return
+	}
+
+	resp, err := ctrl.subscriptionService.ImportSubscriptions(ctx.Request.Context(), &req, query.Allow)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrConflict):
+			ctx.JSON(http.StatusConflict, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, resp)
+}
+
+// CreatePlan adds a plan to the catalog
+func (ctrl *AdminController) CreatePlan(ctx *gin.Context) {
+	var req apiModels.CreatePlanRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
+		return
+	}
+
+	plan, err := ctrl.subscriptionService.CreatePlan(ctx.Request.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrPlanConflict):
+			ctx.JSON(http.StatusConflict, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, plan)
+}
+
+// CreateOrgUnit adds a unit to the organization tree, under parent_id or as a root
+func (ctrl *AdminController) CreateOrgUnit(ctx *gin.Context) {
+	var req apiModels.CreateOrgUnitRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
+		return
+	}
+
+	unit, err := ctrl.subscriptionService.CreateOrgUnit(ctx.Request.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrOrgUnitConflict):
+			ctx.JSON(http.StatusConflict, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, unit)
+}
+
+// UpdatePlanPrice records an official price change and applies it to subscriptions following the plan
+func (ctrl *AdminController) UpdatePlanPrice(ctx *gin.Context) {
+	var id apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&id); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	var req apiModels.UpdatePlanPriceRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
+		return
+	}
+
+	resp, err := ctrl.subscriptionService.UpdatePlanPrice(ctx.Request.Context(), id, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrPlanNotFound):
+			ctx.JSON(http.StatusNotFound, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// RenameService renames a service across subscriptions in batches and returns the audit record of the rename
+func (ctrl *AdminController) RenameService(ctx *gin.Context) {
+	var req apiModels.RenameServiceRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
+		return
+	}
+
+	rename, err := ctrl.subscriptionService.RenameService(ctx.Request.Context(), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrValidationError) {
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		} else {
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rename)
+}
+
+// PurgeSubscription permanently deletes a subscription for an erasure request and returns the audit record
+func (ctrl *AdminController) PurgeSubscription(ctx *gin.Context) {
+	var id apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&id); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	record, err := ctrl.subscriptionService.PurgeSubscription(ctx.Request.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrNotFound):
+			ctx.JSON(http.StatusNotFound, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, record)
+}
+
+// PurgeUserData permanently deletes all data of a user for an erasure request and returns the audit record
+func (ctrl *AdminController) PurgeUserData(ctx *gin.Context) {
+	var user apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&user); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	record, err := ctrl.subscriptionService.PurgeUserData(ctx.Request.Context(), user)
+	if err != nil {
+		if errors.Is(err, service.ErrValidationError) {
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		} else {
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, record)
+}
@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"testing"
+
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/models"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata/")
+
+// jsonShape replaces every value in a decoded JSON document with its type name,
+// so golden files pin field names and types but not volatile values like IDs and timestamps
+func jsonShape(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, inner := range val {
+			out[k] = jsonShape(inner)
+		}
+		return out
+	case []any:
+		if len(val) == 0 {
+			return []any{}
+		}
+		merged := jsonShape(val[0])
+		for _, inner := range val[1:] {
+			merged = mergeShapes(merged, jsonShape(inner))
+		}
+		return []any{merged}
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	default:
+		return "unknown"
+	}
+}
+
+// mergeShapes unions object shapes of array elements, so optional fields
+// (e.g. end_date) don't make the golden output depend on element order
+func mergeShapes(a, b any) any {
+	am, aok := a.(map[string]any)
+	bm, bok := b.(map[string]any)
+	if !aok || !bok {
+		return a
+	}
+	out := make(map[string]any, len(am))
+	for k, v := range am {
+		out[k] = v
+	}
+	for k, v := range bm {
+		if existing, ok := out[k]; ok {
+			out[k] = mergeShapes(existing, v)
+		} else {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func assertGoldenShape(t *testing.T, name string, body []byte) {
+	t.Helper()
+
+	var decoded any
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	got, err := json.MarshalIndent(jsonShape(decoded), "", "  ")
+	if err != nil {
+		t.Fatalf("failed to marshal response shape: %v", err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if *updateGolden {
+		if err = os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("response contract changed for %s\n--- got ---\n%s--- want ---\n%s", name, got, want)
+	}
+}
+
+func TestResponseContracts(t *testing.T) {
+	mockService := NewMockService()
+	ctrl := NewSubscriptionController(mockService)
+	router := setupRouter(ctrl)
+
+	existingID := uuid.New()
+	mockService.subscriptions[existingID] = &models.Subscription{
+		ID:          existingID,
+		ServiceName: "Test",
+		Price:       100,
+		UserID:      uuid.New(),
+		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:     timePtr(time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	createBody, _ := json.Marshal(apiModels.CreateSubscriptionRequest{
+		ServiceName: "Netflix",
+		Price:       intPtr(299),
+		UserID:      "550e8400-e29b-41d4-a716-446655440000",
+		StartDate:   "01-2024",
+	})
+	updateBody, _ := json.Marshal(apiModels.UpdateSubscriptionRequest{Price: intPtr(399)})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       []byte
+		wantStatus int
+	}{
+		{name: "create_subscription", method: http.MethodPost, path: "/subscriptions", body: createBody, wantStatus: http.StatusCreated},
+		{name: "get_subscription", method: http.MethodGet, path: "/subscriptions/" + existingID.String(), wantStatus: http.StatusOK},
+		{name: "update_subscription", method: http.MethodPut, path: "/subscriptions/" + existingID.String(), body: updateBody, wantStatus: http.StatusOK},
+		{name: "list_subscriptions", method: http.MethodGet, path: "/subscriptions", wantStatus: http.StatusOK},
+		{name: "total_cost", method: http.MethodGet, path: "/subscriptions/total?start_date=01-2024&end_date=12-2024", wantStatus: http.StatusOK},
+		{name: "explain_total_cost", method: http.MethodGet, path: "/subscriptions/total/explain?start_date=01-2024&end_date=12-2024", wantStatus: http.StatusOK},
+		{name: "suggest_services", method: http.MethodGet, path: "/services/suggest?q=net", wantStatus: http.StatusOK},
+		{name: "error", method: http.MethodGet, path: "/subscriptions/" + uuid.NewString(), wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBuffer(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("%s %s status = %d, want %d", tt.method, tt.path, w.Code, tt.wantStatus)
+			}
+			assertGoldenShape(t, tt.name, w.Body.Bytes())
+		})
+	}
+}
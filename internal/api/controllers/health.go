@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/health"
+)
+
+type HealthController struct {
+	monitor *health.Monitor
+	growth  *health.GrowthCollector // Nil if database stats are disabled
+}
+
+func NewHealthController(m *health.Monitor, growth *health.GrowthCollector) *HealthController {
+	return &HealthController{monitor: m, growth: growth}
+}
+
+// Status reports health of every dependency with check latency and last error.
+// Mounted outside the API base path, so it's not part of the Swagger spec.
+func (ctrl *HealthController) Status(ctx *gin.Context) {
+	report := ctrl.monitor.Report()
+
+	resp := apiModels.StatusResponse{Status: report.Status}
+	for _, c := range report.Components {
+		resp.Components = append(resp.Components, apiModels.ComponentStatus{
+			Name:        c.Name,
+			Status:      c.Status,
+			LatencyMs:   c.Latency.Milliseconds(),
+			CheckedAt:   c.CheckedAt,
+			LastError:   c.LastError,
+			LastErrorAt: c.LastErrorAt,
+		})
+	}
+
+	if report.Status != health.StatusUp {
+		ctx.JSON(http.StatusServiceUnavailable, resp)
+		return
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// DatabaseMetrics exposes sizes of the database and its tables with dead tuple ratios from the last collection,
+// and a gauge per soft limit crossed. Mounted outside the API base path behind the admin token, so it's not part of the Swagger spec.
+func (ctrl *HealthController) DatabaseMetrics(ctx *gin.Context) {
+	if ctrl.growth == nil {
+		ctx.JSON(http.StatusNotFound, apiModels.ErrorResponse{Error: "database stats are disabled", Code: apiModels.CodeNotFound})
+		return
+	}
+	snap, warnings := ctrl.growth.Last()
+	if snap == nil {
+		ctx.JSON(http.StatusServiceUnavailable, apiModels.ErrorResponse{Error: "database stats are not collected yet", Code: apiModels.CodeServiceUnavailable})
+		return
+	}
+
+	var b strings.Builder
+	writeMetric(&b, "database_size_bytes", "gauge", "Size of the database on disk.", "", snap.DatabaseBytes)
+	fmt.Fprintf(&b, "# TYPE %stable_size_bytes gauge\n# HELP %stable_size_bytes Size of the table with TOAST, without indexes.\n", metricsPrefix, metricsPrefix)
+	for _, t := range snap.Tables {
+		fmt.Fprintf(&b, "%stable_size_bytes{table=\"%s\"} %d\n", metricsPrefix, escapeLabel(t.Name), t.TableBytes)
+	}
+	fmt.Fprintf(&b, "# TYPE %sindex_size_bytes gauge\n# HELP %sindex_size_bytes Size of indexes of the table.\n", metricsPrefix, metricsPrefix)
+	for _, t := range snap.Tables {
+		fmt.Fprintf(&b, "%sindex_size_bytes{table=\"%s\"} %d\n", metricsPrefix, escapeLabel(t.Name), t.IndexBytes)
+	}
+	fmt.Fprintf(&b, "# TYPE %sdead_tuple_ratio gauge\n# HELP %sdead_tuple_ratio Share of dead tuples in the table, estimated by autovacuum statistics.\n", metricsPrefix, metricsPrefix)
+	for _, t := range snap.Tables {
+		fmt.Fprintf(&b, "%sdead_tuple_ratio{table=\"%s\"} %.4f\n", metricsPrefix, escapeLabel(t.Name), t.DeadRatio())
+	}
+	fmt.Fprintf(&b, "# TYPE %sdatabase_limit_crossed gauge\n# HELP %sdatabase_limit_crossed Soft limits crossed on the last collection, table is empty for the whole database.\n", metricsPrefix, metricsPrefix)
+	for _, w := range warnings {
+		fmt.Fprintf(&b, "%sdatabase_limit_crossed{kind=\"%s\",table=\"%s\"} 1\n", metricsPrefix, w.Kind, escapeLabel(w.Table))
+	}
+	writeMetric(&b, "database_stats_collected_seconds", "gauge", "Unix time of the last collection.", "", snap.CollectedAt.Unix())
+	b.WriteString("# EOF\n")
+
+	ctx.Data(http.StatusOK, openMetricsContentType, []byte(b.String()))
+}
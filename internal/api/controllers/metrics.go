@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"subscription-aggregator-service/internal/api/middlewares"
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/service"
+)
+
+const (
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	metricsPrefix          = "subscription_aggregator_"
+)
+
+// MetricsController serves per org unit usage in OpenMetrics text format, so tenants can scrape it into their own monitoring.
+// Mounted outside the API base path behind per-unit tokens, so it's not part of the Swagger spec.
+type MetricsController struct {
+	subscriptionService service.SubscriptionService
+	calls               *middlewares.CallCounter
+	keys                map[string][]string // Org unit ID: HMAC key IDs whose calls are the unit's, sorted
+}
+
+// NewMetricsController takes a mapping of HMAC key IDs to org unit IDs, as in app.metrics.keys
+func NewMetricsController(ss service.SubscriptionService, keys map[string]string) *MetricsController {
+	byUnit := make(map[string][]string)
+	for keyID, unitID := range keys {
+		if id, err := uuid.Parse(unitID); err == nil { // Validated on config load
+			byUnit[id.String()] = append(byUnit[id.String()], keyID)
+		}
+	}
+	for _, keyIDs := range byUnit {
+		sort.Strings(keyIDs)
+	}
+	return &MetricsController{subscriptionService: ss, calls: middlewares.NewCallCounter(), keys: byUnit}
+}
+
+// CountCalls counts API requests by HMAC key, must run after HMACAuth
+func (ctrl *MetricsController) CountCalls() gin.HandlerFunc {
+	return ctrl.calls.Middleware()
+}
+
+// OrgUnitMetrics exposes subscription counts and current month spend of the unit and its descendants,
+// and API requests signed with keys of the unit itself since service start
+func (ctrl *MetricsController) OrgUnitMetrics(ctx *gin.Context) {
+	var id apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&id); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	usage, err := ctrl.subscriptionService.OrgUnitUsage(ctx.Request.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrOrgUnitNotFound):
+			ctx.JSON(http.StatusNotFound, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	unit := fmt.Sprintf(`org_unit="%s"`, usage.ID)
+	var b strings.Builder
+	writeMetric(&b, "subscriptions", "gauge", "Live subscriptions of the org unit and its descendants.", unit, usage.Subscriptions)
+	writeMetric(&b, "active_subscriptions", "gauge", "Subscriptions active in the current month.", unit, usage.ActiveSubscriptions)
+	writeMetric(&b, "monthly_spend", "gauge", "Cost of subscriptions in the current month, after credits.",
+		fmt.Sprintf(`%s,month="%s"`, unit, usage.Month), usage.MonthlySpend)
+	fmt.Fprintf(&b, "# TYPE %sapi_requests counter\n# HELP %sapi_requests API requests signed with the org unit's keys since service start.\n", metricsPrefix, metricsPrefix)
+	for _, keyID := range ctrl.keys[usage.ID.String()] {
+		fmt.Fprintf(&b, "%sapi_requests_total{%s,key_id=\"%s\"} %d\n", metricsPrefix, unit, escapeLabel(keyID), ctrl.calls.Calls(keyID))
+	}
+	b.WriteString("# EOF\n")
+
+	ctx.Data(http.StatusOK, openMetricsContentType, []byte(b.String()))
+}
+
+func writeMetric(b *strings.Builder, name, kind, help, labels string, value int64) {
+	fmt.Fprintf(b, "# TYPE %s%s %s\n# HELP %s%s %s\n%s%s{%s} %d\n", metricsPrefix, name, kind, metricsPrefix, name, help, metricsPrefix, name, labels, value)
+}
+
+// escapeLabel escapes a label value as OpenMetrics requires
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
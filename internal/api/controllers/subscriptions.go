@@ -1,14 +1,22 @@
 package controllers
 
 import (
+	"bytes"
+	"encoding/csv"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
 
 	apiModels "subscription-aggregator-service/internal/api/models"
-	_ "subscription-aggregator-service/internal/models" // Make visible for swaggo/swag tool
+	"subscription-aggregator-service/internal/config"
+	"subscription-aggregator-service/internal/models"
 	"subscription-aggregator-service/internal/service"
+	"subscription-aggregator-service/internal/utils/dates"
+	"subscription-aggregator-service/internal/utils/display"
 )
 
 type SubscriptionController struct {
@@ -19,45 +27,171 @@ func NewSubscriptionController(ss service.SubscriptionService) *SubscriptionCont
 	return &SubscriptionController{subscriptionService: ss}
 }
 
+// ReadRoutes are "METHOD /route" of endpoints that don't change data despite their method, relative to the API base path.
+// The read-only mode lets them through, so a new one must be added here.
+var ReadRoutes = []string{
+	http.MethodPost + " /subscriptions/total/batch",
+	http.MethodPost + " /utils/parse-date",
+}
+
+// RegisterRoutes adds subscription endpoints to r, used by the server and by tests, so they always see the same route table
+func (ctrl *SubscriptionController) RegisterRoutes(r gin.IRoutes) {
+	r.POST("/subscriptions", ctrl.CreateSubscription)
+	r.POST("/subscriptions/batch", ctrl.CreateSubscriptionsBatch)
+	r.GET("/subscriptions/total", ctrl.TotalSubscriptionsCost) // Must be above parameterized route to avoid conflict
+	r.GET("/subscriptions/total/explain", ctrl.ExplainTotalCost)
+	r.POST("/subscriptions/total/batch", ctrl.TotalSubscriptionsCostBatch)
+	r.GET("/subscriptions/chargeback", ctrl.Chargeback)
+	r.GET("/subscriptions/expiring", ctrl.ListExpiringSubscriptions)
+	r.GET("/subscriptions/:id", ctrl.GetSubscriptionByID)
+	r.HEAD("/subscriptions/:id", ctrl.HeadSubscriptionByID)
+	r.PUT("/subscriptions/:id", ctrl.UpdateSubscriptionByID)
+	r.PATCH("/subscriptions/:id", ctrl.PatchSubscriptionByID)
+	r.DELETE("/subscriptions/:id", ctrl.DeleteSubscriptionByID)
+	r.POST("/subscriptions/:id/cancel", ctrl.CancelSubscription)
+	r.POST("/subscriptions/:id/renew", ctrl.RenewSubscription)
+	r.POST("/undo/:token", ctrl.UndoDeletion)
+	r.POST("/subscriptions/bulk-delete", ctrl.StartBulkDelete)
+	r.GET("/subscriptions/bulk-delete/:id", ctrl.GetBulkDeleteJob)
+	r.GET("/subscriptions", ctrl.ListSubscriptions)
+	r.POST("/subscriptions/:id/credits", ctrl.CreateCredit)
+	r.GET("/subscriptions/:id/credits", ctrl.ListCredits)
+	r.POST("/subscriptions/:id/pause", ctrl.PauseSubscription)
+	r.POST("/subscriptions/:id/resume", ctrl.ResumeSubscription)
+	r.GET("/subscriptions/:id/pauses", ctrl.ListPausedPeriods)
+	r.GET("/services/suggest", ctrl.SuggestServiceNames)
+	r.POST("/utils/parse-date", ctrl.ParseDate)
+	r.GET("/plans", ctrl.ListPlans)
+	r.GET("/org-units", ctrl.ListOrgUnits)
+	r.GET("/org-units/:id/total", ctrl.OrgUnitTotalCost)
+	r.POST("/users/:id/views", ctrl.CreateView)
+	r.GET("/users/:id/views", ctrl.ListViews)
+	r.GET("/users/:id/summary", ctrl.UserSummary)
+	r.PUT("/users/:id/subscriptions", ctrl.BulkUpdateSubscriptions)
+	r.PUT("/users/:id/:action", ctrl.SyncSubscriptions) // Only "subscriptions:sync", gin can't route literal colon without Run()
+}
+
 // CreateSubscription godoc
 // @Summary Create a new subscription
-// @Description Adds a new subscription record to the database with given details
+// @Description Adds a new subscription record to the database with given details.
+// @Description With if_absent_by=external_id returns the user's existing subscription with the same external_id (200) instead of creating a duplicate.
 // @Tags subscriptions
 // @Accept json
 // @Produce json
 // @Param request body apiModels.CreateSubscriptionRequest true "New subscription details"
-// @Success 201 {object} models.Subscription
+// @Param if_absent_by query string false "Deduplication key" Enums(external_id)
+// @Success 200 {object} apiModels.SubscriptionResponse "Existing subscription (if_absent_by only)"
+// @Success 201 {object} apiModels.SubscriptionResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} apiModels.SubscriptionResponse "Subscription with given ID or, with detect_duplicates enabled, an overlapping one to the same service already exists"
 // @Failure 500 {object} models.ErrorResponse
 // @Router /subscriptions [post]
 func (ctrl *SubscriptionController) CreateSubscription(ctx *gin.Context) {
+	var query apiModels.CreateSubscriptionQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error(), Code: apiModels.CodeInvalidQuery})
+		return
+	}
+
 	var req apiModels.CreateSubscriptionRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: apiModels.ErrBadJSON.Error()})
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
+		return
+	}
+
+	var sub *models.Subscription
+	var err error
+	status := http.StatusCreated
+	if query.IfAbsentBy != "" {
+		var created bool
+		sub, created, err = ctrl.subscriptionService.CreateSubscriptionIfAbsent(ctx.Request.Context(), &req)
+		if !created {
+			status = http.StatusOK
+		}
+	} else {
+		sub, err = ctrl.subscriptionService.CreateSubscription(ctx.Request.Context(), &req)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrConflict) && sub != nil:
+			ctx.JSON(http.StatusConflict, apiModels.NewSubscriptionResponse(sub, serialization(ctx)))
+		case errors.Is(err, service.ErrConflict):
+			ctx.JSON(http.StatusConflict, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.Header("ETag", sub.ETag())
+	ctx.JSON(status, apiModels.NewSubscriptionResponse(sub, serialization(ctx)))
+}
+
+// CreateSubscriptionsBatch godoc
+// @Summary Create subscriptions in batch
+// @Description Validates every subscription of the array and creates the valid ones in one transaction, up to 1000 per request.
+// @Description 201 if all were created, 207 if some were rejected, 400 if all were. Every item gets its own status in results:
+// @Description 400 if invalid, 409 if it overlaps an existing subscription or an earlier item with detect_duplicates enabled.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param request body []apiModels.CreateSubscriptionRequest true "New subscriptions"
+// @Success 201 {object} apiModels.BatchCreateResponse
+// @Success 207 {object} apiModels.BatchCreateResponse "Some items are invalid, the valid ones are created"
+// @Failure 400 {object} apiModels.BatchCreateResponse "All items are invalid, or apiModels.ErrorResponse for a malformed batch"
+// @Failure 409 {object} apiModels.ErrorResponse "Subscription with given ID already exists, nothing is created"
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /subscriptions/batch [post]
+func (ctrl *SubscriptionController) CreateSubscriptionsBatch(ctx *gin.Context) {
+	var reqs []apiModels.CreateSubscriptionRequest
+	if err := ctx.ShouldBindJSON(&reqs); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
 		return
 	}
 
-	sub, err := ctrl.subscriptionService.CreateSubscription(ctx.Request.Context(), &req)
+	resp, err := ctrl.subscriptionService.CreateSubscriptionsBatch(ctx.Request.Context(), reqs)
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrValidationError):
-			ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error()})
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrConflict):
+			ctx.JSON(http.StatusConflict, apiModels.NewErrorResponse(err))
 		default:
-			ctx.JSON(http.StatusInternalServerError, apiModels.ErrorResponse{Error: service.ErrIES.Error()})
+			serverError(ctx, err)
 		}
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, sub)
+	for i := range resp.Results {
+		switch {
+		case resp.Results[i].Code == apiModels.CodeSubscriptionExists:
+			resp.Results[i].Status = http.StatusConflict
+		case resp.Results[i].Error != "":
+			resp.Results[i].Status = http.StatusBadRequest
+		default:
+			resp.Results[i].Status = http.StatusCreated
+		}
+	}
+	switch {
+	case resp.Failed == 0:
+		ctx.JSON(http.StatusCreated, resp)
+	case resp.Created == 0:
+		ctx.JSON(http.StatusBadRequest, resp)
+	default:
+		ctx.JSON(http.StatusMultiStatus, resp)
+	}
 }
 
 // GetSubscriptionByID godoc
 // @Summary Get a subscription by ID
-// @Description Returns a single subscription record by its UUID
+// @Description Returns a single subscription record by its UUID, ETag header carries its version for If-Match of updates
 // @Tags subscriptions
 // @Produce json
 // @Param id path string true "Subscription UUID"
-// @Success 200 {object} models.Subscription
+// @Success 200 {object} apiModels.SubscriptionResponse
+// @Header 200 {string} ETag "Subscription version"
 // @Failure 400 {object} apiModels.ErrorResponse
 // @Failure 404 {object} apiModels.ErrorResponse
 // @Failure 500 {object} apiModels.ErrorResponse
@@ -65,7 +199,7 @@ func (ctrl *SubscriptionController) CreateSubscription(ctx *gin.Context) {
 func (ctrl *SubscriptionController) GetSubscriptionByID(ctx *gin.Context) {
 	var id apiModels.ItemByIDRequest
 	if err := ctx.ShouldBindUri(&id); err != nil {
-		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: apiModels.ErrBadParam.Error()})
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
 		return
 	}
 
@@ -73,66 +207,249 @@ func (ctrl *SubscriptionController) GetSubscriptionByID(ctx *gin.Context) {
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrValidationError):
-			ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error()})
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrNotFound):
+			ctx.JSON(http.StatusNotFound, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.Header("ETag", sub.ETag())
+	ctx.JSON(http.StatusOK, apiModels.NewSubscriptionResponse(sub, serialization(ctx)))
+}
+
+// HeadSubscriptionByID godoc
+// @Summary Check that a subscription exists
+// @Description Cheap existence check for other services: 200 if the subscription exists, 404 if not, no body either way
+// @Tags subscriptions
+// @Param id path string true "Subscription UUID"
+// @Success 200 "Subscription exists"
+// @Failure 400 "Invalid UUID"
+// @Failure 404 "Subscription not found"
+// @Failure 500 "Internal server error"
+// @Router /subscriptions/{id} [head]
+func (ctrl *SubscriptionController) HeadSubscriptionByID(ctx *gin.Context) {
+	var id apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&id); err != nil {
+		ctx.Status(http.StatusBadRequest)
+		return
+	}
+
+	if err := ctrl.subscriptionService.SubscriptionExists(ctx.Request.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.Status(http.StatusBadRequest)
 		case errors.Is(err, service.ErrNotFound):
-			ctx.JSON(http.StatusNotFound, apiModels.ErrorResponse{Error: err.Error()})
+			ctx.Status(http.StatusNotFound)
 		default:
-			ctx.JSON(http.StatusInternalServerError, apiModels.ErrorResponse{Error: service.ErrIES.Error()})
+			serverError(ctx, err) // net/http drops the body of HEAD responses
 		}
 		return
 	}
 
-	ctx.JSON(http.StatusOK, sub)
+	ctx.Status(http.StatusOK)
 }
 
 // UpdateSubscriptionByID godoc
 // @Summary Update a subscription
 // @Description Updates an existing subscription record. Supports partial updates.
+// @Description If-Match must carry the ETag from GET (required unless app.api.require_if_match is off), 412 means someone updated it since.
 // @Tags subscriptions
 // @Accept json
 // @Produce json
 // @Param id path string true "Subscription UUID"
+// @Param If-Match header string false "ETag of the subscription version being updated, or *"
 // @Param request body apiModels.UpdateSubscriptionRequest true "Subscription update data"
-// @Success 200 {object} models.Subscription
+// @Success 200 {object} apiModels.SubscriptionResponse
+// @Header 200 {string} ETag "New subscription version"
 // @Failure 400 {object} apiModels.ErrorResponse
 // @Failure 404 {object} apiModels.ErrorResponse
+// @Failure 412 {object} apiModels.ErrorResponse
+// @Failure 428 {object} apiModels.ErrorResponse
 // @Failure 500 {object} apiModels.ErrorResponse
 // @Router /subscriptions/{id} [put]
 func (ctrl *SubscriptionController) UpdateSubscriptionByID(ctx *gin.Context) {
 	var id apiModels.ItemByIDRequest
 	if err := ctx.ShouldBindUri(&id); err != nil {
-		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: apiModels.ErrBadParam.Error()})
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
 		return
 	}
 
 	var req apiModels.UpdateSubscriptionRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: apiModels.ErrBadJSON.Error()})
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
+		return
+	}
+
+	ctrl.updateSubscription(ctx, id, &req)
+}
+
+// PatchSubscriptionByID godoc
+// @Summary Patch a subscription
+// @Description Applies RFC 7386 JSON merge patch: absent fields are kept, null clears a field (only end_date can be cleared).
+// @Description If-Match works as for PUT.
+// @Tags subscriptions
+// @Accept application/merge-patch+json,json
+// @Produce json
+// @Param id path string true "Subscription UUID"
+// @Param If-Match header string false "ETag of the subscription version being updated, or *"
+// @Param request body apiModels.UpdateSubscriptionRequest true "Merge patch, null clears end_date"
+// @Success 200 {object} apiModels.SubscriptionResponse
+// @Header 200 {string} ETag "New subscription version"
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 404 {object} apiModels.ErrorResponse
+// @Failure 412 {object} apiModels.ErrorResponse
+// @Failure 428 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /subscriptions/{id} [patch]
+func (ctrl *SubscriptionController) PatchSubscriptionByID(ctx *gin.Context) {
+	var id apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&id); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	var patch apiModels.SubscriptionMergePatch
+	if err := ctx.ShouldBindJSON(&patch); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
+		return
+	}
+	req, err := patch.ToUpdate()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: fmt.Sprintf("%s: %v", apiModels.ErrBadJSON, err), Code: apiModels.CodeInvalidJSON})
+		return
+	}
+
+	ctrl.updateSubscription(ctx, id, req)
+}
+
+func (ctrl *SubscriptionController) updateSubscription(ctx *gin.Context, id apiModels.ItemByIDRequest, req *apiModels.UpdateSubscriptionRequest) {
+	req.IfMatch = ctx.GetHeader("If-Match")
+	sub, err := ctrl.subscriptionService.UpdateSubscriptionByID(ctx.Request.Context(), id, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrNotFound):
+			ctx.JSON(http.StatusNotFound, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrPreconditionFail):
+			ctx.JSON(http.StatusPreconditionFailed, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrPreconditionNeeded):
+			ctx.JSON(http.StatusPreconditionRequired, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.Header("ETag", sub.ETag())
+	ctx.JSON(http.StatusOK, apiModels.NewSubscriptionResponse(sub, serialization(ctx)))
+}
+
+// CancelSubscription godoc
+// @Summary Cancel a subscription
+// @Description Ends the subscription with the current month or the given one and sets cancelled_at. Unlike deletion it stays listed and counts until its end.
+// @Description The end month cannot precede the start or follow the current end, one-time purchases cannot be cancelled. If-Match is checked only when sent.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription UUID"
+// @Param If-Match header string false "ETag of the subscription version being cancelled, or *"
+// @Param request body apiModels.CancelSubscriptionRequest false "Last month, current one if omitted"
+// @Success 200 {object} apiModels.SubscriptionResponse
+// @Header 200 {string} ETag "New subscription version"
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 404 {object} apiModels.ErrorResponse
+// @Failure 412 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /subscriptions/{id}/cancel [post]
+func (ctrl *SubscriptionController) CancelSubscription(ctx *gin.Context) {
+	var id apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&id); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	var req apiModels.CancelSubscriptionRequest
+	if ctx.Request.ContentLength != 0 { // Body is optional
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
+			return
+		}
+	}
+	req.IfMatch = ctx.GetHeader("If-Match")
+
+	sub, err := ctrl.subscriptionService.CancelSubscription(ctx.Request.Context(), id, &req)
+	ctrl.termChanged(ctx, sub, err)
+}
+
+// RenewSubscription godoc
+// @Summary Renew a subscription
+// @Description Pushes the end date the given number of months forward. An open-ended subscription gets a fixed term ending that many months
+// @Description after the current month, or after its start if it hasn't started yet. Renewal clears cancelled_at, one-time purchases cannot be renewed.
+// @Description If-Match is checked only when sent.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription UUID"
+// @Param If-Match header string false "ETag of the subscription version being renewed, or *"
+// @Param request body apiModels.RenewSubscriptionRequest true "Months to add"
+// @Success 200 {object} apiModels.SubscriptionResponse
+// @Header 200 {string} ETag "New subscription version"
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 404 {object} apiModels.ErrorResponse
+// @Failure 412 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /subscriptions/{id}/renew [post]
+func (ctrl *SubscriptionController) RenewSubscription(ctx *gin.Context) {
+	var id apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&id); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	var req apiModels.RenewSubscriptionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
 		return
 	}
+	req.IfMatch = ctx.GetHeader("If-Match")
+
+	sub, err := ctrl.subscriptionService.RenewSubscription(ctx.Request.Context(), id, &req)
+	ctrl.termChanged(ctx, sub, err)
+}
 
-	sub, err := ctrl.subscriptionService.UpdateSubscriptionByID(ctx.Request.Context(), id, &req)
+// termChanged writes the response of cancellation or renewal
+func (ctrl *SubscriptionController) termChanged(ctx *gin.Context, sub *models.Subscription, err error) {
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrValidationError):
-			ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error()})
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
 		case errors.Is(err, service.ErrNotFound):
-			ctx.JSON(http.StatusNotFound, apiModels.ErrorResponse{Error: err.Error()})
+			ctx.JSON(http.StatusNotFound, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrPreconditionFail):
+			ctx.JSON(http.StatusPreconditionFailed, apiModels.NewErrorResponse(err))
 		default:
-			ctx.JSON(http.StatusInternalServerError, apiModels.ErrorResponse{Error: service.ErrIES.Error()})
+			serverError(ctx, err)
 		}
 		return
 	}
 
-	ctx.JSON(http.StatusOK, sub)
+	ctx.Header("ETag", sub.ETag())
+	ctx.JSON(http.StatusOK, apiModels.NewSubscriptionResponse(sub, serialization(ctx)))
 }
 
 // DeleteSubscriptionByID godoc
 // @Summary Delete a subscription
-// @Description Marks a subscription record as (soft-)deleted in the database
+// @Description Marks a subscription record as (soft-)deleted in the database.
+// @Description Returns a token for POST /undo/{token} valid for app.undo.window, or 204 if undo is disabled.
 // @Tags subscriptions
+// @Produce json
 // @Param id path string true "Subscription UUID"
-// @Success 200 "OK"
+// @Success 200 {object} models.UndoToken
+// @Success 204 "Deleted, undo is disabled"
 // @Failure 400 {object} apiModels.ErrorResponse
 // @Failure 404 {object} apiModels.ErrorResponse
 // @Failure 500 {object} apiModels.ErrorResponse
@@ -140,57 +457,182 @@ func (ctrl *SubscriptionController) UpdateSubscriptionByID(ctx *gin.Context) {
 func (ctrl *SubscriptionController) DeleteSubscriptionByID(ctx *gin.Context) {
 	var id apiModels.ItemByIDRequest
 	if err := ctx.ShouldBindUri(&id); err != nil {
-		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: apiModels.ErrBadParam.Error()})
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
 		return
 	}
 
-	err := ctrl.subscriptionService.DeleteSubscriptionByID(ctx.Request.Context(), id)
+	token, err := ctrl.subscriptionService.DeleteSubscriptionByID(ctx.Request.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrValidationError):
-			ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error()})
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
 		case errors.Is(err, service.ErrNotFound):
-			ctx.JSON(http.StatusNotFound, apiModels.ErrorResponse{Error: err.Error()})
+			ctx.JSON(http.StatusNotFound, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	if token == nil {
+		ctx.AbortWithStatus(http.StatusNoContent)
+		return
+	}
+	ctx.JSON(http.StatusOK, token)
+}
+
+// UndoDeletion godoc
+// @Summary Undo a deletion
+// @Description Restores a subscription deleted with DELETE /subscriptions/{id}, while its undo token is valid. A token works once.
+// @Tags subscriptions
+// @Produce json
+// @Param token path string true "Undo token"
+// @Success 200 {object} apiModels.SubscriptionResponse
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 404 {object} apiModels.ErrorResponse "Token not found or expired"
+// @Failure 409 {object} apiModels.ErrorResponse "Subscription with the same external_id was created since"
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /undo/{token} [post]
+func (ctrl *SubscriptionController) UndoDeletion(ctx *gin.Context) {
+	var req apiModels.UndoRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	sub, err := ctrl.subscriptionService.UndoDeletion(ctx.Request.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrUndoNotFound):
+			ctx.JSON(http.StatusNotFound, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrConflict):
+			ctx.JSON(http.StatusConflict, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, apiModels.NewSubscriptionResponse(sub, serialization(ctx)))
+}
+
+// StartBulkDelete godoc
+// @Summary Delete subscriptions by filter
+// @Description Starts a background job soft-deleting subscriptions matching the filter in batches, each batch in its own transaction.
+// @Description Dates select subscriptions whose whole period lies within [start_date, end_date], open-ended ones are kept if end_date is set.
+// @Description Responds right away with the job, follow its progress at the Location URL. Deletions by the job can't be undone.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param request body apiModels.BulkDeleteRequest true "Filter, at least one criterion"
+// @Success 202 {object} models.BulkDeleteJob
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /subscriptions/bulk-delete [post]
+func (ctrl *SubscriptionController) StartBulkDelete(ctx *gin.Context) {
+	var req apiModels.BulkDeleteRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error(), Code: apiModels.CodeInvalidJSON})
+		return
+	}
+
+	job, err := ctrl.subscriptionService.StartBulkDelete(ctx.Request.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.Header("Location", ctx.FullPath()+"/"+job.ID.String()) // Route path with the group prefix, so base_path is included
+	ctx.JSON(http.StatusAccepted, job)
+}
+
+// GetBulkDeleteJob godoc
+// @Summary Get bulk delete progress
+// @Description Returns a bulk delete job: status (pending, running, done, failed), subscriptions matched when it started and deleted so far.
+// @Description A finished job has finished_at set, a failed one also has error; subscriptions deleted before the failure stay deleted.
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "Job UUID"
+// @Success 200 {object} models.BulkDeleteJob
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 404 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /subscriptions/bulk-delete/{id} [get]
+func (ctrl *SubscriptionController) GetBulkDeleteJob(ctx *gin.Context) {
+	var id apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&id); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	job, err := ctrl.subscriptionService.GetBulkDeleteJob(ctx.Request.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrBulkDeleteNotFound):
+			ctx.JSON(http.StatusNotFound, apiModels.NewErrorResponse(err))
 		default:
-			ctx.JSON(http.StatusInternalServerError, apiModels.ErrorResponse{Error: service.ErrIES.Error()})
+			serverError(ctx, err)
 		}
 		return
 	}
 
-	ctx.AbortWithStatus(http.StatusNoContent)
+	ctx.JSON(http.StatusOK, job)
 }
 
 // ListSubscriptions godoc
 // @Summary List subscriptions
-// @Description Returns a list of subscriptions with optional filtering by user ID and service name
+// @Description Returns a page of subscriptions with optional filtering by user ID, service name and creation time and sorting, with total count and next page offset
 // @Tags subscriptions
 // @Produce json
 // @Param user_id query string false "User UUID"
 // @Param service_name query string false "Service Name"
+// @Param service_name_ci query bool false "Match service_name case-insensitively"
+// @Param service_name_like query string false "Case-insensitive match, * is any characters, without * a substring"
+// @Param created_after query string false "Created after (RFC3339)"
+// @Param created_before query string false "Created before (RFC3339)"
+// @Param active_at query string false "Only subscriptions active in this month (MM-YYYY)"
+// @Param min_price query int false "Minimum price"
+// @Param max_price query int false "Maximum price"
 // @Param limit query int false "Limit"
 // @Param offset query int false "Offset"
-// @Success 200 {object} []models.Subscription
+// @Param view query string false "Saved view UUID, explicit filters take precedence"
+// @Param sort_by query string false "Sort by price, start_date, service_name or created_at (default)"
+// @Param order query string false "Sort order, asc or desc (default)"
+// @Success 200 {object} apiModels.ListSubscriptionsResponse
 // @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 404 {object} apiModels.ErrorResponse "View not found"
 // @Failure 500 {object} apiModels.ErrorResponse
 // @Router /subscriptions [get]
 func (ctrl *SubscriptionController) ListSubscriptions(ctx *gin.Context) {
 	var req apiModels.ListSubscriptionsRequest
 	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error()})
+		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error(), Code: apiModels.CodeInvalidQuery})
 		return
 	}
 
-	subs, err := ctrl.subscriptionService.ListSubscriptions(ctx.Request.Context(), req)
+	resp, err := ctrl.subscriptionService.ListSubscriptions(ctx.Request.Context(), req)
 	if err != nil {
-		if errors.Is(err, service.ErrValidationError) {
-			ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error()})
-		} else {
-			ctx.JSON(http.StatusInternalServerError, apiModels.ErrorResponse{Error: service.ErrIES.Error()})
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrViewNotFound):
+			ctx.JSON(http.StatusNotFound, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
 		}
 		return
 	}
 
-	ctx.JSON(http.StatusOK, subs)
+	ctx.JSON(http.StatusOK, resp)
 }
 
 // TotalSubscriptionsCost godoc
@@ -200,8 +642,13 @@ func (ctrl *SubscriptionController) ListSubscriptions(ctx *gin.Context) {
 // @Produce json
 // @Param user_id query string false "User UUID"
 // @Param service_name query string false "Service Name"
+// @Param service_name_ci query bool false "Match service_name case-insensitively"
+// @Param service_name_like query string false "Case-insensitive match, * is any characters, without * a substring"
 // @Param start_date query string true "Start Date (MM-YYYY)"
 // @Param end_date query string true "End Date (MM-YYYY)"
+// @Param group_by query string false "Also return breakdown by" Enums(service_name, month)
+// @Param X-Locale header string false "Locale of formatted fields, ru (default) or en"
+// @Param X-Display-Currency header string false "Currency of formatted prices, RUB (default) or one with a configured rate"
 // @Success 200 {object} apiModels.TotalCostResponse
 // @Failure 400 {object} apiModels.ErrorResponse
 // @Failure 500 {object} apiModels.ErrorResponse
@@ -209,19 +656,720 @@ func (ctrl *SubscriptionController) ListSubscriptions(ctx *gin.Context) {
 func (ctrl *SubscriptionController) TotalSubscriptionsCost(ctx *gin.Context) {
 	var req apiModels.TotalCostRequest
 	if err := ctx.ShouldBindQuery(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error()})
+		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error(), Code: apiModels.CodeInvalidQuery})
 		return
 	}
 
 	resp, err := ctrl.subscriptionService.TotalSubscriptionsCost(ctx.Request.Context(), req)
 	if err != nil {
 		if errors.Is(err, service.ErrValidationError) {
-			ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error()})
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		} else {
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.Formatted(display.FromContext(ctx.Request.Context())))
+}
+
+// Chargeback godoc
+// @Summary Export chargeback
+// @Description Exports spend for a period grouped by allocation tag (cost_center or project_code) and month as CSV
+// @Description with columns <by>,month,cost. Untagged spend has an empty tag, months without spend are omitted.
+// @Tags subscriptions
+// @Produce text/csv
+// @Param by query string true "Allocation tag to group by" Enums(cost_center, project_code)
+// @Param user_id query string false "User UUID"
+// @Param start_date query string true "Start Date (MM-YYYY)"
+// @Param end_date query string true "End Date (MM-YYYY)"
+// @Success 200 {string} string "CSV"
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /subscriptions/chargeback [get]
+func (ctrl *SubscriptionController) Chargeback(ctx *gin.Context) {
+	var req apiModels.ChargebackRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error(), Code: apiModels.CodeInvalidQuery})
+		return
+	}
+
+	rows, err := ctrl.subscriptionService.Chargeback(ctx.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrValidationError) {
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		} else {
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{req.By, "month", "cost"}) // Writes to a buffer can't fail
+	for _, row := range rows {
+		_ = w.Write([]string{row.Allocation, row.Month, strconv.FormatInt(row.Cost, 10)})
+	}
+	w.Flush()
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="chargeback-%s-%s-%s.csv"`, req.By, req.StartDate, req.EndDate))
+	ctx.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+// ListExpiringSubscriptions godoc
+// @Summary List expiring subscriptions
+// @Description Lists recurring subscriptions ending from the current month through the month window days ahead, grouped by user.
+// @Description A subscription is renewed if the user has another one to the same service starting no later than the month after it ends.
+// @Tags subscriptions
+// @Produce json
+// @Param window query string false "How far ahead to look in days, e.g. 60d (default 30d, at most 366d)"
+// @Param auto_renew query bool false "false for subscriptions that will lapse, true for renewed ones, both if absent"
+// @Success 200 {object} apiModels.ExpiringSubscriptionsResponse
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /subscriptions/expiring [get]
+func (ctrl *SubscriptionController) ListExpiringSubscriptions(ctx *gin.Context) {
+	var req apiModels.ExpiringSubscriptionsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error(), Code: apiModels.CodeInvalidQuery})
+		return
+	}
+
+	resp, err := ctrl.subscriptionService.ListExpiringSubscriptions(ctx.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrValidationError) {
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		} else {
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// TotalSubscriptionsCostBatch godoc
+// @Summary Get total cost of many users
+// @Description Calculates total cost of subscriptions for a period per user, for up to 1000 users in one request
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param request body apiModels.BatchTotalCostRequest true "User IDs and period"
+// @Success 200 {object} apiModels.BatchTotalCostResponse
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /subscriptions/total/batch [post]
+func (ctrl *SubscriptionController) TotalSubscriptionsCostBatch(ctx *gin.Context) {
+	var req apiModels.BatchTotalCostRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error(), Code: apiModels.CodeInvalidJSON})
+		return
+	}
+
+	resp, err := ctrl.subscriptionService.TotalSubscriptionsCostBatch(ctx.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrValidationError) {
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
 		} else {
-			ctx.JSON(http.StatusInternalServerError, apiModels.ErrorResponse{Error: service.ErrIES.Error()})
+			serverError(ctx, err)
 		}
 		return
 	}
 
 	ctx.JSON(http.StatusOK, resp)
 }
+
+// ExplainTotalCost godoc
+// @Summary Explain total cost
+// @Description Breaks down total cost for a period per subscription: clipped interval, months counted, price and amount
+// @Tags subscriptions
+// @Produce json
+// @Param user_id query string false "User UUID"
+// @Param service_name query string false "Service Name"
+// @Param service_name_ci query bool false "Match service_name case-insensitively"
+// @Param service_name_like query string false "Case-insensitive match, * is any characters, without * a substring"
+// @Param start_date query string true "Start Date (MM-YYYY)"
+// @Param end_date query string true "End Date (MM-YYYY)"
+// @Param X-Locale header string false "Locale of formatted fields, ru (default) or en"
+// @Param X-Display-Currency header string false "Currency of formatted prices, RUB (default) or one with a configured rate"
+// @Success 200 {object} apiModels.CostExplanationResponse
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /subscriptions/total/explain [get]
+func (ctrl *SubscriptionController) ExplainTotalCost(ctx *gin.Context) {
+	var req apiModels.TotalCostRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error(), Code: apiModels.CodeInvalidQuery})
+		return
+	}
+
+	resp, err := ctrl.subscriptionService.ExplainTotalCost(ctx.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrValidationError) {
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		} else {
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.Formatted(display.FromContext(ctx.Request.Context())))
+}
+
+// SuggestServiceNames godoc
+// @Summary Suggest service names
+// @Description Returns distinct service names starting with given prefix (case-insensitive) for autocomplete
+// @Tags services
+// @Produce json
+// @Param q query string true "Service name prefix"
+// @Param user_id query string false "User UUID"
+// @Param limit query int false "Limit (1-50, default 10)"
+// @Success 200 {object} apiModels.SuggestServicesResponse
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /services/suggest [get]
+func (ctrl *SubscriptionController) SuggestServiceNames(ctx *gin.Context) {
+	var req apiModels.SuggestServicesRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error(), Code: apiModels.CodeInvalidQuery})
+		return
+	}
+
+	resp, err := ctrl.subscriptionService.SuggestServiceNames(ctx.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrValidationError) {
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		} else {
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// ParseDate godoc
+// @Summary Normalize a month
+// @Description Converts a human-style month, as found in spreadsheets and user input, to MM-YYYY the rest of the API expects.
+// @Description Accepts English and Russian month names, full or abbreviated ("Jan 2024", "январь 2024", "января 2024 г."), and numeric forms ("01.2024", "1/2024", "2024-01").
+// @Tags utils
+// @Accept json
+// @Produce json
+// @Param request body apiModels.ParseDateRequest true "Month to normalize"
+// @Success 200 {object} apiModels.ParseDateResponse
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Router /utils/parse-date [post]
+func (ctrl *SubscriptionController) ParseDate(ctx *gin.Context) {
+	var req apiModels.ParseDateRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
+		return
+	}
+
+	month, err := dates.ParseMonth(req.Date)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: fmt.Sprintf("%s: %v", service.ErrValidationError, err), Code: apiModels.CodeValidation,
+			Fields: []apiModels.FieldError{{Field: "date", Code: apiModels.CodeInvalidDate, Message: err.Error()}}})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, apiModels.ParseDateResponse{Date: month.Format(dates.Layout)})
+}
+
+// CreateCredit godoc
+// @Summary Add a credit to a subscription
+// @Description Attaches a discount subtracted from the subscription price every month of the credit period, total cost accounts for it.
+// @Description The period must fit into the subscription and the discount cannot exceed its price.
+// @Tags credits
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription UUID"
+// @Param request body apiModels.CreateCreditRequest true "Credit details"
+// @Success 201 {object} apiModels.CreditResponse
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 404 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /subscriptions/{id}/credits [post]
+func (ctrl *SubscriptionController) CreateCredit(ctx *gin.Context) {
+	var id apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&id); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	var req apiModels.CreateCreditRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
+		return
+	}
+
+	credit, err := ctrl.subscriptionService.CreateCredit(ctx.Request.Context(), id, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrNotFound):
+			ctx.JSON(http.StatusNotFound, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, apiModels.NewCreditResponse(credit, serialization(ctx)))
+}
+
+// ListCredits godoc
+// @Summary List subscription credits
+// @Description Returns credits of the subscription ordered by start date
+// @Tags credits
+// @Produce json
+// @Param id path string true "Subscription UUID"
+// @Success 200 {object} []apiModels.CreditResponse
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 404 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /subscriptions/{id}/credits [get]
+func (ctrl *SubscriptionController) ListCredits(ctx *gin.Context) {
+	var id apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&id); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	credits, err := ctrl.subscriptionService.ListCredits(ctx.Request.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrNotFound):
+			ctx.JSON(http.StatusNotFound, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, apiModels.NewCreditResponses(credits, serialization(ctx)))
+}
+
+// PauseSubscription godoc
+// @Summary Pause a subscription
+// @Description Stops charging the subscription from the current month or the given one until it's resumed, total cost leaves paused months out.
+// @Description The month must fall within the subscription and after its earlier pauses, one-time purchases cannot be paused.
+// @Tags pauses
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription UUID"
+// @Param request body apiModels.PauseSubscriptionRequest false "First paused month, current one if omitted"
+// @Success 201 {object} apiModels.PausedPeriodResponse
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 404 {object} apiModels.ErrorResponse
+// @Failure 409 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /subscriptions/{id}/pause [post]
+func (ctrl *SubscriptionController) PauseSubscription(ctx *gin.Context) {
+	id, req, ok := bindPauseRequest(ctx)
+	if !ok {
+		return
+	}
+
+	pause, err := ctrl.subscriptionService.PauseSubscription(ctx.Request.Context(), id, req)
+	if err != nil {
+		pauseError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, apiModels.NewPausedPeriodResponse(pause, serialization(ctx)))
+}
+
+// ResumeSubscription godoc
+// @Summary Resume a subscription
+// @Description Charges the paused subscription again from the current month or the given one, the pause ends a month before.
+// @Description A pause resumed in or before its first month is dropped.
+// @Tags pauses
+// @Accept json
+// @Produce json
+// @Param id path string true "Subscription UUID"
+// @Param request body apiModels.PauseSubscriptionRequest false "First charged month, current one if omitted"
+// @Success 200 {object} apiModels.PausedPeriodResponse
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 404 {object} apiModels.ErrorResponse
+// @Failure 409 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /subscriptions/{id}/resume [post]
+func (ctrl *SubscriptionController) ResumeSubscription(ctx *gin.Context) {
+	id, req, ok := bindPauseRequest(ctx)
+	if !ok {
+		return
+	}
+
+	pause, err := ctrl.subscriptionService.ResumeSubscription(ctx.Request.Context(), id, req)
+	if err != nil {
+		pauseError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, apiModels.NewPausedPeriodResponse(pause, serialization(ctx)))
+}
+
+// bindPauseRequest binds the subscription ID and the optional body, on failure the response is already written
+func bindPauseRequest(ctx *gin.Context) (apiModels.ItemByIDRequest, *apiModels.PauseSubscriptionRequest, bool) {
+	var id apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&id); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return id, nil, false
+	}
+
+	var req apiModels.PauseSubscriptionRequest
+	if ctx.Request.ContentLength != 0 { // Body is optional
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
+			return id, nil, false
+		}
+	}
+	return id, &req, true
+}
+
+func pauseError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrValidationError):
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+	case errors.Is(err, service.ErrNotFound):
+		ctx.JSON(http.StatusNotFound, apiModels.NewErrorResponse(err))
+	case errors.Is(err, service.ErrAlreadyPaused), errors.Is(err, service.ErrNotPaused):
+		ctx.JSON(http.StatusConflict, apiModels.NewErrorResponse(err))
+	default:
+		serverError(ctx, err)
+	}
+}
+
+// ListPausedPeriods godoc
+// @Summary List subscription pauses
+// @Description Returns pauses of the subscription ordered by start date, an open one (without end_date) means it's paused now
+// @Tags pauses
+// @Produce json
+// @Param id path string true "Subscription UUID"
+// @Success 200 {object} []apiModels.PausedPeriodResponse
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 404 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /subscriptions/{id}/pauses [get]
+func (ctrl *SubscriptionController) ListPausedPeriods(ctx *gin.Context) {
+	var id apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&id); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	pauses, err := ctrl.subscriptionService.ListPausedPeriods(ctx.Request.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrNotFound):
+			ctx.JSON(http.StatusNotFound, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, apiModels.NewPausedPeriodResponses(pauses, serialization(ctx)))
+}
+
+// CreateView godoc
+// @Summary Save a list view
+// @Description Saves a named set of list filters for the user, apply it with GET /subscriptions?view={id}
+// @Tags views
+// @Accept json
+// @Produce json
+// @Param id path string true "User UUID"
+// @Param request body apiModels.CreateViewRequest true "View name and filters"
+// @Success 201 {object} models.SavedView
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 409 {object} apiModels.ErrorResponse "View with this name already exists"
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /users/{id}/views [post]
+func (ctrl *SubscriptionController) CreateView(ctx *gin.Context) {
+	var user apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&user); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	var req apiModels.CreateViewRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
+		return
+	}
+
+	view, err := ctrl.subscriptionService.CreateView(ctx.Request.Context(), user, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrViewConflict):
+			ctx.JSON(http.StatusConflict, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, view)
+}
+
+// ListPlans godoc
+// @Summary List catalog plans
+// @Description Returns plans with official prices ordered by service and plan name. Pass plan_id when creating a subscription to prefill its service name and price.
+// @Tags plans
+// @Produce json
+// @Success 200 {object} []models.Plan
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /plans [get]
+func (ctrl *SubscriptionController) ListPlans(ctx *gin.Context) {
+	plans, err := ctrl.subscriptionService.ListPlans(ctx.Request.Context())
+	if err != nil {
+		serverError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, plans)
+}
+
+// ListOrgUnits godoc
+// @Summary List org units
+// @Description Returns all org units (company, departments, teams) ordered by name, the tree is given by parent_id.
+// @Description Pass org_unit_id when creating or updating a subscription to attribute its cost to a unit.
+// @Tags org-units
+// @Produce json
+// @Success 200 {object} []models.OrgUnit
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /org-units [get]
+func (ctrl *SubscriptionController) ListOrgUnits(ctx *gin.Context) {
+	units, err := ctrl.subscriptionService.ListOrgUnits(ctx.Request.Context())
+	if err != nil {
+		serverError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, units)
+}
+
+// OrgUnitTotalCost godoc
+// @Summary Get org unit cost
+// @Description Calculates cost of subscriptions attributed to the unit for a period and rolls up costs of its descendants.
+// @Description Returns the subtree: every node has own_cost of its own subscriptions and total_cost including its children.
+// @Tags org-units
+// @Produce json
+// @Param id path string true "Org unit UUID"
+// @Param start_date query string true "Start Date (MM-YYYY)"
+// @Param end_date query string true "End Date (MM-YYYY)"
+// @Success 200 {object} apiModels.OrgUnitCost
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 404 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /org-units/{id}/total [get]
+func (ctrl *SubscriptionController) OrgUnitTotalCost(ctx *gin.Context) {
+	var id apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&id); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+	var req apiModels.OrgUnitCostRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.ErrorResponse{Error: err.Error(), Code: apiModels.CodeInvalidQuery})
+		return
+	}
+
+	resp, err := ctrl.subscriptionService.OrgUnitTotalCost(ctx.Request.Context(), id, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrOrgUnitNotFound):
+			ctx.JSON(http.StatusNotFound, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// UserSummary godoc
+// @Summary Get user summary
+// @Description Returns number of active subscriptions, monthly cost and the most expensive service of active recurring ones
+// @Description and earliest/latest start dates of user's subscriptions. Active means covering the current month.
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "User UUID"
+// @Param X-Locale header string false "Locale of formatted fields, ru (default) or en"
+// @Param X-Display-Currency header string false "Currency of formatted prices, RUB (default) or one with a configured rate"
+// @Success 200 {object} apiModels.UserSummaryResponse
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /users/{id}/summary [get]
+func (ctrl *SubscriptionController) UserSummary(ctx *gin.Context) {
+	var user apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&user); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	resp, err := ctrl.subscriptionService.UserSummary(ctx.Request.Context(), user)
+	if err != nil {
+		if errors.Is(err, service.ErrValidationError) {
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		} else {
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp.Formatted(display.FromContext(ctx.Request.Context())))
+}
+
+// ListViews godoc
+// @Summary List saved views
+// @Description Returns all saved list views of the user ordered by name
+// @Tags views
+// @Produce json
+// @Param id path string true "User UUID"
+// @Success 200 {object} []models.SavedView
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /users/{id}/views [get]
+func (ctrl *SubscriptionController) ListViews(ctx *gin.Context) {
+	var user apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&user); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	views, err := ctrl.subscriptionService.ListViews(ctx.Request.Context(), user)
+	if err != nil {
+		if errors.Is(err, service.ErrValidationError) {
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		} else {
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, views)
+}
+
+// BulkUpdateSubscriptions godoc
+// @Summary Change price of user's subscriptions to a service
+// @Description Sets new_price on all user's subscriptions whose service name matches service_name_filter (case-insensitive, * is any characters)
+// @Description in one transaction, e.g. when a provider changes pricing for everyone. Updated subscriptions stop following their plan price.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "User UUID"
+// @Param request body apiModels.BulkUpdateSubscriptionsRequest true "Service name filter and new price"
+// @Success 200 {object} apiModels.BulkUpdateSubscriptionsResponse
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /users/{id}/subscriptions [put]
+func (ctrl *SubscriptionController) BulkUpdateSubscriptions(ctx *gin.Context) {
+	var user apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&user); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	var req apiModels.BulkUpdateSubscriptionsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
+		return
+	}
+
+	resp, err := ctrl.subscriptionService.BulkUpdateSubscriptions(ctx.Request.Context(), user, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// SyncSubscriptions godoc
+// @Summary Sync user's subscriptions
+// @Description Takes the full desired set of user's subscriptions keyed by external_id and reconciles the stored ones in one transaction:
+// @Description missing ones are created, differing ones updated, ones absent from the set deleted. Subscriptions without external_id are left alone.
+// @Description Returns the diff, with dry_run=true only plans it without applying.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path string true "User UUID"
+// @Param dry_run query bool false "Only return the plan"
+// @Param request body apiModels.SyncSubscriptionsRequest true "Desired subscriptions"
+// @Success 200 {object} apiModels.SyncSubscriptionsResponse
+// @Failure 400 {object} apiModels.ErrorResponse
+// @Failure 404 {object} apiModels.ErrorResponse
+// @Failure 409 {object} apiModels.ErrorResponse "Subscription with the same external_id created concurrently"
+// @Failure 500 {object} apiModels.ErrorResponse
+// @Router /users/{id}/subscriptions:sync [put]
+func (ctrl *SubscriptionController) SyncSubscriptions(ctx *gin.Context) {
+	if ctx.Param("action") != "subscriptions:sync" {
+		ctx.JSON(http.StatusNotFound, apiModels.ErrorResponse{Error: http.StatusText(http.StatusNotFound), Code: apiModels.CodeNotFound})
+		return
+	}
+
+	var user apiModels.ItemByIDRequest
+	if err := ctx.ShouldBindUri(&user); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	var query apiModels.SyncSubscriptionsQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadParam))
+		return
+	}
+
+	var req apiModels.SyncSubscriptionsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(apiModels.ErrBadJSON))
+		return
+	}
+
+	resp, err := ctrl.subscriptionService.SyncSubscriptions(ctx.Request.Context(), user, &req, query.DryRun)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrValidationError):
+			ctx.JSON(http.StatusBadRequest, apiModels.NewErrorResponse(err))
+		case errors.Is(err, service.ErrConflict):
+			ctx.JSON(http.StatusConflict, apiModels.NewErrorResponse(err))
+		default:
+			serverError(ctx, err)
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// serverError responds to an error the client can't fix: 503 with Retry-After if the database is unavailable, 500 otherwise
+// serialization of the API version the request came to
+func serialization(ctx *gin.Context) apiModels.Serialization {
+	return apiModels.SerializationFromContext(ctx.Request.Context())
+}
+
+func serverError(ctx *gin.Context, err error) {
+	if errors.Is(err, service.ErrStorageUnavailable) {
+		ctx.Header("Retry-After", strconv.Itoa(int(viper.GetDuration(config.DatabaseRetryAfter).Seconds())))
+		ctx.JSON(http.StatusServiceUnavailable, apiModels.ErrorResponse{Error: http.StatusText(http.StatusServiceUnavailable), Code: apiModels.CodeServiceUnavailable})
+		return
+	}
+	ctx.JSON(http.StatusInternalServerError, apiModels.NewErrorResponse(service.ErrIES))
+}
@@ -6,38 +6,61 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/spf13/viper"
 
+	"subscription-aggregator-service/internal/api/middlewares"
 	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/config"
+	"subscription-aggregator-service/internal/health"
 	"subscription-aggregator-service/internal/models"
 	"subscription-aggregator-service/internal/service"
+	"subscription-aggregator-service/internal/utils/dates"
+	"subscription-aggregator-service/internal/utils/display"
 )
 
 // MockSubscriptionService implements service.SubscriptionService for testing
 type MockSubscriptionService struct {
 	subscriptions map[uuid.UUID]*models.Subscription
+	pauses        map[uuid.UUID]*models.PausedPeriod // Open pause by subscription ID
 }
 
 func NewMockService() *MockSubscriptionService {
 	return &MockSubscriptionService{
 		subscriptions: make(map[uuid.UUID]*models.Subscription),
+		pauses:        make(map[uuid.UUID]*models.PausedPeriod),
 	}
 }
 
 func (m *MockSubscriptionService) CreateSubscription(ctx context.Context, req *apiModels.CreateSubscriptionRequest) (*models.Subscription, error) {
 	if err := req.Validate(); err != nil {
-		return nil, service.ErrValidationError
+		return nil, fmt.Errorf("%w: %w", service.ErrValidationError, err)
+	}
+
+	id := uuid.New()
+	if req.ID != nil {
+		id = uuid.MustParse(*req.ID)
+	}
+	if existing, ok := m.subscriptions[id]; ok {
+		return existing, service.ErrConflict
 	}
 
+	var price int
+	if req.Price != nil {
+		price = *req.Price
+	}
 	sub := &models.Subscription{
-		ID:          uuid.New(),
+		ID:          id,
 		ServiceName: req.ServiceName,
-		Price:       req.Price,
+		Price:       price,
 		UserID:      uuid.MustParse(req.UserID),
 		StartDate:   time.Now(),
 		CreatedAt:   time.Now(),
@@ -47,6 +70,42 @@ func (m *MockSubscriptionService) CreateSubscription(ctx context.Context, req *a
 	return sub, nil
 }
 
+func (m *MockSubscriptionService) CreateSubscriptionsBatch(ctx context.Context, reqs []apiModels.CreateSubscriptionRequest) (*apiModels.BatchCreateResponse, error) {
+	if len(reqs) == 0 {
+		return nil, service.ErrValidationError
+	}
+	resp := &apiModels.BatchCreateResponse{Results: make([]apiModels.BatchItemResult, len(reqs))}
+	for i := range reqs {
+		sub, err := m.CreateSubscription(ctx, &reqs[i])
+		if err != nil {
+			errResp := apiModels.NewErrorResponse(err)
+			resp.Results[i].Error, resp.Results[i].Code = errResp.Error, errResp.Code
+			resp.Failed++
+			continue
+		}
+		resp.Results[i].Subscription = apiModels.NewSubscriptionResponse(sub, apiModels.SerializationFromContext(ctx))
+		resp.Created++
+	}
+	return resp, nil
+}
+
+func (m *MockSubscriptionService) CreateSubscriptionIfAbsent(ctx context.Context, req *apiModels.CreateSubscriptionRequest) (*models.Subscription, bool, error) {
+	if req.ExternalID == nil || *req.ExternalID == "" {
+		return nil, false, service.ErrValidationError
+	}
+	for _, sub := range m.subscriptions {
+		if sub.UserID.String() == req.UserID && sub.ExternalID != nil && *sub.ExternalID == *req.ExternalID {
+			return sub, false, nil
+		}
+	}
+	sub, err := m.CreateSubscription(ctx, req)
+	if err != nil {
+		return sub, false, err
+	}
+	sub.ExternalID = req.ExternalID
+	return sub, true, nil
+}
+
 func (m *MockSubscriptionService) GetSubscriptionByID(ctx context.Context, req apiModels.ItemByIDRequest) (*models.Subscription, error) {
 	id, err := uuid.Parse(req.ID)
 	if err != nil {
@@ -59,6 +118,17 @@ func (m *MockSubscriptionService) GetSubscriptionByID(ctx context.Context, req a
 	return nil, service.ErrNotFound
 }
 
+func (m *MockSubscriptionService) SubscriptionExists(ctx context.Context, req apiModels.ItemByIDRequest) error {
+	id, err := uuid.Parse(req.ID)
+	if err != nil {
+		return service.ErrValidationError
+	}
+	if _, ok := m.subscriptions[id]; !ok {
+		return service.ErrNotFound
+	}
+	return nil
+}
+
 func (m *MockSubscriptionService) UpdateSubscriptionByID(ctx context.Context, req apiModels.ItemByIDRequest, update *apiModels.UpdateSubscriptionRequest) (*models.Subscription, error) {
 	id, err := uuid.Parse(req.ID)
 	if err != nil {
@@ -69,6 +139,9 @@ func (m *MockSubscriptionService) UpdateSubscriptionByID(ctx context.Context, re
 	if !ok {
 		return nil, service.ErrNotFound
 	}
+	if update.IfMatch != "" && update.IfMatch != "*" && update.IfMatch != sub.ETag() {
+		return nil, service.ErrPreconditionFail
+	}
 
 	if update.ServiceName != nil {
 		sub.ServiceName = *update.ServiceName
@@ -76,70 +149,602 @@ func (m *MockSubscriptionService) UpdateSubscriptionByID(ctx context.Context, re
 	if update.Price != nil {
 		sub.Price = *update.Price
 	}
+	if update.EndDate != nil && *update.EndDate == "" {
+		sub.EndDate = nil
+	}
 	sub.UpdatedAt = time.Now()
+	sub.Version++
 
 	return sub, nil
 }
 
-func (m *MockSubscriptionService) DeleteSubscriptionByID(ctx context.Context, req apiModels.ItemByIDRequest) error {
+func (m *MockSubscriptionService) CancelSubscription(ctx context.Context, req apiModels.ItemByIDRequest, cancel *apiModels.CancelSubscriptionRequest) (*models.Subscription, error) {
 	id, err := uuid.Parse(req.ID)
 	if err != nil {
-		return service.ErrValidationError
+		return nil, service.ErrValidationError
+	}
+
+	sub, ok := m.subscriptions[id]
+	if !ok {
+		return nil, service.ErrNotFound
+	}
+	end, err := cancel.ParseEndDate()
+	if err != nil {
+		return nil, service.ErrValidationError
+	}
+	if end == nil {
+		now := time.Now().UTC()
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		end = &month
+	}
+	if end.Before(sub.StartDate) {
+		return nil, service.ErrValidationError
+	}
+
+	now := time.Now()
+	sub.EndDate = end
+	sub.CancelledAt = &now
+	sub.Version++
+	return sub, nil
+}
+
+func (m *MockSubscriptionService) RenewSubscription(ctx context.Context, req apiModels.ItemByIDRequest, renew *apiModels.RenewSubscriptionRequest) (*models.Subscription, error) {
+	id, err := uuid.Parse(req.ID)
+	if err != nil {
+		return nil, service.ErrValidationError
+	}
+
+	sub, ok := m.subscriptions[id]
+	if !ok {
+		return nil, service.ErrNotFound
+	}
+	if err = renew.Validate(); err != nil || sub.EndDate == nil {
+		return nil, service.ErrValidationError
+	}
+
+	end := sub.EndDate.AddDate(0, renew.Months, 0)
+	sub.EndDate = &end
+	sub.CancelledAt = nil
+	sub.Version++
+	return sub, nil
+}
+
+func (m *MockSubscriptionService) DeleteSubscriptionByID(ctx context.Context, req apiModels.ItemByIDRequest) (*models.UndoToken, error) {
+	id, err := uuid.Parse(req.ID)
+	if err != nil {
+		return nil, service.ErrValidationError
 	}
 
 	if _, ok := m.subscriptions[id]; !ok {
-		return service.ErrNotFound
+		return nil, service.ErrNotFound
 	}
 	delete(m.subscriptions, id)
-	return nil
+	return &models.UndoToken{Token: knownUndoToken, SubscriptionID: id, ExpiresAt: time.Now().Add(10 * time.Minute)}, nil
+}
+
+func (m *MockSubscriptionService) UndoDeletion(ctx context.Context, req apiModels.UndoRequest) (*models.Subscription, error) {
+	if req.Token != knownUndoToken.String() {
+		return nil, service.ErrUndoNotFound
+	}
+	return &models.Subscription{ID: uuid.New(), ServiceName: "Restored", Price: 100}, nil
+}
+
+func (m *MockSubscriptionService) PurgeSubscription(ctx context.Context, req apiModels.ItemByIDRequest) (*models.PurgeRecord, error) {
+	id, err := uuid.Parse(req.ID)
+	if err != nil {
+		return nil, service.ErrValidationError
+	}
+	if _, ok := m.subscriptions[id]; !ok {
+		return nil, service.ErrNotFound
+	}
+	delete(m.subscriptions, id)
+	return &models.PurgeRecord{ID: uuid.New(), Subject: models.PurgeSubjectSubscription, SubjectID: id, Subscriptions: 1}, nil
+}
+
+func (m *MockSubscriptionService) PurgeUserData(ctx context.Context, user apiModels.ItemByIDRequest) (*models.PurgeRecord, error) {
+	userID, err := uuid.Parse(user.ID)
+	if err != nil {
+		return nil, service.ErrValidationError
+	}
+	return &models.PurgeRecord{ID: uuid.New(), Subject: models.PurgeSubjectUser, SubjectID: userID}, nil
+}
+
+func (m *MockSubscriptionService) BulkUpdateSubscriptions(ctx context.Context, user apiModels.ItemByIDRequest, req *apiModels.BulkUpdateSubscriptionsRequest) (*apiModels.BulkUpdateSubscriptionsResponse, error) {
+	userID, err := uuid.Parse(user.ID)
+	if err != nil {
+		return nil, service.ErrValidationError
+	}
+	if err = req.Validate(); err != nil {
+		return nil, service.ErrValidationError
+	}
+	resp := &apiModels.BulkUpdateSubscriptionsResponse{Items: []apiModels.SubscriptionResponse{}}
+	for _, sub := range m.subscriptions {
+		if sub.UserID == userID && strings.EqualFold(sub.ServiceName, req.ServiceNameFilter) && sub.Price != *req.NewPrice {
+			sub.Price = *req.NewPrice
+			resp.Items = append(resp.Items, *apiModels.NewSubscriptionResponse(sub, apiModels.SerializationFromContext(ctx)))
+		}
+	}
+	resp.Updated = len(resp.Items)
+	return resp, nil
+}
+
+func (m *MockSubscriptionService) SyncSubscriptions(ctx context.Context, user apiModels.ItemByIDRequest, req *apiModels.SyncSubscriptionsRequest, dryRun bool) (*apiModels.SyncSubscriptionsResponse, error) {
+	if _, err := uuid.Parse(user.ID); err != nil {
+		return nil, service.ErrValidationError
+	}
+	if err := req.Validate(); err != nil {
+		return nil, service.ErrValidationError
+	}
+	resp := &apiModels.SyncSubscriptionsResponse{DryRun: dryRun, Changes: []apiModels.SyncChange{}}
+	for _, item := range req.Subscriptions {
+		if item.ExternalID == "taken" {
+			return nil, service.ErrConflict
+		}
+		resp.Changes = append(resp.Changes, apiModels.SyncChange{Action: "create", ExternalID: item.ExternalID})
+	}
+	return resp, nil
+}
+
+func (m *MockSubscriptionService) ImportSubscriptions(ctx context.Context, req *apiModels.ImportSubscriptionsRequest, allow []string) (*apiModels.ImportSubscriptionsResponse, error) {
+	resp := &apiModels.ImportSubscriptionsResponse{}
+	for _, sub := range req.Subscriptions {
+		if start, err := dates.String2Date(sub.StartDate); err != nil || (start.Year() < 2000 && !slices.Contains(allow, apiModels.RelaxHistoricalStart)) {
+			return nil, service.ErrValidationError
+		}
+		resp.IDs = append(resp.IDs, uuid.New())
+	}
+	return resp, nil
 }
 
-func (m *MockSubscriptionService) ListSubscriptions(ctx context.Context, req apiModels.ListSubscriptionsRequest) ([]models.Subscription, error) {
-	var result []models.Subscription
+func (m *MockSubscriptionService) ListSubscriptions(ctx context.Context, req apiModels.ListSubscriptionsRequest) (*apiModels.ListSubscriptionsResponse, error) {
+	result := []models.Subscription{}
 	for _, sub := range m.subscriptions {
 		result = append(result, *sub)
 	}
-	return result, nil
+	return &apiModels.ListSubscriptionsResponse{Items: apiModels.NewSubscriptionResponses(result, apiModels.SerializationFromContext(ctx)), TotalCount: int64(len(result)), Limit: req.Limit, Offset: 0}, nil
 }
 
 func (m *MockSubscriptionService) TotalSubscriptionsCost(ctx context.Context, req apiModels.TotalCostRequest) (*apiModels.TotalCostResponse, error) {
 	return &apiModels.TotalCostResponse{TotalCost: 1000}, nil
 }
 
+func (m *MockSubscriptionService) TotalSubscriptionsCostBatch(ctx context.Context, req apiModels.BatchTotalCostRequest) (*apiModels.BatchTotalCostResponse, error) {
+	resp := &apiModels.BatchTotalCostResponse{}
+	for _, id := range req.UserIDs {
+		resp.Totals = append(resp.Totals, apiModels.UserTotalCost{UserID: uuid.MustParse(id), TotalCost: 1000})
+	}
+	return resp, nil
+}
+
+func (m *MockSubscriptionService) Chargeback(ctx context.Context, req apiModels.ChargebackRequest) ([]apiModels.ChargebackRow, error) {
+	return []apiModels.ChargebackRow{
+		{Allocation: "", Month: req.StartDate, Cost: 100},
+		{Allocation: "CC, 7", Month: req.StartDate, Cost: 1000},
+	}, nil
+}
+
+func (m *MockSubscriptionService) ListExpiringSubscriptions(ctx context.Context, req apiModels.ExpiringSubscriptionsRequest) (*apiModels.ExpiringSubscriptionsResponse, error) {
+	if req.Window == "1y" {
+		return nil, fmt.Errorf("%w: window must be a number of days", service.ErrValidationError)
+	}
+	resp := &apiModels.ExpiringSubscriptionsResponse{From: "03-2024", To: "05-2024", Users: []apiModels.ExpiringUser{}}
+	for _, sub := range m.subscriptions {
+		if sub.EndDate == nil {
+			continue
+		}
+		resp.Users = append(resp.Users, apiModels.ExpiringUser{UserID: sub.UserID, Subscriptions: []apiModels.SubscriptionResponse{*apiModels.NewSubscriptionResponse(sub, apiModels.SerializationFromContext(ctx))}})
+		resp.TotalCount++
+	}
+	return resp, nil
+}
+
+func (m *MockSubscriptionService) UserSummary(ctx context.Context, user apiModels.ItemByIDRequest) (*apiModels.UserSummaryResponse, error) {
+	userID, err := uuid.Parse(user.ID)
+	if err != nil {
+		return nil, service.ErrValidationError
+	}
+	resp := &apiModels.UserSummaryResponse{UserID: userID}
+	for _, sub := range m.subscriptions {
+		if sub.UserID == userID {
+			resp.ActiveSubscriptions++
+			resp.MonthlyCost += int64(sub.Price)
+		}
+	}
+	return resp, nil
+}
+
+func (m *MockSubscriptionService) ExplainTotalCost(ctx context.Context, req apiModels.TotalCostRequest) (*apiModels.CostExplanationResponse, error) {
+	return &apiModels.CostExplanationResponse{
+		TotalCost: 1000,
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+		Items: []apiModels.CostExplanationItem{
+			{SubscriptionID: uuid.New(), ServiceName: "Netflix", Price: 100, From: req.StartDate, To: req.EndDate, Months: 10, Amount: 1000},
+		},
+	}, nil
+}
+
+func (m *MockSubscriptionService) SuggestServiceNames(ctx context.Context, req apiModels.SuggestServicesRequest) (*apiModels.SuggestServicesResponse, error) {
+	return &apiModels.SuggestServicesResponse{Services: []string{"Netflix"}}, nil
+}
+
+func (m *MockSubscriptionService) CreateView(ctx context.Context, user apiModels.ItemByIDRequest, req *apiModels.CreateViewRequest) (*models.SavedView, error) {
+	if err := req.Validate(); err != nil {
+		return nil, service.ErrValidationError
+	}
+	if req.Name == "Taken" {
+		return nil, service.ErrViewConflict
+	}
+	return &models.SavedView{ID: uuid.New(), UserID: uuid.MustParse(user.ID), Name: req.Name, ServiceName: req.ServiceName,
+		MinPrice: req.MinPrice, MaxPrice: req.MaxPrice, SortBy: req.SortBy, Order: req.Order, Limit: req.Limit, CreatedAt: time.Now()}, nil
+}
+
+func (m *MockSubscriptionService) ListViews(ctx context.Context, user apiModels.ItemByIDRequest) ([]models.SavedView, error) {
+	return []models.SavedView{}, nil
+}
+
+func (m *MockSubscriptionService) CreatePlan(ctx context.Context, req *apiModels.CreatePlanRequest) (*models.Plan, error) {
+	if err := req.Validate(); err != nil {
+		return nil, service.ErrValidationError
+	}
+	if req.Name == "Taken" {
+		return nil, service.ErrPlanConflict
+	}
+	return &models.Plan{ID: uuid.New(), ServiceName: req.ServiceName, Name: req.Name, Price: req.Price, CreatedAt: time.Now(), UpdatedAt: time.Now()}, nil
+}
+
+func (m *MockSubscriptionService) ListPlans(ctx context.Context) ([]models.Plan, error) {
+	return []models.Plan{}, nil
+}
+
+func (m *MockSubscriptionService) UpdatePlanPrice(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.UpdatePlanPriceRequest) (*apiModels.UpdatePlanPriceResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, service.ErrValidationError
+	}
+	if id.ID != knownPlanID {
+		return nil, service.ErrPlanNotFound
+	}
+	return &apiModels.UpdatePlanPriceResponse{Plan: &models.Plan{ID: uuid.MustParse(id.ID), Price: *req.Price}}, nil
+}
+
+func (m *MockSubscriptionService) CreateOrgUnit(ctx context.Context, req *apiModels.CreateOrgUnitRequest) (*models.OrgUnit, error) {
+	if err := req.Validate(); err != nil {
+		return nil, service.ErrValidationError
+	}
+	if req.Name == "Taken" {
+		return nil, service.ErrOrgUnitConflict
+	}
+	return &models.OrgUnit{ID: uuid.New(), Name: req.Name, CreatedAt: time.Now()}, nil
+}
+
+func (m *MockSubscriptionService) ListOrgUnits(ctx context.Context) ([]models.OrgUnit, error) {
+	return []models.OrgUnit{}, nil
+}
+
+func (m *MockSubscriptionService) OrgUnitTotalCost(ctx context.Context, id apiModels.ItemByIDRequest, req apiModels.OrgUnitCostRequest) (*apiModels.OrgUnitCost, error) {
+	if id.ID != knownOrgUnitID {
+		return nil, service.ErrOrgUnitNotFound
+	}
+	return &apiModels.OrgUnitCost{ID: uuid.MustParse(id.ID), Name: "Company", TotalCost: 1000, Children: []apiModels.OrgUnitCost{}}, nil
+}
+
+func (m *MockSubscriptionService) OrgUnitUsage(ctx context.Context, id apiModels.ItemByIDRequest) (*apiModels.OrgUnitUsage, error) {
+	if id.ID != knownOrgUnitID {
+		return nil, service.ErrOrgUnitNotFound
+	}
+	return &apiModels.OrgUnitUsage{ID: uuid.MustParse(id.ID), Name: "Company", Month: "10-2026", Subscriptions: 3, ActiveSubscriptions: 2, MonthlySpend: 1000}, nil
+}
+
+func (m *MockSubscriptionService) StartBulkDelete(ctx context.Context, req *apiModels.BulkDeleteRequest) (*models.BulkDeleteJob, error) {
+	if err := req.Validate(); err != nil {
+		return nil, service.ErrValidationError
+	}
+	return &models.BulkDeleteJob{ID: uuid.New(), Status: models.BulkDeleteStatusPending, Matched: 3}, nil
+}
+
+func (m *MockSubscriptionService) GetBulkDeleteJob(ctx context.Context, id apiModels.ItemByIDRequest) (*models.BulkDeleteJob, error) {
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		return nil, service.ErrValidationError
+	}
+	if id.ID != knownBulkDeleteID {
+		return nil, service.ErrBulkDeleteNotFound
+	}
+	return &models.BulkDeleteJob{ID: uid, Status: models.BulkDeleteStatusRunning, Matched: 3, Deleted: 2}, nil
+}
+
+func (m *MockSubscriptionService) ResumeBulkDeletes(ctx context.Context) error {
+	return nil
+}
+
+func (m *MockSubscriptionService) CreateCredit(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.CreateCreditRequest) (*models.SubscriptionCredit, error) {
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		return nil, service.ErrValidationError
+	}
+	if err = req.Validate(); err != nil {
+		return nil, service.ErrValidationError
+	}
+	if _, ok := m.subscriptions[uid]; !ok {
+		return nil, service.ErrNotFound
+	}
+	return &models.SubscriptionCredit{ID: uuid.New(), SubscriptionID: uid, Amount: req.Amount, StartDate: time.Now(), Description: req.Description, CreatedAt: time.Now()}, nil
+}
+
+func (m *MockSubscriptionService) ListCredits(ctx context.Context, id apiModels.ItemByIDRequest) ([]models.SubscriptionCredit, error) {
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		return nil, service.ErrValidationError
+	}
+	if _, ok := m.subscriptions[uid]; !ok {
+		return nil, service.ErrNotFound
+	}
+	return []models.SubscriptionCredit{}, nil
+}
+
+func (m *MockSubscriptionService) PauseSubscription(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.PauseSubscriptionRequest) (*models.PausedPeriod, error) {
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		return nil, service.ErrValidationError
+	}
+	if _, err = req.ParseMonth(); err != nil {
+		return nil, service.ErrValidationError
+	}
+	if _, ok := m.subscriptions[uid]; !ok {
+		return nil, service.ErrNotFound
+	}
+	if _, ok := m.pauses[uid]; ok {
+		return nil, service.ErrAlreadyPaused
+	}
+	pause := &models.PausedPeriod{ID: uuid.New(), SubscriptionID: uid, StartDate: time.Now(), CreatedAt: time.Now()}
+	m.pauses[uid] = pause
+	return pause, nil
+}
+
+func (m *MockSubscriptionService) ResumeSubscription(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.PauseSubscriptionRequest) (*models.PausedPeriod, error) {
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		return nil, service.ErrValidationError
+	}
+	if _, ok := m.subscriptions[uid]; !ok {
+		return nil, service.ErrNotFound
+	}
+	pause, ok := m.pauses[uid]
+	if !ok {
+		return nil, service.ErrNotPaused
+	}
+	delete(m.pauses, uid)
+	end := time.Now()
+	pause.EndDate = &end
+	return pause, nil
+}
+
+func (m *MockSubscriptionService) ListPausedPeriods(ctx context.Context, id apiModels.ItemByIDRequest) ([]models.PausedPeriod, error) {
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		return nil, service.ErrValidationError
+	}
+	if _, ok := m.subscriptions[uid]; !ok {
+		return nil, service.ErrNotFound
+	}
+	if pause, ok := m.pauses[uid]; ok {
+		return []models.PausedPeriod{*pause}, nil
+	}
+	return []models.PausedPeriod{}, nil
+}
+
+func (m *MockSubscriptionService) RenameService(ctx context.Context, req *apiModels.RenameServiceRequest) (*models.ServiceRename, error) {
+	if err := req.Validate(); err != nil {
+		return nil, service.ErrValidationError
+	}
+	rename := &models.ServiceRename{ID: uuid.New(), FromName: req.From, ToName: req.To, CreatedAt: time.Now()}
+	for _, sub := range m.subscriptions {
+		if strings.EqualFold(sub.ServiceName, req.From) {
+			sub.ServiceName = req.To
+			rename.Renamed++
+		}
+	}
+	now := time.Now()
+	rename.FinishedAt = &now
+	return rename, nil
+}
+
+func (m *MockSubscriptionService) CheckIntegrity(ctx context.Context) ([]models.IntegrityFinding, error) {
+	return m.ListIntegrityFindings(ctx)
+}
+
+func (m *MockSubscriptionService) ListIntegrityFindings(ctx context.Context) ([]models.IntegrityFinding, error) {
+	var findings []models.IntegrityFinding
+	for _, sub := range m.subscriptions {
+		if sub.EndDate != nil && sub.EndDate.Before(sub.StartDate) {
+			findings = append(findings, models.IntegrityFinding{Check: "end_before_start", SubscriptionID: sub.ID})
+		}
+	}
+	return findings, nil
+}
+
 func setupRouter(ctrl *SubscriptionController) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
-
-	r.POST("/subscriptions", ctrl.CreateSubscription)
-	r.GET("/subscriptions/:id", ctrl.GetSubscriptionByID)
-	r.PUT("/subscriptions/:id", ctrl.UpdateSubscriptionByID)
-	r.DELETE("/subscriptions/:id", ctrl.DeleteSubscriptionByID)
-	r.GET("/subscriptions", ctrl.ListSubscriptions)
-	r.GET("/subscriptions/total", ctrl.TotalSubscriptionsCost)
-
+	ctrl.RegisterRoutes(r)
 	return r
 }
 
-func TestCreateSubscriptionHandler(t *testing.T) {
-	mockService := NewMockService()
-	ctrl := NewSubscriptionController(mockService)
-	router := setupRouter(ctrl)
+func TestCreateSubscriptionsBatchHandler(t *testing.T) {
+	router := setupRouter(NewSubscriptionController(NewMockService()))
 
+	const valid = `{"service_name":"Netflix","price":299,"user_id":"550e8400-e29b-41d4-a716-446655440000","start_date":"01-2024"}`
+	const invalid = `{"service_name":"Netflix","price":-1,"user_id":"550e8400-e29b-41d4-a716-446655440000","start_date":"01-2024"}`
+	const withID = `{"id":"7f1d6e4a-1c2b-4a5e-9f3d-2b8c9a0e1f2a","service_name":"Netflix","price":299,"user_id":"550e8400-e29b-41d4-a716-446655440000","start_date":"01-2024"}`
 	tests := []struct {
 		name           string
-		body           interface{}
+		body           string
 		wantStatusCode int
+		wantStatuses   []int
 	}{
-		{
-			name: "valid request",
-			body: apiModels.CreateSubscriptionRequest{
-				ServiceName: "Netflix",
-				Price:       299,
-				UserID:      "550e8400-e29b-41d4-a716-446655440000",
-				StartDate:   "01-2024",
+		{name: "all created", body: "[" + valid + "," + valid + "]", wantStatusCode: http.StatusCreated, wantStatuses: []int{http.StatusCreated, http.StatusCreated}},
+		{name: "partially invalid", body: "[" + valid + "," + invalid + "]", wantStatusCode: http.StatusMultiStatus, wantStatuses: []int{http.StatusCreated, http.StatusBadRequest}},
+		{name: "all invalid", body: "[" + invalid + "]", wantStatusCode: http.StatusBadRequest, wantStatuses: []int{http.StatusBadRequest}},
+		{name: "duplicate", body: "[" + withID + "," + withID + "," + invalid + "]", wantStatusCode: http.StatusMultiStatus, wantStatuses: []int{http.StatusCreated, http.StatusConflict, http.StatusBadRequest}},
+		{name: "empty batch", body: "[]", wantStatusCode: http.StatusBadRequest},
+		{name: "not an array", body: valid, wantStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/subscriptions/batch", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("CreateSubscriptionsBatch() status = %d, want %d", w.Code, tt.wantStatusCode)
+			}
+			if tt.wantStatuses == nil {
+				return
+			}
+			var resp apiModels.BatchCreateResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			var got []int
+			for _, result := range resp.Results {
+				got = append(got, result.Status)
+			}
+			if !slices.Equal(got, tt.wantStatuses) {
+				t.Errorf("CreateSubscriptionsBatch() item statuses = %v, want %v", got, tt.wantStatuses)
+			}
+		})
+	}
+}
+
+func TestCreateSubscriptionValidationFields(t *testing.T) {
+	router := setupRouter(NewSubscriptionController(NewMockService()))
+
+	body, _ := json.Marshal(apiModels.CreateSubscriptionRequest{ServiceName: "Netflix", Price: intPtr(-1), UserID: "not-a-uuid", StartDate: "01-2024"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/subscriptions", bytes.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("CreateSubscription() status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	var resp apiModels.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := []apiModels.FieldError{
+		{Field: "price", Code: apiModels.CodeMustNotBeNegative, Message: "price cannot be negative"},
+		{Field: "user_id", Code: apiModels.CodeInvalidUUID, Message: "user ID must be a valid UUID"},
+	}
+	if !slices.Equal(resp.Fields, want) {
+		t.Errorf("fields = %+v, want %+v", resp.Fields, want)
+	}
+	if !strings.HasPrefix(resp.Error, service.ErrValidationError.Error()) {
+		t.Errorf("error = %q, want it to start with %q", resp.Error, service.ErrValidationError)
+	}
+	if resp.Code != apiModels.CodeValidation {
+		t.Errorf("code = %q, want %q", resp.Code, apiModels.CodeValidation)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/subscriptions/"+uuid.NewString(), nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusNotFound || resp.Code != apiModels.CodeSubscriptionNotFound {
+		t.Errorf("GetSubscriptionByID() = %d %q, want %d %q", w.Code, resp.Code, http.StatusNotFound, apiModels.CodeSubscriptionNotFound)
+	}
+}
+
+func TestCreateSubscriptionHandler(t *testing.T) {
+	mockService := NewMockService()
+	ctrl := NewSubscriptionController(mockService)
+	router := setupRouter(ctrl)
+
+	existingID := uuid.New()
+	mockService.subscriptions[existingID] = &models.Subscription{
+		ID:          existingID,
+		ExternalID:  strPtr("crm-1"),
+		ServiceName: "Test",
+		Price:       100,
+		UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+		StartDate:   time.Now(),
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		body           interface{}
+		wantStatusCode int
+	}{
+		{
+			name: "valid request",
+			body: apiModels.CreateSubscriptionRequest{
+				ServiceName: "Netflix",
+				Price:       intPtr(299),
+				UserID:      "550e8400-e29b-41d4-a716-446655440000",
+				StartDate:   "01-2024",
+			},
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name: "client-supplied ID already exists",
+			body: apiModels.CreateSubscriptionRequest{
+				ID:          strPtr(existingID.String()),
+				ServiceName: "Netflix",
+				Price:       intPtr(299),
+				UserID:      "550e8400-e29b-41d4-a716-446655440000",
+				StartDate:   "01-2024",
+			},
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name:  "if absent by external ID, new",
+			query: "?if_absent_by=external_id",
+			body: apiModels.CreateSubscriptionRequest{
+				ExternalID:  strPtr("crm-2"),
+				ServiceName: "Netflix",
+				Price:       intPtr(299),
+				UserID:      "550e8400-e29b-41d4-a716-446655440000",
+				StartDate:   "01-2024",
 			},
 			wantStatusCode: http.StatusCreated,
 		},
+		{
+			name:  "if absent by external ID, existing",
+			query: "?if_absent_by=external_id",
+			body: apiModels.CreateSubscriptionRequest{
+				ExternalID:  strPtr("crm-1"),
+				ServiceName: "Netflix",
+				Price:       intPtr(299),
+				UserID:      "550e8400-e29b-41d4-a716-446655440000",
+				StartDate:   "01-2024",
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:  "if absent by external ID without external ID",
+			query: "?if_absent_by=external_id",
+			body: apiModels.CreateSubscriptionRequest{
+				ServiceName: "Netflix",
+				Price:       intPtr(299),
+				UserID:      "550e8400-e29b-41d4-a716-446655440000",
+				StartDate:   "01-2024",
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:  "unsupported if_absent_by key",
+			query: "?if_absent_by=service_name",
+			body: apiModels.CreateSubscriptionRequest{
+				ServiceName: "Netflix",
+				Price:       intPtr(299),
+				UserID:      "550e8400-e29b-41d4-a716-446655440000",
+				StartDate:   "01-2024",
+			},
+			wantStatusCode: http.StatusBadRequest,
+		},
 		{
 			name:           "invalid JSON",
 			body:           "not json",
@@ -148,7 +753,7 @@ func TestCreateSubscriptionHandler(t *testing.T) {
 		{
 			name: "missing required field",
 			body: apiModels.CreateSubscriptionRequest{
-				Price:     299,
+				Price:     intPtr(299),
 				UserID:    "550e8400-e29b-41d4-a716-446655440000",
 				StartDate: "01-2024",
 			},
@@ -171,290 +776,1516 @@ func TestCreateSubscriptionHandler(t *testing.T) {
 				}
 			}
 
-			req := httptest.NewRequest(http.MethodPost, "/subscriptions", bytes.NewBuffer(body))
+			req := httptest.NewRequest(http.MethodPost, "/subscriptions"+tt.query, bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("CreateSubscription() status = %d, want %d", w.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestGetSubscriptionByIDHandler(t *testing.T) {
+	mockService := NewMockService()
+	ctrl := NewSubscriptionController(mockService)
+	router := setupRouter(ctrl)
+
+	// Pre-create a subscription
+	existingID := uuid.New()
+	mockService.subscriptions[existingID] = &models.Subscription{
+		ID:          existingID,
+		ServiceName: "Test",
+		Price:       100,
+		UserID:      uuid.New(),
+		StartDate:   time.Now(),
+	}
+
+	tests := []struct {
+		name           string
+		id             string
+		wantStatusCode int
+	}{
+		{
+			name:           "existing subscription",
+			id:             existingID.String(),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "non-existing subscription",
+			id:             uuid.New().String(),
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "invalid UUID",
+			id:             "not-a-uuid",
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/subscriptions/"+tt.id, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("GetSubscriptionByID() status = %d, want %d", w.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestHeadSubscriptionByIDHandler(t *testing.T) {
+	mockService := NewMockService()
+	router := setupRouter(NewSubscriptionController(mockService))
+
+	existingID := uuid.New()
+	mockService.subscriptions[existingID] = &models.Subscription{ID: existingID, ServiceName: "Test", Price: 100, UserID: uuid.New(), StartDate: time.Now()}
+
+	tests := []struct {
+		name           string
+		id             string
+		wantStatusCode int
+	}{
+		{"existing subscription", existingID.String(), http.StatusOK},
+		{"non-existing subscription", uuid.New().String(), http.StatusNotFound},
+		{"invalid UUID", "invalid-uuid", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/subscriptions/"+tt.id, nil))
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("HeadSubscriptionByID() status = %d, want %d", w.Code, tt.wantStatusCode)
+			}
+			if w.Body.Len() != 0 {
+				t.Errorf("HeadSubscriptionByID() body = %q, want empty", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCancelSubscriptionHandler(t *testing.T) {
+	mockService := NewMockService()
+	router := setupRouter(NewSubscriptionController(mockService))
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	existingID := uuid.New()
+	mockService.subscriptions[existingID] = &models.Subscription{ID: existingID, ServiceName: "Test", Price: 100, UserID: uuid.New(), StartDate: start}
+
+	tests := []struct {
+		name           string
+		id             string
+		body           string
+		wantStatusCode int
+		wantEnd        string
+	}{
+		{name: "current month", id: existingID.String(), wantStatusCode: http.StatusOK, wantEnd: time.Date(time.Now().UTC().Year(), time.Now().UTC().Month(), 1, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)},
+		{name: "given month", id: existingID.String(), body: `{"end_date":"06-2025"}`, wantStatusCode: http.StatusOK, wantEnd: "2025-06-01T00:00:00Z"},
+		{name: "before start", id: existingID.String(), body: `{"end_date":"12-2024"}`, wantStatusCode: http.StatusBadRequest},
+		{name: "invalid JSON", id: existingID.String(), body: `{`, wantStatusCode: http.StatusBadRequest},
+		{name: "non-existing subscription", id: uuid.New().String(), wantStatusCode: http.StatusNotFound},
+		{name: "invalid UUID", id: "invalid-uuid", wantStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/subscriptions/"+tt.id+"/cancel", strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("CancelSubscription() status = %d, want %d, body: %s", w.Code, tt.wantStatusCode, w.Body.String())
+			}
+			if tt.wantStatusCode != http.StatusOK {
+				return
+			}
+			var sub apiModels.SubscriptionResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &sub); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if sub.EndDate == nil || *sub.EndDate != tt.wantEnd {
+				t.Errorf("CancelSubscription() end_date = %v, want %s", sub.EndDate, tt.wantEnd)
+			}
+			if sub.CancelledAt == nil {
+				t.Error("CancelSubscription() cancelled_at is not set")
+			}
+			if want := fmt.Sprintf(`"%d"`, sub.Version); w.Header().Get("ETag") != want {
+				t.Errorf("ETag = %q, want %q", w.Header().Get("ETag"), want)
+			}
+		})
+	}
+}
+
+func TestRenewSubscriptionHandler(t *testing.T) {
+	mockService := NewMockService()
+	router := setupRouter(NewSubscriptionController(mockService))
+
+	end := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	existingID := uuid.New()
+	mockService.subscriptions[existingID] = &models.Subscription{ID: existingID, ServiceName: "Test", Price: 100, UserID: uuid.New(),
+		StartDate: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), EndDate: &end}
+
+	tests := []struct {
+		name           string
+		id             string
+		body           string
+		wantStatusCode int
+		wantEnd        string
+	}{
+		{name: "valid renewal", id: existingID.String(), body: `{"months":3}`, wantStatusCode: http.StatusOK, wantEnd: "2025-09-01T00:00:00Z"},
+		{name: "zero months", id: existingID.String(), body: `{"months":0}`, wantStatusCode: http.StatusBadRequest},
+		{name: "missing body", id: existingID.String(), wantStatusCode: http.StatusBadRequest},
+		{name: "non-existing subscription", id: uuid.New().String(), body: `{"months":3}`, wantStatusCode: http.StatusNotFound},
+		{name: "invalid UUID", id: "invalid-uuid", body: `{"months":3}`, wantStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/subscriptions/"+tt.id+"/renew", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("RenewSubscription() status = %d, want %d, body: %s", w.Code, tt.wantStatusCode, w.Body.String())
+			}
+			if tt.wantStatusCode != http.StatusOK {
+				return
+			}
+			var sub apiModels.SubscriptionResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &sub); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if sub.EndDate == nil || *sub.EndDate != tt.wantEnd {
+				t.Errorf("RenewSubscription() end_date = %v, want %s", sub.EndDate, tt.wantEnd)
+			}
+			if want := fmt.Sprintf(`"%d"`, sub.Version); w.Header().Get("ETag") != want {
+				t.Errorf("ETag = %q, want %q", w.Header().Get("ETag"), want)
+			}
+		})
+	}
+}
+
+func TestParseDateHandler(t *testing.T) {
+	router := setupRouter(NewSubscriptionController(NewMockService()))
+
+	tests := []struct {
+		name           string
+		body           string
+		wantStatusCode int
+		wantDate       string
+	}{
+		{name: "english name", body: `{"date":"Jan 2024"}`, wantStatusCode: http.StatusOK, wantDate: "01-2024"},
+		{name: "russian genitive", body: `{"date":"марта 2025 г."}`, wantStatusCode: http.StatusOK, wantDate: "03-2025"},
+		{name: "already normalized", body: `{"date":"12-2023"}`, wantStatusCode: http.StatusOK, wantDate: "12-2023"},
+		{name: "unparseable", body: `{"date":"someday"}`, wantStatusCode: http.StatusBadRequest},
+		{name: "missing date", body: `{}`, wantStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/utils/parse-date", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("ParseDate() status = %d, want %d, body: %s", w.Code, tt.wantStatusCode, w.Body.String())
+			}
+			if tt.wantDate == "" {
+				return
+			}
+			var resp apiModels.ParseDateResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.Date != tt.wantDate {
+				t.Errorf("ParseDate() date = %q, want %q", resp.Date, tt.wantDate)
+			}
+		})
+	}
+}
+
+func TestUpdateSubscriptionByIDHandler(t *testing.T) {
+	mockService := NewMockService()
+	ctrl := NewSubscriptionController(mockService)
+	router := setupRouter(ctrl)
+
+	existingID := uuid.New()
+	mockService.subscriptions[existingID] = &models.Subscription{
+		ID:          existingID,
+		ServiceName: "Test",
+		Price:       100,
+		UserID:      uuid.New(),
+		StartDate:   time.Now(),
+	}
+
+	tests := []struct {
+		name           string
+		id             string
+		body           interface{}
+		wantStatusCode int
+	}{
+		{
+			name: "valid update",
+			id:   existingID.String(),
+			body: apiModels.UpdateSubscriptionRequest{
+				ServiceName: strPtr("Updated Name"),
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "non-existing subscription",
+			id:             uuid.New().String(),
+			body:           apiModels.UpdateSubscriptionRequest{ServiceName: strPtr("Test")},
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "invalid UUID",
+			id:             "not-a-uuid",
+			body:           apiModels.UpdateSubscriptionRequest{ServiceName: strPtr("Test")},
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid JSON",
+			id:             existingID.String(),
+			body:           "not json",
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			var err error
+
+			switch v := tt.body.(type) {
+			case string:
+				body = []byte(v)
+			default:
+				body, err = json.Marshal(tt.body)
+				if err != nil {
+					t.Fatalf("failed to marshal request body: %v", err)
+				}
+			}
+
+			req := httptest.NewRequest(http.MethodPut, "/subscriptions/"+tt.id, bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("UpdateSubscriptionByID() status = %d, want %d", w.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestPatchSubscriptionByIDHandler(t *testing.T) {
+	mockService := NewMockService()
+	router := setupRouter(NewSubscriptionController(mockService))
+
+	existingID := uuid.New()
+	end := time.Now().AddDate(1, 0, 0)
+	mockService.subscriptions[existingID] = &models.Subscription{
+		ID:          existingID,
+		ServiceName: "Test",
+		Price:       100,
+		UserID:      uuid.New(),
+		StartDate:   time.Now(),
+		EndDate:     &end,
+	}
+
+	tests := []struct {
+		name           string
+		id             string
+		body           string
+		wantStatusCode int
+	}{
+		{"price only", existingID.String(), `{"price": 150}`, http.StatusOK},
+		{"clear end date", existingID.String(), `{"end_date": null}`, http.StatusOK},
+		{"required field cannot be cleared", existingID.String(), `{"service_name": null}`, http.StatusBadRequest},
+		{"unknown field", existingID.String(), `{"user_id": "beef4269-0a1b-0c1F-afce-e13873b7b23b"}`, http.StatusBadRequest},
+		{"wrong type", existingID.String(), `{"price": "150"}`, http.StatusBadRequest},
+		{"not an object", existingID.String(), `[]`, http.StatusBadRequest},
+		{"non-existing subscription", uuid.New().String(), `{"price": 150}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/subscriptions/"+tt.id, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("PatchSubscriptionByID() status = %d, want %d, body: %s", w.Code, tt.wantStatusCode, w.Body.String())
+			}
+		})
+	}
+
+	sub := mockService.subscriptions[existingID]
+	if sub.Price != 150 || sub.EndDate != nil || sub.ServiceName != "Test" {
+		t.Errorf("after patches price = %d, end_date = %v, service_name = %q; want 150, cleared, unchanged", sub.Price, sub.EndDate, sub.ServiceName)
+	}
+}
+
+func TestUpdateSubscriptionIfMatchHandler(t *testing.T) {
+	mockService := NewMockService()
+	router := setupRouter(NewSubscriptionController(mockService))
+
+	existingID := uuid.New()
+	mockService.subscriptions[existingID] = &models.Subscription{ID: existingID, ServiceName: "Test", Price: 100, UserID: uuid.New(), StartDate: time.Now(), Version: 1}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/subscriptions/"+existingID.String(), nil))
+	etag := w.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("GetSubscriptionByID() ETag = %q, want %q", etag, `"1"`)
+	}
+
+	update := func(ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/subscriptions/"+existingID.String(), strings.NewReader(`{"price": 150}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", ifMatch)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w = update(etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("UpdateSubscriptionByID() with current ETag status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if got := w.Header().Get("ETag"); got != `"2"` {
+		t.Errorf("UpdateSubscriptionByID() ETag = %q, want %q", got, `"2"`)
+	}
+
+	if w = update(etag); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("UpdateSubscriptionByID() with stale ETag status = %d, want %d", w.Code, http.StatusPreconditionFailed)
+	}
+	if price := mockService.subscriptions[existingID].Price; price != 150 {
+		t.Errorf("UpdateSubscriptionByID() with stale ETag changed price to %d", price)
+	}
+}
+
+// unavailableService fails reads as during database failover
+type unavailableService struct{ *MockSubscriptionService }
+
+func (unavailableService) GetSubscriptionByID(context.Context, apiModels.ItemByIDRequest) (*models.Subscription, error) {
+	return nil, fmt.Errorf("%w: connection refused", service.ErrStorageUnavailable)
+}
+
+func TestStorageUnavailable(t *testing.T) {
+	viper.Set(config.DatabaseRetryAfter, "5s")
+	t.Cleanup(func() { viper.Set(config.DatabaseRetryAfter, nil) })
+	router := setupRouter(NewSubscriptionController(unavailableService{NewMockService()}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/subscriptions/"+uuid.New().String(), nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("GetSubscriptionByID() status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if got := w.Header().Get("Retry-After"); got != "5" {
+		t.Errorf("GetSubscriptionByID() Retry-After = %q, want %q", got, "5")
+	}
+}
+
+func TestDeleteSubscriptionByIDHandler(t *testing.T) {
+	mockService := NewMockService()
+	ctrl := NewSubscriptionController(mockService)
+	router := setupRouter(ctrl)
+
+	existingID := uuid.New()
+	mockService.subscriptions[existingID] = &models.Subscription{
+		ID:          existingID,
+		ServiceName: "Test",
+		Price:       100,
+		UserID:      uuid.New(),
+		StartDate:   time.Now(),
+	}
+
+	tests := []struct {
+		name           string
+		id             string
+		wantStatusCode int
+	}{
+		{
+			name:           "existing subscription",
+			id:             existingID.String(),
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "non-existing subscription",
+			id:             uuid.New().String(),
+			wantStatusCode: http.StatusNotFound,
+		},
+		{
+			name:           "invalid UUID",
+			id:             "not-a-uuid",
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodDelete, "/subscriptions/"+tt.id, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("DeleteSubscriptionByID() status = %d, want %d", w.Code, tt.wantStatusCode)
+			}
+			if w.Code == http.StatusOK && !strings.Contains(w.Body.String(), knownUndoToken.String()) {
+				t.Errorf("DeleteSubscriptionByID() body = %s, want undo token", w.Body.String())
+			}
+		})
+	}
+}
+
+var knownUndoToken = uuid.MustParse("66666666-7777-8888-9999-000000000000")
+
+func TestUndoDeletionHandler(t *testing.T) {
+	router := setupRouter(NewSubscriptionController(NewMockService()))
+
+	tests := []struct {
+		name           string
+		token          string
+		wantStatusCode int
+	}{
+		{name: "valid token", token: knownUndoToken.String(), wantStatusCode: http.StatusOK},
+		{name: "unknown or expired token", token: uuid.NewString(), wantStatusCode: http.StatusNotFound},
+		{name: "invalid token", token: "not-a-uuid", wantStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/undo/"+tt.token, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("UndoDeletion() status = %d, want %d", w.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestListSubscriptionsHandler(t *testing.T) {
+	mockService := NewMockService()
+	ctrl := NewSubscriptionController(mockService)
+	router := setupRouter(ctrl)
+
+	// Pre-create subscriptions
+	for i := 0; i < 3; i++ {
+		id := uuid.New()
+		mockService.subscriptions[id] = &models.Subscription{
+			ID:          id,
+			ServiceName: "Test",
+			Price:       100,
+			UserID:      uuid.New(),
+			StartDate:   time.Now(),
+		}
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		wantStatusCode int
+	}{
+		{
+			name:           "list all",
+			query:          "",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "with user_id filter",
+			query:          "?user_id=550e8400-e29b-41d4-a716-446655440000",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "with service_name filter",
+			query:          "?service_name=Netflix",
+			wantStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/subscriptions"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("ListSubscriptions() status = %d, want %d", w.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestTotalSubscriptionsCostHandler(t *testing.T) {
+	mockService := NewMockService()
+	ctrl := NewSubscriptionController(mockService)
+	router := setupRouter(ctrl)
+
+	tests := []struct {
+		name           string
+		query          string
+		wantStatusCode int
+	}{
+		{
+			name:           "valid request",
+			query:          "?start_date=01-2024&end_date=12-2024",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "with user_id",
+			query:          "?start_date=01-2024&end_date=12-2024&user_id=550e8400-e29b-41d4-a716-446655440000",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "grouped by month",
+			query:          "?start_date=01-2024&end_date=12-2024&group_by=month",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "unknown group_by",
+			query:          "?start_date=01-2024&end_date=12-2024&group_by=user_id",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "missing start_date",
+			query:          "?end_date=12-2024",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "missing end_date",
+			query:          "?start_date=01-2024",
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/subscriptions/total"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("TotalSubscriptionsCost() status = %d, want %d", w.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestTotalSubscriptionsCostDisplayPrefs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middlewares.DisplayPrefs(display.NewNegotiator("ru", "RUB", map[string]float64{"USD": 80})))
+	NewSubscriptionController(NewMockService()).RegisterRoutes(router)
+
+	tests := []struct {
+		name     string
+		locale   string
+		currency string
+		want     string
+	}{
+		{name: "default", want: "1\u00a0000\u00a0₽"},
+		{name: "english dollars", locale: "en", currency: "USD", want: "$12.50"},
+		{name: "unknown currency ignored", locale: "en", currency: "XYZ", want: "₽1,000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/subscriptions/total?start_date=01-2024&end_date=12-2024", nil)
+			req.Header.Set(display.LocaleHeader, tt.locale)
+			req.Header.Set(display.CurrencyHeader, tt.currency)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			var resp apiModels.TotalCostResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if resp.TotalCost != 1000 {
+				t.Errorf("total_cost = %d, want raw 1000 regardless of preferences", resp.TotalCost)
+			}
+			if resp.FormattedTotalCost != tt.want {
+				t.Errorf("formatted_total_cost = %q, want %q", resp.FormattedTotalCost, tt.want)
+			}
+		})
+	}
+}
+
+func TestTotalSubscriptionsCostBatchHandler(t *testing.T) {
+	router := setupRouter(NewSubscriptionController(NewMockService()))
+
+	tests := []struct {
+		name           string
+		body           string
+		wantStatusCode int
+	}{
+		{"valid request", `{"user_ids":["550e8400-e29b-41d4-a716-446655440000","beef4269-0a1b-0c1f-afce-e13873b7b23b"],"start_date":"01-2024","end_date":"12-2024"}`, http.StatusOK},
+		{"invalid user ID", `{"user_ids":["not-a-uuid"],"start_date":"01-2024","end_date":"12-2024"}`, http.StatusBadRequest},
+		{"missing user_ids", `{"start_date":"01-2024","end_date":"12-2024"}`, http.StatusBadRequest},
+		{"missing end_date", `{"user_ids":["550e8400-e29b-41d4-a716-446655440000"],"start_date":"01-2024"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/subscriptions/total/batch", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("TotalSubscriptionsCostBatch() status = %d, want %d, body: %s", w.Code, tt.wantStatusCode, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var resp apiModels.BatchTotalCostResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(resp.Totals) != 2 || resp.Totals[0].UserID.String() != "550e8400-e29b-41d4-a716-446655440000" {
+				t.Errorf("totals = %+v, want one per user in request order", resp.Totals)
+			}
+		})
+	}
+}
+
+func TestUserSummaryHandler(t *testing.T) {
+	mockService := NewMockService()
+	userID := uuid.New()
+	mockService.subscriptions[uuid.New()] = &models.Subscription{ServiceName: "Netflix", Price: 300, UserID: userID, StartDate: time.Now()}
+	router := setupRouter(NewSubscriptionController(mockService))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/"+userID.String()+"/summary", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("UserSummary() status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var resp apiModels.UserSummaryResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.UserID != userID || resp.ActiveSubscriptions != 1 || resp.MonthlyCost != 300 {
+		t.Errorf("UserSummary() = %+v, want 1 active subscription costing 300", resp)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/not-a-uuid/summary", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("UserSummary() with invalid UUID status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestChargebackHandler(t *testing.T) {
+	router := setupRouter(NewSubscriptionController(NewMockService()))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/subscriptions/chargeback?by=cost_center&start_date=01-2024&end_date=12-2024", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Chargeback() status = %d, want %d, body: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Content-Type = %q, want text/csv", ct)
+	}
+	if cd := w.Header().Get("Content-Disposition"); !strings.Contains(cd, `filename="chargeback-cost_center-01-2024-12-2024.csv"`) {
+		t.Errorf("Content-Disposition = %q, want attachment named after the request", cd)
+	}
+	const want = "cost_center,month,cost\n,01-2024,100\n\"CC, 7\",01-2024,1000\n"
+	if w.Body.String() != want {
+		t.Errorf("Chargeback() body = %q, want %q", w.Body.String(), want)
+	}
+
+	for _, query := range []string{"?start_date=01-2024&end_date=12-2024", "?by=team&start_date=01-2024&end_date=12-2024", "?by=cost_center"} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/subscriptions/chargeback"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Chargeback%s status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestListExpiringSubscriptionsHandler(t *testing.T) {
+	mockService := NewMockService()
+	end := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	sub := &models.Subscription{ID: uuid.New(), ServiceName: "Netflix", Price: 500, UserID: uuid.New(), StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), EndDate: &end}
+	mockService.subscriptions[sub.ID] = sub
+	router := setupRouter(NewSubscriptionController(mockService))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/subscriptions/expiring?window=60d&auto_renew=false", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("ListExpiringSubscriptions() status = %d, want %d, body: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var resp apiModels.ExpiringSubscriptionsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.TotalCount != 1 || len(resp.Users) != 1 || resp.Users[0].UserID != sub.UserID || len(resp.Users[0].Subscriptions) != 1 {
+		t.Fatalf("ListExpiringSubscriptions() = %+v, want one user with one subscription", resp)
+	}
+	if got := resp.Users[0].Subscriptions[0].EndDate; got == nil || *got != "2024-04-01T00:00:00Z" {
+		t.Errorf("end_date = %v, want 2024-04-01T00:00:00Z", got)
+	}
+
+	for _, query := range []string{"?window=1y", "?auto_renew=maybe"} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/subscriptions/expiring"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("ListExpiringSubscriptions%s status = %d, want %d", query, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestExplainTotalCostHandler(t *testing.T) {
+	mockService := NewMockService()
+	ctrl := NewSubscriptionController(mockService)
+	router := setupRouter(ctrl)
+
+	tests := []struct {
+		name           string
+		query          string
+		wantStatusCode int
+	}{
+		{
+			name:           "valid request",
+			query:          "?start_date=01-2024&end_date=12-2024",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "missing end_date",
+			query:          "?start_date=01-2024",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid user_id",
+			query:          "?start_date=01-2024&end_date=12-2024&user_id=not-a-uuid",
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/subscriptions/total/explain"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("ExplainTotalCost() status = %d, want %d", w.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestSuggestServiceNamesHandler(t *testing.T) {
+	mockService := NewMockService()
+	ctrl := NewSubscriptionController(mockService)
+	router := setupRouter(ctrl)
+
+	tests := []struct {
+		name           string
+		query          string
+		wantStatusCode int
+	}{
+		{
+			name:           "valid request",
+			query:          "?q=net",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "missing query",
+			query:          "",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "limit too high",
+			query:          "?q=net&limit=500",
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid user_id",
+			query:          "?q=net&user_id=not-a-uuid",
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/services/suggest"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("SuggestServiceNames() status = %d, want %d", w.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestCreateViewHandler(t *testing.T) {
+	mockService := NewMockService()
+	ctrl := NewSubscriptionController(mockService)
+	router := setupRouter(ctrl)
+
+	tests := []struct {
+		name           string
+		userID         string
+		body           string
+		wantStatusCode int
+	}{
+		{
+			name:           "valid request",
+			userID:         "550e8400-e29b-41d4-a716-446655440000",
+			body:           `{"name":"Streaming","service_name":"Netflix","limit":10}`,
+			wantStatusCode: http.StatusCreated,
+		},
+		{
+			name:           "duplicate name",
+			userID:         "550e8400-e29b-41d4-a716-446655440000",
+			body:           `{"name":"Taken"}`,
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name:           "missing name",
+			userID:         "550e8400-e29b-41d4-a716-446655440000",
+			body:           `{"service_name":"Netflix"}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid user ID",
+			userID:         "not-a-uuid",
+			body:           `{"name":"Streaming"}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid JSON",
+			userID:         "550e8400-e29b-41d4-a716-446655440000",
+			body:           `{invalid}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users/"+tt.userID+"/views", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("CreateView() status = %d, want %d", w.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestCreditHandlers(t *testing.T) {
+	mockService := NewMockService()
+	ctrl := NewSubscriptionController(mockService)
+	router := setupRouter(ctrl)
+
+	existingID := uuid.New()
+	mockService.subscriptions[existingID] = &models.Subscription{ID: existingID, ServiceName: "Netflix", Price: 400, UserID: uuid.New(), StartDate: time.Now()}
+
+	tests := []struct {
+		name           string
+		method         string
+		id             string
+		body           string
+		wantStatusCode int
+	}{
+		{name: "create credit", method: http.MethodPost, id: existingID.String(), body: `{"amount":-100,"start_date":"01-2025","description":"promo"}`, wantStatusCode: http.StatusCreated},
+		{name: "positive amount", method: http.MethodPost, id: existingID.String(), body: `{"amount":100,"start_date":"01-2025"}`, wantStatusCode: http.StatusBadRequest},
+		{name: "create for unknown subscription", method: http.MethodPost, id: uuid.NewString(), body: `{"amount":-100,"start_date":"01-2025"}`, wantStatusCode: http.StatusNotFound},
+		{name: "invalid JSON", method: http.MethodPost, id: existingID.String(), body: `{invalid}`, wantStatusCode: http.StatusBadRequest},
+		{name: "list credits", method: http.MethodGet, id: existingID.String(), wantStatusCode: http.StatusOK},
+		{name: "list for unknown subscription", method: http.MethodGet, id: uuid.NewString(), wantStatusCode: http.StatusNotFound},
+		{name: "invalid ID", method: http.MethodGet, id: "not-a-uuid", wantStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/subscriptions/"+tt.id+"/credits", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("%s credits status = %d, want %d", tt.method, w.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestPauseSubscriptionHandler(t *testing.T) {
+	mockService := NewMockService()
+	router := setupRouter(NewSubscriptionController(mockService))
+
+	existingID := uuid.New()
+	mockService.subscriptions[existingID] = &models.Subscription{ID: existingID, ServiceName: "Netflix", Price: 400, UserID: uuid.New(), StartDate: time.Now()}
+
+	// Steps run in order against the same subscription
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		wantStatusCode int
+	}{
+		{name: "resume not paused", method: http.MethodPost, path: "/resume", wantStatusCode: http.StatusConflict},
+		{name: "invalid month", method: http.MethodPost, path: "/pause", body: `{"month":"2025-13"}`, wantStatusCode: http.StatusBadRequest},
+		{name: "invalid JSON", method: http.MethodPost, path: "/pause", body: `{invalid}`, wantStatusCode: http.StatusBadRequest},
+		{name: "pause", method: http.MethodPost, path: "/pause", wantStatusCode: http.StatusCreated},
+		{name: "pause again", method: http.MethodPost, path: "/pause", body: `{"month":"06-2026"}`, wantStatusCode: http.StatusConflict},
+		{name: "list pauses", method: http.MethodGet, path: "/pauses", wantStatusCode: http.StatusOK},
+		{name: "resume", method: http.MethodPost, path: "/resume", wantStatusCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/subscriptions/"+existingID.String()+tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("%s %s status = %d, want %d, body: %s", tt.method, tt.path, w.Code, tt.wantStatusCode, w.Body.String())
+			}
+		})
+	}
+
+	for _, path := range []string{"/pause", "/resume"} {
+		req := httptest.NewRequest(http.MethodPost, "/subscriptions/"+uuid.NewString()+path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("POST %s for unknown subscription status = %d, want %d", path, w.Code, http.StatusNotFound)
+		}
+	}
+}
+
+func TestSyncSubscriptionsHandler(t *testing.T) {
+	mockService := NewMockService()
+	ctrl := NewSubscriptionController(mockService)
+	router := setupRouter(ctrl)
+
+	const userID = "550e8400-e29b-41d4-a716-446655440000"
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		wantStatusCode int
+		wantDryRun     bool
+	}{
+		{
+			name:           "valid request",
+			path:           "/users/" + userID + "/subscriptions:sync",
+			body:           `{"subscriptions":[{"external_id":"crm-1","service_name":"Netflix","price":299,"start_date":"01-2024"}]}`,
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "dry run",
+			path:           "/users/" + userID + "/subscriptions:sync?dry_run=true",
+			body:           `{"subscriptions":[]}`,
+			wantStatusCode: http.StatusOK,
+			wantDryRun:     true,
+		},
+		{
+			name:           "missing subscriptions",
+			path:           "/users/" + userID + "/subscriptions:sync",
+			body:           `{}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "duplicate external ID",
+			path:           "/users/" + userID + "/subscriptions:sync",
+			body:           `{"subscriptions":[{"external_id":"crm-1"},{"external_id":"crm-1"}]}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "conflict",
+			path:           "/users/" + userID + "/subscriptions:sync",
+			body:           `{"subscriptions":[{"external_id":"taken"}]}`,
+			wantStatusCode: http.StatusConflict,
+		},
+		{
+			name:           "invalid dry_run",
+			path:           "/users/" + userID + "/subscriptions:sync?dry_run=maybe",
+			body:           `{"subscriptions":[]}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid user ID",
+			path:           "/users/not-a-uuid/subscriptions:sync",
+			body:           `{"subscriptions":[]}`,
+			wantStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown action",
+			path:           "/users/" + userID + "/subscriptions:purge",
+			body:           `{"subscriptions":[]}`,
+			wantStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("SyncSubscriptions() status = %d, want %d", w.Code, tt.wantStatusCode)
+			}
+			if w.Code == http.StatusOK {
+				var resp apiModels.SyncSubscriptionsResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				if resp.DryRun != tt.wantDryRun {
+					t.Errorf("SyncSubscriptions() dry_run = %v, want %v", resp.DryRun, tt.wantDryRun)
+				}
+			}
+		})
+	}
+}
+
+func TestBulkUpdateSubscriptionsHandler(t *testing.T) {
+	mockService := NewMockService()
+	router := setupRouter(NewSubscriptionController(mockService))
+
+	userID := uuid.New()
+	subID := uuid.New()
+	mockService.subscriptions[subID] = &models.Subscription{ID: subID, ServiceName: "Netflix", Price: 599, UserID: userID, StartDate: time.Now()}
+
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		wantStatusCode int
+		wantUpdated    int
+	}{
+		{"price change", "/users/" + userID.String() + "/subscriptions", `{"service_name_filter": "netflix", "new_price": 699}`, http.StatusOK, 1},
+		{"nothing matches", "/users/" + userID.String() + "/subscriptions", `{"service_name_filter": "Spotify", "new_price": 699}`, http.StatusOK, 0},
+		{"no price", "/users/" + userID.String() + "/subscriptions", `{"service_name_filter": "netflix"}`, http.StatusBadRequest, 0},
+		{"invalid user", "/users/not-a-uuid/subscriptions", `{"service_name_filter": "netflix", "new_price": 699}`, http.StatusBadRequest, 0},
+		{"invalid JSON", "/users/" + userID.String() + "/subscriptions", `not json`, http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, tt.path, strings.NewReader(tt.body))
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
-
 			router.ServeHTTP(w, req)
 
 			if w.Code != tt.wantStatusCode {
-				t.Errorf("CreateSubscription() status = %d, want %d", w.Code, tt.wantStatusCode)
+				t.Fatalf("BulkUpdateSubscriptions() status = %d, want %d, body: %s", w.Code, tt.wantStatusCode, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var resp apiModels.BulkUpdateSubscriptionsResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if resp.Updated != tt.wantUpdated || len(resp.Items) != tt.wantUpdated {
+				t.Errorf("BulkUpdateSubscriptions() updated = %d with %d items, want %d", resp.Updated, len(resp.Items), tt.wantUpdated)
 			}
 		})
 	}
 }
 
-func TestGetSubscriptionByIDHandler(t *testing.T) {
+func TestIntegrityHandlers(t *testing.T) {
 	mockService := NewMockService()
-	ctrl := NewSubscriptionController(mockService)
-	router := setupRouter(ctrl)
+	bad := &models.Subscription{ID: uuid.New(), ServiceName: "Legacy", UserID: uuid.New(), StartDate: time.Now(), EndDate: &time.Time{}}
+	mockService.subscriptions[bad.ID] = bad
+	router := gin.New()
+	NewAdminController(mockService).RegisterRoutes(router)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/integrity/findings", nil),
+		httptest.NewRequest(http.MethodPost, "/integrity/check", nil),
+	} {
+		t.Run(req.Method+" "+req.URL.Path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
 
-	// Pre-create a subscription
-	existingID := uuid.New()
-	mockService.subscriptions[existingID] = &models.Subscription{
-		ID:          existingID,
-		ServiceName: "Test",
-		Price:       100,
-		UserID:      uuid.New(),
-		StartDate:   time.Now(),
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			var findings []models.IntegrityFinding
+			if err := json.Unmarshal(w.Body.Bytes(), &findings); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if len(findings) != 1 || findings[0].SubscriptionID != bad.ID {
+				t.Errorf("findings = %+v, want one for %s", findings, bad.ID)
+			}
+		})
 	}
+}
+
+func TestPurgeHandlers(t *testing.T) {
+	mockService := NewMockService()
+	existingID := uuid.New()
+	mockService.subscriptions[existingID] = &models.Subscription{ID: existingID, ServiceName: "Test", UserID: uuid.New(), StartDate: time.Now()}
+	router := gin.New()
+	NewAdminController(mockService).RegisterRoutes(router)
 
 	tests := []struct {
 		name           string
-		id             string
+		path           string
 		wantStatusCode int
 	}{
-		{
-			name:           "existing subscription",
-			id:             existingID.String(),
-			wantStatusCode: http.StatusOK,
-		},
-		{
-			name:           "non-existing subscription",
-			id:             uuid.New().String(),
-			wantStatusCode: http.StatusNotFound,
-		},
-		{
-			name:           "invalid UUID",
-			id:             "not-a-uuid",
-			wantStatusCode: http.StatusBadRequest,
-		},
+		{"purge subscription", "/subscriptions/" + existingID.String() + "/purge", http.StatusOK},
+		{"purge purged subscription", "/subscriptions/" + existingID.String() + "/purge", http.StatusNotFound},
+		{"purge subscription with invalid UUID", "/subscriptions/not-a-uuid/purge", http.StatusBadRequest},
+		{"purge user data", "/users/" + uuid.New().String() + "/data", http.StatusOK},
+		{"purge user data with invalid UUID", "/users/not-a-uuid/data", http.StatusBadRequest},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodGet, "/subscriptions/"+tt.id, nil)
 			w := httptest.NewRecorder()
-
-			router.ServeHTTP(w, req)
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, tt.path, nil))
 
 			if w.Code != tt.wantStatusCode {
-				t.Errorf("GetSubscriptionByID() status = %d, want %d", w.Code, tt.wantStatusCode)
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatusCode)
 			}
 		})
 	}
 }
 
-func TestUpdateSubscriptionByIDHandler(t *testing.T) {
+func TestRenameServiceHandler(t *testing.T) {
 	mockService := NewMockService()
-	ctrl := NewSubscriptionController(mockService)
-	router := setupRouter(ctrl)
-
 	existingID := uuid.New()
-	mockService.subscriptions[existingID] = &models.Subscription{
-		ID:          existingID,
-		ServiceName: "Test",
-		Price:       100,
-		UserID:      uuid.New(),
-		StartDate:   time.Now(),
-	}
+	mockService.subscriptions[existingID] = &models.Subscription{ID: existingID, ServiceName: "HBO Max", UserID: uuid.New(), StartDate: time.Now()}
+	router := gin.New()
+	NewAdminController(mockService).RegisterRoutes(router)
 
 	tests := []struct {
 		name           string
-		id             string
-		body           interface{}
+		body           string
 		wantStatusCode int
+		wantRenamed    int64
 	}{
-		{
-			name: "valid update",
-			id:   existingID.String(),
-			body: apiModels.UpdateSubscriptionRequest{
-				ServiceName: strPtr("Updated Name"),
-			},
-			wantStatusCode: http.StatusOK,
-		},
-		{
-			name:           "non-existing subscription",
-			id:             uuid.New().String(),
-			body:           apiModels.UpdateSubscriptionRequest{ServiceName: strPtr("Test")},
-			wantStatusCode: http.StatusNotFound,
-		},
-		{
-			name:           "invalid UUID",
-			id:             "not-a-uuid",
-			body:           apiModels.UpdateSubscriptionRequest{ServiceName: strPtr("Test")},
-			wantStatusCode: http.StatusBadRequest,
-		},
-		{
-			name:           "invalid JSON",
-			id:             existingID.String(),
-			body:           "not json",
-			wantStatusCode: http.StatusBadRequest,
-		},
+		{name: "rename", body: `{"from":"hbo max","to":"Max"}`, wantStatusCode: http.StatusOK, wantRenamed: 1},
+		{name: "same name", body: `{"from":"Max","to":" Max "}`, wantStatusCode: http.StatusBadRequest},
+		{name: "missing new name", body: `{"from":"Max"}`, wantStatusCode: http.StatusBadRequest},
+		{name: "invalid org unit ID", body: `{"from":"Max","to":"HBO Max","org_unit_id":"not-a-uuid"}`, wantStatusCode: http.StatusBadRequest},
+		{name: "invalid JSON", body: `{invalid}`, wantStatusCode: http.StatusBadRequest},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var body []byte
-			var err error
+			req := httptest.NewRequest(http.MethodPost, "/services/rename", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
 
-			switch v := tt.body.(type) {
-			case string:
-				body = []byte(v)
-			default:
-				body, err = json.Marshal(tt.body)
-				if err != nil {
-					t.Fatalf("failed to marshal request body: %v", err)
-				}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("status = %d, want %d, body: %s", w.Code, tt.wantStatusCode, w.Body.String())
+			}
+			if tt.wantStatusCode != http.StatusOK {
+				return
+			}
+			var rename models.ServiceRename
+			if err := json.Unmarshal(w.Body.Bytes(), &rename); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
 			}
+			if rename.Renamed != tt.wantRenamed || rename.FinishedAt == nil {
+				t.Errorf("rename = %+v, want %d renamed and finished", rename, tt.wantRenamed)
+			}
+		})
+	}
+}
 
-			req := httptest.NewRequest(http.MethodPut, "/subscriptions/"+tt.id, bytes.NewBuffer(body))
+const knownPlanID = "11111111-2222-3333-4444-555555555555"
+
+func TestPlanHandlers(t *testing.T) {
+	mockService := NewMockService()
+	router := gin.New()
+	NewAdminController(mockService).RegisterRoutes(router)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		wantStatusCode int
+	}{
+		{name: "create plan", method: http.MethodPost, path: "/plans", body: `{"service_name":"Yandex Plus","name":"Family","price":499}`, wantStatusCode: http.StatusCreated},
+		{name: "duplicate plan", method: http.MethodPost, path: "/plans", body: `{"service_name":"Yandex Plus","name":"Taken","price":499}`, wantStatusCode: http.StatusConflict},
+		{name: "plan without name", method: http.MethodPost, path: "/plans", body: `{"service_name":"Yandex Plus","price":499}`, wantStatusCode: http.StatusBadRequest},
+		{name: "update price", method: http.MethodPut, path: "/plans/" + knownPlanID, body: `{"price":549}`, wantStatusCode: http.StatusOK},
+		{name: "update without price", method: http.MethodPut, path: "/plans/" + knownPlanID, body: `{}`, wantStatusCode: http.StatusBadRequest},
+		{name: "update unknown plan", method: http.MethodPut, path: "/plans/" + uuid.NewString(), body: `{"price":549}`, wantStatusCode: http.StatusNotFound},
+		{name: "invalid plan ID", method: http.MethodPut, path: "/plans/not-a-uuid", body: `{"price":549}`, wantStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
 			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
 			router.ServeHTTP(w, req)
 
 			if w.Code != tt.wantStatusCode {
-				t.Errorf("UpdateSubscriptionByID() status = %d, want %d", w.Code, tt.wantStatusCode)
+				t.Errorf("%s %s status = %d, want %d", tt.method, tt.path, w.Code, tt.wantStatusCode)
 			}
 		})
 	}
 }
 
-func TestDeleteSubscriptionByIDHandler(t *testing.T) {
-	mockService := NewMockService()
-	ctrl := NewSubscriptionController(mockService)
-	router := setupRouter(ctrl)
+const knownOrgUnitID = "22222222-3333-4444-5555-666666666666"
 
-	existingID := uuid.New()
-	mockService.subscriptions[existingID] = &models.Subscription{
-		ID:          existingID,
-		ServiceName: "Test",
-		Price:       100,
-		UserID:      uuid.New(),
-		StartDate:   time.Now(),
-	}
+func TestOrgUnitHandlers(t *testing.T) {
+	mockService := NewMockService()
+	router := gin.New()
+	NewSubscriptionController(mockService).RegisterRoutes(router)
+	NewAdminController(mockService).RegisterRoutes(router.Group("/admin"))
 
 	tests := []struct {
 		name           string
-		id             string
+		method         string
+		path           string
+		body           string
 		wantStatusCode int
 	}{
-		{
-			name:           "existing subscription",
-			id:             existingID.String(),
-			wantStatusCode: http.StatusOK,
-		},
-		{
-			name:           "non-existing subscription",
-			id:             uuid.New().String(),
-			wantStatusCode: http.StatusNotFound,
-		},
-		{
-			name:           "invalid UUID",
-			id:             "not-a-uuid",
-			wantStatusCode: http.StatusBadRequest,
-		},
+		{name: "create root unit", method: http.MethodPost, path: "/admin/org-units", body: `{"name":"Company"}`, wantStatusCode: http.StatusCreated},
+		{name: "create child unit", method: http.MethodPost, path: "/admin/org-units", body: `{"name":"Marketing","parent_id":"` + knownOrgUnitID + `"}`, wantStatusCode: http.StatusCreated},
+		{name: "duplicate unit", method: http.MethodPost, path: "/admin/org-units", body: `{"name":"Taken"}`, wantStatusCode: http.StatusConflict},
+		{name: "unit without name", method: http.MethodPost, path: "/admin/org-units", body: `{}`, wantStatusCode: http.StatusBadRequest},
+		{name: "invalid parent ID", method: http.MethodPost, path: "/admin/org-units", body: `{"name":"Sales","parent_id":"nope"}`, wantStatusCode: http.StatusBadRequest},
+		{name: "list units", method: http.MethodGet, path: "/org-units", wantStatusCode: http.StatusOK},
+		{name: "unit total", method: http.MethodGet, path: "/org-units/" + knownOrgUnitID + "/total?start_date=01-2024&end_date=12-2024", wantStatusCode: http.StatusOK},
+		{name: "unit total without period", method: http.MethodGet, path: "/org-units/" + knownOrgUnitID + "/total", wantStatusCode: http.StatusBadRequest},
+		{name: "total of unknown unit", method: http.MethodGet, path: "/org-units/" + uuid.NewString() + "/total?start_date=01-2024&end_date=12-2024", wantStatusCode: http.StatusNotFound},
+		{name: "total with invalid unit ID", method: http.MethodGet, path: "/org-units/not-a-uuid/total?start_date=01-2024&end_date=12-2024", wantStatusCode: http.StatusBadRequest},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodDelete, "/subscriptions/"+tt.id, nil)
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
 			router.ServeHTTP(w, req)
 
 			if w.Code != tt.wantStatusCode {
-				t.Errorf("DeleteSubscriptionByID() status = %d, want %d", w.Code, tt.wantStatusCode)
+				t.Errorf("status = %d, want %d, body: %s", w.Code, tt.wantStatusCode, w.Body.String())
 			}
 		})
 	}
 }
 
-func TestListSubscriptionsHandler(t *testing.T) {
-	mockService := NewMockService()
-	ctrl := NewSubscriptionController(mockService)
-	router := setupRouter(ctrl)
+func TestOrgUnitMetricsHandler(t *testing.T) {
+	metrics := NewMetricsController(NewMockService(), map[string]string{"integrator-a": knownOrgUnitID, "integrator-b": uuid.NewString()})
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set(middlewares.KeyIDContextKey, c.GetHeader(middlewares.HeaderKeyID)) }, metrics.CountCalls())
+	router.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/metrics/org-units/:id", metrics.OrgUnitMetrics)
+
+	for _, keyID := range []string{"integrator-a", "integrator-a", "integrator-b"} {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set(middlewares.HeaderKeyID, keyID)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
 
-	// Pre-create subscriptions
-	for i := 0; i < 3; i++ {
-		id := uuid.New()
-		mockService.subscriptions[id] = &models.Subscription{
-			ID:          id,
-			ServiceName: "Test",
-			Price:       100,
-			UserID:      uuid.New(),
-			StartDate:   time.Now(),
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics/org-units/"+knownOrgUnitID, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Errorf("Content-Type = %q, want OpenMetrics text", ct)
+	}
+	body := w.Body.String()
+	for _, line := range []string{
+		`subscription_aggregator_subscriptions{org_unit="` + knownOrgUnitID + `"} 3`,
+		`subscription_aggregator_active_subscriptions{org_unit="` + knownOrgUnitID + `"} 2`,
+		`subscription_aggregator_monthly_spend{org_unit="` + knownOrgUnitID + `",month="10-2026"} 1000`,
+		`subscription_aggregator_api_requests_total{org_unit="` + knownOrgUnitID + `",key_id="integrator-a"} 2`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics don't contain %q:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "integrator-b") {
+		t.Errorf("metrics contain calls of another unit's key:\n%s", body)
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("metrics don't end with # EOF:\n%s", body)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics/org-units/"+uuid.NewString(), nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown unit status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+type fixedSizes struct{}
+
+func (fixedSizes) Sizes(ctx context.Context) (*health.SizeSnapshot, error) {
+	return &health.SizeSnapshot{CollectedAt: time.Unix(1700000000, 0), DatabaseBytes: 300 << 20, Tables: []health.TableSize{
+		{Name: "subscriptions", TableBytes: 200 << 20, IndexBytes: 50 << 20, LiveTuples: 700, DeadTuples: 300},
+	}}, nil
+}
+
+func TestDatabaseMetricsHandler(t *testing.T) {
+	growth := health.NewGrowthCollector(fixedSizes{}, health.GrowthLimits{DeadTupleRatio: 0.2, DatabaseBytes: 256 << 20})
+	router := gin.New()
+	router.GET("/metrics/database", NewHealthController(nil, growth).DatabaseMetrics)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics/database", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status before collection = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+
+	if err := growth.Collect(context.Background()); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics/database", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/openmetrics-text") {
+		t.Errorf("Content-Type = %q, want OpenMetrics text", ct)
+	}
+	body := w.Body.String()
+	for _, line := range []string{
+		`subscription_aggregator_database_size_bytes{} 314572800`,
+		`subscription_aggregator_table_size_bytes{table="subscriptions"} 209715200`,
+		`subscription_aggregator_index_size_bytes{table="subscriptions"} 52428800`,
+		`subscription_aggregator_dead_tuple_ratio{table="subscriptions"} 0.3000`,
+		`subscription_aggregator_database_limit_crossed{kind="size",table=""} 1`,
+		`subscription_aggregator_database_limit_crossed{kind="bloat",table="subscriptions"} 1`,
+		`subscription_aggregator_database_stats_collected_seconds{} 1700000000`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics don't contain %q:\n%s", line, body)
 		}
 	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("metrics don't end with # EOF:\n%s", body)
+	}
+
+	router = gin.New()
+	router.GET("/metrics/database", NewHealthController(nil, nil).DatabaseMetrics)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics/database", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status with stats disabled = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+const knownBulkDeleteID = "6f1c2d3e-4a5b-4c6d-8e7f-9a0b1c2d3e4f"
+
+func TestBulkDeleteHandlers(t *testing.T) {
+	router := setupRouter(NewSubscriptionController(NewMockService()))
 
 	tests := []struct {
 		name           string
-		query          string
+		method         string
+		path           string
+		body           string
 		wantStatusCode int
 	}{
-		{
-			name:           "list all",
-			query:          "",
-			wantStatusCode: http.StatusOK,
-		},
-		{
-			name:           "with user_id filter",
-			query:          "?user_id=550e8400-e29b-41d4-a716-446655440000",
-			wantStatusCode: http.StatusOK,
-		},
-		{
-			name:           "with service_name filter",
-			query:          "?service_name=Netflix",
-			wantStatusCode: http.StatusOK,
-		},
+		{name: "start", method: http.MethodPost, path: "/subscriptions/bulk-delete", body: `{"service_name":"Netflix","end_date":"12-2024"}`, wantStatusCode: http.StatusAccepted},
+		{name: "start without criteria", method: http.MethodPost, path: "/subscriptions/bulk-delete", body: `{}`, wantStatusCode: http.StatusBadRequest},
+		{name: "start with invalid JSON", method: http.MethodPost, path: "/subscriptions/bulk-delete", body: `{invalid}`, wantStatusCode: http.StatusBadRequest},
+		{name: "progress", method: http.MethodGet, path: "/subscriptions/bulk-delete/" + knownBulkDeleteID, wantStatusCode: http.StatusOK},
+		{name: "unknown job", method: http.MethodGet, path: "/subscriptions/bulk-delete/" + uuid.NewString(), wantStatusCode: http.StatusNotFound},
+		{name: "invalid job ID", method: http.MethodGet, path: "/subscriptions/bulk-delete/nope", wantStatusCode: http.StatusBadRequest},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodGet, "/subscriptions"+tt.query, nil)
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
 			router.ServeHTTP(w, req)
 
 			if w.Code != tt.wantStatusCode {
-				t.Errorf("ListSubscriptions() status = %d, want %d", w.Code, tt.wantStatusCode)
+				t.Errorf("status = %d, want %d, body: %s", w.Code, tt.wantStatusCode, w.Body.String())
+			}
+			if w.Code == http.StatusAccepted {
+				var job models.BulkDeleteJob
+				if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+					t.Fatalf("failed to decode job: %v", err)
+				}
+				if loc := w.Header().Get("Location"); loc != "/subscriptions/bulk-delete/"+job.ID.String() {
+					t.Errorf("Location = %q, want the job's progress URL", loc)
+				}
 			}
 		})
 	}
 }
 
-func TestTotalSubscriptionsCostHandler(t *testing.T) {
-	mockService := NewMockService()
-	ctrl := NewSubscriptionController(mockService)
-	router := setupRouter(ctrl)
+func TestImportSubscriptionsHandler(t *testing.T) {
+	router := gin.New()
+	NewAdminController(NewMockService()).RegisterRoutes(router)
 
+	const historical = `{"subscriptions":[{"service_name":"Netflix","price":299,"user_id":"550e8400-e29b-41d4-a716-446655440000","start_date":"01-1985"}]}`
 	tests := []struct {
 		name           string
 		query          string
+		body           string
 		wantStatusCode int
 	}{
-		{
-			name:           "valid request",
-			query:          "?start_date=01-2024&end_date=12-2024",
-			wantStatusCode: http.StatusOK,
-		},
-		{
-			name:           "with user_id",
-			query:          "?start_date=01-2024&end_date=12-2024&user_id=550e8400-e29b-41d4-a716-446655440000",
-			wantStatusCode: http.StatusOK,
-		},
-		{
-			name:           "missing start_date",
-			query:          "?end_date=12-2024",
-			wantStatusCode: http.StatusBadRequest,
-		},
-		{
-			name:           "missing end_date",
-			query:          "?start_date=01-2024",
-			wantStatusCode: http.StatusBadRequest,
-		},
+		{name: "relaxed", query: "?allow=historical_start,long_duration", body: historical, wantStatusCode: http.StatusCreated},
+		{name: "strict", body: historical, wantStatusCode: http.StatusBadRequest},
+		{name: "unknown validation", query: "?allow=price", body: historical, wantStatusCode: http.StatusBadRequest},
+		{name: "empty import", body: `{"subscriptions":[]}`, wantStatusCode: http.StatusBadRequest},
+		{name: "invalid JSON", body: `{invalid}`, wantStatusCode: http.StatusBadRequest},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodGet, "/subscriptions/total"+tt.query, nil)
+			req := httptest.NewRequest(http.MethodPost, "/subscriptions/import"+tt.query, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
 			w := httptest.NewRecorder()
 
 			router.ServeHTTP(w, req)
 
 			if w.Code != tt.wantStatusCode {
-				t.Errorf("TotalSubscriptionsCost() status = %d, want %d", w.Code, tt.wantStatusCode)
+				t.Errorf("ImportSubscriptions() status = %d, want %d", w.Code, tt.wantStatusCode)
 			}
 		})
 	}
@@ -468,7 +2299,7 @@ func TestResponseFormat(t *testing.T) {
 	// Create subscription and verify response format
 	body, _ := json.Marshal(apiModels.CreateSubscriptionRequest{
 		ServiceName: "Netflix",
-		Price:       299,
+		Price:       intPtr(299),
 		UserID:      "550e8400-e29b-41d4-a716-446655440000",
 		StartDate:   "01-2024",
 	})
@@ -483,7 +2314,7 @@ func TestResponseFormat(t *testing.T) {
 		t.Fatalf("CreateSubscription() status = %d, want %d", w.Code, http.StatusCreated)
 	}
 
-	var response models.Subscription
+	var response apiModels.SubscriptionResponse
 	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
 		t.Fatalf("failed to unmarshal response: %v", err)
 	}
@@ -497,8 +2328,40 @@ func TestResponseFormat(t *testing.T) {
 	if response.Price != 299 {
 		t.Errorf("Price = %d, want %d", response.Price, 299)
 	}
+	if _, err := time.Parse(time.RFC3339, response.StartDate); err != nil { // v1 default
+		t.Errorf("StartDate = %q is not an RFC3339 timestamp: %v", response.StartDate, err)
+	}
+	if response.Status == "" {
+		t.Error("status should be present")
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &raw); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	for _, field := range []string{"created_at", "updated_at"} {
+		value, ok := raw[field].(string)
+		if !ok {
+			t.Errorf("%s should be present as a string, got %v", field, raw[field])
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			t.Errorf("%s = %q is not an RFC3339 timestamp: %v", field, value, err)
+		}
+	}
+	if _, ok := raw["deleted_at"]; ok {
+		t.Error("deleted_at should not be exposed")
+	}
 }
 
 func strPtr(s string) *string {
 	return &s
 }
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
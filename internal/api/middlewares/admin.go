@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+)
+
+// AdminAuth lets through requests carrying the admin token, either as "Authorization: Bearer <token>"
+// or as basic auth password with any user name, so admin pages can be opened in a browser
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		presented := ""
+		if bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			presented = strings.TrimSpace(bearer)
+		} else if _, password, ok := c.Request.BasicAuth(); ok {
+			presented = password
+		}
+
+		if token == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			slog.Warn("admin request rejected", "ip", c.ClientIP(), "path", c.Request.URL.Path, "token_presented", presented != "")
+			c.Header("WWW-Authenticate", `Basic realm="admin"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, apiModels.ErrorResponse{Error: "Admin authorization required", Code: apiModels.CodeUnauthorized})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,49 @@
+package middlewares
+
+import (
+	"testing"
+
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAdminAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newRouter := func(token string) *gin.Engine {
+		r := gin.New()
+		r.GET("/admin", AdminAuth(token), func(c *gin.Context) { c.Status(http.StatusOK) })
+		return r
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		auth   func(r *http.Request)
+		status int
+	}{
+		{"bearer", "s3cret", func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cret") }, http.StatusOK},
+		{"basic", "s3cret", func(r *http.Request) { r.SetBasicAuth("admin", "s3cret") }, http.StatusOK},
+		{"wrong token", "s3cret", func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, http.StatusUnauthorized},
+		{"no token", "s3cret", func(r *http.Request) {}, http.StatusUnauthorized},
+		{"admin token not configured", "", func(r *http.Request) { r.Header.Set("Authorization", "Bearer ") }, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			tt.auth(req)
+			w := httptest.NewRecorder()
+			newRouter(tt.token).ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate, browsers won't prompt for credentials")
+			}
+		})
+	}
+}
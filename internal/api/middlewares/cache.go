@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CacheRule sets caching headers for requests whose path starts with Prefix
+type CacheRule struct {
+	Prefix string
+	Value  string        // Cache-Control
+	MaxAge time.Duration // Expires is now+MaxAge, or already expired when zero
+}
+
+// NoStore forbids caching, for user data
+func NoStore(prefix string) CacheRule {
+	return CacheRule{Prefix: prefix, Value: "no-store"}
+}
+
+// Revalidate lets caches keep the response, but only use it after checking with the server
+func Revalidate(prefix string) CacheRule {
+	return CacheRule{Prefix: prefix, Value: "no-cache"}
+}
+
+// Private lets only the client's own cache keep the response, checking with the server before each use.
+// For content behind authentication, which shared caches must not serve to anyone else.
+func Private(prefix string) CacheRule {
+	return CacheRule{Prefix: prefix, Value: "private, no-cache"}
+}
+
+// Public allows any cache to serve the response for maxAge
+func Public(prefix string, maxAge time.Duration) CacheRule {
+	return CacheRule{Prefix: prefix, Value: fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())), MaxAge: maxAge}
+}
+
+// Immutable is for content that never changes under the same URL
+func Immutable(prefix string) CacheRule {
+	const year = 365 * 24 * time.Hour
+	return CacheRule{Prefix: prefix, Value: fmt.Sprintf("public, max-age=%d, immutable", int(year.Seconds())), MaxAge: year}
+}
+
+// CachePolicy sets Cache-Control and Expires by the first rule matching request path, paths without a rule are left alone.
+// Error responses are never cached, whatever the rule says.
+func CachePolicy(rules ...CacheRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, rule := range rules {
+			if !strings.HasPrefix(c.Request.URL.Path, rule.Prefix) {
+				continue
+			}
+			if rule.MaxAge > 0 && (c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead) {
+				setCacheHeaders(c.Writer.Header(), rule.Value, time.Now().Add(rule.MaxAge).UTC().Format(http.TimeFormat))
+			} else if rule.MaxAge > 0 {
+				setCacheHeaders(c.Writer.Header(), "no-store", "0")
+			} else {
+				setCacheHeaders(c.Writer.Header(), rule.Value, "0")
+			}
+			c.Writer = &noErrorCacheWriter{ResponseWriter: c.Writer}
+			break
+		}
+		c.Next()
+	}
+}
+
+func setCacheHeaders(h http.Header, cacheControl, expires string) {
+	h.Set("Cache-Control", cacheControl)
+	h.Set("Expires", expires)
+}
+
+// noErrorCacheWriter replaces caching headers with no-store once an error status is written
+type noErrorCacheWriter struct {
+	gin.ResponseWriter
+}
+
+func (w *noErrorCacheWriter) WriteHeader(code int) {
+	if code >= http.StatusBadRequest {
+		setCacheHeaders(w.Header(), "no-store", "0")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"testing"
+
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCachePolicy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(CachePolicy(
+		Immutable("/assets/"),
+		Private("/admin/"),
+		Public("/api/suggest", 30*time.Second),
+		NoStore("/api"),
+	))
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	r.GET("/assets/*file", ok)
+	r.GET("/admin/*file", ok)
+	r.GET("/api/suggest", func(c *gin.Context) {
+		if c.Query("fail") != "" {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"})
+			return
+		}
+		ok(c)
+	})
+	r.POST("/api/suggest", ok)
+	r.GET("/api/items", ok)
+	r.GET("/other", ok)
+
+	tests := []struct {
+		method, path     string
+		wantCacheControl string
+		wantExpires      bool // Future date rather than "0"
+	}{
+		{http.MethodGet, "/assets/app.js", "public, max-age=31536000, immutable", true},
+		{http.MethodGet, "/admin/index.html", "private, no-cache", false},
+		{http.MethodGet, "/api/suggest?prefix=ne", "public, max-age=30", true},
+		{http.MethodGet, "/api/suggest?fail=1", "no-store", false}, // Errors aren't cached
+		{http.MethodPost, "/api/suggest", "no-store", false},       // Only safe methods are cached
+		{http.MethodGet, "/api/items", "no-store", false},
+		{http.MethodGet, "/other", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if got := w.Header().Get("Cache-Control"); got != tt.wantCacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCacheControl)
+			}
+			expires := w.Header().Get("Expires")
+			if tt.wantCacheControl == "" {
+				if expires != "" {
+					t.Errorf("Expires = %q, want none", expires)
+				}
+				return
+			}
+			at, err := http.ParseTime(expires)
+			if tt.wantExpires && (err != nil || !at.After(time.Now())) {
+				t.Errorf("Expires = %q, want future date", expires)
+			}
+			if !tt.wantExpires && expires != "0" {
+				t.Errorf("Expires = %q, want 0", expires)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+)
+
+// ConcurrencyLimit caps the number of in-flight requests per client, so a single integrator
+// can't occupy every connection. Clients are identified by the HMAC key when present, otherwise by IP.
+// Requests over the limit are rejected right away instead of queueing.
+func ConcurrencyLimit(perKey int) gin.HandlerFunc {
+	var mu sync.Mutex
+	inFlight := make(map[string]int)
+
+	return func(c *gin.Context) {
+		key := "ip:" + c.ClientIP()
+		if keyID := c.GetString(KeyIDContextKey); keyID != "" {
+			key = "key:" + keyID
+		}
+
+		mu.Lock()
+		if inFlight[key] >= perKey {
+			mu.Unlock()
+			slog.Warn("concurrent request limit exceeded", "client", key, "limit", perKey)
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, apiModels.ErrorResponse{Error: "Too many concurrent requests", Code: apiModels.CodeTooManyRequests})
+			return
+		}
+		inFlight[key]++
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			if inFlight[key]--; inFlight[key] == 0 {
+				delete(inFlight, key) // Keep the map bounded by the number of active clients
+			}
+			mu.Unlock()
+		}()
+
+		c.Next()
+	}
+}
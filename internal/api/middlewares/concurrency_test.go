@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"testing"
+
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConcurrencyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	entered := make(chan struct{}, 4)
+	release := make(chan struct{})
+	r := gin.New()
+	r.Use(func(c *gin.Context) { // Stand-in for HMACAuth
+		if keyID := c.GetHeader(HeaderKeyID); keyID != "" {
+			c.Set(KeyIDContextKey, keyID)
+		}
+	})
+	r.Use(ConcurrencyLimit(2))
+	r.GET("/slow", func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	request := func(keyID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+		req.Header.Set(HeaderKeyID, keyID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 3)
+	for _, keyID := range []string{"batch", "batch", "other"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- request(keyID).Code
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		<-entered
+	}
+
+	if w := request("batch"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("request over limit status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+
+	close(release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("request within limit status = %d, want %d", code, http.StatusOK)
+		}
+	}
+
+	if w := request("batch"); w.Code != http.StatusOK {
+		t.Errorf("request after release status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
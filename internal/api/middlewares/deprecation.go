@@ -0,0 +1,106 @@
+package middlewares
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeprecationUsage is how often a deprecated feature was used since start, by client
+type DeprecationUsage struct {
+	Feature string           `json:"feature"`
+	Sunset  *time.Time       `json:"sunset,omitempty"`
+	Calls   int64            `json:"calls"`
+	Clients map[string]int64 `json:"clients"` // "key:<HMAC key ID>" or "ip:<address>"
+}
+
+// DeprecationTracker marks responses of deprecated routes and flags with Deprecation and Sunset headers (RFC 8594)
+// and counts who still calls them. Features are keyed by "METHOD /route/:param" or "/route/:param" relative to the base path
+// of the API version, lower case, optionally followed by "?param" to deprecate only requests carrying that query parameter.
+// A zero sunset means the removal date isn't set yet. Calls to every version are counted together.
+type DeprecationTracker struct {
+	features map[string]time.Time
+
+	mu    sync.Mutex
+	usage map[string]map[string]int64
+}
+
+func NewDeprecationTracker(features map[string]time.Time) *DeprecationTracker {
+	return &DeprecationTracker{features: features, usage: make(map[string]map[string]int64)}
+}
+
+// Middleware tracks the API version under basePath. Must run after HMACAuth, so signed clients are counted by key
+func (d *DeprecationTracker) Middleware(basePath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		matched := d.match(c, basePath)
+		if len(matched) == 0 {
+			c.Next()
+			return
+		}
+
+		client := "ip:" + c.ClientIP()
+		if keyID := c.GetString(KeyIDContextKey); keyID != "" {
+			client = "key:" + keyID
+		}
+
+		var sunset time.Time
+		d.mu.Lock()
+		for _, feature := range matched {
+			if d.usage[feature] == nil {
+				d.usage[feature] = make(map[string]int64)
+			}
+			d.usage[feature][client]++
+			if s := d.features[feature]; !s.IsZero() && (sunset.IsZero() || s.Before(sunset)) {
+				sunset = s
+			}
+		}
+		d.mu.Unlock()
+
+		c.Header("Deprecation", "true")
+		if !sunset.IsZero() {
+			c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		c.Next()
+	}
+}
+
+func (d *DeprecationTracker) match(c *gin.Context, basePath string) []string {
+	route := strings.ToLower(strings.TrimPrefix(c.FullPath(), basePath))
+	var matched []string
+	for _, key := range []string{strings.ToLower(c.Request.Method) + " " + route, route} {
+		if _, ok := d.features[key]; ok {
+			matched = append(matched, key)
+		}
+		for param := range c.Request.URL.Query() {
+			if _, ok := d.features[key+"?"+strings.ToLower(param)]; ok {
+				matched = append(matched, key+"?"+strings.ToLower(param))
+			}
+		}
+	}
+	return matched
+}
+
+// Usage lists every deprecated feature, unused ones with zero calls, sorted by feature
+func (d *DeprecationTracker) Usage() []DeprecationUsage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := make([]DeprecationUsage, 0, len(d.features))
+	for feature, sunset := range d.features {
+		u := DeprecationUsage{Feature: feature, Clients: make(map[string]int64)}
+		if !sunset.IsZero() {
+			u.Sunset = &sunset
+		}
+		for client, calls := range d.usage[feature] {
+			u.Clients[client] = calls
+			u.Calls += calls
+		}
+		result = append(result, u)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Feature < result[j].Feature })
+	return result
+}
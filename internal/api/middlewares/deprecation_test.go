@@ -0,0 +1,85 @@
+package middlewares
+
+import (
+	"testing"
+
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeprecation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	sunset := time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)
+	tracker := NewDeprecationTracker(map[string]time.Time{
+		"get /subscriptions?user_id": sunset,
+		"/subscriptions/total":       {},
+		"delete /subscriptions/:id":  {},
+	})
+	r := gin.New()
+	auth := func(c *gin.Context) {
+		if keyID := c.GetHeader("X-Key-Id"); keyID != "" {
+			c.Set(KeyIDContextKey, keyID) // As HMACAuth does
+		}
+	}
+	api := r.Group("/api/v1", auth, tracker.Middleware("/api/v1"))
+	v2 := r.Group("/api/v2", auth, tracker.Middleware("/api/v2"))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	api.GET("/subscriptions", ok)
+	api.GET("/subscriptions/total", ok)
+	v2.GET("/subscriptions/total", ok)
+
+	tests := []struct {
+		path, keyID string
+		wantSunset  string
+	}{
+		{"/api/v1/subscriptions?user_id=1", "partner", "Thu, 31 Dec 2026 00:00:00 GMT"},
+		{"/api/v1/subscriptions?user_id=2", "partner", "Thu, 31 Dec 2026 00:00:00 GMT"},
+		{"/api/v1/subscriptions?USER_ID=3", "", "Thu, 31 Dec 2026 00:00:00 GMT"}, // Query params are matched case-insensitively
+		{"/api/v1/subscriptions/total", "", ""},                                  // Deprecated without removal date
+		{"/api/v2/subscriptions/total", "", ""},                                  // In every version
+		{"/api/v1/subscriptions?limit=10", "", "-"},                              // Not deprecated
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("X-Key-Id", tt.keyID)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if tt.wantSunset == "-" {
+				if got := w.Header().Get("Deprecation"); got != "" {
+					t.Errorf("Deprecation = %q on a live feature", got)
+				}
+				return
+			}
+			if got := w.Header().Get("Deprecation"); got != "true" {
+				t.Errorf("Deprecation = %q, want true", got)
+			}
+			if got := w.Header().Get("Sunset"); got != tt.wantSunset {
+				t.Errorf("Sunset = %q, want %q", got, tt.wantSunset)
+			}
+		})
+	}
+
+	usage := tracker.Usage()
+	if len(usage) != 3 {
+		t.Fatalf("usage has %d features, want 3 including unused one", len(usage))
+	}
+	if u := usage[0]; u.Feature != "/subscriptions/total" || u.Calls != 2 || u.Sunset != nil {
+		t.Errorf("usage[0] = %+v, want 2 calls of /subscriptions/total in both versions without sunset", u)
+	}
+	if u := usage[1]; u.Feature != "delete /subscriptions/:id" || u.Calls != 0 {
+		t.Errorf("usage[1] = %+v, want unused delete /subscriptions/:id", u)
+	}
+	u := usage[2]
+	if u.Feature != "get /subscriptions?user_id" || u.Calls != 3 || u.Sunset == nil || !u.Sunset.Equal(sunset) {
+		t.Errorf("usage[2] = %+v, want 3 calls of get /subscriptions?user_id until %s", u, sunset)
+	}
+	if u.Clients["key:partner"] != 2 || u.Clients["ip:192.0.2.1"] != 1 {
+		t.Errorf("clients = %v, want 2 calls by key:partner and 1 by ip:192.0.2.1", u.Clients)
+	}
+}
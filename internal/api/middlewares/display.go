@@ -0,0 +1,20 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"subscription-aggregator-service/internal/utils/display"
+)
+
+// DisplayPrefs negotiates X-Locale and X-Display-Currency into the request context for formatted fields of responses.
+// Unsupported values fall back to the defaults, Content-Language tells the client which locale it got.
+func DisplayPrefs(n *display.Negotiator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		prefs := n.Negotiate(c.GetHeader(display.LocaleHeader), c.GetHeader(display.CurrencyHeader))
+		c.Request = c.Request.WithContext(display.WithContext(c.Request.Context(), prefs))
+		c.Header("Content-Language", prefs.Locale)
+		c.Writer.Header().Add("Vary", display.LocaleHeader+", "+display.CurrencyHeader)
+
+		c.Next()
+	}
+}
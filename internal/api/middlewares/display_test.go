@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"testing"
+
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+
+	"subscription-aggregator-service/internal/utils/display"
+)
+
+func TestDisplayPrefs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var got display.Prefs
+	r := gin.New()
+	r.Use(DisplayPrefs(display.NewNegotiator("ru", "RUB", map[string]float64{"USD": 80})))
+	r.GET("/total", func(c *gin.Context) {
+		got = display.FromContext(c.Request.Context())
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		locale   string
+		currency string
+		want     display.Prefs
+	}{
+		{name: "no headers", want: display.Prefs{Locale: display.LocaleRU, Currency: "RUB", Rate: 1}},
+		{name: "both headers", locale: "en-US", currency: "USD", want: display.Prefs{Locale: display.LocaleEN, Currency: "USD", Rate: 80}},
+		{name: "unknown currency ignored", locale: "en", currency: "XYZ", want: display.Prefs{Locale: display.LocaleEN, Currency: "RUB", Rate: 1}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/total", nil)
+		if tt.locale != "" {
+			req.Header.Set(display.LocaleHeader, tt.locale)
+		}
+		if tt.currency != "" {
+			req.Header.Set(display.CurrencyHeader, tt.currency)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200", tt.name, w.Code)
+		}
+		if got != tt.want {
+			t.Errorf("%s: prefs = %+v, want %+v", tt.name, got, tt.want)
+		}
+		if lang := w.Header().Get("Content-Language"); lang != tt.want.Locale {
+			t.Errorf("%s: Content-Language = %q, want %q", tt.name, lang, tt.want.Locale)
+		}
+		if w.Header().Get("Vary") == "" {
+			t.Errorf("%s: Vary is missing", tt.name)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/logger"
+)
+
+const (
+	HeaderKeyID     = "X-Key-ID"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderSignature = "X-Signature"
+
+	KeyIDContextKey = "auth_key_id" // Set on gin.Context for authenticated requests
+)
+
+// HMACAuth verifies that requests are signed by one of the known clients.
+// Signature is hex(HMAC-SHA256(secret, StringToSign(...))), timestamp is unix seconds.
+// Requests outside maxSkew or repeating an already seen signature are rejected.
+// Key IDs are case-insensitive: config keys come lower-cased, so the header is lower-cased before lookup.
+func HMACAuth(keys map[string]string, maxSkew time.Duration) gin.HandlerFunc {
+	seen := newReplayCache(maxSkew)
+
+	return func(c *gin.Context) {
+		keyID := strings.ToLower(strings.TrimSpace(c.GetHeader(HeaderKeyID)))
+		timestamp := strings.TrimSpace(c.GetHeader(HeaderTimestamp))
+		signature := strings.TrimSpace(c.GetHeader(HeaderSignature))
+		if keyID == "" || timestamp == "" || signature == "" {
+			rejectUnauthorized(c, keyID, "missing signature headers")
+			return
+		}
+
+		secret, ok := keys[keyID]
+		if !ok {
+			rejectUnauthorized(c, keyID, "unknown key")
+			return
+		}
+
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			rejectUnauthorized(c, keyID, "malformed timestamp")
+			return
+		}
+		if skew := time.Since(time.Unix(ts, 0)); skew > maxSkew || skew < -maxSkew {
+			rejectUnauthorized(c, keyID, "timestamp outside allowed window")
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			body, err = io.ReadAll(c.Request.Body)
+			if err != nil {
+				rejectUnauthorized(c, keyID, "unreadable body")
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		expected := Sign(secret, c.Request.Method, c.Request.URL.RequestURI(), timestamp, body)
+		if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+			rejectUnauthorized(c, keyID, "signature mismatch")
+			return
+		}
+
+		if !seen.add(keyID+":"+signature, time.Now()) {
+			rejectUnauthorized(c, keyID, "replayed request")
+			return
+		}
+
+		c.Set(KeyIDContextKey, keyID)
+		c.Request = c.Request.WithContext(logger.With(c.Request.Context(), "key_id", keyID))
+		c.Next()
+	}
+}
+
+// StringToSign builds the canonical representation of a request that clients must sign
+func StringToSign(method, requestURI, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{strings.ToUpper(method), requestURI, timestamp, hex.EncodeToString(bodyHash[:])}, "\n")
+}
+
+// Sign returns the hex-encoded signature for given request parts
+func Sign(secret, method, requestURI, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(StringToSign(method, requestURI, timestamp, body)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func rejectUnauthorized(c *gin.Context, keyID, reason string) {
+	slog.Warn("request signature rejected", "key_id", keyID, "reason", reason, "ip", c.ClientIP(), "path", c.Request.URL.Path)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, apiModels.ErrorResponse{Error: "Invalid request signature", Code: apiModels.CodeInvalidSignature})
+}
+
+// replayCache remembers signatures for as long as their timestamps are acceptable
+type replayCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]time.Time
+	sweptAt time.Time
+}
+
+func newReplayCache(window time.Duration) *replayCache {
+	return &replayCache{ttl: 2 * window, entries: make(map[string]time.Time)} // Timestamps are accepted on both sides of now
+}
+
+// add returns false if the key was already seen within the TTL
+func (rc *replayCache) add(key string, now time.Time) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if now.Sub(rc.sweptAt) > rc.ttl {
+		for k, t := range rc.entries {
+			if now.Sub(t) > rc.ttl {
+				delete(rc.entries, k)
+			}
+		}
+		rc.sweptAt = now
+	}
+
+	if t, ok := rc.entries[key]; ok && now.Sub(t) <= rc.ttl {
+		return false
+	}
+	rc.entries[key] = now
+	return true
+}
@@ -0,0 +1,113 @@
+package middlewares
+
+import (
+	"testing"
+
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupSignedRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(HMACAuth(map[string]string{"partner": "secret"}, time.Minute))
+	r.POST("/subscriptions", func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString(KeyIDContextKey))
+	})
+	return r
+}
+
+func signedRequest(keyID, secret string, ts time.Time, body string) *http.Request {
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/subscriptions?x=1", bytes.NewBufferString(body))
+	req.Header.Set(HeaderKeyID, keyID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(secret, http.MethodPost, "/subscriptions?x=1", timestamp, []byte(body)))
+	return req
+}
+
+func TestHMACAuth(t *testing.T) {
+	tests := []struct {
+		name           string
+		req            func() *http.Request
+		wantStatusCode int
+	}{
+		{
+			name:           "valid signature",
+			req:            func() *http.Request { return signedRequest("partner", "secret", time.Now(), `{"a":1}`) },
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "key ID in other case",
+			req:            func() *http.Request { return signedRequest("Partner", "secret", time.Now(), `{"a":7}`) },
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name:           "wrong secret",
+			req:            func() *http.Request { return signedRequest("partner", "wrong", time.Now(), `{"a":2}`) },
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "unknown key",
+			req:            func() *http.Request { return signedRequest("stranger", "secret", time.Now(), `{"a":3}`) },
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:           "stale timestamp",
+			req:            func() *http.Request { return signedRequest("partner", "secret", time.Now().Add(-time.Hour), `{"a":4}`) },
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "tampered body",
+			req: func() *http.Request {
+				req := signedRequest("partner", "secret", time.Now(), `{"a":5}`)
+				req.Body = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"a":6}`)).Body
+				return req
+			},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "missing headers",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/subscriptions", bytes.NewBufferString(`{}`))
+			},
+			wantStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	router := setupSignedRouter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, tt.req())
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("HMACAuth() status = %d, want %d", w.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestHMACAuth_Replay(t *testing.T) {
+	router := setupSignedRouter()
+	now := time.Now()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, signedRequest("partner", "secret", now, `{}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("first request status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w.Body.String() != "partner" {
+		t.Errorf("key id in context = %q, want %q", w.Body.String(), "partner")
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, signedRequest("partner", "secret", now, `{}`))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("replayed request status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
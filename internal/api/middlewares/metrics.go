@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+)
+
+// CallCounter counts API requests by HMAC key since start. Unsigned requests can't be attributed to a client and aren't counted.
+type CallCounter struct {
+	mu    sync.Mutex
+	calls map[string]int64
+}
+
+func NewCallCounter() *CallCounter {
+	return &CallCounter{calls: make(map[string]int64)}
+}
+
+// Middleware must run after HMACAuth, so requests are counted by key
+func (cc *CallCounter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if keyID := c.GetString(KeyIDContextKey); keyID != "" {
+			cc.mu.Lock()
+			cc.calls[keyID]++
+			cc.mu.Unlock()
+		}
+		c.Next()
+	}
+}
+
+// Calls returns the number of requests signed with keyID
+func (cc *CallCounter) Calls(keyID string) int64 {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.calls[keyID]
+}
+
+// MetricsAuth lets through requests carrying "Authorization: Bearer <token>" of the org unit in the :id path parameter,
+// so every unit can scrape only its own metrics. Units without a token are rejected the same way as wrong tokens.
+func MetricsAuth(tokens map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := ""
+		if id, err := uuid.Parse(c.Param("id")); err == nil {
+			token = tokens[id.String()]
+		}
+		presented := ""
+		if bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			presented = strings.TrimSpace(bearer)
+		}
+
+		if token == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			slog.Warn("metrics request rejected", "ip", c.ClientIP(), "path", c.Request.URL.Path, "token_presented", presented != "")
+			c.Header("WWW-Authenticate", `Bearer realm="metrics"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, apiModels.ErrorResponse{Error: "Metrics authorization required", Code: apiModels.CodeUnauthorized})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,76 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMetricsAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const unitID = "0b5e2a4c-1f3d-4e6a-9b7c-8d9e0f1a2b3c"
+	r := gin.New()
+	r.GET("/metrics/org-units/:id", MetricsAuth(map[string]string{unitID: "s3cret"}), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name   string
+		id     string
+		token  string
+		status int
+	}{
+		{"own token", unitID, "s3cret", http.StatusOK},
+		{"upper case ID", strings.ToUpper(unitID), "s3cret", http.StatusOK},
+		{"wrong token", unitID, "nope", http.StatusUnauthorized},
+		{"no token", unitID, "", http.StatusUnauthorized},
+		{"unit without token", "9f8e7d6c-5b4a-4321-8fed-cba987654321", "s3cret", http.StatusUnauthorized},
+		{"invalid ID", "not-a-uuid", "s3cret", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics/org-units/"+tt.id, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestCallCounter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	counter := NewCallCounter()
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if keyID := c.GetHeader(HeaderKeyID); keyID != "" { // Stands in for HMACAuth
+			c.Set(KeyIDContextKey, keyID)
+		}
+	}, counter.Middleware())
+	r.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, keyID := range []string{"integrator-a", "integrator-a", "integrator-b", ""} {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set(HeaderKeyID, keyID)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if got := counter.Calls("integrator-a"); got != 2 {
+		t.Errorf("Calls(integrator-a) = %d, want 2", got)
+	}
+	if got := counter.Calls("integrator-b"); got != 1 {
+		t.Errorf("Calls(integrator-b) = %d, want 1", got)
+	}
+	if got := counter.Calls(""); got != 0 {
+		t.Errorf("Calls() of unsigned requests = %d, want 0", got)
+	}
+}
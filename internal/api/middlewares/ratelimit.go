@@ -0,0 +1,56 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+)
+
+// RateLimit allows each client IP perMinute requests per fixed one-minute window, the rest get 429 with Retry-After.
+// Meant for anonymous public access, where the concurrency limit alone lets a fast client hammer the database.
+func RateLimit(perMinute int) gin.HandlerFunc {
+	type window struct {
+		start time.Time
+		count int
+	}
+	var mu sync.Mutex
+	var sweptAt time.Time
+	windows := make(map[string]*window)
+
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		now := time.Now()
+
+		mu.Lock()
+		if now.Sub(sweptAt) >= time.Minute { // Keep the map bounded by the number of clients seen in the last couple of minutes
+			for key, old := range windows {
+				if now.Sub(old.start) >= time.Minute {
+					delete(windows, key)
+				}
+			}
+			sweptAt = now
+		}
+		w, ok := windows[ip]
+		if !ok || now.Sub(w.start) >= time.Minute {
+			w = &window{start: now}
+			windows[ip] = w
+		}
+		w.count++
+		over, retryAfter := w.count > perMinute, w.start.Add(time.Minute).Sub(now)
+		mu.Unlock()
+
+		if over {
+			slog.Warn("rate limit exceeded", "ip", ip, "limit", perMinute)
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, apiModels.ErrorResponse{Error: "Too many requests", Code: apiModels.CodeTooManyRequests})
+			return
+		}
+		c.Next()
+	}
+}
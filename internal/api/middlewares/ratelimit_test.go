@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"testing"
+
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(RateLimit(2))
+	r.GET("/subscriptions", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/subscriptions", nil)
+		req.RemoteAddr = ip + ":12345"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := request("10.0.0.1"); w.Code != http.StatusOK {
+			t.Fatalf("request %d within limit status = %d, want %d", i+1, w.Code, http.StatusOK)
+		}
+	}
+
+	w := request("10.0.0.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("request over limit status = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("rejected request has no Retry-After header")
+	}
+
+	if w = request("10.0.0.2"); w.Code != http.StatusOK {
+		t.Errorf("other client status = %d, want %d, limits are per IP", w.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,35 @@
+package middlewares
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+)
+
+// ReadOnly rejects every request that could change data, whatever route it was meant for.
+// Installed on the engine, it also covers routes registered after it, so a new mutation can't slip through.
+// Reads are "METHOD /full/route/:param" of routes that only compute a response despite their method, they are let through.
+func ReadOnly(reads ...string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(reads))
+	for _, route := range reads {
+		allowed[route] = true
+	}
+
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+		default:
+			if allowed[c.Request.Method+" "+c.FullPath()] {
+				c.Next()
+				return
+			}
+			slog.Warn("mutation rejected in read-only mode", "method", c.Request.Method, "path", c.Request.URL.Path)
+			c.Header("Allow", "GET, HEAD, OPTIONS")
+			c.AbortWithStatusJSON(http.StatusMethodNotAllowed, apiModels.ErrorResponse{Error: "API is read-only", Code: apiModels.CodeReadOnly})
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"testing"
+
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReadOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(ReadOnly("POST /subscriptions/total/batch"))
+	handler := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/subscriptions", handler)
+	r.POST("/subscriptions", handler)
+	r.POST("/subscriptions/total/batch", handler)
+	r.DELETE("/subscriptions/:id", handler)
+	r.PUT("/users/:id/:action", handler) // Registered after the policy, still covered
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodGet, "/subscriptions", http.StatusOK},
+		{http.MethodPost, "/subscriptions", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/subscriptions/total/batch", http.StatusOK}, // Only reads
+		{http.MethodPost, "/subscriptions/batch", http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/subscriptions/1", http.StatusMethodNotAllowed},
+		{http.MethodPut, "/users/1/subscriptions:sync", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s %s status = %d, want %d", tt.method, tt.path, w.Code, tt.want)
+		}
+		if tt.want == http.StatusMethodNotAllowed && w.Header().Get("Allow") == "" {
+			t.Errorf("%s %s has no Allow header", tt.method, tt.path)
+		}
+	}
+}
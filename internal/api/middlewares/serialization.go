@@ -0,0 +1,18 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+)
+
+// Serialization puts serialization of the API version into the request context, shared controllers render responses with it.
+// API-Version tells the client which version answered.
+func Serialization(s apiModels.Serialization) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(apiModels.WithSerialization(c.Request.Context(), s))
+		c.Header("API-Version", s.Version)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HeaderTimeout echoes the deadline applied to the request, for debugging timeout configuration
+const HeaderTimeout = "X-Request-Timeout"
+
+// Timeout sets a deadline on the request context, so storage calls are cancelled once it passes.
+// Routes are keyed by "METHOD /route/:param" or "/route/:param" relative to basePath, lower case,
+// the former wins; other routes get def. Zero means no deadline.
+func Timeout(basePath string, def time.Duration, routes map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := strings.ToLower(strings.TrimPrefix(c.FullPath(), basePath))
+		timeout, ok := routes[strings.ToLower(c.Request.Method)+" "+route]
+		if !ok {
+			if timeout, ok = routes[route]; !ok {
+				timeout = def
+			}
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(HeaderTimeout, timeout.String())
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			slog.Warn("request deadline exceeded", "method", c.Request.Method, "route", c.FullPath(), "timeout", timeout, "status", c.Writer.Status())
+		}
+	}
+}
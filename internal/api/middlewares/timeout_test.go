@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"testing"
+
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	api := r.Group("/api/v1", Timeout("/api/v1", 5*time.Second, map[string]time.Duration{
+		"get /subscriptions/:id":    time.Second,
+		"/subscriptions/total":      time.Minute,
+		"delete /subscriptions/:id": 0,
+	}))
+	handler := func(c *gin.Context) {
+		deadline, ok := c.Request.Context().Deadline()
+		if !ok {
+			c.String(http.StatusOK, "none")
+			return
+		}
+		c.String(http.StatusOK, time.Until(deadline).Round(time.Second).String())
+	}
+	api.GET("/subscriptions/:id", handler)
+	api.PUT("/subscriptions/:id", handler)
+	api.DELETE("/subscriptions/:id", handler)
+	api.GET("/subscriptions/total", handler)
+
+	tests := []struct {
+		method, path string
+		want         string
+	}{
+		{http.MethodGet, "/api/v1/subscriptions/1", "1s"},
+		{http.MethodPut, "/api/v1/subscriptions/1", "5s"},       // Route configured for another method only, default applies
+		{http.MethodGet, "/api/v1/subscriptions/total", "1m0s"}, // Any method
+		{http.MethodDelete, "/api/v1/subscriptions/1", ""},      // Disabled
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if got := w.Header().Get(HeaderTimeout); got != tt.want {
+				t.Errorf("%s = %q, want %q", HeaderTimeout, got, tt.want)
+			}
+			wantBody := tt.want
+			if wantBody == "" {
+				wantBody = "none"
+			}
+			if w.Body.String() != wantBody {
+				t.Errorf("context deadline in %s, want %s", w.Body.String(), wantBody)
+			}
+		})
+	}
+}
@@ -1,63 +1,237 @@
 package models
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/spf13/viper"
 
+	"subscription-aggregator-service/internal/config"
+	"subscription-aggregator-service/internal/models"
 	"subscription-aggregator-service/internal/utils/dates"
+	"subscription-aggregator-service/internal/utils/display"
 )
 
 type ErrorResponse struct {
-	Error string `json:"error" example:"Subscription not found" format:"string"` // Returned error struct example
+	Error  string       `json:"error" example:"Subscription not found" format:"string"` // Returned error struct example
+	Code   string       `json:"code" example:"SUBSCRIPTION_NOT_FOUND" format:"string"`  // One of Code* constants, stable for clients to branch on
+	Fields []FieldError `json:"fields,omitempty"`                                       // (Optional) Invalid fields of the request, for validation errors
+}
+
+// NewErrorResponse takes the code of the first Error err is or wraps and invalid fields if it wraps a ValidationError.
+// Errors without a code are internal ones.
+func NewErrorResponse(err error) ErrorResponse {
+	resp := ErrorResponse{Error: err.Error(), Code: CodeInternal}
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		resp.Code, resp.Fields = CodeValidation, verr.Fields
+	}
+	var coded *Error
+	if errors.As(err, &coded) {
+		resp.Code = coded.Code
+	}
+	return resp
+}
+
+// Error is an error with a code from the catalogue, sentinel errors of the service are Errors
+type Error struct {
+	Code    string
+	Message string
+}
+
+func NewError(code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Codes of ErrorResponse, stable for clients to branch on instead of parsing messages
+const (
+	CodeValidation           = "VALIDATION_ERROR"
+	CodeInvalidJSON          = "INVALID_JSON"
+	CodeInvalidURI           = "INVALID_URI"
+	CodeInvalidQuery         = "INVALID_QUERY"
+	CodeNotFound             = "NOT_FOUND"
+	CodeSubscriptionNotFound = "SUBSCRIPTION_NOT_FOUND"
+	CodeSubscriptionExists   = "SUBSCRIPTION_ALREADY_EXISTS"
+	CodeSubscriptionModified = "SUBSCRIPTION_MODIFIED"
+	CodeIfMatchRequired      = "IF_MATCH_REQUIRED"
+	CodeAlreadyPaused        = "ALREADY_PAUSED"
+	CodeNotPaused            = "NOT_PAUSED"
+	CodeViewNotFound         = "VIEW_NOT_FOUND"
+	CodeViewExists           = "VIEW_ALREADY_EXISTS"
+	CodePlanNotFound         = "PLAN_NOT_FOUND"
+	CodePlanExists           = "PLAN_ALREADY_EXISTS"
+	CodeOrgUnitNotFound      = "ORG_UNIT_NOT_FOUND"
+	CodeOrgUnitExists        = "ORG_UNIT_ALREADY_EXISTS"
+	CodeUndoTokenNotFound    = "UNDO_TOKEN_NOT_FOUND"
+	CodeBulkDeleteNotFound   = "BULK_DELETE_NOT_FOUND"
+	CodeUnauthorized         = "UNAUTHORIZED"
+	CodeInvalidSignature     = "INVALID_SIGNATURE"
+	CodeTooManyRequests      = "TOO_MANY_REQUESTS"
+	CodeReadOnly             = "READ_ONLY"
+	CodeServiceUnavailable   = "SERVICE_UNAVAILABLE"
+	CodeInternal             = "INTERNAL_ERROR"
+)
+
+// Codes of FieldError, from the same catalogue as codes of ErrorResponse
+const (
+	CodeRequired          = "REQUIRED"
+	CodeInvalidUUID       = "INVALID_UUID"
+	CodeInvalidDate       = "INVALID_DATE_FORMAT"
+	CodeInvalidValue      = "INVALID_VALUE"
+	CodeMustBePositive    = "MUST_BE_POSITIVE"
+	CodeMustBeNegative    = "MUST_BE_NEGATIVE"
+	CodeMustNotBeNegative = "MUST_NOT_BE_NEGATIVE"
+	CodeOutOfRange        = "OUT_OF_RANGE"
+	CodeTooLong           = "TOO_LONG"
+	CodeEndBeforeStart    = "END_BEFORE_START"
+	CodeDuplicate         = "DUPLICATE"
+	CodeMustDiffer        = "MUST_DIFFER"
+	CodeOneOfRequired     = "ONE_OF_REQUIRED"
+)
+
+// FieldError is one invalid field of a request
+type FieldError struct {
+	Field   string `json:"field" example:"price" format:"string"`                      // JSON name of the field, subscriptions[2].external_id for list items, empty if the request as a whole is invalid
+	Code    string `json:"code" example:"MUST_NOT_BE_NEGATIVE" format:"string"`        // One of Code* constants
+	Message string `json:"message" example:"price cannot be negative" format:"string"` // Human-readable description
+}
+
+// ValidationError is returned by Validate methods with every invalid field found
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// NestFields puts invalid fields of err, if any, under prefix, like subscriptions[2].price for an item of a list
+func NestFields(err error, prefix string) {
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		return
+	}
+	for i := range verr.Fields {
+		if verr.Fields[i].Field == "" {
+			verr.Fields[i].Field = prefix
+		} else {
+			verr.Fields[i].Field = prefix + "." + verr.Fields[i].Field
+		}
+	}
+}
+
+// fieldErrors collects invalid fields in a Validate method
+type fieldErrors []FieldError
+
+func (fe *fieldErrors) add(field, code, format string, args ...any) {
+	*fe = append(*fe, FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// has tells if field was already found invalid, so checks depending on it can be skipped
+func (fe fieldErrors) has(field string) bool {
+	for _, f := range fe {
+		if f.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (fe fieldErrors) err() error {
+	if len(fe) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: fe}
 }
 
 var (
-	ErrBadJSON  = errors.New(fmt.Sprintf("Invalid request body"))
-	ErrBadParam = errors.New(fmt.Sprintf("Invalid request uri"))
+	ErrBadJSON  = NewError(CodeInvalidJSON, "Invalid request body")
+	ErrBadParam = NewError(CodeInvalidURI, "Invalid request uri")
 )
 
 type CreateSubscriptionRequest struct {
-	ServiceName string  `json:"service_name" example:"Telegram Premium" format:"string"`              // Name of the service
-	Price       int     `json:"price" example:"299" format:"int"`                                     // Price in rubles
-	UserID      string  `json:"user_id" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"` // User UUID
-	StartDate   string  `json:"start_date" example:"01-2026" format:"string"`                         // Start date in MM-YYYY format
-	EndDate     *string `json:"end_date,omitempty" example:"02-2026" format:"string"`                 // (Optional) End date in MM-YYYY format
+	ID          *string `json:"id,omitempty" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"`          // (Optional) Client-supplied subscription UUID
+	ExternalID  *string `json:"external_id,omitempty" example:"crm-42" format:"string"`                             // (Optional) ID in the client's system, unique per user
+	ServiceName string  `json:"service_name" example:"Telegram Premium" format:"string"`                            // Name of the service
+	Price       *int    `json:"price,omitempty" example:"299" format:"int"`                                         // Price in rubles, taken from the plan if omitted with plan_id
+	UserID      string  `json:"user_id" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"`               // User UUID
+	StartDate   string  `json:"start_date" example:"01-2026" format:"string"`                                       // Start date in MM-YYYY format
+	EndDate     *string `json:"end_date,omitempty" example:"02-2026" format:"string"`                               // (Optional) End date in MM-YYYY format
+	Type        string  `json:"type,omitempty" example:"recurring" enums:"recurring,one_time"`                      // (Optional) "one_time" is charged only in its start month, "recurring" by default
+	PlanID      *string `json:"plan_id,omitempty" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"`     // (Optional) Catalog plan, prefills omitted service name and price
+	FollowPlan  bool    `json:"follow_plan_price,omitempty" example:"true" format:"bool"`                           // (Optional) Keep price equal to the plan's official price as it changes
+	OrgUnitID   *string `json:"org_unit_id,omitempty" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"` // (Optional) Department or team the cost is attributed to
+	CostCenter  *string `json:"cost_center,omitempty" example:"CC-1042" format:"string"`                            // (Optional) Allocation tag for chargeback
+	ProjectCode *string `json:"project_code,omitempty" example:"APOLLO" format:"string"`                            // (Optional) Allocation tag for chargeback
 }
 
 func (req *CreateSubscriptionRequest) Validate() error {
+	var errs fieldErrors
+	if req.ID != nil && *req.ID != "" {
+		if _, err := uuid.Parse(*req.ID); err != nil {
+			errs.add("id", CodeInvalidUUID, "subscription ID must be a valid UUID")
+		}
+	}
+	if req.PlanID != nil {
+		if _, err := uuid.Parse(*req.PlanID); err != nil {
+			errs.add("plan_id", CodeInvalidUUID, "plan ID must be a valid UUID")
+		}
+	} else if req.FollowPlan {
+		errs.add("plan_id", CodeRequired, "plan ID is required to follow plan price")
+	}
+	if req.OrgUnitID != nil {
+		if _, err := uuid.Parse(*req.OrgUnitID); err != nil {
+			errs.add("org_unit_id", CodeInvalidUUID, "org unit ID must be a valid UUID")
+		}
+	}
 	if req.ServiceName == "" { // && ∈ [A-z][0-9]?
-		return fmt.Errorf("service name is required")
+		errs.add("service_name", CodeRequired, "service name is required")
 	}
-	if req.Price <= 0 {
-		return fmt.Errorf("price must be above zero")
+	if req.Price != nil && *req.Price < 0 { // Zero is a free or comped subscription
+		errs.add("price", CodeMustNotBeNegative, "price cannot be negative")
 	}
 	if req.UserID == "" {
-		return fmt.Errorf("user ID is required")
+		errs.add("user_id", CodeRequired, "user ID is required")
 	} else if _, err := uuid.Parse(req.UserID); err != nil {
-		return fmt.Errorf("user ID must be a valid UUID")
+		errs.add("user_id", CodeInvalidUUID, "user ID must be a valid UUID")
 	}
+	var start time.Time
+	var err error
 	if req.StartDate == "" {
-		return fmt.Errorf("start date is required")
-	}
-	start, err := dates.String2Date(req.StartDate)
-	if err != nil {
-		return fmt.Errorf("start date: %w", err)
+		errs.add("start_date", CodeRequired, "start date is required")
+	} else if start, err = dates.String2Date(req.StartDate); err != nil {
+		errs.add("start_date", CodeInvalidDate, "start date: %v", err)
 	}
 	if req.EndDate != nil && *req.EndDate != "" {
-		var end time.Time
-		end, err = dates.String2Date(*req.EndDate)
-		if err != nil {
-			return fmt.Errorf("end date: %w", err)
-		}
-		if end.Before(start) {
-			return fmt.Errorf("end date cannot precede start date")
+		end, err := dates.String2Date(*req.EndDate)
+		switch {
+		case err != nil:
+			errs.add("end_date", CodeInvalidDate, "end date: %v", err)
+		case errs.has("start_date"): // Nothing to compare with
+		case end.Before(start):
+			errs.add("end_date", CodeEndBeforeStart, "end date cannot precede start date")
+		case req.Type == models.TypeOneTime && !end.Equal(start):
+			errs.add("end_date", CodeInvalidValue, "one-time purchase must end in its start month")
 		}
 	}
-	return nil
+	if req.Type != "" && req.Type != models.TypeRecurring && req.Type != models.TypeOneTime {
+		errs.add("type", CodeInvalidValue, "type must be %q or %q", models.TypeRecurring, models.TypeOneTime)
+	}
+	return errs.err()
 }
 
 func (req *CreateSubscriptionRequest) ParseDates() (time.Time, *time.Time, error) {
@@ -83,35 +257,187 @@ func (req *CreateSubscriptionRequest) ParseDates() (time.Time, *time.Time, error
 	return start, &end, nil
 }
 
+type CreateSubscriptionQuery struct {
+	IfAbsentBy string `form:"if_absent_by" binding:"omitempty,oneof=external_id" example:"external_id" format:"string"` // (Optional) Return existing subscription with the same key instead of creating a duplicate
+}
+
 type CreateSubscriptionResponse struct {
 	ID uuid.UUID `json:"id" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"` // UUID of created subscription
 }
 
+// SubscriptionResponse is the subscription as handlers return it, decoupled from the storage model
+type SubscriptionResponse struct {
+	ID          uuid.UUID  `json:"id" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"`                    // UUID of subscription
+	ExternalID  *string    `json:"external_id,omitempty" example:"crm-42" format:"string"`                             // (Optional) ID in the client's system
+	ServiceName string     `json:"service_name" example:"Telegram Premium" format:"string"`                            // Name of the service
+	Price       int        `json:"price" example:"299" format:"int"`                                                   // Price in rubles
+	Type        string     `json:"type" example:"recurring" enums:"recurring,one_time"`                                // Subscription type
+	PlanID      *uuid.UUID `json:"plan_id,omitempty" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"`     // (Optional) Plan the subscription is on
+	FollowPlan  bool       `json:"follow_plan_price,omitempty" example:"true" format:"bool"`                           // (Optional) Price is kept equal to the plan's official price
+	OrgUnitID   *uuid.UUID `json:"org_unit_id,omitempty" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"` // (Optional) Department or team the cost is attributed to
+	CostCenter  *string    `json:"cost_center,omitempty" example:"CC-1042" format:"string"`                            // (Optional) Allocation tag for chargeback
+	ProjectCode *string    `json:"project_code,omitempty" example:"APOLLO" format:"string"`                            // (Optional) Allocation tag for chargeback
+	UserID      uuid.UUID  `json:"user_id" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"`               // UUID of the user
+	StartDate   string     `json:"start_date" example:"01-2026" format:"string"`                                       // Start date in MM-YYYY format
+	EndDate     *string    `json:"end_date,omitempty" example:"02-2026" format:"string"`                               // (Optional) End date in MM-YYYY format, absent if open-ended
+	CancelledAt *time.Time `json:"cancelled_at,omitempty" example:"2026-01-15T10:00:00Z" format:"date-time"`           // (Optional) When the subscription was cancelled
+	Status      string     `json:"status" example:"active" enums:"active,upcoming,expired,cancelled,deleted"`          // Status in the current month
+	CreatedAt   time.Time  `json:"created_at" example:"2026-01-01T10:00:00Z" format:"date-time"`                       // Creation time
+	UpdatedAt   time.Time  `json:"updated_at" example:"2026-01-01T10:00:00Z" format:"date-time"`                       // Last change time
+	Version     int        `json:"version" example:"1" format:"int"`                                                   // Same as ETag, incremented by every change
+}
+
+// NewSubscriptionResponse maps the subscription to its response with status in the current month
+func NewSubscriptionResponse(sub *models.Subscription, ser Serialization) *SubscriptionResponse {
+	now := time.Now().UTC()
+	resp := &SubscriptionResponse{
+		ID:          sub.ID,
+		ExternalID:  sub.ExternalID,
+		ServiceName: sub.ServiceName,
+		Price:       sub.Price,
+		Type:        sub.Type,
+		PlanID:      sub.PlanID,
+		FollowPlan:  sub.FollowPlan,
+		OrgUnitID:   sub.OrgUnitID,
+		CostCenter:  sub.CostCenter,
+		ProjectCode: sub.ProjectCode,
+		UserID:      sub.UserID,
+		StartDate:   ser.Month(sub.StartDate),
+		CancelledAt: sub.CancelledAt,
+		Status:      sub.StatusIn(time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)),
+		CreatedAt:   sub.CreatedAt,
+		UpdatedAt:   sub.UpdatedAt,
+		Version:     sub.Version,
+	}
+	resp.EndDate = ser.MonthPtr(sub.EndDate)
+	return resp
+}
+
+// Serialization is how a version of the API renders responses. Controllers and the service are shared by all versions,
+// so a breaking change in rendering goes to a new version while older ones stay as they were.
+type Serialization struct {
+	Version     string
+	MonthLayout string // Months like start_date
+}
+
+// SerializationV1 renders months as RFC3339 timestamps like v1 always did, or as MM-YYYY only with app.api.date_format: month
+func SerializationV1() Serialization {
+	if viper.GetString(config.ApiDateFormat) == config.DateFormatMonth {
+		return Serialization{Version: "v1", MonthLayout: dates.Layout}
+	}
+	return Serialization{Version: "v1", MonthLayout: time.RFC3339}
+}
+
+// SerializationV2 always renders months as MM-YYYY, the same as requests take them, whatever app.api.date_format is
+func SerializationV2() Serialization {
+	return Serialization{Version: "v2", MonthLayout: dates.Layout}
+}
+
+func (s Serialization) Month(t time.Time) string {
+	return t.Format(s.MonthLayout)
+}
+
+func (s Serialization) MonthPtr(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	month := s.Month(*t)
+	return &month
+}
+
+type serializationKey struct{}
+
+func WithSerialization(ctx context.Context, s Serialization) context.Context {
+	return context.WithValue(ctx, serializationKey{}, s)
+}
+
+// SerializationFromContext returns serialization of the API version the request came to, SerializationV1 outside of requests
+func SerializationFromContext(ctx context.Context) Serialization {
+	if s, ok := ctx.Value(serializationKey{}).(Serialization); ok {
+		return s
+	}
+	return SerializationV1()
+}
+
+// NewSubscriptionResponses maps subscriptions to responses in the same order, never nil
+func NewSubscriptionResponses(subs []models.Subscription, ser Serialization) []SubscriptionResponse {
+	resp := make([]SubscriptionResponse, len(subs))
+	for i := range subs {
+		resp[i] = *NewSubscriptionResponse(&subs[i], ser)
+	}
+	return resp
+}
+
 type UpdateSubscriptionRequest struct {
-	ServiceName *string `json:"service_name,omitempty" example:"Telegram Premium" format:"string"` // (Optional) Updated name of the service
-	Price       *int    `json:"price,omitempty"  example:"299" format:"int"`                       // (Optional) Updated price of the subscription
-	StartDate   *string `json:"start_date,omitempty" example:"02-2026" format:"string"`            // (Optional) Updated start date of subscription
-	EndDate     *string `json:"end_date,omitempty" example:"02-2027" format:"string"`              // (Optional) Updated end date of subscription, send empty string ("") to clear
+	ServiceName *string `json:"service_name,omitempty" example:"Telegram Premium" format:"string"`                  // (Optional) Updated name of the service
+	Price       *int    `json:"price,omitempty"  example:"299" format:"int"`                                        // (Optional) Updated price of the subscription
+	StartDate   *string `json:"start_date,omitempty" example:"02-2026" format:"string"`                             // (Optional) Updated start date of subscription
+	EndDate     *string `json:"end_date,omitempty" example:"02-2027" format:"string"`                               // (Optional) Updated end date of subscription, send empty string ("") to clear
+	OrgUnitID   *string `json:"org_unit_id,omitempty" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"` // (Optional) Updated org unit, send empty string ("") to detach
+	CostCenter  *string `json:"cost_center,omitempty" example:"CC-1042" format:"string"`                            // (Optional) Updated cost center, send empty string ("") to clear
+	ProjectCode *string `json:"project_code,omitempty" example:"APOLLO" format:"string"`                            // (Optional) Updated project code, send empty string ("") to clear
+	IfMatch     string  `json:"-"`                                                                                  // If-Match header, set by controller
+}
+
+// SubscriptionMergePatch is RFC 7386 JSON merge patch for PATCH /subscriptions/{id}: absent field is kept, null clears it
+type SubscriptionMergePatch map[string]json.RawMessage
+
+// ToUpdate converts patch to the PUT request it stands for, only end_date, org_unit_id and allocation tags can be cleared
+func (p SubscriptionMergePatch) ToUpdate() (*UpdateSubscriptionRequest, error) {
+	req := &UpdateSubscriptionRequest{}
+	fields := map[string]any{"service_name": &req.ServiceName, "price": &req.Price, "start_date": &req.StartDate, "end_date": &req.EndDate, "org_unit_id": &req.OrgUnitID,
+		"cost_center": &req.CostCenter, "project_code": &req.ProjectCode}
+	for name, value := range p {
+		field, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field '%s'", name)
+		}
+		if string(bytes.TrimSpace(value)) == "null" {
+			switch name {
+			case "end_date":
+				req.EndDate = new(string) // Empty value clears it
+			case "org_unit_id":
+				req.OrgUnitID = new(string)
+			case "cost_center":
+				req.CostCenter = new(string)
+			case "project_code":
+				req.ProjectCode = new(string)
+			default:
+				return nil, fmt.Errorf("field '%s' cannot be cleared", name)
+			}
+			continue
+		}
+		if err := json.Unmarshal(value, field); err != nil {
+			return nil, fmt.Errorf("invalid value of field '%s'", name)
+		}
+	}
+	return req, nil
 }
 
 func (req *UpdateSubscriptionRequest) Validate() error {
+	var errs fieldErrors
 	if req.ServiceName != nil && strings.TrimSpace(*req.ServiceName) == "" {
-		return fmt.Errorf("service name is required")
+		errs.add("service_name", CodeRequired, "service name is required")
 	}
-	if req.Price != nil && *req.Price <= 0 {
-		return fmt.Errorf("price must be above zero")
+	if req.Price != nil && *req.Price < 0 {
+		errs.add("price", CodeMustNotBeNegative, "price cannot be negative")
 	}
 	if req.StartDate != nil {
 		if _, err := dates.String2Date(*req.StartDate); err != nil {
-			return fmt.Errorf("invalid start date format")
+			errs.add("start_date", CodeInvalidDate, "invalid start date format")
 		}
 	}
 	if req.EndDate != nil && strings.TrimSpace(*req.EndDate) != "" {
 		if _, err := dates.String2Date(*req.EndDate); err != nil {
-			return fmt.Errorf("invalid end date format")
+			errs.add("end_date", CodeInvalidDate, "invalid end date format")
+		}
+	}
+	if req.OrgUnitID != nil && *req.OrgUnitID != "" {
+		if _, err := uuid.Parse(*req.OrgUnitID); err != nil {
+			errs.add("org_unit_id", CodeInvalidUUID, "org unit ID must be a valid UUID")
 		}
 	}
-	return nil
+	return errs.err()
 }
 
 func (req *UpdateSubscriptionRequest) ParseDates() (*time.Time, *time.Time, bool, error) {
@@ -146,24 +472,678 @@ func (req *UpdateSubscriptionRequest) ParseDates() (*time.Time, *time.Time, bool
 	return start, end, false, nil
 }
 
+type CancelSubscriptionRequest struct {
+	EndDate *string `json:"end_date,omitempty" example:"06-2026" format:"string"` // (Optional) Last paid month in MM-YYYY format, current month by default
+	IfMatch string  `json:"-"`                                                    // If-Match header, set by controller
+}
+
+// ParseEndDate returns the requested last month, nil if it's not set
+func (req *CancelSubscriptionRequest) ParseEndDate() (*time.Time, error) {
+	if req.EndDate == nil {
+		return nil, nil
+	}
+	end, err := dates.String2Date(*req.EndDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date format")
+	}
+	return &end, nil
+}
+
+type RenewSubscriptionRequest struct {
+	Months  int    `json:"months" example:"12" format:"int"` // Months to add to the end date, a fixed term for an open-ended subscription
+	IfMatch string `json:"-"`                                // If-Match header, set by controller
+}
+
+func (req *RenewSubscriptionRequest) Validate() error {
+	var errs fieldErrors
+	if req.Months < 1 || req.Months > 1200 {
+		errs.add("months", CodeOutOfRange, "months must be between 1 and 1200")
+	}
+	return errs.err()
+}
+
+type PauseSubscriptionRequest struct {
+	Month *string `json:"month,omitempty" example:"06-2026" format:"string"` // (Optional) Month to pause from or resume in, MM-YYYY, current month by default
+}
+
+// ParseMonth returns the requested month, nil if it's not set
+func (req *PauseSubscriptionRequest) ParseMonth() (*time.Time, error) {
+	if req.Month == nil {
+		return nil, nil
+	}
+	month, err := dates.String2Date(*req.Month)
+	if err != nil {
+		return nil, fmt.Errorf("invalid month format")
+	}
+	return &month, nil
+}
+
+type PausedPeriodResponse struct {
+	ID             uuid.UUID `json:"id" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"`              // UUID of the pause
+	SubscriptionID uuid.UUID `json:"subscription_id" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"` // UUID of paused subscription
+	StartDate      string    `json:"start_date" example:"06-2026" format:"string"`                                 // First paused month in MM-YYYY format
+	EndDate        *string   `json:"end_date,omitempty" example:"08-2026" format:"string"`                         // (Optional) Last paused month in MM-YYYY format, absent while paused
+	CreatedAt      time.Time `json:"created_at" example:"2026-06-01T10:00:00Z" format:"date-time"`                 // Creation time
+}
+
+func NewPausedPeriodResponse(p *models.PausedPeriod, ser Serialization) *PausedPeriodResponse {
+	return &PausedPeriodResponse{ID: p.ID, SubscriptionID: p.SubscriptionID, StartDate: ser.Month(p.StartDate), EndDate: ser.MonthPtr(p.EndDate), CreatedAt: p.CreatedAt}
+}
+
+func NewPausedPeriodResponses(pauses []models.PausedPeriod, ser Serialization) []PausedPeriodResponse {
+	resp := make([]PausedPeriodResponse, len(pauses))
+	for i := range pauses {
+		resp[i] = *NewPausedPeriodResponse(&pauses[i], ser)
+	}
+	return resp
+}
+
+type CreateCreditRequest struct {
+	Amount      int     `json:"amount,omitempty" example:"-100" format:"int"`                      // Discount per month in rubles, negative; either it or percent
+	Percent     int     `json:"percent,omitempty" example:"50" format:"int"`                       // Discount per month as a share of the price, 1-100; either it or amount
+	StartDate   string  `json:"start_date" example:"01-2026" format:"string"`                      // First discounted month in MM-YYYY format
+	EndDate     *string `json:"end_date,omitempty" example:"03-2026" format:"string"`              // (Optional) Last discounted month in MM-YYYY format, subscription end by default
+	Description string  `json:"description,omitempty" example:"Promo code SPRING" format:"string"` // (Optional) Reason for the credit
+}
+
+func (req *CreateCreditRequest) Validate() error {
+	var errs fieldErrors
+	switch {
+	case req.Amount > 0:
+		errs.add("amount", CodeMustBeNegative, "credit amount must be negative")
+	case req.Percent < 0 || req.Percent > 100:
+		errs.add("percent", CodeOutOfRange, "credit percent must be between 1 and 100")
+	case (req.Amount < 0) == (req.Percent > 0):
+		errs.add("amount", CodeOneOfRequired, "exactly one of amount and percent is required")
+	}
+	var start time.Time
+	var err error
+	if req.StartDate == "" {
+		errs.add("start_date", CodeRequired, "start date is required")
+	} else if start, err = dates.String2Date(req.StartDate); err != nil {
+		errs.add("start_date", CodeInvalidDate, "start date: %v", err)
+	}
+	if req.EndDate != nil {
+		end, err := dates.String2Date(*req.EndDate)
+		switch {
+		case err != nil:
+			errs.add("end_date", CodeInvalidDate, "end date: %v", err)
+		case errs.has("start_date"): // Nothing to compare with
+		case end.Before(start):
+			errs.add("end_date", CodeEndBeforeStart, "end date cannot precede start date")
+		}
+	}
+	if len(req.Description) > 200 {
+		errs.add("description", CodeTooLong, "description cannot be longer than 200 characters")
+	}
+	return errs.err()
+}
+
+type CreditResponse struct {
+	ID             uuid.UUID `json:"id" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"`              // UUID of the credit
+	SubscriptionID uuid.UUID `json:"subscription_id" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"` // UUID of discounted subscription
+	Amount         int       `json:"amount" example:"-100" format:"int"`                                           // Discount per month in rubles, negative; 0 for percentage credits
+	Percent        int       `json:"percent,omitempty" example:"50" format:"int"`                                  // (Optional) Discount per month as a share of the price
+	StartDate      string    `json:"start_date" example:"01-2026" format:"string"`                                 // First discounted month in MM-YYYY format
+	EndDate        *string   `json:"end_date,omitempty" example:"03-2026" format:"string"`                         // (Optional) Last discounted month in MM-YYYY format
+	Description    string    `json:"description" example:"Promo code SPRING" format:"string"`                      // Reason for the credit
+	CreatedAt      time.Time `json:"created_at" example:"2026-01-01T10:00:00Z" format:"date-time"`                 // Creation time
+}
+
+func NewCreditResponse(c *models.SubscriptionCredit, ser Serialization) *CreditResponse {
+	return &CreditResponse{
+		ID:             c.ID,
+		SubscriptionID: c.SubscriptionID,
+		Amount:         c.Amount,
+		Percent:        c.Percent,
+		StartDate:      ser.Month(c.StartDate),
+		EndDate:        ser.MonthPtr(c.EndDate),
+		Description:    c.Description,
+		CreatedAt:      c.CreatedAt,
+	}
+}
+
+func NewCreditResponses(credits []models.SubscriptionCredit, ser Serialization) []CreditResponse {
+	resp := make([]CreditResponse, len(credits))
+	for i := range credits {
+		resp[i] = *NewCreditResponse(&credits[i], ser)
+	}
+	return resp
+}
+
 type ItemByIDRequest struct {
 	ID string `uri:"id" binding:"required,uuid" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"` // UUID of subscription
 }
 
+type UndoRequest struct {
+	Token string `uri:"token" binding:"required,uuid" example:"9b2f6c1e-3d4a-4e8b-9f0a-1c2d3e4f5a6b" format:"uuid"` // Undo token returned by delete
+}
+
 type ListSubscriptionsRequest struct {
-	ServiceName string `form:"service_name" example:"Telegram Premium" format:"string"`                                       // Filter by service name
-	UserID      string `form:"user_id" binding:"omitempty,uuid" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"` // Filter by user UUID
-	Limit       *int   `form:"limit" binding:"omitempty,min=1" example:"50" format:"int"`                                     // Limit the number of results
-	Offset      *int   `form:"offset" binding:"omitempty,min=0" example:"0" format:"int"`                                     // Offset for pagination
+	ServiceName     string `form:"service_name" example:"Telegram Premium" format:"string"`                                       // Filter by service name
+	ServiceNameCI   bool   `form:"service_name_ci" example:"true" format:"bool"`                                                  // (Optional) Match service_name case-insensitively
+	ServiceNameLike string `form:"service_name_like" example:"net*" format:"string"`                                              // (Optional) Case-insensitive match, * is any characters, without * a substring
+	UserID          string `form:"user_id" binding:"omitempty,uuid" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"` // Filter by user UUID
+	CreatedAfter    string `form:"created_after" example:"2024-01-01T00:00:00Z" format:"date-time"`                               // Only records created after this RFC3339 timestamp
+	CreatedBefore   string `form:"created_before" example:"2024-12-31T23:59:59Z" format:"date-time"`                              // Only records created before this RFC3339 timestamp
+	ActiveAt        string `form:"active_at" example:"03-2024" format:"string"`                                                   // (Optional) Only subscriptions active in this month, MM-YYYY
+	Status          string `form:"status" example:"active" format:"string"`                                                       // (Optional) active, upcoming, expired, cancelled or deleted in the current month
+	MinPrice        *int   `form:"min_price" binding:"omitempty,min=0" example:"500" format:"int"`                                // (Optional) Only subscriptions costing at least this much
+	MaxPrice        *int   `form:"max_price" binding:"omitempty,min=0" example:"1000" format:"int"`                               // (Optional) Only subscriptions costing at most this much
+	Limit           *int   `form:"limit" binding:"omitempty,min=1" example:"50" format:"int"`                                     // Limit the number of results
+	Offset          *int   `form:"offset" binding:"omitempty,min=0" example:"0" format:"int"`                                     // Offset for pagination
+	View            string `form:"view" binding:"omitempty,uuid" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"`    // (Optional) Saved view to apply, explicit filters take precedence
+	SortBy          string `form:"sort_by" example:"price" format:"string"`                                                       // (Optional) price, start_date, service_name or created_at (default)
+	Order           string `form:"order" example:"desc" format:"string"`                                                          // (Optional) asc or desc (default)
+}
+
+type ListSubscriptionsResponse struct {
+	Items      []SubscriptionResponse `json:"items"`                                  // Page of subscriptions in requested order, newest first by default
+	TotalCount int64                  `json:"total_count" example:"120" format:"int"` // Subscriptions matching the filters regardless of paging
+	Limit      *int                   `json:"limit" example:"50" format:"int"`        // Applied limit, null if unlimited
+	Offset     int                    `json:"offset" example:"0" format:"int"`        // Applied offset
+	NextOffset *int                   `json:"next_offset" example:"50" format:"int"`  // Offset of the next page, null on the last one
+}
+
+type CreatePlanRequest struct {
+	ServiceName string `json:"service_name" example:"Yandex Plus" format:"string"` // Name of the service
+	Name        string `json:"name" example:"Family" format:"string"`              // Name of the plan, unique per service
+	Price       int    `json:"price" example:"499" format:"int"`                   // Official monthly price in rubles
+}
+
+func (req *CreatePlanRequest) Validate() error {
+	var errs fieldErrors
+	if strings.TrimSpace(req.ServiceName) == "" {
+		errs.add("service_name", CodeRequired, "service name is required")
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		errs.add("name", CodeRequired, "plan name is required")
+	}
+	if req.Price < 0 {
+		errs.add("price", CodeMustNotBeNegative, "price cannot be negative")
+	}
+	return errs.err()
+}
+
+type UpdatePlanPriceRequest struct {
+	Price *int `json:"price" example:"549" format:"int"` // New official monthly price in rubles
+}
+
+func (req *UpdatePlanPriceRequest) Validate() error {
+	var errs fieldErrors
+	if req.Price == nil {
+		errs.add("price", CodeRequired, "price is required")
+	} else if *req.Price < 0 {
+		errs.add("price", CodeMustNotBeNegative, "price cannot be negative")
+	}
+	return errs.err()
+}
+
+type UpdatePlanPriceResponse struct {
+	Plan       *models.Plan `json:"plan"`                                 // Plan with the new price
+	Propagated int64        `json:"propagated" example:"12" format:"int"` // Running subscriptions following the plan that got the new price, ended ones keep theirs
+}
+
+type RenameServiceRequest struct {
+	From      string  `json:"from" example:"HBO Max" format:"string"`                                             // Current service name, matched case-insensitively
+	To        string  `json:"to" example:"Max" format:"string"`                                                   // New service name
+	OrgUnitID *string `json:"org_unit_id,omitempty" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"` // (Optional) Rename only in the unit and its descendants
+}
+
+func (req *RenameServiceRequest) Validate() error {
+	var errs fieldErrors
+	if strings.TrimSpace(req.From) == "" {
+		errs.add("from", CodeRequired, "current service name is required")
+	}
+	if strings.TrimSpace(req.To) == "" {
+		errs.add("to", CodeRequired, "new service name is required")
+	} else if strings.TrimSpace(req.From) == strings.TrimSpace(req.To) {
+		errs.add("to", CodeMustDiffer, "new service name must differ from the current one")
+	}
+	if req.OrgUnitID != nil {
+		if _, err := uuid.Parse(*req.OrgUnitID); err != nil {
+			errs.add("org_unit_id", CodeInvalidUUID, "org unit ID must be a valid UUID")
+		}
+	}
+	return errs.err()
+}
+
+type CreateOrgUnitRequest struct {
+	Name     string  `json:"name" example:"Marketing" format:"string"`                                         // Name of the unit, unique among its siblings
+	ParentID *string `json:"parent_id,omitempty" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"` // (Optional) Parent unit, root if omitted
+}
+
+func (req *CreateOrgUnitRequest) Validate() error {
+	var errs fieldErrors
+	if strings.TrimSpace(req.Name) == "" {
+		errs.add("name", CodeRequired, "org unit name is required")
+	}
+	if req.ParentID != nil {
+		if _, err := uuid.Parse(*req.ParentID); err != nil {
+			errs.add("parent_id", CodeInvalidUUID, "parent ID must be a valid UUID")
+		}
+	}
+	return errs.err()
+}
+
+// BulkDeleteRequest selects subscriptions to delete in the background, at least one criterion is required
+type BulkDeleteRequest struct {
+	UserID      *string `json:"user_id,omitempty" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"` // (Optional) Only subscriptions of this user
+	ServiceName *string `json:"service_name,omitempty" example:"Netflix" format:"string"`                       // (Optional) Only subscriptions to this service, exact match
+	StartDate   *string `json:"start_date,omitempty" example:"01-2020" format:"string"`                         // (Optional) MM-YYYY, only subscriptions starting in or after this month
+	EndDate     *string `json:"end_date,omitempty" example:"12-2021" format:"string"`                           // (Optional) MM-YYYY, only subscriptions ending in or before this month, open-ended ones are kept
+}
+
+func (req *BulkDeleteRequest) Validate() error {
+	var errs fieldErrors
+	if req.UserID == nil && req.ServiceName == nil && req.StartDate == nil && req.EndDate == nil {
+		errs.add("", CodeOneOfRequired, "at least one of user_id, service_name, start_date, end_date is required")
+	}
+	if req.UserID != nil {
+		if _, err := uuid.Parse(*req.UserID); err != nil {
+			errs.add("user_id", CodeInvalidUUID, "user ID must be a valid UUID")
+		}
+	}
+	if req.ServiceName != nil && strings.TrimSpace(*req.ServiceName) == "" {
+		errs.add("service_name", CodeRequired, "service name must not be empty")
+	}
+	return errs.err()
+}
+
+type OrgUnitCostRequest struct {
+	StartDate string `form:"start_date" binding:"required" example:"01-2024" format:"string"` // Start date in MM-YYYY format
+	EndDate   string `form:"end_date" binding:"required" example:"12-2024" format:"string"`   // End date in MM-YYYY format
+}
+
+// OrgUnitCost is the cost of a unit over the period, TotalCost includes all descendants
+type OrgUnitCost struct {
+	ID        uuid.UUID     `json:"id" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"` // UUID of the unit
+	Name      string        `json:"name" example:"Marketing" format:"string"`                        // Name of the unit
+	OwnCost   int64         `json:"own_cost" example:"1200" format:"int"`                            // Cost of subscriptions attributed to the unit itself
+	TotalCost int64         `json:"total_cost" example:"3600" format:"int"`                          // Own cost plus total cost of all children
+	Children  []OrgUnitCost `json:"children"`                                                        // Child units by name
+}
+
+// OrgUnitUsage is what an org unit and its descendants use, exposed to the unit's own monitoring
+type OrgUnitUsage struct {
+	ID                  uuid.UUID `json:"id"`
+	Name                string    `json:"name"`
+	Month               string    `json:"month"`                // Current month in MM-YYYY format, MonthlySpend and ActiveSubscriptions cover it
+	Subscriptions       int64     `json:"subscriptions"`        // Live subscriptions, including inactive in Month
+	ActiveSubscriptions int64     `json:"active_subscriptions"` // Subscriptions active in Month
+	MonthlySpend        int64     `json:"monthly_spend"`        // Cost of subscriptions in Month, after credits
+}
+
+type ChargebackRequest struct {
+	By        string `form:"by" binding:"required,oneof=cost_center project_code" example:"cost_center" format:"string"`    // Allocation tag to group spend by, cost_center or project_code
+	UserID    string `form:"user_id" binding:"omitempty,uuid" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"` // (Optional) Filter by user UUID
+	StartDate string `form:"start_date" binding:"required" example:"01-2024" format:"string"`                               // Start date in MM-YYYY format
+	EndDate   string `form:"end_date" binding:"required" example:"12-2024" format:"string"`                                 // End date in MM-YYYY format
+}
+
+// Values of ChargebackRequest.By
+const (
+	AllocationCostCenter  = "cost_center"
+	AllocationProjectCode = "project_code"
+)
+
+// ChargebackRow is spend of one allocation tag value in one month, Allocation is empty for untagged subscriptions
+type ChargebackRow struct {
+	Allocation string
+	Month      string // MM-YYYY
+	Cost       int64
+}
+
+type ExpiringSubscriptionsRequest struct {
+	Window    string `form:"window" example:"60d" format:"string"`     // (Optional) How far ahead to look in days, 30d by default
+	AutoRenew *bool  `form:"auto_renew" example:"false" format:"bool"` // (Optional) false lists only subscriptions without a renewal, true only renewed ones, both if absent
+}
+
+type ExpiringSubscriptionsResponse struct {
+	From       string         `json:"from" example:"03-2024" format:"string"` // First month of the window in MM-YYYY format, the current one
+	To         string         `json:"to" example:"05-2024" format:"string"`   // Last month of the window in MM-YYYY format
+	Users      []ExpiringUser `json:"users"`                                  // Users with subscriptions ending in the window, ordered by user ID
+	TotalCount int            `json:"total_count" example:"12" format:"int"`  // Subscriptions over all users
+}
+
+type ExpiringUser struct {
+	UserID        uuid.UUID              `json:"user_id" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"` // User UUID
+	Subscriptions []SubscriptionResponse `json:"subscriptions"`                                                        // Subscriptions of the user ending in the window, soonest first
+}
+
+type CreateViewRequest struct {
+	Name          string  `json:"name" example:"Streaming" format:"string"`                                   // Name of the view, unique per user
+	ServiceName   *string `json:"service_name,omitempty" example:"Netflix" format:"string"`                   // (Optional) Filter by service name
+	CreatedAfter  *string `json:"created_after,omitempty" example:"2024-01-01T00:00:00Z" format:"date-time"`  // (Optional) Only records created after this RFC3339 timestamp
+	CreatedBefore *string `json:"created_before,omitempty" example:"2024-12-31T23:59:59Z" format:"date-time"` // (Optional) Only records created before this RFC3339 timestamp
+	MinPrice      *int    `json:"min_price,omitempty" example:"500" format:"int"`                             // (Optional) Only subscriptions costing at least this much
+	MaxPrice      *int    `json:"max_price,omitempty" example:"1000" format:"int"`                            // (Optional) Only subscriptions costing at most this much
+	SortBy        *string `json:"sort_by,omitempty" example:"price" format:"string"`                          // (Optional) price, start_date, service_name or created_at
+	Order         *string `json:"order,omitempty" example:"desc" format:"string"`                             // (Optional) asc or desc
+	Limit         *int    `json:"limit,omitempty" example:"50" format:"int"`                                  // (Optional) Limit the number of results
+}
+
+func (req *CreateViewRequest) Validate() error {
+	var errs fieldErrors
+	if strings.TrimSpace(req.Name) == "" {
+		errs.add("name", CodeRequired, "view name is required")
+	}
+	if req.ServiceName != nil && strings.TrimSpace(*req.ServiceName) == "" {
+		errs.add("service_name", CodeRequired, "service name cannot be empty")
+	}
+	var after, before time.Time
+	var err error
+	if req.CreatedAfter != nil {
+		if after, err = time.Parse(time.RFC3339, *req.CreatedAfter); err != nil {
+			errs.add("created_after", CodeInvalidDate, "created_after must be an RFC3339 timestamp")
+		}
+	}
+	if req.CreatedBefore != nil {
+		if before, err = time.Parse(time.RFC3339, *req.CreatedBefore); err != nil {
+			errs.add("created_before", CodeInvalidDate, "created_before must be an RFC3339 timestamp")
+		}
+	}
+	if req.CreatedAfter != nil && req.CreatedBefore != nil && !errs.has("created_after") && !errs.has("created_before") && !after.Before(before) {
+		errs.add("created_before", CodeEndBeforeStart, "created_after must precede created_before")
+	}
+	if req.MinPrice != nil && *req.MinPrice < 0 {
+		errs.add("min_price", CodeMustNotBeNegative, "min_price cannot be negative")
+	}
+	if req.MaxPrice != nil && *req.MaxPrice < 0 {
+		errs.add("max_price", CodeMustNotBeNegative, "max_price cannot be negative")
+	}
+	if req.MinPrice != nil && req.MaxPrice != nil && !errs.has("min_price") && !errs.has("max_price") && *req.MinPrice > *req.MaxPrice {
+		errs.add("max_price", CodeEndBeforeStart, "min_price cannot exceed max_price")
+	}
+	if req.SortBy != nil {
+		switch *req.SortBy {
+		case models.SortByCreatedAt, models.SortByPrice, models.SortByStartDate, models.SortByServiceName:
+		default:
+			errs.add("sort_by", CodeInvalidValue, "sort_by must be one of created_at, price, start_date, service_name")
+		}
+	}
+	if req.Order != nil && *req.Order != models.OrderAsc && *req.Order != models.OrderDesc {
+		errs.add("order", CodeInvalidValue, "order must be asc or desc")
+	}
+	if req.Limit != nil && *req.Limit <= 0 {
+		errs.add("limit", CodeMustBePositive, "limit must be above zero")
+	}
+	return errs.err()
+}
+
+type BatchCreateResponse struct {
+	Created int               `json:"created" example:"2" format:"int"` // Subscriptions created
+	Failed  int               `json:"failed" example:"1" format:"int"`  // Items rejected by validation
+	Results []BatchItemResult `json:"results"`                          // Outcome of every item in request order
+}
+
+type BatchItemResult struct {
+	Status       int                   `json:"status" example:"201" format:"int"`                         // 201 if created, 400 if invalid, 409 if a duplicate
+	Subscription *SubscriptionResponse `json:"subscription,omitempty"`                                    // Created subscription
+	Error        string                `json:"error,omitempty" example:"Validation error: invalid price"` // Why the item was rejected
+	Code         string                `json:"code,omitempty" example:"VALIDATION_ERROR"`                 // (Optional) Code of the error, as in ErrorResponse
+	Fields       []FieldError          `json:"fields,omitempty"`                                          // (Optional) Invalid fields of the item
+}
+
+// Validations trusted imports may skip, see ImportSubscriptionsQuery
+const (
+	RelaxHistoricalStart = "historical_start" // start_date outside app.limits.start_date_window_years
+	RelaxLongDuration    = "long_duration"    // Longer than app.limits.subscription_max_years
+	RelaxMonthNames      = "month_names"      // Dates like "Jan 2024" or "январь 2024" instead of MM-YYYY, see dates.ParseMonth
+)
+
+type ImportSubscriptionsQuery struct {
+	Allow []string `form:"allow" collection_format:"csv" binding:"dive,oneof=historical_start long_duration month_names" example:"historical_start,long_duration"` // (Optional) Validations to skip, comma-separated
+}
+
+type ParseDateRequest struct {
+	Date string `json:"date" binding:"required" example:"январь 2024" format:"string"` // Month in MM-YYYY or human-style form, e.g. "Jan 2024", "января 2024 г.", "01.2024"
+}
+
+type ParseDateResponse struct {
+	Date string `json:"date" example:"01-2024" format:"string"` // The month in MM-YYYY format
+}
+
+type ImportSubscriptionsRequest struct {
+	Subscriptions []CreateSubscriptionRequest `json:"subscriptions" binding:"required,min=1,max=1000"` // Subscriptions to create, all or none
+}
+
+type ImportSubscriptionsResponse struct {
+	IDs []uuid.UUID `json:"ids"` // UUIDs of created subscriptions in request order
+}
+
+type SyncSubscriptionsRequest struct {
+	Subscriptions []SyncSubscriptionItem `json:"subscriptions" binding:"required"` // Full desired set of user's subscriptions managed by sync, may be empty
+}
+
+type SyncSubscriptionItem struct {
+	ExternalID  string  `json:"external_id" example:"crm-42" format:"string"`                  // ID in the client's system, matches desired and existing subscriptions
+	ServiceName string  `json:"service_name" example:"Telegram Premium" format:"string"`       // Name of the service
+	Price       int     `json:"price" example:"299" format:"int"`                              // Price in rubles
+	StartDate   string  `json:"start_date" example:"01-2026" format:"string"`                  // Start date in MM-YYYY format
+	EndDate     *string `json:"end_date,omitempty" example:"02-2026" format:"string"`          // (Optional) End date in MM-YYYY format
+	Type        string  `json:"type,omitempty" example:"recurring" enums:"recurring,one_time"` // (Optional) Subscription type, "recurring" by default
+}
+
+func (req *SyncSubscriptionsRequest) Validate() error {
+	var errs fieldErrors
+	seen := make(map[string]bool, len(req.Subscriptions))
+	for i, item := range req.Subscriptions {
+		field := fmt.Sprintf("subscriptions[%d].external_id", i)
+		id := strings.TrimSpace(item.ExternalID)
+		if id == "" {
+			errs.add(field, CodeRequired, "subscriptions[%d]: external ID is required", i)
+			continue
+		}
+		if seen[id] {
+			errs.add(field, CodeDuplicate, "subscriptions[%d]: duplicate external ID %q", i, id)
+		}
+		seen[id] = true
+	}
+	return errs.err()
+}
+
+// CreateRequest turns the item into a regular create payload of given user, so it's validated the same way
+func (item *SyncSubscriptionItem) CreateRequest(userID string) *CreateSubscriptionRequest {
+	externalID := strings.TrimSpace(item.ExternalID)
+	price := item.Price
+	return &CreateSubscriptionRequest{
+		ExternalID:  &externalID,
+		ServiceName: item.ServiceName,
+		Price:       &price,
+		UserID:      userID,
+		StartDate:   item.StartDate,
+		EndDate:     item.EndDate,
+		Type:        item.Type,
+	}
+}
+
+type BulkUpdateSubscriptionsRequest struct {
+	ServiceNameFilter string `json:"service_name_filter" example:"Netflix*" format:"string"` // Service name matched case-insensitively, * is any characters
+	NewPrice          *int   `json:"new_price" example:"799" format:"int"`                   // New monthly price in rubles
+}
+
+func (req *BulkUpdateSubscriptionsRequest) Validate() error {
+	var errs fieldErrors
+	if strings.Trim(req.ServiceNameFilter, " *") == "" { // Catch-all filter is more likely a mistake than intent
+		errs.add("service_name_filter", CodeRequired, "service name filter is required")
+	}
+	if req.NewPrice == nil {
+		errs.add("new_price", CodeRequired, "new price is required")
+	} else if *req.NewPrice < 0 {
+		errs.add("new_price", CodeMustNotBeNegative, "price cannot be negative")
+	}
+	return errs.err()
+}
+
+type BulkUpdateSubscriptionsResponse struct {
+	Updated int                    `json:"updated" example:"3" format:"int"` // Subscriptions that got the new price, ones already at it or ended are left alone
+	Items   []SubscriptionResponse `json:"items"`                            // Updated subscriptions with the new price
+}
+
+type SyncSubscriptionsQuery struct {
+	DryRun bool `form:"dry_run" example:"true" format:"bool"` // (Optional) Only return the plan, don't apply it
+}
+
+type SyncSubscriptionsResponse struct {
+	DryRun    bool         `json:"dry_run" example:"false" format:"bool"` // True if changes were only planned
+	Changes   []SyncChange `json:"changes"`                               // Planned or applied changes, creates first, then updates and deletes
+	Unchanged int          `json:"unchanged" example:"3" format:"int"`    // Subscriptions already matching the desired state
+}
+
+type SyncChange struct {
+	Action     string                `json:"action" example:"update" enums:"create,update,delete"` // What sync does with the subscription
+	ExternalID string                `json:"external_id" example:"crm-42" format:"string"`         // ID in the client's system
+	Before     *SubscriptionResponse `json:"before,omitempty"`                                     // (Optional) Current state, absent for create
+	After      *SubscriptionResponse `json:"after,omitempty"`                                      // (Optional) Desired state, absent for delete
 }
 
 type TotalCostRequest struct {
-	UserID      string `form:"user_id" binding:"omitempty,uuid" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"` // Filter by user UUID
-	ServiceName string `form:"service_name" example:"Telegram Premium" format:"string"`                                       // Filter by service name
-	StartDate   string `form:"start_date" binding:"required" example:"01-2024" format:"string"`                               // Start date in MM-YYYY format
-	EndDate     string `form:"end_date" binding:"required" example:"12-2024" format:"string"`                                 // End date in MM-YYYY format
+	UserID          string `form:"user_id" binding:"omitempty,uuid" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"` // Filter by user UUID
+	ServiceName     string `form:"service_name" example:"Telegram Premium" format:"string"`                                       // Filter by service name
+	ServiceNameCI   bool   `form:"service_name_ci" example:"true" format:"bool"`                                                  // (Optional) Match service_name case-insensitively
+	ServiceNameLike string `form:"service_name_like" example:"net*" format:"string"`                                              // (Optional) Case-insensitive match, * is any characters, without * a substring
+	StartDate       string `form:"start_date" binding:"required" example:"01-2024" format:"string"`                               // Start date in MM-YYYY format
+	EndDate         string `form:"end_date" binding:"required" example:"12-2024" format:"string"`                                 // End date in MM-YYYY format
+	GroupBy         string `form:"group_by" binding:"omitempty,oneof=service_name month" example:"service_name" format:"string"`  // (Optional) Also return breakdown by service_name or month
 }
 
+// Values of TotalCostRequest.GroupBy
+const (
+	GroupByServiceName = "service_name"
+	GroupByMonth       = "month"
+)
+
 type TotalCostResponse struct {
-	TotalCost int64 `json:"total_cost" example:"3600" format:"int"` // Total cost in y.e.
+	TotalCost          int64               `json:"total_cost" example:"3600" format:"int"`                 // Total cost in y.e.
+	FormattedTotalCost string              `json:"formatted_total_cost" example:"3 600 ₽" format:"string"` // Total cost in the display locale and currency
+	Breakdown          []CostBreakdownItem `json:"breakdown,omitempty"`                                    // (Optional) Total split by group_by, sums up to total_cost
+}
+
+// Formatted returns a copy with formatted fields rendered for p, the response itself may be cached and is left as is
+func (r *TotalCostResponse) Formatted(p display.Prefs) *TotalCostResponse {
+	out := *r
+	out.FormattedTotalCost = p.Price(r.TotalCost)
+	if r.Breakdown != nil {
+		out.Breakdown = make([]CostBreakdownItem, len(r.Breakdown))
+		for i, item := range r.Breakdown {
+			item.FormattedCost = p.Price(item.Cost)
+			if month, err := dates.String2Date(item.Month); err == nil {
+				item.FormattedMonth = p.Month(month)
+			}
+			out.Breakdown[i] = item
+		}
+	}
+	return &out
+}
+
+type CostBreakdownItem struct {
+	ServiceName    string `json:"service_name,omitempty" example:"Netflix" format:"string"`      // (Optional) Service, when grouped by service_name
+	Month          string `json:"month,omitempty" example:"03-2024" format:"string"`             // (Optional) Month in MM-YYYY format, when grouped by month
+	Months         int    `json:"months" example:"12" format:"int"`                              // Subscription-months counted in the group
+	Cost           int64  `json:"cost" example:"2400" format:"int"`                              // Cost of the group in y.e., credits included
+	FormattedMonth string `json:"formatted_month,omitempty" example:"март 2024" format:"string"` // (Optional) Month with its localized name, when grouped by month
+	FormattedCost  string `json:"formatted_cost" example:"2 400 ₽" format:"string"`              // Cost in the display locale and currency
+}
+
+type BatchTotalCostRequest struct {
+	UserIDs         []string `json:"user_ids" binding:"required,dive,uuid" example:"550e8400-e29b-41d4-a716-446655440000"` // User UUIDs, up to 1000
+	ServiceName     string   `json:"service_name,omitempty" example:"Telegram Premium" format:"string"`                    // (Optional) Filter by service name
+	ServiceNameCI   bool     `json:"service_name_ci,omitempty" example:"true" format:"bool"`                               // (Optional) Match service_name case-insensitively
+	ServiceNameLike string   `json:"service_name_like,omitempty" example:"net*" format:"string"`                           // (Optional) Case-insensitive match, * is any characters, without * a substring
+	StartDate       string   `json:"start_date" binding:"required" example:"01-2024" format:"string"`                      // Start date in MM-YYYY format
+	EndDate         string   `json:"end_date" binding:"required" example:"12-2024" format:"string"`                        // End date in MM-YYYY format
+}
+
+type BatchTotalCostResponse struct {
+	Totals []UserTotalCost `json:"totals"` // One per requested user, in request order
+}
+
+type UserTotalCost struct {
+	UserID    uuid.UUID `json:"user_id" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"` // User UUID
+	TotalCost int64     `json:"total_cost" example:"3600" format:"int"`                               // Total cost in y.e., 0 if user has no subscriptions in period
+}
+
+type CostExplanationResponse struct {
+	TotalCost          int64                 `json:"total_cost" example:"2600" format:"int"`                     // Sum of item amounts, same as GET /subscriptions/total
+	StartDate          string                `json:"start_date" example:"01-2024" format:"string"`               // Requested period start in MM-YYYY format
+	EndDate            string                `json:"end_date" example:"12-2024" format:"string"`                 // Requested period end in MM-YYYY format
+	Items              []CostExplanationItem `json:"items"`                                                      // Contributing subscriptions
+	FormattedTotalCost string                `json:"formatted_total_cost" example:"2 600 ₽" format:"string"`     // Total cost in the display locale and currency
+	FormattedStartDate string                `json:"formatted_start_date" example:"январь 2024" format:"string"` // Period start with its localized month name
+	FormattedEndDate   string                `json:"formatted_end_date" example:"декабрь 2024" format:"string"`  // Period end with its localized month name
+}
+
+// Formatted returns a copy with formatted fields rendered for p, the response itself may be cached and is left as is
+func (r *CostExplanationResponse) Formatted(p display.Prefs) *CostExplanationResponse {
+	out := *r
+	out.FormattedTotalCost = p.Price(r.TotalCost)
+	if start, err := dates.String2Date(r.StartDate); err == nil {
+		out.FormattedStartDate = p.Month(start)
+	}
+	if end, err := dates.String2Date(r.EndDate); err == nil {
+		out.FormattedEndDate = p.Month(end)
+	}
+	out.Items = make([]CostExplanationItem, len(r.Items))
+	for i, item := range r.Items {
+		item.FormattedPrice = p.Price(int64(item.Price))
+		item.FormattedAmount = p.Price(item.Amount)
+		out.Items[i] = item
+	}
+	return &out
+}
+
+type CostExplanationItem struct {
+	SubscriptionID  uuid.UUID `json:"subscription_id" example:"beef4269-0a1b-0c1F-afce-e13873b7b23b" format:"uuid"` // UUID of subscription
+	ServiceName     string    `json:"service_name" example:"Netflix" format:"string"`                               // Name of the service
+	Price           int       `json:"price" example:"200" format:"int"`                                             // Monthly price applied
+	From            string    `json:"from" example:"06-2024" format:"string"`                                       // First counted month (subscription clipped to period)
+	To              string    `json:"to" example:"12-2024" format:"string"`                                         // Last counted month (subscription clipped to period)
+	Months          int       `json:"months" example:"7" format:"int"`                                              // Number of months counted
+	Credits         int64     `json:"credits,omitempty" example:"-200" format:"int"`                                // (Optional) Credits applied within counted months, negative
+	Paused          int64     `json:"paused,omitempty" example:"-400" format:"int"`                                 // (Optional) Price and credits of paused months taken off, negative
+	Amount          int64     `json:"amount" example:"1200" format:"int"`                                           // Months × price + credits + paused
+	FormattedPrice  string    `json:"formatted_price" example:"200 ₽" format:"string"`                              // Price in the display locale and currency
+	FormattedAmount string    `json:"formatted_amount" example:"1 200 ₽" format:"string"`                           // Amount in the display locale and currency
+}
+
+type UserSummaryResponse struct {
+	UserID               uuid.UUID `json:"user_id" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"` // User UUID
+	ActiveSubscriptions  int       `json:"active_subscriptions" example:"3" format:"int"`                        // Subscriptions active in the current month
+	MonthlyCost          int64     `json:"monthly_cost" example:"1097" format:"int"`                             // Sum of prices of active recurring subscriptions, before credits
+	MostExpensiveService *string   `json:"most_expensive_service,omitempty" example:"Netflix" format:"string"`   // (Optional) Active recurring subscription with the highest price, absent if none
+	EarliestStartDate    *string   `json:"earliest_start_date,omitempty" example:"01-2022" format:"string"`      // (Optional) Earliest start of any subscription in MM-YYYY format, absent if user has none
+	LatestStartDate      *string   `json:"latest_start_date,omitempty" example:"06-2025" format:"string"`        // (Optional) Latest start of any subscription in MM-YYYY format, absent if user has none
+	FormattedMonthlyCost string    `json:"formatted_monthly_cost" example:"1 097 ₽" format:"string"`             // Monthly cost in the display locale and currency
+}
+
+// Formatted returns a copy with formatted fields rendered for p
+func (r *UserSummaryResponse) Formatted(p display.Prefs) *UserSummaryResponse {
+	out := *r
+	out.FormattedMonthlyCost = p.Price(r.MonthlyCost)
+	return &out
+}
+
+type SuggestServicesRequest struct {
+	Query  string `form:"q" binding:"required" example:"net" format:"string"`                                            // Service name prefix (case-insensitive)
+	UserID string `form:"user_id" binding:"omitempty,uuid" example:"550e8400-e29b-41d4-a716-446655440000" format:"uuid"` // (Optional) Only suggest services of this user
+	Limit  *int   `form:"limit" binding:"omitempty,min=1,max=50" example:"10" format:"int"`                              // (Optional) Maximum number of suggestions, 10 by default
+}
+
+type SuggestServicesResponse struct {
+	Services []string `json:"services" example:"Netflix,Netflix Premium"` // Distinct matching service names in alphabetical order
+}
+
+type StatusResponse struct {
+	Status     string            `json:"status" example:"up" format:"string"` // Overall status, "up" only if every component is up
+	Components []ComponentStatus `json:"components"`                          // Per-dependency status
+}
+
+type ComponentStatus struct {
+	Name        string     `json:"name" example:"postgres" format:"string"`                                   // Dependency name
+	Status      string     `json:"status" example:"up" format:"string"`                                       // "up", "down" or "schema_outdated"
+	LatencyMs   int64      `json:"latency_ms" example:"3" format:"int"`                                       // Duration of the last check in milliseconds
+	CheckedAt   time.Time  `json:"checked_at" example:"2026-01-01T12:00:00Z" format:"date-time"`              // Time of the last check
+	LastError   string     `json:"last_error,omitempty" example:"connection refused" format:"string"`         // (Optional) Last observed error, kept after recovery
+	LastErrorAt *time.Time `json:"last_error_at,omitempty" example:"2026-01-01T11:58:00Z" format:"date-time"` // (Optional) Time of the last observed error
 }
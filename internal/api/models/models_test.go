@@ -2,6 +2,19 @@ package models
 
 import (
 	"testing"
+
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+
+	"subscription-aggregator-service/internal/config"
+	"subscription-aggregator-service/internal/models"
 )
 
 func TestCreateSubscriptionRequest_Validate(t *testing.T) {
@@ -14,7 +27,7 @@ func TestCreateSubscriptionRequest_Validate(t *testing.T) {
 			name: "valid request",
 			req: CreateSubscriptionRequest{
 				ServiceName: "Test Service",
-				Price:       299,
+				Price:       intPtr(299),
 				UserID:      "550e8400-e29b-41d4-a716-446655440000",
 				StartDate:   "01-2024",
 			},
@@ -24,18 +37,40 @@ func TestCreateSubscriptionRequest_Validate(t *testing.T) {
 			name: "valid request with end date",
 			req: CreateSubscriptionRequest{
 				ServiceName: "Test Service",
-				Price:       299,
+				Price:       intPtr(299),
 				UserID:      "550e8400-e29b-41d4-a716-446655440000",
 				StartDate:   "01-2024",
 				EndDate:     strPtr("12-2024"),
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid request with client-supplied id",
+			req: CreateSubscriptionRequest{
+				ID:          strPtr("beef4269-0a1b-0c1f-afce-e13873b7b23b"),
+				ServiceName: "Test Service",
+				Price:       intPtr(299),
+				UserID:      "550e8400-e29b-41d4-a716-446655440000",
+				StartDate:   "01-2024",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid client-supplied id",
+			req: CreateSubscriptionRequest{
+				ID:          strPtr("not-a-uuid"),
+				ServiceName: "Test Service",
+				Price:       intPtr(299),
+				UserID:      "550e8400-e29b-41d4-a716-446655440000",
+				StartDate:   "01-2024",
+			},
+			wantErr: true,
+		},
 		{
 			name: "empty service name",
 			req: CreateSubscriptionRequest{
 				ServiceName: "",
-				Price:       299,
+				Price:       intPtr(299),
 				UserID:      "550e8400-e29b-41d4-a716-446655440000",
 				StartDate:   "01-2024",
 			},
@@ -45,17 +80,17 @@ func TestCreateSubscriptionRequest_Validate(t *testing.T) {
 			name: "zero price",
 			req: CreateSubscriptionRequest{
 				ServiceName: "Test",
-				Price:       0,
+				Price:       intPtr(0),
 				UserID:      "550e8400-e29b-41d4-a716-446655440000",
 				StartDate:   "01-2024",
 			},
-			wantErr: true,
+			wantErr: false,
 		},
 		{
 			name: "negative price",
 			req: CreateSubscriptionRequest{
 				ServiceName: "Test",
-				Price:       -100,
+				Price:       intPtr(-100),
 				UserID:      "550e8400-e29b-41d4-a716-446655440000",
 				StartDate:   "01-2024",
 			},
@@ -65,7 +100,7 @@ func TestCreateSubscriptionRequest_Validate(t *testing.T) {
 			name: "empty user id",
 			req: CreateSubscriptionRequest{
 				ServiceName: "Test",
-				Price:       299,
+				Price:       intPtr(299),
 				UserID:      "",
 				StartDate:   "01-2024",
 			},
@@ -75,7 +110,7 @@ func TestCreateSubscriptionRequest_Validate(t *testing.T) {
 			name: "invalid user id",
 			req: CreateSubscriptionRequest{
 				ServiceName: "Test",
-				Price:       299,
+				Price:       intPtr(299),
 				UserID:      "not-a-uuid",
 				StartDate:   "01-2024",
 			},
@@ -85,7 +120,7 @@ func TestCreateSubscriptionRequest_Validate(t *testing.T) {
 			name: "empty start date",
 			req: CreateSubscriptionRequest{
 				ServiceName: "Test",
-				Price:       299,
+				Price:       intPtr(299),
 				UserID:      "550e8400-e29b-41d4-a716-446655440000",
 				StartDate:   "",
 			},
@@ -95,7 +130,7 @@ func TestCreateSubscriptionRequest_Validate(t *testing.T) {
 			name: "invalid start date format",
 			req: CreateSubscriptionRequest{
 				ServiceName: "Test",
-				Price:       299,
+				Price:       intPtr(299),
 				UserID:      "550e8400-e29b-41d4-a716-446655440000",
 				StartDate:   "2024-01",
 			},
@@ -105,7 +140,7 @@ func TestCreateSubscriptionRequest_Validate(t *testing.T) {
 			name: "end date before start date",
 			req: CreateSubscriptionRequest{
 				ServiceName: "Test",
-				Price:       299,
+				Price:       intPtr(299),
 				UserID:      "550e8400-e29b-41d4-a716-446655440000",
 				StartDate:   "06-2024",
 				EndDate:     strPtr("01-2024"),
@@ -127,6 +162,57 @@ func TestCreateSubscriptionRequest_Validate(t *testing.T) {
 	}
 }
 
+func TestValidationErrorFields(t *testing.T) {
+	req := CreateSubscriptionRequest{UserID: "550e8400-e29b-41d4-a716-446655440000", StartDate: "13-2024", EndDate: strPtr("01-2024"), Type: "weekly"}
+	err := req.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Validate() error = %v, want *ValidationError", err)
+	}
+	var got []string
+	for _, f := range verr.Fields {
+		got = append(got, f.Field+":"+f.Code)
+	}
+	want := []string{"service_name:REQUIRED", "start_date:INVALID_DATE_FORMAT", "type:INVALID_VALUE"} // End date isn't compared with an invalid start
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+	if !strings.Contains(err.Error(), "service name is required; start date: invalid date format") {
+		t.Errorf("Error() = %q, want messages joined", err.Error())
+	}
+
+	wrapped := fmt.Errorf("wrapped: %w", (&SyncSubscriptionsRequest{Subscriptions: []SyncSubscriptionItem{{ExternalID: "a"}, {ExternalID: " a "}}}).Validate())
+	NestFields(wrapped, "batch")
+	if resp := NewErrorResponse(wrapped); len(resp.Fields) != 1 || resp.Fields[0].Field != "batch.subscriptions[1].external_id" || resp.Fields[0].Code != CodeDuplicate {
+		t.Errorf("NewErrorResponse().Fields = %+v, want nested duplicate external ID", resp.Fields)
+	}
+	if resp := NewErrorResponse(errors.New("boom")); resp.Fields != nil {
+		t.Errorf("NewErrorResponse() of a plain error has fields %+v", resp.Fields)
+	}
+}
+
+func TestErrorResponseCodes(t *testing.T) {
+	notFound := NewError(CodeSubscriptionNotFound, "Subscription not found")
+	validation := NewError(CodeValidation, "Validation error")
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "coded", err: notFound, want: CodeSubscriptionNotFound},
+		{name: "wrapped", err: fmt.Errorf("%w: id", notFound), want: CodeSubscriptionNotFound},
+		{name: "validation", err: fmt.Errorf("%w: %w", validation, (&CreateSubscriptionRequest{}).Validate()), want: CodeValidation},
+		{name: "bare validation", err: (&CreateSubscriptionRequest{}).Validate(), want: CodeValidation},
+		{name: "uncoded", err: errors.New("boom"), want: CodeInternal},
+	}
+
+	for _, tt := range tests {
+		if got := NewErrorResponse(tt.err); got.Code != tt.want || got.Error != tt.err.Error() {
+			t.Errorf("%s: NewErrorResponse() = %+v, want code %s", tt.name, got, tt.want)
+		}
+	}
+}
+
 func TestUpdateSubscriptionRequest_Validate(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -171,7 +257,7 @@ func TestUpdateSubscriptionRequest_Validate(t *testing.T) {
 			req: UpdateSubscriptionRequest{
 				Price: intPtr(0),
 			},
-			wantErr: true,
+			wantErr: false,
 		},
 		{
 			name: "negative price",
@@ -382,6 +468,103 @@ func TestUpdateSubscriptionRequest_ParseDates(t *testing.T) {
 	}
 }
 
+var snakeCase = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+// TestJSONTagsAreSnakeCase guards the public JSON contract: every exported field of a
+// serialized struct must carry an explicit snake_case json tag (or be explicitly hidden)
+func TestJSONTagsAreSnakeCase(t *testing.T) {
+	types := []any{
+		models.Subscription{},
+		models.SavedView{},
+		ErrorResponse{},
+		CreateSubscriptionRequest{},
+		CreateSubscriptionResponse{},
+		SubscriptionResponse{},
+		UpdateSubscriptionRequest{},
+		TotalCostResponse{},
+		CostExplanationResponse{},
+		CostExplanationItem{},
+		SuggestServicesResponse{},
+		CreateViewRequest{},
+		StatusResponse{},
+		ComponentStatus{},
+	}
+
+	for _, v := range types {
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			tag, ok := field.Tag.Lookup("json")
+			if !ok {
+				t.Errorf("%s.%s: missing json tag", typ.Name(), field.Name)
+				continue
+			}
+			name := strings.Split(tag, ",")[0]
+			if name == "-" {
+				continue
+			}
+			if !snakeCase.MatchString(name) {
+				t.Errorf("%s.%s: json tag %q is not snake_case", typ.Name(), field.Name, name)
+			}
+		}
+	}
+}
+
+func TestNewSubscriptionResponse(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	sub := &models.Subscription{
+		ID:          uuid.New(),
+		ServiceName: "Netflix",
+		Price:       299,
+		Type:        models.TypeRecurring,
+		UserID:      uuid.New(),
+		StartDate:   start,
+		EndDate:     &end,
+		Version:     3,
+	}
+
+	resp := NewSubscriptionResponse(sub, SerializationV2())
+	if resp.ID != sub.ID || resp.UserID != sub.UserID || resp.ServiceName != "Netflix" || resp.Price != 299 || resp.Version != 3 {
+		t.Errorf("NewSubscriptionResponse() = %+v, fields not copied from %+v", resp, sub)
+	}
+	if resp.StartDate != "01-2020" {
+		t.Errorf("StartDate = %q, want %q", resp.StartDate, "01-2020")
+	}
+	if resp.EndDate == nil || *resp.EndDate != "03-2021" {
+		t.Errorf("EndDate = %v, want %q", resp.EndDate, "03-2021")
+	}
+	if resp.Status != models.StatusExpired {
+		t.Errorf("Status = %q, want %q", resp.Status, models.StatusExpired)
+	}
+
+	sub.EndDate = nil
+	if resp = NewSubscriptionResponse(sub, SerializationV2()); resp.EndDate != nil || resp.Status != models.StatusActive {
+		t.Errorf("open-ended NewSubscriptionResponse() end_date = %v, status = %q, want none and %q", resp.EndDate, resp.Status, models.StatusActive)
+	}
+
+	// v1 keeps RFC3339 timestamps unless MM-YYYY is asked for
+	if resp = NewSubscriptionResponse(sub, SerializationV1()); resp.StartDate != "2020-01-01T00:00:00Z" {
+		t.Errorf("v1 StartDate = %q, want %q", resp.StartDate, "2020-01-01T00:00:00Z")
+	}
+	pause := NewPausedPeriodResponse(&models.PausedPeriod{StartDate: start, EndDate: &end}, SerializationV1())
+	if pause.StartDate != "2020-01-01T00:00:00Z" || pause.EndDate == nil || *pause.EndDate != "2021-03-01T00:00:00Z" {
+		t.Errorf("v1 pause = %s - %v, want RFC3339 timestamps", pause.StartDate, pause.EndDate)
+	}
+	viper.Set(config.ApiDateFormat, config.DateFormatMonth)
+	t.Cleanup(func() { viper.Set(config.ApiDateFormat, nil) })
+	if resp = NewSubscriptionResponse(sub, SerializationV1()); resp.StartDate != "01-2020" {
+		t.Errorf("v1 StartDate with %s = %q, want %q", config.DateFormatMonth, resp.StartDate, "01-2020")
+	}
+
+	if list := NewSubscriptionResponses(nil, SerializationV1()); list == nil || len(list) != 0 {
+		t.Errorf("NewSubscriptionResponses(nil, SerializationV1()) = %#v, want empty slice", list)
+	}
+}
+
 func strPtr(s string) *string {
 	return &s
 }
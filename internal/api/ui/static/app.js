@@ -0,0 +1,197 @@
+"use strict";
+
+const apiBase = document.body.dataset.apiBase;
+const subscriptionsBody = document.querySelector("#subscriptions tbody");
+const totalsBody = document.querySelector("#totals tbody");
+const filters = document.getElementById("filters");
+const editor = document.getElementById("editor");
+const errorBox = document.getElementById("error");
+const undoBox = document.getElementById("undo");
+
+async function api(method, path, body, headers = {}) {
+    const resp = await fetch(apiBase + path, {
+        method,
+        headers: body ? {"Content-Type": "application/json", ...headers} : headers,
+        body: body ? JSON.stringify(body) : undefined,
+    });
+    if (resp.status === 204) {
+        return null;
+    }
+    const data = await resp.json();
+    if (!resp.ok) {
+        throw new Error(data.error || resp.statusText);
+    }
+    return data;
+}
+
+function monthOf(d) {
+    return String(d.getUTCMonth() + 1).padStart(2, "0") + "-" + d.getUTCFullYear();
+}
+
+// API renders months as MM-YYYY, or as RFC3339 timestamps with app.api.date_format: rfc3339, and accepts MM-YYYY
+function toMonth(value) {
+    if (!value) {
+        return "";
+    }
+    if (/^\d{2}-\d{4}$/.test(value)) {
+        return value;
+    }
+    return monthOf(new Date(value));
+}
+
+function filterQuery() {
+    const params = new URLSearchParams();
+    for (const [key, value] of new FormData(filters)) {
+        if (value.trim() !== "") {
+            params.set(key, value.trim());
+        }
+    }
+    return params;
+}
+
+function showError(err) {
+    errorBox.textContent = err ? err.message : "";
+    errorBox.hidden = !err;
+}
+
+function cell(text) {
+    const td = document.createElement("td");
+    td.textContent = text;
+    return td;
+}
+
+function button(label, onClick) {
+    const b = document.createElement("button");
+    b.type = "button";
+    b.textContent = label;
+    b.addEventListener("click", onClick);
+    return b;
+}
+
+async function loadSubscriptions() {
+    const subs = (await api("GET", "/subscriptions?" + filterQuery())).items;
+    subscriptionsBody.replaceChildren(...subs.map(sub => {
+        const tr = document.createElement("tr");
+        tr.append(cell(sub.service_name), cell(sub.price), cell(sub.user_id), cell(toMonth(sub.start_date)), cell(toMonth(sub.end_date)));
+        const actions = cell("");
+        actions.className = "actions";
+        actions.append(
+            button("Edit", () => edit(sub)),
+            button("Delete", () => remove(sub)),
+        );
+        tr.append(actions);
+        return tr;
+    }));
+}
+
+async function loadTotals() {
+    const now = new Date();
+    const months = [];
+    for (let i = 11; i >= 0; i--) {
+        const d = new Date(Date.UTC(now.getUTCFullYear(), now.getUTCMonth() - i, 1));
+        months.push(monthOf(d));
+    }
+    const totals = await Promise.all(months.map(month => {
+        const params = filterQuery();
+        params.set("start_date", month);
+        params.set("end_date", month);
+        return api("GET", "/subscriptions/total?" + params);
+    }));
+    totalsBody.replaceChildren(...months.map((month, i) => {
+        const tr = document.createElement("tr");
+        tr.append(cell(month), cell(totals[i].total_cost));
+        return tr;
+    }));
+}
+
+async function refresh() {
+    try {
+        await Promise.all([loadSubscriptions(), loadTotals()]);
+        showError(null);
+    } catch (err) {
+        showError(err);
+    }
+}
+
+function edit(sub) {
+    editor.elements.id.value = sub.id;
+    editor.elements.version.value = sub.version; // Sent back in If-Match, so edits of a stale copy are rejected
+    editor.elements.service_name.value = sub.service_name;
+    editor.elements.price.value = sub.price;
+    editor.elements.user_id.value = sub.user_id;
+    editor.elements.user_id.disabled = true; // Owner can't be changed
+    editor.elements.start_date.value = toMonth(sub.start_date);
+    editor.elements.end_date.value = toMonth(sub.end_date);
+    document.getElementById("form-title").textContent = "Edit subscription";
+}
+
+async function remove(sub) {
+    if (!confirm(`Delete ${sub.service_name}?`)) {
+        return;
+    }
+    try {
+        const undo = await api("DELETE", "/subscriptions/" + sub.id);
+        await refresh();
+        offerUndo(sub, undo);
+    } catch (err) {
+        showError(err);
+    }
+}
+
+// DELETE returns an undo token unless undo is disabled on the server
+function offerUndo(sub, undo) {
+    undoBox.hidden = !undo;
+    if (!undo) {
+        return;
+    }
+    undoBox.querySelector("span").textContent = `Deleted ${sub.service_name}.`;
+    undoBox.querySelector("button").onclick = async () => {
+        undoBox.hidden = true;
+        try {
+            await api("POST", "/undo/" + undo.undo_token);
+            await refresh();
+        } catch (err) {
+            showError(err);
+        }
+    };
+}
+
+editor.addEventListener("reset", () => {
+    editor.elements.id.value = ""; // Hidden inputs aren't restored by reset
+    editor.elements.version.value = "";
+    editor.elements.user_id.disabled = false;
+    document.getElementById("form-title").textContent = "New subscription";
+});
+
+editor.addEventListener("submit", async event => {
+    event.preventDefault();
+    const f = editor.elements;
+    const payload = {
+        service_name: f.service_name.value.trim(),
+        price: Number(f.price.value),
+        start_date: f.start_date.value.trim(),
+    };
+    try {
+        if (f.id.value) {
+            payload.end_date = f.end_date.value.trim(); // Empty string clears the end date
+            await api("PUT", "/subscriptions/" + f.id.value, payload, {"If-Match": `"${f.version.value}"`});
+        } else {
+            payload.user_id = f.user_id.value.trim();
+            if (f.end_date.value.trim() !== "") {
+                payload.end_date = f.end_date.value.trim();
+            }
+            await api("POST", "/subscriptions", payload);
+        }
+        editor.reset();
+        await refresh();
+    } catch (err) {
+        showError(err);
+    }
+});
+
+filters.addEventListener("submit", event => {
+    event.preventDefault();
+    refresh();
+});
+
+refresh();
@@ -0,0 +1,49 @@
+package ui
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const Path = "/ui"
+
+//go:embed static
+var static embed.FS
+
+// Register serves the embedded dashboard at /ui. The page talks to the regular API under apiBase,
+// which is injected into index.html so the UI follows the configured base path.
+func Register(e *gin.Engine, apiBase string) error {
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := template.ParseFS(files, "index.html")
+	if err != nil {
+		return err
+	}
+	var index bytes.Buffer
+	if err = tmpl.Execute(&index, struct{ APIBase string }{APIBase: strings.TrimRight(apiBase, "/")}); err != nil {
+		return err
+	}
+
+	fileServer := http.StripPrefix(Path, http.FileServer(http.FS(files)))
+	e.GET(Path, func(ctx *gin.Context) {
+		ctx.Redirect(http.StatusMovedPermanently, Path+"/")
+	})
+	e.GET(Path+"/*filepath", func(ctx *gin.Context) {
+		switch ctx.Param("filepath") {
+		case "/", "/index.html":
+			ctx.Data(http.StatusOK, "text/html; charset=utf-8", index.Bytes())
+		default:
+			fileServer.ServeHTTP(ctx.Writer, ctx.Request)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,112 @@
+package ui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRegister(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := Register(r, "/api/v1/"); err != nil {
+		t.Fatalf("Register() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		wantStatus   int
+		wantContains string
+	}{
+		{name: "redirect to trailing slash", path: "/ui", wantStatus: http.StatusMovedPermanently},
+		{name: "index with API base", path: "/ui/", wantStatus: http.StatusOK, wantContains: `data-api-base="/api/v1"`},
+		{name: "script", path: "/ui/app.js", wantStatus: http.StatusOK, wantContains: "apiBase"},
+		{name: "missing file", path: "/ui/missing.js", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("GET %s status = %d, want %d", tt.path, w.Code, tt.wantStatus)
+			}
+			if tt.wantContains != "" && !strings.Contains(w.Body.String(), tt.wantContains) {
+				t.Errorf("GET %s body does not contain %q", tt.path, tt.wantContains)
+			}
+		})
+	}
+}
+
+// domStub fakes just enough of the browser for app.js: elements by selector, fetch answering with fixed
+// subscriptions and a FormData with no filters. Listeners are kept so the test can click buttons.
+const domStub = `
+const registry = {};
+function element() {
+    return {
+        children: [], listeners: {}, dataset: {}, textContent: "", hidden: false,
+        elements: new Proxy({}, {get: (inputs, name) => inputs[name] ??= {value: "", disabled: false}}),
+        append(...c) { this.children.push(...c); },
+        replaceChildren(...c) { this.children = c; },
+        addEventListener(type, fn) { this.listeners[type] = fn; },
+        querySelector(sel) { return registry[sel] ??= element(); },
+    };
+}
+globalThis.document = {
+    body: {dataset: {apiBase: "/api/v1"}},
+    querySelector: sel => registry[sel] ??= element(),
+    getElementById: id => registry["#" + id] ??= element(),
+    createElement: () => element(),
+};
+globalThis.FormData = class { [Symbol.iterator]() { return [][Symbol.iterator](); } };
+const subscriptions = [
+    {id: "1", version: 1, service_name: "Netflix", price: 500, user_id: "u", start_date: "01-2026", end_date: null},
+    {id: "2", version: 1, service_name: "Spotify", price: 300, user_id: "u", start_date: "2026-03-01T00:00:00Z", end_date: "2026-05-01T00:00:00Z"},
+];
+globalThis.fetch = async url => ({
+    status: 200, ok: true,
+    json: async () => url.includes("/total") ? {total_cost: 0} : {items: subscriptions},
+});
+`
+
+// domCheck runs after app.js has rendered and prints the cells of the table and the edit form of the first row
+const domCheck = `
+setTimeout(() => {
+    const rows = registry["#subscriptions tbody"].children.map(tr => tr.children.slice(0, 5).map(td => String(td.textContent)));
+    registry["#subscriptions tbody"].children[0].children[5].children[0].listeners.click();
+    const form = registry["#editor"].elements;
+    console.log(JSON.stringify({rows, start: form.start_date.value, end: form.end_date.value}));
+}, 50);
+`
+
+func TestScriptRendersSubscriptions(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	app, err := static.ReadFile("static/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(t.TempDir(), "app_test.js")
+	if err = os.WriteFile(script, []byte(domStub+string(app)+domCheck), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command(node, script).CombinedOutput()
+	if err != nil {
+		t.Fatalf("node failed: %v\n%s", err, out)
+	}
+	want := `{"rows":[["Netflix","500","u","01-2026",""],["Spotify","300","u","03-2026","05-2026"]],"start":"01-2026","end":""}`
+	if got := strings.TrimSpace(string(out)); got != want {
+		t.Errorf("rendered = %s, want %s", got, want)
+	}
+}
@@ -1,29 +1,273 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+
 	"subscription-aggregator-service/internal/api"
 	"subscription-aggregator-service/internal/api/controllers"
 	"subscription-aggregator-service/internal/config"
+	"subscription-aggregator-service/internal/health"
 	"subscription-aggregator-service/internal/logger"
 	"subscription-aggregator-service/internal/service"
 	"subscription-aggregator-service/internal/storage"
+	"subscription-aggregator-service/migrations"
 	"subscription-aggregator-service/pkg/postgres"
 )
 
 type App struct {
-	API *api.API
+	API     *api.API
+	service service.SubscriptionService
+
+	configPath string
+	db         *gorm.DB
+	growth     *health.GrowthCollector
+	lifecycle  *Lifecycle
+
+	stopWorkers context.CancelFunc
+	workers     sync.WaitGroup
 }
 
-func Load() *App {
-	config.LoadConfig()
-	logger.SetupLogger()
-	db := postgres.NewInstance(config.DatabaseConfig())
-	st := storage.NewSubscriptionsStorage(db)
-	svc := service.NewSubscriptionService(st)
-	ctrl := controllers.NewSubscriptionController(svc)
-	return &App{API: api.NewAPI(ctrl)}
+// New prepares the app without loading anything, phases of its components are run by a Lifecycle
+func New(configPath string) *App {
+	return &App{configPath: configPath}
+}
+
+// Components of the app in startup order, each one needs those before it
+func (a *App) Components() []Component {
+	return []Component{
+		{Name: "config", Init: a.initConfig},
+		{Name: "logger", Init: a.initLogger},
+		{Name: "database", Init: a.initDatabase, Stop: a.stopDatabase},
+		{Name: "service", Init: a.initService},
+		{Name: "workers", Start: a.startWorkers, Stop: a.stopWorkersAndWait},
+		{Name: "api", Init: a.initAPI, Start: a.startAPI, Stop: a.stopAPI},
+	}
+}
+
+// Lifecycle of the named components in startup order, all of them if no names are given.
+// Lets tests boot a part of the app, e.g. "config", "logger", "database" and "service" without workers and API.
+func (a *App) Lifecycle(names ...string) *Lifecycle {
+	components := a.Components()
+	if len(names) == 0 {
+		return NewLifecycle(components...)
+	}
+	var picked []Component
+	for _, c := range components {
+		if slices.Contains(names, c.Name) {
+			picked = append(picked, c)
+		}
+	}
+	return NewLifecycle(picked...)
+}
+
+// Load initializes every component, the app serves nothing until Run
+func Load(configPath string) *App {
+	a := New(configPath)
+	a.lifecycle = a.Lifecycle()
+	if err := a.lifecycle.Init(context.Background()); err != nil {
+		log.Fatalf("Fatal: %v", err)
+	}
+	return a
 }
 
+// Run starts workers and then the API, and stops them in reverse on SIGINT/SIGTERM or if the API server fails
 func (a *App) Run() {
-	a.API.Run()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := a.lifecycle.Start(ctx); err != nil {
+		log.Fatalf("Fatal: %v", err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-a.API.Done():
+	}
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), viper.GetDuration(config.ApiShutdownTimeout))
+	defer cancel()
+	if err := a.lifecycle.Stop(stopCtx); err != nil {
+		log.Printf("Shutdown error: %v", err)
+	}
+}
+
+func (a *App) initConfig(context.Context) error {
+	config.LoadConfig(a.configPath)
+	return nil
+}
+
+func (a *App) initLogger(context.Context) error {
+	logger.SetupLogger()
+	return nil
+}
+
+func (a *App) initDatabase(ctx context.Context) error {
+	db, err := postgres.Open(config.DatabaseConfig())
+	if err != nil {
+		return err
+	}
+	if err = checkMigrations(ctx, db); err != nil {
+		if pool, poolErr := db.DB(); poolErr == nil {
+			_ = pool.Close() // A failed Init isn't rolled back by Stop
+		}
+		return err
+	}
+	a.db = db
+	if viper.GetDuration(config.DatabaseStatsInterval) > 0 {
+		a.growth = health.NewGrowthCollector(health.NewPostgresSizes(db), health.GrowthLimits{
+			DailyGrowthPercent: viper.GetFloat64(config.DatabaseStatsMaxDailyGrowth),
+			DeadTupleRatio:     viper.GetFloat64(config.DatabaseStatsMaxDeadRatio),
+			DatabaseBytes:      viper.GetInt64(config.DatabaseStatsMaxSizeMB) << 20,
+		})
+	}
+	return nil
+}
+
+func (a *App) stopDatabase(context.Context) error {
+	if a.db == nil {
+		return nil
+	}
+	pool, err := a.db.DB()
+	if err != nil {
+		return err
+	}
+	a.db, a.growth = nil, nil
+	return pool.Close()
+}
+
+func (a *App) initService(context.Context) error {
+	if a.db == nil {
+		return errors.New("database is not initialized")
+	}
+	pool, err := a.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get database pool: %w", err)
+	}
+	hooks, err := loadHooks()
+	if err != nil {
+		return err
+	}
+	st := storage.NewFailoverStorage(storage.NewSubscriptionsStorage(a.db), pool,
+		viper.GetInt(config.DatabaseRetryAttempts), viper.GetDuration(config.DatabaseRetryBackoff))
+	a.service = service.NewSubscriptionService(st, hooks...)
+	return nil
+}
+
+func (a *App) startWorkers(context.Context) error {
+	if a.service == nil {
+		return errors.New("service is not initialized")
+	}
+	ctx, cancel := context.WithCancel(context.Background()) // Not the start context, workers live until Stop
+	a.stopWorkers = cancel
+	if config.ReadOnly() { // Workers below write, the primary's instances run them
+		slog.Info("read-only profile, integrity checks and bulk deletes are left to the primary")
+	} else {
+		if interval := viper.GetDuration(config.IntegrityCheckInterval); interval > 0 {
+			a.workers.Go(func() { service.RunIntegrityChecks(ctx, a.service, interval) })
+		}
+		a.workers.Go(func() { service.RunBulkDeletes(ctx, a.service, time.Minute) })
+	}
+	if a.growth != nil {
+		a.workers.Go(func() { health.RunGrowthCollector(ctx, a.growth, viper.GetDuration(config.DatabaseStatsInterval)) })
+	}
+	return nil
+}
+
+// stopWorkersAndWait waits for workers to finish what they're doing, so the database isn't closed under them
+func (a *App) stopWorkersAndWait(ctx context.Context) error {
+	if a.stopWorkers == nil {
+		return nil
+	}
+	a.stopWorkers()
+	a.stopWorkers = nil
+
+	done := make(chan struct{})
+	go func() {
+		a.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("workers didn't stop in time: %w", ctx.Err())
+	}
+}
+
+func (a *App) initAPI(context.Context) error {
+	if a.service == nil {
+		return errors.New("service is not initialized")
+	}
+	monitor := health.NewMonitor(viper.GetDuration(config.ApiStatusCacheTTL), viper.GetDuration(config.ApiStatusCheckTimeout),
+		health.NewPostgresChecker(a.db), health.NewSchemaChecker(a.db))
+	a.API = api.NewAPI(controllers.NewSubscriptionController(a.service), controllers.NewHealthController(monitor, a.growth), controllers.NewAdminController(a.service),
+		controllers.NewMetricsController(a.service, viper.GetStringMapString(config.MetricsKeys)))
+	return nil
+}
+
+func (a *App) startAPI(context.Context) error {
+	return a.API.Start()
+}
+
+func (a *App) stopAPI(ctx context.Context) error {
+	if a.API == nil {
+		return nil
+	}
+	return a.API.Stop(ctx)
+}
+
+// loadHooks opens hook plugins listed in app.hooks.plugins, in order
+func loadHooks() ([]service.Hook, error) {
+	var hooks []service.Hook
+	for _, path := range viper.GetStringSlice(config.HooksPlugins) {
+		h, err := service.LoadHookPlugin(path)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("hook plugin loaded", "path", path)
+		hooks = append(hooks, h)
+	}
+	return hooks, nil
+}
+
+// checkMigrations handles migrations pending on startup according to app.database.migrations
+func checkMigrations(ctx context.Context, db *gorm.DB) error {
+	switch viper.GetString(config.DatabaseMigrations) {
+	case config.MigrationsApply:
+		applied, err := migrations.Apply(ctx, db)
+		if err != nil {
+			return fmt.Errorf("failed to apply migrations: %w", err)
+		}
+		for _, m := range applied {
+			slog.Info("migration applied", "name", m.Name)
+		}
+	case config.MigrationsRefuse:
+		pending, err := migrations.Pending(ctx, db)
+		if err != nil {
+			return fmt.Errorf("failed to check migrations: %w", err)
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("database schema is outdated, %d migrations pending, next is %s", len(pending), pending[0].Name)
+		}
+	default:
+		pending, err := migrations.Pending(ctx, db)
+		if err != nil {
+			slog.Warn("failed to check migrations", "error", err)
+		} else if len(pending) > 0 {
+			slog.Warn("database schema is outdated, serving anyway", "pending", len(pending), "next", pending[0].Name)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Component is a part of the app with explicit phases, any of them may be nil.
+// Init prepares it without doing any work, Start makes it work, Stop undoes both
+// and must cope with a component that was initialized but never started.
+type Component struct {
+	Name  string
+	Init  func(ctx context.Context) error
+	Start func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+}
+
+// Lifecycle runs phases of components in order and stops them in reverse.
+// A failed phase rolls back everything done before it, so the app is never left half up:
+// nothing starts until every component is initialized, and the API starts last.
+// Phases already done are skipped, so calling any of them twice is harmless.
+type Lifecycle struct {
+	components  []Component
+	initialized int // Number of leading components initialized
+	started     int // Number of leading components started
+}
+
+func NewLifecycle(components ...Component) *Lifecycle {
+	return &Lifecycle{components: components}
+}
+
+func (l *Lifecycle) Init(ctx context.Context) error {
+	for ; l.initialized < len(l.components); l.initialized++ {
+		c := l.components[l.initialized]
+		if c.Init == nil {
+			continue
+		}
+		if err := c.Init(ctx); err != nil {
+			return errors.Join(fmt.Errorf("failed to init %s: %w", c.Name, err), l.Stop(ctx))
+		}
+	}
+	return nil
+}
+
+// Start initializes components if it wasn't done yet and starts them
+func (l *Lifecycle) Start(ctx context.Context) error {
+	if err := l.Init(ctx); err != nil {
+		return err
+	}
+	for ; l.started < len(l.components); l.started++ {
+		c := l.components[l.started]
+		if c.Start == nil {
+			continue
+		}
+		if err := c.Start(ctx); err != nil {
+			return errors.Join(fmt.Errorf("failed to start %s: %w", c.Name, err), l.Stop(ctx))
+		}
+	}
+	return nil
+}
+
+// Stop stops every initialized component in reverse order, errors don't stop the rest
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	var errs []error
+	for ; l.initialized > 0; l.initialized-- {
+		c := l.components[l.initialized-1]
+		if c.Stop == nil {
+			continue
+		}
+		if err := c.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop %s: %w", c.Name, err))
+		}
+	}
+	l.started = 0
+	return errors.Join(errs...)
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// recorder builds components that log their phases into calls
+type recorder struct {
+	calls []string
+	fail  string // Phase to fail, e.g. "start:api"
+}
+
+func (r *recorder) component(name string) Component {
+	phase := func(kind string) func(context.Context) error {
+		return func(context.Context) error {
+			call := kind + ":" + name
+			r.calls = append(r.calls, call)
+			if call == r.fail {
+				return errors.New("boom")
+			}
+			return nil
+		}
+	}
+	return Component{Name: name, Init: phase("init"), Start: phase("start"), Stop: phase("stop")}
+}
+
+func TestLifecycle(t *testing.T) {
+	tests := []struct {
+		name    string
+		fail    string
+		wantErr bool
+		want    []string
+	}{
+		{
+			name: "start and stop in reverse",
+			want: []string{"init:db", "init:workers", "init:api", "start:db", "start:workers", "start:api", "stop:api", "stop:workers", "stop:db"},
+		},
+		{
+			name:    "failed init rolls back initialized",
+			fail:    "init:workers",
+			wantErr: true,
+			want:    []string{"init:db", "init:workers", "stop:db"},
+		},
+		{
+			name:    "failed start stops everything",
+			fail:    "start:workers",
+			wantErr: true,
+			want:    []string{"init:db", "init:workers", "init:api", "start:db", "start:workers", "stop:api", "stop:workers", "stop:db"},
+		},
+	}
+
+	for _, tt := range tests {
+		r := &recorder{fail: tt.fail}
+		l := NewLifecycle(r.component("db"), r.component("workers"), r.component("api"))
+
+		err := l.Start(context.Background())
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: Start() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if err == nil {
+			if err = l.Start(context.Background()); err != nil { // Already started, a no-op
+				t.Fatalf("%s: second Start() error = %v", tt.name, err)
+			}
+			if err = l.Stop(context.Background()); err != nil {
+				t.Fatalf("%s: Stop() error = %v", tt.name, err)
+			}
+		}
+		if err = l.Stop(context.Background()); err != nil { // Already stopped, a no-op
+			t.Fatalf("%s: second Stop() error = %v", tt.name, err)
+		}
+		if !slices.Equal(r.calls, tt.want) {
+			t.Errorf("%s: calls = %v, want %v", tt.name, r.calls, tt.want)
+		}
+	}
+}
+
+func TestAppLifecycleSubset(t *testing.T) {
+	l := New("").Lifecycle("database", "config")
+	var names []string
+	for _, c := range l.components {
+		names = append(names, c.Name)
+	}
+	if want := []string{"config", "database"}; !slices.Equal(names, want) {
+		t.Errorf("components = %v, want %v", names, want)
+	}
+}
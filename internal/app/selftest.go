@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/config"
+	"subscription-aggregator-service/internal/logger"
+	"subscription-aggregator-service/internal/service"
+	"subscription-aggregator-service/internal/storage"
+	"subscription-aggregator-service/migrations"
+	"subscription-aggregator-service/pkg/postgres"
+)
+
+// SelfTest applies migrations to a temporary schema in the configured database and runs
+// a minimal CRUD + total cost sequence against it. Used as a pre-deploy gate.
+func SelfTest(configPath string) (err error) {
+	config.LoadConfig(configPath)
+	logger.SetupLogger()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	cfg := config.DatabaseConfig()
+	admin := postgres.NewInstance(cfg)
+	schema := fmt.Sprintf("selftest_%d", time.Now().UnixNano())
+	if err = admin.WithContext(ctx).Exec(fmt.Sprintf("CREATE SCHEMA %s", schema)).Error; err != nil {
+		return fmt.Errorf("failed to create temporary schema: %w", err)
+	}
+	defer func() {
+		if dropErr := admin.Exec(fmt.Sprintf("DROP SCHEMA %s CASCADE", schema)).Error; dropErr != nil && err == nil {
+			err = fmt.Errorf("failed to drop temporary schema: %w", dropErr)
+		}
+	}()
+
+	cfg.Schema = schema + ",public" // Extensions live in public
+	db := postgres.NewInstance(cfg)
+	defer func() {
+		if sqlDB, dbErr := db.DB(); dbErr == nil {
+			_ = sqlDB.Close()
+		}
+	}()
+
+	scripts, err := migrations.UpScripts()
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+	for _, script := range scripts {
+		if err = db.WithContext(ctx).Exec(script).Error; err != nil {
+			return fmt.Errorf("failed to apply migrations: %w", err)
+		}
+	}
+
+	svc := service.NewSubscriptionService(storage.NewSubscriptionsStorage(db))
+	return runSmokeSequence(ctx, svc)
+}
+
+func runSmokeSequence(ctx context.Context, svc service.SubscriptionService) error {
+	userID := "00000000-0000-0000-0000-00000000beef"
+	endDate := "12-2024"
+	price := 100
+
+	created, err := svc.CreateSubscription(ctx, &apiModels.CreateSubscriptionRequest{
+		ServiceName: "Self-test", Price: &price, UserID: userID, StartDate: "01-2024", EndDate: &endDate,
+	})
+	if err != nil {
+		return fmt.Errorf("create: %w", err)
+	}
+	id := apiModels.ItemByIDRequest{ID: created.ID.String()}
+
+	if _, err = svc.GetSubscriptionByID(ctx, id); err != nil {
+		return fmt.Errorf("get: %w", err)
+	}
+
+	newPrice := 200
+	if _, err = svc.UpdateSubscriptionByID(ctx, id, &apiModels.UpdateSubscriptionRequest{Price: &newPrice, IfMatch: created.ETag()}); err != nil {
+		return fmt.Errorf("update: %w", err)
+	}
+
+	list, err := svc.ListSubscriptions(ctx, apiModels.ListSubscriptionsRequest{UserID: userID})
+	if err != nil {
+		return fmt.Errorf("list: %w", err)
+	}
+	if len(list.Items) != 1 || list.TotalCount != 1 {
+		return fmt.Errorf("list: got %d subscriptions of %d, want 1", len(list.Items), list.TotalCount)
+	}
+
+	total, err := svc.TotalSubscriptionsCost(ctx, apiModels.TotalCostRequest{UserID: userID, StartDate: "01-2024", EndDate: "06-2024"})
+	if err != nil {
+		return fmt.Errorf("total: %w", err)
+	}
+	if total.TotalCost != 1200 { // 6 months * 200
+		return fmt.Errorf("total: got %d, want 1200", total.TotalCost)
+	}
+
+	undo, err := svc.DeleteSubscriptionByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+	if _, err = svc.GetSubscriptionByID(ctx, id); !errors.Is(err, service.ErrNotFound) {
+		return fmt.Errorf("get after delete: got %v, want %v", err, service.ErrNotFound)
+	}
+
+	if undo != nil {
+		if _, err = svc.UndoDeletion(ctx, apiModels.UndoRequest{Token: undo.Token.String()}); err != nil {
+			return fmt.Errorf("undo: %w", err)
+		}
+		if _, err = svc.GetSubscriptionByID(ctx, id); err != nil {
+			return fmt.Errorf("get after undo: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -1,42 +1,156 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"os"
+	"strconv"
 	"strings"
 	"subscription-aggregator-service/pkg/postgres"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/spf13/viper"
 )
 
 const (
+	Profile = "app.profile"
+
 	LogEnabled  = "app.log.enabled"
 	LogLevel    = "app.log.level"
 	LogToFile   = "app.log.log2file"
 	LogFilePath = "app.log.file_path"
 	LogFormat   = "app.log.log_format"
 
+	LogAllowLevelHeader = "app.log.allow_level_header"
+
 	ApiHost            = "app.api.host"
 	ApiPort            = "app.api.port"
 	ApiBasePath        = "app.api.base_path"
+	ApiV2BasePath      = "app.api.v2.base_path"
 	GinReleaseMode     = "app.api.gin_release_mode"
 	ApiShutdownTimeout = "app.api.shutdown_timeout"
 
+	ApiUiEnabled         = "app.api.ui.enabled"
+	ApiDocsEnabled       = "app.api.docs.enabled"
+	ApiConcurrencyPerKey = "app.api.concurrency.per_key"
+
+	ApiPublicEnabled       = "app.api.public.enabled"
+	ApiPublicRatePerMinute = "app.api.public.rate_per_minute"
+
+	ApiTimeoutDefault = "app.api.timeouts.default"
+	ApiTimeoutRoutes  = "app.api.timeouts.routes"
+
+	ApiDeprecations = "app.api.deprecations"
+
+	ApiStatusCacheTTL     = "app.api.status.cache_ttl"
+	ApiStatusCheckTimeout = "app.api.status.check_timeout"
+
+	ApiRequireIfMatch = "app.api.require_if_match"
+
+	ApiDateFormat = "app.api.date_format"
+
+	AuthHmacEnabled = "app.auth.hmac.enabled"
+	AuthHmacKeys    = "app.auth.hmac.keys"
+	AuthHmacMaxSkew = "app.auth.hmac.max_skew"
+
+	AdminToken = "app.admin.token"
+
+	IntegrityCheckInterval = "app.integrity.interval"
+
+	UndoWindow = "app.undo.window"
+
+	BulkDeleteBatchSize = "app.bulk_delete.batch_size"
+
+	ServiceRenameBatchSize = "app.service_rename.batch_size"
+
+	HooksPlugins = "app.hooks.plugins"
+
+	DisplayLocale        = "app.display.locale"
+	DisplayCurrency      = "app.display.currency"
+	DisplayCurrencyRates = "app.display.currency_rates"
+
+	MetricsCacheTTL = "app.metrics.cache_ttl"
+	MetricsTokens   = "app.metrics.tokens"
+	MetricsKeys     = "app.metrics.keys"
+
+	LimitsTotalCostMaxYears    = "app.limits.total_cost_max_years"
+	LimitsSubscriptionMaxYears = "app.limits.subscription_max_years"
+	LimitsStartDateWindowYears = "app.limits.start_date_window_years"
+	LimitsDetectDuplicates     = "app.limits.detect_duplicates"
+
+	CacheAggregatesTTL   = "app.cache.aggregates.ttl"
+	CacheAggregatesStale = "app.cache.aggregates.stale"
+
+	ShadowTotalCostEnabled = "app.shadow.total_cost.enabled"
+	ShadowTotalCostServe   = "app.shadow.total_cost.serve"
+
 	DatabaseHost     = "app.database.host"
 	DatabasePort     = "app.database.port"
 	DatabaseUser     = "app.database.user"
 	DatabasePassword = "app.database.password"
 	DatabaseName     = "app.database.database_name"
 	DatabaseSslMode  = "app.database.ssl_mode"
+	DatabaseSslRoot  = "app.database.ssl_root_cert"
+
+	DatabaseMigrations = "app.database.migrations"
+
+	DatabaseRetryAttempts = "app.database.retry.attempts"
+	DatabaseRetryBackoff  = "app.database.retry.backoff"
+	DatabaseRetryAfter    = "app.database.retry.retry_after"
+
+	DatabaseStatsInterval       = "app.database.stats.interval"
+	DatabaseStatsMaxDailyGrowth = "app.database.stats.max_daily_growth_percent"
+	DatabaseStatsMaxDeadRatio   = "app.database.stats.max_dead_tuple_ratio"
+	DatabaseStatsMaxSizeMB      = "app.database.stats.max_size_mb"
 )
 
-func LoadConfig() {
+// Values of Profile, what the instance is deployed for
+const (
+	ProfileFull     = "full"     // Serves reads and writes, runs background workers
+	ProfileReadOnly = "readonly" // Serves reads only from a replica database, e.g. for heavy list and aggregate traffic
+)
+
+// Values of DatabaseMigrations, what to do with migrations pending on startup
+const (
+	MigrationsCheck  = "check"  // Start anyway, report "schema_outdated" in /status until the migrator catches up
+	MigrationsApply  = "apply"  // Apply them before serving
+	MigrationsRefuse = "refuse" // Exit, so the orchestrator keeps old replicas running
+)
+
+// Values of ApiDateFormat, how months like start_date are rendered in responses
+const (
+	DateFormatMonth   = "month"   // MM-YYYY, the same as requests take
+	DateFormatRFC3339 = "rfc3339" // First day of the month at midnight UTC, as responses had it before
+)
+
+const (
+	DefaultConfigPath = "./config.yaml"
+	ConfigPathEnv     = "CONFIG_PATH"
+)
+
+// LoadConfig reads the config file at path, falling back to $CONFIG_PATH and then ./config.yaml.
+// A missing default file is not an error, so the service can be configured purely through env.
+func LoadConfig(path string) {
 	fmt.Print("Loading configuration... ")
 
-	viper.SetConfigFile("./config.yaml")
+	if path == "" {
+		path = os.Getenv(ConfigPathEnv)
+	}
+	explicit := path != ""
+	if !explicit {
+		path = DefaultConfigPath
+	}
+
+	viper.SetConfigFile(path)
 	if err := viper.ReadInConfig(); err != nil {
-		fmt.Println()
-		log.Fatalf("Fatal: failed to read configuration: %v", err)
+		if explicit || !errors.Is(err, fs.ErrNotExist) {
+			fmt.Println()
+			log.Fatalf("Fatal: failed to read configuration: %v", err)
+		}
+		fmt.Print("no config file, using environment only...")
 	}
 
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -58,13 +172,28 @@ func ValidateConfigFields() error {
 		LogToFile: LogFilePath,
 	}
 	var defaults = map[string]any{ // Will be set if not present
-		LogEnabled: true, LogLevel: "INFO", LogToFile: false, LogFilePath: "application.log",
-		ApiShutdownTimeout: "5s",
-		DatabaseName:       "subscription-aggregator-service", DatabaseSslMode: "disable",
+		Profile: ProfileFull, LogEnabled: true, LogLevel: "INFO", LogToFile: false, LogFilePath: "application.log", LogAllowLevelHeader: false,
+		ApiV2BasePath: "/api/v2", ApiShutdownTimeout: "5s", ApiUiEnabled: true, ApiDocsEnabled: true, ApiConcurrencyPerKey: 0, ApiTimeoutDefault: "30s",
+		ApiPublicEnabled: false, ApiPublicRatePerMinute: 60,
+		ApiStatusCacheTTL: "5s", ApiStatusCheckTimeout: "2s", ApiRequireIfMatch: true, ApiDateFormat: DateFormatRFC3339,
+		AuthHmacEnabled: false, AuthHmacMaxSkew: "5m",
+		LimitsTotalCostMaxYears: 50, LimitsSubscriptionMaxYears: 10, LimitsStartDateWindowYears: 30, LimitsDetectDuplicates: false,
+		ShadowTotalCostEnabled: false, ShadowTotalCostServe: "sql", IntegrityCheckInterval: "1h", UndoWindow: "10m", BulkDeleteBatchSize: 1000,
+		ServiceRenameBatchSize: 1000, MetricsCacheTTL: "30s", DisplayLocale: "ru", DisplayCurrency: "RUB",
+		CacheAggregatesTTL: "0s", CacheAggregatesStale: "1m",
+		DatabaseName: "subscription-aggregator-service", DatabaseSslMode: "disable", DatabaseMigrations: MigrationsCheck,
+		DatabaseRetryAttempts: 3, DatabaseRetryBackoff: "500ms", DatabaseRetryAfter: "5s",
+		DatabaseStatsInterval: "15m", DatabaseStatsMaxDailyGrowth: 20, DatabaseStatsMaxDeadRatio: 0.2, DatabaseStatsMaxSizeMB: 0,
 	}
 	var possibleValues = map[string][]string{ // If present, must be one of these values
-		LogLevel:  {"DEBUG", "INFO", "WARN", "ERROR"},
-		LogFormat: {"text", "json"},
+		Profile:              {ProfileFull, ProfileReadOnly},
+		LogLevel:             {"DEBUG", "INFO", "WARN", "ERROR"},
+		LogFormat:            {"text", "json"},
+		ShadowTotalCostServe: {"sql", "go"},
+		DatabaseSslMode:      {"disable", "allow", "prefer", "require", "verify-ca", "verify-full"},
+		DatabaseMigrations:   {MigrationsCheck, MigrationsApply, MigrationsRefuse},
+		DisplayLocale:        {"ru", "en"},
+		ApiDateFormat:        {DateFormatMonth, DateFormatRFC3339},
 	}
 
 	for k, v := range defaults {
@@ -119,9 +248,156 @@ func ValidateConfigFields() error {
 		return fmt.Errorf("invalid value '%s' for key '%s': must be >0", viper.GetString(ApiShutdownTimeout), ApiShutdownTimeout)
 	}
 
+	if viper.GetDuration(ApiStatusCheckTimeout) <= 0 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be >0", viper.GetString(ApiStatusCheckTimeout), ApiStatusCheckTimeout)
+	}
+
+	if viper.GetBool(ApiPublicEnabled) && viper.GetInt(ApiPublicRatePerMinute) <= 0 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be >0 in public mode", viper.GetString(ApiPublicRatePerMinute), ApiPublicRatePerMinute)
+	}
+
+	if viper.GetDuration(ApiTimeoutDefault) < 0 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be >=0", viper.GetString(ApiTimeoutDefault), ApiTimeoutDefault)
+	}
+	if viper.GetDuration(IntegrityCheckInterval) < 0 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be >=0", viper.GetString(IntegrityCheckInterval), IntegrityCheckInterval)
+	}
+	if viper.GetDuration(UndoWindow) < 0 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be >=0", viper.GetString(UndoWindow), UndoWindow)
+	}
+	if viper.GetInt(DatabaseRetryAttempts) <= 0 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be >0", viper.GetString(DatabaseRetryAttempts), DatabaseRetryAttempts)
+	}
+	if viper.GetDuration(DatabaseRetryBackoff) < 0 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be >=0", viper.GetString(DatabaseRetryBackoff), DatabaseRetryBackoff)
+	}
+	if viper.GetDuration(DatabaseRetryAfter) < time.Second {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be >=1s", viper.GetString(DatabaseRetryAfter), DatabaseRetryAfter)
+	}
+	if viper.GetDuration(DatabaseStatsInterval) < 0 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be >=0", viper.GetString(DatabaseStatsInterval), DatabaseStatsInterval)
+	}
+	if viper.GetFloat64(DatabaseStatsMaxDailyGrowth) < 0 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be >=0", viper.GetString(DatabaseStatsMaxDailyGrowth), DatabaseStatsMaxDailyGrowth)
+	}
+	if ratio := viper.GetFloat64(DatabaseStatsMaxDeadRatio); ratio < 0 || ratio > 1 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be between 0 and 1", viper.GetString(DatabaseStatsMaxDeadRatio), DatabaseStatsMaxDeadRatio)
+	}
+	if viper.GetInt64(DatabaseStatsMaxSizeMB) < 0 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be >=0", viper.GetString(DatabaseStatsMaxSizeMB), DatabaseStatsMaxSizeMB)
+	}
+	if viper.GetInt(BulkDeleteBatchSize) <= 0 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be >0", viper.GetString(BulkDeleteBatchSize), BulkDeleteBatchSize)
+	}
+	if viper.GetInt(ServiceRenameBatchSize) <= 0 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be >0", viper.GetString(ServiceRenameBatchSize), ServiceRenameBatchSize)
+	}
+	if viper.GetDuration(CacheAggregatesTTL) < 0 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be >=0", viper.GetString(CacheAggregatesTTL), CacheAggregatesTTL)
+	}
+	if viper.GetDuration(CacheAggregatesStale) < 0 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be >=0", viper.GetString(CacheAggregatesStale), CacheAggregatesStale)
+	}
+
+	if viper.GetDuration(MetricsCacheTTL) < 0 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be >=0", viper.GetString(MetricsCacheTTL), MetricsCacheTTL)
+	}
+	for unitID, token := range viper.GetStringMapString(MetricsTokens) {
+		if _, err := uuid.Parse(unitID); err != nil {
+			return fmt.Errorf("invalid key '%s.%s': must be an org unit UUID", MetricsTokens, unitID)
+		}
+		if strings.TrimSpace(token) == "" {
+			return fmt.Errorf("missing required fields/values in config: %s.%s", MetricsTokens, unitID)
+		}
+	}
+	for keyID, unitID := range viper.GetStringMapString(MetricsKeys) {
+		if _, err := uuid.Parse(unitID); err != nil {
+			return fmt.Errorf("invalid value '%s' for key '%s.%s': must be an org unit UUID", unitID, MetricsKeys, keyID)
+		}
+	}
+
+	if _, err := RouteTimeouts(); err != nil {
+		return err
+	}
+	if _, err := Deprecations(); err != nil {
+		return err
+	}
+	rates, err := CurrencyRates()
+	if err != nil {
+		return err
+	}
+	if currency := strings.ToUpper(viper.GetString(DisplayCurrency)); currency != "RUB" && rates[currency] == 0 {
+		return fmt.Errorf("invalid value '%s' for key '%s': must be RUB or have a rate in %s", viper.GetString(DisplayCurrency), DisplayCurrency, DisplayCurrencyRates)
+	}
+
+	if v2 := viper.GetString(ApiV2BasePath); v2 != "" && v2 == viper.GetString(ApiBasePath) {
+		return fmt.Errorf("invalid value '%s' for key '%s': must differ from %s", v2, ApiV2BasePath, ApiBasePath)
+	}
+	if ReadOnly() && viper.GetString(DatabaseMigrations) == MigrationsApply {
+		return fmt.Errorf("invalid value '%s' for key '%s': can't apply migrations in %s profile", viper.GetString(DatabaseMigrations), DatabaseMigrations, ProfileReadOnly)
+	}
+
+	for _, key := range []string{ApiConcurrencyPerKey, LimitsTotalCostMaxYears, LimitsSubscriptionMaxYears, LimitsStartDateWindowYears} {
+		if viper.GetInt(key) < 0 {
+			return fmt.Errorf("invalid value '%s' for key '%s': must be >=0", viper.GetString(key), key)
+		}
+	}
+
+	if viper.GetBool(AuthHmacEnabled) {
+		if len(viper.GetStringMapString(AuthHmacKeys)) == 0 {
+			return fmt.Errorf("missing required fields/values in config: %s (%s=true)", AuthHmacKeys, AuthHmacEnabled)
+		}
+		if viper.GetDuration(AuthHmacMaxSkew) <= 0 {
+			return fmt.Errorf("invalid value '%s' for key '%s': must be >0", viper.GetString(AuthHmacMaxSkew), AuthHmacMaxSkew)
+		}
+	}
+
 	return nil
 }
 
+// RouteTimeouts parses per-route timeouts, keyed by "METHOD /route/:param" or "/route/:param" (lower case, as viper stores keys)
+func RouteTimeouts() (map[string]time.Duration, error) {
+	routes := make(map[string]time.Duration)
+	for route, value := range viper.GetStringMapString(ApiTimeoutRoutes) {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout < 0 {
+			return nil, fmt.Errorf("invalid value '%s' for key '%s.%s': must be a duration >=0", value, ApiTimeoutRoutes, route)
+		}
+		routes[strings.ToLower(route)] = timeout
+	}
+	return routes, nil
+}
+
+// Deprecations returns sunset dates of deprecated features keyed by lower-cased "METHOD /route?param",
+// zero time for features deprecated without a removal date
+func Deprecations() (map[string]time.Time, error) {
+	features := make(map[string]time.Time)
+	for feature, value := range viper.GetStringMapString(ApiDeprecations) {
+		var sunset time.Time
+		if value != "" {
+			var err error
+			if sunset, err = time.Parse(time.DateOnly, value); err != nil {
+				return nil, fmt.Errorf("invalid value '%s' for key '%s.%s': must be a YYYY-MM-DD date or empty", value, ApiDeprecations, feature)
+			}
+		}
+		features[strings.ToLower(feature)] = sunset
+	}
+	return features, nil
+}
+
+// CurrencyRates returns rubles per unit of display currencies keyed by upper-case ISO 4217 code
+func CurrencyRates() (map[string]float64, error) {
+	rates := make(map[string]float64)
+	for currency, value := range viper.GetStringMapString(DisplayCurrencyRates) {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid value '%s' for key '%s.%s': must be a number >0", value, DisplayCurrencyRates, currency)
+		}
+		rates[strings.ToUpper(currency)] = rate
+	}
+	return rates, nil
+}
+
 func DatabaseConfig() postgres.Config {
 	return postgres.Config{
 		Host:     viper.GetString(DatabaseHost),
@@ -130,6 +406,13 @@ func DatabaseConfig() postgres.Config {
 		Password: viper.GetString(DatabasePassword),
 		Database: viper.GetString(DatabaseName),
 		SSLMode:  viper.GetString(DatabaseSslMode),
+		SSLRoot:  viper.GetString(DatabaseSslRoot),
 		LogLevel: viper.GetString(LogLevel),
+		ReadOnly: ReadOnly(),
 	}
 }
+
+// ReadOnly tells if the instance runs in ProfileReadOnly
+func ReadOnly() bool {
+	return viper.GetString(Profile) == ProfileReadOnly
+}
@@ -0,0 +1,184 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of GrowthWarning
+const (
+	WarningGrowth = "growth" // Grows faster than GrowthLimits.DailyGrowthPercent
+	WarningBloat  = "bloat"  // Dead tuples above GrowthLimits.DeadTupleRatio
+	WarningSize   = "size"   // Database larger than GrowthLimits.DatabaseBytes
+)
+
+const (
+	minAlertTableBytes = 1 << 20        // Growth and bloat of smaller tables are noise
+	minGrowthWindow    = time.Hour      // Growth over a shorter span is too noisy to extrapolate to a day
+	growthWindow       = 24 * time.Hour // Snapshots older than that are dropped once a newer one is as old
+)
+
+type TableSize struct {
+	Name       string
+	TableBytes int64 // Heap with TOAST
+	IndexBytes int64
+	LiveTuples int64
+	DeadTuples int64
+}
+
+// DeadRatio is the share of dead tuples, estimated by autovacuum statistics
+func (t TableSize) DeadRatio() float64 {
+	if t.LiveTuples+t.DeadTuples == 0 {
+		return 0
+	}
+	return float64(t.DeadTuples) / float64(t.LiveTuples+t.DeadTuples)
+}
+
+type SizeSnapshot struct {
+	CollectedAt   time.Time
+	DatabaseBytes int64
+	Tables        []TableSize // By name
+}
+
+// SizeSource reads sizes of the database
+type SizeSource interface {
+	Sizes(ctx context.Context) (*SizeSnapshot, error)
+}
+
+// GrowthLimits are soft: crossing one logs a warning and shows up in metrics, nothing is refused. Zero disables a limit.
+type GrowthLimits struct {
+	DailyGrowthPercent float64
+	DeadTupleRatio     float64
+	DatabaseBytes      int64
+}
+
+// GrowthWarning is a limit crossed on the last collection, Table is empty for the whole database
+type GrowthWarning struct {
+	Kind  string
+	Table string
+	Value float64
+	Limit float64
+}
+
+// GrowthCollector keeps the last size snapshot and the limits it crossed, giving operators
+// early warning before the disk fills. Growth is measured against the oldest snapshot of the last day.
+type GrowthCollector struct {
+	source SizeSource
+	limits GrowthLimits
+
+	mu       sync.Mutex
+	history  []*SizeSnapshot // Oldest first, the last one is the current
+	warnings []GrowthWarning
+}
+
+func NewGrowthCollector(source SizeSource, limits GrowthLimits) *GrowthCollector {
+	return &GrowthCollector{source: source, limits: limits}
+}
+
+// Collect takes a snapshot, checks it against the limits and logs a warning per crossed one
+func (gc *GrowthCollector) Collect(ctx context.Context) error {
+	snap, err := gc.source.Sizes(ctx)
+	if err != nil {
+		slog.Error("failed to collect database sizes", "error", err)
+		return err
+	}
+
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	gc.history = append(gc.history, snap)
+	for len(gc.history) > 1 && snap.CollectedAt.Sub(gc.history[1].CollectedAt) >= growthWindow {
+		gc.history = gc.history[1:]
+	}
+	gc.warnings = gc.check(gc.history[0], snap)
+
+	for _, w := range gc.warnings {
+		slog.Warn("database limit crossed", "kind", w.Kind, "table", w.Table, "value", w.Value, "limit", w.Limit)
+	}
+	slog.Debug("database sizes collected", "bytes", snap.DatabaseBytes, "tables", len(snap.Tables), "warnings", len(gc.warnings))
+	return nil
+}
+
+func (gc *GrowthCollector) check(baseline, snap *SizeSnapshot) []GrowthWarning {
+	var warnings []GrowthWarning
+	if limit := gc.limits.DatabaseBytes; limit > 0 && snap.DatabaseBytes > limit {
+		warnings = append(warnings, GrowthWarning{Kind: WarningSize, Value: float64(snap.DatabaseBytes), Limit: float64(limit)})
+	}
+
+	elapsed := snap.CollectedAt.Sub(baseline.CollectedAt)
+	growth := func(table string, before, after int64) {
+		if gc.limits.DailyGrowthPercent <= 0 || elapsed < minGrowthWindow || before < minAlertTableBytes {
+			return
+		}
+		daily := float64(after-before) / float64(before) * 100 * float64(24*time.Hour) / float64(elapsed)
+		if daily > gc.limits.DailyGrowthPercent {
+			warnings = append(warnings, GrowthWarning{Kind: WarningGrowth, Table: table, Value: daily, Limit: gc.limits.DailyGrowthPercent})
+		}
+	}
+	growth("", baseline.DatabaseBytes, snap.DatabaseBytes)
+
+	before := make(map[string]int64, len(baseline.Tables))
+	for _, t := range baseline.Tables {
+		before[t.Name] = t.TableBytes + t.IndexBytes
+	}
+	for _, t := range snap.Tables {
+		if prev, ok := before[t.Name]; ok {
+			growth(t.Name, prev, t.TableBytes+t.IndexBytes)
+		}
+		if limit := gc.limits.DeadTupleRatio; limit > 0 && t.TableBytes >= minAlertTableBytes && t.DeadRatio() > limit {
+			warnings = append(warnings, GrowthWarning{Kind: WarningBloat, Table: t.Name, Value: t.DeadRatio(), Limit: limit})
+		}
+	}
+	return warnings
+}
+
+// Last returns the last snapshot, nil before the first collection, and the limits it crossed
+func (gc *GrowthCollector) Last() (*SizeSnapshot, []GrowthWarning) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+	if len(gc.history) == 0 {
+		return nil, nil
+	}
+	return gc.history[len(gc.history)-1], gc.warnings
+}
+
+// RunGrowthCollector collects sizes every interval until ctx is done
+func RunGrowthCollector(ctx context.Context, gc *GrowthCollector, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_ = gc.Collect(ctx) // Logged inside, next tick retries
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PostgresSizes reads sizes of tables in the search path from Postgres statistics
+type PostgresSizes struct {
+	db *gorm.DB
+}
+
+func NewPostgresSizes(db *gorm.DB) *PostgresSizes {
+	return &PostgresSizes{db: db}
+}
+
+func (ps *PostgresSizes) Sizes(ctx context.Context) (*SizeSnapshot, error) {
+	snap := &SizeSnapshot{CollectedAt: time.Now()}
+	db := ps.db.WithContext(ctx)
+	if err := db.Raw("SELECT pg_database_size(current_database())").Scan(&snap.DatabaseBytes).Error; err != nil {
+		return nil, err
+	}
+	err := db.Raw(`SELECT relname AS name, pg_table_size(relid) AS table_bytes, pg_indexes_size(relid) AS index_bytes,
+		n_live_tup AS live_tuples, n_dead_tup AS dead_tuples
+		FROM pg_stat_user_tables WHERE schemaname = ANY (current_schemas(false)) ORDER BY relname`).Scan(&snap.Tables).Error
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
@@ -0,0 +1,84 @@
+package health
+
+import (
+	"testing"
+
+	"context"
+	"time"
+)
+
+type fakeSizes struct {
+	snap *SizeSnapshot
+}
+
+func (f *fakeSizes) Sizes(ctx context.Context) (*SizeSnapshot, error) {
+	snap := *f.snap
+	return &snap, nil
+}
+
+func TestGrowthCollector(t *testing.T) {
+	ctx := context.Background()
+	const mb = 1 << 20
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	source := &fakeSizes{snap: &SizeSnapshot{CollectedAt: start, DatabaseBytes: 100 * mb, Tables: []TableSize{
+		{Name: "subscriptions", TableBytes: 50 * mb, IndexBytes: 10 * mb, LiveTuples: 900, DeadTuples: 100},
+		{Name: "saved_views", TableBytes: mb / 2, LiveTuples: 1, DeadTuples: 9}, // Too small to alert on
+	}}}
+	gc := NewGrowthCollector(source, GrowthLimits{DailyGrowthPercent: 20, DeadTupleRatio: 0.2, DatabaseBytes: 150 * mb})
+
+	if snap, warnings := gc.Last(); snap != nil || warnings != nil {
+		t.Fatalf("Last() before collection = %v, %v, want nothing", snap, warnings)
+	}
+	if err := gc.Collect(ctx); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if _, warnings := gc.Last(); len(warnings) != 0 {
+		t.Errorf("warnings on first collection = %+v, want none", warnings)
+	}
+
+	// 20 MB of 60 MB in 6 hours is over 130% a day, the database as a whole grows 80% a day
+	source.snap = &SizeSnapshot{CollectedAt: start.Add(6 * time.Hour), DatabaseBytes: 120 * mb, Tables: []TableSize{
+		{Name: "subscriptions", TableBytes: 70 * mb, IndexBytes: 10 * mb, LiveTuples: 600, DeadTuples: 400},
+	}}
+	if err := gc.Collect(ctx); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	snap, warnings := gc.Last()
+	if snap.DatabaseBytes != 120*mb {
+		t.Errorf("Last() DatabaseBytes = %d, want the latest snapshot", snap.DatabaseBytes)
+	}
+	kinds := map[string]string{}
+	for _, w := range warnings {
+		kinds[w.Kind+":"+w.Table] = w.Kind
+	}
+	for _, want := range []string{"growth:", "growth:subscriptions", "bloat:subscriptions"} {
+		if _, ok := kinds[want]; !ok {
+			t.Errorf("warnings = %+v, missing %s", warnings, want)
+		}
+	}
+	if len(warnings) != 3 {
+		t.Errorf("got %d warnings, want 3: %+v", len(warnings), warnings)
+	}
+
+	// A day later growth is measured against the snapshot of 6h, and the database is over its size limit
+	source.snap = &SizeSnapshot{CollectedAt: start.Add(30 * time.Hour), DatabaseBytes: 160 * mb, Tables: []TableSize{
+		{Name: "subscriptions", TableBytes: 80 * mb, IndexBytes: 10 * mb, LiveTuples: 1000},
+	}}
+	if err := gc.Collect(ctx); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(gc.history) != 2 {
+		t.Errorf("history has %d snapshots, want the one of a day ago and the current", len(gc.history))
+	}
+	_, warnings = gc.Last()
+	kinds = map[string]string{}
+	for _, w := range warnings {
+		kinds[w.Kind+":"+w.Table] = w.Kind
+	}
+	if _, ok := kinds["size:"]; !ok {
+		t.Errorf("warnings = %+v, want size limit crossed", warnings)
+	}
+	if _, ok := kinds["growth:subscriptions"]; ok {
+		t.Errorf("warnings = %+v, subscriptions grew 12.5%% a day, below the limit", warnings)
+	}
+}
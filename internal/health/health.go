@@ -0,0 +1,162 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"subscription-aggregator-service/migrations"
+)
+
+const (
+	StatusUp             = "up"
+	StatusDown           = "down"
+	StatusSchemaOutdated = "schema_outdated" // Database works, but migrations the code relies on aren't applied yet
+)
+
+// ErrSchemaOutdated is returned by checkers to report StatusSchemaOutdated instead of StatusDown
+var ErrSchemaOutdated = errors.New("schema outdated")
+
+// Checker probes a single dependency
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type ComponentStatus struct {
+	Name        string
+	Status      string
+	Latency     time.Duration
+	CheckedAt   time.Time
+	LastError   string
+	LastErrorAt *time.Time
+}
+
+type Report struct {
+	Status     string
+	Components []ComponentStatus
+}
+
+// Monitor runs all checkers and caches the resulting report for ttl,
+// so frequent dashboard polling doesn't hammer the dependencies
+type Monitor struct {
+	checkers []Checker
+	ttl      time.Duration
+	timeout  time.Duration
+
+	mu         sync.Mutex
+	report     *Report
+	reportedAt time.Time
+	lastErrors map[string]ComponentStatus // Last failure per component, survives recovery
+}
+
+func NewMonitor(ttl, timeout time.Duration, checkers ...Checker) *Monitor {
+	return &Monitor{
+		checkers:   checkers,
+		ttl:        ttl,
+		timeout:    timeout,
+		lastErrors: make(map[string]ComponentStatus),
+	}
+}
+
+// Report returns the cached report or checks every dependency anew once it's older than ttl.
+// Checks get their own timeout rather than a caller's context: the report is shared until it expires,
+// so a client hanging up mustn't mark dependencies down for everyone else.
+func (m *Monitor) Report() Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.report != nil && time.Since(m.reportedAt) < m.ttl {
+		return *m.report
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	components := make([]ComponentStatus, len(m.checkers))
+	var wg sync.WaitGroup
+	for i, checker := range m.checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := checker.Check(ctx)
+			components[i] = ComponentStatus{Name: checker.Name(), Status: StatusUp, Latency: time.Since(start), CheckedAt: start}
+			if err != nil {
+				components[i].Status = StatusDown
+				if errors.Is(err, ErrSchemaOutdated) {
+					components[i].Status = StatusSchemaOutdated
+				}
+				components[i].LastError = err.Error()
+				components[i].LastErrorAt = &start
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Components: components}
+	for i, c := range components {
+		if c.Status != StatusUp {
+			if report.Status != StatusDown { // Outage outranks outdated schema
+				report.Status = c.Status
+			}
+			m.lastErrors[c.Name] = c
+		} else if prev, ok := m.lastErrors[c.Name]; ok {
+			components[i].LastError = prev.LastError
+			components[i].LastErrorAt = prev.LastErrorAt
+		}
+	}
+
+	m.report = &report
+	m.reportedAt = time.Now()
+	return report
+}
+
+type PostgresChecker struct {
+	db *gorm.DB
+}
+
+func NewPostgresChecker(db *gorm.DB) *PostgresChecker {
+	return &PostgresChecker{db: db}
+}
+
+func (pc *PostgresChecker) Name() string {
+	return "postgres"
+}
+
+func (pc *PostgresChecker) Check(ctx context.Context) error {
+	sqlDB, err := pc.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// SchemaChecker reports StatusSchemaOutdated while the database lags behind embedded migrations,
+// e.g. when a new replica rolls out before the migrator ran
+type SchemaChecker struct {
+	db *gorm.DB
+}
+
+func NewSchemaChecker(db *gorm.DB) *SchemaChecker {
+	return &SchemaChecker{db: db}
+}
+
+func (sc *SchemaChecker) Name() string {
+	return "schema"
+}
+
+func (sc *SchemaChecker) Check(ctx context.Context) error {
+	pending, err := migrations.Pending(ctx, sc.db)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %d pending migrations, next is %s", ErrSchemaOutdated, len(pending), pending[0].Name)
+	}
+	return nil
+}
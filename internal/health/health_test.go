@@ -0,0 +1,104 @@
+package health
+
+import (
+	"testing"
+
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type fakeChecker struct {
+	name  string
+	err   error
+	calls int
+}
+
+func (f *fakeChecker) Name() string {
+	return f.name
+}
+
+func (f *fakeChecker) Check(ctx context.Context) error {
+	f.calls++
+	return f.err
+}
+
+func TestMonitorReport(t *testing.T) {
+	db := &fakeChecker{name: "postgres"}
+	m := NewMonitor(time.Hour, time.Second, db)
+
+	report := m.Report()
+	if report.Status != StatusUp {
+		t.Errorf("Status = %q, want %q", report.Status, StatusUp)
+	}
+
+	db.err = errors.New("connection refused")
+	report = m.Report()
+	if db.calls != 1 {
+		t.Errorf("checker called %d times, want 1 (cached report)", db.calls)
+	}
+	if report.Status != StatusUp {
+		t.Errorf("cached Status = %q, want %q", report.Status, StatusUp)
+	}
+}
+
+func TestMonitorReport_LastError(t *testing.T) {
+	db := &fakeChecker{name: "postgres", err: errors.New("connection refused")}
+	m := NewMonitor(0, time.Second, db)
+
+	report := m.Report()
+	if report.Status != StatusDown {
+		t.Fatalf("Status = %q, want %q", report.Status, StatusDown)
+	}
+	if report.Components[0].LastError != "connection refused" {
+		t.Errorf("LastError = %q, want %q", report.Components[0].LastError, "connection refused")
+	}
+
+	db.err = nil
+	report = m.Report()
+	if report.Status != StatusUp {
+		t.Fatalf("Status = %q, want %q", report.Status, StatusUp)
+	}
+	if report.Components[0].LastError != "connection refused" || report.Components[0].LastErrorAt == nil {
+		t.Errorf("last error should survive recovery, got %+v", report.Components[0])
+	}
+}
+
+func TestMonitorReport_SchemaOutdated(t *testing.T) {
+	db := &fakeChecker{name: "postgres"}
+	schema := &fakeChecker{name: "schema", err: fmt.Errorf("%w: 1 pending migrations", ErrSchemaOutdated)}
+	m := NewMonitor(0, time.Second, db, schema)
+
+	report := m.Report()
+	if report.Status != StatusSchemaOutdated {
+		t.Errorf("Status = %q, want %q", report.Status, StatusSchemaOutdated)
+	}
+	if report.Components[1].Status != StatusSchemaOutdated {
+		t.Errorf("schema component Status = %q, want %q", report.Components[1].Status, StatusSchemaOutdated)
+	}
+
+	db.err = errors.New("connection refused")
+	if report = m.Report(); report.Status != StatusDown {
+		t.Errorf("Status = %q, want %q to outrank outdated schema", report.Status, StatusDown)
+	}
+}
+
+// deadlineChecker fails unless it gets a live context with a deadline
+type deadlineChecker struct{}
+
+func (deadlineChecker) Name() string { return "deadline" }
+
+func (deadlineChecker) Check(ctx context.Context) error {
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("no deadline")
+	}
+	return ctx.Err()
+}
+
+func TestMonitorReport_OwnTimeout(t *testing.T) {
+	m := NewMonitor(time.Hour, time.Second, deadlineChecker{})
+	if report := m.Report(); report.Status != StatusUp {
+		t.Errorf("Status = %q, want %q: checks run under the monitor's timeout, %+v", report.Status, StatusUp, report.Components)
+	}
+}
@@ -1,11 +1,13 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"log/slog"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -47,15 +49,17 @@ func SetupLogger() {
 		level = slog.LevelError
 	}
 
+	// Handler itself passes every level, so a request can lower it below the configured one, see WithLevel
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
 	switch viper.GetString(config.LogFormat) {
 	case "text":
-		slog.SetDefault(slog.New(slog.NewTextHandler(io.MultiWriter(writers...), &slog.HandlerOptions{Level: level})))
+		slog.SetDefault(slog.New(levelHandler{Handler: slog.NewTextHandler(io.MultiWriter(writers...), opts), level: level}))
 	case "json":
-		slog.SetDefault(slog.New(slog.NewTextHandler(io.MultiWriter(writers...), &slog.HandlerOptions{Level: level})))
+		slog.SetDefault(slog.New(levelHandler{Handler: slog.NewTextHandler(io.MultiWriter(writers...), opts), level: level}))
 	default:
 		fmt.Println("")
 		log.Printf("Unknown log format (\"%s\"), will fallback to text format\n", viper.GetString(config.LogFormat))
-		slog.SetDefault(slog.New(slog.NewTextHandler(io.MultiWriter(writers...), &slog.HandlerOptions{Level: level})))
+		slog.SetDefault(slog.New(levelHandler{Handler: slog.NewTextHandler(io.MultiWriter(writers...), opts), level: level}))
 	}
 
 	fmt.Println(" Done.")
@@ -94,14 +98,96 @@ func GinLoggerMiddleware() gin.HandlerFunc {
 			slog.Duration("duration", duration),
 		}
 
+		if errors != "" {
+			args = append(args, slog.String("errors", errors))
+		}
+
+		FromContext(ctx.Request.Context()).Log(ctx.Request.Context(), level, "http_request", args...) // Carries request_id and the rest
+	}
+}
+
+// LevelHeaderName lets a client raise or lower log level of its own request, if app.log.allow_level_header is set
+const LevelHeaderName = "X-Log-Level"
+
+// ContextMiddleware puts a logger with request_id, user_id and org_unit_id of the request (whichever are known) into its context,
+// for the service to log with. Must run after RequestID.
+func ContextMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var args []any
 		if id, ok := request.FromContext(ctx.Request.Context()); ok {
 			args = append(args, slog.String("request_id", id))
 		}
+		if userID := requestParam(ctx, "/users/", "user_id"); userID != "" {
+			args = append(args, slog.String("user_id", userID))
+		}
+		if unitID := requestParam(ctx, "/org-units/", "org_unit_id"); unitID != "" {
+			args = append(args, slog.String("org_unit_id", unitID))
+		}
 
-		if errors != "" {
-			args = append(args, slog.String("errors", errors))
+		l := slog.Default().With(args...)
+		if viper.GetBool(config.LogAllowLevelHeader) {
+			var level slog.Level
+			if header := ctx.GetHeader(LevelHeaderName); header != "" && level.UnmarshalText([]byte(header)) == nil {
+				l = WithLevel(l, level)
+			}
 		}
 
-		slog.Log(ctx.Request.Context(), level, "http_request", args...)
+		ctx.Request = ctx.Request.WithContext(WithContext(ctx.Request.Context(), l))
+		ctx.Next()
 	}
 }
+
+// requestParam returns :id of routes under resource, or query parameter otherwise
+func requestParam(ctx *gin.Context, resource, query string) string {
+	if strings.Contains(ctx.FullPath(), resource+":id") {
+		return ctx.Param("id")
+	}
+	return ctx.Query(query)
+}
+
+type ctxKey struct{}
+
+func WithContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the request's logger, or the default one outside of requests
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// With adds attributes to the logger in ctx, e.g. once the client is authenticated
+func With(ctx context.Context, args ...any) context.Context {
+	return WithContext(ctx, FromContext(ctx).With(args...))
+}
+
+// WithLevel returns l logging from level instead of the configured one.
+// Loggers not made by SetupLogger (e.g. in tests) are returned as is.
+func WithLevel(l *slog.Logger, level slog.Level) *slog.Logger {
+	h, ok := l.Handler().(levelHandler)
+	if !ok {
+		return l
+	}
+	return slog.New(levelHandler{Handler: h.Handler, level: level})
+}
+
+// levelHandler filters records by its own level, so it can differ between loggers sharing one output handler
+type levelHandler struct {
+	slog.Handler
+	level slog.Level
+}
+
+func (h levelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h levelHandler) WithGroup(name string) slog.Handler {
+	return levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+
+	"subscription-aggregator-service/internal/config"
+	"subscription-aggregator-service/internal/utils/request"
+)
+
+func TestContextMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(levelHandler{Handler: slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}), level: slog.LevelInfo}))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(request.WithContext(c.Request.Context(), "req-1"))
+	}, ContextMiddleware())
+	handler := func(c *gin.Context) {
+		FromContext(c.Request.Context()).Debug("debug")
+		FromContext(c.Request.Context()).Info("info")
+	}
+	r.GET("/users/:id/summary", handler)
+	r.GET("/subscriptions", handler)
+	r.GET("/org-units/:id/total", handler)
+
+	tests := []struct {
+		name       string
+		path       string
+		header     string
+		allow      bool
+		wantAttrs  []string
+		wantDebug  bool
+		wantNoInfo bool
+	}{
+		{name: "user from path", path: "/users/u-1/summary", wantAttrs: []string{"request_id=req-1", "user_id=u-1"}},
+		{name: "user and org unit from query", path: "/subscriptions?user_id=u-2&org_unit_id=o-2", wantAttrs: []string{"user_id=u-2", "org_unit_id=o-2"}},
+		{name: "org unit from path", path: "/org-units/o-3/total", wantAttrs: []string{"org_unit_id=o-3"}},
+		{name: "level header ignored by default", path: "/subscriptions", header: "DEBUG"},
+		{name: "level lowered", path: "/subscriptions", header: "DEBUG", allow: true, wantDebug: true},
+		{name: "level raised", path: "/subscriptions", header: "ERROR", allow: true, wantNoInfo: true},
+		{name: "invalid level", path: "/subscriptions", header: "LOUD", allow: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set(config.LogAllowLevelHeader, tt.allow)
+			t.Cleanup(func() { viper.Set(config.LogAllowLevelHeader, false) })
+			buf.Reset()
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(LevelHeaderName, tt.header)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			out := buf.String()
+			for _, attr := range tt.wantAttrs {
+				if !strings.Contains(out, attr) {
+					t.Errorf("log %q misses %s", out, attr)
+				}
+			}
+			if got := strings.Contains(out, "msg=debug"); got != tt.wantDebug {
+				t.Errorf("debug record logged = %v, want %v", got, tt.wantDebug)
+			}
+			if got := strings.Contains(out, "msg=info"); got == tt.wantNoInfo {
+				t.Errorf("info record logged = %v, want %v", got, !tt.wantNoInfo)
+			}
+		})
+	}
+}
+
+func TestFromContextDefault(t *testing.T) {
+	if FromContext(httptest.NewRequest(http.MethodGet, "/", nil).Context()) != slog.Default() {
+		t.Error("FromContext() outside of request is not the default logger")
+	}
+}
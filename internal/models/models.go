@@ -1,27 +1,246 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// Subscription types. One-time purchases (domain renewals, lifetime licenses) are charged only in their start month.
+const (
+	TypeRecurring = "recurring"
+	TypeOneTime   = "one_time"
+)
+
+// Columns subscriptions list can be sorted by
+const (
+	SortByCreatedAt   = "created_at"
+	SortByPrice       = "price"
+	SortByStartDate   = "start_date"
+	SortByServiceName = "service_name"
+)
+
+// Sort orders
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// Subscription statuses, derived from its dates relative to the current month rather than stored
+const (
+	StatusActive    = "active"
+	StatusUpcoming  = "upcoming"  // Starts after the current month
+	StatusExpired   = "expired"   // Ended before the current month
+	StatusCancelled = "cancelled" // Cancelled, whether or not it has ended yet
+	StatusDeleted   = "deleted"
+)
+
 type Subscription struct {
 	ID          uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey"`
+	ExternalID  *string        `json:"external_id,omitempty"`
 	ServiceName string         `json:"service_name"`
 	Price       int            `json:"price"`
+	Type        string         `json:"type" gorm:"default:recurring"`
+	PlanID      *uuid.UUID     `json:"plan_id,omitempty" gorm:"type:uuid"`
+	FollowPlan  bool           `json:"follow_plan_price,omitempty" gorm:"column:follow_plan_price"` // Price is kept equal to the plan's official price
+	OrgUnitID   *uuid.UUID     `json:"org_unit_id,omitempty" gorm:"type:uuid"`                      // Department or team the cost is attributed to
+	CostCenter  *string        `json:"cost_center,omitempty"`                                       // Allocation tag for chargeback
+	ProjectCode *string        `json:"project_code,omitempty"`                                      // Allocation tag for chargeback
 	UserID      uuid.UUID      `json:"user_id"`
 	StartDate   time.Time      `json:"start_date"`
 	EndDate     *time.Time     `json:"end_date,omitempty"`
-	CreatedAt   time.Time      `json:"-" gorm:"autoCreateTime"`
-	UpdatedAt   time.Time      `json:"-" gorm:"autoUpdateTime"`
+	CancelledAt *time.Time     `json:"cancelled_at,omitempty"` // When the subscription was cancelled, it still counts until its end date unlike a deleted one
+	CreatedAt   time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+	Version     int            `json:"version" gorm:"default:1"` // Incremented by every change, the ETag for optimistic concurrency
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+
+	Credits []SubscriptionCredit `json:"credits,omitempty" gorm:"foreignKey:SubscriptionID"` // Only loaded for cost calculation
+	Pauses  []PausedPeriod       `json:"pauses,omitempty" gorm:"foreignKey:SubscriptionID"`  // Only loaded for cost calculation
+}
+
+// ETag identifies the subscription's current version in ETag and If-Match headers
+func (s *Subscription) ETag() string {
+	return fmt.Sprintf(`"%d"`, s.Version)
+}
+
+// StatusIn is one of Status* constants in the month, the first day of it. Deletion takes precedence over cancellation,
+// and cancellation over dates; storage filters by status in the same order.
+func (s *Subscription) StatusIn(month time.Time) string {
+	switch {
+	case s.DeletedAt.Valid:
+		return StatusDeleted
+	case s.CancelledAt != nil:
+		return StatusCancelled
+	case s.StartDate.After(month):
+		return StatusUpcoming
+	case s.EndDate != nil && s.EndDate.Before(month):
+		return StatusExpired
+	default:
+		return StatusActive
+	}
+}
+
+// SubscriptionCredit is a discount subtracted from subscription price every month of [StartDate, EndDate]
+type SubscriptionCredit struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	SubscriptionID uuid.UUID  `json:"subscription_id" gorm:"type:uuid"`
+	Amount         int        `json:"amount"`            // Negative, per month; 0 for percentage credits
+	Percent        int        `json:"percent,omitempty"` // Share of the subscription price taken off every month, 1-100
+	StartDate      time.Time  `json:"start_date"`
+	EndDate        *time.Time `json:"end_date,omitempty"`
+	Description    string     `json:"description"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// PausedPeriod is a span of months the subscription isn't charged for, open-ended while it's paused.
+// A subscription has at most one open pause and its pauses don't overlap.
+type PausedPeriod struct {
+	ID             uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	SubscriptionID uuid.UUID  `json:"subscription_id" gorm:"type:uuid"`
+	StartDate      time.Time  `json:"start_date"`         // First paused month
+	EndDate        *time.Time `json:"end_date,omitempty"` // Last paused month, nil while paused
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// Plan is a catalog entry with the vendor's official price, subscriptions can be created from it and follow its price
+type Plan struct {
+	ID          uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	ServiceName string    `json:"service_name"`
+	Name        string    `json:"name"`
+	Price       int       `json:"price"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// OrgUnit is a node of an organization tree (company, department, team), subscriptions attributed to it roll up to its ancestors
+type OrgUnit struct {
+	ID        uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	ParentID  *uuid.UUID `json:"parent_id,omitempty" gorm:"type:uuid"` // Nil for a root unit
+	Name      string     `json:"name"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// UndoToken lets the client restore a soft-deleted subscription until ExpiresAt
+type UndoToken struct {
+	Token          uuid.UUID `json:"undo_token" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	SubscriptionID uuid.UUID `json:"-" gorm:"type:uuid"`
+	ExpiresAt      time.Time `json:"undo_expires_at"`
+}
+
+type SavedView struct {
+	ID            uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	UserID        uuid.UUID  `json:"user_id"`
+	Name          string     `json:"name"`
+	ServiceName   *string    `json:"service_name,omitempty"`
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
+	MinPrice      *int       `json:"min_price,omitempty"`
+	MaxPrice      *int       `json:"max_price,omitempty"`
+	SortBy        *string    `json:"sort_by,omitempty"`
+	Order         *string    `json:"order,omitempty" gorm:"column:sort_order"`
+	Limit         *int       `json:"limit,omitempty" gorm:"column:row_limit"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// IntegrityFinding is an anomaly in a live subscription, kept while consecutive checks keep finding it
+type IntegrityFinding struct {
+	Check          string    `json:"check" gorm:"column:check_name;primaryKey"`
+	SubscriptionID uuid.UUID `json:"subscription_id" gorm:"type:uuid;primaryKey"`
+	Details        string    `json:"details"`
+	DetectedAt     time.Time `json:"detected_at"` // First check that found it
+	CheckedAt      time.Time `json:"checked_at"`  // Last check that still found it
+}
+
+// UserSummary aggregates a user's live subscriptions, Active* and Monthly* fields cover ones active in a given month
+type UserSummary struct {
+	ActiveCount          int
+	MonthlyCost          int64   // List prices of active recurring subscriptions, before credits
+	MostExpensiveService *string // Of active recurring subscriptions, nil if none
+	EarliestStart        *time.Time
+	LatestStart          *time.Time
+}
+
+// SubscriptionCounts counts live subscriptions of a group, Active covers ones active in a given month
+type SubscriptionCounts struct {
+	Total  int64
+	Active int64
+}
+
+const (
+	PurgeSubjectSubscription = "subscription"
+	PurgeSubjectUser         = "user"
+)
+
+// PurgeRecord is the audit trail of a permanent deletion, it keeps only IDs and counts of what was removed
+type PurgeRecord struct {
+	ID            uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	Subject       string    `json:"subject"`    // One of PurgeSubject* constants
+	SubjectID     uuid.UUID `json:"subject_id"` // Subscription or user ID
+	Subscriptions int       `json:"subscriptions"`
+	Views         int       `json:"views"`
+	PurgedAt      time.Time `json:"purged_at" gorm:"autoCreateTime"`
+}
+
+const (
+	BulkDeleteStatusPending = "pending"
+	BulkDeleteStatusRunning = "running"
+	BulkDeleteStatusDone    = "done"
+	BulkDeleteStatusFailed  = "failed"
+)
+
+// BulkDeleteJob soft-deletes subscriptions matching its filter in batches, in the background.
+// It matches subscriptions whose whole period lies within [StartDate, EndDate], open-ended ones only without EndDate.
+type BulkDeleteJob struct {
+	ID          uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	Status      string     `json:"status"` // One of BulkDeleteStatus* constants
+	UserID      *uuid.UUID `json:"user_id,omitempty" gorm:"type:uuid"`
+	ServiceName *string    `json:"service_name,omitempty"`
+	StartDate   *time.Time `json:"start_date,omitempty"`
+	EndDate     *time.Time `json:"end_date,omitempty"`
+	Matched     int64      `json:"matched"` // Subscriptions matching the filter when the job was created
+	Deleted     int64      `json:"deleted"`
+	Error       *string    `json:"error,omitempty"` // Why the job failed, subscriptions deleted before stay deleted
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at"` // Bumped after every batch
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// ServiceRename is the audit trail of a service renamed across subscriptions, e.g. after the provider rebranded.
+// It's recorded before the first batch, FinishedAt stays nil if a batch failed and only Renamed subscriptions got the new name.
+type ServiceRename struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey"`
+	FromName   string     `json:"from"`
+	ToName     string     `json:"to"`
+	OrgUnitID  *uuid.UUID `json:"org_unit_id,omitempty" gorm:"type:uuid"` // Only subscriptions of the unit and its descendants were renamed
+	Renamed    int64      `json:"renamed"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
 }
 
 type SubscriptionFilter struct {
-	UserID      *uuid.UUID
-	ServiceName *string
-	Limit       *int
-	Offset      *int
+	UserID          *uuid.UUID
+	ServiceName     *string
+	ServiceNameFold bool    // ServiceName matches case-insensitively
+	ServiceNameLike *string // Case-insensitive pattern, * matches any characters, without * it matches a substring
+	CreatedAfter    *time.Time
+	CreatedBefore   *time.Time
+	ActiveAt        *time.Time // First day of a month the subscription period must cover
+	Status          *string    // One of Status* constants in StatusAt, deleted ones are listed only with StatusDeleted
+	StatusAt        time.Time  // First day of the current month
+	MinPrice        *int
+	MaxPrice        *int
+	Limit           *int
+	Offset          *int
+	SortBy          string // One of SortBy* constants, created_at if empty
+	SortDesc        bool
+}
+
+// SyncPlan is a set of changes to one user's subscriptions applied in a single transaction
+type SyncPlan struct {
+	Create []*Subscription
+	Update []*Subscription
+	Delete []*Subscription
 }
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/config"
+	"subscription-aggregator-service/internal/logger"
+	"subscription-aggregator-service/internal/models"
+	"subscription-aggregator-service/internal/storage"
+	"subscription-aggregator-service/internal/utils/dates"
+)
+
+// bulkDeleteStaleAfter is how long a running job may go without finishing a batch before another runner takes it over
+const bulkDeleteStaleAfter = time.Minute
+
+// StartBulkDelete records a job deleting subscriptions matching the filter and starts it in the background.
+// The returned job has the number of matching subscriptions, its progress is available via GetBulkDeleteJob.
+func (ss *SubscriptionServiceImpl) StartBulkDelete(ctx context.Context, req *apiModels.BulkDeleteRequest) (*models.BulkDeleteJob, error) {
+	log := logger.FromContext(ctx)
+	if err := req.Validate(); err != nil {
+		log.Warn("failed to validate bulk delete payload", "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrValidationError, err)
+	}
+
+	job := &models.BulkDeleteJob{ID: uuid.New(), Status: models.BulkDeleteStatusPending}
+	if req.UserID != nil {
+		userID := uuid.MustParse(*req.UserID) // Validated above
+		job.UserID = &userID
+	}
+	if req.ServiceName != nil {
+		serviceName := strings.TrimSpace(*req.ServiceName)
+		job.ServiceName = &serviceName
+	}
+	var err error
+	if job.StartDate, err = bulkDeleteDate(ctx, req.StartDate, "start date"); err != nil {
+		return nil, err
+	}
+	if job.EndDate, err = bulkDeleteDate(ctx, req.EndDate, "end date"); err != nil {
+		return nil, err
+	}
+	if job.StartDate != nil && job.EndDate != nil && job.EndDate.Before(*job.StartDate) {
+		log.Warn("bulk delete end date before start date")
+		return nil, fmt.Errorf("%w: end date must not be before start date", ErrValidationError)
+	}
+
+	if err = ss.storage.CreateBulkDeleteJob(ctx, job); err != nil {
+		log.Error("failed to create bulk delete job in database", "error", err)
+		return nil, err
+	}
+
+	run := *job // The caller gets its own copy to serialize
+	go ss.runBulkDelete(context.WithoutCancel(ctx), &run)
+	log.Info("bulk delete started", "id", job.ID, "matched", job.Matched)
+	return job, nil
+}
+
+func bulkDeleteDate(ctx context.Context, value *string, name string) (*time.Time, error) {
+	log := logger.FromContext(ctx)
+	if value == nil {
+		return nil, nil
+	}
+	t, err := dates.String2Date(*value)
+	if err != nil {
+		log.Warn("failed to parse bulk delete date", "field", name, "error", err)
+		return nil, fmt.Errorf("%w: invalid %s format, expected MM-YYYY", ErrValidationError, name)
+	}
+	return &t, nil
+}
+
+func (ss *SubscriptionServiceImpl) GetBulkDeleteJob(ctx context.Context, id apiModels.ItemByIDRequest) (*models.BulkDeleteJob, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		log.Warn("failed to validate bulk delete job id", "error", err)
+		return nil, fmt.Errorf("%w: invalid job UUID", ErrValidationError)
+	}
+
+	job, err := ss.storage.GetBulkDeleteJobByID(ctx, uid)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Warn("requested bulk delete job not found", "id", uid)
+			return nil, ErrBulkDeleteNotFound
+		}
+		log.Error("failed to get bulk delete job from database", "error", err)
+		return nil, err
+	}
+	return job, nil
+}
+
+// ResumeBulkDeletes runs jobs left pending or abandoned by a stopped replica, one after another
+func (ss *SubscriptionServiceImpl) ResumeBulkDeletes(ctx context.Context) error {
+	log := logger.FromContext(ctx)
+	jobs, err := ss.storage.ListUnfinishedBulkDeleteJobs(ctx)
+	if err != nil {
+		log.Error("failed to list unfinished bulk delete jobs from database", "error", err)
+		return err
+	}
+	for i := range jobs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		ss.runBulkDelete(ctx, &jobs[i])
+	}
+	return nil
+}
+
+// runBulkDelete claims the job and deletes its subscriptions batch by batch, each batch in its own transaction.
+// A rejection by a hook or a storage error fails the job, batches deleted before stay deleted.
+// If ctx is done the job is left running, so another runner resumes it once it's stale.
+func (ss *SubscriptionServiceImpl) runBulkDelete(ctx context.Context, job *models.BulkDeleteJob) {
+	log := logger.FromContext(ctx)
+	claimed, err := ss.storage.ClaimBulkDeleteJob(ctx, job.ID, time.Now().Add(-bulkDeleteStaleAfter))
+	if err != nil {
+		log.Error("failed to claim bulk delete job in database", "id", job.ID, "error", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	batchSize := viper.GetInt(config.BulkDeleteBatchSize)
+	for {
+		batch, err := ss.storage.DeleteBulkDeleteBatch(ctx, job, batchSize, func(batch []models.Subscription) error {
+			for i := range batch {
+				if err := ss.hooks.preDelete(ctx, &batch[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				log.Warn("bulk delete interrupted", "id", job.ID, "error", err)
+				return
+			}
+			log.Error("bulk delete failed", "id", job.ID, "error", err)
+			ss.finishBulkDelete(ctx, job.ID, models.BulkDeleteStatusFailed, err)
+			return
+		}
+
+		if len(batch) > 0 {
+			ss.aggregatesChanged()
+			events := make([]HookEvent, len(batch))
+			for i := range batch {
+				events[i] = HookEvent{Action: HookActionDelete, Before: &batch[i]}
+			}
+			ss.hooks.postCommit(ctx, events...)
+			log.Debug("bulk delete batch deleted", "id", job.ID, "subscriptions", len(batch))
+		}
+		if len(batch) == 0 {
+			break
+		}
+	}
+
+	ss.finishBulkDelete(ctx, job.ID, models.BulkDeleteStatusDone, nil)
+	log.Info("bulk delete finished", "id", job.ID)
+}
+
+func (ss *SubscriptionServiceImpl) finishBulkDelete(ctx context.Context, id uuid.UUID, status string, cause error) {
+	log := logger.FromContext(ctx)
+	var errMsg *string
+	if cause != nil {
+		msg := cause.Error()
+		if !errors.Is(cause, ErrValidationError) { // Hook rejections are meant for the client, anything else stays in logs
+			msg = ErrIES.Error()
+		}
+		errMsg = &msg
+	}
+	if err := ss.storage.FinishBulkDeleteJob(ctx, id, status, errMsg); err != nil {
+		log.Error("failed to finish bulk delete job in database", "id", id, "status", status, "error", err)
+	}
+}
+
+// RunBulkDeletes resumes unfinished bulk delete jobs right away and then every interval until ctx is done
+func RunBulkDeletes(ctx context.Context, svc SubscriptionService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_ = svc.ResumeBulkDeletes(ctx) // Logged inside, next tick retries
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/logger"
+	"subscription-aggregator-service/internal/models"
+	"subscription-aggregator-service/internal/utils/dates"
+)
+
+// Chargeback splits spend over the period by allocation tag and month, for finance to charge internal teams.
+// Rows are ordered by tag then month, untagged spend comes first with empty tag, months without spend are omitted.
+func (ss *SubscriptionServiceImpl) Chargeback(ctx context.Context, req apiModels.ChargebackRequest) ([]apiModels.ChargebackRow, error) {
+	log := logger.FromContext(ctx)
+	if req.By != apiModels.AllocationCostCenter && req.By != apiModels.AllocationProjectCode {
+		log.Warn("failed to validate chargeback grouping", "by", req.By)
+		return nil, fmt.Errorf("%w: by must be %q or %q", ErrValidationError, apiModels.AllocationCostCenter, apiModels.AllocationProjectCode)
+	}
+	filter, startDate, endDate, err := parseTotalCostRequest(ctx, apiModels.TotalCostRequest{UserID: req.UserID, StartDate: req.StartDate, EndDate: req.EndDate})
+	if err != nil {
+		return nil, err
+	}
+
+	subs, err := ss.storage.ListSubscriptionsInPeriod(ctx, filter, startDate, endDate)
+	if err != nil {
+		log.Error("failed to list subscriptions from database", "error", err)
+		return nil, err
+	}
+
+	type key struct {
+		allocation string
+		month      string
+	}
+	costs := make(map[key]int64)
+	for _, sub := range subs {
+		allocation := allocationTag(&sub, req.By)
+		for month := startDate; !month.After(endDate); month = month.AddDate(0, 1, 0) {
+			if cost := calculateSubscriptionCost(sub, month, month); cost != 0 {
+				costs[key{allocation, month.Format(dates.Layout)}] += cost
+			}
+		}
+	}
+
+	rows := make([]apiModels.ChargebackRow, 0, len(costs))
+	for k, cost := range costs {
+		rows = append(rows, apiModels.ChargebackRow{Allocation: k.allocation, Month: k.month, Cost: cost})
+	}
+	slices.SortFunc(rows, func(a, b apiModels.ChargebackRow) int {
+		if c := strings.Compare(a.Allocation, b.Allocation); c != 0 {
+			return c
+		}
+		am, _ := dates.String2Date(a.Month)
+		bm, _ := dates.String2Date(b.Month)
+		return cmp.Compare(am.Unix(), bm.Unix())
+	})
+
+	log.Debug("chargeback calculated", "by", req.By, "start", req.StartDate, "end", req.EndDate, "rows", len(rows))
+	return rows, nil
+}
+
+func allocationTag(sub *models.Subscription, by string) string {
+	tag := sub.CostCenter
+	if by == apiModels.AllocationProjectCode {
+		tag = sub.ProjectCode
+	}
+	if tag == nil {
+		return ""
+	}
+	return *tag
+}
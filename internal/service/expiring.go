@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/logger"
+	"subscription-aggregator-service/internal/utils/dates"
+)
+
+const (
+	defaultExpiringWindowDays = 30
+	maxExpiringWindowDays     = 366
+)
+
+// ListExpiringSubscriptions groups by user recurring subscriptions ending from the current month to the month window days ahead,
+// for campaigns asking users whether they'll renew. A subscription counts as renewed if the user has another one
+// to the same service starting no later than the month after it ends; open-ended ones never expire.
+func (ss *SubscriptionServiceImpl) ListExpiringSubscriptions(ctx context.Context, req apiModels.ExpiringSubscriptionsRequest) (*apiModels.ExpiringSubscriptionsResponse, error) {
+	log := logger.FromContext(ctx)
+	days, err := parseWindowDays(req.Window)
+	if err != nil {
+		log.Warn("failed to validate expiring window", "window", req.Window, "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrValidationError, err)
+	}
+
+	now := time.Now().UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	last := now.AddDate(0, 0, days)
+	to := time.Date(last.Year(), last.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	subs, err := ss.storage.ListExpiringSubscriptions(ctx, from, to, req.AutoRenew)
+	if err != nil {
+		log.Error("failed to list expiring subscriptions from database", "error", err)
+		return nil, err
+	}
+
+	ser := apiModels.SerializationFromContext(ctx)
+	resp := &apiModels.ExpiringSubscriptionsResponse{From: from.Format(dates.Layout), To: to.Format(dates.Layout), Users: []apiModels.ExpiringUser{}, TotalCount: len(subs)}
+	for i := range subs { // Sorted by user
+		if n := len(resp.Users); n == 0 || resp.Users[n-1].UserID != subs[i].UserID {
+			resp.Users = append(resp.Users, apiModels.ExpiringUser{UserID: subs[i].UserID})
+		}
+		user := &resp.Users[len(resp.Users)-1]
+		user.Subscriptions = append(user.Subscriptions, *apiModels.NewSubscriptionResponse(&subs[i], ser))
+	}
+
+	log.Debug("expiring subscriptions listed", "from", resp.From, "to", resp.To, "auto_renew", req.AutoRenew, "users", len(resp.Users), "total", resp.TotalCount)
+	return resp, nil
+}
+
+// parseWindowDays parses a window like "60d", empty is the default one
+func parseWindowDays(window string) (int, error) {
+	if window == "" {
+		return defaultExpiringWindowDays, nil
+	}
+	days, err := strconv.Atoi(strings.TrimSuffix(window, "d"))
+	if err != nil || !strings.HasSuffix(window, "d") || days < 1 || days > maxExpiringWindowDays {
+		return 0, fmt.Errorf("window must be a number of days from 1d to %dd", maxExpiringWindowDays)
+	}
+	return days, nil
+}
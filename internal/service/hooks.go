@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"plugin"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/logger"
+	"subscription-aggregator-service/internal/models"
+)
+
+// Hook lets a deployment enforce its own rules on subscription changes without forking the service.
+// Pre* methods run before the change is stored, an error rejects it as a validation error with the error's text.
+// PostCommit runs synchronously after the change is stored and can't undo it, so slow work belongs in a goroutine.
+// Restoring a deletion with an undo token counts as a creation, a plan price reaching subscriptions as their updates.
+// Purges for erasure requests bypass hooks: a rule can't refuse erasure, and the events would pass on the data being erased.
+// Embed NopHook to implement only some of the methods.
+type Hook interface {
+	PreCreate(ctx context.Context, sub *models.Subscription) error
+	PreUpdate(ctx context.Context, before, after *models.Subscription) error
+	PreDelete(ctx context.Context, sub *models.Subscription) error
+	PostCommit(ctx context.Context, event HookEvent)
+}
+
+const (
+	HookActionCreate = "create"
+	HookActionUpdate = "update"
+	HookActionDelete = "delete"
+)
+
+// HookEvent describes a stored change, Before is nil for creations and After is nil for deletions
+type HookEvent struct {
+	Action string
+	Before *models.Subscription
+	After  *models.Subscription
+}
+
+type NopHook struct{}
+
+func (NopHook) PreCreate(context.Context, *models.Subscription) error { return nil }
+func (NopHook) PreUpdate(context.Context, *models.Subscription, *models.Subscription) error {
+	return nil
+}
+func (NopHook) PreDelete(context.Context, *models.Subscription) error { return nil }
+func (NopHook) PostCommit(context.Context, HookEvent)                 {}
+
+// HookSymbol is the name of the variable of type Hook a plugin has to export
+const HookSymbol = "Hook"
+
+// LoadHookPlugin opens a plugin built with -buildmode=plugin against the same sources and toolchain as the service.
+// Plugins need a cgo-enabled build of the service, the Docker image is built without cgo and can't load them.
+func LoadHookPlugin(path string) (Hook, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hook plugin %s: %w", path, err)
+	}
+	sym, err := p.Lookup(HookSymbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load hook plugin %s: %w", path, err)
+	}
+	switch h := sym.(type) {
+	case *Hook: // var Hook service.Hook = ...
+		if *h == nil {
+			return nil, fmt.Errorf("failed to load hook plugin %s: %s is nil", path, HookSymbol)
+		}
+		return *h, nil
+	case Hook: // var Hook = myHook{} with pointer receivers
+		return h, nil
+	default:
+		return nil, fmt.Errorf("failed to load hook plugin %s: %s is %T, not service.Hook", path, HookSymbol, sym)
+	}
+}
+
+// hooks runs registered hooks in order, the first rejection stops the change
+type hooks []Hook
+
+func (hs hooks) preCreate(ctx context.Context, sub *models.Subscription) error {
+	log := logger.FromContext(ctx)
+	for _, h := range hs {
+		if err := h.PreCreate(ctx, sub); err != nil {
+			log.Warn("subscription creation rejected by hook", "user_id", sub.UserID, "error", err)
+			return fmt.Errorf("%w: %w", ErrValidationError, err)
+		}
+	}
+	return nil
+}
+
+func (hs hooks) preUpdate(ctx context.Context, before, after *models.Subscription) error {
+	log := logger.FromContext(ctx)
+	for _, h := range hs {
+		if err := h.PreUpdate(ctx, before, after); err != nil {
+			log.Warn("subscription update rejected by hook", "id", before.ID, "error", err)
+			return fmt.Errorf("%w: %w", ErrValidationError, err)
+		}
+	}
+	return nil
+}
+
+func (hs hooks) preDelete(ctx context.Context, sub *models.Subscription) error {
+	log := logger.FromContext(ctx)
+	for _, h := range hs {
+		if err := h.PreDelete(ctx, sub); err != nil {
+			log.Warn("subscription deletion rejected by hook", "id", sub.ID, "error", err)
+			return fmt.Errorf("%w: %w", ErrValidationError, err)
+		}
+	}
+	return nil
+}
+
+func (hs hooks) postCommit(ctx context.Context, events ...HookEvent) {
+	for _, h := range hs {
+		for _, e := range events {
+			h.PostCommit(ctx, e)
+		}
+	}
+}
+
+// preSync runs pre-hooks for every change of a sync plan
+func (ss *SubscriptionServiceImpl) preSync(ctx context.Context, changes []HookEvent) error {
+	for _, c := range changes {
+		var err error
+		switch c.Action {
+		case HookActionCreate:
+			err = ss.hooks.preCreate(ctx, c.After)
+		case HookActionUpdate:
+			err = ss.hooks.preUpdate(ctx, c.Before, c.After)
+		case HookActionDelete:
+			err = ss.hooks.preDelete(ctx, c.Before)
+		}
+		if err != nil {
+			return fmt.Errorf("%w (external ID %s)", err, syncExternalID(c))
+		}
+	}
+	return nil
+}
+
+func creationEvents(subs []*models.Subscription) []HookEvent {
+	events := make([]HookEvent, len(subs))
+	for i, sub := range subs {
+		events[i] = HookEvent{Action: HookActionCreate, After: sub}
+	}
+	return events
+}
+
+// syncChanges maps changes of a sync plan to the response
+func syncChanges(events []HookEvent, ser apiModels.Serialization) []apiModels.SyncChange {
+	changes := make([]apiModels.SyncChange, len(events))
+	for i, e := range events {
+		changes[i] = apiModels.SyncChange{Action: e.Action, ExternalID: syncExternalID(e)}
+		if e.Before != nil {
+			changes[i].Before = apiModels.NewSubscriptionResponse(e.Before, ser)
+		}
+		if e.After != nil {
+			changes[i].After = apiModels.NewSubscriptionResponse(e.After, ser)
+		}
+	}
+	return changes
+}
+
+// syncExternalID is the external ID sync matched the change by
+func syncExternalID(e HookEvent) string {
+	if e.After != nil {
+		return *e.After.ExternalID
+	}
+	return *e.Before.ExternalID
+}
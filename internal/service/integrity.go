@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"subscription-aggregator-service/internal/logger"
+	"subscription-aggregator-service/internal/models"
+)
+
+// CheckIntegrity scans subscriptions for anomalies, records them and returns all current findings
+func (ss *SubscriptionServiceImpl) CheckIntegrity(ctx context.Context) ([]models.IntegrityFinding, error) {
+	log := logger.FromContext(ctx)
+	ran, err := ss.storage.CheckIntegrity(ctx)
+	if err != nil {
+		log.Error("failed to check integrity in database", "error", err)
+		return nil, err
+	}
+	if !ran {
+		log.Info("integrity check skipped, already running elsewhere")
+	}
+
+	findings, err := ss.ListIntegrityFindings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ran && len(findings) > 0 {
+		log.Warn("integrity check found anomalies", "findings", len(findings))
+	} else if ran {
+		log.Info("integrity check found no anomalies")
+	}
+	return findings, nil
+}
+
+func (ss *SubscriptionServiceImpl) ListIntegrityFindings(ctx context.Context) ([]models.IntegrityFinding, error) {
+	log := logger.FromContext(ctx)
+	findings, err := ss.storage.ListIntegrityFindings(ctx)
+	if err != nil {
+		log.Error("failed to list integrity findings from database", "error", err)
+		return nil, err
+	}
+	return findings, nil
+}
+
+// RunIntegrityChecks checks integrity right away and then every interval until ctx is done
+func RunIntegrityChecks(ctx context.Context, svc SubscriptionService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, _ = svc.CheckIntegrity(ctx) // Logged inside, next tick retries
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/logger"
+	"subscription-aggregator-service/internal/models"
+	"subscription-aggregator-service/internal/storage"
+	"subscription-aggregator-service/internal/utils/dates"
+)
+
+func (ss *SubscriptionServiceImpl) CreateOrgUnit(ctx context.Context, req *apiModels.CreateOrgUnitRequest) (*models.OrgUnit, error) {
+	log := logger.FromContext(ctx)
+	if err := req.Validate(); err != nil {
+		log.Warn("failed to validate org unit payload", "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrValidationError, err)
+	}
+
+	unit := &models.OrgUnit{
+		ID:        uuid.New(),
+		Name:      strings.TrimSpace(req.Name),
+		CreatedAt: time.Now(),
+	}
+	if req.ParentID != nil {
+		parentID, err := ss.checkOrgUnit(ctx, *req.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		unit.ParentID = parentID
+	}
+
+	if err := ss.storage.CreateOrgUnit(ctx, unit); err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			log.Warn("org unit with requested name already exists", "parent_id", unit.ParentID, "name", unit.Name)
+			return nil, ErrOrgUnitConflict
+		}
+		log.Error("failed to create org unit in database", "error", err)
+		return nil, err
+	}
+
+	log.Info("org unit created", "id", unit.ID, "parent_id", unit.ParentID, "name", unit.Name)
+	return unit, nil
+}
+
+func (ss *SubscriptionServiceImpl) ListOrgUnits(ctx context.Context) ([]models.OrgUnit, error) {
+	log := logger.FromContext(ctx)
+	units, err := ss.storage.ListOrgUnits(ctx)
+	if err != nil {
+		log.Error("failed to list org units from database", "error", err)
+		return nil, err
+	}
+	if units == nil {
+		units = []models.OrgUnit{}
+	}
+	return units, nil
+}
+
+// OrgUnitTotalCost computes cost of the unit over the period and rolls up costs of its descendants, returned as a tree
+func (ss *SubscriptionServiceImpl) OrgUnitTotalCost(ctx context.Context, id apiModels.ItemByIDRequest, req apiModels.OrgUnitCostRequest) (*apiModels.OrgUnitCost, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		log.Warn("failed to validate org unit id", "error", err)
+		return nil, fmt.Errorf("%w: invalid org unit UUID", ErrValidationError)
+	}
+
+	_, startDate, endDate, err := parseTotalCostRequest(ctx, apiModels.TotalCostRequest{StartDate: req.StartDate, EndDate: req.EndDate})
+	if err != nil {
+		return nil, err
+	}
+
+	byID, children, subtree, err := ss.orgUnitSubtree(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	costs, err := ss.storage.TotalSubscriptionsCostByOrgUnit(ctx, subtree, startDate, endDate)
+	if err != nil {
+		log.Error("failed to calculate org unit costs in database", "error", err)
+		return nil, err
+	}
+
+	var rollup func(id uuid.UUID) apiModels.OrgUnitCost
+	rollup = func(id uuid.UUID) apiModels.OrgUnitCost {
+		node := apiModels.OrgUnitCost{ID: id, Name: byID[id].Name, OwnCost: costs[id], TotalCost: costs[id], Children: []apiModels.OrgUnitCost{}}
+		for _, childID := range children[id] {
+			child := rollup(childID)
+			node.TotalCost += child.TotalCost
+			node.Children = append(node.Children, child)
+		}
+		return node
+	}
+	resp := rollup(uid)
+
+	log.Debug("org unit cost calculated", "id", uid, "units", len(subtree), "total", resp.TotalCost)
+	return &resp, nil
+}
+
+// OrgUnitUsage counts subscriptions of the unit and its descendants and their spend in the current month.
+// Results are cached for app.metrics.cache_ttl, so frequent scrapes don't reach the database.
+func (ss *SubscriptionServiceImpl) OrgUnitUsage(ctx context.Context, id apiModels.ItemByIDRequest) (*apiModels.OrgUnitUsage, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		log.Warn("failed to validate org unit id", "error", err)
+		return nil, fmt.Errorf("%w: invalid org unit UUID", ErrValidationError)
+	}
+	if ss.usageCache != nil {
+		if usage, ok := ss.usageCache.Get(uid); ok {
+			return usage, nil
+		}
+	}
+
+	byID, _, subtree, err := ss.orgUnitSubtree(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	counts, err := ss.storage.CountSubscriptionsByOrgUnit(ctx, subtree, month)
+	if err != nil {
+		log.Error("failed to count org unit subscriptions in database", "error", err)
+		return nil, err
+	}
+	costs, err := ss.storage.TotalSubscriptionsCostByOrgUnit(ctx, subtree, month, month)
+	if err != nil {
+		log.Error("failed to calculate org unit costs in database", "error", err)
+		return nil, err
+	}
+
+	usage := &apiModels.OrgUnitUsage{ID: uid, Name: byID[uid].Name, Month: month.Format(dates.Layout)}
+	for _, unitID := range subtree {
+		usage.Subscriptions += counts[unitID].Total
+		usage.ActiveSubscriptions += counts[unitID].Active
+		usage.MonthlySpend += costs[unitID]
+	}
+	if ss.usageCache != nil {
+		ss.usageCache.Set(uid, usage)
+	}
+
+	log.Debug("org unit usage calculated", "id", uid, "units", len(subtree), "subscriptions", usage.Subscriptions)
+	return usage, nil
+}
+
+// orgUnitSubtree loads all units and returns the IDs of the unit and its descendants, the unit first
+func (ss *SubscriptionServiceImpl) orgUnitSubtree(ctx context.Context, uid uuid.UUID) (map[uuid.UUID]models.OrgUnit, map[uuid.UUID][]uuid.UUID, []uuid.UUID, error) {
+	log := logger.FromContext(ctx)
+	units, err := ss.storage.ListOrgUnits(ctx)
+	if err != nil {
+		log.Error("failed to list org units from database", "error", err)
+		return nil, nil, nil, err
+	}
+	byID := make(map[uuid.UUID]models.OrgUnit, len(units))
+	children := make(map[uuid.UUID][]uuid.UUID, len(units))
+	for _, u := range units { // Listed by name, so children come out sorted
+		byID[u.ID] = u
+		if u.ParentID != nil {
+			children[*u.ParentID] = append(children[*u.ParentID], u.ID)
+		}
+	}
+	if _, ok := byID[uid]; !ok {
+		log.Warn("requested org unit not found", "id", uid)
+		return nil, nil, nil, ErrOrgUnitNotFound
+	}
+
+	subtree := []uuid.UUID{uid}
+	for i := 0; i < len(subtree); i++ { // Parents are set once on creation, so the tree has no cycles
+		subtree = append(subtree, children[subtree[i]]...)
+	}
+	return byID, children, subtree, nil
+}
+
+// checkOrgUnit parses a referenced org unit ID and makes sure the unit exists
+func (ss *SubscriptionServiceImpl) checkOrgUnit(ctx context.Context, id string) (*uuid.UUID, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		log.Warn("failed to validate org unit id", "error", err)
+		return nil, fmt.Errorf("%w: org unit ID must be a valid UUID", ErrValidationError)
+	}
+	if _, err = ss.storage.GetOrgUnitByID(ctx, uid); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Warn("requested org unit not found", "org_unit_id", uid)
+			return nil, fmt.Errorf("%w: unknown org unit", ErrValidationError)
+		}
+		log.Error("failed to get org unit from database", "error", err)
+		return nil, err
+	}
+	return &uid, nil
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/logger"
+	"subscription-aggregator-service/internal/models"
+	"subscription-aggregator-service/internal/storage"
+	"subscription-aggregator-service/internal/utils/dates"
+)
+
+// PauseSubscription stops charging the subscription from the current month or req.Month until it's resumed.
+// The month must fall within the subscription and after its earlier pauses, a paused subscription can't be paused again.
+func (ss *SubscriptionServiceImpl) PauseSubscription(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.PauseSubscriptionRequest) (*models.PausedPeriod, error) {
+	log := logger.FromContext(ctx)
+	sub, month, err := ss.pauseTarget(ctx, id, req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case sub.Type == models.TypeOneTime:
+		log.Warn("pause of one-time purchase", "id", sub.ID)
+		return nil, fmt.Errorf("%w: one-time purchase cannot be paused", ErrValidationError)
+	case month.Before(sub.StartDate):
+		log.Warn("pause before subscription start", "id", sub.ID, "month", month.Format(dates.Layout))
+		return nil, fmt.Errorf("%w: subscription cannot be paused before its start date %s", ErrValidationError, sub.StartDate.Format(dates.Layout))
+	case sub.EndDate != nil && month.After(*sub.EndDate):
+		log.Warn("pause after subscription end", "id", sub.ID, "month", month.Format(dates.Layout))
+		return nil, fmt.Errorf("%w: subscription ends in %s", ErrValidationError, sub.EndDate.Format(dates.Layout))
+	}
+
+	pauses, err := ss.storage.ListPausedPeriods(ctx, sub.ID)
+	if err != nil {
+		log.Error("failed to list pauses from database", "error", err)
+		return nil, err
+	}
+	for _, p := range pauses {
+		if p.EndDate == nil {
+			log.Warn("subscription already paused", "id", sub.ID)
+			return nil, ErrAlreadyPaused
+		}
+		if !p.EndDate.Before(month) {
+			log.Warn("pause overlaps earlier one", "id", sub.ID, "month", month.Format(dates.Layout))
+			return nil, fmt.Errorf("%w: subscription was paused until %s", ErrValidationError, p.EndDate.Format(dates.Layout))
+		}
+	}
+
+	pause := &models.PausedPeriod{ID: uuid.New(), SubscriptionID: sub.ID, StartDate: month, CreatedAt: time.Now()}
+	if err = ss.storage.CreatePausedPeriod(ctx, pause); err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			log.Warn("subscription already paused", "id", sub.ID)
+			return nil, ErrAlreadyPaused
+		}
+		log.Error("failed to create pause in database", "error", err)
+		return nil, err
+	}
+
+	ss.aggregatesChanged()
+	log.Info("subscription paused", "id", sub.ID, "month", month.Format(dates.Layout))
+	return pause, nil
+}
+
+// ResumeSubscription charges the paused subscription again from the current month or req.Month, the pause ends a month before.
+// A pause resumed in or before its first month paused nothing and is dropped.
+func (ss *SubscriptionServiceImpl) ResumeSubscription(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.PauseSubscriptionRequest) (*models.PausedPeriod, error) {
+	log := logger.FromContext(ctx)
+	sub, month, err := ss.pauseTarget(ctx, id, req)
+	if err != nil {
+		return nil, err
+	}
+
+	pause, err := ss.storage.ResumePausedPeriod(ctx, sub.ID, month.AddDate(0, -1, 0))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Warn("subscription is not paused", "id", sub.ID)
+			return nil, ErrNotPaused
+		}
+		log.Error("failed to resume pause in database", "error", err)
+		return nil, err
+	}
+
+	ss.aggregatesChanged()
+	log.Info("subscription resumed", "id", sub.ID, "month", month.Format(dates.Layout))
+	return pause, nil
+}
+
+// pauseTarget validates a pause or resume request and returns the subscription and the month, current one by default
+func (ss *SubscriptionServiceImpl) pauseTarget(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.PauseSubscriptionRequest) (*models.Subscription, time.Time, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		log.Warn("failed to validate subscription id", "error", err)
+		return nil, time.Time{}, fmt.Errorf("%w: invalid subscription UUID", ErrValidationError)
+	}
+
+	month, err := req.ParseMonth()
+	if err != nil {
+		log.Warn("failed to validate pause payload", "error", err)
+		return nil, time.Time{}, fmt.Errorf("%w: %s", ErrValidationError, err.Error())
+	}
+	if month == nil {
+		now := time.Now().UTC()
+		current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		month = &current
+	}
+
+	sub, err := ss.storage.GetSubscriptionByID(ctx, uid)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Warn("requested subscription not found", "error", err)
+			return nil, time.Time{}, ErrNotFound
+		}
+		log.Error("failed to get subscription from database", "error", err)
+		return nil, time.Time{}, err
+	}
+	return sub, *month, nil
+}
+
+func (ss *SubscriptionServiceImpl) ListPausedPeriods(ctx context.Context, id apiModels.ItemByIDRequest) ([]models.PausedPeriod, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		log.Warn("failed to validate subscription id", "error", err)
+		return nil, fmt.Errorf("%w: invalid subscription UUID", ErrValidationError)
+	}
+
+	if _, err = ss.storage.GetSubscriptionByID(ctx, uid); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Warn("requested subscription not found", "error", err)
+			return nil, ErrNotFound
+		}
+		log.Error("failed to get subscription from database", "error", err)
+		return nil, err
+	}
+
+	pauses, err := ss.storage.ListPausedPeriods(ctx, uid)
+	if err != nil {
+		log.Error("failed to list pauses from database", "error", err)
+		return nil, err
+	}
+	if pauses == nil {
+		pauses = []models.PausedPeriod{}
+	}
+	return pauses, nil
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/logger"
+	"subscription-aggregator-service/internal/models"
+	"subscription-aggregator-service/internal/storage"
+)
+
+func (ss *SubscriptionServiceImpl) CreatePlan(ctx context.Context, req *apiModels.CreatePlanRequest) (*models.Plan, error) {
+	log := logger.FromContext(ctx)
+	if err := req.Validate(); err != nil {
+		log.Warn("failed to validate plan payload", "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrValidationError, err)
+	}
+
+	plan := &models.Plan{
+		ID:          uuid.New(),
+		ServiceName: strings.TrimSpace(req.ServiceName),
+		Name:        strings.TrimSpace(req.Name),
+		Price:       req.Price,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if err := ss.storage.CreatePlan(ctx, plan); err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			log.Warn("plan with requested name already exists", "service_name", plan.ServiceName, "name", plan.Name)
+			return nil, ErrPlanConflict
+		}
+		log.Error("failed to create plan in database", "error", err)
+		return nil, err
+	}
+
+	log.Info("plan created", "id", plan.ID, "service_name", plan.ServiceName, "name", plan.Name)
+	return plan, nil
+}
+
+func (ss *SubscriptionServiceImpl) ListPlans(ctx context.Context) ([]models.Plan, error) {
+	log := logger.FromContext(ctx)
+	plans, err := ss.storage.ListPlans(ctx)
+	if err != nil {
+		log.Error("failed to list plans from database", "error", err)
+		return nil, err
+	}
+	if plans == nil {
+		plans = []models.Plan{}
+	}
+	return plans, nil
+}
+
+// UpdatePlanPrice records an official price change and propagates it to subscriptions that opted in with follow_plan_price.
+// Subscriptions that ended before the current month keep their price, running ones get it in place under the same ID.
+// Pre-update hooks see every propagated change, one rejection cancels the price change with all of them.
+func (ss *SubscriptionServiceImpl) UpdatePlanPrice(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.UpdatePlanPriceRequest) (*apiModels.UpdatePlanPriceResponse, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		log.Warn("failed to validate plan id", "error", err)
+		return nil, fmt.Errorf("%w: invalid plan UUID", ErrValidationError)
+	}
+
+	if err = req.Validate(); err != nil {
+		log.Warn("failed to validate plan payload", "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrValidationError, err)
+	}
+
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	plan, before, after, err := ss.storage.UpdatePlanPrice(ctx, uid, *req.Price, month, func(before []models.Subscription) error {
+		for i := range before {
+			changed := before[i]
+			changed.Price, changed.UpdatedAt = *req.Price, now
+			changed.Version++
+			if hookErr := ss.hooks.preUpdate(ctx, &before[i], &changed); hookErr != nil {
+				return hookErr
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Warn("requested plan not found", "error", err)
+			return nil, ErrPlanNotFound
+		} else if errors.Is(err, ErrValidationError) { // Rejected by a hook, already logged
+			return nil, err
+		}
+		log.Error("failed to update plan price in database", "error", err)
+		return nil, err
+	}
+
+	ss.aggregatesChanged()
+	if len(after) > 0 {
+		events := make([]HookEvent, len(after))
+		for i := range after {
+			events[i] = HookEvent{Action: HookActionUpdate, Before: &before[i], After: &after[i]}
+		}
+		ss.hooks.postCommit(ctx, events...)
+	}
+	log.Info("plan price updated", "id", uid, "price", plan.Price, "propagated", len(after))
+	return &apiModels.UpdatePlanPriceResponse{Plan: plan, Propagated: int64(len(after))}, nil
+}
+
+// applyPlan prefills omitted service name and price from the referenced plan. An explicit price, zero included, is kept,
+// but a subscription following the plan price must start at it. A service name given along must be the plan's one, in any case.
+func (ss *SubscriptionServiceImpl) applyPlan(ctx context.Context, req *apiModels.CreateSubscriptionRequest) error {
+	log := logger.FromContext(ctx)
+	if req.PlanID == nil {
+		return nil
+	}
+	uid, err := uuid.Parse(*req.PlanID)
+	if err != nil {
+		log.Warn("failed to validate plan id", "error", err)
+		return fmt.Errorf("%w: plan ID must be a valid UUID", ErrValidationError)
+	}
+
+	plan, err := ss.storage.GetPlanByID(ctx, uid)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Warn("requested plan not found", "plan_id", uid)
+			return fmt.Errorf("%w: unknown plan", ErrValidationError)
+		}
+		log.Error("failed to get plan from database", "error", err)
+		return err
+	}
+
+	if req.ServiceName != "" && !strings.EqualFold(strings.TrimSpace(req.ServiceName), plan.ServiceName) {
+		log.Warn("service name does not match plan", "plan_id", uid, "service_name", req.ServiceName, "plan_service_name", plan.ServiceName)
+		return fmt.Errorf("%w: service name must match plan service %q", ErrValidationError, plan.ServiceName)
+	}
+	req.ServiceName = plan.ServiceName
+	switch {
+	case req.Price == nil:
+		req.Price = &plan.Price
+	case req.FollowPlan && *req.Price != plan.Price:
+		return fmt.Errorf("%w: price must match plan price %d to follow it", ErrValidationError, plan.Price)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/config"
+	"subscription-aggregator-service/internal/logger"
+	"subscription-aggregator-service/internal/models"
+)
+
+// RenameService gives every live subscription named req.From in any case, optionally only of an org unit and its descendants,
+// the name req.To, e.g. after the provider rebranded. The rename is recorded in service_renames and applied in batches
+// of app.service_rename.batch_size, each in its own transaction; pre-update hooks see every change and a rejection stops
+// the rename, leaving earlier batches renamed. Every renamed subscription is passed to post-commit hooks as an update.
+func (ss *SubscriptionServiceImpl) RenameService(ctx context.Context, req *apiModels.RenameServiceRequest) (*models.ServiceRename, error) {
+	log := logger.FromContext(ctx)
+	if err := req.Validate(); err != nil {
+		log.Warn("failed to validate service rename payload", "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrValidationError, err)
+	}
+
+	rename := &models.ServiceRename{ID: uuid.New(), FromName: strings.TrimSpace(req.From), ToName: strings.TrimSpace(req.To), CreatedAt: time.Now()}
+	var unitIDs []uuid.UUID
+	if req.OrgUnitID != nil {
+		unitID := uuid.MustParse(*req.OrgUnitID) // Validated above
+		_, _, subtree, err := ss.orgUnitSubtree(ctx, unitID)
+		if err != nil {
+			if errors.Is(err, ErrOrgUnitNotFound) {
+				return nil, fmt.Errorf("%w: unknown org unit", ErrValidationError)
+			}
+			return nil, err
+		}
+		rename.OrgUnitID, unitIDs = &unitID, subtree
+	}
+
+	if err := ss.storage.CreateServiceRename(ctx, rename); err != nil {
+		log.Error("failed to create service rename in database", "error", err)
+		return nil, err
+	}
+
+	batchSize := viper.GetInt(config.ServiceRenameBatchSize)
+	for {
+		var after []models.Subscription
+		before, err := ss.storage.RenameServiceBatch(ctx, rename, unitIDs, batchSize, func(before []models.Subscription) error {
+			now := time.Now()
+			after = make([]models.Subscription, len(before))
+			for i := range before {
+				after[i] = before[i]
+				after[i].ServiceName, after[i].UpdatedAt = rename.ToName, now
+				after[i].Version++
+				if hookErr := ss.hooks.preUpdate(ctx, &before[i], &after[i]); hookErr != nil {
+					return hookErr
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			if errors.Is(err, ErrValidationError) { // Rejected by a hook, already logged
+				return nil, err
+			}
+			log.Error("failed to rename service in database", "id", rename.ID, "renamed", rename.Renamed, "error", err)
+			return nil, err
+		}
+
+		if len(after) > 0 {
+			rename.Renamed += int64(len(after))
+			ss.aggregatesChanged()
+			events := make([]HookEvent, len(after))
+			for i := range after {
+				events[i] = HookEvent{Action: HookActionUpdate, Before: &before[i], After: &after[i]}
+			}
+			ss.hooks.postCommit(ctx, events...)
+			log.Debug("service rename batch applied", "id", rename.ID, "subscriptions", len(after))
+		}
+		if len(after) < batchSize {
+			break
+		}
+	}
+
+	if err := ss.storage.FinishServiceRename(ctx, rename.ID); err != nil {
+		log.Error("failed to finish service rename in database", "id", rename.ID, "error", err)
+		return nil, err
+	}
+	now := time.Now()
+	rename.FinishedAt = &now
+
+	log.Info("service renamed", "id", rename.ID, "from", rename.FromName, "to", rename.ToName, "org_unit_id", rename.OrgUnitID, "renamed", rename.Renamed)
+	return rename, nil
+}
@@ -1,127 +1,568 @@
 package service
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
-	"log/slog"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/spf13/viper"
 
 	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/config"
+	"subscription-aggregator-service/internal/logger"
 	"subscription-aggregator-service/internal/models"
 	"subscription-aggregator-service/internal/storage"
+	"subscription-aggregator-service/internal/utils/cache"
 	"subscription-aggregator-service/internal/utils/dates"
 )
 
 var (
-	ErrValidationError = errors.New(fmt.Sprintf("Validation error"))
-	ErrNotFound        = errors.New(fmt.Sprintf("Subscription not found"))
-	ErrIES             = errors.New(fmt.Sprintf("Internal server error"))
+	ErrValidationError    = apiModels.NewError(apiModels.CodeValidation, "Validation error")
+	ErrNotFound           = apiModels.NewError(apiModels.CodeSubscriptionNotFound, "Subscription not found")
+	ErrConflict           = apiModels.NewError(apiModels.CodeSubscriptionExists, "Subscription already exists")
+	ErrViewNotFound       = apiModels.NewError(apiModels.CodeViewNotFound, "View not found")
+	ErrViewConflict       = apiModels.NewError(apiModels.CodeViewExists, "View with this name already exists")
+	ErrPlanNotFound       = apiModels.NewError(apiModels.CodePlanNotFound, "Plan not found")
+	ErrPlanConflict       = apiModels.NewError(apiModels.CodePlanExists, "Plan with this name already exists")
+	ErrOrgUnitNotFound    = apiModels.NewError(apiModels.CodeOrgUnitNotFound, "Org unit not found")
+	ErrOrgUnitConflict    = apiModels.NewError(apiModels.CodeOrgUnitExists, "Org unit with this name already exists under the parent")
+	ErrUndoNotFound       = apiModels.NewError(apiModels.CodeUndoTokenNotFound, "Undo token not found or expired")
+	ErrBulkDeleteNotFound = apiModels.NewError(apiModels.CodeBulkDeleteNotFound, "Bulk delete job not found")
+	ErrAlreadyPaused      = apiModels.NewError(apiModels.CodeAlreadyPaused, "Subscription is already paused")
+	ErrNotPaused          = apiModels.NewError(apiModels.CodeNotPaused, "Subscription is not paused")
+	ErrPreconditionFail   = apiModels.NewError(apiModels.CodeSubscriptionModified, "Subscription was modified, fetch it again and retry")
+	ErrPreconditionNeeded = apiModels.NewError(apiModels.CodeIfMatchRequired, "If-Match header with subscription ETag is required")
+	ErrIES                = apiModels.NewError(apiModels.CodeInternal, "Internal server error")
+
+	ErrStorageUnavailable = storage.ErrUnavailable // Storage errors wrap it during database failover, methods pass them through
+
 )
 
 type SubscriptionService interface {
 	CreateSubscription(ctx context.Context, s *apiModels.CreateSubscriptionRequest) (*models.Subscription, error)
+	CreateSubscriptionsBatch(ctx context.Context, reqs []apiModels.CreateSubscriptionRequest) (*apiModels.BatchCreateResponse, error)
+	CreateSubscriptionIfAbsent(ctx context.Context, s *apiModels.CreateSubscriptionRequest) (*models.Subscription, bool, error)
 	GetSubscriptionByID(ctx context.Context, id apiModels.ItemByIDRequest) (*models.Subscription, error)
+	SubscriptionExists(ctx context.Context, id apiModels.ItemByIDRequest) error
 	UpdateSubscriptionByID(ctx context.Context, id apiModels.ItemByIDRequest, sub *apiModels.UpdateSubscriptionRequest) (*models.Subscription, error)
-	DeleteSubscriptionByID(ctx context.Context, id apiModels.ItemByIDRequest) error
-	ListSubscriptions(ctx context.Context, req apiModels.ListSubscriptionsRequest) ([]models.Subscription, error)
+	CancelSubscription(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.CancelSubscriptionRequest) (*models.Subscription, error)
+	RenewSubscription(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.RenewSubscriptionRequest) (*models.Subscription, error)
+	DeleteSubscriptionByID(ctx context.Context, id apiModels.ItemByIDRequest) (*models.UndoToken, error)
+	UndoDeletion(ctx context.Context, req apiModels.UndoRequest) (*models.Subscription, error)
+	PurgeSubscription(ctx context.Context, id apiModels.ItemByIDRequest) (*models.PurgeRecord, error)
+	PurgeUserData(ctx context.Context, user apiModels.ItemByIDRequest) (*models.PurgeRecord, error)
+	SyncSubscriptions(ctx context.Context, user apiModels.ItemByIDRequest, req *apiModels.SyncSubscriptionsRequest, dryRun bool) (*apiModels.SyncSubscriptionsResponse, error)
+	BulkUpdateSubscriptions(ctx context.Context, user apiModels.ItemByIDRequest, req *apiModels.BulkUpdateSubscriptionsRequest) (*apiModels.BulkUpdateSubscriptionsResponse, error)
+	ImportSubscriptions(ctx context.Context, req *apiModels.ImportSubscriptionsRequest, allow []string) (*apiModels.ImportSubscriptionsResponse, error)
+	ListSubscriptions(ctx context.Context, req apiModels.ListSubscriptionsRequest) (*apiModels.ListSubscriptionsResponse, error)
 	TotalSubscriptionsCost(ctx context.Context, req apiModels.TotalCostRequest) (*apiModels.TotalCostResponse, error)
+	TotalSubscriptionsCostBatch(ctx context.Context, req apiModels.BatchTotalCostRequest) (*apiModels.BatchTotalCostResponse, error)
+	ExplainTotalCost(ctx context.Context, req apiModels.TotalCostRequest) (*apiModels.CostExplanationResponse, error)
+	UserSummary(ctx context.Context, user apiModels.ItemByIDRequest) (*apiModels.UserSummaryResponse, error)
+	SuggestServiceNames(ctx context.Context, req apiModels.SuggestServicesRequest) (*apiModels.SuggestServicesResponse, error)
+	CreateView(ctx context.Context, user apiModels.ItemByIDRequest, req *apiModels.CreateViewRequest) (*models.SavedView, error)
+	ListViews(ctx context.Context, user apiModels.ItemByIDRequest) ([]models.SavedView, error)
+	CreatePlan(ctx context.Context, req *apiModels.CreatePlanRequest) (*models.Plan, error)
+	ListPlans(ctx context.Context) ([]models.Plan, error)
+	UpdatePlanPrice(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.UpdatePlanPriceRequest) (*apiModels.UpdatePlanPriceResponse, error)
+	CreateOrgUnit(ctx context.Context, req *apiModels.CreateOrgUnitRequest) (*models.OrgUnit, error)
+	ListOrgUnits(ctx context.Context) ([]models.OrgUnit, error)
+	OrgUnitTotalCost(ctx context.Context, id apiModels.ItemByIDRequest, req apiModels.OrgUnitCostRequest) (*apiModels.OrgUnitCost, error)
+	OrgUnitUsage(ctx context.Context, id apiModels.ItemByIDRequest) (*apiModels.OrgUnitUsage, error)
+	StartBulkDelete(ctx context.Context, req *apiModels.BulkDeleteRequest) (*models.BulkDeleteJob, error)
+	GetBulkDeleteJob(ctx context.Context, id apiModels.ItemByIDRequest) (*models.BulkDeleteJob, error)
+	ResumeBulkDeletes(ctx context.Context) error
+	Chargeback(ctx context.Context, req apiModels.ChargebackRequest) ([]apiModels.ChargebackRow, error)
+	ListExpiringSubscriptions(ctx context.Context, req apiModels.ExpiringSubscriptionsRequest) (*apiModels.ExpiringSubscriptionsResponse, error)
+	CreateCredit(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.CreateCreditRequest) (*models.SubscriptionCredit, error)
+	ListCredits(ctx context.Context, id apiModels.ItemByIDRequest) ([]models.SubscriptionCredit, error)
+	PauseSubscription(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.PauseSubscriptionRequest) (*models.PausedPeriod, error)
+	ResumeSubscription(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.PauseSubscriptionRequest) (*models.PausedPeriod, error)
+	ListPausedPeriods(ctx context.Context, id apiModels.ItemByIDRequest) ([]models.PausedPeriod, error)
+	RenameService(ctx context.Context, req *apiModels.RenameServiceRequest) (*models.ServiceRename, error)
+	CheckIntegrity(ctx context.Context) ([]models.IntegrityFinding, error)
+	ListIntegrityFindings(ctx context.Context) ([]models.IntegrityFinding, error)
 }
 
+const (
+	defaultSuggestLimit = 10
+	maxBatchSize        = 1000
+	suggestCacheTTL     = 30 * time.Second
+)
+
 type SubscriptionServiceImpl struct {
-	storage storage.SubscriptionStorage
+	storage      storage.SubscriptionStorage
+	suggestCache *cache.TTL[suggestKey, []string]
+	totalCache   *cache.SWR[aggregateKey, *apiModels.TotalCostResponse]       // Nil if aggregate caching is disabled
+	explainCache *cache.SWR[aggregateKey, *apiModels.CostExplanationResponse] // Nil if aggregate caching is disabled
+	usageCache   *cache.TTL[uuid.UUID, *apiModels.OrgUnitUsage]               // Nil if usage caching is disabled
+	hooks        hooks
+}
+
+type suggestKey struct {
+	prefix string
+	userID string
+	limit  int
+}
+
+// aggregateKey identifies a cached aggregate by its filter and period
+type aggregateKey struct {
+	userID          string
+	serviceName     string
+	serviceNameFold bool
+	serviceNameLike string
+	start, end      string
+	groupBy         string
+}
+
+func newAggregateKey(filter models.SubscriptionFilter, startDate, endDate time.Time) aggregateKey {
+	key := aggregateKey{start: startDate.Format(dates.Layout), end: endDate.Format(dates.Layout)}
+	if filter.UserID != nil {
+		key.userID = filter.UserID.String()
+	}
+	if filter.ServiceName != nil {
+		key.serviceName = *filter.ServiceName
+		key.serviceNameFold = filter.ServiceNameFold
+	}
+	if filter.ServiceNameLike != nil {
+		key.serviceNameLike = *filter.ServiceNameLike
+	}
+	return key
+}
+
+func NewSubscriptionService(ss storage.SubscriptionStorage, hs ...Hook) SubscriptionService {
+	svc := &SubscriptionServiceImpl{
+		storage:      ss,
+		suggestCache: cache.NewTTL[suggestKey, []string](suggestCacheTTL),
+		hooks:        hs,
+	}
+	if ttl := viper.GetDuration(config.CacheAggregatesTTL); ttl > 0 {
+		stale := viper.GetDuration(config.CacheAggregatesStale)
+		svc.totalCache = cache.NewSWR[aggregateKey, *apiModels.TotalCostResponse](ttl, stale)
+		svc.explainCache = cache.NewSWR[aggregateKey, *apiModels.CostExplanationResponse](ttl, stale)
+	}
+	if ttl := viper.GetDuration(config.MetricsCacheTTL); ttl > 0 {
+		svc.usageCache = cache.NewTTL[uuid.UUID, *apiModels.OrgUnitUsage](ttl)
+	}
+	return svc
+}
+
+// aggregatesChanged drops cached aggregates after a mutation. Other replicas keep theirs until the stale window ends.
+func (ss *SubscriptionServiceImpl) aggregatesChanged() {
+	if ss.totalCache != nil {
+		ss.totalCache.Invalidate()
+		ss.explainCache.Invalidate()
+	}
 }
 
-func NewSubscriptionService(ss storage.SubscriptionStorage) SubscriptionService {
-	return &SubscriptionServiceImpl{storage: ss}
+// cachedAggregate loads the aggregate through c, or directly if caching is disabled
+func cachedAggregate[V any](ctx context.Context, c *cache.SWR[aggregateKey, V], key aggregateKey, load func(ctx context.Context) (V, error)) (V, error) {
+	if c == nil {
+		return load(ctx)
+	}
+	return c.Get(ctx, key, load)
 }
 
 func (ss *SubscriptionServiceImpl) CreateSubscription(ctx context.Context, req *apiModels.CreateSubscriptionRequest) (*models.Subscription, error) {
+	log := logger.FromContext(ctx)
+	if err := ss.resolveReferences(ctx, req); err != nil {
+		return nil, err
+	}
+
+	sub, err := newSubscription(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err = ss.hooks.preCreate(ctx, sub); err != nil {
+		return nil, err
+	}
+	if viper.GetBool(config.LimitsDetectDuplicates) {
+		if existing, err := ss.findDuplicate(ctx, sub); existing != nil || err != nil {
+			return existing, err
+		}
+	}
+
+	if err = ss.storage.CreateSubscription(ctx, sub); err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			log.Warn("subscription with requested ID already exists", "id", sub.ID)
+			existing, getErr := ss.storage.GetSubscriptionByID(ctx, sub.ID)
+			if getErr != nil { // Soft-deleted records still hold their ID, but can't be returned
+				return nil, ErrConflict
+			}
+			return existing, ErrConflict
+		}
+		log.Error("failed to create subscription in database", "error", err)
+		return nil, err
+	}
+
+	ss.aggregatesChanged()
+	ss.hooks.postCommit(ctx, HookEvent{Action: HookActionCreate, After: sub})
+	log.Info("subscription created", "id", sub.ID, "user_id", sub.UserID)
+	return sub, nil
+}
+
+// findDuplicate returns the subscription sub overlaps with and ErrConflict, or nil if there's none.
+// The check isn't atomic with the insert, concurrent requests can still create overlapping subscriptions.
+func (ss *SubscriptionServiceImpl) findDuplicate(ctx context.Context, sub *models.Subscription) (*models.Subscription, error) {
+	log := logger.FromContext(ctx)
+	existing, err := ss.storage.FindOverlappingSubscription(ctx, sub)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil
+		}
+		log.Error("failed to check subscription for duplicates", "error", err)
+		return nil, err
+	}
+	log.Warn("subscription overlaps with existing one", "id", existing.ID, "user_id", sub.UserID, "service_name", sub.ServiceName)
+	return existing, ErrConflict
+}
+
+// duplicateIn returns the first of subs that sub overlaps with, or nil. It's the in-memory counterpart of
+// FindOverlappingSubscription, for subscriptions written together in one request.
+func duplicateIn(subs []*models.Subscription, sub *models.Subscription) *models.Subscription {
+	for _, other := range subs {
+		if other.ID == sub.ID || other.UserID != sub.UserID || !strings.EqualFold(other.ServiceName, sub.ServiceName) {
+			continue
+		}
+		if (other.EndDate == nil || !other.EndDate.Before(sub.StartDate)) && (sub.EndDate == nil || !other.StartDate.After(*sub.EndDate)) {
+			return other
+		}
+	}
+	return nil
+}
+
+// checkDuplicate rejects sub overlapping a stored subscription or one written along with it, when duplicates are detected
+func (ss *SubscriptionServiceImpl) checkDuplicate(ctx context.Context, sub *models.Subscription, along []*models.Subscription) error {
+	if !viper.GetBool(config.LimitsDetectDuplicates) {
+		return nil
+	}
+	if existing := duplicateIn(along, sub); existing != nil {
+		logger.FromContext(ctx).Warn("subscription overlaps with another one of the request", "id", existing.ID, "user_id", sub.UserID, "service_name", sub.ServiceName)
+		return fmt.Errorf("%w: overlaps with subscription %s of the same request", ErrConflict, existing.ID)
+	}
+	if existing, err := ss.findDuplicate(ctx, sub); existing != nil {
+		return fmt.Errorf("%w: overlaps with subscription %s", ErrConflict, existing.ID)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateSubscriptionsBatch validates every item and creates the valid ones in one transaction.
+// Invalid items are reported in their results and don't block the rest, a storage failure fails the whole batch.
+// With duplicate detection, so are items overlapping a stored subscription or an earlier item of the batch.
+func (ss *SubscriptionServiceImpl) CreateSubscriptionsBatch(ctx context.Context, reqs []apiModels.CreateSubscriptionRequest) (*apiModels.BatchCreateResponse, error) {
+	log := logger.FromContext(ctx)
+	if len(reqs) == 0 || len(reqs) > maxBatchSize {
+		log.Warn("failed to validate batch size", "size", len(reqs))
+		return nil, fmt.Errorf("%w: batch must contain 1 to %d subscriptions", ErrValidationError, maxBatchSize)
+	}
+
+	resp := &apiModels.BatchCreateResponse{Results: make([]apiModels.BatchItemResult, len(reqs))}
+	subs := make([]*models.Subscription, 0, len(reqs))
+	created := make([]*models.Subscription, len(reqs)) // Subscription of every valid item, nil for rejected ones
+	for i := range reqs {
+		sub, err := ss.batchItem(ctx, &reqs[i])
+		if err == nil {
+			err = ss.checkDuplicate(ctx, sub, subs)
+		}
+		if err != nil {
+			if !errors.Is(err, ErrValidationError) && !errors.Is(err, ErrConflict) {
+				return nil, err
+			}
+			errResp := apiModels.NewErrorResponse(err)
+			resp.Results[i] = apiModels.BatchItemResult{Error: errResp.Error, Code: errResp.Code, Fields: errResp.Fields}
+			resp.Failed++
+			continue
+		}
+		created[i] = sub
+		subs = append(subs, sub)
+	}
+
+	if len(subs) > 0 {
+		if err := ss.storage.CreateSubscriptions(ctx, subs); err != nil {
+			if errors.Is(err, storage.ErrAlreadyExists) {
+				log.Warn("batch subscription already exists")
+				return nil, ErrConflict
+			}
+			log.Error("failed to create batch of subscriptions in database", "error", err)
+			return nil, err
+		}
+		resp.Created = len(subs)
+	}
+	for i, sub := range created {
+		if sub != nil {
+			resp.Results[i].Subscription = apiModels.NewSubscriptionResponse(sub, apiModels.SerializationFromContext(ctx))
+		}
+	}
+
+	ss.aggregatesChanged()
+	ss.hooks.postCommit(ctx, creationEvents(subs)...)
+	log.Info("subscription batch processed", "created", resp.Created, "failed", resp.Failed)
+	return resp, nil
+}
+
+func (ss *SubscriptionServiceImpl) batchItem(ctx context.Context, req *apiModels.CreateSubscriptionRequest) (*models.Subscription, error) {
+	if err := ss.resolveReferences(ctx, req); err != nil {
+		return nil, err
+	}
+	sub, err := newSubscription(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err = ss.hooks.preCreate(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// CreateSubscriptionIfAbsent creates the subscription unless the user already has one with the same external ID,
+// in which case the existing one is returned and created is false. With duplicate detection, a subscription overlapping
+// another one to the same service is rejected like in CreateSubscription.
+func (ss *SubscriptionServiceImpl) CreateSubscriptionIfAbsent(ctx context.Context, req *apiModels.CreateSubscriptionRequest) (*models.Subscription, bool, error) {
+	log := logger.FromContext(ctx)
+	if req.ExternalID == nil || strings.TrimSpace(*req.ExternalID) == "" {
+		log.Warn("failed to validate subscription payload", "error", "missing external ID")
+		return nil, false, fmt.Errorf("%w: external ID is required", ErrValidationError)
+	}
+	if err := ss.resolveReferences(ctx, req); err != nil {
+		return nil, false, err
+	}
+
+	sub, err := newSubscription(ctx, req)
+	if err != nil {
+		return nil, false, err
+	}
+	if err = ss.hooks.preCreate(ctx, sub); err != nil {
+		return nil, false, err
+	}
+	if viper.GetBool(config.LimitsDetectDuplicates) {
+		// A retry overlaps the subscription it created before, storage returns that one instead of creating another
+		existing, dupErr := ss.findDuplicate(ctx, sub)
+		if dupErr != nil && (existing == nil || existing.ExternalID == nil || *existing.ExternalID != *sub.ExternalID) {
+			return existing, false, dupErr
+		}
+	}
+
+	result, created, err := ss.storage.CreateSubscriptionIfAbsent(ctx, sub)
+	if err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) { // Client-supplied ID taken by another record
+			log.Warn("subscription with requested ID already exists", "id", sub.ID)
+			return nil, false, ErrConflict
+		}
+		log.Error("failed to create subscription in database", "error", err)
+		return nil, false, err
+	}
+
+	ss.aggregatesChanged()
+	if created {
+		ss.hooks.postCommit(ctx, HookEvent{Action: HookActionCreate, After: result})
+		log.Info("subscription created", "id", result.ID, "user_id", result.UserID, "external_id", *result.ExternalID)
+	} else {
+		log.Info("subscription with external ID already exists", "id", result.ID, "user_id", result.UserID, "external_id", *result.ExternalID)
+	}
+	return result, created, nil
+}
+
+// resolveReferences applies the referenced plan and makes sure the referenced org unit exists
+func (ss *SubscriptionServiceImpl) resolveReferences(ctx context.Context, req *apiModels.CreateSubscriptionRequest) error {
+	if err := ss.applyPlan(ctx, req); err != nil {
+		return err
+	}
+	if req.OrgUnitID != nil {
+		if _, err := ss.checkOrgUnit(ctx, *req.OrgUnitID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// relaxations are validations a trusted import skipped, zero value enforces all of them
+type relaxations struct {
+	historicalStart bool
+	longDuration    bool
+	monthNames      bool
+}
+
+// trimmedTag normalizes an allocation tag, blank one is no tag
+func trimmedTag(tag *string) *string {
+	if tag == nil || strings.TrimSpace(*tag) == "" {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*tag)
+	return &trimmed
+}
+
+func newSubscription(ctx context.Context, req *apiModels.CreateSubscriptionRequest) (*models.Subscription, error) {
+	return newRelaxedSubscription(ctx, req, relaxations{})
+}
+
+func newRelaxedSubscription(ctx context.Context, req *apiModels.CreateSubscriptionRequest, relax relaxations) (*models.Subscription, error) {
+	log := logger.FromContext(ctx)
 	if err := req.Validate(); err != nil {
-		slog.Warn("failed to validate subscription payload", "error", err)
-		return nil, fmt.Errorf("%w: %s", ErrValidationError, err.Error())
+		log.Warn("failed to validate subscription payload", "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrValidationError, err)
 	}
 
 	start, end, err := req.ParseDates()
 	if err != nil {
-		slog.Warn("failed to validate subscription dates", "error", err)
+		log.Warn("failed to validate subscription dates", "error", err)
 		return nil, fmt.Errorf("%w: %s", ErrValidationError, err.Error())
 	}
 
-	sub := &models.Subscription{
-		ID:          uuid.New(),
+	subType := models.TypeRecurring
+	if req.Type == models.TypeOneTime {
+		subType = models.TypeOneTime
+		end = &start // Charged once, in its start month
+	}
+
+	if !relax.historicalStart {
+		if err = checkStartDate(start); err != nil {
+			log.Warn("failed to validate subscription dates", "error", err)
+			return nil, err
+		}
+	}
+	if !relax.longDuration {
+		if err = checkDuration(start, end); err != nil {
+			log.Warn("failed to validate subscription dates", "error", err)
+			return nil, err
+		}
+	}
+
+	id := uuid.New()
+	if req.ID != nil && *req.ID != "" {
+		id = uuid.MustParse(*req.ID) // Assuming already validated above
+	}
+
+	var externalID *string
+	if req.ExternalID != nil && strings.TrimSpace(*req.ExternalID) != "" {
+		trimmed := strings.TrimSpace(*req.ExternalID)
+		externalID = &trimmed
+	}
+
+	var planID *uuid.UUID
+	if req.PlanID != nil {
+		parsed := uuid.MustParse(*req.PlanID) // Assuming already validated above
+		planID = &parsed
+	}
+
+	var orgUnitID *uuid.UUID
+	if req.OrgUnitID != nil {
+		parsed := uuid.MustParse(*req.OrgUnitID) // Assuming already validated above
+		orgUnitID = &parsed
+	}
+
+	var price int // Omitted price without a plan is a free subscription, as it always was
+	if req.Price != nil {
+		price = *req.Price
+	}
+
+	return &models.Subscription{
+		ID:          id,
+		ExternalID:  externalID,
 		ServiceName: req.ServiceName,
-		Price:       req.Price,
+		Price:       price,
+		Type:        subType,
+		PlanID:      planID,
+		FollowPlan:  req.FollowPlan,
+		OrgUnitID:   orgUnitID,
+		CostCenter:  trimmedTag(req.CostCenter),
+		ProjectCode: trimmedTag(req.ProjectCode),
 		UserID:      uuid.MustParse(req.UserID), // Assuming already validated above
 		StartDate:   start,
 		EndDate:     end,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
-	}
-
-	if err = ss.storage.CreateSubscription(ctx, sub); err != nil {
-		slog.Error("failed to create subscription in database", "error", err)
-		return nil, err
-	}
-
-	slog.Info("subscription created", "id", sub.ID, "user_id", sub.UserID)
-	return sub, nil
+	}, nil
 }
 
 func (ss *SubscriptionServiceImpl) GetSubscriptionByID(ctx context.Context, id apiModels.ItemByIDRequest) (*models.Subscription, error) {
+	log := logger.FromContext(ctx)
 	uid, err := uuid.Parse(id.ID)
 	if err != nil {
-		slog.Warn("failed to validate subscription id", "error", err)
+		log.Warn("failed to validate subscription id", "error", err)
 		return nil, fmt.Errorf("%w: invalid subscription UUID", ErrValidationError)
 	}
 
 	sub, err := ss.storage.GetSubscriptionByID(ctx, uid)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
-			slog.Warn("requested subscription not found", "error", err)
+			log.Warn("requested subscription not found", "error", err)
 			return nil, ErrNotFound
 		} else {
-			slog.Error("failed to get subscription from database", "error", err)
+			log.Error("failed to get subscription from database", "error", err)
 			return nil, err
 		}
 	}
 
-	slog.Debug("subscription retrieved", "id", uid)
+	log.Debug("subscription retrieved", "id", uid)
 	return sub, nil
 }
 
+// SubscriptionExists returns ErrNotFound unless the subscription exists, without loading it
+func (ss *SubscriptionServiceImpl) SubscriptionExists(ctx context.Context, id apiModels.ItemByIDRequest) error {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		log.Warn("failed to validate subscription id", "error", err)
+		return fmt.Errorf("%w: invalid subscription UUID", ErrValidationError)
+	}
+
+	exists, err := ss.storage.SubscriptionExistsByID(ctx, uid)
+	if err != nil {
+		log.Error("failed to check subscription in database", "error", err)
+		return err
+	}
+	if !exists {
+		log.Debug("checked subscription not found", "id", uid)
+		return ErrNotFound
+	}
+	return nil
+}
+
 func (ss *SubscriptionServiceImpl) UpdateSubscriptionByID(ctx context.Context, id apiModels.ItemByIDRequest, updated *apiModels.UpdateSubscriptionRequest) (*models.Subscription, error) {
+	log := logger.FromContext(ctx)
 	uid, err := uuid.Parse(id.ID)
 	if err != nil {
-		slog.Warn("failed to validate subscription id", "error", err)
+		log.Warn("failed to validate subscription id", "error", err)
 		return nil, fmt.Errorf("%w: invalid subscription UUID", ErrValidationError)
 	}
 
 	if err = updated.Validate(); err != nil {
-		slog.Warn("failed to validate subscription payload", "error", err)
-		return nil, fmt.Errorf("%w: %s", ErrValidationError, err.Error())
+		log.Warn("failed to validate subscription payload", "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrValidationError, err)
 	}
 
 	current, err := ss.storage.GetSubscriptionByID(ctx, uid)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
-			slog.Warn("requested subscription not found", "error", err)
+			log.Warn("requested subscription not found", "error", err)
 			return nil, ErrNotFound
 		} else {
-			slog.Error("failed to get subscription from database", "error", err)
+			log.Error("failed to get subscription from database", "error", err)
 			return nil, err
 		}
 	}
+	if err = checkIfMatch(ctx, updated.IfMatch, current); err != nil {
+		return nil, err
+	}
 
 	reqStart, reqEnd, clearEnd, err := updated.ParseDates()
 	if err != nil {
-		slog.Warn("failed to validate subscription dates", "error", err)
+		log.Warn("failed to validate subscription dates", "error", err)
 		return nil, fmt.Errorf("%w: %s", ErrValidationError, err.Error())
 	}
 	startDate := current.StartDate
 	if reqStart != nil {
+		if err = checkStartDate(*reqStart); err != nil {
+			log.Warn("failed to validate subscription dates", "error", err)
+			return nil, err
+		}
 		startDate = *reqStart
 	}
 	endDate := current.EndDate
@@ -130,154 +571,1447 @@ func (ss *SubscriptionServiceImpl) UpdateSubscriptionByID(ctx context.Context, i
 	} else if reqEnd != nil {
 		endDate = reqEnd
 	}
+	if current.Type == models.TypeOneTime {
+		if updated.EndDate != nil {
+			return nil, fmt.Errorf("%w: one-time purchase ends in its start month", ErrValidationError)
+		}
+		endDate = &startDate
+	}
 	if endDate != nil && endDate.Before(startDate) {
-		slog.Warn("failed to validate subscription dates", "error", err)
+		log.Warn("failed to validate subscription dates", "error", err)
 		return nil, fmt.Errorf("%w: subscription end date cannot precede start date", ErrValidationError)
 	}
+	if err = checkDuration(startDate, endDate); err != nil {
+		log.Warn("failed to validate subscription dates", "error", err)
+		return nil, err
+	}
 
+	before := *current
 	if updated.ServiceName != nil {
 		current.ServiceName = *updated.ServiceName
 	}
 	if updated.Price != nil {
+		if *updated.Price != current.Price {
+			current.FollowPlan = false // Manual price overrides the plan's from now on
+		}
 		current.Price = *updated.Price
 	}
+	if updated.OrgUnitID != nil {
+		if *updated.OrgUnitID == "" {
+			current.OrgUnitID = nil
+		} else if current.OrgUnitID, err = ss.checkOrgUnit(ctx, *updated.OrgUnitID); err != nil {
+			return nil, err
+		}
+	}
+	if updated.CostCenter != nil {
+		current.CostCenter = trimmedTag(updated.CostCenter)
+	}
+	if updated.ProjectCode != nil {
+		current.ProjectCode = trimmedTag(updated.ProjectCode)
+	}
 	current.StartDate = startDate
 	current.EndDate = endDate
 	current.UpdatedAt = time.Now()
+	if err = ss.hooks.preUpdate(ctx, &before, current); err != nil {
+		return nil, err
+	}
 
 	if err = ss.storage.UpdateSubscriptionByID(ctx, current); err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
-			slog.Warn("requested subscription not found", "error", err)
+			log.Warn("requested subscription not found", "error", err)
 			return nil, ErrNotFound
+		} else if errors.Is(err, storage.ErrStale) {
+			log.Warn("subscription modified concurrently", "id", uid)
+			return nil, ErrPreconditionFail
 		} else {
-			slog.Error("failed to update subscription in database", "error", err)
+			log.Error("failed to update subscription in database", "error", err)
 			return nil, err
 		}
 	}
 
-	slog.Info("subscription updated", "id", uid)
+	ss.aggregatesChanged()
+	ss.hooks.postCommit(ctx, HookEvent{Action: HookActionUpdate, Before: &before, After: current})
+	log.Info("subscription updated", "id", uid)
 	return current, nil
 }
 
-func (ss *SubscriptionServiceImpl) DeleteSubscriptionByID(ctx context.Context, id apiModels.ItemByIDRequest) error {
-	uid, err := uuid.Parse(id.ID)
-	if err != nil {
-		slog.Warn("failed to validate subscription id", "error", err)
-		return fmt.Errorf("%w: invalid subscription UUID", ErrValidationError)
+// checkIfMatch compares If-Match header value with the subscription's ETag, "*" matches any version.
+// Missing header is an error only when app.api.require_if_match is set.
+func checkIfMatch(ctx context.Context, ifMatch string, current *models.Subscription) error {
+	log := logger.FromContext(ctx)
+	if strings.TrimSpace(ifMatch) == "" {
+		if viper.GetBool(config.ApiRequireIfMatch) {
+			log.Warn("subscription update without If-Match", "id", current.ID)
+			return ErrPreconditionNeeded
+		}
+		return nil
 	}
-
-	if err = ss.storage.DeleteSubscriptionByID(ctx, uid); err != nil {
-		if errors.Is(err, storage.ErrNotFound) {
-			slog.Warn("requested subscription not found", "error", err)
-			return ErrNotFound
-		} else {
-			slog.Error("failed to delete subscription in database", "error", err)
-			return err
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/") // Weak comparison, the version is all there is to compare
+		if tag == "*" || tag == current.ETag() {
+			return nil
 		}
 	}
+	log.Warn("subscription update with stale If-Match", "id", current.ID, "if_match", ifMatch, "etag", current.ETag())
+	return ErrPreconditionFail
+}
 
-	slog.Info("subscription deleted", "id", uid)
+// checkStartDate catches typos like 01-0224 by rejecting start dates too far from now
+func checkStartDate(start time.Time) error {
+	window := viper.GetInt(config.LimitsStartDateWindowYears)
+	if window <= 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	earliest := time.Date(now.Year()-window, now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	latest := time.Date(now.Year()+window, now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if start.Before(earliest) || start.After(latest) {
+		return fmt.Errorf("%w: start date must be between %s and %s", ErrValidationError, earliest.Format(dates.Layout), latest.Format(dates.Layout))
+	}
 	return nil
 }
 
-func (ss *SubscriptionServiceImpl) ListSubscriptions(ctx context.Context, req apiModels.ListSubscriptionsRequest) ([]models.Subscription, error) {
-	filter := models.SubscriptionFilter{}
+func checkDuration(start time.Time, end *time.Time) error {
+	maxYears := viper.GetInt(config.LimitsSubscriptionMaxYears)
+	if maxYears <= 0 || end == nil {
+		return nil
+	}
+	if dates.MonthSpan(start, *end) > maxYears*12 {
+		return fmt.Errorf("%w: subscription cannot last longer than %d years", ErrValidationError, maxYears)
+	}
+	return nil
+}
 
-	if req.UserID != "" {
-		uid, err := uuid.Parse(req.UserID)
-		if err != nil {
-			slog.Warn("failed to validate user ID", "error", err)
-			return nil, fmt.Errorf("%w: invalid user ID", ErrValidationError)
-		}
-		filter.UserID = &uid
+// CancelSubscription ends the subscription with the current month or req.EndDate and records when it was cancelled.
+// Unlike deletion the subscription stays and counts until its end, it can't end before it starts or later than it already does.
+// If-Match is checked when sent, but not required as nothing else is overwritten.
+func (ss *SubscriptionServiceImpl) CancelSubscription(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.CancelSubscriptionRequest) (*models.Subscription, error) {
+	log := logger.FromContext(ctx)
+	endDate, err := req.ParseEndDate()
+	if err != nil {
+		log.Warn("failed to validate cancellation payload", "error", err)
+		return nil, fmt.Errorf("%w: %s", ErrValidationError, err.Error())
 	}
-	if req.ServiceName != "" {
-		filter.ServiceName = &req.ServiceName
+	now := time.Now().UTC()
+	if endDate == nil {
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		endDate = &month
 	}
-	if req.Limit != nil {
-		if *req.Limit <= 0 {
-			slog.Warn("failed to validate limit", "limit", *req.Limit)
-			return nil, fmt.Errorf("%w: invalid limit", ErrValidationError)
+
+	return ss.changeTerm(ctx, id, req.IfMatch, "cancel", func(current *models.Subscription) error {
+		switch {
+		case current.Type == models.TypeOneTime:
+			log.Warn("cancellation of one-time purchase", "id", current.ID)
+			return fmt.Errorf("%w: one-time purchase cannot be cancelled", ErrValidationError)
+		case endDate.Before(current.StartDate):
+			log.Warn("cancellation before subscription start", "id", current.ID, "end_date", endDate.Format(dates.Layout))
+			return fmt.Errorf("%w: subscription cannot be cancelled before its start date %s", ErrValidationError, current.StartDate.Format(dates.Layout))
+		case current.EndDate != nil && endDate.After(*current.EndDate):
+			log.Warn("cancellation after subscription end", "id", current.ID, "end_date", endDate.Format(dates.Layout))
+			return fmt.Errorf("%w: subscription already ends in %s", ErrValidationError, current.EndDate.Format(dates.Layout))
 		}
-		filter.Limit = req.Limit
+		current.EndDate = endDate
+		current.CancelledAt = &now
+		return nil
+	})
+}
+
+// RenewSubscription pushes the end date of the subscription req.Months months forward. An open-ended subscription gets
+// a fixed term of that many months after the current one, or after its start if it hasn't started yet.
+// Renewal revokes a cancellation. If-Match is checked when sent, as for CancelSubscription.
+func (ss *SubscriptionServiceImpl) RenewSubscription(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.RenewSubscriptionRequest) (*models.Subscription, error) {
+	log := logger.FromContext(ctx)
+	if err := req.Validate(); err != nil {
+		log.Warn("failed to validate renewal payload", "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrValidationError, err)
 	}
-	if req.Offset != nil {
-		if *req.Offset < 0 {
-			slog.Warn("failed to validate offset", "offset", *req.Offset)
-			return nil, fmt.Errorf("%w: invalid offset", ErrValidationError)
+
+	return ss.changeTerm(ctx, id, req.IfMatch, "renew", func(current *models.Subscription) error {
+		if current.Type == models.TypeOneTime {
+			log.Warn("renewal of one-time purchase", "id", current.ID)
+			return fmt.Errorf("%w: one-time purchase cannot be renewed", ErrValidationError)
 		}
-		filter.Offset = req.Offset
-	}
+		endDate := renewedEnd(current, req.Months, time.Now().UTC())
+		if err := checkDuration(current.StartDate, &endDate); err != nil {
+			log.Warn("failed to validate subscription dates", "error", err)
+			return err
+		}
+		current.EndDate = &endDate
+		current.CancelledAt = nil
+		return nil
+	})
+}
 
-	list, err := ss.storage.ListSubscriptions(ctx, filter)
-	if err != nil {
-		slog.Error("failed to list subscriptions from database", "error", err)
-		return nil, err
+// renewedEnd is the end date of sub after renewing it for months at now
+func renewedEnd(sub *models.Subscription, months int, now time.Time) time.Time {
+	if sub.EndDate != nil {
+		return sub.EndDate.AddDate(0, months, 0)
 	}
-
-	slog.Debug("subscriptions list retrieved", "id_filter", filter.UserID, "service_filter", filter.ServiceName, "limit", filter.Limit, "offset", filter.Offset)
-	return list, nil
+	paidThrough := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if beforeStart := sub.StartDate.AddDate(0, -1, 0); paidThrough.Before(beforeStart) {
+		paidThrough = beforeStart
+	}
+	return paidThrough.AddDate(0, months, 0)
 }
 
-func (ss *SubscriptionServiceImpl) TotalSubscriptionsCost(ctx context.Context, req apiModels.TotalCostRequest) (*apiModels.TotalCostResponse, error) {
-	startDate, err := dates.String2Date(req.StartDate)
+// changeTerm loads the subscription, checks If-Match when sent, lets change move its dates and saves it
+// through pre-update hooks, action names the change in logs
+func (ss *SubscriptionServiceImpl) changeTerm(ctx context.Context, id apiModels.ItemByIDRequest, ifMatch, action string, change func(current *models.Subscription) error) (*models.Subscription, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(id.ID)
 	if err != nil {
-		slog.Warn("failed to validate subscription dates", "error", err)
-		return nil, fmt.Errorf("%w: invalid start date", ErrValidationError)
+		log.Warn("failed to validate subscription id", "error", err)
+		return nil, fmt.Errorf("%w: invalid subscription UUID", ErrValidationError)
 	}
-	endDate, err := dates.String2Date(req.EndDate)
+
+	current, err := ss.storage.GetSubscriptionByID(ctx, uid)
 	if err != nil {
-		slog.Warn("failed to validate subscription dates", "error", err)
-		return nil, fmt.Errorf("%w: invalid end date", ErrValidationError)
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Warn("requested subscription not found", "error", err)
+			return nil, ErrNotFound
+		}
+		log.Error("failed to get subscription from database", "error", err)
+		return nil, err
 	}
-	if endDate.Before(startDate) {
-		slog.Warn("failed to validate subscription dates", "error", err)
-		return nil, fmt.Errorf("%w: end date cannot precede start date", ErrValidationError)
+	if ifMatch != "" {
+		if err = checkIfMatch(ctx, ifMatch, current); err != nil {
+			return nil, err
+		}
 	}
 
-	filter := models.SubscriptionFilter{}
-	if req.UserID != "" {
-		var uid uuid.UUID
-		uid, err = uuid.Parse(req.UserID)
-		if err != nil {
-			slog.Warn("failed to validate user ID", "error", err)
-			return nil, fmt.Errorf("%w: invalid user ID", ErrValidationError)
-		}
-		filter.UserID = &uid
+	before := *current
+	if err = change(current); err != nil {
+		return nil, err
 	}
-	if req.ServiceName != "" {
-		filter.ServiceName = &req.ServiceName
+	current.UpdatedAt = time.Now()
+	if err = ss.hooks.preUpdate(ctx, &before, current); err != nil {
+		return nil, err
 	}
 
-	totalCost, err := ss.storage.TotalSubscriptionsCost(ctx, filter, startDate, endDate)
-	if err != nil {
-		slog.Error("failed to calculate total cost in database", "error", err)
+	if err = ss.storage.UpdateSubscriptionByID(ctx, current); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Warn("requested subscription not found", "error", err)
+			return nil, ErrNotFound
+		} else if errors.Is(err, storage.ErrStale) {
+			log.Warn("subscription modified concurrently", "id", uid)
+			return nil, ErrPreconditionFail
+		}
+		log.Error("failed to "+action+" subscription in database", "error", err)
 		return nil, err
 	}
 
-	slog.Info("calculated total cost", "user_id", req.UserID, "total", totalCost, "start", startDate.Format("01-2006"), "end", endDate.Format("01-2006"))
-	return &apiModels.TotalCostResponse{TotalCost: totalCost}, nil
+	ss.aggregatesChanged()
+	ss.hooks.postCommit(ctx, HookEvent{Action: HookActionUpdate, Before: &before, After: current})
+	log.Info("subscription term changed", "id", uid, "action", action, "end_date", current.EndDate.Format(dates.Layout))
+	return current, nil
 }
 
-func calculateSubscriptionCost(sub models.Subscription, startDate, endDate time.Time) int64 {
-	start := startDate
-	if sub.StartDate.After(startDate) {
-		start = sub.StartDate
+// DeleteSubscriptionByID soft-deletes the subscription and returns a token restoring it within app.undo.window,
+// nil token if undo is disabled
+func (ss *SubscriptionServiceImpl) DeleteSubscriptionByID(ctx context.Context, id apiModels.ItemByIDRequest) (*models.UndoToken, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		log.Warn("failed to validate subscription id", "error", err)
+		return nil, fmt.Errorf("%w: invalid subscription UUID", ErrValidationError)
 	}
-	end := endDate
+
+	var deleted *models.Subscription
+	if len(ss.hooks) > 0 { // Hooks get the subscription being deleted, not worth a query without them
+		if deleted, err = ss.storage.GetSubscriptionByID(ctx, uid); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				log.Warn("requested subscription not found", "error", err)
+				return nil, ErrNotFound
+			}
+			log.Error("failed to get subscription from database", "error", err)
+			return nil, err
+		}
+		if err = ss.hooks.preDelete(ctx, deleted); err != nil {
+			return nil, err
+		}
+	}
+
+	var token *models.UndoToken
+	if window := viper.GetDuration(config.UndoWindow); window > 0 {
+		token, err = ss.storage.DeleteSubscriptionWithUndo(ctx, uid, time.Now().Add(window))
+	} else {
+		err = ss.storage.DeleteSubscriptionByID(ctx, uid)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Warn("requested subscription not found", "error", err)
+			return nil, ErrNotFound
+		} else {
+			log.Error("failed to delete subscription in database", "error", err)
+			return nil, err
+		}
+	}
+
+	ss.aggregatesChanged()
+	if deleted != nil {
+		ss.hooks.postCommit(ctx, HookEvent{Action: HookActionDelete, Before: deleted})
+	}
+	log.Info("subscription deleted", "id", uid, "undoable", token != nil)
+	return token, nil
+}
+
+// UndoDeletion restores a subscription deleted with the given undo token, a token works only once.
+// Hooks see the restoration as a creation, a rejection keeps the token usable, as does overlapping a live subscription
+// with duplicate detection.
+func (ss *SubscriptionServiceImpl) UndoDeletion(ctx context.Context, req apiModels.UndoRequest) (*models.Subscription, error) {
+	log := logger.FromContext(ctx)
+	token, err := uuid.Parse(req.Token)
+	if err != nil {
+		log.Warn("failed to validate undo token", "error", err)
+		return nil, fmt.Errorf("%w: invalid undo token", ErrValidationError)
+	}
+
+	sub, err := ss.storage.UndoDeletion(ctx, token, func(sub *models.Subscription) error {
+		if err := ss.checkDuplicate(ctx, sub, nil); err != nil {
+			return err
+		}
+		return ss.hooks.preCreate(ctx, sub)
+	})
+	if err != nil {
+		if errors.Is(err, ErrValidationError) || errors.Is(err, ErrConflict) { // Rejected by a hook or as a duplicate, already logged
+			return nil, err
+		} else if errors.Is(err, storage.ErrNotFound) {
+			log.Warn("undo token not found or expired", "token", token)
+			return nil, ErrUndoNotFound
+		} else if errors.Is(err, storage.ErrAlreadyExists) {
+			log.Warn("restored subscription conflicts with a live one", "token", token)
+			return nil, ErrConflict
+		} else {
+			log.Error("failed to restore subscription in database", "error", err)
+			return nil, err
+		}
+	}
+
+	ss.aggregatesChanged()
+	ss.hooks.postCommit(ctx, HookEvent{Action: HookActionCreate, After: sub})
+	log.Info("subscription restored", "id", sub.ID)
+	return sub, nil
+}
+
+// PurgeSubscription permanently deletes a subscription, including a soft-deleted one, for erasure requests. Can't be undone.
+func (ss *SubscriptionServiceImpl) PurgeSubscription(ctx context.Context, id apiModels.ItemByIDRequest) (*models.PurgeRecord, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		log.Warn("failed to validate subscription id", "error", err)
+		return nil, fmt.Errorf("%w: invalid subscription UUID", ErrValidationError)
+	}
+
+	record, err := ss.storage.PurgeSubscription(ctx, uid)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Warn("requested subscription not found", "error", err)
+			return nil, ErrNotFound
+		}
+		log.Error("failed to purge subscription in database", "error", err)
+		return nil, err
+	}
+
+	ss.aggregatesChanged()
+	log.Warn("subscription purged", "id", uid, "purge_id", record.ID)
+	return record, nil
+}
+
+// PurgeUserData permanently deletes all subscriptions and saved views of a user, for erasure requests. Can't be undone.
+func (ss *SubscriptionServiceImpl) PurgeUserData(ctx context.Context, user apiModels.ItemByIDRequest) (*models.PurgeRecord, error) {
+	log := logger.FromContext(ctx)
+	userID, err := uuid.Parse(user.ID)
+	if err != nil {
+		log.Warn("failed to validate user id", "error", err)
+		return nil, fmt.Errorf("%w: invalid user UUID", ErrValidationError)
+	}
+
+	record, err := ss.storage.PurgeUserData(ctx, userID)
+	if err != nil {
+		log.Error("failed to purge user data in database", "error", err)
+		return nil, err
+	}
+
+	ss.aggregatesChanged()
+	log.Warn("user data purged", "user_id", userID, "subscriptions", record.Subscriptions, "views", record.Views, "purge_id", record.ID)
+	return record, nil
+}
+
+// ImportSubscriptions creates all subscriptions in one transaction, skipping the validations listed in allow.
+// Meant for migrating historical data by admins, regular clients go through CreateSubscription.
+// Duplicate detection isn't relaxable: one overlapping subscription fails the import.
+func (ss *SubscriptionServiceImpl) ImportSubscriptions(ctx context.Context, req *apiModels.ImportSubscriptionsRequest, allow []string) (*apiModels.ImportSubscriptionsResponse, error) {
+	log := logger.FromContext(ctx)
+	var relax relaxations
+	for _, a := range allow {
+		switch a {
+		case apiModels.RelaxHistoricalStart:
+			relax.historicalStart = true
+		case apiModels.RelaxLongDuration:
+			relax.longDuration = true
+		case apiModels.RelaxMonthNames:
+			relax.monthNames = true
+		default:
+			return nil, fmt.Errorf("%w: unknown validation to skip %q", ErrValidationError, a)
+		}
+	}
+
+	subs := make([]*models.Subscription, 0, len(req.Subscriptions))
+	resp := &apiModels.ImportSubscriptionsResponse{IDs: make([]uuid.UUID, 0, len(req.Subscriptions))}
+	for i := range req.Subscriptions {
+		if relax.monthNames {
+			normalizeMonths(&req.Subscriptions[i])
+		}
+		if err := ss.resolveReferences(ctx, &req.Subscriptions[i]); err != nil {
+			return nil, fmt.Errorf("%w (subscriptions[%d])", err, i)
+		}
+		sub, err := newRelaxedSubscription(ctx, &req.Subscriptions[i], relax)
+		if err != nil {
+			apiModels.NestFields(err, fmt.Sprintf("subscriptions[%d]", i))
+			return nil, fmt.Errorf("%w (subscriptions[%d])", err, i)
+		}
+		if err = ss.hooks.preCreate(ctx, sub); err != nil {
+			return nil, fmt.Errorf("%w (subscriptions[%d])", err, i)
+		}
+		if err = ss.checkDuplicate(ctx, sub, subs); err != nil {
+			return nil, fmt.Errorf("%w (subscriptions[%d])", err, i)
+		}
+		subs = append(subs, sub)
+		resp.IDs = append(resp.IDs, sub.ID)
+	}
+
+	if err := ss.storage.CreateSubscriptions(ctx, subs); err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			log.Warn("imported subscription already exists")
+			return nil, ErrConflict
+		}
+		log.Error("failed to import subscriptions in database", "error", err)
+		return nil, err
+	}
+
+	ss.aggregatesChanged()
+	ss.hooks.postCommit(ctx, creationEvents(subs)...)
+	log.Info("subscriptions imported", "count", len(subs), "relaxed", allow)
+	return resp, nil
+}
+
+// normalizeMonths rewrites human-style dates of req to MM-YYYY, the ones it can't parse are left for validation to reject
+func normalizeMonths(req *apiModels.CreateSubscriptionRequest) {
+	if t, err := dates.ParseMonth(req.StartDate); err == nil {
+		req.StartDate = t.Format(dates.Layout)
+	}
+	if req.EndDate != nil {
+		if t, err := dates.ParseMonth(*req.EndDate); err == nil {
+			end := t.Format(dates.Layout)
+			req.EndDate = &end
+		}
+	}
+}
+
+// SyncSubscriptions reconciles user's subscriptions with the desired set by external ID: missing ones are created,
+// differing ones updated and the ones absent from the set deleted. Subscriptions without external ID are left alone.
+func (ss *SubscriptionServiceImpl) SyncSubscriptions(ctx context.Context, user apiModels.ItemByIDRequest, req *apiModels.SyncSubscriptionsRequest, dryRun bool) (*apiModels.SyncSubscriptionsResponse, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(user.ID)
+	if err != nil {
+		log.Warn("failed to validate user ID", "error", err)
+		return nil, fmt.Errorf("%w: invalid user ID", ErrValidationError)
+	}
+
+	if err = req.Validate(); err != nil {
+		log.Warn("failed to validate sync payload", "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrValidationError, err)
+	}
+
+	desired := make([]*models.Subscription, 0, len(req.Subscriptions))
+	for i, item := range req.Subscriptions {
+		sub, subErr := newSubscription(ctx, item.CreateRequest(uid.String()))
+		if subErr != nil {
+			apiModels.NestFields(subErr, fmt.Sprintf("subscriptions[%d]", i))
+			return nil, subErr
+		}
+		desired = append(desired, sub)
+	}
+
+	resp := &apiModels.SyncSubscriptionsResponse{DryRun: dryRun}
+	var changes []HookEvent
+	plan := func(current []models.Subscription) (*models.SyncPlan, error) {
+		var p *models.SyncPlan
+		p, changes, resp.Unchanged = planSync(current, desired)
+		if dupErr := ss.syncDuplicate(ctx, current, p); dupErr != nil {
+			return nil, dupErr
+		}
+		if hookErr := ss.preSync(ctx, changes); hookErr != nil {
+			return nil, hookErr
+		}
+		return p, nil
+	}
+
+	if dryRun {
+		current, _, listErr := ss.storage.ListSubscriptions(ctx, models.SubscriptionFilter{UserID: &uid})
+		if listErr != nil {
+			log.Error("failed to list subscriptions from database", "error", listErr)
+			return nil, listErr
+		}
+		if _, err = plan(current); err != nil { // Dry run reports a rejection the real one would hit
+			return nil, err
+		}
+		resp.Changes = syncChanges(changes, apiModels.SerializationFromContext(ctx))
+		return resp, nil
+	}
+
+	applied, err := ss.storage.SyncUserSubscriptions(ctx, uid, plan)
+	if err != nil {
+		if errors.Is(err, ErrValidationError) || errors.Is(err, ErrConflict) { // Rejected by a hook or as a duplicate, already logged
+			return nil, err
+		}
+		if errors.Is(err, storage.ErrAlreadyExists) { // Subscription with the same external ID created concurrently
+			log.Warn("subscription with requested external ID already exists", "user_id", uid)
+			return nil, ErrConflict
+		}
+		log.Error("failed to sync subscriptions in database", "error", err)
+		return nil, err
+	}
+
+	ss.aggregatesChanged()
+	ss.hooks.postCommit(ctx, changes...)
+	resp.Changes = syncChanges(changes, apiModels.SerializationFromContext(ctx))
+	log.Info("subscriptions synced", "user_id", uid, "created", len(applied.Create), "updated", len(applied.Update), "deleted", len(applied.Delete), "unchanged", resp.Unchanged)
+	return resp, nil
+}
+
+// BulkUpdateSubscriptions sets the price of all user's subscriptions to matching services in one transaction,
+// e.g. when a provider changes pricing for everyone. Like a plan price change, it's updated in place in running subscriptions
+// and ended ones are left alone. Pre-update hooks see every change, one rejection cancels all of them,
+// as does a subscription whose credits would exceed the new price in any month of its period.
+func (ss *SubscriptionServiceImpl) BulkUpdateSubscriptions(ctx context.Context, user apiModels.ItemByIDRequest, req *apiModels.BulkUpdateSubscriptionsRequest) (*apiModels.BulkUpdateSubscriptionsResponse, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(user.ID)
+	if err != nil {
+		log.Warn("failed to validate user ID", "error", err)
+		return nil, fmt.Errorf("%w: invalid user ID", ErrValidationError)
+	}
+
+	if err = req.Validate(); err != nil {
+		log.Warn("failed to validate bulk update payload", "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrValidationError, err)
+	}
+
+	filter := models.SubscriptionFilter{UserID: &uid}
+	if name := strings.TrimSpace(req.ServiceNameFilter); strings.Contains(name, "*") {
+		filter.ServiceNameLike = &name
+	} else {
+		filter.ServiceName, filter.ServiceNameFold = &name, true
+	}
+
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	before, after, err := ss.storage.UpdateSubscriptionsPrice(ctx, filter, *req.NewPrice, month, func(before []models.Subscription) error {
+		for i := range before {
+			changed := before[i]
+			changed.Price, changed.FollowPlan, changed.UpdatedAt = *req.NewPrice, false, now
+			changed.Version++
+			if at, exceeded := creditsExceedPrice(changed, changed.StartDate, changed.EndDate); exceeded {
+				log.Warn("credits exceed new subscription price", "id", changed.ID, "price", changed.Price, "month", at.Format(dates.Layout))
+				return fmt.Errorf("%w: credits of subscription %s would exceed new price %d in %s",
+					ErrValidationError, changed.ID, changed.Price, at.Format(dates.Layout))
+			}
+			if hookErr := ss.hooks.preUpdate(ctx, &before[i], &changed); hookErr != nil {
+				return hookErr
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrValidationError) { // Rejected by a hook or credits, already logged
+			return nil, err
+		}
+		log.Error("failed to bulk update subscriptions in database", "error", err)
+		return nil, err
+	}
+
+	if len(after) > 0 {
+		ss.aggregatesChanged()
+		events := make([]HookEvent, len(after))
+		for i := range after {
+			events[i] = HookEvent{Action: HookActionUpdate, Before: &before[i], After: &after[i]}
+		}
+		ss.hooks.postCommit(ctx, events...)
+	}
+	log.Info("subscriptions bulk updated", "user_id", uid, "service_name_filter", req.ServiceNameFilter, "price", *req.NewPrice, "updated", len(after))
+	return &apiModels.BulkUpdateSubscriptionsResponse{Updated: len(after), Items: apiModels.NewSubscriptionResponses(after, apiModels.SerializationFromContext(ctx))}, nil
+}
+
+// planSync matches current subscriptions with desired ones by external ID and returns the changes as hook events.
+// Updated subscriptions keep their ID and creation time.
+func planSync(current []models.Subscription, desired []*models.Subscription) (*models.SyncPlan, []HookEvent, int) {
+	current = slices.Clone(current)
+	slices.SortFunc(current, func(a, b models.Subscription) int { // Same order whether read with or without lock
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID.String(), b.ID.String())
+	})
+
+	existing := make(map[string]*models.Subscription, len(current))
+	for i := range current {
+		if current[i].ExternalID != nil {
+			existing[*current[i].ExternalID] = &current[i]
+		}
+	}
+
+	plan := &models.SyncPlan{}
+	var creates, updates, deletes []HookEvent
+	unchanged := 0
+	kept := make(map[string]bool, len(desired))
+	for _, want := range desired {
+		kept[*want.ExternalID] = true
+		have, ok := existing[*want.ExternalID]
+		switch {
+		case !ok:
+			plan.Create = append(plan.Create, want)
+			creates = append(creates, HookEvent{Action: HookActionCreate, After: want})
+		case sameTerms(have, want):
+			unchanged++
+		default:
+			before := *have
+			after := *have
+			after.ServiceName, after.Price, after.Type, after.StartDate, after.EndDate = want.ServiceName, want.Price, want.Type, want.StartDate, want.EndDate
+			plan.Update = append(plan.Update, &after)
+			updates = append(updates, HookEvent{Action: HookActionUpdate, Before: &before, After: &after})
+		}
+	}
+	for i := range current {
+		if sub := &current[i]; sub.ExternalID != nil && !kept[*sub.ExternalID] {
+			plan.Delete = append(plan.Delete, sub)
+			deletes = append(deletes, HookEvent{Action: HookActionDelete, Before: sub})
+		}
+	}
+
+	changes := make([]HookEvent, 0, len(creates)+len(updates)+len(deletes))
+	changes = append(append(append(changes, creates...), updates...), deletes...)
+	return plan, changes, unchanged
+}
+
+// syncDuplicate rejects a sync creating a subscription that would overlap another one of the user, when duplicates are detected.
+// Like single updates, updated subscriptions aren't checked. The set is taken as it would be after the sync,
+// so subscriptions it updates or deletes count with their new terms or not at all.
+func (ss *SubscriptionServiceImpl) syncDuplicate(ctx context.Context, current []models.Subscription, p *models.SyncPlan) error {
+	if !viper.GetBool(config.LimitsDetectDuplicates) {
+		return nil
+	}
+	gone := make(map[uuid.UUID]bool, len(p.Update)+len(p.Delete))
+	for _, sub := range slices.Concat(p.Update, p.Delete) {
+		gone[sub.ID] = true
+	}
+	result := make([]*models.Subscription, 0, len(current)+len(p.Update)+len(p.Create))
+	for i := range current {
+		if !gone[current[i].ID] {
+			result = append(result, &current[i])
+		}
+	}
+	result = append(append(result, p.Update...), p.Create...)
+
+	for _, sub := range p.Create {
+		if existing := duplicateIn(result, sub); existing != nil {
+			logger.FromContext(ctx).Warn("synced subscription overlaps with another one", "id", existing.ID, "user_id", sub.UserID, "service_name", sub.ServiceName)
+			return fmt.Errorf("%w: subscription %q would overlap with subscription %s", ErrConflict, *sub.ExternalID, existing.ID)
+		}
+	}
+	return nil
+}
+
+func sameTerms(a, b *models.Subscription) bool {
+	if a.ServiceName != b.ServiceName || a.Price != b.Price || a.Type != b.Type || !a.StartDate.Equal(b.StartDate) {
+		return false
+	}
+	if a.EndDate == nil || b.EndDate == nil {
+		return a.EndDate == nil && b.EndDate == nil
+	}
+	return a.EndDate.Equal(*b.EndDate)
+}
+
+func (ss *SubscriptionServiceImpl) ListSubscriptions(ctx context.Context, req apiModels.ListSubscriptionsRequest) (*apiModels.ListSubscriptionsResponse, error) {
+	log := logger.FromContext(ctx)
+	if req.View != "" {
+		var err error
+		if req, err = ss.applyView(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+
+	filter := models.SubscriptionFilter{}
+
+	if req.UserID != "" {
+		uid, err := uuid.Parse(req.UserID)
+		if err != nil {
+			log.Warn("failed to validate user ID", "error", err)
+			return nil, fmt.Errorf("%w: invalid user ID", ErrValidationError)
+		}
+		filter.UserID = &uid
+	}
+	if err := serviceNameFilter(ctx, &filter, req.ServiceName, req.ServiceNameLike, req.ServiceNameCI); err != nil {
+		return nil, err
+	}
+	if req.CreatedAfter != "" {
+		after, err := time.Parse(time.RFC3339, req.CreatedAfter)
+		if err != nil {
+			log.Warn("failed to validate created_after", "error", err)
+			return nil, fmt.Errorf("%w: created_after must be an RFC3339 timestamp", ErrValidationError)
+		}
+		filter.CreatedAfter = &after
+	}
+	if req.CreatedBefore != "" {
+		before, err := time.Parse(time.RFC3339, req.CreatedBefore)
+		if err != nil {
+			log.Warn("failed to validate created_before", "error", err)
+			return nil, fmt.Errorf("%w: created_before must be an RFC3339 timestamp", ErrValidationError)
+		}
+		filter.CreatedBefore = &before
+	}
+	if filter.CreatedAfter != nil && filter.CreatedBefore != nil && !filter.CreatedAfter.Before(*filter.CreatedBefore) {
+		log.Warn("failed to validate creation time range", "created_after", req.CreatedAfter, "created_before", req.CreatedBefore)
+		return nil, fmt.Errorf("%w: created_after must precede created_before", ErrValidationError)
+	}
+	if req.ActiveAt != "" {
+		month, err := dates.String2Date(req.ActiveAt)
+		if err != nil {
+			log.Warn("failed to validate active_at", "error", err)
+			return nil, fmt.Errorf("%w: active_at must be in MM-YYYY format", ErrValidationError)
+		}
+		filter.ActiveAt = &month
+	}
+	switch req.Status {
+	case "":
+	case models.StatusActive, models.StatusUpcoming, models.StatusExpired, models.StatusCancelled, models.StatusDeleted:
+		now := time.Now().UTC()
+		filter.Status, filter.StatusAt = &req.Status, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		log.Warn("failed to validate status", "status", req.Status)
+		return nil, fmt.Errorf("%w: status must be one of %s, %s, %s, %s, %s", ErrValidationError,
+			models.StatusActive, models.StatusUpcoming, models.StatusExpired, models.StatusCancelled, models.StatusDeleted)
+	}
+	if req.MinPrice != nil {
+		if *req.MinPrice < 0 {
+			log.Warn("failed to validate min_price", "min_price", *req.MinPrice)
+			return nil, fmt.Errorf("%w: invalid min_price", ErrValidationError)
+		}
+		filter.MinPrice = req.MinPrice
+	}
+	if req.MaxPrice != nil {
+		if *req.MaxPrice < 0 {
+			log.Warn("failed to validate max_price", "max_price", *req.MaxPrice)
+			return nil, fmt.Errorf("%w: invalid max_price", ErrValidationError)
+		}
+		filter.MaxPrice = req.MaxPrice
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		log.Warn("failed to validate price range", "min_price", *filter.MinPrice, "max_price", *filter.MaxPrice)
+		return nil, fmt.Errorf("%w: min_price cannot exceed max_price", ErrValidationError)
+	}
+	if req.Limit != nil {
+		if *req.Limit <= 0 {
+			log.Warn("failed to validate limit", "limit", *req.Limit)
+			return nil, fmt.Errorf("%w: invalid limit", ErrValidationError)
+		}
+		filter.Limit = req.Limit
+	}
+	if req.Offset != nil {
+		if *req.Offset < 0 {
+			log.Warn("failed to validate offset", "offset", *req.Offset)
+			return nil, fmt.Errorf("%w: invalid offset", ErrValidationError)
+		}
+		filter.Offset = req.Offset
+	}
+	switch req.SortBy {
+	case "", models.SortByCreatedAt, models.SortByPrice, models.SortByStartDate, models.SortByServiceName:
+		filter.SortBy = req.SortBy
+	default:
+		log.Warn("failed to validate sort_by", "sort_by", req.SortBy)
+		return nil, fmt.Errorf("%w: sort_by must be one of %s, %s, %s, %s", ErrValidationError, models.SortByCreatedAt, models.SortByPrice, models.SortByStartDate, models.SortByServiceName)
+	}
+	switch req.Order {
+	case "", models.OrderDesc:
+		filter.SortDesc = true
+	case models.OrderAsc:
+	default:
+		log.Warn("failed to validate order", "order", req.Order)
+		return nil, fmt.Errorf("%w: order must be %s or %s", ErrValidationError, models.OrderAsc, models.OrderDesc)
+	}
+
+	list, total, err := ss.storage.ListSubscriptions(ctx, filter)
+	if err != nil {
+		log.Error("failed to list subscriptions from database", "error", err)
+		return nil, err
+	}
+
+	resp := &apiModels.ListSubscriptionsResponse{Items: apiModels.NewSubscriptionResponses(list, apiModels.SerializationFromContext(ctx)), TotalCount: total, Limit: filter.Limit}
+	if filter.Offset != nil {
+		resp.Offset = *filter.Offset
+	}
+	if next := resp.Offset + len(list); int64(next) < total {
+		resp.NextOffset = &next
+	}
+
+	log.Debug("subscriptions list retrieved", "id_filter", filter.UserID, "service_filter", filter.ServiceName, "limit", filter.Limit, "offset", filter.Offset, "total", total)
+	return resp, nil
+}
+
+// applyView fills filters missing from the request with the ones saved in the view and scopes the list to the view owner
+func (ss *SubscriptionServiceImpl) applyView(ctx context.Context, req apiModels.ListSubscriptionsRequest) (apiModels.ListSubscriptionsRequest, error) {
+	log := logger.FromContext(ctx)
+	vid, err := uuid.Parse(req.View)
+	if err != nil {
+		log.Warn("failed to validate view id", "error", err)
+		return req, fmt.Errorf("%w: invalid view UUID", ErrValidationError)
+	}
+
+	view, err := ss.storage.GetViewByID(ctx, vid)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Warn("requested view not found", "id", vid)
+			return req, ErrViewNotFound
+		} else {
+			log.Error("failed to get view from database", "error", err)
+			return req, err
+		}
+	}
+
+	if req.UserID == "" {
+		req.UserID = view.UserID.String()
+	} else if req.UserID != view.UserID.String() {
+		log.Warn("view belongs to another user", "view_id", vid, "user_id", req.UserID)
+		return req, fmt.Errorf("%w: view belongs to another user", ErrValidationError)
+	}
+	if req.ServiceName == "" && view.ServiceName != nil {
+		req.ServiceName = *view.ServiceName
+	}
+	if req.CreatedAfter == "" && view.CreatedAfter != nil {
+		req.CreatedAfter = view.CreatedAfter.Format(time.RFC3339)
+	}
+	if req.CreatedBefore == "" && view.CreatedBefore != nil {
+		req.CreatedBefore = view.CreatedBefore.Format(time.RFC3339)
+	}
+	if req.MinPrice == nil {
+		req.MinPrice = view.MinPrice
+	}
+	if req.MaxPrice == nil {
+		req.MaxPrice = view.MaxPrice
+	}
+	if req.SortBy == "" && view.SortBy != nil {
+		req.SortBy = *view.SortBy
+	}
+	if req.Order == "" && view.Order != nil {
+		req.Order = *view.Order
+	}
+	if req.Limit == nil {
+		req.Limit = view.Limit
+	}
+
+	return req, nil
+}
+
+func (ss *SubscriptionServiceImpl) TotalSubscriptionsCost(ctx context.Context, req apiModels.TotalCostRequest) (*apiModels.TotalCostResponse, error) {
+	log := logger.FromContext(ctx)
+	filter, startDate, endDate, err := parseTotalCostRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GroupBy != "" && req.GroupBy != apiModels.GroupByServiceName && req.GroupBy != apiModels.GroupByMonth {
+		log.Warn("failed to validate total cost grouping", "group_by", req.GroupBy)
+		return nil, fmt.Errorf("%w: group_by must be %s or %s", ErrValidationError, apiModels.GroupByServiceName, apiModels.GroupByMonth)
+	}
+
+	key := newAggregateKey(filter, startDate, endDate)
+	key.groupBy = req.GroupBy
+	resp, err := cachedAggregate(ctx, ss.totalCache, key, func(ctx context.Context) (*apiModels.TotalCostResponse, error) {
+		if req.GroupBy != "" {
+			return ss.costBreakdown(ctx, filter, startDate, endDate, req.GroupBy)
+		}
+		var totalCost int64
+		var err error
+		if viper.GetBool(config.ShadowTotalCostEnabled) {
+			totalCost, err = ss.shadowTotalCost(ctx, filter, startDate, endDate)
+		} else {
+			totalCost, err = ss.storage.TotalSubscriptionsCost(ctx, filter, startDate, endDate)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &apiModels.TotalCostResponse{TotalCost: totalCost}, nil
+	})
+	if err != nil {
+		log.Error("failed to calculate total cost in database", "error", err)
+		return nil, err
+	}
+
+	log.Info("calculated total cost", "user_id", req.UserID, "total", resp.TotalCost, "group_by", req.GroupBy, "start", startDate.Format("01-2006"), "end", endDate.Format("01-2006"))
+	return resp, nil
+}
+
+// costBreakdown computes the total per service or per month of the period with the Go loop, so the breakdown always adds up to the total.
+// Services are ordered by cost, most expensive first; months go in order and include ones without any cost.
+func (ss *SubscriptionServiceImpl) costBreakdown(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time, groupBy string) (*apiModels.TotalCostResponse, error) {
+	subs, err := ss.storage.ListSubscriptionsInPeriod(ctx, filter, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &apiModels.TotalCostResponse{Breakdown: []apiModels.CostBreakdownItem{}}
+	switch groupBy {
+	case apiModels.GroupByServiceName:
+		byService := make(map[string]int)
+		for _, sub := range subs {
+			_, _, months := clipToPeriod(sub, startDate, endDate)
+			if months == 0 {
+				continue
+			}
+			i, ok := byService[sub.ServiceName]
+			if !ok {
+				i = len(resp.Breakdown)
+				byService[sub.ServiceName] = i
+				resp.Breakdown = append(resp.Breakdown, apiModels.CostBreakdownItem{ServiceName: sub.ServiceName})
+			}
+			resp.Breakdown[i].Months += months
+			resp.Breakdown[i].Cost += calculateSubscriptionCost(sub, startDate, endDate)
+		}
+		slices.SortFunc(resp.Breakdown, func(a, b apiModels.CostBreakdownItem) int {
+			if a.Cost != b.Cost {
+				return cmp.Compare(b.Cost, a.Cost)
+			}
+			return strings.Compare(a.ServiceName, b.ServiceName)
+		})
+	case apiModels.GroupByMonth:
+		for month := startDate; !month.After(endDate); month = month.AddDate(0, 1, 0) {
+			item := apiModels.CostBreakdownItem{Month: month.Format(dates.Layout)}
+			for _, sub := range subs {
+				if _, _, n := clipToPeriod(sub, month, month); n > 0 {
+					item.Months++
+					item.Cost += calculateSubscriptionCost(sub, month, month)
+				}
+			}
+			resp.Breakdown = append(resp.Breakdown, item)
+		}
+	}
+
+	for _, item := range resp.Breakdown {
+		resp.TotalCost += item.Cost
+	}
+	return resp, nil
+}
+
+// TotalSubscriptionsCostBatch computes totals of many users in one grouped query, always via the SQL aggregate
+func (ss *SubscriptionServiceImpl) TotalSubscriptionsCostBatch(ctx context.Context, req apiModels.BatchTotalCostRequest) (*apiModels.BatchTotalCostResponse, error) {
+	log := logger.FromContext(ctx)
+	if len(req.UserIDs) == 0 || len(req.UserIDs) > maxBatchSize {
+		log.Warn("failed to validate batch size", "size", len(req.UserIDs))
+		return nil, fmt.Errorf("%w: batch must contain 1 to %d user IDs", ErrValidationError, maxBatchSize)
+	}
+	filter, startDate, endDate, err := parseTotalCostRequest(ctx, apiModels.TotalCostRequest{ServiceName: req.ServiceName, ServiceNameCI: req.ServiceNameCI, ServiceNameLike: req.ServiceNameLike, StartDate: req.StartDate, EndDate: req.EndDate})
+	if err != nil {
+		return nil, err
+	}
+	userIDs := make([]uuid.UUID, len(req.UserIDs))
+	for i, id := range req.UserIDs {
+		if userIDs[i], err = uuid.Parse(id); err != nil {
+			log.Warn("failed to validate user ID", "error", err)
+			return nil, fmt.Errorf("%w: invalid user ID '%s'", ErrValidationError, id)
+		}
+	}
+
+	totals, err := ss.storage.TotalSubscriptionsCostByUser(ctx, filter, userIDs, startDate, endDate)
+	if err != nil {
+		log.Error("failed to calculate total costs in database", "error", err)
+		return nil, err
+	}
+
+	resp := &apiModels.BatchTotalCostResponse{Totals: make([]apiModels.UserTotalCost, len(userIDs))}
+	for i, userID := range userIDs {
+		resp.Totals[i] = apiModels.UserTotalCost{UserID: userID, TotalCost: totals[userID]}
+	}
+
+	log.Info("calculated total costs", "users", len(userIDs), "start", startDate.Format(dates.Layout), "end", endDate.Format(dates.Layout))
+	return resp, nil
+}
+
+// shadowTotalCost computes the total both via the SQL aggregate and the Go loop, logs any mismatch and returns
+// the one selected by config. Failure of the other path is only logged, so shadowing never breaks a request.
+func (ss *SubscriptionServiceImpl) shadowTotalCost(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time) (int64, error) {
+	log := logger.FromContext(ctx)
+	primarySQL := viper.GetString(config.ShadowTotalCostServe) != "go"
+
+	sqlTotal, sqlErr := ss.storage.TotalSubscriptionsCost(ctx, filter, startDate, endDate)
+	goTotal, goErr := ss.totalCostInGo(ctx, filter, startDate, endDate)
+
+	switch {
+	case sqlErr != nil && goErr == nil && !primarySQL:
+		log.Warn("shadow total cost: SQL aggregate failed", "error", sqlErr)
+	case goErr != nil && sqlErr == nil && primarySQL:
+		log.Warn("shadow total cost: Go loop failed", "error", goErr)
+	case sqlErr == nil && goErr == nil && sqlTotal != goTotal:
+		log.Warn("shadow total cost mismatch", "sql", sqlTotal, "go", goTotal, "diff", sqlTotal-goTotal,
+			"user_id", filter.UserID, "service_name", filter.ServiceName, "start", startDate.Format(dates.Layout), "end", endDate.Format(dates.Layout))
+	}
+
+	if primarySQL {
+		return sqlTotal, sqlErr
+	}
+	return goTotal, goErr
+}
+
+func (ss *SubscriptionServiceImpl) totalCostInGo(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time) (int64, error) {
+	subs, err := ss.storage.ListSubscriptionsInPeriod(ctx, filter, startDate, endDate)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, sub := range subs {
+		total += calculateSubscriptionCost(sub, startDate, endDate)
+	}
+	return total, nil
+}
+
+// ExplainTotalCost breaks the total down per subscription, computed the same way as the SQL aggregate
+func (ss *SubscriptionServiceImpl) ExplainTotalCost(ctx context.Context, req apiModels.TotalCostRequest) (*apiModels.CostExplanationResponse, error) {
+	log := logger.FromContext(ctx)
+	filter, startDate, endDate, err := parseTotalCostRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := cachedAggregate(ctx, ss.explainCache, newAggregateKey(filter, startDate, endDate), func(ctx context.Context) (*apiModels.CostExplanationResponse, error) {
+		return ss.explainTotalCost(ctx, filter, startDate, endDate)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("explained total cost", "user_id", req.UserID, "total", resp.TotalCost, "items", len(resp.Items), "start", resp.StartDate, "end", resp.EndDate)
+	return resp, nil
+}
+
+func (ss *SubscriptionServiceImpl) explainTotalCost(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time) (*apiModels.CostExplanationResponse, error) {
+	log := logger.FromContext(ctx)
+	subs, err := ss.storage.ListSubscriptionsInPeriod(ctx, filter, startDate, endDate)
+	if err != nil {
+		log.Error("failed to list subscriptions in period from database", "error", err)
+		return nil, err
+	}
+
+	resp := &apiModels.CostExplanationResponse{
+		StartDate: startDate.Format(dates.Layout),
+		EndDate:   endDate.Format(dates.Layout),
+		Items:     make([]apiModels.CostExplanationItem, 0, len(subs)),
+	}
+	for _, sub := range subs {
+		from, to, months := clipToPeriod(sub, startDate, endDate)
+		if months == 0 {
+			continue
+		}
+		credits := creditsInPeriod(sub, startDate, endDate)
+		paused := -pausedInPeriod(sub, startDate, endDate)
+		amount := int64(months)*int64(sub.Price) + credits + paused
+		resp.Items = append(resp.Items, apiModels.CostExplanationItem{
+			SubscriptionID: sub.ID,
+			ServiceName:    sub.ServiceName,
+			Price:          sub.Price,
+			From:           from.Format(dates.Layout),
+			To:             to.Format(dates.Layout),
+			Months:         months,
+			Credits:        credits,
+			Paused:         paused,
+			Amount:         amount,
+		})
+		resp.TotalCost += amount
+	}
+
+	return resp, nil
+}
+
+// UserSummary describes user's subscriptions at a glance, active ones are those covering the current month
+func (ss *SubscriptionServiceImpl) UserSummary(ctx context.Context, user apiModels.ItemByIDRequest) (*apiModels.UserSummaryResponse, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(user.ID)
+	if err != nil {
+		log.Warn("failed to validate user ID", "error", err)
+		return nil, fmt.Errorf("%w: invalid user ID", ErrValidationError)
+	}
+
+	now := time.Now().UTC()
+	summary, err := ss.storage.UserSummary(ctx, uid, time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		log.Error("failed to summarize user subscriptions in database", "error", err)
+		return nil, err
+	}
+
+	resp := &apiModels.UserSummaryResponse{
+		UserID:               uid,
+		ActiveSubscriptions:  summary.ActiveCount,
+		MonthlyCost:          summary.MonthlyCost,
+		MostExpensiveService: summary.MostExpensiveService,
+	}
+	if summary.EarliestStart != nil {
+		earliest, latest := summary.EarliestStart.Format(dates.Layout), summary.LatestStart.Format(dates.Layout)
+		resp.EarliestStartDate, resp.LatestStartDate = &earliest, &latest
+	}
+
+	log.Debug("user summary computed", "user_id", uid, "active", resp.ActiveSubscriptions, "monthly_cost", resp.MonthlyCost)
+	return resp, nil
+}
+
+func parseTotalCostRequest(ctx context.Context, req apiModels.TotalCostRequest) (models.SubscriptionFilter, time.Time, time.Time, error) {
+	log := logger.FromContext(ctx)
+	filter := models.SubscriptionFilter{}
+
+	startDate, err := dates.String2Date(req.StartDate)
+	if err != nil {
+		log.Warn("failed to validate subscription dates", "error", err)
+		return filter, time.Time{}, time.Time{}, fmt.Errorf("%w: invalid start date", ErrValidationError)
+	}
+	endDate, err := dates.String2Date(req.EndDate)
+	if err != nil {
+		log.Warn("failed to validate subscription dates", "error", err)
+		return filter, time.Time{}, time.Time{}, fmt.Errorf("%w: invalid end date", ErrValidationError)
+	}
+	if endDate.Before(startDate) {
+		log.Warn("failed to validate subscription dates", "error", err)
+		return filter, time.Time{}, time.Time{}, fmt.Errorf("%w: end date cannot precede start date", ErrValidationError)
+	}
+	if maxYears := viper.GetInt(config.LimitsTotalCostMaxYears); maxYears > 0 && dates.MonthSpan(startDate, endDate) > maxYears*12 {
+		log.Warn("total cost period exceeds limit", "start", req.StartDate, "end", req.EndDate, "max_years", maxYears)
+		return filter, time.Time{}, time.Time{}, fmt.Errorf("%w: period cannot exceed %d years", ErrValidationError, maxYears)
+	}
+
+	if req.UserID != "" {
+		var uid uuid.UUID
+		uid, err = uuid.Parse(req.UserID)
+		if err != nil {
+			log.Warn("failed to validate user ID", "error", err)
+			return filter, time.Time{}, time.Time{}, fmt.Errorf("%w: invalid user ID", ErrValidationError)
+		}
+		filter.UserID = &uid
+	}
+	if err = serviceNameFilter(ctx, &filter, req.ServiceName, req.ServiceNameLike, req.ServiceNameCI); err != nil {
+		return filter, time.Time{}, time.Time{}, err
+	}
+
+	return filter, startDate, endDate, nil
+}
+
+// serviceNameFilter sets service name filters from service_name, service_name_like and service_name_ci
+func serviceNameFilter(ctx context.Context, filter *models.SubscriptionFilter, name, like string, fold bool) error {
+	log := logger.FromContext(ctx)
+	if fold && name == "" {
+		log.Warn("failed to validate service name filter", "error", "service_name_ci without service_name")
+		return fmt.Errorf("%w: service_name_ci requires service_name", ErrValidationError)
+	}
+	if name != "" {
+		filter.ServiceName = &name
+		filter.ServiceNameFold = fold
+	}
+	if like != "" {
+		filter.ServiceNameLike = &like
+	}
+	return nil
+}
+
+func (ss *SubscriptionServiceImpl) SuggestServiceNames(ctx context.Context, req apiModels.SuggestServicesRequest) (*apiModels.SuggestServicesResponse, error) {
+	log := logger.FromContext(ctx)
+	prefix := strings.TrimSpace(req.Query)
+	if prefix == "" {
+		log.Warn("failed to validate suggest query", "query", req.Query)
+		return nil, fmt.Errorf("%w: query is required", ErrValidationError)
+	}
+
+	var userID *uuid.UUID
+	if req.UserID != "" {
+		uid, err := uuid.Parse(req.UserID)
+		if err != nil {
+			log.Warn("failed to validate user ID", "error", err)
+			return nil, fmt.Errorf("%w: invalid user ID", ErrValidationError)
+		}
+		userID = &uid
+	}
+
+	limit := defaultSuggestLimit
+	if req.Limit != nil {
+		limit = *req.Limit
+	}
+
+	key := suggestKey{prefix: strings.ToLower(prefix), userID: req.UserID, limit: limit}
+	if names, ok := ss.suggestCache.Get(key); ok {
+		log.Debug("service name suggestions served from cache", "query", prefix, "user_id", req.UserID)
+		return &apiModels.SuggestServicesResponse{Services: names}, nil
+	}
+
+	names, err := ss.storage.SuggestServiceNames(ctx, prefix, userID, limit)
+	if err != nil {
+		log.Error("failed to suggest service names from database", "error", err)
+		return nil, err
+	}
+	if names == nil {
+		names = []string{}
+	}
+	ss.suggestCache.Set(key, names)
+
+	log.Debug("service name suggestions retrieved", "query", prefix, "user_id", req.UserID, "count", len(names))
+	return &apiModels.SuggestServicesResponse{Services: names}, nil
+}
+
+func calculateSubscriptionCost(sub models.Subscription, startDate, endDate time.Time) int64 {
+	_, _, months := clipToPeriod(sub, startDate, endDate)
+	return int64(months)*int64(sub.Price) + creditsInPeriod(sub, startDate, endDate) - pausedInPeriod(sub, startDate, endDate)
+}
+
+// creditsInPeriod sums subscription credits over months they share with both the subscription and [startDate, endDate].
+// Credits of a month take off no more than the price, so stacked credits or a later price cut can't make it negative.
+func creditsInPeriod(sub models.Subscription, startDate, endDate time.Time) int64 {
+	from, to, months := clipToPeriod(sub, startDate, endDate)
+	if months == 0 || len(sub.Credits) == 0 {
+		return 0
+	}
+
+	var total int64
+	for month := from; !month.After(to); month = month.AddDate(0, 1, 0) {
+		total += max(creditsInMonth(sub, month), -int64(sub.Price))
+	}
+	return total
+}
+
+// creditsInMonth sums credits of the subscription active in the month, as is
+func creditsInMonth(sub models.Subscription, month time.Time) int64 {
+	var total int64
+	for _, c := range sub.Credits {
+		if !c.StartDate.After(month) && (c.EndDate == nil || !c.EndDate.Before(month)) {
+			total += int64(c.Amount - sub.Price*c.Percent/100) // Truncated like integer division in SQL
+		}
+	}
+	return total
+}
+
+// pausedInPeriod sums what paused months shared with both the subscription and [startDate, endDate] would have cost,
+// their price and credits
+func pausedInPeriod(sub models.Subscription, startDate, endDate time.Time) int64 {
+	from, to, months := clipToPeriod(sub, startDate, endDate)
+	if months == 0 {
+		return 0
+	}
+
+	var total int64
+	for _, p := range sub.Pauses {
+		start, end, n := clipToPeriod(models.Subscription{StartDate: p.StartDate, EndDate: p.EndDate}, from, to)
+		if n == 0 {
+			continue
+		}
+		total += int64(n) * int64(sub.Price)
+		for month := start; !month.After(end); month = month.AddDate(0, 1, 0) {
+			total += creditsInPeriod(sub, month, month)
+		}
+	}
+	return total
+}
+
+// clipToPeriod returns the part of the subscription within [startDate, endDate] and its length in months, 0 if they don't overlap
+func clipToPeriod(sub models.Subscription, startDate, endDate time.Time) (time.Time, time.Time, int) {
+	start := startDate
+	if sub.StartDate.After(startDate) {
+		start = sub.StartDate
+	}
+	end := endDate
 	if sub.EndDate != nil && sub.EndDate.Before(endDate) {
 		end = *sub.EndDate
 	}
 	if end.Before(start) {
-		return 0
+		return start, end, 0
 	}
 
-	y1, m1, _ := start.Date()
-	y2, m2, _ := end.Date()
-	months := (y2-y1)*12 + int(m2-m1) + 1
-	if months < 0 {
-		return 0
-	} else {
-		return int64(months) * int64(sub.Price)
+	return start, end, dates.MonthSpan(start, end)
+}
+
+func (ss *SubscriptionServiceImpl) CreateView(ctx context.Context, user apiModels.ItemByIDRequest, req *apiModels.CreateViewRequest) (*models.SavedView, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(user.ID)
+	if err != nil {
+		log.Warn("failed to validate user ID", "error", err)
+		return nil, fmt.Errorf("%w: invalid user ID", ErrValidationError)
+	}
+
+	if err = req.Validate(); err != nil {
+		log.Warn("failed to validate view payload", "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrValidationError, err)
+	}
+
+	view := &models.SavedView{
+		ID:          uuid.New(),
+		UserID:      uid,
+		Name:        strings.TrimSpace(req.Name),
+		ServiceName: req.ServiceName,
+		MinPrice:    req.MinPrice,
+		MaxPrice:    req.MaxPrice,
+		SortBy:      req.SortBy,
+		Order:       req.Order,
+		Limit:       req.Limit,
+		CreatedAt:   time.Now(),
+	}
+	if req.CreatedAfter != nil {
+		after, _ := time.Parse(time.RFC3339, *req.CreatedAfter) // Assuming already validated above
+		view.CreatedAfter = &after
+	}
+	if req.CreatedBefore != nil {
+		before, _ := time.Parse(time.RFC3339, *req.CreatedBefore) // Assuming already validated above
+		view.CreatedBefore = &before
+	}
+
+	if err = ss.storage.CreateView(ctx, view); err != nil {
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			log.Warn("view with requested name already exists", "user_id", uid, "name", view.Name)
+			return nil, ErrViewConflict
+		}
+		log.Error("failed to create view in database", "error", err)
+		return nil, err
+	}
+
+	log.Info("view created", "id", view.ID, "user_id", uid)
+	return view, nil
+}
+
+func (ss *SubscriptionServiceImpl) ListViews(ctx context.Context, user apiModels.ItemByIDRequest) ([]models.SavedView, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(user.ID)
+	if err != nil {
+		log.Warn("failed to validate user ID", "error", err)
+		return nil, fmt.Errorf("%w: invalid user ID", ErrValidationError)
+	}
+
+	views, err := ss.storage.ListViews(ctx, uid)
+	if err != nil {
+		log.Error("failed to list views from database", "error", err)
+		return nil, err
+	}
+	if views == nil {
+		views = []models.SavedView{}
+	}
+
+	log.Debug("views list retrieved", "user_id", uid, "count", len(views))
+	return views, nil
+}
+
+// CreateCredit attaches a discount to the subscription. It must fit into the subscription period,
+// and together with credits already there it must not exceed the price in any month it covers.
+// Percentage credits follow later price changes, e.g. "50% for the first 3 months".
+func (ss *SubscriptionServiceImpl) CreateCredit(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.CreateCreditRequest) (*models.SubscriptionCredit, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		log.Warn("failed to validate subscription id", "error", err)
+		return nil, fmt.Errorf("%w: invalid subscription UUID", ErrValidationError)
+	}
+
+	if err = req.Validate(); err != nil {
+		log.Warn("failed to validate credit payload", "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrValidationError, err)
+	}
+	start, _ := dates.String2Date(req.StartDate) // Assuming already validated above
+	var end *time.Time
+	if req.EndDate != nil {
+		parsed, _ := dates.String2Date(*req.EndDate)
+		end = &parsed
+	}
+
+	sub, err := ss.storage.GetSubscriptionByID(ctx, uid)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Warn("requested subscription not found", "error", err)
+			return nil, ErrNotFound
+		}
+		log.Error("failed to get subscription from database", "error", err)
+		return nil, err
+	}
+
+	switch {
+	case start.Before(sub.StartDate):
+		return nil, fmt.Errorf("%w: credit cannot start before the subscription", ErrValidationError)
+	case sub.EndDate != nil && (start.After(*sub.EndDate) || end != nil && end.After(*sub.EndDate)):
+		return nil, fmt.Errorf("%w: credit cannot last past subscription end", ErrValidationError)
+	}
+
+	credit := &models.SubscriptionCredit{
+		ID:             uuid.New(),
+		SubscriptionID: uid,
+		Amount:         req.Amount,
+		Percent:        req.Percent,
+		StartDate:      start,
+		EndDate:        end,
+		Description:    strings.TrimSpace(req.Description),
+		CreatedAt:      time.Now(),
+	}
+
+	credits, err := ss.storage.ListCredits(ctx, uid)
+	if err != nil {
+		log.Error("failed to list credits from database", "error", err)
+		return nil, err
+	}
+	withCredit := *sub
+	withCredit.Credits = append(slices.Clone(credits), *credit)
+	if month, ok := creditsExceedPrice(withCredit, start, end); ok {
+		log.Warn("credits exceed subscription price", "subscription_id", uid, "month", month.Format(dates.Layout))
+		return nil, fmt.Errorf("%w: credits cannot exceed subscription price %d, exceeded in %s", ErrValidationError, sub.Price, month.Format(dates.Layout))
+	}
+
+	if err = ss.storage.CreateCredit(ctx, credit); err != nil {
+		log.Error("failed to create credit in database", "error", err)
+		return nil, err
+	}
+
+	ss.aggregatesChanged()
+	log.Info("credit created", "id", credit.ID, "subscription_id", uid, "amount", credit.Amount, "percent", credit.Percent)
+	return credit, nil
+}
+
+// creditsExceedPrice finds the first month of [start, end] in which credits of the subscription sum up to more than its price.
+// Their sum only grows when a credit starts, so it's enough to check months credits start in.
+func creditsExceedPrice(sub models.Subscription, start time.Time, end *time.Time) (time.Time, bool) {
+	months := []time.Time{start}
+	for _, c := range sub.Credits {
+		if c.StartDate.After(start) && (end == nil || !c.StartDate.After(*end)) {
+			months = append(months, c.StartDate)
+		}
+	}
+	slices.SortFunc(months, time.Time.Compare)
+	for _, month := range months {
+		if creditsInMonth(sub, month) < -int64(sub.Price) {
+			return month, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (ss *SubscriptionServiceImpl) ListCredits(ctx context.Context, id apiModels.ItemByIDRequest) ([]models.SubscriptionCredit, error) {
+	log := logger.FromContext(ctx)
+	uid, err := uuid.Parse(id.ID)
+	if err != nil {
+		log.Warn("failed to validate subscription id", "error", err)
+		return nil, fmt.Errorf("%w: invalid subscription UUID", ErrValidationError)
+	}
+
+	if _, err = ss.storage.GetSubscriptionByID(ctx, uid); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Warn("requested subscription not found", "error", err)
+			return nil, ErrNotFound
+		}
+		log.Error("failed to get subscription from database", "error", err)
+		return nil, err
+	}
+
+	credits, err := ss.storage.ListCredits(ctx, uid)
+	if err != nil {
+		log.Error("failed to list credits from database", "error", err)
+		return nil, err
+	}
+	if credits == nil {
+		credits = []models.SubscriptionCredit{}
 	}
+	return credits, nil
 }
@@ -3,33 +3,97 @@ package service
 import (
 	"testing"
 
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"reflect"
+	"slices"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
 
 	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/config"
+	"subscription-aggregator-service/internal/logger"
 	"subscription-aggregator-service/internal/models"
 	"subscription-aggregator-service/internal/storage"
+	"subscription-aggregator-service/internal/utils/dates"
+	"subscription-aggregator-service/tests/factory"
 )
 
 // MockStorage implements storage.SubscriptionStorage for testing
 type MockStorage struct {
 	subscriptions map[uuid.UUID]*models.Subscription
+	views         map[uuid.UUID]*models.SavedView
+	plans         map[uuid.UUID]*models.Plan
+	orgUnits      map[uuid.UUID]*models.OrgUnit
+	deleted       map[uuid.UUID]*models.Subscription
+	undoTokens    map[uuid.UUID]models.UndoToken
+	suggestCalls  int
+	findings      []models.IntegrityFinding
+	purges        []models.PurgeRecord
+	checks        int
+	checkErr      error
+
+	renames map[uuid.UUID]*models.ServiceRename
+
+	bulkMu   sync.Mutex // Bulk delete jobs run in background goroutines
+	bulkJobs map[uuid.UUID]*models.BulkDeleteJob
 }
 
 func NewMockStorage() *MockStorage {
 	return &MockStorage{
 		subscriptions: make(map[uuid.UUID]*models.Subscription),
+		views:         make(map[uuid.UUID]*models.SavedView),
+		plans:         make(map[uuid.UUID]*models.Plan),
+		orgUnits:      make(map[uuid.UUID]*models.OrgUnit),
+		deleted:       make(map[uuid.UUID]*models.Subscription),
+		undoTokens:    make(map[uuid.UUID]models.UndoToken),
+		renames:       make(map[uuid.UUID]*models.ServiceRename),
+		bulkJobs:      make(map[uuid.UUID]*models.BulkDeleteJob),
 	}
 }
 
 func (m *MockStorage) CreateSubscription(ctx context.Context, s *models.Subscription) error {
+	if _, ok := m.subscriptions[s.ID]; ok {
+		return storage.ErrAlreadyExists
+	}
 	m.subscriptions[s.ID] = s
 	return nil
 }
 
+func (m *MockStorage) CreateSubscriptions(ctx context.Context, subs []*models.Subscription) error {
+	for _, s := range subs {
+		if _, ok := m.subscriptions[s.ID]; ok {
+			return storage.ErrAlreadyExists
+		}
+	}
+	for _, s := range subs {
+		m.subscriptions[s.ID] = s
+	}
+	return nil
+}
+
+func (m *MockStorage) CreateSubscriptionIfAbsent(ctx context.Context, s *models.Subscription) (*models.Subscription, bool, error) {
+	for _, sub := range m.subscriptions {
+		if sub.UserID == s.UserID && sub.ExternalID != nil && *sub.ExternalID == *s.ExternalID {
+			return sub, false, nil
+		}
+	}
+	if err := m.CreateSubscription(ctx, s); err != nil {
+		return nil, false, err
+	}
+	return s, true, nil
+}
+
 func (m *MockStorage) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
 	if sub, ok := m.subscriptions[id]; ok {
 		return sub, nil
@@ -37,14 +101,53 @@ func (m *MockStorage) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*m
 	return nil, storage.ErrNotFound
 }
 
+func (m *MockStorage) SubscriptionExistsByID(ctx context.Context, id uuid.UUID) (bool, error) {
+	_, ok := m.subscriptions[id]
+	return ok, nil
+}
+
+func (m *MockStorage) FindOverlappingSubscription(ctx context.Context, s *models.Subscription) (*models.Subscription, error) {
+	for _, sub := range m.subscriptions {
+		if sub.UserID != s.UserID || !strings.EqualFold(sub.ServiceName, s.ServiceName) {
+			continue
+		}
+		if (sub.EndDate == nil || !sub.EndDate.Before(s.StartDate)) && (s.EndDate == nil || !sub.StartDate.After(*s.EndDate)) {
+			return sub, nil
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
 func (m *MockStorage) UpdateSubscriptionByID(ctx context.Context, s *models.Subscription) error {
 	if _, ok := m.subscriptions[s.ID]; !ok {
 		return storage.ErrNotFound
 	}
+	s.Version++
 	m.subscriptions[s.ID] = s
 	return nil
 }
 
+func (m *MockStorage) UpdateSubscriptionsPrice(ctx context.Context, filter models.SubscriptionFilter, price int, month time.Time, check func(before []models.Subscription) error) ([]models.Subscription, []models.Subscription, error) {
+	var before []models.Subscription
+	for _, sub := range m.subscriptions {
+		if sub.UserID == *filter.UserID && sub.Price != price && matchesServiceName(filter, sub.ServiceName) && (sub.EndDate == nil || !sub.EndDate.Before(month)) {
+			before = append(before, *sub)
+		}
+	}
+	sort.Slice(before, func(i, j int) bool { return before[i].ID.String() < before[j].ID.String() })
+	if err := check(before); err != nil {
+		return nil, nil, err
+	}
+	after := make([]models.Subscription, len(before))
+	for i, sub := range before {
+		stored := m.subscriptions[sub.ID]
+		stored.Price, stored.FollowPlan = price, false
+		stored.Version++
+		after[i] = *stored
+	}
+	return before, after, nil
+}
+
 func (m *MockStorage) DeleteSubscriptionByID(ctx context.Context, id uuid.UUID) error {
 	if _, ok := m.subscriptions[id]; !ok {
 		return storage.ErrNotFound
@@ -53,23 +156,231 @@ func (m *MockStorage) DeleteSubscriptionByID(ctx context.Context, id uuid.UUID)
 	return nil
 }
 
-func (m *MockStorage) ListSubscriptions(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, error) {
-	var result []models.Subscription
+func (m *MockStorage) DeleteSubscriptionWithUndo(ctx context.Context, id uuid.UUID, expiresAt time.Time) (*models.UndoToken, error) {
+	sub, ok := m.subscriptions[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	delete(m.subscriptions, id)
+	m.deleted[id] = sub
+	token := models.UndoToken{Token: uuid.New(), SubscriptionID: id, ExpiresAt: expiresAt}
+	m.undoTokens[token.Token] = token
+	return &token, nil
+}
+
+func (m *MockStorage) UndoDeletion(ctx context.Context, token uuid.UUID, check func(sub *models.Subscription) error) (*models.Subscription, error) {
+	t, ok := m.undoTokens[token]
+	if !ok || !t.ExpiresAt.After(time.Now()) {
+		return nil, storage.ErrNotFound
+	}
+	sub, ok := m.deleted[t.SubscriptionID]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	if err := check(sub); err != nil {
+		return nil, err
+	}
+	delete(m.undoTokens, token)
+	delete(m.deleted, t.SubscriptionID)
+	m.subscriptions[sub.ID] = sub
+	return sub, nil
+}
+
+func (m *MockStorage) PurgeSubscription(ctx context.Context, id uuid.UUID) (*models.PurgeRecord, error) {
+	_, live := m.subscriptions[id]
+	_, deleted := m.deleted[id]
+	if !live && !deleted {
+		return nil, storage.ErrNotFound
+	}
+	delete(m.subscriptions, id)
+	delete(m.deleted, id)
+	record := models.PurgeRecord{ID: uuid.New(), Subject: models.PurgeSubjectSubscription, SubjectID: id, Subscriptions: 1, PurgedAt: time.Now()}
+	m.purges = append(m.purges, record)
+	return &record, nil
+}
+
+func (m *MockStorage) PurgeUserData(ctx context.Context, userID uuid.UUID) (*models.PurgeRecord, error) {
+	record := models.PurgeRecord{ID: uuid.New(), Subject: models.PurgeSubjectUser, SubjectID: userID, PurgedAt: time.Now()}
+	for _, subs := range []map[uuid.UUID]*models.Subscription{m.subscriptions, m.deleted} {
+		for id, sub := range subs {
+			if sub.UserID == userID {
+				delete(subs, id)
+				record.Subscriptions++
+			}
+		}
+	}
+	for id, v := range m.views {
+		if v.UserID == userID {
+			delete(m.views, id)
+			record.Views++
+		}
+	}
+	m.purges = append(m.purges, record)
+	return &record, nil
+}
+
+func matchesBulkDelete(job *models.BulkDeleteJob, sub *models.Subscription) bool {
+	return (job.UserID == nil || sub.UserID == *job.UserID) &&
+		(job.ServiceName == nil || sub.ServiceName == *job.ServiceName) &&
+		(job.StartDate == nil || !sub.StartDate.Before(*job.StartDate)) &&
+		(job.EndDate == nil || (sub.EndDate != nil && !sub.EndDate.After(*job.EndDate)))
+}
+
+func (m *MockStorage) CreateBulkDeleteJob(ctx context.Context, job *models.BulkDeleteJob) error {
+	m.bulkMu.Lock()
+	defer m.bulkMu.Unlock()
+	for _, sub := range m.subscriptions {
+		if matchesBulkDelete(job, sub) {
+			job.Matched++
+		}
+	}
+	job.CreatedAt, job.UpdatedAt = time.Now(), time.Now()
+	stored := *job
+	m.bulkJobs[job.ID] = &stored
+	return nil
+}
+
+func (m *MockStorage) GetBulkDeleteJobByID(ctx context.Context, id uuid.UUID) (*models.BulkDeleteJob, error) {
+	m.bulkMu.Lock()
+	defer m.bulkMu.Unlock()
+	job, ok := m.bulkJobs[id]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	found := *job
+	return &found, nil
+}
+
+func (m *MockStorage) ListUnfinishedBulkDeleteJobs(ctx context.Context) ([]models.BulkDeleteJob, error) {
+	m.bulkMu.Lock()
+	defer m.bulkMu.Unlock()
+	var jobs []models.BulkDeleteJob
+	for _, job := range m.bulkJobs {
+		if job.Status == models.BulkDeleteStatusPending || job.Status == models.BulkDeleteStatusRunning {
+			jobs = append(jobs, *job)
+		}
+	}
+	return jobs, nil
+}
+
+func (m *MockStorage) ClaimBulkDeleteJob(ctx context.Context, id uuid.UUID, staleBefore time.Time) (bool, error) {
+	m.bulkMu.Lock()
+	defer m.bulkMu.Unlock()
+	job, ok := m.bulkJobs[id]
+	if !ok || !(job.Status == models.BulkDeleteStatusPending || job.Status == models.BulkDeleteStatusRunning && job.UpdatedAt.Before(staleBefore)) {
+		return false, nil
+	}
+	job.Status, job.UpdatedAt = models.BulkDeleteStatusRunning, time.Now()
+	return true, nil
+}
+
+func (m *MockStorage) DeleteBulkDeleteBatch(ctx context.Context, job *models.BulkDeleteJob, limit int, check func(batch []models.Subscription) error) ([]models.Subscription, error) {
+	m.bulkMu.Lock()
+	defer m.bulkMu.Unlock()
+	var batch []models.Subscription
 	for _, sub := range m.subscriptions {
+		if len(batch) < limit && matchesBulkDelete(job, sub) {
+			batch = append(batch, *sub)
+		}
+	}
+	if err := check(batch); err != nil {
+		return nil, err
+	}
+	for _, sub := range batch {
+		m.deleted[sub.ID] = m.subscriptions[sub.ID]
+		delete(m.subscriptions, sub.ID)
+	}
+	m.bulkJobs[job.ID].Deleted += int64(len(batch))
+	m.bulkJobs[job.ID].UpdatedAt = time.Now()
+	return batch, nil
+}
+
+func (m *MockStorage) FinishBulkDeleteJob(ctx context.Context, id uuid.UUID, status string, errMsg *string) error {
+	m.bulkMu.Lock()
+	defer m.bulkMu.Unlock()
+	now := time.Now()
+	job := m.bulkJobs[id]
+	job.Status, job.Error, job.UpdatedAt, job.FinishedAt = status, errMsg, now, &now
+	return nil
+}
+
+func (m *MockStorage) SyncUserSubscriptions(ctx context.Context, userID uuid.UUID, plan func(current []models.Subscription) (*models.SyncPlan, error)) (*models.SyncPlan, error) {
+	current, _, _ := m.ListSubscriptions(ctx, models.SubscriptionFilter{UserID: &userID})
+	p, err := plan(current)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range p.Create {
+		if err = m.CreateSubscription(ctx, sub); err != nil {
+			return nil, err
+		}
+	}
+	for _, sub := range p.Update {
+		if err = m.UpdateSubscriptionByID(ctx, sub); err != nil {
+			return nil, err
+		}
+	}
+	for _, sub := range p.Delete {
+		if err = m.DeleteSubscriptionByID(ctx, sub.ID); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (m *MockStorage) ListSubscriptions(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, int64, error) {
+	var result []models.Subscription
+	subs := m.subscriptions
+	if filter.Status != nil && *filter.Status == models.StatusDeleted {
+		subs = m.deleted
+	}
+	for _, sub := range subs {
 		if filter.UserID != nil && sub.UserID != *filter.UserID {
 			continue
 		}
-		if filter.ServiceName != nil && sub.ServiceName != *filter.ServiceName {
+		if !matchesServiceName(filter, sub.ServiceName) {
+			continue
+		}
+		if filter.CreatedAfter != nil && !sub.CreatedAt.After(*filter.CreatedAfter) {
+			continue
+		}
+		if filter.CreatedBefore != nil && !sub.CreatedAt.Before(*filter.CreatedBefore) {
+			continue
+		}
+		if filter.ActiveAt != nil && (sub.StartDate.After(*filter.ActiveAt) || (sub.EndDate != nil && sub.EndDate.Before(*filter.ActiveAt))) {
+			continue
+		}
+		if (filter.MinPrice != nil && sub.Price < *filter.MinPrice) || (filter.MaxPrice != nil && sub.Price > *filter.MaxPrice) {
+			continue
+		}
+		if filter.Status != nil && *filter.Status != models.StatusDeleted && sub.StatusIn(filter.StatusAt) != *filter.Status {
 			continue
 		}
 		result = append(result, *sub)
 	}
+	compare := func(a, b models.Subscription) int {
+		switch filter.SortBy {
+		case models.SortByPrice:
+			return a.Price - b.Price
+		case models.SortByStartDate:
+			return a.StartDate.Compare(b.StartDate)
+		case models.SortByServiceName:
+			return strings.Compare(a.ServiceName, b.ServiceName)
+		default:
+			return a.CreatedAt.Compare(b.CreatedAt)
+		}
+	}
 	sort.Slice(result, func(i, j int) bool {
-		if result[i].CreatedAt.Equal(result[j].CreatedAt) {
-			return result[i].ID.String() > result[j].ID.String()
+		c := compare(result[i], result[j])
+		if c == 0 {
+			c = strings.Compare(result[i].ID.String(), result[j].ID.String())
 		}
-		return result[i].CreatedAt.After(result[j].CreatedAt)
+		if filter.SortDesc {
+			return c > 0
+		}
+		return c < 0
 	})
+	total := int64(len(result))
 	if filter.Offset != nil && *filter.Offset < len(result) {
 		result = result[*filter.Offset:]
 	} else if filter.Offset != nil {
@@ -78,7 +389,7 @@ func (m *MockStorage) ListSubscriptions(ctx context.Context, filter models.Subsc
 	if filter.Limit != nil && *filter.Limit < len(result) {
 		result = result[:*filter.Limit]
 	}
-	return result, nil
+	return result, total, nil
 }
 
 func (m *MockStorage) TotalSubscriptionsCost(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time) (int64, error) {
@@ -87,7 +398,7 @@ func (m *MockStorage) TotalSubscriptionsCost(ctx context.Context, filter models.
 		if filter.UserID != nil && sub.UserID != *filter.UserID {
 			continue
 		}
-		if filter.ServiceName != nil && sub.ServiceName != *filter.ServiceName {
+		if !matchesServiceName(filter, sub.ServiceName) {
 			continue
 		}
 		total += calculateSubscriptionCost(*sub, startDate, endDate)
@@ -95,753 +406,3594 @@ func (m *MockStorage) TotalSubscriptionsCost(ctx context.Context, filter models.
 	return total, nil
 }
 
-func TestCreateSubscription(t *testing.T) {
-	mockStorage := NewMockStorage()
-	svc := NewSubscriptionService(mockStorage)
-	ctx := context.Background()
-
-	tests := []struct {
-		name    string
-		req     *apiModels.CreateSubscriptionRequest
-		wantErr bool
-	}{
-		{
-			name: "valid subscription",
-			req: &apiModels.CreateSubscriptionRequest{
-				ServiceName: "Test Service",
-				Price:       299,
-				UserID:      "550e8400-e29b-41d4-a716-446655440000",
-				StartDate:   "01-2024",
-			},
-			wantErr: false,
-		},
-		{
-			name: "valid subscription with end date",
-			req: &apiModels.CreateSubscriptionRequest{
-				ServiceName: "Test Service",
-				Price:       199,
-				UserID:      "550e8400-e29b-41d4-a716-446655440000",
-				StartDate:   "01-2024",
-				EndDate:     strPtr("12-2024"),
-			},
-			wantErr: false,
-		},
-		{
-			name: "empty service name",
-			req: &apiModels.CreateSubscriptionRequest{
-				ServiceName: "",
-				Price:       299,
-				UserID:      "550e8400-e29b-41d4-a716-446655440000",
-				StartDate:   "01-2024",
-			},
-			wantErr: true,
-		},
-		{
-			name: "zero price",
-			req: &apiModels.CreateSubscriptionRequest{
-				ServiceName: "Test",
-				Price:       0,
-				UserID:      "550e8400-e29b-41d4-a716-446655440000",
-				StartDate:   "01-2024",
-			},
-			wantErr: true,
-		},
-		{
-			name: "negative price",
-			req: &apiModels.CreateSubscriptionRequest{
-				ServiceName: "Test",
-				Price:       -100,
-				UserID:      "550e8400-e29b-41d4-a716-446655440000",
-				StartDate:   "01-2024",
-			},
-			wantErr: true,
-		},
-		{
-			name: "invalid user ID",
-			req: &apiModels.CreateSubscriptionRequest{
-				ServiceName: "Test",
-				Price:       299,
-				UserID:      "not-a-uuid",
-				StartDate:   "01-2024",
-			},
-			wantErr: true,
-		},
-		{
-			name: "invalid start date format",
-			req: &apiModels.CreateSubscriptionRequest{
-				ServiceName: "Test",
-				Price:       299,
-				UserID:      "550e8400-e29b-41d4-a716-446655440000",
-				StartDate:   "2024-01",
-			},
-			wantErr: true,
-		},
-		{
-			name: "end date before start date",
-			req: &apiModels.CreateSubscriptionRequest{
-				ServiceName: "Test",
-				Price:       299,
-				UserID:      "550e8400-e29b-41d4-a716-446655440000",
-				StartDate:   "06-2024",
-				EndDate:     strPtr("01-2024"),
-			},
-			wantErr: true,
-		},
+func (m *MockStorage) TotalSubscriptionsCostByUser(ctx context.Context, filter models.SubscriptionFilter, userIDs []uuid.UUID, startDate, endDate time.Time) (map[uuid.UUID]int64, error) {
+	totals := make(map[uuid.UUID]int64)
+	for _, userID := range userIDs {
+		filter.UserID = &userID
+		total, err := m.TotalSubscriptionsCost(ctx, filter, startDate, endDate)
+		if err != nil {
+			return nil, err
+		}
+		if total != 0 {
+			totals[userID] = total
+		}
 	}
+	return totals, nil
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			sub, err := svc.CreateSubscription(ctx, tt.req)
-
-			if tt.wantErr {
-				if err == nil {
-					t.Errorf("CreateSubscription() expected error, got nil")
-				}
-				return
-			}
-
-			if err != nil {
-				t.Errorf("CreateSubscription() unexpected error: %v", err)
-				return
-			}
+func (m *MockStorage) UserSummary(ctx context.Context, userID uuid.UUID, month time.Time) (*models.UserSummary, error) {
+	summary := &models.UserSummary{}
+	var topPrice int
+	for _, sub := range m.subscriptions {
+		if sub.UserID != userID {
+			continue
+		}
+		if summary.EarliestStart == nil || sub.StartDate.Before(*summary.EarliestStart) {
+			summary.EarliestStart = &sub.StartDate
+		}
+		if summary.LatestStart == nil || sub.StartDate.After(*summary.LatestStart) {
+			summary.LatestStart = &sub.StartDate
+		}
+		if sub.StartDate.After(month) || (sub.EndDate != nil && sub.EndDate.Before(month)) {
+			continue
+		}
+		summary.ActiveCount++
+		if sub.Type == models.TypeOneTime {
+			continue
+		}
+		summary.MonthlyCost += int64(sub.Price)
+		if summary.MostExpensiveService == nil || sub.Price > topPrice || (sub.Price == topPrice && sub.ServiceName < *summary.MostExpensiveService) {
+			summary.MostExpensiveService, topPrice = &sub.ServiceName, sub.Price
+		}
+	}
+	return summary, nil
+}
 
-			if sub.ServiceName != tt.req.ServiceName {
-				t.Errorf("ServiceName = %q, want %q", sub.ServiceName, tt.req.ServiceName)
-			}
-			if sub.Price != tt.req.Price {
-				t.Errorf("Price = %d, want %d", sub.Price, tt.req.Price)
-			}
-		})
+func (m *MockStorage) ListSubscriptionsInPeriod(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time) ([]models.Subscription, error) {
+	var result []models.Subscription
+	for _, sub := range m.subscriptions {
+		if filter.UserID != nil && sub.UserID != *filter.UserID {
+			continue
+		}
+		if !matchesServiceName(filter, sub.ServiceName) {
+			continue
+		}
+		if sub.StartDate.After(endDate) || (sub.EndDate != nil && sub.EndDate.Before(startDate)) {
+			continue
+		}
+		result = append(result, *sub)
 	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ServiceName < result[j].ServiceName })
+	return result, nil
 }
 
-func TestGetSubscriptionByID(t *testing.T) {
-	mockStorage := NewMockStorage()
-	svc := NewSubscriptionService(mockStorage)
-	ctx := context.Background()
+func (m *MockStorage) ListExpiringSubscriptions(ctx context.Context, from, to time.Time, renewed *bool) ([]models.Subscription, error) {
+	var result []models.Subscription
+	for _, sub := range m.subscriptions {
+		if sub.Type == models.TypeOneTime || sub.EndDate == nil || sub.EndDate.Before(from) || sub.EndDate.After(to) {
+			continue
+		}
+		if renewed != nil && m.renewed(sub) != *renewed {
+			continue
+		}
+		result = append(result, *sub)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].UserID != result[j].UserID {
+			return result[i].UserID.String() < result[j].UserID.String()
+		}
+		return result[i].EndDate.Before(*result[j].EndDate)
+	})
+	return result, nil
+}
 
-	existingID := uuid.New()
-	mockStorage.subscriptions[existingID] = &models.Subscription{
-		ID:          existingID,
-		ServiceName: "Test",
-		Price:       100,
-		UserID:      uuid.New(),
-		StartDate:   time.Now(),
+// renewed mirrors the storage condition of a subscription being continued by another one
+func (m *MockStorage) renewed(sub *models.Subscription) bool {
+	for _, next := range m.subscriptions {
+		if next.ID != sub.ID && next.UserID == sub.UserID && strings.EqualFold(next.ServiceName, sub.ServiceName) &&
+			next.StartDate.After(sub.StartDate) && !next.StartDate.After(sub.EndDate.AddDate(0, 1, 0)) &&
+			(next.EndDate == nil || next.EndDate.After(*sub.EndDate)) {
+			return true
+		}
 	}
+	return false
+}
 
-	tests := []struct {
-		name    string
-		id      string
+// matchesServiceName mirrors storage service name filters
+func matchesServiceName(filter models.SubscriptionFilter, name string) bool {
+	if filter.ServiceName != nil {
+		if filter.ServiceNameFold && !strings.EqualFold(name, *filter.ServiceName) || !filter.ServiceNameFold && name != *filter.ServiceName {
+			return false
+		}
+	}
+	if filter.ServiceNameLike != nil {
+		pattern := strings.ToLower(*filter.ServiceNameLike)
+		if !strings.Contains(pattern, "*") {
+			pattern = "*" + pattern + "*"
+		}
+		if ok, _ := path.Match(pattern, strings.ToLower(name)); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *MockStorage) SuggestServiceNames(ctx context.Context, prefix string, userID *uuid.UUID, limit int) ([]string, error) {
+	m.suggestCalls++
+	seen := make(map[string]bool)
+	var result []string
+	for _, sub := range m.subscriptions {
+		if userID != nil && sub.UserID != *userID {
+			continue
+		}
+		if !strings.HasPrefix(strings.ToLower(sub.ServiceName), strings.ToLower(prefix)) || seen[sub.ServiceName] {
+			continue
+		}
+		seen[sub.ServiceName] = true
+		result = append(result, sub.ServiceName)
+	}
+	sort.Strings(result)
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (m *MockStorage) CreateView(ctx context.Context, v *models.SavedView) error {
+	for _, existing := range m.views {
+		if existing.UserID == v.UserID && existing.Name == v.Name {
+			return storage.ErrAlreadyExists
+		}
+	}
+	m.views[v.ID] = v
+	return nil
+}
+
+func (m *MockStorage) GetViewByID(ctx context.Context, id uuid.UUID) (*models.SavedView, error) {
+	if v, ok := m.views[id]; ok {
+		return v, nil
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (m *MockStorage) ListViews(ctx context.Context, userID uuid.UUID) ([]models.SavedView, error) {
+	var result []models.SavedView
+	for _, v := range m.views {
+		if v.UserID == userID {
+			result = append(result, *v)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (m *MockStorage) CreatePlan(ctx context.Context, p *models.Plan) error {
+	for _, existing := range m.plans {
+		if existing.ServiceName == p.ServiceName && existing.Name == p.Name {
+			return storage.ErrAlreadyExists
+		}
+	}
+	m.plans[p.ID] = p
+	return nil
+}
+
+func (m *MockStorage) GetPlanByID(ctx context.Context, id uuid.UUID) (*models.Plan, error) {
+	if p, ok := m.plans[id]; ok {
+		return p, nil
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (m *MockStorage) ListPlans(ctx context.Context) ([]models.Plan, error) {
+	var result []models.Plan
+	for _, p := range m.plans {
+		result = append(result, *p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ServiceName+result[i].Name < result[j].ServiceName+result[j].Name
+	})
+	return result, nil
+}
+
+func (m *MockStorage) UpdatePlanPrice(ctx context.Context, id uuid.UUID, price int, month time.Time, check func(before []models.Subscription) error) (*models.Plan, []models.Subscription, []models.Subscription, error) {
+	p, ok := m.plans[id]
+	if !ok {
+		return nil, nil, nil, storage.ErrNotFound
+	}
+	var before []models.Subscription
+	for _, sub := range m.subscriptions {
+		if sub.PlanID != nil && *sub.PlanID == id && sub.FollowPlan && sub.Price != price && (sub.EndDate == nil || !sub.EndDate.Before(month)) {
+			before = append(before, *sub)
+		}
+	}
+	sort.Slice(before, func(i, j int) bool { return before[i].ID.String() < before[j].ID.String() })
+	if err := check(before); err != nil {
+		return nil, nil, nil, err
+	}
+	p.Price = price
+	after := make([]models.Subscription, len(before))
+	for i, sub := range before {
+		stored := m.subscriptions[sub.ID]
+		stored.Price = price
+		stored.Version++
+		after[i] = *stored
+	}
+	return p, before, after, nil
+}
+
+func (m *MockStorage) CreateOrgUnit(ctx context.Context, u *models.OrgUnit) error {
+	for _, existing := range m.orgUnits {
+		if reflect.DeepEqual(existing.ParentID, u.ParentID) && existing.Name == u.Name {
+			return storage.ErrAlreadyExists
+		}
+	}
+	m.orgUnits[u.ID] = u
+	return nil
+}
+
+func (m *MockStorage) GetOrgUnitByID(ctx context.Context, id uuid.UUID) (*models.OrgUnit, error) {
+	if u, ok := m.orgUnits[id]; ok {
+		return u, nil
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (m *MockStorage) ListOrgUnits(ctx context.Context) ([]models.OrgUnit, error) {
+	var result []models.OrgUnit
+	for _, u := range m.orgUnits {
+		result = append(result, *u)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (m *MockStorage) TotalSubscriptionsCostByOrgUnit(ctx context.Context, unitIDs []uuid.UUID, startDate, endDate time.Time) (map[uuid.UUID]int64, error) {
+	totals := make(map[uuid.UUID]int64)
+	for _, sub := range m.subscriptions {
+		if sub.OrgUnitID != nil && slices.Contains(unitIDs, *sub.OrgUnitID) {
+			if cost := calculateSubscriptionCost(*sub, startDate, endDate); cost != 0 {
+				totals[*sub.OrgUnitID] += cost
+			}
+		}
+	}
+	return totals, nil
+}
+
+func (m *MockStorage) CountSubscriptionsByOrgUnit(ctx context.Context, unitIDs []uuid.UUID, month time.Time) (map[uuid.UUID]models.SubscriptionCounts, error) {
+	counts := make(map[uuid.UUID]models.SubscriptionCounts)
+	for _, sub := range m.subscriptions {
+		if sub.OrgUnitID != nil && slices.Contains(unitIDs, *sub.OrgUnitID) {
+			c := counts[*sub.OrgUnitID]
+			c.Total++
+			if !sub.StartDate.After(month) && (sub.EndDate == nil || !sub.EndDate.Before(month)) {
+				c.Active++
+			}
+			counts[*sub.OrgUnitID] = c
+		}
+	}
+	return counts, nil
+}
+
+func (m *MockStorage) CreateCredit(ctx context.Context, c *models.SubscriptionCredit) error {
+	sub, ok := m.subscriptions[c.SubscriptionID]
+	if !ok {
+		return storage.ErrNotFound
+	}
+	sub.Credits = append(sub.Credits, *c)
+	return nil
+}
+
+func (m *MockStorage) ListCredits(ctx context.Context, subscriptionID uuid.UUID) ([]models.SubscriptionCredit, error) {
+	if sub, ok := m.subscriptions[subscriptionID]; ok {
+		return sub.Credits, nil
+	}
+	return nil, nil
+}
+
+func (m *MockStorage) CreatePausedPeriod(ctx context.Context, p *models.PausedPeriod) error {
+	sub, ok := m.subscriptions[p.SubscriptionID]
+	if !ok {
+		return storage.ErrNotFound
+	}
+	for _, pause := range sub.Pauses {
+		if pause.EndDate == nil {
+			return storage.ErrAlreadyExists
+		}
+	}
+	sub.Pauses = append(sub.Pauses, *p)
+	return nil
+}
+
+func (m *MockStorage) ResumePausedPeriod(ctx context.Context, subscriptionID uuid.UUID, endDate time.Time) (*models.PausedPeriod, error) {
+	sub, ok := m.subscriptions[subscriptionID]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	for i, pause := range sub.Pauses {
+		if pause.EndDate == nil {
+			pause.EndDate = &endDate
+			if endDate.Before(pause.StartDate) {
+				sub.Pauses = slices.Delete(sub.Pauses, i, i+1)
+			} else {
+				sub.Pauses[i] = pause
+			}
+			return &pause, nil
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (m *MockStorage) ListPausedPeriods(ctx context.Context, subscriptionID uuid.UUID) ([]models.PausedPeriod, error) {
+	if sub, ok := m.subscriptions[subscriptionID]; ok {
+		return sub.Pauses, nil
+	}
+	return nil, nil
+}
+
+func (m *MockStorage) CreateServiceRename(ctx context.Context, r *models.ServiceRename) error {
+	stored := *r
+	m.renames[r.ID] = &stored
+	return nil
+}
+
+func (m *MockStorage) RenameServiceBatch(ctx context.Context, r *models.ServiceRename, unitIDs []uuid.UUID, limit int, check func(batch []models.Subscription) error) ([]models.Subscription, error) {
+	var batch []models.Subscription
+	for _, sub := range m.subscriptions {
+		if len(batch) == limit || !strings.EqualFold(sub.ServiceName, r.FromName) || sub.ServiceName == r.ToName {
+			continue
+		}
+		if unitIDs != nil && (sub.OrgUnitID == nil || !slices.Contains(unitIDs, *sub.OrgUnitID)) {
+			continue
+		}
+		batch = append(batch, *sub)
+	}
+	if err := check(batch); err != nil {
+		return nil, err
+	}
+	for _, sub := range batch {
+		m.subscriptions[sub.ID].ServiceName = r.ToName
+		m.subscriptions[sub.ID].Version++
+	}
+	m.renames[r.ID].Renamed += int64(len(batch))
+	return batch, nil
+}
+
+func (m *MockStorage) FinishServiceRename(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	m.renames[id].FinishedAt = &now
+	return nil
+}
+
+func (m *MockStorage) CheckIntegrity(ctx context.Context) (bool, error) {
+	if m.checkErr != nil {
+		return false, m.checkErr
+	}
+	m.checks++
+	m.findings = nil
+	for _, sub := range m.subscriptions {
+		if sub.EndDate != nil && sub.EndDate.Before(sub.StartDate) {
+			m.findings = append(m.findings, models.IntegrityFinding{Check: "end_before_start", SubscriptionID: sub.ID})
+		}
+	}
+	return true, nil
+}
+
+func (m *MockStorage) ListIntegrityFindings(ctx context.Context) ([]models.IntegrityFinding, error) {
+	return m.findings, nil
+}
+
+func TestCreateSubscription(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		req     *apiModels.CreateSubscriptionRequest
+		wantErr bool
+	}{
+		{name: "valid subscription", req: factory.Subscription().Request()},
+		{name: "valid subscription with end date", req: factory.Subscription().WithPrice(199).Ending("12-2024").Request()},
+		{name: "empty service name", req: factory.Subscription().WithService("").Request(), wantErr: true},
+		{name: "free subscription", req: factory.Subscription().WithPrice(0).Request()},
+		{name: "negative price", req: factory.Subscription().WithPrice(-100).Request(), wantErr: true},
+		{
+			name: "invalid user ID",
+			req: &apiModels.CreateSubscriptionRequest{
+				ServiceName: "Test",
+				Price:       intPtr(299),
+				UserID:      "not-a-uuid",
+				StartDate:   "01-2024",
+			},
+			wantErr: true,
+		},
+		{name: "invalid start date format", req: factory.Subscription().Starting("2024-01").Request(), wantErr: true},
+		{name: "end date before start date", req: factory.Subscription().Starting("06-2024").Ending("01-2024").Request(), wantErr: true},
+		{name: "one-time purchase", req: factory.Subscription().OneTime().Request()},
+		{name: "one-time purchase ending later", req: factory.Subscription().OneTime().Ending("12-2024").Request(), wantErr: true},
+		{name: "unknown type", req: &apiModels.CreateSubscriptionRequest{ServiceName: "Test", UserID: uuid.NewString(), StartDate: "01-2024", Type: "weekly"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, err := svc.CreateSubscription(ctx, tt.req)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("CreateSubscription() expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("CreateSubscription() unexpected error: %v", err)
+				return
+			}
+
+			if sub.ServiceName != tt.req.ServiceName {
+				t.Errorf("ServiceName = %q, want %q", sub.ServiceName, tt.req.ServiceName)
+			}
+			if tt.req.Price != nil && sub.Price != *tt.req.Price {
+				t.Errorf("Price = %d, want %d", sub.Price, *tt.req.Price)
+			}
+		})
+	}
+}
+
+func TestDetectDuplicates(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	viper.Set(config.LimitsDetectDuplicates, true)
+	t.Cleanup(func() { viper.Set(config.LimitsDetectDuplicates, false) })
+
+	userID := uuid.New()
+	existing, err := svc.CreateSubscription(ctx, factory.Subscription().WithUser(userID).WithService("Netflix").Starting("03-2024").Ending("06-2024").Request())
+	if err != nil {
+		t.Fatalf("CreateSubscription() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		req          *apiModels.CreateSubscriptionRequest
+		wantConflict bool
+	}{
+		{name: "same period", req: factory.Subscription().WithUser(userID).WithService("Netflix").Starting("03-2024").Ending("06-2024").Request(), wantConflict: true},
+		{name: "overlapping start", req: factory.Subscription().WithUser(userID).WithService("Netflix").Starting("06-2024").Request(), wantConflict: true},
+		{name: "open period covering it", req: factory.Subscription().WithUser(userID).WithService("Netflix").Starting("01-2024").Request(), wantConflict: true},
+		{name: "different case", req: factory.Subscription().WithUser(userID).WithService("NETFLIX").Starting("04-2024").Ending("04-2024").Request(), wantConflict: true},
+		{name: "ends before", req: factory.Subscription().WithUser(userID).WithService("Netflix").Starting("01-2024").Ending("02-2024").Request()},
+		{name: "starts after", req: factory.Subscription().WithUser(userID).WithService("Netflix").Starting("07-2024").Ending("08-2024").Request()},
+		{name: "other service", req: factory.Subscription().WithUser(userID).WithService("Spotify").Starting("03-2024").Request()},
+		{name: "other user", req: factory.Subscription().WithService("Netflix").Starting("03-2024").Request()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, err := svc.CreateSubscription(ctx, tt.req)
+			if !tt.wantConflict {
+				if err != nil {
+					t.Errorf("CreateSubscription() unexpected error: %v", err)
+				}
+				if sub != nil {
+					delete(mockStorage.subscriptions, sub.ID) // Keep the cases independent
+				}
+				return
+			}
+			if !errors.Is(err, ErrConflict) {
+				t.Fatalf("CreateSubscription() error = %v, want ErrConflict", err)
+			}
+			if sub == nil || sub.ID != existing.ID {
+				t.Errorf("CreateSubscription() returned %v, want the overlapping subscription %s", sub, existing.ID)
+			}
+		})
+	}
+
+	viper.Set(config.LimitsDetectDuplicates, false)
+	if _, err = svc.CreateSubscription(ctx, factory.Subscription().WithUser(userID).WithService("Netflix").Starting("03-2024").Request()); err != nil {
+		t.Errorf("CreateSubscription() with detection disabled unexpected error: %v", err)
+	}
+}
+
+func TestDetectDuplicatesInBulkWrites(t *testing.T) {
+	viper.Set(config.LimitsDetectDuplicates, true)
+	viper.Set(config.UndoWindow, "10m")
+	t.Cleanup(func() {
+		viper.Set(config.LimitsDetectDuplicates, false)
+		viper.Set(config.UndoWindow, 0)
+	})
+	ctx := context.Background()
+
+	setup := func() (*MockStorage, SubscriptionService, uuid.UUID, *models.Subscription) {
+		mockStorage := NewMockStorage()
+		userID := uuid.New()
+		existing := factory.Subscription().WithUser(userID).WithService("Netflix").Starting("03-2024").Ending("06-2024").Build()
+		mockStorage.subscriptions[existing.ID] = existing
+		return mockStorage, NewSubscriptionService(mockStorage), userID, existing
+	}
+
+	t.Run("batch rejects overlapping items only", func(t *testing.T) {
+		_, svc, userID, _ := setup()
+		resp, err := svc.CreateSubscriptionsBatch(ctx, []apiModels.CreateSubscriptionRequest{
+			*factory.Subscription().WithUser(userID).WithService("netflix").Starting("05-2024").Request(), // Stored one
+			*factory.Subscription().WithUser(userID).WithService("Spotify").Starting("01-2024").Request(),
+			*factory.Subscription().WithUser(userID).WithService("Spotify").Starting("02-2024").Request(), // Earlier item
+			*factory.Subscription().WithUser(userID).WithService("Netflix").Starting("07-2024").Request(),
+		})
+		if err != nil {
+			t.Fatalf("CreateSubscriptionsBatch() unexpected error: %v", err)
+		}
+		if resp.Created != 2 || resp.Failed != 2 {
+			t.Fatalf("CreateSubscriptionsBatch() created %d, failed %d, want 2 and 2", resp.Created, resp.Failed)
+		}
+		for _, i := range []int{0, 2} {
+			if resp.Results[i].Code != apiModels.CodeSubscriptionExists {
+				t.Errorf("result %d code = %q, want %q", i, resp.Results[i].Code, apiModels.CodeSubscriptionExists)
+			}
+		}
+	})
+
+	t.Run("if absent rejects overlaps but not retries", func(t *testing.T) {
+		_, svc, userID, existing := setup()
+		existing.ExternalID = strPtr("crm-1")
+
+		retry, created, err := svc.CreateSubscriptionIfAbsent(ctx, factory.Subscription().WithUser(userID).WithService("Netflix").WithExternalID("crm-1").Starting("03-2024").Request())
+		if err != nil || created || retry.ID != existing.ID {
+			t.Errorf("CreateSubscriptionIfAbsent() retry = %v, %t, %v; want the existing subscription", retry, created, err)
+		}
+		dup, created, err := svc.CreateSubscriptionIfAbsent(ctx, factory.Subscription().WithUser(userID).WithService("Netflix").WithExternalID("crm-2").Starting("04-2024").Request())
+		if !errors.Is(err, ErrConflict) || created || dup == nil || dup.ID != existing.ID {
+			t.Errorf("CreateSubscriptionIfAbsent() overlapping = %v, %t, %v; want ErrConflict with the existing subscription", dup, created, err)
+		}
+	})
+
+	t.Run("import fails as a whole", func(t *testing.T) {
+		mockStorage, svc, userID, _ := setup()
+		_, err := svc.ImportSubscriptions(ctx, &apiModels.ImportSubscriptionsRequest{Subscriptions: []apiModels.CreateSubscriptionRequest{
+			*factory.Subscription().WithUser(userID).WithService("Spotify").Starting("01-2024").Request(),
+			*factory.Subscription().WithUser(userID).WithService("Netflix").Starting("01-2024").Ending("03-2024").Request(),
+		}}, nil)
+		if !errors.Is(err, ErrConflict) || !strings.Contains(err.Error(), "subscriptions[1]") {
+			t.Errorf("ImportSubscriptions() error = %v, want ErrConflict for subscriptions[1]", err)
+		}
+		if len(mockStorage.subscriptions) != 1 {
+			t.Errorf("ImportSubscriptions() stored %d subscriptions, want none", len(mockStorage.subscriptions)-1)
+		}
+	})
+
+	t.Run("undo keeps the token while overlapping", func(t *testing.T) {
+		mockStorage, svc, userID, existing := setup()
+		token, err := svc.DeleteSubscriptionByID(ctx, apiModels.ItemByIDRequest{ID: existing.ID.String()})
+		if err != nil || token == nil {
+			t.Fatalf("DeleteSubscriptionByID() = %v, %v, want undo token", token, err)
+		}
+		replacement := factory.Subscription().WithUser(userID).WithService("Netflix").Starting("06-2024").Build()
+		mockStorage.subscriptions[replacement.ID] = replacement
+
+		undo := apiModels.UndoRequest{Token: token.Token.String()}
+		if _, err = svc.UndoDeletion(ctx, undo); !errors.Is(err, ErrConflict) {
+			t.Fatalf("UndoDeletion() error = %v, want %v", err, ErrConflict)
+		}
+		delete(mockStorage.subscriptions, replacement.ID)
+		if _, err = svc.UndoDeletion(ctx, undo); err != nil {
+			t.Errorf("UndoDeletion() after the overlap is gone unexpected error: %v", err)
+		}
+	})
+
+	t.Run("sync checks the set after it", func(t *testing.T) {
+		mockStorage, svc, userID, existing := setup()
+		existing.ExternalID = strPtr("crm-1")
+		user := apiModels.ItemByIDRequest{ID: userID.String()}
+
+		overlapping := &apiModels.SyncSubscriptionsRequest{Subscriptions: []apiModels.SyncSubscriptionItem{
+			{ExternalID: "crm-1", ServiceName: "Netflix", Price: factory.DefaultPrice, StartDate: "03-2024", EndDate: strPtr("06-2024")},
+			{ExternalID: "crm-2", ServiceName: "Netflix", Price: factory.DefaultPrice, StartDate: "06-2024"},
+		}}
+		for _, dryRun := range []bool{true, false} {
+			if _, err := svc.SyncSubscriptions(ctx, user, overlapping, dryRun); !errors.Is(err, ErrConflict) {
+				t.Errorf("SyncSubscriptions(dryRun=%t) error = %v, want %v", dryRun, err, ErrConflict)
+			}
+		}
+
+		replacing := &apiModels.SyncSubscriptionsRequest{Subscriptions: []apiModels.SyncSubscriptionItem{ // crm-1 is deleted by the same sync
+			{ExternalID: "crm-2", ServiceName: "Netflix", Price: factory.DefaultPrice, StartDate: "03-2024"},
+		}}
+		if _, err := svc.SyncSubscriptions(ctx, user, replacing, false); err != nil {
+			t.Errorf("SyncSubscriptions() replacing the overlapping one unexpected error: %v", err)
+		}
+		if len(mockStorage.subscriptions) != 1 {
+			t.Errorf("SyncSubscriptions() left %d subscriptions, want 1", len(mockStorage.subscriptions))
+		}
+	})
+}
+
+func TestContextLogger(t *testing.T) {
+	svc := NewSubscriptionService(NewMockStorage())
+	var buf bytes.Buffer
+	ctx := logger.WithContext(context.Background(), slog.New(slog.NewTextHandler(&buf, nil)).With("request_id", "req-1"))
+
+	id := uuid.New()
+	if _, err := svc.GetSubscriptionByID(ctx, apiModels.ItemByIDRequest{ID: id.String()}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetSubscriptionByID() error = %v, want %v", err, ErrNotFound)
+	}
+	if out := buf.String(); !strings.Contains(out, "level=WARN") || !strings.Contains(out, "request_id=req-1") || !strings.Contains(out, "requested subscription not found") {
+		t.Errorf("GetSubscriptionByID() logged %q, want the not found warning with request_id", out)
+	}
+}
+
+func TestUpdateIfMatch(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	viper.Set(config.ApiRequireIfMatch, true)
+	t.Cleanup(func() { viper.Set(config.ApiRequireIfMatch, false) })
+
+	sub, err := svc.CreateSubscription(ctx, factory.Subscription().Request())
+	if err != nil {
+		t.Fatalf("CreateSubscription() unexpected error: %v", err)
+	}
+	id := apiModels.ItemByIDRequest{ID: sub.ID.String()}
+	etag := sub.ETag()
+
+	if _, err = svc.UpdateSubscriptionByID(ctx, id, &apiModels.UpdateSubscriptionRequest{Price: intPtr(200)}); !errors.Is(err, ErrPreconditionNeeded) {
+		t.Errorf("UpdateSubscriptionByID() without If-Match error = %v, want %v", err, ErrPreconditionNeeded)
+	}
+	updated, err := svc.UpdateSubscriptionByID(ctx, id, &apiModels.UpdateSubscriptionRequest{Price: intPtr(200), IfMatch: `"other", ` + etag})
+	if err != nil {
+		t.Fatalf("UpdateSubscriptionByID() with current ETag unexpected error: %v", err)
+	}
+	if updated.ETag() == etag {
+		t.Errorf("UpdateSubscriptionByID() kept ETag %s", etag)
+	}
+	if _, err = svc.UpdateSubscriptionByID(ctx, id, &apiModels.UpdateSubscriptionRequest{Price: intPtr(300), IfMatch: etag}); !errors.Is(err, ErrPreconditionFail) {
+		t.Errorf("UpdateSubscriptionByID() with stale ETag error = %v, want %v", err, ErrPreconditionFail)
+	}
+	if _, err = svc.UpdateSubscriptionByID(ctx, id, &apiModels.UpdateSubscriptionRequest{Price: intPtr(300), IfMatch: "*"}); err != nil {
+		t.Errorf("UpdateSubscriptionByID() with If-Match * unexpected error: %v", err)
+	}
+
+	viper.Set(config.ApiRequireIfMatch, false)
+	if _, err = svc.UpdateSubscriptionByID(ctx, id, &apiModels.UpdateSubscriptionRequest{Price: intPtr(400)}); err != nil {
+		t.Errorf("UpdateSubscriptionByID() without If-Match when not required unexpected error: %v", err)
+	}
+}
+
+func TestCancelSubscription(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	sub, err := svc.CreateSubscription(ctx, factory.Subscription().Starting(month.AddDate(0, -6, 0).Format("01-2006")).Request())
+	if err != nil {
+		t.Fatalf("CreateSubscription() unexpected error: %v", err)
+	}
+	id := apiModels.ItemByIDRequest{ID: sub.ID.String()}
+
+	tests := []struct {
+		name    string
+		req     apiModels.CancelSubscriptionRequest
+		wantErr error
+		wantEnd time.Time
+	}{
+		{name: "after end of open-ended", req: apiModels.CancelSubscriptionRequest{EndDate: strPtr(month.AddDate(0, 3, 0).Format("01-2006"))}, wantEnd: month.AddDate(0, 3, 0)},
+		{name: "later than it ends", req: apiModels.CancelSubscriptionRequest{EndDate: strPtr(month.AddDate(0, 4, 0).Format("01-2006"))}, wantErr: ErrValidationError},
+		{name: "before start", req: apiModels.CancelSubscriptionRequest{EndDate: strPtr(month.AddDate(0, -7, 0).Format("01-2006"))}, wantErr: ErrValidationError},
+		{name: "invalid end date", req: apiModels.CancelSubscriptionRequest{EndDate: strPtr("13-2024")}, wantErr: ErrValidationError},
+		{name: "stale If-Match", req: apiModels.CancelSubscriptionRequest{IfMatch: sub.ETag()}, wantErr: ErrPreconditionFail},
+		{name: "current month", req: apiModels.CancelSubscriptionRequest{}, wantEnd: month},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cancelled, err := svc.CancelSubscription(ctx, id, &tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CancelSubscription() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if cancelled.EndDate == nil || !cancelled.EndDate.Equal(tt.wantEnd) {
+				t.Errorf("CancelSubscription() end date = %v, want %v", cancelled.EndDate, tt.wantEnd)
+			}
+			if cancelled.CancelledAt == nil {
+				t.Error("CancelSubscription() didn't set cancelled_at")
+			}
+		})
+	}
+
+	if _, err = svc.GetSubscriptionByID(ctx, id); err != nil {
+		t.Errorf("GetSubscriptionByID() after cancellation unexpected error: %v, cancelled subscription is not deleted", err)
+	}
+
+	oneTime, err := svc.CreateSubscription(ctx, factory.Subscription().Starting(month.Format("01-2006")).OneTime().Request())
+	if err != nil {
+		t.Fatalf("CreateSubscription() unexpected error: %v", err)
+	}
+	if _, err = svc.CancelSubscription(ctx, apiModels.ItemByIDRequest{ID: oneTime.ID.String()}, &apiModels.CancelSubscriptionRequest{}); !errors.Is(err, ErrValidationError) {
+		t.Errorf("CancelSubscription() of one-time purchase error = %v, want %v", err, ErrValidationError)
+	}
+	if _, err = svc.CancelSubscription(ctx, apiModels.ItemByIDRequest{ID: uuid.New().String()}, &apiModels.CancelSubscriptionRequest{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CancelSubscription() of missing subscription error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestRenewedEnd(t *testing.T) {
+	month := func(s string) time.Time {
+		t, _ := dates.String2Date(s)
+		return t
+	}
+	now := time.Date(2025, 5, 17, 12, 0, 0, 0, time.UTC)
+	end := month("06-2025")
+
+	tests := []struct {
+		name   string
+		sub    models.Subscription
+		months int
+		want   string
+	}{
+		{name: "fixed term extended", sub: models.Subscription{StartDate: month("01-2025"), EndDate: &end}, months: 3, want: "09-2025"},
+		{name: "across year", sub: models.Subscription{StartDate: month("01-2025"), EndDate: &end}, months: 12, want: "06-2026"},
+		{name: "open-ended gets term after current month", sub: models.Subscription{StartDate: month("01-2025")}, months: 6, want: "11-2025"},
+		{name: "open-ended not started yet", sub: models.Subscription{StartDate: month("09-2025")}, months: 12, want: "08-2026"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renewedEnd(&tt.sub, tt.months, now).Format(dates.Layout); got != tt.want {
+				t.Errorf("renewedEnd() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenewSubscription(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	viper.Set(config.LimitsSubscriptionMaxYears, 2)
+	t.Cleanup(func() { viper.Set(config.LimitsSubscriptionMaxYears, 0) })
+
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	sub, err := svc.CreateSubscription(ctx, factory.Subscription().Starting(month.Format(dates.Layout)).Ending(month.Format(dates.Layout)).Request())
+	if err != nil {
+		t.Fatalf("CreateSubscription() unexpected error: %v", err)
+	}
+	id, etag := apiModels.ItemByIDRequest{ID: sub.ID.String()}, sub.ETag()
+	if _, err = svc.CancelSubscription(ctx, id, &apiModels.CancelSubscriptionRequest{}); err != nil {
+		t.Fatalf("CancelSubscription() unexpected error: %v", err)
+	}
+
+	renewed, err := svc.RenewSubscription(ctx, id, &apiModels.RenewSubscriptionRequest{Months: 3})
+	if err != nil {
+		t.Fatalf("RenewSubscription() unexpected error: %v", err)
+	}
+	if want := month.AddDate(0, 3, 0); renewed.EndDate == nil || !renewed.EndDate.Equal(want) {
+		t.Errorf("RenewSubscription() end date = %v, want %v", renewed.EndDate, want)
+	}
+	if renewed.CancelledAt != nil {
+		t.Errorf("RenewSubscription() kept cancelled_at %v", renewed.CancelledAt)
+	}
+
+	for _, months := range []int{0, -1, 1201} {
+		if _, err = svc.RenewSubscription(ctx, id, &apiModels.RenewSubscriptionRequest{Months: months}); !errors.Is(err, ErrValidationError) {
+			t.Errorf("RenewSubscription(%d months) error = %v, want %v", months, err, ErrValidationError)
+		}
+	}
+	if _, err = svc.RenewSubscription(ctx, id, &apiModels.RenewSubscriptionRequest{Months: 24}); !errors.Is(err, ErrValidationError) {
+		t.Errorf("RenewSubscription() beyond subscription_max_years error = %v, want %v", err, ErrValidationError)
+	}
+	if _, err = svc.RenewSubscription(ctx, id, &apiModels.RenewSubscriptionRequest{Months: 1, IfMatch: etag}); !errors.Is(err, ErrPreconditionFail) {
+		t.Errorf("RenewSubscription() with stale If-Match error = %v, want %v", err, ErrPreconditionFail)
+	}
+	if _, err = svc.RenewSubscription(ctx, apiModels.ItemByIDRequest{ID: uuid.New().String()}, &apiModels.RenewSubscriptionRequest{Months: 1}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RenewSubscription() of missing subscription error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestOneTimeSubscription(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	sub, err := svc.CreateSubscription(ctx, factory.Subscription().WithUser(userID).WithService("Domain").WithPrice(1500).Starting("03-2024").OneTime().Request())
+	if err != nil {
+		t.Fatalf("CreateSubscription() unexpected error: %v", err)
+	}
+	if sub.Type != models.TypeOneTime || sub.EndDate == nil || !sub.EndDate.Equal(sub.StartDate) {
+		t.Fatalf("CreateSubscription() = %+v, want one-time purchase ending in its start month", sub)
+	}
+
+	total, err := svc.TotalSubscriptionsCost(ctx, apiModels.TotalCostRequest{UserID: userID.String(), StartDate: "01-2024", EndDate: "12-2024"})
+	if err != nil {
+		t.Fatalf("TotalSubscriptionsCost() unexpected error: %v", err)
+	}
+	if total.TotalCost != 1500 {
+		t.Errorf("TotalSubscriptionsCost() = %d, want 1500 charged once", total.TotalCost)
+	}
+
+	id := apiModels.ItemByIDRequest{ID: sub.ID.String()}
+	updated, err := svc.UpdateSubscriptionByID(ctx, id, &apiModels.UpdateSubscriptionRequest{StartDate: strPtr("05-2024")})
+	if err != nil {
+		t.Fatalf("UpdateSubscriptionByID() unexpected error: %v", err)
+	}
+	if updated.EndDate == nil || !updated.EndDate.Equal(updated.StartDate) {
+		t.Errorf("UpdateSubscriptionByID() end date = %v, want it moved with start date %v", updated.EndDate, updated.StartDate)
+	}
+
+	for _, end := range []string{"12-2024", ""} {
+		if _, err = svc.UpdateSubscriptionByID(ctx, id, &apiModels.UpdateSubscriptionRequest{EndDate: strPtr(end)}); !errors.Is(err, ErrValidationError) {
+			t.Errorf("UpdateSubscriptionByID(end_date=%q) error = %v, want %v", end, err, ErrValidationError)
+		}
+	}
+}
+
+func TestPlans(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	plan, err := svc.CreatePlan(ctx, &apiModels.CreatePlanRequest{ServiceName: " Yandex Plus ", Name: "Family", Price: 499})
+	if err != nil {
+		t.Fatalf("CreatePlan() unexpected error: %v", err)
+	}
+	if plan.ServiceName != "Yandex Plus" {
+		t.Errorf("CreatePlan() service name = %q, want trimmed", plan.ServiceName)
+	}
+	if _, err = svc.CreatePlan(ctx, &apiModels.CreatePlanRequest{ServiceName: "Yandex Plus", Name: "Family", Price: 599}); !errors.Is(err, ErrPlanConflict) {
+		t.Errorf("CreatePlan() duplicate error = %v, want %v", err, ErrPlanConflict)
+	}
+	if _, err = svc.CreatePlan(ctx, &apiModels.CreatePlanRequest{ServiceName: "Yandex Plus", Name: "Solo", Price: -1}); !errors.Is(err, ErrValidationError) {
+		t.Errorf("CreatePlan() negative price error = %v, want %v", err, ErrValidationError)
+	}
+
+	planID := plan.ID.String()
+	userID := uuid.NewString()
+	tests := []struct {
+		name            string
+		req             apiModels.CreateSubscriptionRequest
+		wantServiceName string
+		wantPrice       int
+		wantErr         bool
+	}{
+		{name: "prefilled from plan", req: apiModels.CreateSubscriptionRequest{PlanID: &planID, UserID: userID, StartDate: "01-2024"}, wantServiceName: "Yandex Plus", wantPrice: 499},
+		{name: "own price", req: apiModels.CreateSubscriptionRequest{PlanID: &planID, Price: intPtr(399), UserID: userID, StartDate: "01-2024"}, wantServiceName: "Yandex Plus", wantPrice: 399},
+		{name: "free on plan", req: apiModels.CreateSubscriptionRequest{PlanID: &planID, Price: intPtr(0), UserID: userID, StartDate: "01-2024"}, wantServiceName: "Yandex Plus", wantPrice: 0},
+		{name: "plan service in other case", req: apiModels.CreateSubscriptionRequest{PlanID: &planID, ServiceName: "yandex plus", UserID: userID, StartDate: "01-2024"}, wantServiceName: "Yandex Plus", wantPrice: 499},
+		{name: "other service than plan", req: apiModels.CreateSubscriptionRequest{PlanID: &planID, ServiceName: "Netflix", UserID: userID, StartDate: "01-2024"}, wantErr: true},
+		{name: "following plan", req: apiModels.CreateSubscriptionRequest{PlanID: &planID, FollowPlan: true, UserID: userID, StartDate: "01-2024"}, wantServiceName: "Yandex Plus", wantPrice: 499},
+		{name: "following plan with other price", req: apiModels.CreateSubscriptionRequest{PlanID: &planID, FollowPlan: true, Price: intPtr(399), UserID: userID, StartDate: "01-2024"}, wantErr: true},
+		{name: "following without plan", req: apiModels.CreateSubscriptionRequest{ServiceName: "Netflix", FollowPlan: true, UserID: userID, StartDate: "01-2024"}, wantErr: true},
+		{name: "unknown plan", req: apiModels.CreateSubscriptionRequest{PlanID: strPtr(uuid.NewString()), UserID: userID, StartDate: "01-2024"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, err := svc.CreateSubscription(ctx, &tt.req)
+			if tt.wantErr {
+				if !errors.Is(err, ErrValidationError) {
+					t.Errorf("CreateSubscription() error = %v, want %v", err, ErrValidationError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateSubscription() unexpected error: %v", err)
+			}
+			if sub.ServiceName != tt.wantServiceName || sub.Price != tt.wantPrice || sub.PlanID == nil || *sub.PlanID != plan.ID {
+				t.Errorf("CreateSubscription() = %+v, want %s at %d on plan %s", sub, tt.wantServiceName, tt.wantPrice, plan.ID)
+			}
+		})
+	}
+
+	resp, err := svc.UpdatePlanPrice(ctx, apiModels.ItemByIDRequest{ID: planID}, &apiModels.UpdatePlanPriceRequest{Price: intPtr(549)})
+	if err != nil {
+		t.Fatalf("UpdatePlanPrice() unexpected error: %v", err)
+	}
+	if resp.Plan.Price != 549 || resp.Propagated != 1 {
+		t.Errorf("UpdatePlanPrice() = %+v, want price 549 propagated to 1 subscription", resp)
+	}
+	if _, err = svc.UpdatePlanPrice(ctx, apiModels.ItemByIDRequest{ID: uuid.NewString()}, &apiModels.UpdatePlanPriceRequest{Price: intPtr(1)}); !errors.Is(err, ErrPlanNotFound) {
+		t.Errorf("UpdatePlanPrice() error = %v, want %v", err, ErrPlanNotFound)
+	}
+
+	for _, sub := range mockStorage.subscriptions {
+		if !sub.FollowPlan {
+			continue
+		}
+		updated, err := svc.UpdateSubscriptionByID(ctx, apiModels.ItemByIDRequest{ID: sub.ID.String()}, &apiModels.UpdateSubscriptionRequest{Price: intPtr(100)})
+		if err != nil {
+			t.Fatalf("UpdateSubscriptionByID() unexpected error: %v", err)
+		}
+		if updated.FollowPlan {
+			t.Error("UpdateSubscriptionByID() with manual price kept following the plan")
+		}
+	}
+}
+
+func TestOrgUnits(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	company, err := svc.CreateOrgUnit(ctx, &apiModels.CreateOrgUnitRequest{Name: " Company "})
+	if err != nil {
+		t.Fatalf("CreateOrgUnit() unexpected error: %v", err)
+	}
+	if company.Name != "Company" || company.ParentID != nil {
+		t.Errorf("CreateOrgUnit() = %+v, want trimmed root unit", company)
+	}
+	newUnit := func(name string, parent *models.OrgUnit) *models.OrgUnit {
+		t.Helper()
+		unit, unitErr := svc.CreateOrgUnit(ctx, &apiModels.CreateOrgUnitRequest{Name: name, ParentID: strPtr(parent.ID.String())})
+		if unitErr != nil {
+			t.Fatalf("CreateOrgUnit(%s) unexpected error: %v", name, unitErr)
+		}
+		return unit
+	}
+	marketing := newUnit("Marketing", company)
+	team := newUnit("Growth", marketing)
+	sales := newUnit("Sales", company)
+
+	if _, err = svc.CreateOrgUnit(ctx, &apiModels.CreateOrgUnitRequest{Name: "Marketing", ParentID: strPtr(company.ID.String())}); !errors.Is(err, ErrOrgUnitConflict) {
+		t.Errorf("CreateOrgUnit() duplicate sibling error = %v, want %v", err, ErrOrgUnitConflict)
+	}
+	if _, err = svc.CreateOrgUnit(ctx, &apiModels.CreateOrgUnitRequest{Name: "Marketing", ParentID: strPtr(sales.ID.String())}); err != nil {
+		t.Errorf("CreateOrgUnit() same name under another parent unexpected error: %v", err)
+	}
+	if _, err = svc.CreateOrgUnit(ctx, &apiModels.CreateOrgUnitRequest{Name: "Orphan", ParentID: strPtr(uuid.NewString())}); !errors.Is(err, ErrValidationError) {
+		t.Errorf("CreateOrgUnit() with unknown parent error = %v, want %v", err, ErrValidationError)
+	}
+
+	attribute := func(unit *models.OrgUnit, price int) *models.Subscription {
+		t.Helper()
+		req := factory.Subscription().WithPrice(price).Starting("01-2024").Request()
+		req.OrgUnitID = strPtr(unit.ID.String())
+		sub, subErr := svc.CreateSubscription(ctx, req)
+		if subErr != nil {
+			t.Fatalf("CreateSubscription() unexpected error: %v", subErr)
+		}
+		return sub
+	}
+	attribute(company, 1)
+	attribute(marketing, 10)
+	teamSub := attribute(team, 100)
+	attribute(sales, 1000)
+	unattributed := factory.Subscription().WithPrice(10000).Starting("01-2024").Request()
+	if _, err = svc.CreateSubscription(ctx, unattributed); err != nil {
+		t.Fatalf("CreateSubscription() unexpected error: %v", err)
+	}
+
+	req := factory.Subscription().Request()
+	req.OrgUnitID = strPtr(uuid.NewString())
+	if _, err = svc.CreateSubscription(ctx, req); !errors.Is(err, ErrValidationError) {
+		t.Errorf("CreateSubscription() with unknown org unit error = %v, want %v", err, ErrValidationError)
+	}
+
+	period := apiModels.OrgUnitCostRequest{StartDate: "01-2024", EndDate: "02-2024"}
+	cost, err := svc.OrgUnitTotalCost(ctx, apiModels.ItemByIDRequest{ID: company.ID.String()}, period)
+	if err != nil {
+		t.Fatalf("OrgUnitTotalCost() unexpected error: %v", err)
+	}
+	if cost.OwnCost != 2 || cost.TotalCost != 2222 {
+		t.Errorf("OrgUnitTotalCost() own = %d, total = %d, want 2 and 2222", cost.OwnCost, cost.TotalCost)
+	}
+	if len(cost.Children) != 2 || cost.Children[0].Name != "Marketing" || cost.Children[0].TotalCost != 220 || cost.Children[1].TotalCost != 2000 {
+		t.Errorf("OrgUnitTotalCost() children = %+v, want Marketing with 220 and Sales with 2000", cost.Children)
+	}
+
+	if _, err = svc.UpdateSubscriptionByID(ctx, apiModels.ItemByIDRequest{ID: teamSub.ID.String()}, &apiModels.UpdateSubscriptionRequest{OrgUnitID: strPtr("")}); err != nil {
+		t.Fatalf("UpdateSubscriptionByID() detaching org unit unexpected error: %v", err)
+	}
+	cost, err = svc.OrgUnitTotalCost(ctx, apiModels.ItemByIDRequest{ID: marketing.ID.String()}, period)
+	if err != nil {
+		t.Fatalf("OrgUnitTotalCost() unexpected error: %v", err)
+	}
+	if cost.TotalCost != 20 {
+		t.Errorf("OrgUnitTotalCost() after detaching = %d, want 20", cost.TotalCost)
+	}
+
+	if _, err = svc.OrgUnitTotalCost(ctx, apiModels.ItemByIDRequest{ID: uuid.NewString()}, period); !errors.Is(err, ErrOrgUnitNotFound) {
+		t.Errorf("OrgUnitTotalCost() of unknown unit error = %v, want %v", err, ErrOrgUnitNotFound)
+	}
+}
+
+func TestOrgUnitUsage(t *testing.T) {
+	viper.Set(config.MetricsCacheTTL, "1m")
+	t.Cleanup(func() { viper.Set(config.MetricsCacheTTL, 0) })
+
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	company, err := svc.CreateOrgUnit(ctx, &apiModels.CreateOrgUnitRequest{Name: "Company"})
+	if err != nil {
+		t.Fatalf("CreateOrgUnit() unexpected error: %v", err)
+	}
+	marketing, err := svc.CreateOrgUnit(ctx, &apiModels.CreateOrgUnitRequest{Name: "Marketing", ParentID: strPtr(company.ID.String())})
+	if err != nil {
+		t.Fatalf("CreateOrgUnit() unexpected error: %v", err)
+	}
+	for _, req := range []*apiModels.CreateSubscriptionRequest{
+		factory.Subscription().WithPrice(100).Starting("01-2024").Request(),
+		factory.Subscription().WithPrice(10).Starting("01-2024").Request(),
+		factory.Subscription().WithPrice(1000).Starting("01-2024").Ending("12-2024").Request(), // Not active anymore
+	} {
+		req.OrgUnitID = strPtr(marketing.ID.String())
+		if _, err = svc.CreateSubscription(ctx, req); err != nil {
+			t.Fatalf("CreateSubscription() unexpected error: %v", err)
+		}
+	}
+
+	usage, err := svc.OrgUnitUsage(ctx, apiModels.ItemByIDRequest{ID: company.ID.String()})
+	if err != nil {
+		t.Fatalf("OrgUnitUsage() unexpected error: %v", err)
+	}
+	if usage.Subscriptions != 3 || usage.ActiveSubscriptions != 2 || usage.MonthlySpend != 110 {
+		t.Errorf("OrgUnitUsage() = %+v, want 3 subscriptions, 2 active, 110 spent", usage)
+	}
+	if usage.Month != time.Now().UTC().Format(dates.Layout) {
+		t.Errorf("OrgUnitUsage() month = %s, want current", usage.Month)
+	}
+
+	if _, err = svc.CreateSubscription(ctx, factory.Subscription().Starting("01-2024").Request()); err != nil {
+		t.Fatalf("CreateSubscription() unexpected error: %v", err)
+	}
+	for _, sub := range mockStorage.subscriptions {
+		sub.OrgUnitID = &company.ID
+	}
+	if cached, _ := svc.OrgUnitUsage(ctx, apiModels.ItemByIDRequest{ID: company.ID.String()}); cached.Subscriptions != 3 {
+		t.Errorf("OrgUnitUsage() within TTL = %d subscriptions, want cached 3", cached.Subscriptions)
+	}
+
+	if _, err = svc.OrgUnitUsage(ctx, apiModels.ItemByIDRequest{ID: uuid.NewString()}); !errors.Is(err, ErrOrgUnitNotFound) {
+		t.Errorf("OrgUnitUsage() of unknown unit error = %v, want %v", err, ErrOrgUnitNotFound)
+	}
+	if _, err = svc.OrgUnitUsage(ctx, apiModels.ItemByIDRequest{ID: "not-a-uuid"}); !errors.Is(err, ErrValidationError) {
+		t.Errorf("OrgUnitUsage() with invalid ID error = %v, want %v", err, ErrValidationError)
+	}
+}
+
+// waitBulkDelete polls the job until it's finished
+func waitBulkDelete(t *testing.T, svc SubscriptionService, id uuid.UUID) *models.BulkDeleteJob {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := svc.GetBulkDeleteJob(context.Background(), apiModels.ItemByIDRequest{ID: id.String()})
+		if err != nil {
+			t.Fatalf("GetBulkDeleteJob() unexpected error: %v", err)
+		}
+		if job.FinishedAt != nil {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("bulk delete job %s didn't finish in time", id)
+	return nil
+}
+
+func TestBulkDelete(t *testing.T) {
+	viper.Set(config.BulkDeleteBatchSize, 2)
+	t.Cleanup(func() { viper.Set(config.BulkDeleteBatchSize, 0) })
+
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	var kept []uuid.UUID
+	for _, b := range []struct {
+		sub  *factory.SubscriptionBuilder
+		keep bool
+	}{
+		{sub: factory.Subscription().WithUser(userID).WithService("Netflix").Starting("01-2024").Ending("03-2024")},
+		{sub: factory.Subscription().WithUser(userID).WithService("Netflix").Starting("04-2024").Ending("06-2024")},
+		{sub: factory.Subscription().WithUser(userID).WithService("Netflix").Starting("07-2024").Ending("12-2024")},
+		{sub: factory.Subscription().WithUser(userID).WithService("Netflix").Starting("01-2024"), keep: true}, // Open-ended
+		{sub: factory.Subscription().WithUser(userID).WithService("Netflix").Starting("06-2024").Ending("01-2025"), keep: true},
+		{sub: factory.Subscription().WithUser(userID).WithService("Spotify").Starting("01-2024").Ending("03-2024"), keep: true},
+		{sub: factory.Subscription().WithService("Netflix").Starting("01-2024").Ending("03-2024"), keep: true},
+	} {
+		sub := b.sub.Build()
+		mockStorage.subscriptions[sub.ID] = sub
+		if b.keep {
+			kept = append(kept, sub.ID)
+		}
+	}
+
+	job, err := svc.StartBulkDelete(ctx, &apiModels.BulkDeleteRequest{UserID: strPtr(userID.String()), ServiceName: strPtr("Netflix"), StartDate: strPtr("01-2024"), EndDate: strPtr("12-2024")})
+	if err != nil {
+		t.Fatalf("StartBulkDelete() unexpected error: %v", err)
+	}
+	if job.Matched != 3 || job.Status != models.BulkDeleteStatusPending {
+		t.Errorf("StartBulkDelete() = %d matched, status %s, want 3 and pending", job.Matched, job.Status)
+	}
+
+	done := waitBulkDelete(t, svc, job.ID)
+	if done.Status != models.BulkDeleteStatusDone || done.Deleted != 3 || done.Error != nil {
+		t.Errorf("finished job = %+v, want done with 3 deleted", done)
+	}
+	remaining := make([]uuid.UUID, 0, len(mockStorage.subscriptions))
+	for id := range mockStorage.subscriptions {
+		remaining = append(remaining, id)
+	}
+	slices.SortFunc(remaining, func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) })
+	slices.SortFunc(kept, func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) })
+	if !slices.Equal(remaining, kept) {
+		t.Errorf("remaining subscriptions = %v, want %v", remaining, kept)
+	}
+
+	for name, req := range map[string]*apiModels.BulkDeleteRequest{
+		"no criteria":        {},
+		"invalid user ID":    {UserID: strPtr("nope")},
+		"blank service name": {ServiceName: strPtr(" ")},
+		"invalid date":       {StartDate: strPtr("2024-01")},
+		"end before start":   {StartDate: strPtr("06-2024"), EndDate: strPtr("01-2024")},
+	} {
+		if _, err = svc.StartBulkDelete(ctx, req); !errors.Is(err, ErrValidationError) {
+			t.Errorf("StartBulkDelete() %s error = %v, want %v", name, err, ErrValidationError)
+		}
+	}
+	if _, err = svc.GetBulkDeleteJob(ctx, apiModels.ItemByIDRequest{ID: uuid.NewString()}); !errors.Is(err, ErrBulkDeleteNotFound) {
+		t.Errorf("GetBulkDeleteJob() of unknown job error = %v, want %v", err, ErrBulkDeleteNotFound)
+	}
+}
+
+type expensiveDeleteHook struct{ NopHook }
+
+func (expensiveDeleteHook) PreDelete(_ context.Context, sub *models.Subscription) error {
+	if sub.Price > 500 {
+		return errors.New("deleting expensive subscriptions needs approval")
+	}
+	return nil
+}
+
+func TestBulkDeleteRejectedByHook(t *testing.T) {
+	viper.Set(config.BulkDeleteBatchSize, 2)
+	t.Cleanup(func() { viper.Set(config.BulkDeleteBatchSize, 0) })
+
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage, expensiveDeleteHook{})
+	ctx := context.Background()
+
+	userID := uuid.New()
+	for _, price := range []int{100, 1000} {
+		sub := factory.Subscription().WithUser(userID).WithPrice(price).Build()
+		mockStorage.subscriptions[sub.ID] = sub
+	}
+
+	job, err := svc.StartBulkDelete(ctx, &apiModels.BulkDeleteRequest{UserID: strPtr(userID.String())})
+	if err != nil {
+		t.Fatalf("StartBulkDelete() unexpected error: %v", err)
+	}
+	done := waitBulkDelete(t, svc, job.ID)
+	if done.Status != models.BulkDeleteStatusFailed || done.Error == nil || !strings.Contains(*done.Error, "needs approval") {
+		t.Errorf("finished job = %+v, want failed with the hook's error", done)
+	}
+	if len(mockStorage.subscriptions) != 2 {
+		t.Errorf("%d subscriptions left, want the rejected batch kept", len(mockStorage.subscriptions))
+	}
+}
+
+// shortBatchStorage deletes one subscription less than asked in the first batch, as when a row changed while locked
+type shortBatchStorage struct {
+	*MockStorage
+	batches int
+}
+
+func (s *shortBatchStorage) DeleteBulkDeleteBatch(ctx context.Context, job *models.BulkDeleteJob, limit int, check func(batch []models.Subscription) error) ([]models.Subscription, error) {
+	if s.batches++; s.batches == 1 {
+		limit--
+	}
+	return s.MockStorage.DeleteBulkDeleteBatch(ctx, job, limit, check)
+}
+
+func TestBulkDeleteContinuesAfterShortBatch(t *testing.T) {
+	viper.Set(config.BulkDeleteBatchSize, 2)
+	t.Cleanup(func() { viper.Set(config.BulkDeleteBatchSize, 0) })
+
+	mockStorage := &shortBatchStorage{MockStorage: NewMockStorage()}
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	for range 3 {
+		sub := factory.Subscription().WithUser(userID).Build()
+		mockStorage.subscriptions[sub.ID] = sub
+	}
+
+	job, err := svc.StartBulkDelete(ctx, &apiModels.BulkDeleteRequest{UserID: strPtr(userID.String())})
+	if err != nil {
+		t.Fatalf("StartBulkDelete() unexpected error: %v", err)
+	}
+	done := waitBulkDelete(t, svc, job.ID)
+	if done.Status != models.BulkDeleteStatusDone || done.Deleted != 3 || len(mockStorage.subscriptions) != 0 {
+		t.Errorf("finished job = %+v with %d subscriptions left, want done with all 3 deleted", done, len(mockStorage.subscriptions))
+	}
+}
+
+func TestResumeBulkDeletes(t *testing.T) {
+	viper.Set(config.BulkDeleteBatchSize, 2)
+	t.Cleanup(func() { viper.Set(config.BulkDeleteBatchSize, 0) })
+
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	for range 3 {
+		sub := factory.Subscription().WithUser(userID).Build()
+		mockStorage.subscriptions[sub.ID] = sub
+	}
+	abandoned := &models.BulkDeleteJob{ID: uuid.New(), Status: models.BulkDeleteStatusRunning, UserID: &userID, Matched: 3}
+	active := &models.BulkDeleteJob{ID: uuid.New(), Status: models.BulkDeleteStatusRunning, UserID: &userID}
+	mockStorage.bulkJobs[abandoned.ID] = abandoned
+	mockStorage.bulkJobs[active.ID] = active
+	abandoned.UpdatedAt = time.Now().Add(-2 * bulkDeleteStaleAfter)
+	active.UpdatedAt = time.Now() // Another replica is still on it
+
+	if err := svc.ResumeBulkDeletes(ctx); err != nil {
+		t.Fatalf("ResumeBulkDeletes() unexpected error: %v", err)
+	}
+	if abandoned.Status != models.BulkDeleteStatusDone || abandoned.Deleted != 3 {
+		t.Errorf("abandoned job = %+v, want done with 3 deleted", abandoned)
+	}
+	if active.Status != models.BulkDeleteStatusRunning || active.Deleted != 0 {
+		t.Errorf("active job = %+v, want left to its runner", active)
+	}
+}
+
+func TestCreateSubscriptionWithClientID(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	clientID := "beef4269-0a1b-0c1f-afce-e13873b7b23b"
+	req := &apiModels.CreateSubscriptionRequest{
+		ID:          strPtr(clientID),
+		ServiceName: "Test Service",
+		Price:       intPtr(299),
+		UserID:      "550e8400-e29b-41d4-a716-446655440000",
+		StartDate:   "01-2024",
+	}
+
+	sub, err := svc.CreateSubscription(ctx, req)
+	if err != nil {
+		t.Fatalf("CreateSubscription() unexpected error: %v", err)
+	}
+	if sub.ID.String() != clientID {
+		t.Errorf("ID = %s, want %s", sub.ID, clientID)
+	}
+
+	dup := *req
+	dup.Price = intPtr(399)
+	existing, err := svc.CreateSubscription(ctx, &dup)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("CreateSubscription() error = %v, want %v", err, ErrConflict)
+	}
+	if existing == nil || existing.Price != 299 {
+		t.Errorf("CreateSubscription() should return the existing record on conflict, got %+v", existing)
+	}
+}
+
+func TestCreateSubscriptionIfAbsent(t *testing.T) {
+	svc := NewSubscriptionService(NewMockStorage())
+	ctx := context.Background()
+
+	newReq := func(externalID *string) *apiModels.CreateSubscriptionRequest {
+		return &apiModels.CreateSubscriptionRequest{
+			ExternalID:  externalID,
+			ServiceName: "Netflix",
+			Price:       intPtr(299),
+			UserID:      "550e8400-e29b-41d4-a716-446655440000",
+			StartDate:   "01-2024",
+		}
+	}
+
+	first, created, err := svc.CreateSubscriptionIfAbsent(ctx, newReq(strPtr(" crm-1 ")))
+	if err != nil || !created {
+		t.Fatalf("CreateSubscriptionIfAbsent() = created %v, error %v; want created", created, err)
+	}
+	if *first.ExternalID != "crm-1" {
+		t.Errorf("ExternalID = %q, want trimmed %q", *first.ExternalID, "crm-1")
+	}
+
+	second, created, err := svc.CreateSubscriptionIfAbsent(ctx, newReq(strPtr("crm-1")))
+	if err != nil || created {
+		t.Fatalf("CreateSubscriptionIfAbsent() = created %v, error %v; want existing", created, err)
+	}
+	if second.ID != first.ID {
+		t.Errorf("CreateSubscriptionIfAbsent() returned %s, want existing %s", second.ID, first.ID)
+	}
+
+	if _, _, err = svc.CreateSubscriptionIfAbsent(ctx, newReq(nil)); !errors.Is(err, ErrValidationError) {
+		t.Errorf("CreateSubscriptionIfAbsent() without external ID error = %v, want %v", err, ErrValidationError)
+	}
+}
+
+func TestGetSubscriptionByID(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	existingID := uuid.New()
+	mockStorage.subscriptions[existingID] = &models.Subscription{
+		ID:          existingID,
+		ServiceName: "Test",
+		Price:       100,
+		UserID:      uuid.New(),
+		StartDate:   time.Now(),
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		wantErr bool
+		errType error
+	}{
+		{
+			name:    "existing subscription",
+			id:      existingID.String(),
+			wantErr: false,
+		},
+		{
+			name:    "non-existing subscription",
+			id:      uuid.New().String(),
+			wantErr: true,
+			errType: ErrNotFound,
+		},
+		{
+			name:    "invalid UUID",
+			id:      "not-a-uuid",
+			wantErr: true,
+			errType: ErrValidationError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.GetSubscriptionByID(ctx, apiModels.ItemByIDRequest{ID: tt.id})
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("GetSubscriptionByID() expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("GetSubscriptionByID() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestUpdateSubscriptionByID(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name            string
+		existingSub     *models.Subscription
+		id              string
+		req             *apiModels.UpdateSubscriptionRequest
+		wantErr         bool
+		wantServiceName string
+		wantPrice       int
+	}{
+		{
+			name: "update service name",
+			existingSub: &models.Subscription{
+				ServiceName: "Old Name",
+				Price:       100,
+				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			req: &apiModels.UpdateSubscriptionRequest{
+				ServiceName: strPtr("New Name"),
+			},
+			wantErr:         false,
+			wantServiceName: "New Name",
+			wantPrice:       100,
+		},
+		{
+			name: "update price",
+			existingSub: &models.Subscription{
+				ServiceName: "Test Service",
+				Price:       100,
+				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			req: &apiModels.UpdateSubscriptionRequest{
+				Price: intPtr(500),
+			},
+			wantErr:         false,
+			wantServiceName: "Test Service",
+			wantPrice:       500,
+		},
+		{
+			name: "update multiple fields",
+			existingSub: &models.Subscription{
+				ServiceName: "Old Name",
+				Price:       100,
+				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			req: &apiModels.UpdateSubscriptionRequest{
+				ServiceName: strPtr("New Name"),
+				Price:       intPtr(999),
+			},
+			wantErr:         false,
+			wantServiceName: "New Name",
+			wantPrice:       999,
+		},
+		{
+			name: "update dates",
+			existingSub: &models.Subscription{
+				ServiceName: "Test",
+				Price:       100,
+				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			req: &apiModels.UpdateSubscriptionRequest{
+				StartDate: strPtr("06-2024"),
+				EndDate:   strPtr("12-2024"),
+			},
+			wantErr:         false,
+			wantServiceName: "Test",
+			wantPrice:       100,
+		},
+		{
+			name: "clear end date",
+			existingSub: &models.Subscription{
+				ServiceName: "Test",
+				Price:       100,
+				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				EndDate:     timePtr(time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)),
+			},
+			req: &apiModels.UpdateSubscriptionRequest{
+				EndDate: strPtr(""),
+			},
+			wantErr:         false,
+			wantServiceName: "Test",
+			wantPrice:       100,
+		},
+		{
+			name:        "non-existing subscription",
+			existingSub: nil,
+			id:          uuid.New().String(),
+			req: &apiModels.UpdateSubscriptionRequest{
+				ServiceName: strPtr("New Name"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid UUID",
+			existingSub: &models.Subscription{
+				ServiceName: "Test",
+				Price:       100,
+				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			id: "not-a-uuid",
+			req: &apiModels.UpdateSubscriptionRequest{
+				ServiceName: strPtr("New Name"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty service name",
+			existingSub: &models.Subscription{
+				ServiceName: "Test",
+				Price:       100,
+				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			req: &apiModels.UpdateSubscriptionRequest{
+				ServiceName: strPtr(""),
+			},
+			wantErr: true,
+		},
+		{
+			name: "free subscription",
+			existingSub: &models.Subscription{
+				ServiceName: "Test",
+				Price:       100,
+				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			req: &apiModels.UpdateSubscriptionRequest{
+				Price: intPtr(0),
+			},
+			wantServiceName: "Test",
+			wantPrice:       0,
+		},
+		{
+			name: "negative price",
+			existingSub: &models.Subscription{
+				ServiceName: "Test",
+				Price:       100,
+				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			req: &apiModels.UpdateSubscriptionRequest{
+				Price: intPtr(-100),
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid date format",
+			existingSub: &models.Subscription{
+				ServiceName: "Test",
+				Price:       100,
+				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			req: &apiModels.UpdateSubscriptionRequest{
+				StartDate: strPtr("2024-01"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "end date before start date",
+			existingSub: &models.Subscription{
+				ServiceName: "Test",
+				Price:       100,
+				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
+				StartDate:   time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			},
+			req: &apiModels.UpdateSubscriptionRequest{
+				EndDate: strPtr("01-2024"),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := NewMockStorage()
+			svc := NewSubscriptionService(mockStorage)
+
+			var subID string
+			if tt.existingSub != nil {
+				tt.existingSub.ID = uuid.New()
+				subID = tt.existingSub.ID.String()
+				mockStorage.subscriptions[tt.existingSub.ID] = tt.existingSub
+			} else {
+				subID = tt.id
+			}
+
+			if tt.id != "" {
+				subID = tt.id
+			}
+
+			result, err := svc.UpdateSubscriptionByID(ctx, apiModels.ItemByIDRequest{ID: subID}, tt.req)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("UpdateSubscriptionByID() expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("UpdateSubscriptionByID() unexpected error: %v", err)
+				return
+			}
+
+			if result.ServiceName != tt.wantServiceName {
+				t.Errorf("ServiceName = %q, want %q", result.ServiceName, tt.wantServiceName)
+			}
+			if result.Price != tt.wantPrice {
+				t.Errorf("Price = %d, want %d", result.Price, tt.wantPrice)
+			}
+		})
+	}
+}
+
+func TestDeleteSubscriptionByID(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	existingID := uuid.New()
+	mockStorage.subscriptions[existingID] = &models.Subscription{
+		ID:          existingID,
+		ServiceName: "Test",
+		Price:       100,
+		UserID:      uuid.New(),
+		StartDate:   time.Now(),
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		wantErr bool
+	}{
+		{
+			name:    "existing subscription",
+			id:      existingID.String(),
+			wantErr: false,
+		},
+		{
+			name:    "non-existing subscription",
+			id:      uuid.New().String(),
+			wantErr: true,
+		},
+		{
+			name:    "invalid UUID",
+			id:      "not-a-uuid",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.DeleteSubscriptionByID(ctx, apiModels.ItemByIDRequest{ID: tt.id})
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("DeleteSubscriptionByID() expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("DeleteSubscriptionByID() unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestUndoDeletion(t *testing.T) {
+	viper.Set(config.UndoWindow, "10m")
+	t.Cleanup(func() { viper.Set(config.UndoWindow, 0) })
+
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	sub := factory.Subscription().Build()
+	mockStorage.subscriptions[sub.ID] = sub
+	id := apiModels.ItemByIDRequest{ID: sub.ID.String()}
+
+	token, err := svc.DeleteSubscriptionByID(ctx, id)
+	if err != nil {
+		t.Fatalf("DeleteSubscriptionByID() unexpected error: %v", err)
+	}
+	if token == nil || time.Until(token.ExpiresAt) <= 9*time.Minute {
+		t.Fatalf("DeleteSubscriptionByID() token = %+v, want one valid for 10 minutes", token)
+	}
+	if _, err = svc.GetSubscriptionByID(ctx, id); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetSubscriptionByID() after delete error = %v, want %v", err, ErrNotFound)
+	}
+
+	restored, err := svc.UndoDeletion(ctx, apiModels.UndoRequest{Token: token.Token.String()})
+	if err != nil {
+		t.Fatalf("UndoDeletion() unexpected error: %v", err)
+	}
+	if restored.ID != sub.ID {
+		t.Errorf("UndoDeletion() restored %s, want %s", restored.ID, sub.ID)
+	}
+	if _, err = svc.GetSubscriptionByID(ctx, id); err != nil {
+		t.Errorf("GetSubscriptionByID() after undo unexpected error: %v", err)
+	}
+
+	if _, err = svc.UndoDeletion(ctx, apiModels.UndoRequest{Token: token.Token.String()}); !errors.Is(err, ErrUndoNotFound) {
+		t.Errorf("UndoDeletion() with used token error = %v, want %v", err, ErrUndoNotFound)
+	}
+	if _, err = svc.UndoDeletion(ctx, apiModels.UndoRequest{Token: "not-a-uuid"}); !errors.Is(err, ErrValidationError) {
+		t.Errorf("UndoDeletion() with invalid token error = %v, want %v", err, ErrValidationError)
+	}
+
+	viper.Set(config.UndoWindow, 0)
+	if token, err = svc.DeleteSubscriptionByID(ctx, id); err != nil || token != nil {
+		t.Errorf("DeleteSubscriptionByID() with undo disabled = %+v, %v, want no token", token, err)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	live := factory.Subscription().WithUser(userID).Build()
+	deleted := factory.Subscription().WithUser(userID).Build()
+	other := factory.Subscription().Build()
+	mockStorage.subscriptions[live.ID] = live
+	mockStorage.subscriptions[other.ID] = other
+	mockStorage.deleted[deleted.ID] = deleted
+	view := &models.SavedView{ID: uuid.New(), UserID: userID, Name: "mine"}
+	mockStorage.views[view.ID] = view
+
+	record, err := svc.PurgeSubscription(ctx, apiModels.ItemByIDRequest{ID: deleted.ID.String()})
+	if err != nil {
+		t.Fatalf("PurgeSubscription() of soft-deleted subscription unexpected error: %v", err)
+	}
+	if record.Subject != models.PurgeSubjectSubscription || record.SubjectID != deleted.ID {
+		t.Errorf("PurgeSubscription() record = %+v, want one for subscription %s", record, deleted.ID)
+	}
+	if _, err = svc.PurgeSubscription(ctx, apiModels.ItemByIDRequest{ID: deleted.ID.String()}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("PurgeSubscription() twice error = %v, want %v", err, ErrNotFound)
+	}
+	if _, err = svc.PurgeSubscription(ctx, apiModels.ItemByIDRequest{ID: "not-a-uuid"}); !errors.Is(err, ErrValidationError) {
+		t.Errorf("PurgeSubscription() with invalid ID error = %v, want %v", err, ErrValidationError)
+	}
+
+	record, err = svc.PurgeUserData(ctx, apiModels.ItemByIDRequest{ID: userID.String()})
+	if err != nil {
+		t.Fatalf("PurgeUserData() unexpected error: %v", err)
+	}
+	if record.Subject != models.PurgeSubjectUser || record.Subscriptions != 1 || record.Views != 1 {
+		t.Errorf("PurgeUserData() record = %+v, want 1 subscription and 1 view of user", record)
+	}
+	if _, ok := mockStorage.subscriptions[other.ID]; !ok {
+		t.Error("PurgeUserData() removed a subscription of another user")
+	}
+	if len(mockStorage.purges) != 2 {
+		t.Errorf("%d purges recorded, want 2", len(mockStorage.purges))
+	}
+	if _, err = svc.PurgeUserData(ctx, apiModels.ItemByIDRequest{ID: "not-a-uuid"}); !errors.Is(err, ErrValidationError) {
+		t.Errorf("PurgeUserData() with invalid ID error = %v, want %v", err, ErrValidationError)
+	}
+}
+
+func TestCreateSubscriptionsBatch(t *testing.T) {
+	valid := *factory.Subscription().Request()
+	invalid := *factory.Subscription().WithPrice(-1).Request()
+	taken := factory.Subscription().Build()
+	takenReq := *factory.Subscription().Request()
+	takenReq.ID = strPtr(taken.ID.String())
+
+	tests := []struct {
+		name        string
+		reqs        []apiModels.CreateSubscriptionRequest
+		wantCreated int
+		wantFailed  int
+		wantErr     error
+	}{
+		{name: "all valid", reqs: []apiModels.CreateSubscriptionRequest{valid, valid}, wantCreated: 2},
+		{name: "partially invalid", reqs: []apiModels.CreateSubscriptionRequest{valid, invalid, valid}, wantCreated: 2, wantFailed: 1},
+		{name: "all invalid", reqs: []apiModels.CreateSubscriptionRequest{invalid}, wantFailed: 1},
+		{name: "empty", wantErr: ErrValidationError},
+		{name: "too large", reqs: make([]apiModels.CreateSubscriptionRequest, maxBatchSize+1), wantErr: ErrValidationError},
+		{name: "taken ID fails the whole batch", reqs: []apiModels.CreateSubscriptionRequest{valid, takenReq}, wantErr: ErrConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := NewMockStorage()
+			mockStorage.subscriptions[taken.ID] = taken
+			svc := NewSubscriptionService(mockStorage)
+
+			resp, err := svc.CreateSubscriptionsBatch(context.Background(), tt.reqs)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("CreateSubscriptionsBatch() error = %v, want %v", err, tt.wantErr)
+				}
+				if len(mockStorage.subscriptions) != 1 {
+					t.Errorf("failed batch created %d subscriptions, want none", len(mockStorage.subscriptions)-1)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateSubscriptionsBatch() unexpected error: %v", err)
+			}
+			if resp.Created != tt.wantCreated || resp.Failed != tt.wantFailed || len(mockStorage.subscriptions)-1 != tt.wantCreated {
+				t.Errorf("CreateSubscriptionsBatch() created %d (stored %d), failed %d, want %d and %d", resp.Created, len(mockStorage.subscriptions)-1, resp.Failed, tt.wantCreated, tt.wantFailed)
+			}
+			for i, result := range resp.Results {
+				if (result.Error == "") == (result.Subscription == nil) {
+					t.Errorf("results[%d] = %+v, want either a subscription or an error", i, result)
+				}
+			}
+		})
+	}
+}
+
+func TestImportSubscriptions(t *testing.T) {
+	viper.Set(config.LimitsSubscriptionMaxYears, 10)
+	viper.Set(config.LimitsStartDateWindowYears, 30)
+	t.Cleanup(func() {
+		viper.Set(config.LimitsSubscriptionMaxYears, 0)
+		viper.Set(config.LimitsStartDateWindowYears, 0)
+	})
+
+	historical := factory.Subscription().Starting("01-1985").Ending("12-1985").Request()
+	longAgo := factory.Subscription().Starting("01-1990").Request()
+	lifelong := factory.Subscription().Starting("01-2000").Ending("12-2049").Request()
+	spreadsheet := factory.Subscription().Starting("январь 2024").Ending("Dec 2024").Request()
+
+	tests := []struct {
+		name    string
+		subs    []*apiModels.CreateSubscriptionRequest
+		allow   []string
+		wantErr error
+	}{
+		{name: "historical start rejected by default", subs: []*apiModels.CreateSubscriptionRequest{historical}, wantErr: ErrValidationError},
+		{name: "historical start allowed", subs: []*apiModels.CreateSubscriptionRequest{historical}, allow: []string{apiModels.RelaxHistoricalStart}},
+		{name: "only listed validations are skipped", subs: []*apiModels.CreateSubscriptionRequest{lifelong}, allow: []string{apiModels.RelaxHistoricalStart}, wantErr: ErrValidationError},
+		{name: "several skipped", subs: []*apiModels.CreateSubscriptionRequest{lifelong, longAgo}, allow: []string{apiModels.RelaxHistoricalStart, apiModels.RelaxLongDuration}},
+		{name: "unknown validation", subs: []*apiModels.CreateSubscriptionRequest{longAgo}, allow: []string{"price"}, wantErr: ErrValidationError},
+		{name: "month names rejected by default", subs: []*apiModels.CreateSubscriptionRequest{spreadsheet}, wantErr: ErrValidationError},
+		{name: "month names allowed", subs: []*apiModels.CreateSubscriptionRequest{spreadsheet}, allow: []string{apiModels.RelaxMonthNames}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := NewMockStorage()
+			svc := NewSubscriptionService(mockStorage)
+			req := &apiModels.ImportSubscriptionsRequest{}
+			for _, sub := range tt.subs {
+				req.Subscriptions = append(req.Subscriptions, *sub)
+			}
+
+			resp, err := svc.ImportSubscriptions(context.Background(), req, tt.allow)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ImportSubscriptions() error = %v, want %v", err, tt.wantErr)
+				}
+				if len(mockStorage.subscriptions) != 0 {
+					t.Errorf("failed import created %d subscriptions, want none", len(mockStorage.subscriptions))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ImportSubscriptions() unexpected error: %v", err)
+			}
+			if len(resp.IDs) != len(tt.subs) || len(mockStorage.subscriptions) != len(tt.subs) {
+				t.Errorf("ImportSubscriptions() returned %d IDs and stored %d subscriptions, want %d", len(resp.IDs), len(mockStorage.subscriptions), len(tt.subs))
+			}
+		})
+	}
+}
+
+func TestSyncSubscriptions(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	user := apiModels.ItemByIDRequest{ID: userID.String()}
+	kept := factory.Subscription().WithUser(userID).WithExternalID("crm-1").Build()
+	changed := factory.Subscription().WithUser(userID).WithExternalID("crm-2").WithPrice(100).Build()
+	dropped := factory.Subscription().WithUser(userID).WithExternalID("crm-3").Build()
+	unmanaged := factory.Subscription().WithUser(userID).Build()
+	stranger := factory.Subscription().WithExternalID("crm-3").Build()
+	for _, sub := range []*models.Subscription{kept, changed, dropped, unmanaged, stranger} {
+		mockStorage.subscriptions[sub.ID] = sub
+	}
+
+	req := &apiModels.SyncSubscriptionsRequest{Subscriptions: []apiModels.SyncSubscriptionItem{
+		{ExternalID: "crm-1", ServiceName: factory.DefaultServiceName, Price: factory.DefaultPrice, StartDate: factory.DefaultStartDate},
+		{ExternalID: "crm-2", ServiceName: factory.DefaultServiceName, Price: 200, StartDate: factory.DefaultStartDate},
+		{ExternalID: " crm-4 ", ServiceName: "Spotify", Price: 169, StartDate: "03-2024", EndDate: strPtr("12-2024")},
+	}}
+	actions := func(resp *apiModels.SyncSubscriptionsResponse) string {
+		var got []string
+		for _, c := range resp.Changes {
+			got = append(got, c.Action+" "+c.ExternalID)
+		}
+		return strings.Join(got, ", ")
+	}
+	const wantActions = "create crm-4, update crm-2, delete crm-3"
+
+	t.Run("dry run", func(t *testing.T) {
+		resp, err := svc.SyncSubscriptions(ctx, user, req, true)
+		if err != nil {
+			t.Fatalf("SyncSubscriptions() unexpected error: %v", err)
+		}
+		if got := actions(resp); got != wantActions || resp.Unchanged != 1 || !resp.DryRun {
+			t.Errorf("SyncSubscriptions() = %q, %d unchanged, want %q, 1 unchanged", got, resp.Unchanged, wantActions)
+		}
+		if len(mockStorage.subscriptions) != 5 || mockStorage.subscriptions[changed.ID].Price != 100 {
+			t.Error("dry run changed storage")
+		}
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		resp, err := svc.SyncSubscriptions(ctx, user, req, false)
+		if err != nil {
+			t.Fatalf("SyncSubscriptions() unexpected error: %v", err)
+		}
+		if got := actions(resp); got != wantActions || resp.DryRun {
+			t.Errorf("SyncSubscriptions() = %q, want %q", got, wantActions)
+		}
+		if update := resp.Changes[1]; update.Before.Price != 100 || update.After.Price != 200 || update.After.ID != changed.ID {
+			t.Errorf("update change = %+v -> %+v, want same subscription with price 100 -> 200", update.Before, update.After)
+		}
+		if got := mockStorage.subscriptions[changed.ID]; got == nil || got.Price != 200 {
+			t.Errorf("updated subscription = %+v, want price 200", got)
+		}
+		if _, ok := mockStorage.subscriptions[dropped.ID]; ok {
+			t.Error("subscription absent from desired set wasn't deleted")
+		}
+		if _, ok := mockStorage.subscriptions[unmanaged.ID]; !ok {
+			t.Error("subscription without external ID was deleted")
+		}
+		if _, ok := mockStorage.subscriptions[stranger.ID]; !ok {
+			t.Error("another user's subscription was deleted")
+		}
+		created := resp.Changes[0].After
+		if got := mockStorage.subscriptions[created.ID]; got == nil || *got.ExternalID != "crm-4" || got.UserID != userID {
+			t.Errorf("created subscription = %+v, want crm-4 of user %s", got, userID)
+		}
+	})
+
+	t.Run("repeated apply is a no-op", func(t *testing.T) {
+		resp, err := svc.SyncSubscriptions(ctx, user, req, false)
+		if err != nil {
+			t.Fatalf("SyncSubscriptions() unexpected error: %v", err)
+		}
+		if len(resp.Changes) != 0 || resp.Unchanged != 3 {
+			t.Errorf("SyncSubscriptions() = %q, %d unchanged, want no changes, 3 unchanged", actions(resp), resp.Unchanged)
+		}
+	})
+
+	invalid := []struct {
+		name  string
+		user  apiModels.ItemByIDRequest
+		items []apiModels.SyncSubscriptionItem
+	}{
+		{"invalid user ID", apiModels.ItemByIDRequest{ID: "not-a-uuid"}, nil},
+		{"missing external ID", user, []apiModels.SyncSubscriptionItem{{ServiceName: "Netflix", Price: 1, StartDate: "01-2024"}}},
+		{"duplicate external ID", user, []apiModels.SyncSubscriptionItem{
+			{ExternalID: "crm-1", ServiceName: "Netflix", Price: 1, StartDate: "01-2024"},
+			{ExternalID: "crm-1", ServiceName: "Spotify", Price: 1, StartDate: "01-2024"},
+		}},
+		{"invalid item", user, []apiModels.SyncSubscriptionItem{{ExternalID: "crm-1", ServiceName: "Netflix", Price: -1, StartDate: "01-2024"}}},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.SyncSubscriptions(ctx, tt.user, &apiModels.SyncSubscriptionsRequest{Subscriptions: tt.items}, false)
+			if !errors.Is(err, ErrValidationError) {
+				t.Errorf("SyncSubscriptions() error = %v, want %v", err, ErrValidationError)
+			}
+		})
+	}
+}
+
+func TestBulkUpdateSubscriptions(t *testing.T) {
+	mockStorage := NewMockStorage()
+	hook := &priceLimitHook{limit: 5000}
+	svc := NewSubscriptionService(mockStorage, hook)
+	ctx := context.Background()
+
+	userID := uuid.New()
+	netflix := factory.Subscription().WithUser(userID).WithService("Netflix").WithPrice(599).Build()
+	netflix.FollowPlan = true
+	premium := factory.Subscription().WithUser(userID).WithService("netflix premium").WithPrice(999).Build()
+	spotify := factory.Subscription().WithUser(userID).WithService("Spotify").WithPrice(199).Build()
+	othersNetflix := factory.Subscription().WithService("Netflix").WithPrice(599).Build()
+	endedNetflix := factory.Subscription().WithUser(userID).WithService("Netflix").WithPrice(599).Starting("01-2024").Ending("12-2024").Build()
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	disney := factory.Subscription().WithUser(userID).WithService("Disney+").WithPrice(500).Build()
+	disney.Credits = []models.SubscriptionCredit{
+		{Amount: -450, StartDate: month.AddDate(-1, 0, 0), EndDate: timePtr(month.AddDate(0, -1, 0))}, // In the past, still counts
+		{Amount: -250, StartDate: month.AddDate(0, 2, 0)},
+	}
+	for _, sub := range []*models.Subscription{netflix, premium, spotify, othersNetflix, endedNetflix, disney} {
+		mockStorage.subscriptions[sub.ID] = sub
+	}
+	user := apiModels.ItemByIDRequest{ID: userID.String()}
+
+	resp, err := svc.BulkUpdateSubscriptions(ctx, user, &apiModels.BulkUpdateSubscriptionsRequest{ServiceNameFilter: "NETFLIX", NewPrice: intPtr(699)})
+	if err != nil {
+		t.Fatalf("BulkUpdateSubscriptions() unexpected error: %v", err)
+	}
+	if resp.Updated != 1 || netflix.Price != 699 || netflix.FollowPlan || premium.Price != 999 || othersNetflix.Price != 599 || endedNetflix.Price != 599 {
+		t.Errorf("BulkUpdateSubscriptions() exact filter updated %d, prices %d/%d/%d/%d, following plan %v; want only the user's running Netflix at 699 not following plan",
+			resp.Updated, netflix.Price, premium.Price, othersNetflix.Price, endedNetflix.Price, netflix.FollowPlan)
+	}
+
+	resp, err = svc.BulkUpdateSubscriptions(ctx, user, &apiModels.BulkUpdateSubscriptionsRequest{ServiceNameFilter: "netflix*", NewPrice: intPtr(699)})
+	if err != nil {
+		t.Fatalf("BulkUpdateSubscriptions() unexpected error: %v", err)
+	}
+	if resp.Updated != 1 || premium.Price != 699 || spotify.Price != 199 {
+		t.Errorf("BulkUpdateSubscriptions() wildcard filter updated %d, premium price %d; want 1 and 699, ones at the price already skipped", resp.Updated, premium.Price)
+	}
+
+	_, err = svc.BulkUpdateSubscriptions(ctx, user, &apiModels.BulkUpdateSubscriptionsRequest{ServiceNameFilter: "Netflix*", NewPrice: intPtr(6000)})
+	if !errors.Is(err, ErrValidationError) || netflix.Price != 699 || premium.Price != 699 {
+		t.Errorf("BulkUpdateSubscriptions() rejected by hook error = %v, prices %d/%d; want validation error and nothing changed", err, netflix.Price, premium.Price)
+	}
+	if len(hook.events) != 2 {
+		t.Errorf("BulkUpdateSubscriptions() committed %d events, want 2", len(hook.events))
+	}
+
+	_, err = svc.BulkUpdateSubscriptions(ctx, user, &apiModels.BulkUpdateSubscriptionsRequest{ServiceNameFilter: "Disney+", NewPrice: intPtr(300)})
+	if !errors.Is(err, ErrValidationError) || disney.Price != 500 {
+		t.Errorf("BulkUpdateSubscriptions() below past credits error = %v, price %d; want validation error and price 500, the price applies to the whole period", err, disney.Price)
+	}
+	resp, err = svc.BulkUpdateSubscriptions(ctx, user, &apiModels.BulkUpdateSubscriptionsRequest{ServiceNameFilter: "Disney+", NewPrice: intPtr(450)})
+	if err != nil || resp.Updated != 1 || disney.Price != 450 || disney.ID != resp.Items[0].ID {
+		t.Errorf("BulkUpdateSubscriptions() at credits error = %v, price %d; want 450 in place", err, disney.Price)
+	}
+
+	for name, req := range map[string]apiModels.BulkUpdateSubscriptionsRequest{
+		"catch-all filter": {ServiceNameFilter: " * ", NewPrice: intPtr(100)},
+		"no price":         {ServiceNameFilter: "Netflix"},
+		"negative price":   {ServiceNameFilter: "Netflix", NewPrice: intPtr(-1)},
+	} {
+		if _, err = svc.BulkUpdateSubscriptions(ctx, user, &req); !errors.Is(err, ErrValidationError) {
+			t.Errorf("BulkUpdateSubscriptions() with %s error = %v, want %v", name, err, ErrValidationError)
+		}
+	}
+}
+
+func TestRenameService(t *testing.T) {
+	viper.Set(config.ServiceRenameBatchSize, 2)
+	t.Cleanup(func() { viper.Set(config.ServiceRenameBatchSize, 0) })
+
+	mockStorage := NewMockStorage()
+	hook := &priceLimitHook{limit: 5000}
+	svc := NewSubscriptionService(mockStorage, hook)
+	ctx := context.Background()
+
+	parent := &models.OrgUnit{ID: uuid.New(), Name: "Engineering"}
+	child := &models.OrgUnit{ID: uuid.New(), ParentID: &parent.ID, Name: "Platform"}
+	other := &models.OrgUnit{ID: uuid.New(), Name: "Sales"}
+	for _, u := range []*models.OrgUnit{parent, child, other} {
+		mockStorage.orgUnits[u.ID] = u
+	}
+
+	var inParent, inOther, unattached []uuid.UUID
+	for _, b := range []struct {
+		name string
+		unit *uuid.UUID
+		ids  *[]uuid.UUID
+	}{
+		{name: "HBO Max", unit: &parent.ID, ids: &inParent},
+		{name: "hbo max", unit: &child.ID, ids: &inParent},
+		{name: "HBO MAX", unit: &child.ID, ids: &inParent},
+		{name: "HBO Max", unit: &other.ID, ids: &inOther},
+		{name: "HBO Max", ids: &unattached},
+		{name: "Netflix", unit: &parent.ID},
+	} {
+		sub := factory.Subscription().WithService(b.name).Build()
+		sub.OrgUnitID = b.unit
+		mockStorage.subscriptions[sub.ID] = sub
+		if b.ids != nil {
+			*b.ids = append(*b.ids, sub.ID)
+		}
+	}
+
+	rename, err := svc.RenameService(ctx, &apiModels.RenameServiceRequest{From: " hbo max ", To: "Max", OrgUnitID: strPtr(parent.ID.String())})
+	if err != nil {
+		t.Fatalf("RenameService() unexpected error: %v", err)
+	}
+	if rename.Renamed != 3 || rename.FinishedAt == nil || rename.FromName != "hbo max" || *rename.OrgUnitID != parent.ID {
+		t.Errorf("RenameService() = %+v, want 3 renamed in the unit and finished", rename)
+	}
+	if stored := mockStorage.renames[rename.ID]; stored == nil || stored.Renamed != 3 || stored.FinishedAt == nil {
+		t.Errorf("stored rename = %+v, want 3 renamed and finished", stored)
+	}
+	for _, id := range inParent {
+		if sub := mockStorage.subscriptions[id]; sub.ServiceName != "Max" || sub.Version != 1 {
+			t.Errorf("subscription in the unit = %s v%d, want Max v1", sub.ServiceName, sub.Version)
+		}
+	}
+	if len(hook.events) != 3 || hook.events[0] != HookActionUpdate {
+		t.Errorf("post-commit events = %v, want 3 updates", hook.events)
+	}
+
+	rename, err = svc.RenameService(ctx, &apiModels.RenameServiceRequest{From: "HBO Max", To: "Max"})
+	if err != nil {
+		t.Fatalf("RenameService() unexpected error: %v", err)
+	}
+	if rename.Renamed != 2 {
+		t.Errorf("RenameService() renamed = %d, want the other 2", rename.Renamed)
+	}
+	for _, id := range append(inOther, unattached...) {
+		if sub := mockStorage.subscriptions[id]; sub.ServiceName != "Max" {
+			t.Errorf("subscription = %s, want Max", sub.ServiceName)
+		}
+	}
+
+	for name, req := range map[string]apiModels.RenameServiceRequest{
+		"same name":        {From: "Max", To: " Max"},
+		"no new name":      {From: "Max"},
+		"unknown org unit": {From: "Max", To: "HBO Max", OrgUnitID: strPtr(uuid.NewString())},
+	} {
+		if _, err = svc.RenameService(ctx, &req); !errors.Is(err, ErrValidationError) {
+			t.Errorf("RenameService() with %s error = %v, want %v", name, err, ErrValidationError)
+		}
+	}
+}
+
+// priceLimitHook rejects subscriptions above limit and records committed events
+type priceLimitHook struct {
+	NopHook
+	limit   int
+	deletes int
+	events  []string
+}
+
+func (h *priceLimitHook) PreCreate(_ context.Context, sub *models.Subscription) error {
+	if sub.Price > h.limit {
+		return errors.New("price above limit needs approval")
+	}
+	return nil
+}
+
+func (h *priceLimitHook) PreUpdate(ctx context.Context, _, after *models.Subscription) error {
+	return h.PreCreate(ctx, after)
+}
+
+func (h *priceLimitHook) PreDelete(context.Context, *models.Subscription) error {
+	h.deletes++
+	return nil
+}
+
+func (h *priceLimitHook) PostCommit(_ context.Context, e HookEvent) {
+	h.events = append(h.events, e.Action)
+}
+
+func TestHooks(t *testing.T) {
+	viper.Set(config.UndoWindow, "10m")
+	t.Cleanup(func() { viper.Set(config.UndoWindow, 0) })
+
+	mockStorage := NewMockStorage()
+	hook := &priceLimitHook{limit: 5000}
+	svc := NewSubscriptionService(mockStorage, hook)
+	ctx := context.Background()
+
+	_, err := svc.CreateSubscription(ctx, factory.Subscription().WithPrice(6000).Request())
+	if !errors.Is(err, ErrValidationError) || !strings.Contains(err.Error(), "needs approval") {
+		t.Errorf("CreateSubscription() above limit error = %v, want validation error with hook's message", err)
+	}
+	if len(mockStorage.subscriptions) != 0 {
+		t.Fatal("rejected subscription was stored")
+	}
+
+	batch, err := svc.CreateSubscriptionsBatch(ctx, []apiModels.CreateSubscriptionRequest{
+		*factory.Subscription().WithPrice(100).Request(), *factory.Subscription().WithPrice(6000).Request(),
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscriptionsBatch() unexpected error: %v", err)
+	}
+	if batch.Created != 1 || batch.Failed != 1 {
+		t.Errorf("CreateSubscriptionsBatch() created %d, failed %d, want 1 and 1", batch.Created, batch.Failed)
+	}
+
+	sub := batch.Results[0].Subscription
+	id := apiModels.ItemByIDRequest{ID: sub.ID.String()}
+	if _, err = svc.UpdateSubscriptionByID(ctx, id, &apiModels.UpdateSubscriptionRequest{Price: intPtr(6000)}); !errors.Is(err, ErrValidationError) {
+		t.Errorf("UpdateSubscriptionByID() above limit error = %v, want %v", err, ErrValidationError)
+	}
+	if _, err = svc.UpdateSubscriptionByID(ctx, id, &apiModels.UpdateSubscriptionRequest{Price: intPtr(200)}); err != nil {
+		t.Fatalf("UpdateSubscriptionByID() unexpected error: %v", err)
+	}
+	undo, err := svc.DeleteSubscriptionByID(ctx, id)
+	if err != nil {
+		t.Fatalf("DeleteSubscriptionByID() unexpected error: %v", err)
+	}
+	if hook.deletes != 1 {
+		t.Errorf("PreDelete called %d times, want 1", hook.deletes)
+	}
+
+	// Restoration is a creation to hooks, a rejected one keeps the token
+	hook.limit = 100
+	if _, err = svc.UndoDeletion(ctx, apiModels.UndoRequest{Token: undo.Token.String()}); !errors.Is(err, ErrValidationError) {
+		t.Errorf("UndoDeletion() above limit error = %v, want %v", err, ErrValidationError)
+	}
+	hook.limit = 5000
+	if _, err = svc.UndoDeletion(ctx, apiModels.UndoRequest{Token: undo.Token.String()}); err != nil {
+		t.Fatalf("UndoDeletion() unexpected error: %v", err)
+	}
+
+	plan := &models.Plan{ID: uuid.New(), ServiceName: sub.ServiceName, Name: "Basic", Price: 200}
+	mockStorage.plans[plan.ID] = plan
+	restored := mockStorage.subscriptions[sub.ID]
+	restored.PlanID, restored.FollowPlan = &plan.ID, true
+	planID := apiModels.ItemByIDRequest{ID: plan.ID.String()}
+	if _, err = svc.UpdatePlanPrice(ctx, planID, &apiModels.UpdatePlanPriceRequest{Price: intPtr(6000)}); !errors.Is(err, ErrValidationError) || plan.Price != 200 || restored.Price != 200 {
+		t.Errorf("UpdatePlanPrice() propagated above limit error = %v, prices %d/%d; want validation error and nothing changed", err, plan.Price, restored.Price)
+	}
+	if _, err = svc.UpdatePlanPrice(ctx, planID, &apiModels.UpdatePlanPriceRequest{Price: intPtr(300)}); err != nil {
+		t.Fatalf("UpdatePlanPrice() unexpected error: %v", err)
+	}
+
+	if got, want := strings.Join(hook.events, ", "), "create, update, delete, create, update"; got != want {
+		t.Errorf("committed events = %q, want %q", got, want)
+	}
+}
+
+func TestCreateCredit(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	sub := factory.Subscription().WithPrice(300).Starting("01-2024").Ending("12-2024").Build()
+	mockStorage.subscriptions[sub.ID] = sub
+	id := apiModels.ItemByIDRequest{ID: sub.ID.String()}
+
+	tests := []struct {
+		name    string
+		id      apiModels.ItemByIDRequest
+		req     apiModels.CreateCreditRequest
+		wantErr error
+	}{
+		{name: "valid credit", id: id, req: apiModels.CreateCreditRequest{Amount: -100, StartDate: "03-2024", EndDate: strPtr("05-2024"), Description: " promo "}},
+		{name: "whole price", id: id, req: apiModels.CreateCreditRequest{Amount: -300, StartDate: "06-2024"}},
+		{name: "percentage", id: id, req: apiModels.CreateCreditRequest{Percent: 50, StartDate: "01-2024", EndDate: strPtr("03-2024")}},
+		{name: "percent over 100", id: id, req: apiModels.CreateCreditRequest{Percent: 101, StartDate: "03-2024"}, wantErr: ErrValidationError},
+		{name: "both amount and percent", id: id, req: apiModels.CreateCreditRequest{Amount: -100, Percent: 10, StartDate: "03-2024"}, wantErr: ErrValidationError},
+		{name: "neither amount nor percent", id: id, req: apiModels.CreateCreditRequest{StartDate: "03-2024"}, wantErr: ErrValidationError},
+		{name: "positive amount", id: id, req: apiModels.CreateCreditRequest{Amount: 100, StartDate: "03-2024"}, wantErr: ErrValidationError},
+		{name: "exceeds price", id: id, req: apiModels.CreateCreditRequest{Amount: -301, StartDate: "03-2024"}, wantErr: ErrValidationError},
+		{name: "stacked over price", id: id, req: apiModels.CreateCreditRequest{Amount: -100, StartDate: "03-2024", EndDate: strPtr("03-2024")}, wantErr: ErrValidationError},
+		{name: "stacked over price from later credit", id: id, req: apiModels.CreateCreditRequest{Amount: -50, StartDate: "05-2024"}, wantErr: ErrValidationError},
+		{name: "stacked within price", id: id, req: apiModels.CreateCreditRequest{Amount: -50, StartDate: "04-2024", EndDate: strPtr("05-2024")}},
+		{name: "before subscription", id: id, req: apiModels.CreateCreditRequest{Amount: -100, StartDate: "12-2023"}, wantErr: ErrValidationError},
+		{name: "past subscription end", id: id, req: apiModels.CreateCreditRequest{Amount: -100, StartDate: "10-2024", EndDate: strPtr("01-2025")}, wantErr: ErrValidationError},
+		{name: "invalid id", id: apiModels.ItemByIDRequest{ID: "nope"}, req: apiModels.CreateCreditRequest{Amount: -100, StartDate: "03-2024"}, wantErr: ErrValidationError},
+		{name: "unknown subscription", id: apiModels.ItemByIDRequest{ID: uuid.NewString()}, req: apiModels.CreateCreditRequest{Amount: -100, StartDate: "03-2024"}, wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credit, err := svc.CreateCredit(ctx, tt.id, &tt.req)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("CreateCredit() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateCredit() unexpected error: %v", err)
+			}
+			if credit.SubscriptionID != sub.ID || credit.Amount != tt.req.Amount || credit.Percent != tt.req.Percent {
+				t.Errorf("CreateCredit() = %+v, want amount %d, percent %d on %s", credit, tt.req.Amount, tt.req.Percent, sub.ID)
+			}
+		})
+	}
+
+	credits, err := svc.ListCredits(ctx, id)
+	if err != nil {
+		t.Fatalf("ListCredits() unexpected error: %v", err)
+	}
+	if len(credits) != 4 || credits[0].Description != "promo" {
+		t.Errorf("ListCredits() = %+v, want 4 credits with trimmed description", credits)
+	}
+
+	if _, err = svc.ListCredits(ctx, apiModels.ItemByIDRequest{ID: uuid.NewString()}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ListCredits() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestPauseSubscription(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	sub := factory.Subscription().WithPrice(300).Starting("01-2024").Ending("12-2024").Build()
+	mockStorage.subscriptions[sub.ID] = sub
+	id := apiModels.ItemByIDRequest{ID: sub.ID.String()}
+	oneTime := factory.Subscription().WithPrice(300).Starting("01-2024").Build()
+	oneTime.Type, oneTime.EndDate = models.TypeOneTime, &oneTime.StartDate
+	mockStorage.subscriptions[oneTime.ID] = oneTime
+
+	if _, err := svc.CreateCredit(ctx, id, &apiModels.CreateCreditRequest{Amount: -100, StartDate: "03-2024", EndDate: strPtr("04-2024")}); err != nil {
+		t.Fatalf("CreateCredit() unexpected error: %v", err)
+	}
+
+	// Steps run in order against the same subscription
+	steps := []struct {
+		name    string
+		resume  bool
+		id      apiModels.ItemByIDRequest
+		month   string
+		wantErr error
+	}{
+		{name: "resume not paused", resume: true, id: id, month: "03-2024", wantErr: ErrNotPaused},
+		{name: "before start", id: id, month: "12-2023", wantErr: ErrValidationError},
+		{name: "after end", id: id, month: "01-2025", wantErr: ErrValidationError},
+		{name: "one-time purchase", id: apiModels.ItemByIDRequest{ID: oneTime.ID.String()}, month: "01-2024", wantErr: ErrValidationError},
+		{name: "invalid month", id: id, month: "13-2024", wantErr: ErrValidationError},
+		{name: "unknown subscription", id: apiModels.ItemByIDRequest{ID: uuid.NewString()}, month: "03-2024", wantErr: ErrNotFound},
+		{name: "pause", id: id, month: "03-2024"},
+		{name: "pause again", id: id, month: "05-2024", wantErr: ErrAlreadyPaused},
+		{name: "resume", resume: true, id: id, month: "06-2024"},
+		{name: "overlaps earlier pause", id: id, month: "05-2024", wantErr: ErrValidationError},
+		{name: "pause later", id: id, month: "10-2024"},
+		{name: "resume in first paused month", resume: true, id: id, month: "10-2024"},
+		{name: "pause till end", id: id, month: "11-2024"},
+	}
+
+	for _, tt := range steps {
+		req := &apiModels.PauseSubscriptionRequest{Month: &tt.month}
+		var err error
+		if tt.resume {
+			_, err = svc.ResumeSubscription(ctx, tt.id, req)
+		} else {
+			_, err = svc.PauseSubscription(ctx, tt.id, req)
+		}
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Fatalf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	pauses, err := svc.ListPausedPeriods(ctx, id)
+	if err != nil {
+		t.Fatalf("ListPausedPeriods() unexpected error: %v", err)
+	}
+	if len(pauses) != 2 || pauses[0].EndDate == nil || pauses[0].EndDate.Format(dates.Layout) != "05-2024" || pauses[1].EndDate != nil {
+		t.Errorf("ListPausedPeriods() = %+v, want 03-2024..05-2024 and open one from 11-2024", pauses)
+	}
+
+	// 12 months at 300, minus 03-2024..05-2024 and 11-2024..12-2024, the credited months are paused as well
+	total, err := svc.TotalSubscriptionsCost(ctx, apiModels.TotalCostRequest{UserID: sub.UserID.String(), StartDate: "01-2024", EndDate: "12-2024"})
+	if err != nil {
+		t.Fatalf("TotalSubscriptionsCost() unexpected error: %v", err)
+	}
+	if want := int64(7 * 300); total.TotalCost != want {
+		t.Errorf("TotalSubscriptionsCost() = %d, want %d", total.TotalCost, want)
+	}
+	if got := pausedInPeriod(*sub, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)); got != 200+300+300 {
+		t.Errorf("pausedInPeriod() = %d, want %d", got, 200+300+300)
+	}
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	bad := factory.Subscription().Starting("06-2024").Ending("01-2024").Build()
+	good := factory.Subscription().Build()
+	mockStorage.subscriptions[bad.ID] = bad
+	mockStorage.subscriptions[good.ID] = good
+
+	findings, err := svc.CheckIntegrity(ctx)
+	if err != nil {
+		t.Fatalf("CheckIntegrity() unexpected error: %v", err)
+	}
+	if len(findings) != 1 || findings[0].SubscriptionID != bad.ID {
+		t.Errorf("CheckIntegrity() = %+v, want one finding for %s", findings, bad.ID)
+	}
+
+	mockStorage.checkErr = errors.New("connection refused")
+	if _, err = svc.CheckIntegrity(ctx); err == nil {
+		t.Error("CheckIntegrity() expected storage error, got nil")
+	}
+}
+
+func TestRunIntegrityChecks(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	RunIntegrityChecks(ctx, svc, 10*time.Millisecond) // Returns once ctx is done
+
+	if mockStorage.checks < 2 {
+		t.Errorf("RunIntegrityChecks() ran %d checks, want the immediate one and periodic ones", mockStorage.checks)
+	}
+}
+
+func TestListSubscriptions(t *testing.T) {
+	ctx := context.Background()
+
+	userID1 := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	userID2 := uuid.MustParse("660e8400-e29b-41d4-a716-446655440000")
+
+	setupStorage := func() *MockStorage {
+		mockStorage := NewMockStorage()
+
+		sub1 := &models.Subscription{
+			ID:          uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+			ServiceName: "Netflix",
+			Price:       100,
+			UserID:      userID1,
+			StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			CreatedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+		sub2 := &models.Subscription{
+			ID:          uuid.MustParse("00000000-0000-0000-0000-000000000002"),
+			ServiceName: "Spotify",
+			Price:       200,
+			UserID:      userID1,
+			StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:     timePtr(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)),
+			CreatedAt:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		}
+		sub3 := &models.Subscription{
+			ID:          uuid.MustParse("00000000-0000-0000-0000-000000000003"),
+			ServiceName: "Netflix",
+			Price:       100,
+			UserID:      userID2,
+			StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			CreatedAt:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		}
+
+		mockStorage.subscriptions[sub1.ID] = sub1
+		mockStorage.subscriptions[sub2.ID] = sub2
+		mockStorage.subscriptions[sub3.ID] = sub3
+
+		return mockStorage
+	}
+
+	tests := []struct {
+		name      string
+		req       apiModels.ListSubscriptionsRequest
+		wantCount int
+		wantErr   bool
+	}{
+		{
+			name:      "list all",
+			req:       apiModels.ListSubscriptionsRequest{},
+			wantCount: 3,
+			wantErr:   false,
+		},
+		{
+			name: "filter by user ID",
+			req: apiModels.ListSubscriptionsRequest{
+				UserID: userID1.String(),
+			},
+			wantCount: 2,
+			wantErr:   false,
+		},
+		{
+			name: "filter by service name",
+			req: apiModels.ListSubscriptionsRequest{
+				ServiceName: "Netflix",
+			},
+			wantCount: 2,
+			wantErr:   false,
+		},
+		{
+			name: "service name is case-sensitive by default",
+			req: apiModels.ListSubscriptionsRequest{
+				ServiceName: "netflix",
+			},
+			wantCount: 0,
+			wantErr:   false,
+		},
+		{
+			name: "case-insensitive service name",
+			req: apiModels.ListSubscriptionsRequest{
+				ServiceName:   "netflix",
+				ServiceNameCI: true,
+			},
+			wantCount: 2,
+			wantErr:   false,
+		},
+		{
+			name: "service name substring",
+			req: apiModels.ListSubscriptionsRequest{
+				ServiceNameLike: "FLI",
+			},
+			wantCount: 2,
+			wantErr:   false,
+		},
+		{
+			name: "service name prefix",
+			req: apiModels.ListSubscriptionsRequest{
+				ServiceNameLike: "spot*",
+			},
+			wantCount: 1,
+			wantErr:   false,
+		},
+		{
+			name: "case-insensitive flag without service name",
+			req: apiModels.ListSubscriptionsRequest{
+				ServiceNameCI: true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "filter by user ID and service name",
+			req: apiModels.ListSubscriptionsRequest{
+				UserID:      userID1.String(),
+				ServiceName: "Netflix",
+			},
+			wantCount: 1,
+			wantErr:   false,
+		},
+		{
+			name: "no results",
+			req: apiModels.ListSubscriptionsRequest{
+				ServiceName: "NonExistent",
+			},
+			wantCount: 0,
+			wantErr:   false,
+		},
+		{
+			name: "invalid user ID",
+			req: apiModels.ListSubscriptionsRequest{
+				UserID: "not-a-uuid",
+			},
+			wantErr: true,
+		},
+		{
+			name: "created after",
+			req: apiModels.ListSubscriptionsRequest{
+				CreatedAfter: "2024-01-15T00:00:00Z",
+			},
+			wantCount: 2,
+			wantErr:   false,
+		},
+		{
+			name: "created between",
+			req: apiModels.ListSubscriptionsRequest{
+				CreatedAfter:  "2024-01-15T00:00:00Z",
+				CreatedBefore: "2024-02-15T00:00:00+03:00",
+			},
+			wantCount: 1,
+			wantErr:   false,
+		},
+		{
+			name: "invalid created_after format",
+			req: apiModels.ListSubscriptionsRequest{
+				CreatedAfter: "01-2024",
+			},
+			wantErr: true,
+		},
+		{
+			name: "created_after not before created_before",
+			req: apiModels.ListSubscriptionsRequest{
+				CreatedAfter:  "2024-03-01T00:00:00Z",
+				CreatedBefore: "2024-01-01T00:00:00Z",
+			},
+			wantErr: true,
+		},
+		{
+			name:      "active in last month of a subscription",
+			req:       apiModels.ListSubscriptionsRequest{ActiveAt: "02-2024"},
+			wantCount: 3,
+		},
+		{
+			name:      "active after a subscription ended",
+			req:       apiModels.ListSubscriptionsRequest{ActiveAt: "03-2024"},
+			wantCount: 2,
+		},
+		{
+			name:      "active before any subscription started",
+			req:       apiModels.ListSubscriptionsRequest{ActiveAt: "12-2023"},
+			wantCount: 0,
+		},
+		{
+			name:    "invalid active_at format",
+			req:     apiModels.ListSubscriptionsRequest{ActiveAt: "2024-03"},
+			wantErr: true,
+		},
+		{
+			name:      "min price",
+			req:       apiModels.ListSubscriptionsRequest{MinPrice: intPtr(150)},
+			wantCount: 1,
+		},
+		{
+			name:      "price range inclusive",
+			req:       apiModels.ListSubscriptionsRequest{MinPrice: intPtr(100), MaxPrice: intPtr(100)},
+			wantCount: 2,
+		},
+		{
+			name:    "min price above max price",
+			req:     apiModels.ListSubscriptionsRequest{MinPrice: intPtr(200), MaxPrice: intPtr(100)},
+			wantErr: true,
+		},
+		{
+			name:    "negative max price",
+			req:     apiModels.ListSubscriptionsRequest{MaxPrice: intPtr(-1)},
+			wantErr: true,
+		},
+		{
+			name:      "active now",
+			req:       apiModels.ListSubscriptionsRequest{Status: models.StatusActive},
+			wantCount: 2,
+		},
+		{
+			name:      "expired now",
+			req:       apiModels.ListSubscriptionsRequest{Status: models.StatusExpired},
+			wantCount: 1,
+		},
+		{
+			name:    "unknown status",
+			req:     apiModels.ListSubscriptionsRequest{Status: "paused"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := setupStorage()
+			svc := NewSubscriptionService(mockStorage)
+
+			result, err := svc.ListSubscriptions(ctx, tt.req)
+
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ListSubscriptions() expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("ListSubscriptions() unexpected error: %v", err)
+				return
+			}
+
+			if len(result.Items) != tt.wantCount {
+				t.Errorf("ListSubscriptions() returned %d items, want %d", len(result.Items), tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestSubscriptionStatus(t *testing.T) {
+	month := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	cancelled := factory.Subscription().Starting("01-2025").Ending("12-2025").Build()
+	cancelled.CancelledAt = timePtr(month)
+	deleted := factory.Subscription().Starting("01-2025").Build()
+	deleted.CancelledAt = timePtr(month)
+	deleted.DeletedAt = gorm.DeletedAt{Time: month, Valid: true}
+
+	tests := []struct {
+		name string
+		sub  *models.Subscription
+		want string
+	}{
+		{name: "open-ended", sub: factory.Subscription().Starting("01-2025").Build(), want: models.StatusActive},
+		{name: "ends this month", sub: factory.Subscription().Starting("01-2025").Ending("06-2025").Build(), want: models.StatusActive},
+		{name: "starts this month", sub: factory.Subscription().Starting("06-2025").Build(), want: models.StatusActive},
+		{name: "starts next month", sub: factory.Subscription().Starting("07-2025").Build(), want: models.StatusUpcoming},
+		{name: "ended last month", sub: factory.Subscription().Starting("01-2025").Ending("05-2025").Build(), want: models.StatusExpired},
+		{name: "cancelled before its end", sub: cancelled, want: models.StatusCancelled},
+		{name: "deleted after cancellation", sub: deleted, want: models.StatusDeleted},
+	}
+
+	for _, tt := range tests {
+		if got := tt.sub.StatusIn(month); got != tt.want {
+			t.Errorf("%s: StatusIn() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestListSubscriptionsPagination(t *testing.T) {
+	ctx := context.Background()
+
+	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	mockStorage := NewMockStorage()
+
+	sub1 := &models.Subscription{
+		ID:          uuid.MustParse("00000000-0000-0000-0000-000000000011"),
+		ServiceName: "A",
+		Price:       100,
+		UserID:      userID,
+		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		CreatedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	sub2 := &models.Subscription{
+		ID:          uuid.MustParse("00000000-0000-0000-0000-000000000012"),
+		ServiceName: "B",
+		Price:       200,
+		UserID:      userID,
+		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		CreatedAt:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+	}
+	sub3 := &models.Subscription{
+		ID:          uuid.MustParse("00000000-0000-0000-0000-000000000013"),
+		ServiceName: "C",
+		Price:       300,
+		UserID:      userID,
+		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		CreatedAt:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	mockStorage.subscriptions[sub1.ID] = sub1
+	mockStorage.subscriptions[sub2.ID] = sub2
+	mockStorage.subscriptions[sub3.ID] = sub3
+
+	svc := NewSubscriptionService(mockStorage)
+
+	limit := 2
+	result, err := svc.ListSubscriptions(ctx, apiModels.ListSubscriptionsRequest{Limit: &limit})
+	if err != nil {
+		t.Fatalf("ListSubscriptions() unexpected error: %v", err)
+	}
+	if len(result.Items) != 2 {
+		t.Fatalf("ListSubscriptions() returned %d items, want 2", len(result.Items))
+	}
+	if result.Items[0].ID != sub3.ID || result.Items[1].ID != sub2.ID {
+		t.Fatalf("ListSubscriptions() order mismatch: got %v, %v", result.Items[0].ID, result.Items[1].ID)
+	}
+	if result.TotalCount != 3 {
+		t.Errorf("ListSubscriptions() total_count = %d, want 3", result.TotalCount)
+	}
+	if result.NextOffset == nil || *result.NextOffset != 2 {
+		t.Errorf("ListSubscriptions() next_offset = %v, want 2", result.NextOffset)
+	}
+
+	offset := 1
+	limit = 1
+	result, err = svc.ListSubscriptions(ctx, apiModels.ListSubscriptionsRequest{Limit: &limit, Offset: &offset})
+	if err != nil {
+		t.Fatalf("ListSubscriptions() unexpected error: %v", err)
+	}
+	if len(result.Items) != 1 {
+		t.Fatalf("ListSubscriptions() returned %d items, want 1", len(result.Items))
+	}
+	if result.Items[0].ID != sub2.ID {
+		t.Fatalf("ListSubscriptions() expected %v, got %v", sub2.ID, result.Items[0].ID)
+	}
+
+	offset = 2
+	limit = 5
+	result, err = svc.ListSubscriptions(ctx, apiModels.ListSubscriptionsRequest{Limit: &limit, Offset: &offset})
+	if err != nil {
+		t.Fatalf("ListSubscriptions() unexpected error: %v", err)
+	}
+	if result.TotalCount != 3 || result.Offset != 2 || len(result.Items) != 1 {
+		t.Errorf("ListSubscriptions() last page = %d items of %d at offset %d, want 1 of 3 at 2", len(result.Items), result.TotalCount, result.Offset)
+	}
+	if result.NextOffset != nil {
+		t.Errorf("ListSubscriptions() next_offset = %d on the last page, want nil", *result.NextOffset)
+	}
+}
+
+func TestListSubscriptionsSorting(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := NewMockStorage()
+
+	subs := []*models.Subscription{
+		{ID: uuid.New(), ServiceName: "Netflix", Price: 300, StartDate: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: uuid.New(), ServiceName: "Apple Music", Price: 100, StartDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), CreatedAt: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: uuid.New(), ServiceName: "Spotify", Price: 200, StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), CreatedAt: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, sub := range subs {
+		mockStorage.subscriptions[sub.ID] = sub
+	}
+	svc := NewSubscriptionService(mockStorage)
+
+	tests := []struct {
+		name    string
+		sortBy  string
+		order   string
+		want    []string
 		wantErr bool
-		errType error
+	}{
+		{name: "default newest first", want: []string{"Spotify", "Apple Music", "Netflix"}},
+		{name: "price ascending", sortBy: "price", order: "asc", want: []string{"Apple Music", "Spotify", "Netflix"}},
+		{name: "price descending by default", sortBy: "price", want: []string{"Netflix", "Spotify", "Apple Music"}},
+		{name: "start date ascending", sortBy: "start_date", order: "asc", want: []string{"Spotify", "Netflix", "Apple Music"}},
+		{name: "service name ascending", sortBy: "service_name", order: "asc", want: []string{"Apple Music", "Netflix", "Spotify"}},
+		{name: "unknown column", sortBy: "user_id; drop table subscriptions", wantErr: true},
+		{name: "unknown order", sortBy: "price", order: "up", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := svc.ListSubscriptions(ctx, apiModels.ListSubscriptionsRequest{SortBy: tt.sortBy, Order: tt.order})
+			if tt.wantErr {
+				if !errors.Is(err, ErrValidationError) {
+					t.Errorf("ListSubscriptions() error = %v, want validation error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ListSubscriptions() unexpected error: %v", err)
+			}
+			var got []string
+			for _, sub := range result.Items {
+				got = append(got, sub.ServiceName)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ListSubscriptions() order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTotalSubscriptionsCost(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+
+	// Subscription 01-2024 to 12-2024, price 100
+	sub1 := &models.Subscription{
+		ID:          uuid.New(),
+		ServiceName: "Service A",
+		Price:       100,
+		UserID:      userID,
+		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:     timePtr(time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)),
+	}
+	mockStorage.subscriptions[sub1.ID] = sub1
+
+	// Subscription 06-2024 onwards (no end), price 200
+	sub2 := &models.Subscription{
+		ID:          uuid.New(),
+		ServiceName: "Service B",
+		Price:       200,
+		UserID:      userID,
+		StartDate:   time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:     nil,
+	}
+	mockStorage.subscriptions[sub2.ID] = sub2
+
+	tests := []struct {
+		name      string
+		req       apiModels.TotalCostRequest
+		wantTotal int64
+		wantErr   bool
 	}{
 		{
-			name:    "existing subscription",
-			id:      existingID.String(),
-			wantErr: false,
+			name: "full year 2024",
+			req: apiModels.TotalCostRequest{
+				UserID:    userID.String(),
+				StartDate: "01-2024",
+				EndDate:   "12-2024",
+			},
+			// sub1: 12 months * 100 = 1200
+			// sub2: 7 months (06-12) * 200 = 1400
+			// total = 2600
+			wantTotal: 2600,
+			wantErr:   false,
+		},
+		{
+			name: "first half of 2024",
+			req: apiModels.TotalCostRequest{
+				UserID:    userID.String(),
+				StartDate: "01-2024",
+				EndDate:   "06-2024",
+			},
+			// sub1: 6 months * 100 = 600
+			// sub2: 1 month (06) * 200 = 200
+			// total = 800
+			wantTotal: 800,
+			wantErr:   false,
+		},
+		{
+			name: "filter by service name",
+			req: apiModels.TotalCostRequest{
+				UserID:      userID.String(),
+				ServiceName: "Service A",
+				StartDate:   "01-2024",
+				EndDate:     "12-2024",
+			},
+			wantTotal: 1200,
+			wantErr:   false,
 		},
 		{
-			name:    "non-existing subscription",
-			id:      uuid.New().String(),
+			name: "invalid start date",
+			req: apiModels.TotalCostRequest{
+				UserID:    userID.String(),
+				StartDate: "invalid",
+				EndDate:   "12-2024",
+			},
 			wantErr: true,
-			errType: ErrNotFound,
 		},
 		{
-			name:    "invalid UUID",
-			id:      "not-a-uuid",
+			name: "end date before start date",
+			req: apiModels.TotalCostRequest{
+				UserID:    userID.String(),
+				StartDate: "12-2024",
+				EndDate:   "01-2024",
+			},
 			wantErr: true,
-			errType: ErrValidationError,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := svc.GetSubscriptionByID(ctx, apiModels.ItemByIDRequest{ID: tt.id})
+			resp, err := svc.TotalSubscriptionsCost(ctx, tt.req)
 
 			if tt.wantErr {
 				if err == nil {
-					t.Errorf("GetSubscriptionByID() expected error, got nil")
+					t.Errorf("TotalSubscriptionsCost() expected error, got nil")
 				}
 				return
 			}
 
 			if err != nil {
-				t.Errorf("GetSubscriptionByID() unexpected error: %v", err)
+				t.Errorf("TotalSubscriptionsCost() unexpected error: %v", err)
+				return
+			}
+
+			if resp.TotalCost != tt.wantTotal {
+				t.Errorf("TotalCost = %d, want %d", resp.TotalCost, tt.wantTotal)
 			}
 		})
 	}
 }
 
-func TestUpdateSubscriptionByID(t *testing.T) {
+func TestTotalCostBreakdown(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
 	ctx := context.Background()
 
+	userID := uuid.New()
+	subs := []*models.Subscription{
+		{ID: uuid.New(), ServiceName: "Netflix", Price: 100, UserID: userID, StartDate: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: uuid.New(), ServiceName: "Spotify", Price: 200, UserID: userID, StartDate: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), EndDate: timePtr(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))},
+		{ID: uuid.New(), ServiceName: "Netflix", Price: 50, UserID: userID, StartDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	subs[0].Credits = []models.SubscriptionCredit{{Amount: -30, StartDate: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)}}
+	for _, sub := range subs {
+		mockStorage.subscriptions[sub.ID] = sub
+	}
+	req := apiModels.TotalCostRequest{UserID: userID.String(), StartDate: "01-2024", EndDate: "04-2024"}
+
+	total, err := svc.TotalSubscriptionsCost(ctx, req)
+	if err != nil {
+		t.Fatalf("TotalSubscriptionsCost() unexpected error: %v", err)
+	}
+	if total.Breakdown != nil {
+		t.Errorf("Breakdown = %+v without group_by, want none", total.Breakdown)
+	}
+
 	tests := []struct {
-		name            string
-		existingSub     *models.Subscription
-		id              string
-		req             *apiModels.UpdateSubscriptionRequest
-		wantErr         bool
-		wantServiceName string
-		wantPrice       int
+		groupBy string
+		want    []apiModels.CostBreakdownItem
 	}{
-		{
-			name: "update service name",
-			existingSub: &models.Subscription{
-				ServiceName: "Old Name",
-				Price:       100,
-				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
-				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			},
-			req: &apiModels.UpdateSubscriptionRequest{
-				ServiceName: strPtr("New Name"),
-			},
-			wantErr:         false,
-			wantServiceName: "New Name",
-			wantPrice:       100,
-		},
-		{
-			name: "update price",
-			existingSub: &models.Subscription{
-				ServiceName: "Test Service",
-				Price:       100,
-				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
-				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			},
-			req: &apiModels.UpdateSubscriptionRequest{
-				Price: intPtr(500),
-			},
-			wantErr:         false,
-			wantServiceName: "Test Service",
-			wantPrice:       500,
-		},
-		{
-			name: "update multiple fields",
-			existingSub: &models.Subscription{
-				ServiceName: "Old Name",
-				Price:       100,
-				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
-				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			},
-			req: &apiModels.UpdateSubscriptionRequest{
-				ServiceName: strPtr("New Name"),
-				Price:       intPtr(999),
-			},
-			wantErr:         false,
-			wantServiceName: "New Name",
-			wantPrice:       999,
-		},
-		{
-			name: "update dates",
-			existingSub: &models.Subscription{
-				ServiceName: "Test",
-				Price:       100,
-				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
-				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			},
-			req: &apiModels.UpdateSubscriptionRequest{
-				StartDate: strPtr("06-2024"),
-				EndDate:   strPtr("12-2024"),
-			},
-			wantErr:         false,
-			wantServiceName: "Test",
-			wantPrice:       100,
-		},
-		{
-			name: "clear end date",
-			existingSub: &models.Subscription{
-				ServiceName: "Test",
-				Price:       100,
-				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
-				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-				EndDate:     timePtr(time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)),
-			},
-			req: &apiModels.UpdateSubscriptionRequest{
-				EndDate: strPtr(""),
-			},
-			wantErr:         false,
-			wantServiceName: "Test",
-			wantPrice:       100,
-		},
-		{
-			name:        "non-existing subscription",
-			existingSub: nil,
-			id:          uuid.New().String(),
-			req: &apiModels.UpdateSubscriptionRequest{
-				ServiceName: strPtr("New Name"),
-			},
-			wantErr: true,
-		},
-		{
-			name: "invalid UUID",
-			existingSub: &models.Subscription{
-				ServiceName: "Test",
-				Price:       100,
-				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
-				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			},
-			id: "not-a-uuid",
-			req: &apiModels.UpdateSubscriptionRequest{
-				ServiceName: strPtr("New Name"),
-			},
-			wantErr: true,
-		},
-		{
-			name: "empty service name",
-			existingSub: &models.Subscription{
-				ServiceName: "Test",
-				Price:       100,
-				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
-				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			},
-			req: &apiModels.UpdateSubscriptionRequest{
-				ServiceName: strPtr(""),
-			},
-			wantErr: true,
-		},
-		{
-			name: "zero price",
-			existingSub: &models.Subscription{
-				ServiceName: "Test",
-				Price:       100,
-				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
-				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			},
-			req: &apiModels.UpdateSubscriptionRequest{
-				Price: intPtr(0),
-			},
-			wantErr: true,
-		},
-		{
-			name: "negative price",
-			existingSub: &models.Subscription{
-				ServiceName: "Test",
-				Price:       100,
-				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
-				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			},
-			req: &apiModels.UpdateSubscriptionRequest{
-				Price: intPtr(-100),
-			},
-			wantErr: true,
-		},
-		{
-			name: "invalid date format",
-			existingSub: &models.Subscription{
-				ServiceName: "Test",
-				Price:       100,
-				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
-				StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			},
-			req: &apiModels.UpdateSubscriptionRequest{
-				StartDate: strPtr("2024-01"),
-			},
-			wantErr: true,
-		},
-		{
-			name: "end date before start date",
-			existingSub: &models.Subscription{
-				ServiceName: "Test",
-				Price:       100,
-				UserID:      uuid.MustParse("550e8400-e29b-41d4-a716-446655440000"),
-				StartDate:   time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
-			},
-			req: &apiModels.UpdateSubscriptionRequest{
-				EndDate: strPtr("01-2024"),
-			},
-			wantErr: true,
-		},
+		{apiModels.GroupByServiceName, []apiModels.CostBreakdownItem{
+			{ServiceName: "Netflix", Months: 6, Cost: 4*100 - 30 + 2*50},
+			{ServiceName: "Spotify", Months: 2, Cost: 2 * 200},
+		}},
+		{apiModels.GroupByMonth, []apiModels.CostBreakdownItem{
+			{Month: "01-2024", Months: 1, Cost: 100},
+			{Month: "02-2024", Months: 2, Cost: 300},
+			{Month: "03-2024", Months: 3, Cost: 350},
+			{Month: "04-2024", Months: 2, Cost: 100 - 30 + 50},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.groupBy, func(t *testing.T) {
+			req.GroupBy = tt.groupBy
+			resp, err := svc.TotalSubscriptionsCost(ctx, req)
+			if err != nil {
+				t.Fatalf("TotalSubscriptionsCost() unexpected error: %v", err)
+			}
+			if !slices.Equal(resp.Breakdown, tt.want) {
+				t.Errorf("Breakdown = %+v, want %+v", resp.Breakdown, tt.want)
+			}
+			if resp.TotalCost != total.TotalCost {
+				t.Errorf("TotalCost = %d, want %d as without grouping", resp.TotalCost, total.TotalCost)
+			}
+		})
+	}
+
+	req.GroupBy = "user_id"
+	if _, err = svc.TotalSubscriptionsCost(ctx, req); !errors.Is(err, ErrValidationError) {
+		t.Errorf("TotalSubscriptionsCost() with unknown group_by error = %v, want %v", err, ErrValidationError)
+	}
+}
+
+func TestListExpiringSubscriptions(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	month := func(offset int) time.Time {
+		return time.Date(now.Year(), now.Month()+time.Month(offset), 1, 0, 0, 0, 0, time.UTC)
+	}
+	alice, bob := uuid.MustParse("00000000-0000-0000-0000-00000000000a"), uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	add := func(user uuid.UUID, name, typ string, start, end time.Time, open bool) *models.Subscription {
+		sub := &models.Subscription{ID: uuid.New(), ServiceName: name, Price: 100, Type: typ, UserID: user, StartDate: start}
+		if !open {
+			sub.EndDate = &end
+		}
+		mockStorage.subscriptions[sub.ID] = sub
+		return sub
+	}
+	lapsing := add(alice, "Netflix", models.TypeRecurring, month(-6), month(1), false)
+	renewed := add(alice, "Spotify", models.TypeRecurring, month(-6), month(0), false)
+	add(alice, "spotify", models.TypeRecurring, month(1), time.Time{}, true) // Continues the one above
+	later := add(bob, "Netflix", models.TypeRecurring, month(-1), month(1), false)
+	add(bob, "Domain", models.TypeOneTime, month(1), month(1), false)
+	add(bob, "YouTube", models.TypeRecurring, month(-12), month(-1), false) // Already expired
+	add(bob, "Disney", models.TypeRecurring, month(-1), month(6), false)    // Beyond the window
+
+	resp, err := svc.ListExpiringSubscriptions(ctx, apiModels.ExpiringSubscriptionsRequest{Window: "60d", AutoRenew: boolPtr(false)})
+	if err != nil {
+		t.Fatalf("ListExpiringSubscriptions() unexpected error: %v", err)
+	}
+	if resp.From != month(0).Format(dates.Layout) || resp.TotalCount != 2 || len(resp.Users) != 2 {
+		t.Fatalf("ListExpiringSubscriptions() = %+v, want 2 users with 1 subscription each from the current month", resp)
+	}
+	if resp.Users[0].UserID != alice || resp.Users[0].Subscriptions[0].ID != lapsing.ID {
+		t.Errorf("first user = %+v, want alice with %s", resp.Users[0], lapsing.ServiceName)
+	}
+	if resp.Users[1].UserID != bob || resp.Users[1].Subscriptions[0].ID != later.ID {
+		t.Errorf("second user = %+v, want bob with %s", resp.Users[1], later.ServiceName)
+	}
+
+	resp, err = svc.ListExpiringSubscriptions(ctx, apiModels.ExpiringSubscriptionsRequest{Window: "60d", AutoRenew: boolPtr(true)})
+	if err != nil {
+		t.Fatalf("ListExpiringSubscriptions(auto_renew=true) unexpected error: %v", err)
+	}
+	if resp.TotalCount != 1 || resp.Users[0].Subscriptions[0].ID != renewed.ID {
+		t.Errorf("ListExpiringSubscriptions(auto_renew=true) = %+v, want only the renewed one", resp)
+	}
+
+	resp, err = svc.ListExpiringSubscriptions(ctx, apiModels.ExpiringSubscriptionsRequest{})
+	if err != nil {
+		t.Fatalf("ListExpiringSubscriptions() with defaults unexpected error: %v", err)
+	}
+	if resp.TotalCount < 1 || len(resp.Users) == 0 {
+		t.Errorf("ListExpiringSubscriptions() with defaults = %+v, want at least the one ending this month", resp)
+	}
+
+	for _, window := range []string{"0d", "367d", "2m", "d", "-5d"} {
+		if _, err = svc.ListExpiringSubscriptions(ctx, apiModels.ExpiringSubscriptionsRequest{Window: window}); !errors.Is(err, ErrValidationError) {
+			t.Errorf("ListExpiringSubscriptions(window=%q) error = %v, want %v", window, err, ErrValidationError)
+		}
+	}
+}
+
+func TestChargeback(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	create := func(price int, end string, costCenter, projectCode *string) *models.Subscription {
+		t.Helper()
+		b := factory.Subscription().WithPrice(price).Starting("01-2024")
+		if end != "" {
+			b = b.Ending(end)
+		}
+		req := b.Request()
+		req.CostCenter, req.ProjectCode = costCenter, projectCode
+		sub, err := svc.CreateSubscription(ctx, req)
+		if err != nil {
+			t.Fatalf("CreateSubscription() unexpected error: %v", err)
+		}
+		return sub
+	}
+	tagged := create(100, "", strPtr(" CC-2 "), strPtr("APOLLO"))
+	create(10, "01-2024", strPtr("CC-1"), nil)
+	create(1, "", strPtr(" "), nil)
+	if tagged.CostCenter == nil || *tagged.CostCenter != "CC-2" {
+		t.Errorf("CostCenter = %v, want trimmed CC-2", tagged.CostCenter)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			mockStorage := NewMockStorage()
-			svc := NewSubscriptionService(mockStorage)
+	rows, err := svc.Chargeback(ctx, apiModels.ChargebackRequest{By: apiModels.AllocationCostCenter, StartDate: "01-2024", EndDate: "02-2024"})
+	if err != nil {
+		t.Fatalf("Chargeback() unexpected error: %v", err)
+	}
+	want := []apiModels.ChargebackRow{
+		{Allocation: "", Month: "01-2024", Cost: 1},
+		{Allocation: "", Month: "02-2024", Cost: 1},
+		{Allocation: "CC-1", Month: "01-2024", Cost: 10},
+		{Allocation: "CC-2", Month: "01-2024", Cost: 100},
+		{Allocation: "CC-2", Month: "02-2024", Cost: 100},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("Chargeback() by cost center = %+v, want %+v", rows, want)
+	}
 
-			var subID string
-			if tt.existingSub != nil {
-				tt.existingSub.ID = uuid.New()
-				subID = tt.existingSub.ID.String()
-				mockStorage.subscriptions[tt.existingSub.ID] = tt.existingSub
-			} else {
-				subID = tt.id
-			}
+	if _, err = svc.UpdateSubscriptionByID(ctx, apiModels.ItemByIDRequest{ID: tagged.ID.String()}, &apiModels.UpdateSubscriptionRequest{ProjectCode: strPtr("")}); err != nil {
+		t.Fatalf("UpdateSubscriptionByID() clearing project code unexpected error: %v", err)
+	}
+	rows, err = svc.Chargeback(ctx, apiModels.ChargebackRequest{By: apiModels.AllocationProjectCode, StartDate: "02-2024", EndDate: "02-2024"})
+	if err != nil {
+		t.Fatalf("Chargeback() unexpected error: %v", err)
+	}
+	if want = []apiModels.ChargebackRow{{Allocation: "", Month: "02-2024", Cost: 101}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("Chargeback() by project code = %+v, want %+v", rows, want)
+	}
 
-			if tt.id != "" {
-				subID = tt.id
-			}
+	if _, err = svc.Chargeback(ctx, apiModels.ChargebackRequest{By: "team", StartDate: "01-2024", EndDate: "02-2024"}); !errors.Is(err, ErrValidationError) {
+		t.Errorf("Chargeback() with unknown tag error = %v, want %v", err, ErrValidationError)
+	}
+}
 
-			result, err := svc.UpdateSubscriptionByID(ctx, apiModels.ItemByIDRequest{ID: subID}, tt.req)
+func TestAggregateCache(t *testing.T) {
+	viper.Set(config.CacheAggregatesTTL, "1m")
+	viper.Set(config.CacheAggregatesStale, "1m")
+	t.Cleanup(func() {
+		viper.Set(config.CacheAggregatesTTL, 0)
+		viper.Set(config.CacheAggregatesStale, 0)
+	})
 
-			if tt.wantErr {
-				if err == nil {
-					t.Errorf("UpdateSubscriptionByID() expected error, got nil")
-				}
-				return
-			}
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
 
-			if err != nil {
-				t.Errorf("UpdateSubscriptionByID() unexpected error: %v", err)
-				return
-			}
+	userID := uuid.New()
+	sub := factory.Subscription().WithUser(userID).WithPrice(100).Starting("01-2024").Ending("12-2024").Build()
+	mockStorage.subscriptions[sub.ID] = sub
+	req := apiModels.TotalCostRequest{UserID: userID.String(), StartDate: "01-2024", EndDate: "12-2024"}
 
-			if result.ServiceName != tt.wantServiceName {
-				t.Errorf("ServiceName = %q, want %q", result.ServiceName, tt.wantServiceName)
-			}
-			if result.Price != tt.wantPrice {
-				t.Errorf("Price = %d, want %d", result.Price, tt.wantPrice)
+	total := func() int64 {
+		t.Helper()
+		resp, err := svc.TotalSubscriptionsCost(ctx, req)
+		if err != nil {
+			t.Fatalf("TotalSubscriptionsCost() unexpected error: %v", err)
+		}
+		explained, err := svc.ExplainTotalCost(ctx, req)
+		if err != nil {
+			t.Fatalf("ExplainTotalCost() unexpected error: %v", err)
+		}
+		if explained.TotalCost != resp.TotalCost {
+			t.Errorf("ExplainTotalCost() total = %d, TotalSubscriptionsCost() = %d", explained.TotalCost, resp.TotalCost)
+		}
+		return resp.TotalCost
+	}
+
+	if got := total(); got != 1200 {
+		t.Fatalf("total = %d, want 1200", got)
+	}
+
+	// Change behind the service's back, e.g. by another replica: cached value is served
+	other := factory.Subscription().WithUser(userID).WithPrice(50).Starting("01-2024").Ending("12-2024").Build()
+	mockStorage.subscriptions[other.ID] = other
+	if got := total(); got != 1200 {
+		t.Errorf("total = %d, want cached 1200", got)
+	}
+
+	// Mutation through the service drops cached aggregates
+	if _, err := svc.UpdateSubscriptionByID(ctx, apiModels.ItemByIDRequest{ID: sub.ID.String()}, &apiModels.UpdateSubscriptionRequest{Price: intPtr(200)}); err != nil {
+		t.Fatalf("UpdateSubscriptionByID() unexpected error: %v", err)
+	}
+	if got := total(); got != 12*200+12*50 {
+		t.Errorf("total after update = %d, want %d", got, 12*200+12*50)
+	}
+}
+
+func TestUserSummary(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	userID := uuid.New()
+	for _, sub := range []*models.Subscription{
+		{ID: uuid.New(), ServiceName: "Netflix", Price: 300, UserID: userID, Type: models.TypeRecurring, StartDate: month.AddDate(-2, 0, 0)},
+		{ID: uuid.New(), ServiceName: "Apple One", Price: 300, UserID: userID, Type: models.TypeRecurring, StartDate: month, EndDate: timePtr(month.AddDate(1, 0, 0))},
+		{ID: uuid.New(), ServiceName: "Adobe", Price: 500, UserID: userID, Type: models.TypeRecurring, StartDate: month.AddDate(-3, 0, 0), EndDate: timePtr(month.AddDate(-1, 0, 0))},
+		{ID: uuid.New(), ServiceName: "Domain", Price: 1000, UserID: userID, Type: models.TypeOneTime, StartDate: month, EndDate: timePtr(month)},
+		{ID: uuid.New(), ServiceName: "Spotify", Price: 900, UserID: uuid.New(), Type: models.TypeRecurring, StartDate: month},
+	} {
+		mockStorage.subscriptions[sub.ID] = sub
+	}
+
+	resp, err := svc.UserSummary(ctx, apiModels.ItemByIDRequest{ID: userID.String()})
+	if err != nil {
+		t.Fatalf("UserSummary() unexpected error: %v", err)
+	}
+	want := apiModels.UserSummaryResponse{
+		UserID:               userID,
+		ActiveSubscriptions:  3,                   // Adobe has ended
+		MonthlyCost:          600,                 // One-time Domain isn't recurring cost
+		MostExpensiveService: strPtr("Apple One"), // Ties broken by name
+		EarliestStartDate:    strPtr(month.AddDate(-3, 0, 0).Format(dates.Layout)),
+		LatestStartDate:      strPtr(month.Format(dates.Layout)),
+	}
+	if !reflect.DeepEqual(*resp, want) {
+		t.Errorf("UserSummary() = %+v, want %+v", *resp, want)
+	}
+
+	resp, err = svc.UserSummary(ctx, apiModels.ItemByIDRequest{ID: uuid.New().String()})
+	if err != nil {
+		t.Fatalf("UserSummary() of user without subscriptions unexpected error: %v", err)
+	}
+	if resp.ActiveSubscriptions != 0 || resp.MostExpensiveService != nil || resp.EarliestStartDate != nil {
+		t.Errorf("UserSummary() of user without subscriptions = %+v, want empty", *resp)
+	}
+
+	if _, err = svc.UserSummary(ctx, apiModels.ItemByIDRequest{ID: "not-a-uuid"}); !errors.Is(err, ErrValidationError) {
+		t.Errorf("UserSummary() with invalid ID error = %v, want %v", err, ErrValidationError)
+	}
+}
+
+func TestTotalSubscriptionsCostBatch(t *testing.T) {
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+	ctx := context.Background()
+
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	for _, sub := range []*models.Subscription{
+		{ID: uuid.New(), ServiceName: "Service A", Price: 100, UserID: alice, StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: uuid.New(), ServiceName: "Service B", Price: 200, UserID: alice, StartDate: time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: uuid.New(), ServiceName: "Service A", Price: 50, UserID: bob, StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), EndDate: timePtr(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))},
+	} {
+		mockStorage.subscriptions[sub.ID] = sub
+	}
+
+	resp, err := svc.TotalSubscriptionsCostBatch(ctx, apiModels.BatchTotalCostRequest{
+		UserIDs:   []string{carol.String(), alice.String(), bob.String()},
+		StartDate: "01-2024",
+		EndDate:   "12-2024",
+	})
+	if err != nil {
+		t.Fatalf("TotalSubscriptionsCostBatch() unexpected error: %v", err)
+	}
+	want := []apiModels.UserTotalCost{{UserID: carol}, {UserID: alice, TotalCost: 2400}, {UserID: bob, TotalCost: 150}}
+	if !slices.Equal(resp.Totals, want) {
+		t.Errorf("TotalSubscriptionsCostBatch() = %+v, want %+v", resp.Totals, want)
+	}
+
+	for _, tt := range []struct {
+		name string
+		req  apiModels.BatchTotalCostRequest
+	}{
+		{"no users", apiModels.BatchTotalCostRequest{StartDate: "01-2024", EndDate: "12-2024"}},
+		{"too many users", apiModels.BatchTotalCostRequest{UserIDs: make([]string, maxBatchSize+1), StartDate: "01-2024", EndDate: "12-2024"}},
+		{"invalid user ID", apiModels.BatchTotalCostRequest{UserIDs: []string{"not-a-uuid"}, StartDate: "01-2024", EndDate: "12-2024"}},
+		{"end before start", apiModels.BatchTotalCostRequest{UserIDs: []string{alice.String()}, StartDate: "12-2024", EndDate: "01-2024"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err = svc.TotalSubscriptionsCostBatch(ctx, tt.req); !errors.Is(err, ErrValidationError) {
+				t.Errorf("TotalSubscriptionsCostBatch() error = %v, want %v", err, ErrValidationError)
 			}
 		})
 	}
 }
 
-func TestDeleteSubscriptionByID(t *testing.T) {
+func TestExplainTotalCost(t *testing.T) {
 	mockStorage := NewMockStorage()
 	svc := NewSubscriptionService(mockStorage)
 	ctx := context.Background()
 
-	existingID := uuid.New()
-	mockStorage.subscriptions[existingID] = &models.Subscription{
-		ID:          existingID,
-		ServiceName: "Test",
-		Price:       100,
-		UserID:      uuid.New(),
-		StartDate:   time.Now(),
+	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	subs := []*models.Subscription{
+		{ID: uuid.New(), ServiceName: "Service A", Price: 100, UserID: userID, StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), EndDate: timePtr(time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC))},
+		{ID: uuid.New(), ServiceName: "Service B", Price: 200, UserID: userID, StartDate: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: uuid.New(), ServiceName: "Service C", Price: 300, UserID: userID, StartDate: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), EndDate: timePtr(time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC))},
+	}
+	subs[1].Credits = []models.SubscriptionCredit{{Amount: -50, StartDate: time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)}}
+	for _, sub := range subs {
+		mockStorage.subscriptions[sub.ID] = sub
+	}
+
+	req := apiModels.TotalCostRequest{UserID: userID.String(), StartDate: "01-2024", EndDate: "12-2024"}
+	resp, err := svc.ExplainTotalCost(ctx, req)
+	if err != nil {
+		t.Fatalf("ExplainTotalCost() unexpected error: %v", err)
+	}
+
+	total, err := svc.TotalSubscriptionsCost(ctx, req)
+	if err != nil {
+		t.Fatalf("TotalSubscriptionsCost() unexpected error: %v", err)
+	}
+	if resp.TotalCost != total.TotalCost {
+		t.Errorf("ExplainTotalCost() total = %d, TotalSubscriptionsCost() = %d", resp.TotalCost, total.TotalCost)
+	}
+
+	want := []apiModels.CostExplanationItem{
+		{SubscriptionID: subs[0].ID, ServiceName: "Service A", Price: 100, From: "01-2024", To: "12-2024", Months: 12, Amount: 1200},
+		{SubscriptionID: subs[1].ID, ServiceName: "Service B", Price: 200, From: "06-2024", To: "12-2024", Months: 7, Credits: -100, Amount: 1300},
+	}
+	if len(resp.Items) != len(want) {
+		t.Fatalf("ExplainTotalCost() returned %d items, want %d", len(resp.Items), len(want))
+	}
+	for i := range want {
+		if resp.Items[i] != want[i] {
+			t.Errorf("ExplainTotalCost() item %d = %+v, want %+v", i, resp.Items[i], want[i])
+		}
+	}
+
+	if _, err = svc.ExplainTotalCost(ctx, apiModels.TotalCostRequest{StartDate: "12-2024", EndDate: "01-2024"}); !errors.Is(err, ErrValidationError) {
+		t.Errorf("ExplainTotalCost() error = %v, want %v", err, ErrValidationError)
+	}
+}
+
+// skewedStorage makes the SQL aggregate disagree with the Go loop
+type skewedStorage struct {
+	*MockStorage
+	sqlTotal int64
+}
+
+func (s *skewedStorage) TotalSubscriptionsCost(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time) (int64, error) {
+	return s.sqlTotal, nil
+}
+
+func TestTotalSubscriptionsCostShadow(t *testing.T) {
+	viper.Set(config.ShadowTotalCostEnabled, true)
+	t.Cleanup(func() {
+		viper.Set(config.ShadowTotalCostEnabled, false)
+		viper.Set(config.ShadowTotalCostServe, "")
+	})
+
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	st := &skewedStorage{MockStorage: NewMockStorage(), sqlTotal: 999}
+	sub := &models.Subscription{ID: uuid.New(), ServiceName: "Netflix", Price: 100, UserID: uuid.New(), StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	st.subscriptions[sub.ID] = sub
+	svc := NewSubscriptionService(st)
+	req := apiModels.TotalCostRequest{StartDate: "01-2024", EndDate: "12-2024"}
+
+	tests := []struct {
+		serve string
+		want  int64
+	}{
+		{serve: "sql", want: 999},
+		{serve: "go", want: 1200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.serve, func(t *testing.T) {
+			viper.Set(config.ShadowTotalCostServe, tt.serve)
+			logs.Reset()
+
+			resp, err := svc.TotalSubscriptionsCost(context.Background(), req)
+			if err != nil {
+				t.Fatalf("TotalSubscriptionsCost() unexpected error: %v", err)
+			}
+			if resp.TotalCost != tt.want {
+				t.Errorf("TotalCost = %d, want %d", resp.TotalCost, tt.want)
+			}
+			if !strings.Contains(logs.String(), "shadow total cost mismatch") {
+				t.Errorf("expected mismatch to be logged, got %q", logs.String())
+			}
+		})
 	}
+}
+
+func TestTotalSubscriptionsCostPeriodLimit(t *testing.T) {
+	viper.Set(config.LimitsTotalCostMaxYears, 10)
+	t.Cleanup(func() { viper.Set(config.LimitsTotalCostMaxYears, 0) })
+
+	svc := NewSubscriptionService(NewMockStorage())
+	ctx := context.Background()
 
 	tests := []struct {
 		name    string
-		id      string
+		start   string
+		end     string
 		wantErr bool
 	}{
-		{
-			name:    "existing subscription",
-			id:      existingID.String(),
-			wantErr: false,
-		},
-		{
-			name:    "non-existing subscription",
-			id:      uuid.New().String(),
-			wantErr: true,
-		},
-		{
-			name:    "invalid UUID",
-			id:      "not-a-uuid",
-			wantErr: true,
-		},
+		{name: "exactly at limit", start: "01-2015", end: "12-2024", wantErr: false},
+		{name: "one month over limit", start: "01-2015", end: "01-2025", wantErr: true},
+		{name: "pathological range", start: "01-1900", end: "12-2100", wantErr: true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := svc.DeleteSubscriptionByID(ctx, apiModels.ItemByIDRequest{ID: tt.id})
-
+			_, err := svc.TotalSubscriptionsCost(ctx, apiModels.TotalCostRequest{StartDate: tt.start, EndDate: tt.end})
 			if tt.wantErr {
-				if err == nil {
-					t.Errorf("DeleteSubscriptionByID() expected error, got nil")
+				if !errors.Is(err, ErrValidationError) {
+					t.Errorf("TotalSubscriptionsCost() error = %v, want %v", err, ErrValidationError)
 				}
 				return
 			}
-
 			if err != nil {
-				t.Errorf("DeleteSubscriptionByID() unexpected error: %v", err)
+				t.Errorf("TotalSubscriptionsCost() unexpected error: %v", err)
 			}
 		})
 	}
 }
 
-func TestListSubscriptions(t *testing.T) {
+func TestSubscriptionDateLimits(t *testing.T) {
+	viper.Set(config.LimitsSubscriptionMaxYears, 10)
+	viper.Set(config.LimitsStartDateWindowYears, 30)
+	t.Cleanup(func() {
+		viper.Set(config.LimitsSubscriptionMaxYears, 0)
+		viper.Set(config.LimitsStartDateWindowYears, 0)
+	})
+
+	svc := NewSubscriptionService(NewMockStorage())
 	ctx := context.Background()
+	thisYear := time.Now().Year()
 
-	userID1 := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
-	userID2 := uuid.MustParse("660e8400-e29b-41d4-a716-446655440000")
+	tests := []struct {
+		name    string
+		start   string
+		end     *string
+		wantErr bool
+	}{
+		{name: "recent start without end", start: fmt.Sprintf("01-%d", thisYear), wantErr: false},
+		{name: "ten years long", start: fmt.Sprintf("01-%d", thisYear), end: strPtr(fmt.Sprintf("12-%d", thisYear+9)), wantErr: false},
+		{name: "longer than ten years", start: fmt.Sprintf("01-%d", thisYear), end: strPtr(fmt.Sprintf("01-%d", thisYear+10)), wantErr: true},
+		{name: "typo in year", start: "01-0224", wantErr: true},
+		{name: "far future", start: fmt.Sprintf("01-%d", thisYear+31), wantErr: true},
+	}
 
-	setupStorage := func() *MockStorage {
-		mockStorage := NewMockStorage()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.CreateSubscription(ctx, &apiModels.CreateSubscriptionRequest{
+				ServiceName: "Netflix",
+				Price:       intPtr(100),
+				UserID:      "550e8400-e29b-41d4-a716-446655440000",
+				StartDate:   tt.start,
+				EndDate:     tt.end,
+			})
+			if tt.wantErr {
+				if !errors.Is(err, ErrValidationError) {
+					t.Errorf("CreateSubscription() error = %v, want %v", err, ErrValidationError)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("CreateSubscription() unexpected error: %v", err)
+			}
+		})
+	}
 
-		sub1 := &models.Subscription{
-			ID:          uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+	t.Run("update extending beyond limit", func(t *testing.T) {
+		sub, err := svc.CreateSubscription(ctx, &apiModels.CreateSubscriptionRequest{
 			ServiceName: "Netflix",
-			Price:       100,
-			UserID:      userID1,
-			StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			CreatedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-		}
-		sub2 := &models.Subscription{
-			ID:          uuid.MustParse("00000000-0000-0000-0000-000000000002"),
-			ServiceName: "Spotify",
-			Price:       200,
-			UserID:      userID1,
-			StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			CreatedAt:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+			Price:       intPtr(100),
+			UserID:      "550e8400-e29b-41d4-a716-446655440000",
+			StartDate:   fmt.Sprintf("01-%d", thisYear),
+		})
+		if err != nil {
+			t.Fatalf("CreateSubscription() unexpected error: %v", err)
 		}
-		sub3 := &models.Subscription{
-			ID:          uuid.MustParse("00000000-0000-0000-0000-000000000003"),
-			ServiceName: "Netflix",
-			Price:       100,
-			UserID:      userID2,
-			StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			CreatedAt:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		_, err = svc.UpdateSubscriptionByID(ctx, apiModels.ItemByIDRequest{ID: sub.ID.String()}, &apiModels.UpdateSubscriptionRequest{
+			EndDate: strPtr(fmt.Sprintf("06-%d", thisYear+12)),
+		})
+		if !errors.Is(err, ErrValidationError) {
+			t.Errorf("UpdateSubscriptionByID() error = %v, want %v", err, ErrValidationError)
 		}
+	})
+}
 
-		mockStorage.subscriptions[sub1.ID] = sub1
-		mockStorage.subscriptions[sub2.ID] = sub2
-		mockStorage.subscriptions[sub3.ID] = sub3
-
-		return mockStorage
-	}
+func TestCreateView(t *testing.T) {
+	svc := NewSubscriptionService(NewMockStorage())
+	ctx := context.Background()
+	user := apiModels.ItemByIDRequest{ID: "550e8400-e29b-41d4-a716-446655440000"}
 
 	tests := []struct {
-		name      string
-		req       apiModels.ListSubscriptionsRequest
-		wantCount int
-		wantErr   bool
+		name    string
+		user    apiModels.ItemByIDRequest
+		req     apiModels.CreateViewRequest
+		wantErr error
 	}{
 		{
-			name:      "list all",
-			req:       apiModels.ListSubscriptionsRequest{},
-			wantCount: 3,
-			wantErr:   false,
+			name: "valid view",
+			user: user,
+			req:  apiModels.CreateViewRequest{Name: "Streaming", ServiceName: strPtr("Netflix"), CreatedAfter: strPtr("2024-01-01T00:00:00Z"), Limit: intPtr(5)},
 		},
 		{
-			name: "filter by user ID",
-			req: apiModels.ListSubscriptionsRequest{
-				UserID: userID1.String(),
-			},
-			wantCount: 2,
-			wantErr:   false,
+			name:    "duplicate name",
+			user:    user,
+			req:     apiModels.CreateViewRequest{Name: "Streaming"},
+			wantErr: ErrViewConflict,
 		},
 		{
-			name: "filter by service name",
-			req: apiModels.ListSubscriptionsRequest{
-				ServiceName: "Netflix",
-			},
-			wantCount: 2,
-			wantErr:   false,
+			name: "same name for another user",
+			user: apiModels.ItemByIDRequest{ID: uuid.NewString()},
+			req:  apiModels.CreateViewRequest{Name: "Streaming"},
 		},
 		{
-			name: "filter by user ID and service name",
-			req: apiModels.ListSubscriptionsRequest{
-				UserID:      userID1.String(),
-				ServiceName: "Netflix",
-			},
-			wantCount: 1,
-			wantErr:   false,
+			name:    "empty name",
+			user:    user,
+			req:     apiModels.CreateViewRequest{Name: " "},
+			wantErr: ErrValidationError,
+		},
+		{
+			name:    "invalid timestamp",
+			user:    user,
+			req:     apiModels.CreateViewRequest{Name: "Recent", CreatedAfter: strPtr("yesterday")},
+			wantErr: ErrValidationError,
+		},
+		{
+			name: "sorted price range",
+			user: user,
+			req:  apiModels.CreateViewRequest{Name: "Expensive", MinPrice: intPtr(500), MaxPrice: intPtr(1000), SortBy: strPtr("price"), Order: strPtr("asc")},
 		},
 		{
-			name: "no results",
-			req: apiModels.ListSubscriptionsRequest{
-				ServiceName: "NonExistent",
-			},
-			wantCount: 0,
-			wantErr:   false,
+			name:    "min price above max",
+			user:    user,
+			req:     apiModels.CreateViewRequest{Name: "Empty", MinPrice: intPtr(1000), MaxPrice: intPtr(500)},
+			wantErr: ErrValidationError,
 		},
 		{
-			name: "invalid user ID",
-			req: apiModels.ListSubscriptionsRequest{
-				UserID: "not-a-uuid",
-			},
-			wantErr: true,
+			name:    "unknown sort",
+			user:    user,
+			req:     apiModels.CreateViewRequest{Name: "Sorted", SortBy: strPtr("user_id")},
+			wantErr: ErrValidationError,
+		},
+		{
+			name:    "unknown order",
+			user:    user,
+			req:     apiModels.CreateViewRequest{Name: "Sorted", Order: strPtr("up")},
+			wantErr: ErrValidationError,
+		},
+		{
+			name:    "invalid user ID",
+			user:    apiModels.ItemByIDRequest{ID: "not-a-uuid"},
+			req:     apiModels.CreateViewRequest{Name: "Streaming"},
+			wantErr: ErrValidationError,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockStorage := setupStorage()
-			svc := NewSubscriptionService(mockStorage)
-
-			result, err := svc.ListSubscriptions(ctx, tt.req)
-
-			if tt.wantErr {
-				if err == nil {
-					t.Errorf("ListSubscriptions() expected error, got nil")
+			view, err := svc.CreateView(ctx, tt.user, &tt.req)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("CreateView() error = %v, want %v", err, tt.wantErr)
 				}
 				return
 			}
-
 			if err != nil {
-				t.Errorf("ListSubscriptions() unexpected error: %v", err)
-				return
+				t.Fatalf("CreateView() unexpected error: %v", err)
 			}
-
-			if len(result) != tt.wantCount {
-				t.Errorf("ListSubscriptions() returned %d items, want %d", len(result), tt.wantCount)
+			if view.UserID.String() != tt.user.ID || view.Name != tt.req.Name {
+				t.Errorf("CreateView() = %+v, want user %s and name %s", view, tt.user.ID, tt.req.Name)
 			}
 		})
 	}
-}
-
-func TestListSubscriptionsPagination(t *testing.T) {
-	ctx := context.Background()
-
-	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
-	mockStorage := NewMockStorage()
-
-	sub1 := &models.Subscription{
-		ID:          uuid.MustParse("00000000-0000-0000-0000-000000000011"),
-		ServiceName: "A",
-		Price:       100,
-		UserID:      userID,
-		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-		CreatedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-	}
-	sub2 := &models.Subscription{
-		ID:          uuid.MustParse("00000000-0000-0000-0000-000000000012"),
-		ServiceName: "B",
-		Price:       200,
-		UserID:      userID,
-		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-		CreatedAt:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
-	}
-	sub3 := &models.Subscription{
-		ID:          uuid.MustParse("00000000-0000-0000-0000-000000000013"),
-		ServiceName: "C",
-		Price:       300,
-		UserID:      userID,
-		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-		CreatedAt:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
-	}
-
-	mockStorage.subscriptions[sub1.ID] = sub1
-	mockStorage.subscriptions[sub2.ID] = sub2
-	mockStorage.subscriptions[sub3.ID] = sub3
-
-	svc := NewSubscriptionService(mockStorage)
-
-	limit := 2
-	result, err := svc.ListSubscriptions(ctx, apiModels.ListSubscriptionsRequest{Limit: &limit})
-	if err != nil {
-		t.Fatalf("ListSubscriptions() unexpected error: %v", err)
-	}
-	if len(result) != 2 {
-		t.Fatalf("ListSubscriptions() returned %d items, want 2", len(result))
-	}
-	if result[0].ID != sub3.ID || result[1].ID != sub2.ID {
-		t.Fatalf("ListSubscriptions() order mismatch: got %v, %v", result[0].ID, result[1].ID)
-	}
 
-	offset := 1
-	limit = 1
-	result, err = svc.ListSubscriptions(ctx, apiModels.ListSubscriptionsRequest{Limit: &limit, Offset: &offset})
+	views, err := svc.ListViews(ctx, user)
 	if err != nil {
-		t.Fatalf("ListSubscriptions() unexpected error: %v", err)
+		t.Fatalf("ListViews() unexpected error: %v", err)
 	}
-	if len(result) != 1 {
-		t.Fatalf("ListSubscriptions() returned %d items, want 1", len(result))
-	}
-	if result[0].ID != sub2.ID {
-		t.Fatalf("ListSubscriptions() expected %v, got %v", sub2.ID, result[0].ID)
+	if len(views) != 2 {
+		t.Errorf("ListViews() returned %d views, want 2", len(views))
 	}
 }
 
-func TestTotalSubscriptionsCost(t *testing.T) {
+func TestListSubscriptionsWithView(t *testing.T) {
 	mockStorage := NewMockStorage()
 	svc := NewSubscriptionService(mockStorage)
 	ctx := context.Background()
 
-	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
-
-	// Subscription 01-2024 to 12-2024, price 100
-	sub1 := &models.Subscription{
-		ID:          uuid.New(),
-		ServiceName: "Service A",
-		Price:       100,
-		UserID:      userID,
-		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-		EndDate:     timePtr(time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)),
+	owner := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+	for i, name := range []string{"Netflix", "Netflix", "Spotify"} {
+		id := uuid.New()
+		mockStorage.subscriptions[id] = &models.Subscription{ID: id, ServiceName: name, Price: 100 * (i + 1), UserID: owner, CreatedAt: time.Now()}
 	}
-	mockStorage.subscriptions[sub1.ID] = sub1
+	other := uuid.New()
+	mockStorage.subscriptions[other] = &models.Subscription{ID: other, ServiceName: "Netflix", Price: 100, UserID: uuid.New(), CreatedAt: time.Now()}
 
-	// Subscription 06-2024 onwards (no end), price 200
-	sub2 := &models.Subscription{
-		ID:          uuid.New(),
-		ServiceName: "Service B",
-		Price:       200,
-		UserID:      userID,
-		StartDate:   time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
-		EndDate:     nil,
+	view, err := svc.CreateView(ctx, apiModels.ItemByIDRequest{ID: owner.String()}, &apiModels.CreateViewRequest{Name: "Netflix", ServiceName: strPtr("Netflix")})
+	if err != nil {
+		t.Fatalf("CreateView() unexpected error: %v", err)
+	}
+	pricey, err := svc.CreateView(ctx, apiModels.ItemByIDRequest{ID: owner.String()}, &apiModels.CreateViewRequest{
+		Name: "Pricey", MinPrice: intPtr(200), SortBy: strPtr("price"), Order: strPtr("asc"),
+	})
+	if err != nil {
+		t.Fatalf("CreateView() unexpected error: %v", err)
 	}
-	mockStorage.subscriptions[sub2.ID] = sub2
 
 	tests := []struct {
-		name      string
-		req       apiModels.TotalCostRequest
-		wantTotal int64
-		wantErr   bool
+		name       string
+		req        apiModels.ListSubscriptionsRequest
+		wantLen    int
+		wantPrices []int
+		wantErr    error
 	}{
-		{
-			name: "full year 2024",
-			req: apiModels.TotalCostRequest{
-				UserID:    userID.String(),
-				StartDate: "01-2024",
-				EndDate:   "12-2024",
-			},
-			// sub1: 12 months * 100 = 1200
-			// sub2: 7 months (06-12) * 200 = 1400
-			// total = 2600
-			wantTotal: 2600,
-			wantErr:   false,
-		},
-		{
-			name: "first half of 2024",
-			req: apiModels.TotalCostRequest{
-				UserID:    userID.String(),
-				StartDate: "01-2024",
-				EndDate:   "06-2024",
-			},
-			// sub1: 6 months * 100 = 600
-			// sub2: 1 month (06) * 200 = 200
-			// total = 800
-			wantTotal: 800,
-			wantErr:   false,
-		},
-		{
-			name: "filter by service name",
-			req: apiModels.TotalCostRequest{
-				UserID:      userID.String(),
-				ServiceName: "Service A",
-				StartDate:   "01-2024",
-				EndDate:     "12-2024",
-			},
-			wantTotal: 1200,
-			wantErr:   false,
-		},
-		{
-			name: "invalid start date",
-			req: apiModels.TotalCostRequest{
-				UserID:    userID.String(),
-				StartDate: "invalid",
-				EndDate:   "12-2024",
-			},
-			wantErr: true,
-		},
-		{
-			name: "end date before start date",
-			req: apiModels.TotalCostRequest{
-				UserID:    userID.String(),
-				StartDate: "12-2024",
-				EndDate:   "01-2024",
-			},
-			wantErr: true,
-		},
+		{name: "view price range and sort", req: apiModels.ListSubscriptionsRequest{View: pricey.ID.String()}, wantPrices: []int{200, 300}},
+		{name: "explicit sort and price override view", req: apiModels.ListSubscriptionsRequest{View: pricey.ID.String(), MinPrice: intPtr(0), Order: "desc"}, wantPrices: []int{300, 200, 100}},
+		{name: "view filters", req: apiModels.ListSubscriptionsRequest{View: view.ID.String()}, wantLen: 2},
+		{name: "explicit filter overrides view", req: apiModels.ListSubscriptionsRequest{View: view.ID.String(), ServiceName: "Spotify"}, wantLen: 1},
+		{name: "explicit limit", req: apiModels.ListSubscriptionsRequest{View: view.ID.String(), Limit: intPtr(1)}, wantLen: 1},
+		{name: "view of another user", req: apiModels.ListSubscriptionsRequest{View: view.ID.String(), UserID: uuid.NewString()}, wantErr: ErrValidationError},
+		{name: "unknown view", req: apiModels.ListSubscriptionsRequest{View: uuid.NewString()}, wantErr: ErrViewNotFound},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			resp, err := svc.TotalSubscriptionsCost(ctx, tt.req)
-
-			if tt.wantErr {
-				if err == nil {
-					t.Errorf("TotalSubscriptionsCost() expected error, got nil")
+			subs, err := svc.ListSubscriptions(ctx, tt.req)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ListSubscriptions() error = %v, want %v", err, tt.wantErr)
 				}
 				return
 			}
-
 			if err != nil {
-				t.Errorf("TotalSubscriptionsCost() unexpected error: %v", err)
-				return
+				t.Fatalf("ListSubscriptions() unexpected error: %v", err)
 			}
-
-			if resp.TotalCost != tt.wantTotal {
-				t.Errorf("TotalCost = %d, want %d", resp.TotalCost, tt.wantTotal)
+			if tt.wantPrices != nil {
+				var prices []int
+				for _, item := range subs.Items {
+					prices = append(prices, item.Price)
+				}
+				if !slices.Equal(prices, tt.wantPrices) {
+					t.Errorf("ListSubscriptions() prices = %v, want %v", prices, tt.wantPrices)
+				}
+			} else if len(subs.Items) != tt.wantLen {
+				t.Errorf("ListSubscriptions() returned %d items, want %d", len(subs.Items), tt.wantLen)
 			}
 		})
 	}
@@ -954,6 +4106,59 @@ func TestCalculateSubscriptionCost(t *testing.T) {
 			endDate:   time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
 			want:      300, // 3 months (10-12) * 100
 		},
+		{
+			name: "credits clipped to period and subscription",
+			sub: models.Subscription{
+				Price:     100,
+				StartDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+				EndDate:   timePtr(time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)),
+				Credits: []models.SubscriptionCredit{
+					{Amount: -30, StartDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), EndDate: timePtr(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))},
+					{Amount: -100, StartDate: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)},
+				},
+			},
+			startDate: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			endDate:   time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+			want:      470, // 7 months (04-10) * 100 - 1 month (04) * 30 - 2 months (09-10) * 100
+		},
+		{
+			name: "percentage credit for first months",
+			sub: models.Subscription{
+				Price:     299,
+				StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				Credits: []models.SubscriptionCredit{
+					{Percent: 50, StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), EndDate: timePtr(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))},
+				},
+			},
+			startDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			endDate:   time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+			want:      3141, // 12 months * 299 - 3 months * 149 (50% truncated)
+		},
+		{
+			name: "stacked credits clamped to price",
+			sub: models.Subscription{
+				Price:     100,
+				StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				Credits: []models.SubscriptionCredit{
+					{Amount: -80, StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), EndDate: timePtr(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))},
+					{Amount: -50, StartDate: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), EndDate: timePtr(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))},
+					{Percent: 50, StartDate: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), EndDate: timePtr(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))},
+				},
+			},
+			startDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			endDate:   time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			want:      170, // 4 months * 100 - 80 (01) - 100 (02, 180 clamped) - 50 (03)
+		},
+		{
+			name: "free subscription",
+			sub: models.Subscription{
+				Price:     0,
+				StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+			startDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			endDate:   time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+			want:      0,
+		},
 	}
 
 	for _, tt := range tests {
@@ -966,6 +4171,88 @@ func TestCalculateSubscriptionCost(t *testing.T) {
 	}
 }
 
+func TestSuggestServiceNames(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.MustParse("550e8400-e29b-41d4-a716-446655440000")
+
+	mockStorage := NewMockStorage()
+	for _, name := range []string{"Netflix", "netflix", "Netflix Premium", "Spotify"} {
+		id := uuid.New()
+		mockStorage.subscriptions[id] = &models.Subscription{ID: id, ServiceName: name, Price: 100, UserID: userID}
+	}
+	svc := NewSubscriptionService(mockStorage)
+
+	tests := []struct {
+		name    string
+		req     apiModels.SuggestServicesRequest
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "case-insensitive prefix",
+			req:  apiModels.SuggestServicesRequest{Query: "NET"},
+			want: []string{"Netflix", "Netflix Premium", "netflix"},
+		},
+		{
+			name: "limit",
+			req:  apiModels.SuggestServicesRequest{Query: "net", Limit: intPtr(1)},
+			want: []string{"Netflix"},
+		},
+		{
+			name: "other user",
+			req:  apiModels.SuggestServicesRequest{Query: "net", UserID: uuid.NewString()},
+			want: []string{},
+		},
+		{
+			name:    "blank query",
+			req:     apiModels.SuggestServicesRequest{Query: "  "},
+			wantErr: true,
+		},
+		{
+			name:    "invalid user ID",
+			req:     apiModels.SuggestServicesRequest{Query: "net", UserID: "not-a-uuid"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := svc.SuggestServiceNames(ctx, tt.req)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("SuggestServiceNames() expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SuggestServiceNames() unexpected error: %v", err)
+			}
+			if strings.Join(resp.Services, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("SuggestServiceNames() = %v, want %v", resp.Services, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestServiceNamesCache(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := NewMockStorage()
+	svc := NewSubscriptionService(mockStorage)
+
+	for i := 0; i < 3; i++ {
+		if _, err := svc.SuggestServiceNames(ctx, apiModels.SuggestServicesRequest{Query: "Net"}); err != nil {
+			t.Fatalf("SuggestServiceNames() unexpected error: %v", err)
+		}
+	}
+	if _, err := svc.SuggestServiceNames(ctx, apiModels.SuggestServicesRequest{Query: "net"}); err != nil {
+		t.Fatalf("SuggestServiceNames() unexpected error: %v", err)
+	}
+
+	if mockStorage.suggestCalls != 1 {
+		t.Errorf("storage queried %d times, want 1", mockStorage.suggestCalls)
+	}
+}
+
 func strPtr(s string) *string {
 	return &s
 }
@@ -974,6 +4261,10 @@ func intPtr(i int) *int {
 	return &i
 }
 
+func boolPtr(b bool) *bool {
+	return &b
+}
+
 func timePtr(t time.Time) *time.Time {
 	return &t
 }
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"subscription-aggregator-service/internal/models"
+)
+
+// CreateBulkDeleteJob counts subscriptions matching the job's filter into Matched and stores the job
+func (ss *SubscriptionStorageImpl) CreateBulkDeleteJob(ctx context.Context, job *models.BulkDeleteJob) error {
+	return ss.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := whereBulkDelete(tx.Model(&models.Subscription{}), job).Count(&job.Matched).Error; err != nil {
+			return err
+		}
+		return tx.Create(job).Error
+	})
+}
+
+func (ss *SubscriptionStorageImpl) GetBulkDeleteJobByID(ctx context.Context, id uuid.UUID) (*models.BulkDeleteJob, error) {
+	var job models.BulkDeleteJob
+	if err := ss.db.WithContext(ctx).First(&job, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListUnfinishedBulkDeleteJobs returns pending and running jobs, oldest first
+func (ss *SubscriptionStorageImpl) ListUnfinishedBulkDeleteJobs(ctx context.Context) ([]models.BulkDeleteJob, error) {
+	var jobs []models.BulkDeleteJob
+	if err := ss.db.WithContext(ctx).Where("status IN ?", []string{models.BulkDeleteStatusPending, models.BulkDeleteStatusRunning}).
+		Order("created_at, id").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// ClaimBulkDeleteJob marks a pending job, or a running one not updated since staleBefore, as running.
+// Returns false if the job is finished or another runner is still working on it.
+func (ss *SubscriptionStorageImpl) ClaimBulkDeleteJob(ctx context.Context, id uuid.UUID, staleBefore time.Time) (bool, error) {
+	result := ss.db.WithContext(ctx).Model(&models.BulkDeleteJob{}).
+		Where("id = ?", id).
+		Where("status = ? OR (status = ? AND updated_at < ?)", models.BulkDeleteStatusPending, models.BulkDeleteStatusRunning, staleBefore).
+		Updates(map[string]any{"status": models.BulkDeleteStatusRunning, "updated_at": time.Now()})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteBulkDeleteBatch locks up to limit live subscriptions matching the job's filter, lets check reject them,
+// soft-deletes them and adds them to the job's progress, all in one transaction. Returns the deleted subscriptions,
+// none means nothing is left to delete. Rows locked by other transactions are waited for rather than skipped,
+// but one changed meanwhile drops out of the batch, so a short batch doesn't mean the job is done.
+func (ss *SubscriptionStorageImpl) DeleteBulkDeleteBatch(ctx context.Context, job *models.BulkDeleteJob, limit int, check func(batch []models.Subscription) error) ([]models.Subscription, error) {
+	var batch []models.Subscription
+	err := ss.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := whereBulkDelete(tx.Clauses(clause.Locking{Strength: "UPDATE"}), job)
+		if err := query.Order("id").Limit(limit).Find(&batch).Error; err != nil {
+			return err
+		}
+		if err := check(batch); err != nil {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(batch))
+		for i, sub := range batch {
+			ids[i] = sub.ID
+		}
+		if len(ids) > 0 {
+			if err := tx.Delete(&models.Subscription{}, "id IN ?", ids).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.BulkDeleteJob{}).Where("id = ?", job.ID).
+			Updates(map[string]any{"deleted": gorm.Expr("deleted + ?", len(ids)), "updated_at": time.Now()}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// FinishBulkDeleteJob sets the final status of a job, errMsg is recorded for failed ones
+func (ss *SubscriptionStorageImpl) FinishBulkDeleteJob(ctx context.Context, id uuid.UUID, status string, errMsg *string) error {
+	now := time.Now()
+	return ss.db.WithContext(ctx).Model(&models.BulkDeleteJob{}).Where("id = ?", id).
+		Updates(map[string]any{"status": status, "error": errMsg, "updated_at": now, "finished_at": now}).Error
+}
+
+// whereBulkDelete narrows a query on subscriptions to live ones matching the job's filter
+func whereBulkDelete(query *gorm.DB, job *models.BulkDeleteJob) *gorm.DB {
+	if job.UserID != nil {
+		query = query.Where("user_id = ?", *job.UserID)
+	}
+	if job.ServiceName != nil {
+		query = query.Where("service_name = ?", *job.ServiceName)
+	}
+	if job.StartDate != nil {
+		query = query.Where("start_date >= ?", *job.StartDate)
+	}
+	if job.EndDate != nil {
+		query = query.Where("end_date <= ?", *job.EndDate) // Open-ended subscriptions don't fit into a closed range
+	}
+	return query
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"subscription-aggregator-service/internal/models"
+)
+
+// renewedCondition holds for a subscription continued by another live one of the same user and service in any case,
+// starting after it and no later than the month after its end, and ending later if at all
+const renewedCondition = `EXISTS (SELECT 1 FROM subscriptions next WHERE next.deleted_at IS NULL AND next.id <> subscriptions.id
+	AND next.user_id = subscriptions.user_id AND lower(next.service_name) = lower(subscriptions.service_name)
+	AND next.start_date > subscriptions.start_date AND next.start_date <= subscriptions.end_date + interval '1 month'
+	AND (next.end_date IS NULL OR next.end_date > subscriptions.end_date))`
+
+// ListExpiringSubscriptions returns live recurring subscriptions ending in [from, to] ordered by user and end date,
+// only renewed or only not renewed ones if renewed is not nil
+func (ss *SubscriptionStorageImpl) ListExpiringSubscriptions(ctx context.Context, from, to time.Time, renewed *bool) ([]models.Subscription, error) {
+	query := ss.db.WithContext(ctx).Model(&models.Subscription{}).
+		Where("type = ?", models.TypeRecurring).
+		Where("end_date BETWEEN ? AND ?", from, to)
+	if renewed != nil {
+		if *renewed {
+			query = query.Where(renewedCondition)
+		} else {
+			query = query.Where("NOT " + renewedCondition)
+		}
+	}
+
+	var subs []models.Subscription
+	if err := query.Order("user_id, end_date, service_name, id").Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
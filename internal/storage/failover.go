@@ -0,0 +1,482 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"subscription-aggregator-service/internal/logger"
+	"subscription-aggregator-service/internal/models"
+)
+
+// ErrUnavailable wraps errors of a database that can't serve requests right now, e.g. during failover
+var ErrUnavailable = errors.New("storage unavailable")
+
+// defaultMaxIdleConns is what database/sql keeps idle unless told otherwise, pkg/postgres doesn't change it
+const defaultMaxIdleConns = 2
+
+// FailoverStorage wraps a storage and rides out database failovers: idempotent reads are retried with backoff
+// after dropping idle connections to the old primary, writes only if the failed statement never reached the server.
+// Errors of an unavailable database are returned wrapped in ErrUnavailable, others as is.
+type FailoverStorage struct {
+	next     SubscriptionStorage
+	pool     *sql.DB // Nil disables dropping idle connections
+	attempts int
+	backoff  time.Duration
+}
+
+// NewFailoverStorage makes up to attempts calls, waiting backoff before the second one and twice longer before each next
+func NewFailoverStorage(next SubscriptionStorage, pool *sql.DB, attempts int, backoff time.Duration) *FailoverStorage {
+	return &FailoverStorage{next: next, pool: pool, attempts: max(attempts, 1), backoff: backoff}
+}
+
+func (fs *FailoverStorage) read(ctx context.Context, op string, call func() error) error {
+	return fs.call(ctx, op, call, func(error) bool { return true })
+}
+
+func (fs *FailoverStorage) write(ctx context.Context, op string, call func() error) error {
+	return fs.call(ctx, op, call, pgconn.SafeToRetry)
+}
+
+func (fs *FailoverStorage) call(ctx context.Context, op string, call func() error, retryable func(error) bool) error {
+	log := logger.FromContext(ctx)
+	backoff := fs.backoff
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || !isUnavailable(err) {
+			return err
+		}
+		fs.dropIdle()
+		if attempt >= fs.attempts || !retryable(err) {
+			log.Error("database unavailable", "op", op, "attempts", attempt, "error", err)
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+
+		log.Warn("database unavailable, retrying", "op", op, "attempt", attempt, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// dropIdle closes idle connections, after a failover they point to the old primary and would fail one by one
+func (fs *FailoverStorage) dropIdle() {
+	if fs.pool != nil {
+		fs.pool.SetMaxIdleConns(0)
+		fs.pool.SetMaxIdleConns(defaultMaxIdleConns)
+	}
+}
+
+// isUnavailable tells if err means the database is down, restarting or demoted rather than rejecting the query itself
+func isUnavailable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "57P01", "57P02", "57P03": // admin_shutdown, crash_shutdown, cannot_connect_now
+			return true
+		case "25006": // read_only_sql_transaction: connected to the old primary, already a replica
+			return true
+		}
+		return strings.HasPrefix(pgErr.Code, "08") // Connection exceptions
+	}
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	return errors.As(err, &connectErr) || errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+}
+
+func (fs *FailoverStorage) CreateSubscription(ctx context.Context, s *models.Subscription) error {
+	return fs.write(ctx, "CreateSubscription", func() error { return fs.next.CreateSubscription(ctx, s) })
+}
+
+func (fs *FailoverStorage) CreateSubscriptionIfAbsent(ctx context.Context, s *models.Subscription) (*models.Subscription, bool, error) {
+	var r1 *models.Subscription
+	var r2 bool
+	err := fs.write(ctx, "CreateSubscriptionIfAbsent", func() (err error) {
+		r1, r2, err = fs.next.CreateSubscriptionIfAbsent(ctx, s)
+		return err
+	})
+	return r1, r2, err
+}
+
+func (fs *FailoverStorage) CreateSubscriptions(ctx context.Context, subs []*models.Subscription) error {
+	return fs.write(ctx, "CreateSubscriptions", func() error { return fs.next.CreateSubscriptions(ctx, subs) })
+}
+
+func (fs *FailoverStorage) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	var r *models.Subscription
+	err := fs.read(ctx, "GetSubscriptionByID", func() (err error) {
+		r, err = fs.next.GetSubscriptionByID(ctx, id)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) SubscriptionExistsByID(ctx context.Context, id uuid.UUID) (bool, error) {
+	var r bool
+	err := fs.read(ctx, "SubscriptionExistsByID", func() (err error) {
+		r, err = fs.next.SubscriptionExistsByID(ctx, id)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) FindOverlappingSubscription(ctx context.Context, s *models.Subscription) (*models.Subscription, error) {
+	var r *models.Subscription
+	err := fs.read(ctx, "FindOverlappingSubscription", func() (err error) {
+		r, err = fs.next.FindOverlappingSubscription(ctx, s)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) UpdateSubscriptionByID(ctx context.Context, s *models.Subscription) error {
+	return fs.write(ctx, "UpdateSubscriptionByID", func() error { return fs.next.UpdateSubscriptionByID(ctx, s) })
+}
+
+func (fs *FailoverStorage) UpdateSubscriptionsPrice(ctx context.Context, filter models.SubscriptionFilter, price int, month time.Time, check func(before []models.Subscription) error) ([]models.Subscription, []models.Subscription, error) {
+	var before, after []models.Subscription
+	err := fs.write(ctx, "UpdateSubscriptionsPrice", func() (err error) {
+		before, after, err = fs.next.UpdateSubscriptionsPrice(ctx, filter, price, month, check)
+		return err
+	})
+	return before, after, err
+}
+
+func (fs *FailoverStorage) DeleteSubscriptionByID(ctx context.Context, id uuid.UUID) error {
+	return fs.write(ctx, "DeleteSubscriptionByID", func() error { return fs.next.DeleteSubscriptionByID(ctx, id) })
+}
+
+func (fs *FailoverStorage) DeleteSubscriptionWithUndo(ctx context.Context, id uuid.UUID, expiresAt time.Time) (*models.UndoToken, error) {
+	var r *models.UndoToken
+	err := fs.write(ctx, "DeleteSubscriptionWithUndo", func() (err error) {
+		r, err = fs.next.DeleteSubscriptionWithUndo(ctx, id, expiresAt)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) UndoDeletion(ctx context.Context, token uuid.UUID, check func(sub *models.Subscription) error) (*models.Subscription, error) {
+	var r *models.Subscription
+	err := fs.write(ctx, "UndoDeletion", func() (err error) {
+		r, err = fs.next.UndoDeletion(ctx, token, check)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) PurgeSubscription(ctx context.Context, id uuid.UUID) (*models.PurgeRecord, error) {
+	var r *models.PurgeRecord
+	err := fs.write(ctx, "PurgeSubscription", func() (err error) {
+		r, err = fs.next.PurgeSubscription(ctx, id)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) PurgeUserData(ctx context.Context, userID uuid.UUID) (*models.PurgeRecord, error) {
+	var r *models.PurgeRecord
+	err := fs.write(ctx, "PurgeUserData", func() (err error) {
+		r, err = fs.next.PurgeUserData(ctx, userID)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) CreateBulkDeleteJob(ctx context.Context, job *models.BulkDeleteJob) error {
+	return fs.write(ctx, "CreateBulkDeleteJob", func() error { return fs.next.CreateBulkDeleteJob(ctx, job) })
+}
+
+func (fs *FailoverStorage) GetBulkDeleteJobByID(ctx context.Context, id uuid.UUID) (*models.BulkDeleteJob, error) {
+	var r *models.BulkDeleteJob
+	err := fs.read(ctx, "GetBulkDeleteJobByID", func() (err error) {
+		r, err = fs.next.GetBulkDeleteJobByID(ctx, id)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) ListUnfinishedBulkDeleteJobs(ctx context.Context) ([]models.BulkDeleteJob, error) {
+	var r []models.BulkDeleteJob
+	err := fs.read(ctx, "ListUnfinishedBulkDeleteJobs", func() (err error) {
+		r, err = fs.next.ListUnfinishedBulkDeleteJobs(ctx)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) ClaimBulkDeleteJob(ctx context.Context, id uuid.UUID, staleBefore time.Time) (bool, error) {
+	var r bool
+	err := fs.write(ctx, "ClaimBulkDeleteJob", func() (err error) {
+		r, err = fs.next.ClaimBulkDeleteJob(ctx, id, staleBefore)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) DeleteBulkDeleteBatch(ctx context.Context, job *models.BulkDeleteJob, limit int, check func(batch []models.Subscription) error) ([]models.Subscription, error) {
+	var r []models.Subscription
+	err := fs.write(ctx, "DeleteBulkDeleteBatch", func() (err error) {
+		r, err = fs.next.DeleteBulkDeleteBatch(ctx, job, limit, check)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) FinishBulkDeleteJob(ctx context.Context, id uuid.UUID, status string, errMsg *string) error {
+	return fs.write(ctx, "FinishBulkDeleteJob", func() error { return fs.next.FinishBulkDeleteJob(ctx, id, status, errMsg) })
+}
+
+func (fs *FailoverStorage) SyncUserSubscriptions(ctx context.Context, userID uuid.UUID, plan func(current []models.Subscription) (*models.SyncPlan, error)) (*models.SyncPlan, error) {
+	var r *models.SyncPlan
+	err := fs.write(ctx, "SyncUserSubscriptions", func() (err error) {
+		r, err = fs.next.SyncUserSubscriptions(ctx, userID, plan)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) ListSubscriptions(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, int64, error) {
+	var r1 []models.Subscription
+	var r2 int64
+	err := fs.read(ctx, "ListSubscriptions", func() (err error) {
+		r1, r2, err = fs.next.ListSubscriptions(ctx, filter)
+		return err
+	})
+	return r1, r2, err
+}
+
+func (fs *FailoverStorage) TotalSubscriptionsCost(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time) (int64, error) {
+	var r int64
+	err := fs.read(ctx, "TotalSubscriptionsCost", func() (err error) {
+		r, err = fs.next.TotalSubscriptionsCost(ctx, filter, startDate, endDate)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) TotalSubscriptionsCostByUser(ctx context.Context, filter models.SubscriptionFilter, userIDs []uuid.UUID, startDate, endDate time.Time) (map[uuid.UUID]int64, error) {
+	var r map[uuid.UUID]int64
+	err := fs.read(ctx, "TotalSubscriptionsCostByUser", func() (err error) {
+		r, err = fs.next.TotalSubscriptionsCostByUser(ctx, filter, userIDs, startDate, endDate)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) ListSubscriptionsInPeriod(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time) ([]models.Subscription, error) {
+	var r []models.Subscription
+	err := fs.read(ctx, "ListSubscriptionsInPeriod", func() (err error) {
+		r, err = fs.next.ListSubscriptionsInPeriod(ctx, filter, startDate, endDate)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) ListExpiringSubscriptions(ctx context.Context, from, to time.Time, renewed *bool) ([]models.Subscription, error) {
+	var r []models.Subscription
+	err := fs.read(ctx, "ListExpiringSubscriptions", func() (err error) {
+		r, err = fs.next.ListExpiringSubscriptions(ctx, from, to, renewed)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) UserSummary(ctx context.Context, userID uuid.UUID, month time.Time) (*models.UserSummary, error) {
+	var r *models.UserSummary
+	err := fs.read(ctx, "UserSummary", func() (err error) {
+		r, err = fs.next.UserSummary(ctx, userID, month)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) SuggestServiceNames(ctx context.Context, prefix string, userID *uuid.UUID, limit int) ([]string, error) {
+	var r []string
+	err := fs.read(ctx, "SuggestServiceNames", func() (err error) {
+		r, err = fs.next.SuggestServiceNames(ctx, prefix, userID, limit)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) CreateView(ctx context.Context, v *models.SavedView) error {
+	return fs.write(ctx, "CreateView", func() error { return fs.next.CreateView(ctx, v) })
+}
+
+func (fs *FailoverStorage) GetViewByID(ctx context.Context, id uuid.UUID) (*models.SavedView, error) {
+	var r *models.SavedView
+	err := fs.read(ctx, "GetViewByID", func() (err error) {
+		r, err = fs.next.GetViewByID(ctx, id)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) ListViews(ctx context.Context, userID uuid.UUID) ([]models.SavedView, error) {
+	var r []models.SavedView
+	err := fs.read(ctx, "ListViews", func() (err error) {
+		r, err = fs.next.ListViews(ctx, userID)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) CreatePlan(ctx context.Context, p *models.Plan) error {
+	return fs.write(ctx, "CreatePlan", func() error { return fs.next.CreatePlan(ctx, p) })
+}
+
+func (fs *FailoverStorage) GetPlanByID(ctx context.Context, id uuid.UUID) (*models.Plan, error) {
+	var r *models.Plan
+	err := fs.read(ctx, "GetPlanByID", func() (err error) {
+		r, err = fs.next.GetPlanByID(ctx, id)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) ListPlans(ctx context.Context) ([]models.Plan, error) {
+	var r []models.Plan
+	err := fs.read(ctx, "ListPlans", func() (err error) {
+		r, err = fs.next.ListPlans(ctx)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) UpdatePlanPrice(ctx context.Context, id uuid.UUID, price int, month time.Time, check func(before []models.Subscription) error) (*models.Plan, []models.Subscription, []models.Subscription, error) {
+	var plan *models.Plan
+	var before, after []models.Subscription
+	err := fs.write(ctx, "UpdatePlanPrice", func() (err error) {
+		plan, before, after, err = fs.next.UpdatePlanPrice(ctx, id, price, month, check)
+		return err
+	})
+	return plan, before, after, err
+}
+
+func (fs *FailoverStorage) CreateOrgUnit(ctx context.Context, u *models.OrgUnit) error {
+	return fs.write(ctx, "CreateOrgUnit", func() error { return fs.next.CreateOrgUnit(ctx, u) })
+}
+
+func (fs *FailoverStorage) GetOrgUnitByID(ctx context.Context, id uuid.UUID) (*models.OrgUnit, error) {
+	var r *models.OrgUnit
+	err := fs.read(ctx, "GetOrgUnitByID", func() (err error) {
+		r, err = fs.next.GetOrgUnitByID(ctx, id)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) ListOrgUnits(ctx context.Context) ([]models.OrgUnit, error) {
+	var r []models.OrgUnit
+	err := fs.read(ctx, "ListOrgUnits", func() (err error) {
+		r, err = fs.next.ListOrgUnits(ctx)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) TotalSubscriptionsCostByOrgUnit(ctx context.Context, unitIDs []uuid.UUID, startDate, endDate time.Time) (map[uuid.UUID]int64, error) {
+	var r map[uuid.UUID]int64
+	err := fs.read(ctx, "TotalSubscriptionsCostByOrgUnit", func() (err error) {
+		r, err = fs.next.TotalSubscriptionsCostByOrgUnit(ctx, unitIDs, startDate, endDate)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) CountSubscriptionsByOrgUnit(ctx context.Context, unitIDs []uuid.UUID, month time.Time) (map[uuid.UUID]models.SubscriptionCounts, error) {
+	var r map[uuid.UUID]models.SubscriptionCounts
+	err := fs.read(ctx, "CountSubscriptionsByOrgUnit", func() (err error) {
+		r, err = fs.next.CountSubscriptionsByOrgUnit(ctx, unitIDs, month)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) CreateCredit(ctx context.Context, c *models.SubscriptionCredit) error {
+	return fs.write(ctx, "CreateCredit", func() error { return fs.next.CreateCredit(ctx, c) })
+}
+
+func (fs *FailoverStorage) ListCredits(ctx context.Context, subscriptionID uuid.UUID) ([]models.SubscriptionCredit, error) {
+	var r []models.SubscriptionCredit
+	err := fs.read(ctx, "ListCredits", func() (err error) {
+		r, err = fs.next.ListCredits(ctx, subscriptionID)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) CreatePausedPeriod(ctx context.Context, p *models.PausedPeriod) error {
+	return fs.write(ctx, "CreatePausedPeriod", func() error { return fs.next.CreatePausedPeriod(ctx, p) })
+}
+
+func (fs *FailoverStorage) ResumePausedPeriod(ctx context.Context, subscriptionID uuid.UUID, endDate time.Time) (*models.PausedPeriod, error) {
+	var r *models.PausedPeriod
+	err := fs.write(ctx, "ResumePausedPeriod", func() (err error) {
+		r, err = fs.next.ResumePausedPeriod(ctx, subscriptionID, endDate)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) ListPausedPeriods(ctx context.Context, subscriptionID uuid.UUID) ([]models.PausedPeriod, error) {
+	var r []models.PausedPeriod
+	err := fs.read(ctx, "ListPausedPeriods", func() (err error) {
+		r, err = fs.next.ListPausedPeriods(ctx, subscriptionID)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) CreateServiceRename(ctx context.Context, r *models.ServiceRename) error {
+	return fs.write(ctx, "CreateServiceRename", func() error { return fs.next.CreateServiceRename(ctx, r) })
+}
+
+func (fs *FailoverStorage) RenameServiceBatch(ctx context.Context, r *models.ServiceRename, unitIDs []uuid.UUID, limit int, check func(batch []models.Subscription) error) ([]models.Subscription, error) {
+	var batch []models.Subscription
+	err := fs.write(ctx, "RenameServiceBatch", func() (err error) {
+		batch, err = fs.next.RenameServiceBatch(ctx, r, unitIDs, limit, check)
+		return err
+	})
+	return batch, err
+}
+
+func (fs *FailoverStorage) FinishServiceRename(ctx context.Context, id uuid.UUID) error {
+	return fs.write(ctx, "FinishServiceRename", func() error { return fs.next.FinishServiceRename(ctx, id) })
+}
+
+func (fs *FailoverStorage) CheckIntegrity(ctx context.Context) (bool, error) {
+	var r bool
+	err := fs.write(ctx, "CheckIntegrity", func() (err error) {
+		r, err = fs.next.CheckIntegrity(ctx)
+		return err
+	})
+	return r, err
+}
+
+func (fs *FailoverStorage) ListIntegrityFindings(ctx context.Context) ([]models.IntegrityFinding, error) {
+	var r []models.IntegrityFinding
+	err := fs.read(ctx, "ListIntegrityFindings", func() (err error) {
+		r, err = fs.next.ListIntegrityFindings(ctx)
+		return err
+	})
+	return r, err
+}
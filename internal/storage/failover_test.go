@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"subscription-aggregator-service/internal/models"
+)
+
+// flakyStorage fails calls with errs in order, then succeeds
+type flakyStorage struct {
+	SubscriptionStorage
+	errs  []error
+	calls int
+}
+
+func (f *flakyStorage) fail() error {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return f.errs[f.calls-1]
+	}
+	return nil
+}
+
+func (f *flakyStorage) GetSubscriptionByID(_ context.Context, id uuid.UUID) (*models.Subscription, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return &models.Subscription{ID: id}, nil
+}
+
+func (f *flakyStorage) CreateSubscription(context.Context, *models.Subscription) error {
+	return f.fail()
+}
+
+// safeToRetryError is what pgconn returns when a statement failed before reaching the server
+type safeToRetryError struct{ error }
+
+func (safeToRetryError) SafeToRetry() bool { return true }
+func (e safeToRetryError) Unwrap() error   { return e.error }
+
+func TestFailoverStorage(t *testing.T) {
+	ctx := context.Background()
+	shutdown := &pgconn.PgError{Code: "57P01", Message: "terminating connection due to administrator command"}
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+	tests := []struct {
+		name        string
+		write       bool
+		errs        []error
+		wantCalls   int
+		wantErr     error
+		unavailable bool
+	}{
+		{name: "read retried until failover is over", errs: []error{shutdown, refused}, wantCalls: 3},
+		{name: "read gives up after attempts", errs: []error{shutdown, refused, refused}, wantCalls: 3, unavailable: true},
+		{name: "query error not retried", errs: []error{ErrNotFound}, wantCalls: 1, wantErr: ErrNotFound},
+		{name: "write not retried once sent", write: true, errs: []error{io.ErrUnexpectedEOF}, wantCalls: 1, unavailable: true},
+		{name: "write retried if never sent", write: true, errs: []error{safeToRetryError{refused}}, wantCalls: 2},
+		{name: "write rejected by demoted primary", write: true, errs: []error{&pgconn.PgError{Code: "25006"}}, wantCalls: 1, unavailable: true},
+		{name: "unique violation passed as is", write: true, errs: []error{ErrAlreadyExists}, wantCalls: 1, wantErr: ErrAlreadyExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &flakyStorage{errs: tt.errs}
+			fs := NewFailoverStorage(next, nil, 3, 0)
+
+			var err error
+			if tt.write {
+				err = fs.CreateSubscription(ctx, &models.Subscription{})
+			} else {
+				_, err = fs.GetSubscriptionByID(ctx, uuid.New())
+			}
+
+			if next.calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", next.calls, tt.wantCalls)
+			}
+			if got := errors.Is(err, ErrUnavailable); got != tt.unavailable {
+				t.Errorf("error = %v, unavailable = %v, want %v", err, got, tt.unavailable)
+			}
+			if !tt.unavailable && !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsUnavailable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&pgconn.PgError{Code: "57P01"}, true},
+		{&pgconn.PgError{Code: "08006"}, true},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{fmt.Errorf("query: %w", syscall.ECONNREFUSED), true},
+		{io.ErrUnexpectedEOF, true},
+		{context.DeadlineExceeded, false},
+		{&net.OpError{Op: "read", Err: context.Canceled}, false},
+		{ErrNotFound, false},
+	}
+
+	for _, tt := range tests {
+		if got := isUnavailable(tt.err); got != tt.want {
+			t.Errorf("isUnavailable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
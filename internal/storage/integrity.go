@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"subscription-aggregator-service/internal/models"
+)
+
+// integrityLockKey is the advisory lock held while checking, so replicas running the job at once don't race
+const integrityLockKey = 0x5ab5c41
+
+// integrityChecks are conditions that must never hold for a live subscription, with a description of the offending row.
+// Users and rollups aren't stored by the service, so orphaned user IDs and total mismatches can't be checked here.
+var integrityChecks = []struct {
+	name, condition, details string
+}{
+	{
+		name:      "end_before_start",
+		condition: "end_date < start_date",
+		details:   "format('end_date %s precedes start_date %s', end_date, start_date)",
+	},
+	{
+		name: "credit_outside_subscription", // E.g. the subscription was shortened after crediting
+		condition: `EXISTS (SELECT 1 FROM subscription_credits c WHERE c.subscription_id = subscriptions.id AND
+			(c.start_date < start_date OR COALESCE(c.end_date, 'infinity'::date) > COALESCE(end_date, 'infinity'::date)))`,
+		details: "'credit period exceeds subscription period'",
+	},
+	{
+		name:      "not_month_start",
+		condition: "start_date <> date_trunc('month', start_date)::date OR end_date <> date_trunc('month', end_date)::date",
+		details:   "format('start_date %s, end_date %s, both must be first days of months', start_date, coalesce(end_date::text, 'none'))",
+	},
+}
+
+// CheckIntegrity runs all checks and replaces recorded findings with their results in one transaction.
+// Findings keep their detection time while they persist and disappear once fixed.
+// Returns false without checking if another instance is already running the check.
+func (ss *SubscriptionStorageImpl) CheckIntegrity(ctx context.Context) (bool, error) {
+	locked := false
+	err := ss.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", integrityLockKey).Scan(&locked).Error; err != nil || !locked {
+			return err
+		}
+
+		now := time.Now().Truncate(time.Microsecond) // Postgres precision, so fresh rows aren't older than now
+		for _, check := range integrityChecks {
+			if err := tx.Exec(`INSERT INTO integrity_findings (check_name, subscription_id, details, detected_at, checked_at)
+				SELECT ?, id, `+check.details+`, ?, ? FROM subscriptions WHERE deleted_at IS NULL AND (`+check.condition+`)
+				ON CONFLICT (check_name, subscription_id) DO UPDATE SET details = EXCLUDED.details, checked_at = EXCLUDED.checked_at`,
+				check.name, now, now).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("checked_at < ?", now).Delete(&models.IntegrityFinding{}).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	return locked, nil
+}
+
+func (ss *SubscriptionStorageImpl) ListIntegrityFindings(ctx context.Context) ([]models.IntegrityFinding, error) {
+	var findings []models.IntegrityFinding
+	if err := ss.db.WithContext(ctx).Order("check_name, detected_at, subscription_id").Find(&findings).Error; err != nil {
+		return nil, err
+	}
+	return findings, nil
+}
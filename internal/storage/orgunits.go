@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+
+	"subscription-aggregator-service/internal/models"
+)
+
+func (ss *SubscriptionStorageImpl) CreateOrgUnit(ctx context.Context, u *models.OrgUnit) error {
+	if err := ss.db.WithContext(ctx).Create(u).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return ErrAlreadyExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (ss *SubscriptionStorageImpl) GetOrgUnitByID(ctx context.Context, id uuid.UUID) (*models.OrgUnit, error) {
+	var u models.OrgUnit
+	if err := ss.db.WithContext(ctx).First(&u, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (ss *SubscriptionStorageImpl) ListOrgUnits(ctx context.Context) ([]models.OrgUnit, error) {
+	var units []models.OrgUnit
+	if err := ss.db.WithContext(ctx).Order("name, id").Find(&units).Error; err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+// TotalSubscriptionsCostByOrgUnit computes cost of subscriptions attributed directly to each of unitIDs, descendants aren't included.
+// Units without subscriptions in the period are absent from the result.
+func (ss *SubscriptionStorageImpl) TotalSubscriptionsCostByOrgUnit(ctx context.Context, unitIDs []uuid.UUID, startDate, endDate time.Time) (map[uuid.UUID]int64, error) {
+	return ss.totalsGroupedBy(ctx, "org_unit_id", unitIDs, models.SubscriptionFilter{}, startDate, endDate)
+}
+
+// CountSubscriptionsByOrgUnit counts live subscriptions attributed directly to each of unitIDs and ones of them active in month.
+// Units without subscriptions are absent from the result.
+func (ss *SubscriptionStorageImpl) CountSubscriptionsByOrgUnit(ctx context.Context, unitIDs []uuid.UUID, month time.Time) (map[uuid.UUID]models.SubscriptionCounts, error) {
+	type unitCounts struct {
+		ID     uuid.UUID
+		Total  int64
+		Active int64
+	}
+
+	var rows []unitCounts
+	if err := ss.db.WithContext(ctx).Model(&models.Subscription{}).
+		Select("org_unit_id AS id, COUNT(*) AS total, COUNT(*) FILTER (WHERE start_date <= ? AND (end_date IS NULL OR end_date >= ?)) AS active", month, month).
+		Where("org_unit_id IN ?", unitIDs).Group("org_unit_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uuid.UUID]models.SubscriptionCounts, len(rows))
+	for _, r := range rows {
+		counts[r.ID] = models.SubscriptionCounts{Total: r.Total, Active: r.Active}
+	}
+	return counts, nil
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"subscription-aggregator-service/internal/models"
+)
+
+// pausedMonthsJoin expands every pause (aliased p) of a subscription (aliased s) into months m it shares with the subscription
+// and [startDate, endDate], args are pausedMonthsArgs
+const pausedMonthsJoin = `
+	CROSS JOIN LATERAL generate_series(
+		GREATEST(p.start_date, s.start_date, ?)::timestamp,
+		LEAST(COALESCE(p.end_date, ?), COALESCE(s.end_date, ?), ?)::timestamp,
+		interval '1 month'
+	) AS m(month)
+`
+
+func pausedMonthsArgs(startDate, endDate time.Time) []any {
+	return []any{startDate, endDate, endDate, endDate}
+}
+
+// pausedSQL sums what the paused months would have cost, the price and the credits of each month of pausedMonthsJoin,
+// the credits clamped to the price as in creditsSQL. Pauses of a subscription don't overlap,
+// so subtracting it from costSQL and creditsSQL leaves paused months out.
+const pausedSQL = `
+	COALESCE(SUM(
+		s.price + GREATEST(COALESCE((
+			SELECT SUM(c.amount - s.price * c.percent / 100)
+			FROM subscription_credits AS c
+			WHERE c.subscription_id = s.id AND c.start_date <= m.month AND (c.end_date IS NULL OR c.end_date >= m.month)
+		), 0), -s.price)
+	), 0)
+`
+
+// pausedMonths is a query over months of live subscriptions' pauses within [startDate, endDate] for pausedSQL
+func (ss *SubscriptionStorageImpl) pausedMonths(ctx context.Context, startDate, endDate time.Time) *gorm.DB {
+	return ss.db.WithContext(ctx).Table("paused_periods AS p").
+		Joins("JOIN subscriptions AS s ON s.id = p.subscription_id AND s.deleted_at IS NULL").
+		Joins(pausedMonthsJoin, pausedMonthsArgs(startDate, endDate)...)
+}
+
+// totalPaused sums the cost of paused months of subscriptions matching filter over [startDate, endDate]
+func (ss *SubscriptionStorageImpl) totalPaused(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time) (int64, error) {
+	query := ss.pausedMonths(ctx, startDate, endDate)
+
+	if filter.UserID != nil {
+		query = query.Where("s.user_id = ?", *filter.UserID)
+	}
+	query = whereServiceName(query, "s.service_name", filter)
+
+	var total int64
+	if err := query.Select(pausedSQL).Scan(&total).Error; err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// CreatePausedPeriod stores a pause, ErrAlreadyExists if the subscription already has an open one
+func (ss *SubscriptionStorageImpl) CreatePausedPeriod(ctx context.Context, p *models.PausedPeriod) error {
+	if err := ss.db.WithContext(ctx).Create(p).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return ErrAlreadyExists
+		}
+		return err
+	}
+	return nil
+}
+
+// ResumePausedPeriod ends the open pause of the subscription with endDate, ErrNotFound if it isn't paused.
+// A pause ending before it starts paused nothing and is deleted, it's returned with EndDate set either way.
+func (ss *SubscriptionStorageImpl) ResumePausedPeriod(ctx context.Context, subscriptionID uuid.UUID, endDate time.Time) (*models.PausedPeriod, error) {
+	var pause models.PausedPeriod
+	err := ss.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&pause, "subscription_id = ? AND end_date IS NULL", subscriptionID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		pause.EndDate = &endDate
+		if endDate.Before(pause.StartDate) {
+			return tx.Delete(&models.PausedPeriod{}, "id = ?", pause.ID).Error
+		}
+		return tx.Model(&models.PausedPeriod{}).Where("id = ?", pause.ID).Update("end_date", endDate).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pause, nil
+}
+
+// ListPausedPeriods returns pauses of the subscription, oldest first
+func (ss *SubscriptionStorageImpl) ListPausedPeriods(ctx context.Context, subscriptionID uuid.UUID) ([]models.PausedPeriod, error) {
+	var pauses []models.PausedPeriod
+	if err := ss.db.WithContext(ctx).Where("subscription_id = ?", subscriptionID).Order("start_date").Find(&pauses).Error; err != nil {
+		return nil, err
+	}
+	return pauses, nil
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"subscription-aggregator-service/internal/models"
+)
+
+func (ss *SubscriptionStorageImpl) CreatePlan(ctx context.Context, p *models.Plan) error {
+	if err := ss.db.WithContext(ctx).Create(p).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return ErrAlreadyExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (ss *SubscriptionStorageImpl) GetPlanByID(ctx context.Context, id uuid.UUID) (*models.Plan, error) {
+	var p models.Plan
+	if err := ss.db.WithContext(ctx).First(&p, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (ss *SubscriptionStorageImpl) ListPlans(ctx context.Context) ([]models.Plan, error) {
+	var plans []models.Plan
+	if err := ss.db.WithContext(ctx).Order("service_name, name").Find(&plans).Error; err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
+
+// UpdatePlanPrice changes the official plan price and gives it to subscriptions following the plan that run in month or later,
+// in one transaction, unless check rejects their change. Ended ones keep the price they had.
+// Returns the updated plan and the subscriptions as they were before and with the new price, in the same order.
+func (ss *SubscriptionStorageImpl) UpdatePlanPrice(ctx context.Context, id uuid.UUID, price int, month time.Time, check func(before []models.Subscription) error) (*models.Plan, []models.Subscription, []models.Subscription, error) {
+	var plan models.Plan
+	var before, after []models.Subscription
+	err := ss.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&plan).Clauses(clause.Returning{}).Where("id = ?", id).
+			Updates(map[string]any{"price": price, "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("plan_id = ? AND follow_plan_price AND price <> ?", id, price).
+			Where("end_date IS NULL OR end_date >= ?", month).
+			Order("id").Find(&before).Error
+		if err != nil {
+			return err
+		}
+		if err = check(before); err != nil {
+			return err
+		}
+		after, err = reprice(tx, before, price, true)
+		return err
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return &plan, before, after, nil
+}
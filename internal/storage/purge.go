@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"subscription-aggregator-service/internal/models"
+)
+
+// PurgeSubscription permanently deletes the subscription, soft-deleted or not, with its credits, pauses and integrity findings,
+// and records the purge, all in one transaction. Undo tokens go away by cascade.
+func (ss *SubscriptionStorageImpl) PurgeSubscription(ctx context.Context, id uuid.UUID) (*models.PurgeRecord, error) {
+	record := models.PurgeRecord{Subject: models.PurgeSubjectSubscription, SubjectID: id}
+	err := ss.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := purgeSubscriptionRows(tx, []uuid.UUID{id}); err != nil {
+			return err
+		}
+		result := tx.Unscoped().Delete(&models.Subscription{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		record.Subscriptions = int(result.RowsAffected)
+		return tx.Create(&record).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// PurgeUserData permanently deletes all subscriptions of the user, soft-deleted included, and their saved views,
+// and records the purge, all in one transaction. Purging a user without data still succeeds and is recorded.
+func (ss *SubscriptionStorageImpl) PurgeUserData(ctx context.Context, userID uuid.UUID) (*models.PurgeRecord, error) {
+	record := models.PurgeRecord{Subject: models.PurgeSubjectUser, SubjectID: userID}
+	err := ss.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []uuid.UUID
+		if err := tx.Unscoped().Model(&models.Subscription{}).Where("user_id = ?", userID).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if err := purgeSubscriptionRows(tx, ids); err != nil {
+			return err
+		}
+		result := tx.Unscoped().Delete(&models.Subscription{}, "user_id = ?", userID)
+		if result.Error != nil {
+			return result.Error
+		}
+		record.Subscriptions = int(result.RowsAffected)
+
+		result = tx.Delete(&models.SavedView{}, "user_id = ?", userID)
+		if result.Error != nil {
+			return result.Error
+		}
+		record.Views = int(result.RowsAffected)
+		return tx.Create(&record).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// purgeSubscriptionRows deletes rows referencing the subscriptions without cascade
+func purgeSubscriptionRows(tx *gorm.DB, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := tx.Delete(&models.SubscriptionCredit{}, "subscription_id IN ?", ids).Error; err != nil {
+		return err
+	}
+	if err := tx.Delete(&models.PausedPeriod{}, "subscription_id IN ?", ids).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&models.IntegrityFinding{}, "subscription_id IN ?", ids).Error
+}
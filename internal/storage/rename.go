@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"subscription-aggregator-service/internal/models"
+)
+
+func (ss *SubscriptionStorageImpl) CreateServiceRename(ctx context.Context, r *models.ServiceRename) error {
+	return ss.db.WithContext(ctx).Create(r).Error
+}
+
+// RenameServiceBatch locks up to limit live subscriptions named r.FromName in any case, of unitIDs if not nil, lets check reject them,
+// gives them r.ToName and adds them to r's count, all in one transaction. Returns the subscriptions as they were before,
+// fewer than limit means nothing is left to rename.
+func (ss *SubscriptionStorageImpl) RenameServiceBatch(ctx context.Context, r *models.ServiceRename, unitIDs []uuid.UUID, limit int, check func(batch []models.Subscription) error) ([]models.Subscription, error) {
+	var batch []models.Subscription
+	err := ss.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("lower(service_name) = lower(?) AND service_name <> ?", r.FromName, r.ToName) // Renamed ones may still match in any case
+		if unitIDs != nil {
+			query = query.Where("org_unit_id IN ?", unitIDs)
+		}
+		if err := query.Order("id").Limit(limit).Find(&batch).Error; err != nil {
+			return err
+		}
+		if err := check(batch); err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, len(batch))
+		for i, sub := range batch {
+			ids[i] = sub.ID
+		}
+		if err := tx.Model(&models.Subscription{}).Where("id IN ?", ids).
+			Updates(map[string]any{"service_name": r.ToName, "updated_at": time.Now(), "version": gorm.Expr("version + 1")}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.ServiceRename{}).Where("id = ?", r.ID).Update("renamed", gorm.Expr("renamed + ?", len(ids))).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+func (ss *SubscriptionStorageImpl) FinishServiceRename(ctx context.Context, id uuid.UUID) error {
+	return ss.db.WithContext(ctx).Model(&models.ServiceRename{}).Where("id = ?", id).Update("finished_at", time.Now()).Error
+}
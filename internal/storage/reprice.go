@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"subscription-aggregator-service/internal/models"
+)
+
+// reprice gives subscriptions the price in place, so their IDs, external IDs, ETags and undo tokens stay valid.
+// The caller locks subscriptions. Returns them with the new price in order of subs.
+func reprice(tx *gorm.DB, subs []models.Subscription, price int, followPlan bool) ([]models.Subscription, error) {
+	if len(subs) == 0 {
+		return nil, nil
+	}
+	now := time.Now()
+	repriced := make([]models.Subscription, len(subs))
+	ids := make([]uuid.UUID, len(subs))
+	for i, sub := range subs {
+		ids[i] = sub.ID
+		repriced[i] = sub
+		repriced[i].Price, repriced[i].FollowPlan, repriced[i].UpdatedAt = price, followPlan, now
+		repriced[i].Version++
+	}
+
+	err := tx.Model(&models.Subscription{}).Where("id IN ?", ids).
+		Updates(map[string]any{"price": price, "follow_plan_price": followPlan, "updated_at": now, "version": gorm.Expr("version + 1")}).Error
+	if err != nil {
+		return nil, err
+	}
+	return repriced, nil
+}
@@ -3,23 +3,75 @@ package storage
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"subscription-aggregator-service/internal/models"
 )
 
-var ErrNotFound = errors.New("not found")
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+	ErrStale         = errors.New("stale version")
+)
+
+const uniqueViolationCode = "23505"
 
 type SubscriptionStorage interface {
 	CreateSubscription(ctx context.Context, s *models.Subscription) error
+	CreateSubscriptionIfAbsent(ctx context.Context, s *models.Subscription) (*models.Subscription, bool, error)
+	CreateSubscriptions(ctx context.Context, subs []*models.Subscription) error
 	GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error)
+	SubscriptionExistsByID(ctx context.Context, id uuid.UUID) (bool, error)
+	FindOverlappingSubscription(ctx context.Context, s *models.Subscription) (*models.Subscription, error)
 	UpdateSubscriptionByID(ctx context.Context, s *models.Subscription) error
+	UpdateSubscriptionsPrice(ctx context.Context, filter models.SubscriptionFilter, price int, month time.Time, check func(before []models.Subscription) error) ([]models.Subscription, []models.Subscription, error)
 	DeleteSubscriptionByID(ctx context.Context, id uuid.UUID) error
-	ListSubscriptions(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, error)
+	DeleteSubscriptionWithUndo(ctx context.Context, id uuid.UUID, expiresAt time.Time) (*models.UndoToken, error)
+	UndoDeletion(ctx context.Context, token uuid.UUID, check func(sub *models.Subscription) error) (*models.Subscription, error)
+	PurgeSubscription(ctx context.Context, id uuid.UUID) (*models.PurgeRecord, error)
+	PurgeUserData(ctx context.Context, userID uuid.UUID) (*models.PurgeRecord, error)
+	CreateBulkDeleteJob(ctx context.Context, job *models.BulkDeleteJob) error
+	GetBulkDeleteJobByID(ctx context.Context, id uuid.UUID) (*models.BulkDeleteJob, error)
+	ListUnfinishedBulkDeleteJobs(ctx context.Context) ([]models.BulkDeleteJob, error)
+	ClaimBulkDeleteJob(ctx context.Context, id uuid.UUID, staleBefore time.Time) (bool, error)
+	DeleteBulkDeleteBatch(ctx context.Context, job *models.BulkDeleteJob, limit int, check func(batch []models.Subscription) error) ([]models.Subscription, error)
+	FinishBulkDeleteJob(ctx context.Context, id uuid.UUID, status string, errMsg *string) error
+	SyncUserSubscriptions(ctx context.Context, userID uuid.UUID, plan func(current []models.Subscription) (*models.SyncPlan, error)) (*models.SyncPlan, error)
+	ListSubscriptions(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, int64, error)
 	TotalSubscriptionsCost(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time) (int64, error)
+	TotalSubscriptionsCostByUser(ctx context.Context, filter models.SubscriptionFilter, userIDs []uuid.UUID, startDate, endDate time.Time) (map[uuid.UUID]int64, error)
+	ListSubscriptionsInPeriod(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time) ([]models.Subscription, error)
+	ListExpiringSubscriptions(ctx context.Context, from, to time.Time, renewed *bool) ([]models.Subscription, error)
+	UserSummary(ctx context.Context, userID uuid.UUID, month time.Time) (*models.UserSummary, error)
+	SuggestServiceNames(ctx context.Context, prefix string, userID *uuid.UUID, limit int) ([]string, error)
+	CreateView(ctx context.Context, v *models.SavedView) error
+	GetViewByID(ctx context.Context, id uuid.UUID) (*models.SavedView, error)
+	ListViews(ctx context.Context, userID uuid.UUID) ([]models.SavedView, error)
+	CreatePlan(ctx context.Context, p *models.Plan) error
+	GetPlanByID(ctx context.Context, id uuid.UUID) (*models.Plan, error)
+	ListPlans(ctx context.Context) ([]models.Plan, error)
+	UpdatePlanPrice(ctx context.Context, id uuid.UUID, price int, month time.Time, check func(before []models.Subscription) error) (*models.Plan, []models.Subscription, []models.Subscription, error)
+	CreateOrgUnit(ctx context.Context, u *models.OrgUnit) error
+	GetOrgUnitByID(ctx context.Context, id uuid.UUID) (*models.OrgUnit, error)
+	ListOrgUnits(ctx context.Context) ([]models.OrgUnit, error)
+	TotalSubscriptionsCostByOrgUnit(ctx context.Context, unitIDs []uuid.UUID, startDate, endDate time.Time) (map[uuid.UUID]int64, error)
+	CountSubscriptionsByOrgUnit(ctx context.Context, unitIDs []uuid.UUID, month time.Time) (map[uuid.UUID]models.SubscriptionCounts, error)
+	CreateCredit(ctx context.Context, c *models.SubscriptionCredit) error
+	ListCredits(ctx context.Context, subscriptionID uuid.UUID) ([]models.SubscriptionCredit, error)
+	CreatePausedPeriod(ctx context.Context, p *models.PausedPeriod) error
+	ResumePausedPeriod(ctx context.Context, subscriptionID uuid.UUID, endDate time.Time) (*models.PausedPeriod, error)
+	ListPausedPeriods(ctx context.Context, subscriptionID uuid.UUID) ([]models.PausedPeriod, error)
+	CreateServiceRename(ctx context.Context, r *models.ServiceRename) error
+	RenameServiceBatch(ctx context.Context, r *models.ServiceRename, unitIDs []uuid.UUID, limit int, check func(batch []models.Subscription) error) ([]models.Subscription, error)
+	FinishServiceRename(ctx context.Context, id uuid.UUID) error
+	CheckIntegrity(ctx context.Context) (bool, error)
+	ListIntegrityFindings(ctx context.Context) ([]models.IntegrityFinding, error)
 }
 
 type SubscriptionStorageImpl struct {
@@ -31,7 +83,55 @@ func NewSubscriptionsStorage(db *gorm.DB) SubscriptionStorage {
 }
 
 func (ss *SubscriptionStorageImpl) CreateSubscription(ctx context.Context, sub *models.Subscription) error {
-	return ss.db.WithContext(ctx).Create(sub).Error
+	if err := ss.db.WithContext(ctx).Create(sub).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return ErrAlreadyExists
+		}
+		return err
+	}
+	return nil
+}
+
+// CreateSubscriptions inserts all subscriptions in one transaction, nothing is inserted if any of them fails
+func (ss *SubscriptionStorageImpl) CreateSubscriptions(ctx context.Context, subs []*models.Subscription) error {
+	if len(subs) == 0 {
+		return nil
+	}
+	if err := ss.db.WithContext(ctx).CreateInBatches(subs, 100).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return ErrAlreadyExists
+		}
+		return err
+	}
+	return nil
+}
+
+// CreateSubscriptionIfAbsent inserts the subscription unless a live one with the same (user_id, external_id) exists,
+// otherwise returns the existing one. Relies on the partial unique index from the external_id migration.
+func (ss *SubscriptionStorageImpl) CreateSubscriptionIfAbsent(ctx context.Context, sub *models.Subscription) (*models.Subscription, bool, error) {
+	result := ss.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "user_id"}, {Name: "external_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "external_id IS NOT NULL AND deleted_at IS NULL"}}},
+		DoNothing:   true,
+	}).Create(sub)
+	if result.Error != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(result.Error, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return nil, false, ErrAlreadyExists
+		}
+		return nil, false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return sub, true, nil
+	}
+
+	var existing models.Subscription
+	if err := ss.db.WithContext(ctx).First(&existing, "user_id = ? AND external_id = ?", sub.UserID, *sub.ExternalID).Error; err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
 }
 
 func (ss *SubscriptionStorageImpl) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
@@ -46,26 +146,93 @@ func (ss *SubscriptionStorageImpl) GetSubscriptionByID(ctx context.Context, id u
 	return &sub, nil
 }
 
+// SubscriptionExistsByID checks for a live subscription without loading it
+func (ss *SubscriptionStorageImpl) SubscriptionExistsByID(ctx context.Context, id uuid.UUID) (bool, error) {
+	var exists bool
+	err := ss.db.WithContext(ctx).Raw("SELECT EXISTS (SELECT 1 FROM subscriptions WHERE id = ? AND deleted_at IS NULL)", id).Scan(&exists).Error
+	return exists, err
+}
+
+// FindOverlappingSubscription returns the earliest subscription of the same user to the same service (case-insensitively)
+// active at any point of s's period, ErrNotFound if there's none
+func (ss *SubscriptionStorageImpl) FindOverlappingSubscription(ctx context.Context, s *models.Subscription) (*models.Subscription, error) {
+	query := ss.db.WithContext(ctx).
+		Where("user_id = ?", s.UserID).
+		Where("lower(service_name) = lower(?)", s.ServiceName).
+		Where("end_date IS NULL OR end_date >= ?", s.StartDate)
+	if s.EndDate != nil {
+		query = query.Where("start_date <= ?", *s.EndDate)
+	}
+
+	var sub models.Subscription
+	if err := query.Order("start_date, id").First(&sub).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// UpdateSubscriptionByID stores sub if it's still at sub.Version and increments the version.
+// Returns ErrStale if another update got there first.
 func (ss *SubscriptionStorageImpl) UpdateSubscriptionByID(ctx context.Context, sub *models.Subscription) error {
 	result := ss.db.WithContext(ctx).Model(&models.Subscription{}).
-		Where("id = ?", sub.ID).Select("service_name", "price", "user_id", "start_date", "end_date", "updated_at").
+		Where("id = ? AND version = ?", sub.ID, sub.Version).Select("service_name", "price", "follow_plan_price", "org_unit_id", "cost_center", "project_code", "user_id", "start_date", "end_date", "cancelled_at", "updated_at", "version").
 		Updates(&models.Subscription{
 			ServiceName: sub.ServiceName,
 			Price:       sub.Price,
+			FollowPlan:  sub.FollowPlan,
+			OrgUnitID:   sub.OrgUnitID,
+			CostCenter:  sub.CostCenter,
+			ProjectCode: sub.ProjectCode,
 			UserID:      sub.UserID,
 			StartDate:   sub.StartDate,
 			EndDate:     sub.EndDate,
+			CancelledAt: sub.CancelledAt,
 			UpdatedAt:   time.Now(),
+			Version:     sub.Version + 1,
 		})
 	if result.Error != nil {
 		return result.Error
 	}
 	if result.RowsAffected == 0 {
-		return ErrNotFound
+		if _, err := ss.GetSubscriptionByID(ctx, sub.ID); err != nil {
+			return err
+		}
+		return ErrStale
 	}
+	sub.Version++
 	return nil
 }
 
+// UpdateSubscriptionsPrice locks live subscriptions of filter.UserID matching the service name filter whose price differs
+// and that run in month or later, with their credits, lets check reject the change and gives all of them the price in place
+// in one transaction, so they stop following their plan. Ended ones keep the price they had.
+// Returns the subscriptions as they were before and with the new price, in the same order.
+func (ss *SubscriptionStorageImpl) UpdateSubscriptionsPrice(ctx context.Context, filter models.SubscriptionFilter, price int, month time.Time, check func(before []models.Subscription) error) ([]models.Subscription, []models.Subscription, error) {
+	var before, after []models.Subscription
+	err := ss.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Credits").
+			Where("user_id = ? AND price <> ?", *filter.UserID, price).
+			Where("end_date IS NULL OR end_date >= ?", month)
+		if err := whereServiceName(query, "service_name", filter).Order("id").Find(&before).Error; err != nil {
+			return err
+		}
+		if err := check(before); err != nil {
+			return err
+		}
+
+		var err error
+		after, err = reprice(tx, before, price, false)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
 func (ss *SubscriptionStorageImpl) DeleteSubscriptionByID(ctx context.Context, id uuid.UUID) error {
 	result := ss.db.WithContext(ctx).Delete(&models.Subscription{}, "id = ?", id)
 	if result.Error != nil {
@@ -77,66 +244,426 @@ func (ss *SubscriptionStorageImpl) DeleteSubscriptionByID(ctx context.Context, i
 	return nil
 }
 
-func (ss *SubscriptionStorageImpl) ListSubscriptions(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, error) {
-	query := ss.db.WithContext(ctx).Model(&models.Subscription{}).Order("created_at desc, id desc")
+// SyncUserSubscriptions locks user's live subscriptions, builds the plan from them and applies it, all in one transaction,
+// so concurrent syncs of the same user are serialized and a failed step leaves nothing changed
+func (ss *SubscriptionStorageImpl) SyncUserSubscriptions(ctx context.Context, userID uuid.UUID, plan func(current []models.Subscription) (*models.SyncPlan, error)) (*models.SyncPlan, error) {
+	var applied *models.SyncPlan
+	err := ss.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current []models.Subscription
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).Order("created_at, id").Find(&current).Error; err != nil {
+			return err
+		}
+
+		p, err := plan(current)
+		if err != nil {
+			return err
+		}
+
+		for _, sub := range p.Create {
+			if err = tx.Create(sub).Error; err != nil {
+				var pgErr *pgconn.PgError
+				if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+					return ErrAlreadyExists
+				}
+				return err
+			}
+		}
+		for _, sub := range p.Update {
+			sub.UpdatedAt = time.Now()
+			sub.Version++ // Rows are locked, so the version read above is current
+			result := tx.Model(&models.Subscription{}).
+				Where("id = ?", sub.ID).Select("service_name", "price", "type", "start_date", "end_date", "updated_at", "version").
+				Updates(sub)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrNotFound
+			}
+		}
+		for _, sub := range p.Delete {
+			if err = tx.Delete(&models.Subscription{}, "id = ?", sub.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		applied = p
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// sortColumn maps a requested sort key to a column, anything unknown sorts by creation time
+func sortColumn(sortBy string) string {
+	switch sortBy {
+	case models.SortByPrice, models.SortByStartDate, models.SortByServiceName:
+		return sortBy
+	default:
+		return models.SortByCreatedAt
+	}
+}
+
+// subscriptionWithCount is a listed row with the number of rows matching the filter regardless of limit and offset
+type subscriptionWithCount struct {
+	models.Subscription
+	TotalCount int64
+}
+
+// ListSubscriptions returns a page of subscriptions matching the filter and the total number of matching ones.
+// The total comes from a window function in the same query, only a page past the end needs a separate count.
+func (ss *SubscriptionStorageImpl) ListSubscriptions(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, int64, error) {
+	query := ss.db.WithContext(ctx).Model(&models.Subscription{})
 
 	if filter.UserID != nil {
 		query = query.Where("user_id = ?", *filter.UserID)
 	}
-	if filter.ServiceName != nil {
-		query = query.Where("service_name = ?", *filter.ServiceName)
+	query = whereServiceName(query, "service_name", filter)
+	if filter.CreatedAfter != nil {
+		query = query.Where("created_at > ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *filter.CreatedBefore)
 	}
+	if filter.ActiveAt != nil {
+		query = query.Where("start_date <= ?", *filter.ActiveAt).
+			Where("end_date IS NULL OR end_date >= ?", *filter.ActiveAt)
+	}
+	if filter.MinPrice != nil {
+		query = query.Where("price >= ?", *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		query = query.Where("price <= ?", *filter.MaxPrice)
+	}
+	if filter.Status != nil {
+		query = whereStatus(query, *filter.Status, filter.StatusAt)
+	}
+
+	page := query.Session(&gorm.Session{}).Select("*, COUNT(*) OVER () AS total_count").
+		Order(clause.OrderByColumn{Column: clause.Column{Name: sortColumn(filter.SortBy)}, Desc: filter.SortDesc}).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: "id"}, Desc: filter.SortDesc})
 	if filter.Limit != nil {
-		query = query.Limit(*filter.Limit)
+		page = page.Limit(*filter.Limit)
 	}
 	if filter.Offset != nil {
-		query = query.Offset(*filter.Offset)
+		page = page.Offset(*filter.Offset)
 	}
 
-	var subs []models.Subscription
-	if err := query.Find(&subs).Error; err != nil {
-		return nil, err
+	var rows []subscriptionWithCount
+	if err := page.Find(&rows).Error; err != nil {
+		return nil, 0, err
 	}
 
-	return subs, nil
+	subs := make([]models.Subscription, len(rows))
+	for i, row := range rows {
+		subs[i] = row.Subscription
+	}
+	if len(rows) > 0 {
+		return subs, rows[0].TotalCount, nil
+	}
+	if filter.Offset == nil || *filter.Offset == 0 {
+		return subs, 0, nil
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	return subs, total, nil
+}
+
+// whereStatus narrows a query on subscriptions to ones with the status in month, in the order Subscription.StatusIn checks them
+func whereStatus(query *gorm.DB, status string, month time.Time) *gorm.DB {
+	switch status {
+	case models.StatusDeleted:
+		return query.Unscoped().Where("deleted_at IS NOT NULL")
+	case models.StatusCancelled:
+		return query.Where("cancelled_at IS NOT NULL")
+	case models.StatusUpcoming:
+		return query.Where("cancelled_at IS NULL AND start_date > ?", month)
+	case models.StatusExpired:
+		return query.Where("cancelled_at IS NULL AND start_date <= ? AND end_date < ?", month, month)
+	default:
+		return query.Where("cancelled_at IS NULL AND start_date <= ?", month).Where("end_date IS NULL OR end_date >= ?", month)
+	}
 }
 
+// costSQL sums months of [startDate, endDate] covered by each subscription times its price, args are costArgs
+const costSQL = `
+	COALESCE(SUM(
+		(
+			(
+				EXTRACT(YEAR FROM LEAST(COALESCE(end_date, ?), ?))::int * 12 +
+				EXTRACT(MONTH FROM LEAST(COALESCE(end_date, ?), ?))::int
+			) -
+			(
+				EXTRACT(YEAR FROM GREATEST(start_date, ?))::int * 12 +
+				EXTRACT(MONTH FROM GREATEST(start_date, ?))::int
+			) + 1
+		)::bigint * price
+	), 0)
+`
+
+func costArgs(startDate, endDate time.Time) []any {
+	return []any{endDate, endDate, endDate, endDate, startDate, startDate}
+}
+
+// creditMonthsJoin expands every credit (aliased c) of a subscription (aliased s) into months m it shares with the subscription
+// and [startDate, endDate], args are creditMonthsArgs
+const creditMonthsJoin = `
+	CROSS JOIN LATERAL generate_series(
+		GREATEST(c.start_date, s.start_date, ?)::timestamp,
+		LEAST(COALESCE(c.end_date, ?), COALESCE(s.end_date, ?), ?)::timestamp,
+		interval '1 month'
+	) AS m(month)
+`
+
+func creditMonthsArgs(startDate, endDate time.Time) []any {
+	return []any{startDate, endDate, endDate, endDate}
+}
+
+// creditsSQL sums credits (negative, fixed or a share of the price) over rows of creditMonths (aliased cm).
+// Credits of a month take off no more than the price, so stacked credits or a later price cut can't make it negative.
+const creditsSQL = `COALESCE(SUM(GREATEST(cm.amount, -cm.price)), 0)`
+
+// creditMonths is a query over months of live subscriptions' credits within [startDate, endDate], grouped by subscription
+// and month. Select the sum of credits of the month as amount and s.price as price for creditsSQL.
+func (ss *SubscriptionStorageImpl) creditMonths(ctx context.Context, startDate, endDate time.Time) *gorm.DB {
+	return ss.db.WithContext(ctx).Table("subscription_credits AS c").
+		Joins("JOIN subscriptions AS s ON s.id = c.subscription_id AND s.deleted_at IS NULL").
+		Joins(creditMonthsJoin, creditMonthsArgs(startDate, endDate)...).
+		Group("s.id, m.month")
+}
+
+const creditMonthsSelect = "s.price, SUM(c.amount - s.price * c.percent / 100) AS amount"
+
 func (ss *SubscriptionStorageImpl) TotalSubscriptionsCost(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time) (int64, error) {
 	query := ss.db.WithContext(ctx).Model(&models.Subscription{})
 
 	if filter.UserID != nil {
 		query = query.Where("user_id = ?", *filter.UserID)
 	}
-	if filter.ServiceName != nil {
-		query = query.Where("service_name = ?", *filter.ServiceName)
-	}
+	query = whereServiceName(query, "service_name", filter)
 
 	query = query.Where("start_date <= ?", endDate).
 		Where("end_date IS NULL OR end_date >= ?", startDate)
 
-	selectExpr := `
-		COALESCE(SUM(
-			(
-				(
-					EXTRACT(YEAR FROM LEAST(COALESCE(end_date, ?), ?))::int * 12 +
-					EXTRACT(MONTH FROM LEAST(COALESCE(end_date, ?), ?))::int
-				) -
-				(
-					EXTRACT(YEAR FROM GREATEST(start_date, ?))::int * 12 +
-					EXTRACT(MONTH FROM GREATEST(start_date, ?))::int
-				) + 1
-			)::bigint * price
-		), 0)
-	`
+	var total int64
+	if err := query.Select(costSQL, costArgs(startDate, endDate)...).Scan(&total).Error; err != nil {
+		return 0, err
+	}
+
+	credits, err := ss.totalCredits(ctx, filter, startDate, endDate)
+	if err != nil {
+		return 0, err
+	}
+
+	paused, err := ss.totalPaused(ctx, filter, startDate, endDate)
+	if err != nil {
+		return 0, err
+	}
+
+	return total + credits - paused, nil
+}
+
+// totalCredits sums credits of subscriptions matching filter over [startDate, endDate]
+func (ss *SubscriptionStorageImpl) totalCredits(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time) (int64, error) {
+	query := ss.creditMonths(ctx, startDate, endDate)
+
+	if filter.UserID != nil {
+		query = query.Where("s.user_id = ?", *filter.UserID)
+	}
+	query = whereServiceName(query, "s.service_name", filter)
 
 	var total int64
-	if err := query.Select(selectExpr,
-		endDate, endDate,
-		endDate, endDate,
-		startDate, startDate,
-	).Scan(&total).Error; err != nil {
+	if err := ss.db.WithContext(ctx).Table("(?) AS cm", query.Select(creditMonthsSelect)).Select(creditsSQL).Scan(&total).Error; err != nil {
 		return 0, err
 	}
 
 	return total, nil
 }
+
+// TotalSubscriptionsCostByUser computes TotalSubscriptionsCost for each of userIDs with one grouped query per table,
+// filter.UserID is ignored. Users without subscriptions in the period are absent from the result.
+func (ss *SubscriptionStorageImpl) TotalSubscriptionsCostByUser(ctx context.Context, filter models.SubscriptionFilter, userIDs []uuid.UUID, startDate, endDate time.Time) (map[uuid.UUID]int64, error) {
+	return ss.totalsGroupedBy(ctx, "user_id", userIDs, filter, startDate, endDate)
+}
+
+// totalsGroupedBy sums cost, credits and paused months of subscriptions whose column is one of ids, per value of column
+func (ss *SubscriptionStorageImpl) totalsGroupedBy(ctx context.Context, column string, ids []uuid.UUID, filter models.SubscriptionFilter, startDate, endDate time.Time) (map[uuid.UUID]int64, error) {
+	type groupTotal struct {
+		ID    uuid.UUID
+		Total int64
+	}
+
+	query := ss.db.WithContext(ctx).Model(&models.Subscription{}).
+		Where(column+" IN ?", ids).
+		Where("start_date <= ?", endDate).
+		Where("end_date IS NULL OR end_date >= ?", startDate)
+	query = whereServiceName(query, "service_name", filter)
+	var costs []groupTotal
+	if err := query.Select(column+" AS id, "+costSQL+" AS total", costArgs(startDate, endDate)...).Group(column).Scan(&costs).Error; err != nil {
+		return nil, err
+	}
+
+	query = ss.creditMonths(ctx, startDate, endDate).Where("s."+column+" IN ?", ids)
+	query = whereServiceName(query, "s.service_name", filter)
+	var credits []groupTotal
+	query = ss.db.WithContext(ctx).Table("(?) AS cm", query.Select("s."+column+" AS id, "+creditMonthsSelect))
+	if err := query.Select("cm.id, " + creditsSQL + " AS total").Group("cm.id").Scan(&credits).Error; err != nil {
+		return nil, err
+	}
+
+	query = ss.pausedMonths(ctx, startDate, endDate).Where("s."+column+" IN ?", ids)
+	query = whereServiceName(query, "s.service_name", filter)
+	var paused []groupTotal
+	if err := query.Select("s." + column + " AS id, " + pausedSQL + " AS total").Group("s." + column).Scan(&paused).Error; err != nil {
+		return nil, err
+	}
+
+	totals := make(map[uuid.UUID]int64, len(costs))
+	for _, t := range append(costs, credits...) {
+		totals[t.ID] += t.Total
+	}
+	for _, t := range paused {
+		totals[t.ID] -= t.Total
+	}
+	return totals, nil
+}
+
+// userSummarySQL aggregates user's live subscriptions in one pass, args are month (twice) and user ID
+const userSummarySQL = `
+	SELECT
+		COUNT(*) FILTER (WHERE active) AS active_count,
+		COALESCE(SUM(price) FILTER (WHERE active AND type = 'recurring'), 0) AS monthly_cost,
+		(ARRAY_AGG(service_name ORDER BY price DESC, service_name) FILTER (WHERE active AND type = 'recurring'))[1] AS most_expensive_service,
+		MIN(start_date) AS earliest_start,
+		MAX(start_date) AS latest_start
+	FROM (
+		SELECT *, start_date <= ? AND (end_date IS NULL OR end_date >= ?) AS active
+		FROM subscriptions
+		WHERE user_id = ? AND deleted_at IS NULL
+	) AS s
+`
+
+// UserSummary aggregates user's subscriptions in SQL without loading rows, active ones are those covering month
+func (ss *SubscriptionStorageImpl) UserSummary(ctx context.Context, userID uuid.UUID, month time.Time) (*models.UserSummary, error) {
+	var summary models.UserSummary
+	if err := ss.db.WithContext(ctx).Raw(userSummarySQL, month, month, userID).Scan(&summary).Error; err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// ListSubscriptionsInPeriod returns subscriptions active at any point of [startDate, endDate] with their credits and pauses,
+// i.e. the rows TotalSubscriptionsCost sums up
+func (ss *SubscriptionStorageImpl) ListSubscriptionsInPeriod(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time) ([]models.Subscription, error) {
+	query := ss.db.WithContext(ctx).Model(&models.Subscription{}).Order("start_date, service_name, id").
+		Preload("Credits", func(db *gorm.DB) *gorm.DB { return db.Order("start_date, created_at") }).
+		Preload("Pauses", func(db *gorm.DB) *gorm.DB { return db.Order("start_date") })
+
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	query = whereServiceName(query, "service_name", filter)
+
+	query = query.Where("start_date <= ?", endDate).
+		Where("end_date IS NULL OR end_date >= ?", startDate)
+
+	var subs []models.Subscription
+	if err := query.Find(&subs).Error; err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// whereServiceName applies service name filters to column
+func whereServiceName(query *gorm.DB, column string, filter models.SubscriptionFilter) *gorm.DB {
+	if filter.ServiceName != nil {
+		if filter.ServiceNameFold {
+			query = query.Where("lower("+column+") = lower(?)", *filter.ServiceName)
+		} else {
+			query = query.Where(column+" = ?", *filter.ServiceName)
+		}
+	}
+	if filter.ServiceNameLike != nil {
+		query = query.Where(column+" ILIKE ?", likePattern(*filter.ServiceNameLike))
+	}
+	return query
+}
+
+// likePattern turns a service_name_like value into an ILIKE pattern
+func likePattern(s string) string {
+	escaped := likeEscaper.Replace(s)
+	if !strings.Contains(s, "*") {
+		return "%" + escaped + "%"
+	}
+	return strings.ReplaceAll(escaped, "*", "%")
+}
+
+func (ss *SubscriptionStorageImpl) SuggestServiceNames(ctx context.Context, prefix string, userID *uuid.UUID, limit int) ([]string, error) {
+	query := ss.db.WithContext(ctx).Model(&models.Subscription{}).
+		Distinct("service_name").
+		Where("service_name ILIKE ?", likeEscaper.Replace(prefix)+"%").
+		Order("service_name").
+		Limit(limit)
+
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+
+	var names []string
+	if err := query.Pluck("service_name", &names).Error; err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+func (ss *SubscriptionStorageImpl) CreateView(ctx context.Context, v *models.SavedView) error {
+	if err := ss.db.WithContext(ctx).Create(v).Error; err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return ErrAlreadyExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (ss *SubscriptionStorageImpl) GetViewByID(ctx context.Context, id uuid.UUID) (*models.SavedView, error) {
+	var v models.SavedView
+	if err := ss.db.WithContext(ctx).First(&v, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		} else {
+			return nil, err
+		}
+	}
+	return &v, nil
+}
+
+func (ss *SubscriptionStorageImpl) ListViews(ctx context.Context, userID uuid.UUID) ([]models.SavedView, error) {
+	var views []models.SavedView
+	if err := ss.db.WithContext(ctx).Where("user_id = ?", userID).Order("name").Find(&views).Error; err != nil {
+		return nil, err
+	}
+	return views, nil
+}
+
+func (ss *SubscriptionStorageImpl) CreateCredit(ctx context.Context, c *models.SubscriptionCredit) error {
+	return ss.db.WithContext(ctx).Create(c).Error
+}
+
+func (ss *SubscriptionStorageImpl) ListCredits(ctx context.Context, subscriptionID uuid.UUID) ([]models.SubscriptionCredit, error) {
+	var credits []models.SubscriptionCredit
+	if err := ss.db.WithContext(ctx).Where("subscription_id = ?", subscriptionID).Order("start_date, created_at").Find(&credits).Error; err != nil {
+		return nil, err
+	}
+	return credits, nil
+}
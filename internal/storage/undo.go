@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"subscription-aggregator-service/internal/models"
+)
+
+// DeleteSubscriptionWithUndo soft-deletes the subscription and issues a token restoring it until expiresAt,
+// in one transaction. Expired tokens are dropped on the way, their subscriptions stay deleted.
+func (ss *SubscriptionStorageImpl) DeleteSubscriptionWithUndo(ctx context.Context, id uuid.UUID, expiresAt time.Time) (*models.UndoToken, error) {
+	token := models.UndoToken{SubscriptionID: id, ExpiresAt: expiresAt}
+	err := ss.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Subscription{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		if err := tx.Where("expires_at <= now()").Delete(&models.UndoToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&token).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// UndoDeletion consumes an unexpired token and restores the subscription it was issued for, unless check rejects it.
+// Returns ErrAlreadyExists if a live subscription took its external_id in the meantime, the token stays usable then, as after a rejection.
+func (ss *SubscriptionStorageImpl) UndoDeletion(ctx context.Context, token uuid.UUID, check func(sub *models.Subscription) error) (*models.Subscription, error) {
+	var sub models.Subscription
+	err := ss.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var t models.UndoToken
+		result := tx.Clauses(clause.Returning{}).Where("token = ? AND expires_at > now()", token).Delete(&t)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&sub, "id = ? AND deleted_at IS NOT NULL", t.SubscriptionID).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
+		sub.DeletedAt, sub.UpdatedAt = gorm.DeletedAt{}, time.Now()
+		if err = check(&sub); err != nil {
+			return err
+		}
+
+		err = tx.Unscoped().Model(&models.Subscription{}).Where("id = ?", sub.ID).
+			Updates(map[string]any{"deleted_at": nil, "updated_at": sub.UpdatedAt}).Error
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+				return ErrAlreadyExists
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
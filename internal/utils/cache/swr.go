@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type swrEntry[V any] struct {
+	value    V
+	storedAt time.Time
+}
+
+// SWR is a concurrency-safe in-memory cache with stale-while-revalidate: an entry is served as is for ttl,
+// then for up to stale more while a single background refresh replaces it, after that it's loaded again on request
+type SWR[K comparable, V any] struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	stale      time.Duration
+	items      map[K]swrEntry[V]
+	refreshing map[K]bool
+	generation uint64 // Bumped by Invalidate, so loads started before it don't store outdated values
+	sweptAt    time.Time
+}
+
+func NewSWR[K comparable, V any](ttl, stale time.Duration) *SWR[K, V] {
+	return &SWR[K, V]{ttl: ttl, stale: stale, items: make(map[K]swrEntry[V]), refreshing: make(map[K]bool)}
+}
+
+// Get returns the cached value of key or loads it with ctx. Background refresh runs detached from ctx cancellation
+// and is limited by the stale window, as a value loaded later than that would be dropped anyway.
+func (c *SWR[K, V]) Get(ctx context.Context, key K, load func(ctx context.Context) (V, error)) (V, error) {
+	c.mu.Lock()
+	e, ok := c.items[key]
+	age := time.Since(e.storedAt)
+	if ok && age < c.ttl {
+		c.mu.Unlock()
+		return e.value, nil
+	}
+	if ok && age < c.ttl+c.stale {
+		if !c.refreshing[key] {
+			c.refreshing[key] = true
+			go c.refresh(ctx, key, c.generation, load)
+		}
+		c.mu.Unlock()
+		return e.value, nil
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	v, err := load(ctx)
+	if err != nil {
+		return v, err
+	}
+	c.store(key, v, generation)
+	return v, nil
+}
+
+func (c *SWR[K, V]) refresh(ctx context.Context, key K, generation uint64, load func(ctx context.Context) (V, error)) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.stale)
+	defer cancel()
+
+	v, err := load(ctx)
+
+	c.mu.Lock()
+	delete(c.refreshing, key)
+	c.mu.Unlock()
+	if err == nil { // Otherwise the stale value stays until the window ends, next request retries
+		c.store(key, v, generation)
+	}
+}
+
+func (c *SWR[K, V]) store(key K, value V, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	now := time.Now()
+	if now.Sub(c.sweptAt) > c.ttl+c.stale { // Drop entries past the stale window at most once per window
+		for k, e := range c.items {
+			if now.Sub(e.storedAt) >= c.ttl+c.stale {
+				delete(c.items, k)
+			}
+		}
+		c.sweptAt = now
+	}
+	c.items[key] = swrEntry[V]{value: value, storedAt: now}
+}
+
+// Invalidate drops all entries, including values being loaded right now
+func (c *SWR[K, V]) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	clear(c.items)
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"testing"
+
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+func TestSWR(t *testing.T) {
+	c := NewSWR[string, int64](50*time.Millisecond, time.Second)
+	ctx := context.Background()
+
+	var loads atomic.Int64
+	refreshed := make(chan struct{}, 1)
+	load := func(context.Context) (int64, error) {
+		n := loads.Add(1)
+		if n > 1 {
+			defer func() { refreshed <- struct{}{} }()
+		}
+		return n, nil
+	}
+
+	if v, err := c.Get(ctx, "a", load); err != nil || v != 1 {
+		t.Fatalf("Get() on empty cache = %d, %v, want 1 loaded", v, err)
+	}
+	if v, _ := c.Get(ctx, "a", load); v != 1 || loads.Load() != 1 {
+		t.Errorf("Get() of fresh entry = %d after %d loads, want cached 1", v, loads.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if v, _ := c.Get(ctx, "a", load); v != 1 {
+		t.Errorf("Get() of stale entry = %d, want stale 1 served instantly", v)
+	}
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("stale entry wasn't refreshed in background")
+	}
+	time.Sleep(10 * time.Millisecond) // Refresh stores right after load returns
+	if v, _ := c.Get(ctx, "a", load); v != 2 {
+		t.Errorf("Get() after refresh = %d, want 2", v)
+	}
+
+	c.Invalidate()
+	if v, _ := c.Get(ctx, "a", load); v != 3 {
+		t.Errorf("Get() after Invalidate() = %d, want 3 loaded again", v)
+	}
+	<-refreshed
+}
+
+func TestSWRInvalidateDropsLoadInFlight(t *testing.T) {
+	c := NewSWR[string, int](time.Minute, time.Minute)
+	ctx := context.Background()
+
+	if _, err := c.Get(ctx, "a", func(context.Context) (int, error) {
+		c.Invalidate() // A mutation lands while the value is computed
+		return 1, nil
+	}); err != nil {
+		t.Fatalf("Get() unexpected error: %v", err)
+	}
+	if v, _ := c.Get(ctx, "a", func(context.Context) (int, error) { return 2, nil }); v != 2 {
+		t.Errorf("Get() = %d, want value loaded before Invalidate() not cached", v)
+	}
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// TTL is a minimal concurrency-safe in-memory cache with per-entry expiration
+type TTL[K comparable, V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	items   map[K]entry[V]
+	sweptAt time.Time
+}
+
+func NewTTL[K comparable, V any](ttl time.Duration) *TTL[K, V] {
+	return &TTL[K, V]{ttl: ttl, items: make(map[K]entry[V])}
+}
+
+func (c *TTL[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok || time.Now().After(e.expiresAt) {
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+func (c *TTL[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.sweptAt) > c.ttl { // Drop expired entries at most once per TTL
+		for k, e := range c.items {
+			if now.After(e.expiresAt) {
+				delete(c.items, k)
+			}
+		}
+		c.sweptAt = now
+	}
+	c.items[key] = entry[V]{value: value, expiresAt: now.Add(c.ttl)}
+}
@@ -0,0 +1,25 @@
+package cache
+
+import (
+	"testing"
+
+	"time"
+)
+
+func TestTTL(t *testing.T) {
+	c := NewTTL[string, int](50 * time.Millisecond)
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get() on empty cache should miss")
+	}
+
+	c.Set("a", 1)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("Get() = %d, %v, want 1, true", v, ok)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get() should miss after TTL expired")
+	}
+}
@@ -2,8 +2,10 @@ package dates
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 const Layout = "01-2006"
@@ -21,3 +23,75 @@ func String2Date(s string) (time.Time, error) {
 
 	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), nil // Normalize to single day and time, we only care about month and year
 }
+
+// MonthSpan returns the number of months covered by [start, end], both ends inclusive
+func MonthSpan(start, end time.Time) int {
+	return (end.Year()-start.Year())*12 + int(end.Month()-start.Month()) + 1
+}
+
+// monthNames are lowercase English and Russian month names, abbreviations and genitive forms, in month order
+var monthNames = [12][]string{
+	{"january", "jan", "январь", "января", "янв"},
+	{"february", "feb", "февраль", "февраля", "фев", "февр"},
+	{"march", "mar", "март", "марта", "мар"},
+	{"april", "apr", "апрель", "апреля", "апр"},
+	{"may", "май", "мая"},
+	{"june", "jun", "июнь", "июня", "июн"},
+	{"july", "jul", "июль", "июля", "июл"},
+	{"august", "aug", "август", "августа", "авг"},
+	{"september", "sep", "sept", "сентябрь", "сентября", "сен", "сент"},
+	{"october", "oct", "октябрь", "октября", "окт"},
+	{"november", "nov", "ноябрь", "ноября", "ноя", "нояб"},
+	{"december", "dec", "декабрь", "декабря", "дек"},
+}
+
+var months = func() map[string]time.Month {
+	m := make(map[string]time.Month)
+	for i, names := range monthNames {
+		for _, name := range names {
+			m[name] = time.Month(i + 1)
+		}
+	}
+	return m
+}()
+
+// ParseMonth parses MM-YYYY like String2Date, and also human-style months found in spreadsheets and user input:
+// "Jan 2024", "January 2024", "январь 2024", "января 2024 г.", "01.2024", "1/2024" or "2024-01".
+// Month names are English or Russian in any case, full or abbreviated.
+func ParseMonth(s string) (time.Time, error) {
+	if t, err := String2Date(s); err == nil {
+		return t, nil
+	}
+
+	fields := strings.FieldsFunc(strings.ToLower(strings.TrimSpace(s)), func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune("-./,", r)
+	})
+	if len(fields) == 0 {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+	if last := fields[len(fields)-1]; last == "г" || last == "год" || last == "года" {
+		fields = fields[:len(fields)-1]
+	}
+	if len(fields) != 2 {
+		return time.Time{}, fmt.Errorf("invalid date format")
+	}
+	month, year := fields[0], fields[1]
+	if len(month) == 4 && len(year) <= 2 { // 2024-01
+		month, year = year, month
+	}
+
+	m, ok := months[month]
+	if !ok {
+		n, err := strconv.Atoi(month)
+		if err != nil || n < 1 || n > 12 {
+			return time.Time{}, fmt.Errorf("invalid date format")
+		}
+		m = time.Month(n)
+	}
+	y, err := strconv.Atoi(year)
+	if err != nil || len(year) != 4 {
+		return time.Time{}, fmt.Errorf("invalid date format")
+	}
+
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC), nil
+}
@@ -100,3 +100,72 @@ func TestString2Date(t *testing.T) {
 		})
 	}
 }
+
+func TestMonthSpan(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		end   string
+		want  int
+	}{
+		{name: "same month", start: "01-2024", end: "01-2024", want: 1},
+		{name: "same year", start: "01-2024", end: "12-2024", want: 12},
+		{name: "across years", start: "11-2023", end: "02-2024", want: 4},
+		{name: "ten years", start: "01-2015", end: "12-2024", want: 120},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, _ := String2Date(tt.start)
+			end, _ := String2Date(tt.end)
+			if got := MonthSpan(start, end); got != tt.want {
+				t.Errorf("MonthSpan(%s, %s) = %d, want %d", tt.start, tt.end, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMonth(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "01-2024", want: "01-2024"},
+		{input: "Jan 2024", want: "01-2024"},
+		{input: "SEPTEMBER 2023", want: "09-2023"},
+		{input: "sept. 2023", want: "09-2023"},
+		{input: "Dec-2025", want: "12-2025"},
+		{input: "январь 2024", want: "01-2024"},
+		{input: "Января 2024 г.", want: "01-2024"},
+		{input: "май 2024 года", want: "05-2024"},
+		{input: "нояб. 2024", want: "11-2024"},
+		{input: " 01.2024 ", want: "01-2024"},
+		{input: "1/2024", want: "01-2024"},
+		{input: "2024-03", want: "03-2024"},
+		{input: "", wantErr: true},
+		{input: "Jan", wantErr: true},
+		{input: "Janu 2024", wantErr: true},
+		{input: "13.2024", wantErr: true},
+		{input: "Jan 24", wantErr: true},
+		{input: "1 Jan 2024", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseMonth(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseMonth(%q) = %v, want error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMonth(%q) unexpected error: %v", tt.input, err)
+			}
+			if got.Format(Layout) != tt.want || got.Day() != 1 {
+				t.Errorf("ParseMonth(%q) = %v, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package display
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	LocaleHeader   = "X-Locale"
+	CurrencyHeader = "X-Display-Currency"
+)
+
+// Locales formatted fields are rendered in
+const (
+	LocaleRU = "ru"
+	LocaleEN = "en"
+)
+
+// BaseCurrency is the currency prices are stored in, it needs no rate
+const BaseCurrency = "RUB"
+
+// Prefs shape formatted fields of responses, raw numeric fields never depend on them
+type Prefs struct {
+	Locale   string  // One of Locale* constants
+	Currency string  // ISO 4217 code
+	Rate     float64 // Rubles per unit of Currency, 1 for BaseCurrency
+}
+
+// Default is used when the request context carries no preferences
+var Default = Prefs{Locale: LocaleRU, Currency: BaseCurrency, Rate: 1}
+
+// Negotiator picks preferences of a request from its headers. Preferences are soft:
+// a missing or unsupported value falls back to the default instead of failing the request.
+type Negotiator struct {
+	def   Prefs
+	rates map[string]float64
+}
+
+// NewNegotiator falls back to locale and currency, rates are rubles per unit keyed by upper-case currency code
+func NewNegotiator(locale, currency string, rates map[string]float64) *Negotiator {
+	n := &Negotiator{rates: rates}
+	n.def = Prefs{Locale: language(locale, Default.Locale)}
+	n.def.Currency, n.def.Rate = n.currency(currency, Default.Currency, Default.Rate)
+	return n
+}
+
+func (n *Negotiator) Negotiate(locale, currency string) Prefs {
+	p := Prefs{Locale: language(locale, n.def.Locale)}
+	p.Currency, p.Rate = n.currency(currency, n.def.Currency, n.def.Rate)
+	return p
+}
+
+// language takes the supported language of a tag like "en-US" or "ru_RU"
+func language(tag, fallback string) string {
+	lang, _, _ := strings.Cut(strings.ReplaceAll(strings.ToLower(strings.TrimSpace(tag)), "_", "-"), "-")
+	switch lang {
+	case LocaleRU, LocaleEN:
+		return lang
+	default:
+		return fallback
+	}
+}
+
+func (n *Negotiator) currency(code, fallback string, fallbackRate float64) (string, float64) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == BaseCurrency {
+		return code, 1
+	}
+	if rate, ok := n.rates[code]; ok && rate > 0 {
+		return code, rate
+	}
+	return fallback, fallbackRate
+}
+
+type ctxKey struct{}
+
+func WithContext(ctx context.Context, p Prefs) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext returns preferences of the request, Default if none were negotiated
+func FromContext(ctx context.Context) Prefs {
+	if p, ok := ctx.Value(ctxKey{}).(Prefs); ok {
+		return p
+	}
+	return Default
+}
+
+const nbsp = "\u00a0" // Keeps the amount on one line
+
+var symbols = map[string]string{"RUB": "₽", "USD": "$", "EUR": "€", "GBP": "£", "CNY": "¥", "KZT": "₸"}
+
+// Price formats an amount in rubles converted to Currency: "1 097 ₽" or "12,50 $" in Russian, "₽1,097" or "$12.50" in English.
+// Rubles are whole, other currencies get cents.
+func (p Prefs) Price(rubles int64) string {
+	digits := 2
+	if p.Currency == BaseCurrency {
+		digits = 0
+	}
+	scale := math.Pow10(digits)
+	units := int64(math.Round(float64(rubles) / p.Rate * scale))
+
+	sign := ""
+	if units < 0 {
+		sign, units = "-", -units
+	}
+	group, decimal := ",", "."
+	if p.Locale == LocaleRU {
+		group, decimal = nbsp, ","
+	}
+	number := groupThousands(strconv.FormatInt(units/int64(scale), 10), group)
+	if digits > 0 {
+		number += decimal + strconv.FormatInt(units%int64(scale)+int64(scale), 10)[1:]
+	}
+
+	symbol, ok := symbols[p.Currency]
+	if !ok {
+		symbol = p.Currency
+	}
+	if p.Locale == LocaleRU {
+		return sign + number + nbsp + symbol
+	}
+	if !ok {
+		return sign + symbol + nbsp + number
+	}
+	return sign + symbol + number
+}
+
+func groupThousands(digits, sep string) string {
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+var monthsRU = [12]string{"январь", "февраль", "март", "апрель", "май", "июнь", "июль", "август", "сентябрь", "октябрь", "ноябрь", "декабрь"}
+
+// Month formats the month with its localized name: "январь 2024" or "January 2024"
+func (p Prefs) Month(t time.Time) string {
+	name := t.Month().String()
+	if p.Locale == LocaleRU {
+		name = monthsRU[t.Month()-1]
+	}
+	return name + " " + strconv.Itoa(t.Year())
+}
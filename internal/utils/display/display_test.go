@@ -0,0 +1,68 @@
+package display
+
+import (
+	"testing"
+
+	"time"
+)
+
+func TestPrice(t *testing.T) {
+	tests := []struct {
+		prefs  Prefs
+		rubles int64
+		want   string
+	}{
+		{Prefs{Locale: LocaleRU, Currency: BaseCurrency, Rate: 1}, 1097, "1\u00a0097\u00a0₽"},
+		{Prefs{Locale: LocaleRU, Currency: BaseCurrency, Rate: 1}, 1234567, "1\u00a0234\u00a0567\u00a0₽"},
+		{Prefs{Locale: LocaleRU, Currency: BaseCurrency, Rate: 1}, -200, "-200\u00a0₽"},
+		{Prefs{Locale: LocaleEN, Currency: BaseCurrency, Rate: 1}, 1097, "₽1,097"},
+		{Prefs{Locale: LocaleEN, Currency: "USD", Rate: 80}, 1000, "$12.50"},
+		{Prefs{Locale: LocaleRU, Currency: "USD", Rate: 80}, 1000, "12,50\u00a0$"},
+		{Prefs{Locale: LocaleEN, Currency: "USD", Rate: 80}, 84, "$1.05"},
+		{Prefs{Locale: LocaleEN, Currency: "USD", Rate: 80}, -400, "-$5.00"},
+		{Prefs{Locale: LocaleEN, Currency: "CHF", Rate: 100}, 150000, "CHF\u00a01,500.00"},
+		{Prefs{Locale: LocaleEN, Currency: BaseCurrency, Rate: 1}, 0, "₽0"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.prefs.Price(tt.rubles); got != tt.want {
+			t.Errorf("%+v.Price(%d) = %q, want %q", tt.prefs, tt.rubles, got, tt.want)
+		}
+	}
+}
+
+func TestMonth(t *testing.T) {
+	march := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if got := (Prefs{Locale: LocaleRU}).Month(march); got != "март 2024" {
+		t.Errorf("Month() in ru = %q, want %q", got, "март 2024")
+	}
+	if got := (Prefs{Locale: LocaleEN}).Month(march); got != "March 2024" {
+		t.Errorf("Month() in en = %q, want %q", got, "March 2024")
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	n := NewNegotiator("en", "USD", map[string]float64{"USD": 80, "EUR": 90})
+
+	tests := []struct {
+		name     string
+		locale   string
+		currency string
+		want     Prefs
+	}{
+		{name: "defaults", want: Prefs{Locale: LocaleEN, Currency: "USD", Rate: 80}},
+		{name: "region tag", locale: "ru-RU", currency: "eur", want: Prefs{Locale: LocaleRU, Currency: "EUR", Rate: 90}},
+		{name: "underscore tag", locale: "ru_RU", currency: "RUB", want: Prefs{Locale: LocaleRU, Currency: BaseCurrency, Rate: 1}},
+		{name: "unsupported values fall back", locale: "de", currency: "JPY", want: Prefs{Locale: LocaleEN, Currency: "USD", Rate: 80}},
+	}
+
+	for _, tt := range tests {
+		if got := n.Negotiate(tt.locale, tt.currency); got != tt.want {
+			t.Errorf("%s: Negotiate(%q, %q) = %+v, want %+v", tt.name, tt.locale, tt.currency, got, tt.want)
+		}
+	}
+
+	if got := NewNegotiator("fr", "JPY", nil).Negotiate("", ""); got != Default {
+		t.Errorf("unsupported defaults = %+v, want %+v", got, Default)
+	}
+}
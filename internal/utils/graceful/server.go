@@ -0,0 +1,50 @@
+package graceful
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// Server serves HTTP in background until shut down, so the caller decides when to stop it
+type Server struct {
+	server *http.Server
+	done   chan struct{}
+	err    error
+}
+
+// Serve binds addr right away, so a busy port fails the caller instead of the background goroutine
+func Serve(handler http.Handler, addr string) (*Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{server: &http.Server{Addr: addr, Handler: handler}, done: make(chan struct{})}
+	go func() {
+		if err := s.server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			s.err = err
+		}
+		close(s.done)
+	}()
+	return s, nil
+}
+
+// Done is closed when the server stops, Err tells why if it wasn't Shutdown
+func (s *Server) Done() <-chan struct{} {
+	return s.done
+}
+
+func (s *Server) Err() error {
+	<-s.done
+	return s.err
+}
+
+// Shutdown stops accepting connections and waits for active requests until ctx is done
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.server.Shutdown(ctx); err != nil {
+		return err
+	}
+	return s.Err()
+}
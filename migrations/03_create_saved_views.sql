@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS saved_views (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    name text NOT NULL,
+    service_name text NULL,
+    created_after timestamptz NULL,
+    created_before timestamptz NULL,
+    row_limit integer NULL CHECK (row_limit > 0),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (user_id, name)
+    );
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS saved_views;
+-- +goose StatementEnd
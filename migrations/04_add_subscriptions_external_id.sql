@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS external_id text NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subscriptions_user_external_id ON subscriptions(user_id, external_id)
+    WHERE external_id IS NOT NULL AND deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_subscriptions_user_external_id;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS external_id;
+-- +goose StatementEnd
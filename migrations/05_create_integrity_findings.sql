@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS integrity_findings (
+    check_name text NOT NULL,
+    subscription_id uuid NOT NULL,
+    details text NOT NULL,
+    detected_at timestamptz NOT NULL DEFAULT now(),
+    checked_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (check_name, subscription_id)
+    );
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS integrity_findings;
+-- +goose StatementEnd
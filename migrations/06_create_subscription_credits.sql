@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS subscription_credits (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    subscription_id uuid NOT NULL REFERENCES subscriptions(id),
+    amount integer NOT NULL CHECK (amount < 0),
+    start_date date NOT NULL,
+    end_date date NULL,
+    description text NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT now()
+    );
+CREATE INDEX IF NOT EXISTS idx_subscription_credits_subscription_id ON subscription_credits(subscription_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS subscription_credits;
+-- +goose StatementEnd
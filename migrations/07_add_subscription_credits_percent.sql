@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE subscription_credits ADD COLUMN IF NOT EXISTS percent smallint NOT NULL DEFAULT 0 CHECK (percent BETWEEN 0 AND 100);
+ALTER TABLE subscription_credits DROP CONSTRAINT IF EXISTS subscription_credits_amount_check;
+ALTER TABLE subscription_credits ADD CONSTRAINT subscription_credits_amount_or_percent_check
+    CHECK (amount <= 0 AND (amount < 0) <> (percent > 0));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM subscription_credits WHERE percent > 0;
+ALTER TABLE subscription_credits DROP CONSTRAINT IF EXISTS subscription_credits_amount_or_percent_check;
+ALTER TABLE subscription_credits ADD CONSTRAINT subscription_credits_amount_check CHECK (amount < 0);
+ALTER TABLE subscription_credits DROP COLUMN IF EXISTS percent;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS type text NOT NULL DEFAULT 'recurring'
+    CHECK (type IN ('recurring', 'one_time'));
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_one_time_single_month_check
+    CHECK (type <> 'one_time' OR end_date = start_date);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE subscriptions DROP CONSTRAINT IF EXISTS subscriptions_one_time_single_month_check;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS type;
+-- +goose StatementEnd
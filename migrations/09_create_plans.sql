@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS plans (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    service_name text NOT NULL,
+    name text NOT NULL,
+    price integer NOT NULL CHECK (price >= 0),
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (service_name, name)
+    );
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS plan_id uuid NULL REFERENCES plans(id);
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS follow_plan_price boolean NOT NULL DEFAULT false;
+CREATE INDEX IF NOT EXISTS idx_subscriptions_plan_id ON subscriptions(plan_id) WHERE follow_plan_price;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_subscriptions_plan_id;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS follow_plan_price;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS plan_id;
+DROP TABLE IF EXISTS plans;
+-- +goose StatementEnd
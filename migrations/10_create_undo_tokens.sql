@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS undo_tokens (
+    token uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    subscription_id uuid NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    expires_at timestamptz NOT NULL
+    );
+CREATE INDEX IF NOT EXISTS idx_undo_tokens_expires_at ON undo_tokens(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS undo_tokens;
+-- +goose StatementEnd
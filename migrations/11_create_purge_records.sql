@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS purge_records (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    subject text NOT NULL CHECK (subject IN ('subscription', 'user')),
+    subject_id uuid NOT NULL,
+    subscriptions integer NOT NULL,
+    views integer NOT NULL,
+    purged_at timestamptz NOT NULL DEFAULT now()
+    );
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS purge_records;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_name_lower ON subscriptions(lower(service_name));
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_name_trgm ON subscriptions USING gin (service_name gin_trgm_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_subscriptions_service_name_trgm;
+DROP INDEX IF EXISTS idx_subscriptions_service_name_lower;
+DROP EXTENSION IF EXISTS pg_trgm;
+-- +goose StatementEnd
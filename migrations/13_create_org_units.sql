@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS org_units (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    parent_id uuid NULL REFERENCES org_units(id),
+    name text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+    );
+CREATE UNIQUE INDEX IF NOT EXISTS idx_org_units_parent_name ON org_units(COALESCE(parent_id, '00000000-0000-0000-0000-000000000000'::uuid), name);
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS org_unit_id uuid NULL REFERENCES org_units(id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_org_unit_id ON subscriptions(org_unit_id) WHERE org_unit_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_subscriptions_org_unit_id;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS org_unit_id;
+DROP TABLE IF EXISTS org_units;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS cost_center text NULL;
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS project_code text NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS project_code;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS cost_center;
+-- +goose StatementEnd
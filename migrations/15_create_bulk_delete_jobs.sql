@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS bulk_delete_jobs (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    status text NOT NULL CHECK (status IN ('pending', 'running', 'done', 'failed')),
+    user_id uuid NULL,
+    service_name text NULL,
+    start_date date NULL,
+    end_date date NULL,
+    matched bigint NOT NULL DEFAULT 0,
+    deleted bigint NOT NULL DEFAULT 0,
+    error text NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    finished_at timestamptz NULL
+    );
+CREATE INDEX IF NOT EXISTS idx_bulk_delete_jobs_unfinished ON bulk_delete_jobs(updated_at) WHERE status IN ('pending', 'running');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS bulk_delete_jobs;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS paused_periods (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    subscription_id uuid NOT NULL REFERENCES subscriptions(id),
+    start_date date NOT NULL,
+    end_date date NULL CHECK (end_date >= start_date),
+    created_at timestamptz NOT NULL DEFAULT now()
+    );
+CREATE INDEX IF NOT EXISTS idx_paused_periods_subscription_id ON paused_periods(subscription_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_paused_periods_open ON paused_periods(subscription_id) WHERE end_date IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS paused_periods;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS service_renames (
+    id uuid PRIMARY KEY,
+    from_name text NOT NULL,
+    to_name text NOT NULL,
+    org_unit_id uuid NULL,
+    renamed bigint NOT NULL DEFAULT 0,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    finished_at timestamptz NULL
+    );
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS service_renames;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE saved_views
+    ADD COLUMN IF NOT EXISTS sort_by text NULL,
+    ADD COLUMN IF NOT EXISTS sort_order text NULL,
+    ADD COLUMN IF NOT EXISTS min_price integer NULL CHECK (min_price >= 0),
+    ADD COLUMN IF NOT EXISTS max_price integer NULL CHECK (max_price >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE saved_views
+    DROP COLUMN IF EXISTS sort_by,
+    DROP COLUMN IF EXISTS sort_order,
+    DROP COLUMN IF EXISTS min_price,
+    DROP COLUMN IF EXISTS max_price;
+-- +goose StatementEnd
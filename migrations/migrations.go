@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// Migration is the "Up" section of a goose migration, versioned by its file name prefix (05_... is version 5)
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+}
+
+// All returns embedded migrations in the order they would be applied
+func All() ([]Migration, error) {
+	names, err := fs.Glob(FS, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	all := make([]Migration, 0, len(names))
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: file name must start with a version number", name)
+		}
+		content, err := FS.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		up, _, found := strings.Cut(string(content), "-- +goose Down")
+		if !found || !strings.Contains(up, "-- +goose Up") {
+			return nil, fmt.Errorf("migration %s: missing goose Up/Down markers", name)
+		}
+		all = append(all, Migration{Version: version, Name: name, Up: up})
+	}
+	return all, nil
+}
+
+// UpScripts returns "Up" sections of goose migrations in the order they would be applied
+func UpScripts() ([]string, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	scripts := make([]string, 0, len(all))
+	for _, m := range all {
+		scripts = append(scripts, m.Up)
+	}
+	return scripts, nil
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import "testing"
+
+func TestAll(t *testing.T) {
+	all, err := All()
+	if err != nil {
+		t.Fatalf("All() unexpected error: %v", err)
+	}
+	if len(all) == 0 {
+		t.Fatal("All() returned no migrations")
+	}
+	for i, m := range all {
+		if m.Version != int64(i+1) {
+			t.Errorf("migration %s has version %d, want %d: versions must be consecutive", m.Name, m.Version, i+1)
+		}
+	}
+
+	pending, err := after(all[len(all)-2].Version)
+	if err != nil {
+		t.Fatalf("after() unexpected error: %v", err)
+	}
+	if len(pending) != 1 || pending[0].Name != all[len(all)-1].Name {
+		t.Errorf("after() = %v, want only the latest migration", pending)
+	}
+}
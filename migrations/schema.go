@@ -0,0 +1,95 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// versionTable is where goose records applied migrations, shared with the migrator container
+const versionTable = "goose_db_version"
+
+// applyLockKey is the advisory lock held while applying, so replicas starting at once don't apply twice
+const applyLockKey = 0x5ab5c42
+
+// Pending returns embedded migrations the database hasn't applied yet, a database goose never touched has all of them pending
+func Pending(ctx context.Context, db *gorm.DB) ([]Migration, error) {
+	current, err := currentVersion(db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return after(current)
+}
+
+// Apply runs pending migrations in one transaction and records them the way goose does,
+// so the migrator container and replicas applying on boot agree on the schema version
+func Apply(ctx context.Context, db *gorm.DB) ([]Migration, error) {
+	var applied []Migration
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", applyLockKey).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`CREATE TABLE IF NOT EXISTS ` + versionTable + ` (
+			id serial PRIMARY KEY,
+			version_id bigint NOT NULL,
+			is_applied boolean NOT NULL,
+			tstamp timestamp NULL DEFAULT now()
+		)`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("INSERT INTO " + versionTable + " (version_id, is_applied) SELECT 0, true WHERE NOT EXISTS (SELECT 1 FROM " + versionTable + ")").Error; err != nil {
+			return err // goose expects the initial version 0 row in a table it didn't create itself
+		}
+
+		current, err := currentVersion(tx) // Re-read under the lock, another replica may have just applied them
+		if err != nil {
+			return err
+		}
+		pending, err := after(current)
+		if err != nil {
+			return err
+		}
+		for _, m := range pending {
+			if err = tx.Exec(m.Up).Error; err != nil {
+				return fmt.Errorf("migration %s: %w", m.Name, err)
+			}
+			if err = tx.Exec("INSERT INTO "+versionTable+" (version_id, is_applied) VALUES (?, true)", m.Version).Error; err != nil {
+				return err
+			}
+		}
+		applied = pending
+		return nil
+	})
+	return applied, err
+}
+
+func currentVersion(db *gorm.DB) (int64, error) {
+	var exists bool
+	if err := db.Raw("SELECT to_regclass(?) IS NOT NULL", versionTable).Scan(&exists).Error; err != nil {
+		return 0, err
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var version int64
+	if err := db.Raw("SELECT COALESCE(MAX(version_id), 0) FROM " + versionTable + " WHERE is_applied").Scan(&version).Error; err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+func after(version int64) ([]Migration, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, m := range all {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
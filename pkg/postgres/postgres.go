@@ -16,18 +16,41 @@ type Config struct {
 	Password string
 	Database string
 	SSLMode  string
+	SSLRoot  string // (Optional) CA bundle for verify-ca/verify-full, images without system certs need it
 	LogLevel string
+	Schema   string // (Optional) search_path for all pooled connections
+	ReadOnly bool   // Sessions default to read-only transactions, so any write fails even if the role may write
 }
 
 func NewInstance(cfg Config) *gorm.DB {
+	db, err := Open(cfg)
+	if err != nil {
+		log.Fatalf("Fatal: %v", err)
+	}
+	return db
+}
+
+// Open is NewInstance for callers that handle the error themselves
+func Open(cfg Config) (*gorm.DB, error) {
 	fmt.Print("Connecting to Postgres... ")
 
-	db, err := gorm.Open(postgres.Open(fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Database, cfg.SSLMode,
-	)), &gorm.Config{})
+	)
+	if cfg.SSLRoot != "" {
+		dsn += fmt.Sprintf(" sslrootcert=%s", cfg.SSLRoot)
+	}
+	if cfg.Schema != "" {
+		dsn += fmt.Sprintf(" search_path=%s", cfg.Schema)
+	}
+	if cfg.ReadOnly {
+		dsn += " default_transaction_read_only=on"
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
 		fmt.Println()
-		log.Fatalf("Fatal: failed to connect to database: %v", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
 	switch cfg.LogLevel {
@@ -40,5 +63,5 @@ func NewInstance(cfg Config) *gorm.DB {
 	}
 
 	fmt.Println("Done.")
-	return db
+	return db, nil
 }
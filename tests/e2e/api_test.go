@@ -10,71 +10,37 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"net/http/httptest"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
-	"subscription-aggregator-service/internal/api/controllers"
 	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/config"
 	"subscription-aggregator-service/internal/models"
-	"subscription-aggregator-service/internal/service"
 	"subscription-aggregator-service/internal/storage"
+	"subscription-aggregator-service/tests/factory"
 	"subscription-aggregator-service/tests/testutils"
 )
 
 type E2ETestSuite struct {
 	suite.Suite
 	container *testutils.PostgresContainer
-	server    *httptest.Server
-	router    *gin.Engine
 	ctx       context.Context
 	baseURL   string
 }
 
 func (s *E2ETestSuite) SetupSuite() {
 	s.ctx = context.Background()
-	gin.SetMode(gin.TestMode)
-
-	var err error
-	s.container, err = testutils.SetupPostgresContainer(s.ctx)
-	require.NoError(s.T(), err, "Failed to setup postgres container")
-
-	err = s.container.RunMigrations(s.ctx)
-	require.NoError(s.T(), err, "Failed to run migrations")
-
-	st := storage.NewSubscriptionsStorage(s.container.DB)
-	svc := service.NewSubscriptionService(st)
-	ctrl := controllers.NewSubscriptionController(svc)
-
-	s.router = gin.New()
-	s.router.Use(gin.Recovery())
-
-	api := s.router.Group("/api/v1")
-	{
-		api.POST("/subscriptions", ctrl.CreateSubscription)
-		api.GET("/subscriptions/:id", ctrl.GetSubscriptionByID)
-		api.PUT("/subscriptions/:id", ctrl.UpdateSubscriptionByID)
-		api.DELETE("/subscriptions/:id", ctrl.DeleteSubscriptionByID)
-		api.GET("/subscriptions", ctrl.ListSubscriptions)
-		api.GET("/subscriptions/total", ctrl.TotalSubscriptionsCost)
-	}
-
-	s.server = httptest.NewServer(s.router)
-	s.baseURL = s.server.URL + "/api/v1"
-}
 
-func (s *E2ETestSuite) TearDownSuite() {
-	if s.server != nil {
-		s.server.Close()
-	}
-	if s.container != nil {
-		_ = s.container.Teardown(s.ctx)
-	}
+	s.container = testutils.NewTestDatabase(s.T())
+	api := testutils.NewTestAPI(s.T(),
+		testutils.WithStorage(storage.NewSubscriptionsStorage(s.container.DB)),
+		testutils.WithConfig(config.UndoWindow, "10m"),
+	)
+	s.baseURL = api.BaseURL
 }
 
 func (s *E2ETestSuite) SetupTest() {
@@ -83,22 +49,16 @@ func (s *E2ETestSuite) SetupTest() {
 }
 
 func (s *E2ETestSuite) TestFullCRUDFlow() {
-	userID := uuid.New().String()
+	userID := uuid.New()
 
 	// 1. CREATE
-	createReq := apiModels.CreateSubscriptionRequest{
-		ServiceName: "Netflix",
-		Price:       299,
-		UserID:      userID,
-		StartDate:   "01-2024",
-	}
-	body, _ := json.Marshal(createReq)
+	body := factory.Subscription().WithUser(userID).JSON()
 
 	resp, err := http.Post(s.baseURL+"/subscriptions", "application/json", bytes.NewBuffer(body))
 	require.NoError(s.T(), err)
 	assert.Equal(s.T(), http.StatusCreated, resp.StatusCode)
 
-	var createdSub models.Subscription
+	var createdSub apiModels.SubscriptionResponse
 	err = json.NewDecoder(resp.Body).Decode(&createdSub)
 	require.NoError(s.T(), err)
 	resp.Body.Close()
@@ -112,7 +72,7 @@ func (s *E2ETestSuite) TestFullCRUDFlow() {
 	require.NoError(s.T(), err)
 	assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
 
-	var retrievedSub models.Subscription
+	var retrievedSub apiModels.SubscriptionResponse
 	err = json.NewDecoder(resp.Body).Decode(&retrievedSub)
 	require.NoError(s.T(), err)
 	resp.Body.Close()
@@ -131,13 +91,14 @@ func (s *E2ETestSuite) TestFullCRUDFlow() {
 
 	req, _ := http.NewRequest(http.MethodPut, s.baseURL+"/subscriptions/"+createdSub.ID.String(), bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", fmt.Sprintf(`"%d"`, createdSub.Version))
 
 	client := &http.Client{}
 	resp, err = client.Do(req)
 	require.NoError(s.T(), err)
 	assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
 
-	var updatedSub models.Subscription
+	var updatedSub apiModels.SubscriptionResponse
 	err = json.NewDecoder(resp.Body).Decode(&updatedSub)
 	require.NoError(s.T(), err)
 	resp.Body.Close()
@@ -146,23 +107,28 @@ func (s *E2ETestSuite) TestFullCRUDFlow() {
 	assert.Equal(s.T(), 499, updatedSub.Price)
 
 	// 4. LIST
-	resp, err = http.Get(s.baseURL + "/subscriptions?user_id=" + userID)
+	resp, err = http.Get(s.baseURL + "/subscriptions?user_id=" + userID.String())
 	require.NoError(s.T(), err)
 	assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
 
-	var subs []models.Subscription
-	err = json.NewDecoder(resp.Body).Decode(&subs)
+	var list apiModels.ListSubscriptionsResponse
+	err = json.NewDecoder(resp.Body).Decode(&list)
 	require.NoError(s.T(), err)
 	resp.Body.Close()
 
-	assert.Len(s.T(), subs, 1)
-	assert.Equal(s.T(), "Netflix Premium", subs[0].ServiceName)
+	require.Len(s.T(), list.Items, 1)
+	assert.Equal(s.T(), int64(1), list.TotalCount)
+	assert.Equal(s.T(), "Netflix Premium", list.Items[0].ServiceName)
 
 	// 5. DELETE
 	req, _ = http.NewRequest(http.MethodDelete, s.baseURL+"/subscriptions/"+createdSub.ID.String(), nil)
 	resp, err = client.Do(req)
 	require.NoError(s.T(), err)
 	assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
+
+	var undo models.UndoToken
+	err = json.NewDecoder(resp.Body).Decode(&undo)
+	require.NoError(s.T(), err)
 	resp.Body.Close()
 
 	// 6. Verify
@@ -170,31 +136,30 @@ func (s *E2ETestSuite) TestFullCRUDFlow() {
 	require.NoError(s.T(), err)
 	assert.Equal(s.T(), http.StatusNotFound, resp.StatusCode)
 	resp.Body.Close()
+
+	// 7. UNDO
+	resp, err = http.Post(s.baseURL+"/undo/"+undo.Token.String(), "application/json", nil)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	resp, err = http.Get(s.baseURL + "/subscriptions/" + createdSub.ID.String())
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
 }
 
 func (s *E2ETestSuite) TestTotalCostCalculation() {
-	userID := uuid.New().String()
+	userID := uuid.New()
 
 	// Create multiple subscriptions
-	subscriptions := []apiModels.CreateSubscriptionRequest{
-		{
-			ServiceName: "Netflix",
-			Price:       100,
-			UserID:      userID,
-			StartDate:   "01-2024",
-			EndDate:     strPtr("12-2024"),
-		},
-		{
-			ServiceName: "Spotify",
-			Price:       200,
-			UserID:      userID,
-			StartDate:   "06-2024",
-		},
+	subscriptions := []*factory.SubscriptionBuilder{
+		factory.Subscription().WithUser(userID).WithPrice(100).Ending("12-2024"),
+		factory.Subscription().WithUser(userID).WithService("Spotify").WithPrice(200).Starting("06-2024"),
 	}
 
 	for _, sub := range subscriptions {
-		body, _ := json.Marshal(sub)
-		resp, err := http.Post(s.baseURL+"/subscriptions", "application/json", bytes.NewBuffer(body))
+		resp, err := http.Post(s.baseURL+"/subscriptions", "application/json", bytes.NewBuffer(sub.JSON()))
 		require.NoError(s.T(), err)
 		assert.Equal(s.T(), http.StatusCreated, resp.StatusCode)
 		resp.Body.Close()
@@ -219,12 +184,7 @@ func (s *E2ETestSuite) TestTotalCostCalculation() {
 
 func (s *E2ETestSuite) TestValidationErrors() {
 	// Missing required fields
-	createReq := apiModels.CreateSubscriptionRequest{
-		Price:     299,
-		UserID:    uuid.New().String(),
-		StartDate: "01-2024",
-	}
-	body, _ := json.Marshal(createReq)
+	body := factory.Subscription().WithService("").JSON()
 
 	resp, err := http.Post(s.baseURL+"/subscriptions", "application/json", bytes.NewBuffer(body))
 	require.NoError(s.T(), err)
@@ -238,13 +198,7 @@ func (s *E2ETestSuite) TestValidationErrors() {
 	resp.Body.Close()
 
 	// Invalid date format
-	createReq = apiModels.CreateSubscriptionRequest{
-		ServiceName: "Test",
-		Price:       299,
-		UserID:      uuid.New().String(),
-		StartDate:   "2024-01", // Wrong format
-	}
-	body, _ = json.Marshal(createReq)
+	body = factory.Subscription().Starting("2024-01").JSON() // Wrong format
 
 	resp, err = http.Post(s.baseURL+"/subscriptions", "application/json", bytes.NewBuffer(body))
 	require.NoError(s.T(), err)
@@ -271,53 +225,44 @@ func (s *E2ETestSuite) TestNotFound() {
 }
 
 func (s *E2ETestSuite) TestListWithFilters() {
-	userID1 := uuid.New().String()
-	userID2 := uuid.New().String()
+	userID1 := uuid.New()
+	userID2 := uuid.New()
 
 	// Create subscriptions for different users
-	subs := []apiModels.CreateSubscriptionRequest{
-		{ServiceName: "Netflix", Price: 100, UserID: userID1, StartDate: "01-2024"},
-		{ServiceName: "Spotify", Price: 200, UserID: userID1, StartDate: "01-2024"},
-		{ServiceName: "Netflix", Price: 100, UserID: userID2, StartDate: "01-2024"},
+	subs := []*factory.SubscriptionBuilder{
+		factory.Subscription().WithUser(userID1).WithPrice(100),
+		factory.Subscription().WithUser(userID1).WithService("Spotify").WithPrice(200),
+		factory.Subscription().WithUser(userID2).WithPrice(100),
 	}
 
 	for _, sub := range subs {
-		body, _ := json.Marshal(sub)
-		resp, _ := http.Post(s.baseURL+"/subscriptions", "application/json", bytes.NewBuffer(body))
+		resp, _ := http.Post(s.baseURL+"/subscriptions", "application/json", bytes.NewBuffer(sub.JSON()))
 		resp.Body.Close()
 	}
 
 	// Filter by user
-	resp, _ := http.Get(s.baseURL + "/subscriptions?user_id=" + userID1)
-	var result []models.Subscription
+	resp, _ := http.Get(s.baseURL + "/subscriptions?user_id=" + userID1.String())
+	var result apiModels.ListSubscriptionsResponse
 	json.NewDecoder(resp.Body).Decode(&result)
 	resp.Body.Close()
-	assert.Len(s.T(), result, 2)
+	assert.Len(s.T(), result.Items, 2)
 
 	// Filter by service name
 	resp, _ = http.Get(s.baseURL + "/subscriptions?service_name=Netflix")
 	json.NewDecoder(resp.Body).Decode(&result)
 	resp.Body.Close()
-	assert.Len(s.T(), result, 2)
+	assert.Len(s.T(), result.Items, 2)
 
 	// Filter by both
-	resp, _ = http.Get(s.baseURL + "/subscriptions?user_id=" + userID1 + "&service_name=Netflix")
+	resp, _ = http.Get(s.baseURL + "/subscriptions?user_id=" + userID1.String() + "&service_name=Netflix")
 	json.NewDecoder(resp.Body).Decode(&result)
 	resp.Body.Close()
-	assert.Len(s.T(), result, 1)
+	assert.Len(s.T(), result.Items, 1)
 }
 
 func (s *E2ETestSuite) TestResponseTimes() {
-	userID := uuid.New().String()
-
 	// Create subscription
-	createReq := apiModels.CreateSubscriptionRequest{
-		ServiceName: "Netflix",
-		Price:       299,
-		UserID:      userID,
-		StartDate:   "01-2024",
-	}
-	body, _ := json.Marshal(createReq)
+	body := factory.Subscription().JSON()
 
 	start := time.Now()
 	resp, err := http.Post(s.baseURL+"/subscriptions", "application/json", bytes.NewBuffer(body))
@@ -343,7 +288,3 @@ func TestE2ESuite(t *testing.T) {
 	}
 	suite.Run(t, new(E2ETestSuite))
 }
-
-func strPtr(s string) *string {
-	return &s
-}
@@ -7,20 +7,17 @@ import (
 
 	"bytes"
 	"context"
-	"encoding/json"
 	"net/http"
-	"net/http/httptest"
 
-	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
-	"subscription-aggregator-service/internal/api/controllers"
 	apiModels "subscription-aggregator-service/internal/api/models"
 	"subscription-aggregator-service/internal/models"
 	"subscription-aggregator-service/internal/service"
 	"subscription-aggregator-service/internal/storage"
+	"subscription-aggregator-service/tests/factory"
 	"subscription-aggregator-service/tests/testutils"
 )
 
@@ -29,36 +26,11 @@ func TestSmoke_ServiceStarts(t *testing.T) {
 		t.Skip("Skipping smoke tests in short mode")
 	}
 
-	ctx := context.Background()
-	gin.SetMode(gin.TestMode)
-
-	// Setup container
-	container, err := testutils.SetupPostgresContainer(ctx)
-	require.NoError(t, err, "Service should be able to connect to database")
-	defer container.Teardown(ctx)
-
-	err = container.RunMigrations(ctx)
-	require.NoError(t, err, "Migrations should run successfully")
+	// Setup database, migrations are applied by the helper
+	container := testutils.NewTestDatabase(t)
 
 	// Setup application
-	st := storage.NewSubscriptionsStorage(container.DB)
-	svc := service.NewSubscriptionService(st)
-	ctrl := controllers.NewSubscriptionController(svc)
-
-	router := gin.New()
-	router.Use(gin.Recovery())
-	api := router.Group("/api/v1")
-	{
-		api.POST("/subscriptions", ctrl.CreateSubscription)
-		api.GET("/subscriptions/:id", ctrl.GetSubscriptionByID)
-		api.GET("/subscriptions", ctrl.ListSubscriptions)
-		api.GET("/subscriptions/total", ctrl.TotalSubscriptionsCost)
-	}
-
-	server := httptest.NewServer(router)
-	defer server.Close()
-
-	baseURL := server.URL + "/api/v1"
+	baseURL := testutils.NewTestAPI(t, testutils.WithStorage(storage.NewSubscriptionsStorage(container.DB))).BaseURL
 
 	t.Run("List endpoint responds", func(t *testing.T) {
 		resp, err := http.Get(baseURL + "/subscriptions")
@@ -68,15 +40,7 @@ func TestSmoke_ServiceStarts(t *testing.T) {
 	})
 
 	t.Run("Create endpoint responds", func(t *testing.T) {
-		req := apiModels.CreateSubscriptionRequest{
-			ServiceName: "Test",
-			Price:       100,
-			UserID:      uuid.New().String(),
-			StartDate:   "01-2024",
-		}
-		body, _ := json.Marshal(req)
-
-		resp, err := http.Post(baseURL+"/subscriptions", "application/json", bytes.NewBuffer(body))
+		resp, err := http.Post(baseURL+"/subscriptions", "application/json", bytes.NewBuffer(factory.Subscription().JSON()))
 		require.NoError(t, err)
 		defer resp.Body.Close()
 		assert.Equal(t, http.StatusCreated, resp.StatusCode)
@@ -105,23 +69,7 @@ func TestSmoke_ServiceStarts(t *testing.T) {
 }
 
 func TestSmoke_HandlersRespond(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-
-	mockSvc := &mockService{}
-	ctrl := controllers.NewSubscriptionController(mockSvc)
-
-	router := gin.New()
-	api := router.Group("/api/v1")
-	{
-		api.POST("/subscriptions", ctrl.CreateSubscription)
-		api.GET("/subscriptions/:id", ctrl.GetSubscriptionByID)
-		api.GET("/subscriptions", ctrl.ListSubscriptions)
-	}
-
-	server := httptest.NewServer(router)
-	defer server.Close()
-
-	baseURL := server.URL + "/api/v1"
+	baseURL := testutils.NewTestAPI(t, testutils.WithService(&mockService{})).BaseURL
 
 	// Just verify endpoints are reachable
 	endpoints := []string{
@@ -145,22 +93,170 @@ func (m *mockService) CreateSubscription(ctx context.Context, req *apiModels.Cre
 	return nil, service.ErrValidationError
 }
 
+func (m *mockService) CreateSubscriptionIfAbsent(ctx context.Context, req *apiModels.CreateSubscriptionRequest) (*models.Subscription, bool, error) {
+	return nil, false, service.ErrValidationError
+}
+
 func (m *mockService) GetSubscriptionByID(ctx context.Context, req apiModels.ItemByIDRequest) (*models.Subscription, error) {
 	return nil, service.ErrNotFound
 }
 
+func (m *mockService) SubscriptionExists(ctx context.Context, req apiModels.ItemByIDRequest) error {
+	return service.ErrNotFound
+}
+
 func (m *mockService) UpdateSubscriptionByID(ctx context.Context, req apiModels.ItemByIDRequest, update *apiModels.UpdateSubscriptionRequest) (*models.Subscription, error) {
 	return nil, service.ErrNotFound
 }
 
-func (m *mockService) DeleteSubscriptionByID(ctx context.Context, req apiModels.ItemByIDRequest) error {
-	return service.ErrNotFound
+func (m *mockService) CancelSubscription(ctx context.Context, req apiModels.ItemByIDRequest, cancel *apiModels.CancelSubscriptionRequest) (*models.Subscription, error) {
+	return nil, service.ErrNotFound
 }
 
-func (m *mockService) ListSubscriptions(ctx context.Context, req apiModels.ListSubscriptionsRequest) ([]models.Subscription, error) {
-	return []models.Subscription{}, nil
+func (m *mockService) RenewSubscription(ctx context.Context, req apiModels.ItemByIDRequest, renew *apiModels.RenewSubscriptionRequest) (*models.Subscription, error) {
+	return nil, service.ErrNotFound
+}
+
+func (m *mockService) PauseSubscription(ctx context.Context, req apiModels.ItemByIDRequest, pause *apiModels.PauseSubscriptionRequest) (*models.PausedPeriod, error) {
+	return nil, service.ErrNotFound
+}
+
+func (m *mockService) ResumeSubscription(ctx context.Context, req apiModels.ItemByIDRequest, pause *apiModels.PauseSubscriptionRequest) (*models.PausedPeriod, error) {
+	return nil, service.ErrNotFound
+}
+
+func (m *mockService) ListPausedPeriods(ctx context.Context, req apiModels.ItemByIDRequest) ([]models.PausedPeriod, error) {
+	return nil, service.ErrNotFound
+}
+
+func (m *mockService) RenameService(ctx context.Context, req *apiModels.RenameServiceRequest) (*models.ServiceRename, error) {
+	return &models.ServiceRename{ID: uuid.New(), FromName: req.From, ToName: req.To}, nil
+}
+
+func (m *mockService) DeleteSubscriptionByID(ctx context.Context, req apiModels.ItemByIDRequest) (*models.UndoToken, error) {
+	return nil, service.ErrNotFound
+}
+
+func (m *mockService) UndoDeletion(ctx context.Context, req apiModels.UndoRequest) (*models.Subscription, error) {
+	return nil, service.ErrUndoNotFound
+}
+
+func (m *mockService) PurgeSubscription(ctx context.Context, id apiModels.ItemByIDRequest) (*models.PurgeRecord, error) {
+	return nil, service.ErrNotFound
+}
+
+func (m *mockService) PurgeUserData(ctx context.Context, user apiModels.ItemByIDRequest) (*models.PurgeRecord, error) {
+	return nil, service.ErrValidationError
+}
+
+func (m *mockService) SyncSubscriptions(ctx context.Context, user apiModels.ItemByIDRequest, req *apiModels.SyncSubscriptionsRequest, dryRun bool) (*apiModels.SyncSubscriptionsResponse, error) {
+	return nil, service.ErrValidationError
+}
+
+func (m *mockService) BulkUpdateSubscriptions(ctx context.Context, user apiModels.ItemByIDRequest, req *apiModels.BulkUpdateSubscriptionsRequest) (*apiModels.BulkUpdateSubscriptionsResponse, error) {
+	return nil, service.ErrValidationError
+}
+
+func (m *mockService) CreateSubscriptionsBatch(ctx context.Context, reqs []apiModels.CreateSubscriptionRequest) (*apiModels.BatchCreateResponse, error) {
+	return nil, service.ErrValidationError
+}
+
+func (m *mockService) ImportSubscriptions(ctx context.Context, req *apiModels.ImportSubscriptionsRequest, allow []string) (*apiModels.ImportSubscriptionsResponse, error) {
+	return nil, service.ErrValidationError
+}
+
+func (m *mockService) ListSubscriptions(ctx context.Context, req apiModels.ListSubscriptionsRequest) (*apiModels.ListSubscriptionsResponse, error) {
+	return &apiModels.ListSubscriptionsResponse{Items: []apiModels.SubscriptionResponse{}}, nil
 }
 
 func (m *mockService) TotalSubscriptionsCost(ctx context.Context, req apiModels.TotalCostRequest) (*apiModels.TotalCostResponse, error) {
 	return &apiModels.TotalCostResponse{TotalCost: 0}, nil
 }
+
+func (m *mockService) TotalSubscriptionsCostBatch(ctx context.Context, req apiModels.BatchTotalCostRequest) (*apiModels.BatchTotalCostResponse, error) {
+	return &apiModels.BatchTotalCostResponse{Totals: []apiModels.UserTotalCost{}}, nil
+}
+
+func (m *mockService) Chargeback(ctx context.Context, req apiModels.ChargebackRequest) ([]apiModels.ChargebackRow, error) {
+	return []apiModels.ChargebackRow{}, nil
+}
+
+func (m *mockService) ListExpiringSubscriptions(ctx context.Context, req apiModels.ExpiringSubscriptionsRequest) (*apiModels.ExpiringSubscriptionsResponse, error) {
+	return &apiModels.ExpiringSubscriptionsResponse{Users: []apiModels.ExpiringUser{}}, nil
+}
+
+func (m *mockService) UserSummary(ctx context.Context, user apiModels.ItemByIDRequest) (*apiModels.UserSummaryResponse, error) {
+	return nil, service.ErrValidationError
+}
+
+func (m *mockService) ExplainTotalCost(ctx context.Context, req apiModels.TotalCostRequest) (*apiModels.CostExplanationResponse, error) {
+	return &apiModels.CostExplanationResponse{Items: []apiModels.CostExplanationItem{}}, nil
+}
+
+func (m *mockService) SuggestServiceNames(ctx context.Context, req apiModels.SuggestServicesRequest) (*apiModels.SuggestServicesResponse, error) {
+	return &apiModels.SuggestServicesResponse{Services: []string{}}, nil
+}
+
+func (m *mockService) CreateView(ctx context.Context, user apiModels.ItemByIDRequest, req *apiModels.CreateViewRequest) (*models.SavedView, error) {
+	return nil, service.ErrValidationError
+}
+
+func (m *mockService) ListViews(ctx context.Context, user apiModels.ItemByIDRequest) ([]models.SavedView, error) {
+	return []models.SavedView{}, nil
+}
+
+func (m *mockService) CreatePlan(ctx context.Context, req *apiModels.CreatePlanRequest) (*models.Plan, error) {
+	return nil, service.ErrValidationError
+}
+
+func (m *mockService) ListPlans(ctx context.Context) ([]models.Plan, error) {
+	return []models.Plan{}, nil
+}
+
+func (m *mockService) UpdatePlanPrice(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.UpdatePlanPriceRequest) (*apiModels.UpdatePlanPriceResponse, error) {
+	return nil, service.ErrPlanNotFound
+}
+
+func (m *mockService) CreateOrgUnit(ctx context.Context, req *apiModels.CreateOrgUnitRequest) (*models.OrgUnit, error) {
+	return nil, service.ErrValidationError
+}
+
+func (m *mockService) ListOrgUnits(ctx context.Context) ([]models.OrgUnit, error) {
+	return []models.OrgUnit{}, nil
+}
+
+func (m *mockService) OrgUnitTotalCost(ctx context.Context, id apiModels.ItemByIDRequest, req apiModels.OrgUnitCostRequest) (*apiModels.OrgUnitCost, error) {
+	return nil, service.ErrOrgUnitNotFound
+}
+
+func (m *mockService) OrgUnitUsage(ctx context.Context, id apiModels.ItemByIDRequest) (*apiModels.OrgUnitUsage, error) {
+	return nil, service.ErrOrgUnitNotFound
+}
+
+func (m *mockService) StartBulkDelete(ctx context.Context, req *apiModels.BulkDeleteRequest) (*models.BulkDeleteJob, error) {
+	return nil, service.ErrValidationError
+}
+
+func (m *mockService) GetBulkDeleteJob(ctx context.Context, id apiModels.ItemByIDRequest) (*models.BulkDeleteJob, error) {
+	return nil, service.ErrBulkDeleteNotFound
+}
+
+func (m *mockService) ResumeBulkDeletes(ctx context.Context) error {
+	return nil
+}
+
+func (m *mockService) CreateCredit(ctx context.Context, id apiModels.ItemByIDRequest, req *apiModels.CreateCreditRequest) (*models.SubscriptionCredit, error) {
+	return nil, service.ErrNotFound
+}
+
+func (m *mockService) ListCredits(ctx context.Context, id apiModels.ItemByIDRequest) ([]models.SubscriptionCredit, error) {
+	return nil, service.ErrNotFound
+}
+
+func (m *mockService) CheckIntegrity(ctx context.Context) ([]models.IntegrityFinding, error) {
+	return []models.IntegrityFinding{}, nil
+}
+
+func (m *mockService) ListIntegrityFindings(ctx context.Context) ([]models.IntegrityFinding, error) {
+	return []models.IntegrityFinding{}, nil
+}
@@ -0,0 +1,149 @@
+// Package factory builds test fixtures with sensible defaults, so tests only spell out what they care about:
+//
+//	sub := factory.Subscription().WithUser(u).Ending("12-2024").Build()
+//	req := factory.Subscription().WithPrice(100).Request()
+package factory
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/models"
+	"subscription-aggregator-service/internal/utils/dates"
+)
+
+const (
+	DefaultServiceName = "Netflix"
+	DefaultPrice       = 299
+	DefaultStartDate   = "01-2024"
+)
+
+type SubscriptionBuilder struct {
+	id          uuid.UUID
+	externalID  *string
+	serviceName string
+	price       int
+	userID      uuid.UUID
+	startDate   string
+	endDate     *string
+	subType     string
+	createdAt   time.Time
+}
+
+// Subscription starts a builder for a Netflix subscription at 299 starting 01-2024 without end, owned by a fresh user
+func Subscription() *SubscriptionBuilder {
+	return &SubscriptionBuilder{
+		id:          uuid.New(),
+		serviceName: DefaultServiceName,
+		price:       DefaultPrice,
+		userID:      uuid.New(),
+		startDate:   DefaultStartDate,
+		subType:     models.TypeRecurring,
+		createdAt:   time.Now(),
+	}
+}
+
+func (b *SubscriptionBuilder) WithID(id uuid.UUID) *SubscriptionBuilder {
+	b.id = id
+	return b
+}
+
+func (b *SubscriptionBuilder) WithExternalID(id string) *SubscriptionBuilder {
+	b.externalID = &id
+	return b
+}
+
+func (b *SubscriptionBuilder) WithService(name string) *SubscriptionBuilder {
+	b.serviceName = name
+	return b
+}
+
+func (b *SubscriptionBuilder) WithPrice(price int) *SubscriptionBuilder {
+	b.price = price
+	return b
+}
+
+func (b *SubscriptionBuilder) WithUser(id uuid.UUID) *SubscriptionBuilder {
+	b.userID = id
+	return b
+}
+
+// Starting sets start date in MM-YYYY format. It isn't validated until Build, so Request can carry malformed dates.
+func (b *SubscriptionBuilder) Starting(date string) *SubscriptionBuilder {
+	b.startDate = date
+	return b
+}
+
+// Ending sets end date in MM-YYYY format, see Starting
+func (b *SubscriptionBuilder) Ending(date string) *SubscriptionBuilder {
+	b.endDate = &date
+	return b
+}
+
+// OneTime makes it a one-time purchase, which Build ends in its start month unless Ending says otherwise
+func (b *SubscriptionBuilder) OneTime() *SubscriptionBuilder {
+	b.subType = models.TypeOneTime
+	return b
+}
+
+func (b *SubscriptionBuilder) CreatedAt(t time.Time) *SubscriptionBuilder {
+	b.createdAt = t
+	return b
+}
+
+// Build returns the storage model. Panics on malformed dates, since that's a bug in the test itself.
+func (b *SubscriptionBuilder) Build() *models.Subscription {
+	sub := &models.Subscription{
+		ID:          b.id,
+		ExternalID:  b.externalID,
+		ServiceName: b.serviceName,
+		Price:       b.price,
+		Type:        b.subType,
+		UserID:      b.userID,
+		StartDate:   mustDate(b.startDate),
+		CreatedAt:   b.createdAt,
+		UpdatedAt:   b.createdAt,
+	}
+	if b.endDate != nil {
+		end := mustDate(*b.endDate)
+		sub.EndDate = &end
+	} else if b.subType == models.TypeOneTime {
+		sub.EndDate = &sub.StartDate
+	}
+	return sub
+}
+
+// Request returns the equivalent POST /subscriptions payload. ID is left for the server to assign.
+func (b *SubscriptionBuilder) Request() *apiModels.CreateSubscriptionRequest {
+	price := b.price
+	return &apiModels.CreateSubscriptionRequest{
+		ExternalID:  b.externalID,
+		ServiceName: b.serviceName,
+		Price:       &price,
+		UserID:      b.userID.String(),
+		StartDate:   b.startDate,
+		EndDate:     b.endDate,
+		Type:        b.subType,
+	}
+}
+
+// JSON returns Request marshalled for use as a request body
+func (b *SubscriptionBuilder) JSON() []byte {
+	body, err := json.Marshal(b.Request())
+	if err != nil {
+		panic(fmt.Sprintf("factory: failed to marshal request: %v", err))
+	}
+	return body
+}
+
+func mustDate(s string) time.Time {
+	t, err := dates.String2Date(s)
+	if err != nil {
+		panic(fmt.Sprintf("factory: invalid date %q: %v", s, err))
+	}
+	return t
+}
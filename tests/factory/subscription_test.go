@@ -0,0 +1,44 @@
+package factory
+
+import (
+	"testing"
+
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSubscriptionBuilder(t *testing.T) {
+	userID := uuid.New()
+	sub := Subscription().WithUser(userID).WithService("Spotify").WithPrice(199).Starting("03-2024").Ending("12-2024").Build()
+
+	if sub.ID == uuid.Nil {
+		t.Error("Build() left ID empty")
+	}
+	if sub.UserID != userID || sub.ServiceName != "Spotify" || sub.Price != 199 {
+		t.Errorf("Build() = %+v, overrides not applied", sub)
+	}
+	if !sub.StartDate.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("StartDate = %v, want 2024-03-01", sub.StartDate)
+	}
+	if sub.EndDate == nil || !sub.EndDate.Equal(time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("EndDate = %v, want 2024-12-01", sub.EndDate)
+	}
+
+	req := Subscription().WithUser(userID).Starting("2024-01").Request()
+	if req.UserID != userID.String() || req.StartDate != "2024-01" || req.EndDate != nil {
+		t.Errorf("Request() = %+v, want raw fields", req)
+	}
+	if err := req.Validate(); err == nil {
+		t.Error("Request() with malformed start date should fail validation")
+	}
+}
+
+func TestSubscriptionBuilderPanicsOnBadDate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Build() with malformed date should panic")
+		}
+	}()
+	Subscription().Starting("13-2024").Build()
+}
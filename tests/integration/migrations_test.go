@@ -0,0 +1,50 @@
+//go:build integration
+
+package integration
+
+import (
+	"testing"
+
+	"context"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"subscription-aggregator-service/internal/health"
+	"subscription-aggregator-service/migrations"
+	"subscription-aggregator-service/tests/testutils"
+)
+
+func TestApplyMigrations(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	ctx := context.Background()
+
+	pc, err := testutils.SharedPostgres(ctx)
+	require.NoError(t, err)
+	db, err := pc.CreateDatabase(ctx)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Teardown(context.Background()) })
+
+	all, err := migrations.All()
+	require.NoError(t, err)
+
+	pending, err := migrations.Pending(ctx, db.DB)
+	require.NoError(t, err)
+	assert.Len(t, pending, len(all), "fresh database has every migration pending")
+	assert.ErrorIs(t, health.NewSchemaChecker(db.DB).Check(ctx), health.ErrSchemaOutdated)
+
+	applied, err := migrations.Apply(ctx, db.DB)
+	require.NoError(t, err)
+	assert.Len(t, applied, len(all))
+
+	pending, err = migrations.Pending(ctx, db.DB)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	assert.NoError(t, health.NewSchemaChecker(db.DB).Check(ctx))
+
+	applied, err = migrations.Apply(ctx, db.DB)
+	require.NoError(t, err)
+	assert.Empty(t, applied, "second replica has nothing to apply")
+}
@@ -6,6 +6,8 @@ import (
 	"testing"
 
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,6 +17,8 @@ import (
 
 	"subscription-aggregator-service/internal/models"
 	"subscription-aggregator-service/internal/storage"
+	"subscription-aggregator-service/pkg/postgres"
+	"subscription-aggregator-service/tests/factory"
 	"subscription-aggregator-service/tests/testutils"
 )
 
@@ -28,22 +32,10 @@ type StorageIntegrationTestSuite struct {
 func (s *StorageIntegrationTestSuite) SetupSuite() {
 	s.ctx = context.Background()
 
-	var err error
-	s.container, err = testutils.SetupPostgresContainer(s.ctx)
-	require.NoError(s.T(), err, "Failed to setup postgres container")
-
-	err = s.container.RunMigrations(s.ctx)
-	require.NoError(s.T(), err, "Failed to run migrations")
-
+	s.container = testutils.NewTestDatabase(s.T())
 	s.storage = storage.NewSubscriptionsStorage(s.container.DB)
 }
 
-func (s *StorageIntegrationTestSuite) TearDownSuite() {
-	if s.container != nil {
-		_ = s.container.Teardown(s.ctx)
-	}
-}
-
 func (s *StorageIntegrationTestSuite) SetupTest() {
 	// Clean up before each test
 	err := s.container.Cleanup(s.ctx)
@@ -51,15 +43,7 @@ func (s *StorageIntegrationTestSuite) SetupTest() {
 }
 
 func (s *StorageIntegrationTestSuite) TestCreateSubscription() {
-	sub := &models.Subscription{
-		ID:          uuid.New(),
-		ServiceName: "Netflix",
-		Price:       299,
-		UserID:      uuid.New(),
-		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}
+	sub := factory.Subscription().Build()
 
 	err := s.storage.CreateSubscription(s.ctx, sub)
 	assert.NoError(s.T(), err)
@@ -72,28 +56,104 @@ func (s *StorageIntegrationTestSuite) TestCreateSubscription() {
 	assert.Equal(s.T(), sub.UserID, retrieved.UserID)
 }
 
+func (s *StorageIntegrationTestSuite) TestCreateSubscription_AlreadyExists() {
+	sub := factory.Subscription().Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+
+	dup := *sub
+	dup.Price = 399
+	err := s.storage.CreateSubscription(s.ctx, &dup)
+	assert.ErrorIs(s.T(), err, storage.ErrAlreadyExists)
+}
+
+func (s *StorageIntegrationTestSuite) TestCreateSubscriptions_AllOrNothing() {
+	existing := factory.Subscription().Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, existing))
+
+	fresh := factory.Subscription().Build()
+	err := s.storage.CreateSubscriptions(s.ctx, []*models.Subscription{fresh, factory.Subscription().WithID(existing.ID).Build()})
+	assert.ErrorIs(s.T(), err, storage.ErrAlreadyExists)
+	_, err = s.storage.GetSubscriptionByID(s.ctx, fresh.ID)
+	assert.ErrorIs(s.T(), err, storage.ErrNotFound)
+
+	require.NoError(s.T(), s.storage.CreateSubscriptions(s.ctx, []*models.Subscription{fresh, factory.Subscription().WithPrice(0).Build()}))
+	_, err = s.storage.GetSubscriptionByID(s.ctx, fresh.ID)
+	assert.NoError(s.T(), err)
+}
+
+func (s *StorageIntegrationTestSuite) TestCreateSubscriptionIfAbsent() {
+	userID := uuid.New()
+	newSub := func() *models.Subscription {
+		return factory.Subscription().WithUser(userID).WithExternalID("crm-42").Build()
+	}
+
+	first, created, err := s.storage.CreateSubscriptionIfAbsent(s.ctx, newSub())
+	require.NoError(s.T(), err)
+	assert.True(s.T(), created)
+
+	second, created, err := s.storage.CreateSubscriptionIfAbsent(s.ctx, newSub())
+	require.NoError(s.T(), err)
+	assert.False(s.T(), created)
+	assert.Equal(s.T(), first.ID, second.ID)
+
+	// Soft-deleted rows don't block reuse of the external ID
+	require.NoError(s.T(), s.storage.DeleteSubscriptionByID(s.ctx, first.ID))
+	third, created, err := s.storage.CreateSubscriptionIfAbsent(s.ctx, newSub())
+	require.NoError(s.T(), err)
+	assert.True(s.T(), created)
+	assert.NotEqual(s.T(), first.ID, third.ID)
+}
+
 func (s *StorageIntegrationTestSuite) TestGetSubscriptionByID_NotFound() {
 	_, err := s.storage.GetSubscriptionByID(s.ctx, uuid.New())
 	assert.ErrorIs(s.T(), err, storage.ErrNotFound)
 }
 
+func (s *StorageIntegrationTestSuite) TestFindOverlappingSubscription() {
+	userID := uuid.New()
+	existing := factory.Subscription().WithUser(userID).WithService("Netflix").Starting("03-2024").Ending("06-2024").Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, existing))
+
+	found, err := s.storage.FindOverlappingSubscription(s.ctx, factory.Subscription().WithUser(userID).WithService("netflix").Starting("06-2024").Build())
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), existing.ID, found.ID)
+
+	_, err = s.storage.FindOverlappingSubscription(s.ctx, factory.Subscription().WithUser(userID).WithService("Netflix").Starting("07-2024").Build())
+	assert.ErrorIs(s.T(), err, storage.ErrNotFound)
+
+	_, err = s.storage.FindOverlappingSubscription(s.ctx, factory.Subscription().WithUser(userID).WithService("Netflix").Starting("01-2024").Ending("02-2024").Build())
+	assert.ErrorIs(s.T(), err, storage.ErrNotFound)
+}
+
+func (s *StorageIntegrationTestSuite) TestSubscriptionExistsByID() {
+	sub := factory.Subscription().Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+
+	exists, err := s.storage.SubscriptionExistsByID(s.ctx, sub.ID)
+	require.NoError(s.T(), err)
+	assert.True(s.T(), exists)
+
+	exists, err = s.storage.SubscriptionExistsByID(s.ctx, uuid.New())
+	require.NoError(s.T(), err)
+	assert.False(s.T(), exists)
+
+	require.NoError(s.T(), s.storage.DeleteSubscriptionByID(s.ctx, sub.ID))
+	exists, err = s.storage.SubscriptionExistsByID(s.ctx, sub.ID)
+	require.NoError(s.T(), err)
+	assert.False(s.T(), exists, "soft-deleted subscription must not exist")
+}
+
 func (s *StorageIntegrationTestSuite) TestUpdateSubscription() {
 	// Create subscription
-	sub := &models.Subscription{
-		ID:          uuid.New(),
-		ServiceName: "Netflix",
-		Price:       299,
-		UserID:      uuid.New(),
-		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}
+	sub := factory.Subscription().Build()
 	err := s.storage.CreateSubscription(s.ctx, sub)
 	require.NoError(s.T(), err)
 
 	// Update
 	sub.ServiceName = "Netflix Premium"
 	sub.Price = 499
+	costCenter := "CC-1042"
+	sub.CostCenter = &costCenter
 	err = s.storage.UpdateSubscriptionByID(s.ctx, sub)
 	assert.NoError(s.T(), err)
 
@@ -102,16 +162,52 @@ func (s *StorageIntegrationTestSuite) TestUpdateSubscription() {
 	assert.NoError(s.T(), err)
 	assert.Equal(s.T(), "Netflix Premium", retrieved.ServiceName)
 	assert.Equal(s.T(), 499, retrieved.Price)
+	assert.Equal(s.T(), &costCenter, retrieved.CostCenter)
+	assert.Nil(s.T(), retrieved.ProjectCode)
+}
+
+func (s *StorageIntegrationTestSuite) TestUpdateSubscription_Stale() {
+	sub := factory.Subscription().Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+	require.Equal(s.T(), 1, sub.Version)
+
+	first, err := s.storage.GetSubscriptionByID(s.ctx, sub.ID)
+	require.NoError(s.T(), err)
+	second, err := s.storage.GetSubscriptionByID(s.ctx, sub.ID)
+	require.NoError(s.T(), err)
+
+	first.Price = 100
+	require.NoError(s.T(), s.storage.UpdateSubscriptionByID(s.ctx, first))
+	assert.Equal(s.T(), 2, first.Version)
+
+	second.Price = 200
+	assert.ErrorIs(s.T(), s.storage.UpdateSubscriptionByID(s.ctx, second), storage.ErrStale)
+
+	retrieved, err := s.storage.GetSubscriptionByID(s.ctx, sub.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 100, retrieved.Price)
+	assert.Equal(s.T(), 2, retrieved.Version)
+}
+
+func (s *StorageIntegrationTestSuite) TestUpdateSubscription_Cancelled() {
+	sub := factory.Subscription().Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+
+	end := sub.StartDate.AddDate(0, 2, 0)
+	cancelledAt := time.Now().UTC().Truncate(time.Microsecond)
+	sub.EndDate = &end
+	sub.CancelledAt = &cancelledAt
+	require.NoError(s.T(), s.storage.UpdateSubscriptionByID(s.ctx, sub))
+
+	retrieved, err := s.storage.GetSubscriptionByID(s.ctx, sub.ID)
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), retrieved.CancelledAt)
+	assert.True(s.T(), cancelledAt.Equal(*retrieved.CancelledAt))
+	assert.True(s.T(), end.Equal(*retrieved.EndDate))
 }
 
 func (s *StorageIntegrationTestSuite) TestUpdateSubscription_NotFound() {
-	sub := &models.Subscription{
-		ID:          uuid.New(),
-		ServiceName: "Test",
-		Price:       100,
-		UserID:      uuid.New(),
-		StartDate:   time.Now(),
-	}
+	sub := factory.Subscription().Build()
 
 	err := s.storage.UpdateSubscriptionByID(s.ctx, sub)
 	assert.ErrorIs(s.T(), err, storage.ErrNotFound)
@@ -119,15 +215,7 @@ func (s *StorageIntegrationTestSuite) TestUpdateSubscription_NotFound() {
 
 func (s *StorageIntegrationTestSuite) TestDeleteSubscription() {
 	// Create subscription
-	sub := &models.Subscription{
-		ID:          uuid.New(),
-		ServiceName: "Netflix",
-		Price:       299,
-		UserID:      uuid.New(),
-		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}
+	sub := factory.Subscription().Build()
 	err := s.storage.CreateSubscription(s.ctx, sub)
 	require.NoError(s.T(), err)
 
@@ -145,38 +233,256 @@ func (s *StorageIntegrationTestSuite) TestDeleteSubscription_NotFound() {
 	assert.ErrorIs(s.T(), err, storage.ErrNotFound)
 }
 
+func (s *StorageIntegrationTestSuite) TestUndoDeletion() {
+	userID := uuid.New()
+	sub := factory.Subscription().WithUser(userID).WithExternalID("crm-1").Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+
+	token, err := s.storage.DeleteSubscriptionWithUndo(s.ctx, sub.ID, time.Now().Add(time.Minute))
+	require.NoError(s.T(), err)
+	assert.NotEqual(s.T(), uuid.Nil, token.Token)
+	_, err = s.storage.GetSubscriptionByID(s.ctx, sub.ID)
+	assert.ErrorIs(s.T(), err, storage.ErrNotFound)
+
+	// Rejected by check, the token stays usable
+	rejected := errors.New("rejected")
+	noCheck := func(*models.Subscription) error { return nil }
+	_, err = s.storage.UndoDeletion(s.ctx, token.Token, func(*models.Subscription) error { return rejected })
+	assert.ErrorIs(s.T(), err, rejected)
+
+	restored, err := s.storage.UndoDeletion(s.ctx, token.Token, func(sub *models.Subscription) error {
+		assert.Equal(s.T(), "crm-1", *sub.ExternalID)
+		return nil
+	})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), sub.ID, restored.ID)
+	_, err = s.storage.GetSubscriptionByID(s.ctx, sub.ID)
+	assert.NoError(s.T(), err)
+
+	// Token works once
+	_, err = s.storage.UndoDeletion(s.ctx, token.Token, noCheck)
+	assert.ErrorIs(s.T(), err, storage.ErrNotFound)
+
+	// Expired token doesn't restore
+	expired, err := s.storage.DeleteSubscriptionWithUndo(s.ctx, sub.ID, time.Now().Add(-time.Second))
+	require.NoError(s.T(), err)
+	_, err = s.storage.UndoDeletion(s.ctx, expired.Token, noCheck)
+	assert.ErrorIs(s.T(), err, storage.ErrNotFound)
+
+	// A live subscription took the external_id meanwhile, the token is kept for a retry
+	retry := factory.Subscription().WithUser(userID).WithExternalID("crm-2").Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, retry))
+	token, err = s.storage.DeleteSubscriptionWithUndo(s.ctx, retry.ID, time.Now().Add(time.Minute))
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, factory.Subscription().WithUser(userID).WithExternalID("crm-2").Build()))
+	_, err = s.storage.UndoDeletion(s.ctx, token.Token, noCheck)
+	assert.ErrorIs(s.T(), err, storage.ErrAlreadyExists)
+
+	_, err = s.storage.DeleteSubscriptionWithUndo(s.ctx, uuid.New(), time.Now().Add(time.Minute))
+	assert.ErrorIs(s.T(), err, storage.ErrNotFound)
+}
+
+func (s *StorageIntegrationTestSuite) TestPurge() {
+	userID := uuid.New()
+	live := factory.Subscription().WithUser(userID).Build()
+	deleted := factory.Subscription().WithUser(userID).Build()
+	other := factory.Subscription().Build()
+	for _, sub := range []*models.Subscription{live, deleted, other} {
+		require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+	}
+	require.NoError(s.T(), s.storage.CreateCredit(s.ctx, &models.SubscriptionCredit{ID: uuid.New(), SubscriptionID: live.ID, Amount: -10, StartDate: live.StartDate}))
+	_, err := s.storage.DeleteSubscriptionWithUndo(s.ctx, deleted.ID, time.Now().Add(time.Minute))
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), s.storage.CreateView(s.ctx, &models.SavedView{ID: uuid.New(), UserID: userID, Name: "mine"}))
+
+	// Soft-deleted subscription is purged along with its undo token
+	record, err := s.storage.PurgeSubscription(s.ctx, deleted.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 1, record.Subscriptions)
+	_, err = s.storage.PurgeSubscription(s.ctx, deleted.ID)
+	assert.ErrorIs(s.T(), err, storage.ErrNotFound)
+
+	// User's subscriptions go with their credits, other users' data stays
+	record, err = s.storage.PurgeUserData(s.ctx, userID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 1, record.Subscriptions)
+	assert.Equal(s.T(), 1, record.Views)
+	var left int64
+	require.NoError(s.T(), s.container.DB.Unscoped().Model(&models.Subscription{}).Where("user_id = ?", userID).Count(&left).Error)
+	assert.Zero(s.T(), left)
+	require.NoError(s.T(), s.container.DB.Model(&models.SubscriptionCredit{}).Where("subscription_id = ?", live.ID).Count(&left).Error)
+	assert.Zero(s.T(), left)
+	_, err = s.storage.GetSubscriptionByID(s.ctx, other.ID)
+	assert.NoError(s.T(), err)
+
+	var records []models.PurgeRecord
+	require.NoError(s.T(), s.container.DB.Order("purged_at").Find(&records).Error)
+	require.Len(s.T(), records, 2)
+	assert.Equal(s.T(), models.PurgeSubjectUser, records[1].Subject)
+	assert.Equal(s.T(), userID, records[1].SubjectID)
+}
+
+func (s *StorageIntegrationTestSuite) TestUpdateSubscriptionsPrice() {
+	userID := uuid.New()
+	netflix := factory.Subscription().WithUser(userID).WithService("Netflix").WithPrice(599).Starting("01-2024").Build()
+	later := factory.Subscription().WithUser(userID).WithService("netflix").WithPrice(599).Starting("09-2024").Build()
+	ended := factory.Subscription().WithUser(userID).WithService("Netflix").WithPrice(599).Starting("01-2023").Ending("12-2023").Build()
+	premium := factory.Subscription().WithUser(userID).WithService("netflix premium").WithPrice(999).Build()
+	atPrice := factory.Subscription().WithUser(userID).WithService("NETFLIX").WithPrice(699).Build()
+	others := factory.Subscription().WithService("Netflix").WithPrice(599).Build()
+	for _, sub := range []*models.Subscription{netflix, later, ended, premium, atPrice, others} {
+		require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+	}
+	month := func(m time.Month) time.Time { return time.Date(2024, m, 1, 0, 0, 0, 0, time.UTC) }
+	require.NoError(s.T(), s.storage.CreateCredit(s.ctx, &models.SubscriptionCredit{ID: uuid.New(), SubscriptionID: netflix.ID, Amount: -50, StartDate: month(1), CreatedAt: time.Now()}))
+
+	name := "netflix"
+	filter := models.SubscriptionFilter{UserID: &userID, ServiceName: &name, ServiceNameFold: true}
+	rejected := errors.New("rejected")
+	_, _, err := s.storage.UpdateSubscriptionsPrice(s.ctx, filter, 699, month(6), func([]models.Subscription) error { return rejected })
+	assert.ErrorIs(s.T(), err, rejected)
+
+	before, after, err := s.storage.UpdateSubscriptionsPrice(s.ctx, filter, 699, month(6), func(before []models.Subscription) error {
+		assert.Len(s.T(), before, 2, "only the user's running Netflix not at the price yet")
+		for _, sub := range before {
+			if sub.ID == netflix.ID {
+				assert.Len(s.T(), sub.Credits, 1, "credits are loaded for the check")
+			}
+		}
+		return nil
+	})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), before, 2)
+	require.Len(s.T(), after, 2)
+	for i := range before {
+		assert.Equal(s.T(), 599, before[i].Price)
+		assert.Equal(s.T(), 699, after[i].Price)
+		assert.False(s.T(), after[i].FollowPlan)
+		assert.Equal(s.T(), before[i].ID, after[i].ID, "updated in place")
+		assert.Equal(s.T(), before[i].StartDate, after[i].StartDate)
+	}
+
+	for id, want := range map[uuid.UUID]int{netflix.ID: 699, later.ID: 699, ended.ID: 599, premium.ID: 999, atPrice.ID: 699, others.ID: 599} {
+		got, err := s.storage.GetSubscriptionByID(s.ctx, id)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), want, got.Price)
+	}
+	original, err := s.storage.GetSubscriptionByID(s.ctx, netflix.ID)
+	require.NoError(s.T(), err)
+	assert.Nil(s.T(), original.EndDate)
+	assert.Equal(s.T(), 2, original.Version)
+	credits, err := s.storage.ListCredits(s.ctx, netflix.ID)
+	require.NoError(s.T(), err)
+	assert.Len(s.T(), credits, 1)
+}
+
+func (s *StorageIntegrationTestSuite) TestSyncUserSubscriptions() {
+	userID := uuid.New()
+	kept := factory.Subscription().WithUser(userID).WithExternalID("crm-1").Build()
+	dropped := factory.Subscription().WithUser(userID).WithExternalID("crm-2").Build()
+	other := factory.Subscription().WithExternalID("crm-2").Build()
+	for _, sub := range []*models.Subscription{kept, dropped, other} {
+		require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+	}
+
+	added := factory.Subscription().WithUser(userID).WithExternalID("crm-3").Build()
+	_, err := s.storage.SyncUserSubscriptions(s.ctx, userID, func(current []models.Subscription) (*models.SyncPlan, error) {
+		assert.Len(s.T(), current, 2) // Only this user's subscriptions
+		updated := *kept
+		updated.Price = 499
+		return &models.SyncPlan{
+			Create: []*models.Subscription{added},
+			Update: []*models.Subscription{&updated},
+			Delete: []*models.Subscription{dropped},
+		}, nil
+	})
+	require.NoError(s.T(), err)
+
+	list, _, err := s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{UserID: &userID})
+	require.NoError(s.T(), err)
+	prices := map[string]int{}
+	for _, sub := range list {
+		prices[*sub.ExternalID] = sub.Price
+	}
+	assert.Equal(s.T(), map[string]int{"crm-1": 499, "crm-3": added.Price}, prices)
+
+	_, err = s.storage.GetSubscriptionByID(s.ctx, other.ID)
+	assert.NoError(s.T(), err, "another user's subscription with the same external ID must survive")
+}
+
+func (s *StorageIntegrationTestSuite) TestSyncUserSubscriptions_RollsBack() {
+	userID := uuid.New()
+	existing := factory.Subscription().WithUser(userID).WithExternalID("crm-1").Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, existing))
+
+	fresh := factory.Subscription().WithUser(userID).WithExternalID("crm-2").Build()
+	duplicate := factory.Subscription().WithUser(userID).WithExternalID("crm-1").Build()
+	_, err := s.storage.SyncUserSubscriptions(s.ctx, userID, func([]models.Subscription) (*models.SyncPlan, error) {
+		return &models.SyncPlan{Create: []*models.Subscription{fresh, duplicate}}, nil
+	})
+	assert.ErrorIs(s.T(), err, storage.ErrAlreadyExists)
+
+	_, err = s.storage.GetSubscriptionByID(s.ctx, fresh.ID)
+	assert.ErrorIs(s.T(), err, storage.ErrNotFound, "changes before the failed step must be rolled back")
+}
+
+func (s *StorageIntegrationTestSuite) TestCheckIntegrity() {
+	good := factory.Subscription().Ending("12-2024").Build()
+	backwards := factory.Subscription().Starting("06-2024").Ending("01-2024").Build()
+	shortened := factory.Subscription().Ending("06-2024").Build()
+	for _, sub := range []*models.Subscription{good, backwards, shortened} {
+		require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+	}
+	require.NoError(s.T(), s.storage.CreateCredit(s.ctx, &models.SubscriptionCredit{
+		ID: uuid.New(), SubscriptionID: shortened.ID, Amount: -100, StartDate: shortened.StartDate,
+	}))
+	midMonth := factory.Subscription().Build()
+	midMonth.StartDate = midMonth.StartDate.AddDate(0, 0, 14)
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, midMonth))
+
+	ran, err := s.storage.CheckIntegrity(s.ctx)
+	require.NoError(s.T(), err)
+	assert.True(s.T(), ran)
+
+	findings, err := s.storage.ListIntegrityFindings(s.ctx)
+	require.NoError(s.T(), err)
+	found := map[string]uuid.UUID{}
+	var detectedAt time.Time
+	for _, f := range findings {
+		found[f.Check] = f.SubscriptionID
+		if f.SubscriptionID == midMonth.ID {
+			detectedAt = f.DetectedAt
+		}
+	}
+	assert.Equal(s.T(), map[string]uuid.UUID{
+		"end_before_start":            backwards.ID,
+		"credit_outside_subscription": shortened.ID,
+		"not_month_start":             midMonth.ID,
+	}, found)
+
+	// Fixed rows drop out, persisting ones keep their detection time
+	require.NoError(s.T(), s.storage.DeleteSubscriptionByID(s.ctx, shortened.ID))
+	backwards.EndDate = nil
+	require.NoError(s.T(), s.storage.UpdateSubscriptionByID(s.ctx, backwards))
+	_, err = s.storage.CheckIntegrity(s.ctx)
+	require.NoError(s.T(), err)
+
+	findings, err = s.storage.ListIntegrityFindings(s.ctx)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), findings, 1)
+	assert.Equal(s.T(), midMonth.ID, findings[0].SubscriptionID)
+	assert.True(s.T(), findings[0].DetectedAt.Equal(detectedAt))
+	assert.True(s.T(), findings[0].CheckedAt.After(detectedAt))
+}
+
 func (s *StorageIntegrationTestSuite) TestListSubscriptions() {
 	userID := uuid.New()
 
 	// Create multiple subscriptions
 	subs := []*models.Subscription{
-		{
-			ID:          uuid.New(),
-			ServiceName: "Netflix",
-			Price:       299,
-			UserID:      userID,
-			StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			CreatedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			UpdatedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-		},
-		{
-			ID:          uuid.New(),
-			ServiceName: "Spotify",
-			Price:       199,
-			UserID:      userID,
-			StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			CreatedAt:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
-			UpdatedAt:   time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
-		},
-		{
-			ID:          uuid.New(),
-			ServiceName: "Netflix",
-			Price:       299,
-			UserID:      uuid.New(), // Different user
-			StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-			CreatedAt:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
-			UpdatedAt:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
-		},
+		factory.Subscription().WithUser(userID).CreatedAt(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)).Build(),
+		factory.Subscription().WithUser(userID).WithService("Spotify").WithPrice(199).CreatedAt(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)).Build(),
+		factory.Subscription().CreatedAt(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)).Build(), // Different user
 	}
 
 	for _, sub := range subs {
@@ -185,81 +491,705 @@ func (s *StorageIntegrationTestSuite) TestListSubscriptions() {
 	}
 
 	// List all
-	result, err := s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{})
+	result, _, err := s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{})
 	assert.NoError(s.T(), err)
 	assert.Len(s.T(), result, 3)
 
 	// Filter by user
-	result, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{UserID: &userID})
+	result, _, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{UserID: &userID})
 	assert.NoError(s.T(), err)
 	assert.Len(s.T(), result, 2)
 
 	// Filter by service name
 	serviceName := "Netflix"
-	result, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{ServiceName: &serviceName})
+	result, _, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{ServiceName: &serviceName})
 	assert.NoError(s.T(), err)
 	assert.Len(s.T(), result, 2)
 
 	// Filter by both
-	result, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{
+	result, _, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{
 		UserID:      &userID,
 		ServiceName: &serviceName,
 	})
 	assert.NoError(s.T(), err)
 	assert.Len(s.T(), result, 1)
 
+	// Case-insensitive exact and partial service name
+	lower, like, wildcard := "netflix", "FLI", "spot*"
+	result, _, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{ServiceName: &lower})
+	assert.NoError(s.T(), err)
+	assert.Empty(s.T(), result)
+	result, _, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{ServiceName: &lower, ServiceNameFold: true})
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), result, 2)
+	result, _, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{ServiceNameLike: &like})
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), result, 2)
+	result, _, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{ServiceNameLike: &wildcard})
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), result, 1)
+
+	// Filter by creation time
+	createdAfter := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	createdBefore := time.Date(2024, 2, 15, 0, 0, 0, 0, time.UTC)
+	result, _, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{
+		CreatedAfter:  &createdAfter,
+		CreatedBefore: &createdBefore,
+	})
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), result, 1)
+	assert.Equal(s.T(), subs[1].ID, result[0].ID)
+
 	limit := 2
-	result, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{Limit: &limit})
+	result, total, err := s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{Limit: &limit})
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), result, 2)
+	assert.Equal(s.T(), subs[2].ID, result[0].ID)
+	assert.Equal(s.T(), int64(3), total, "total counts every match, not just the page")
+
+	// Offset past the end still reports the total
+	offset := 5
+	result, total, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{Limit: &limit, Offset: &offset})
+	assert.NoError(s.T(), err)
+	assert.Empty(s.T(), result)
+	assert.Equal(s.T(), int64(3), total)
+
+	// Active at a month: the open-ended ones and the one ending in it
+	sub := factory.Subscription().WithUser(userID).Starting("01-2024").Ending("03-2024").Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+	activeAt := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	result, _, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{UserID: &userID, ActiveAt: &activeAt})
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), result, 3)
+	activeAt = time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	result, _, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{UserID: &userID, ActiveAt: &activeAt})
 	assert.NoError(s.T(), err)
 	assert.Len(s.T(), result, 2)
+	require.NoError(s.T(), s.storage.DeleteSubscriptionByID(s.ctx, sub.ID))
+
+	// Price range is inclusive
+	minPrice, maxPrice := 199, 200
+	result, _, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{MinPrice: &minPrice, MaxPrice: &maxPrice})
+	assert.NoError(s.T(), err)
+	require.Len(s.T(), result, 1)
+	assert.Equal(s.T(), subs[1].ID, result[0].ID)
+
+	// Sort by price, cheapest first
+	result, _, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{UserID: &userID, SortBy: models.SortByPrice})
+	assert.NoError(s.T(), err)
+	require.Len(s.T(), result, 2)
+	assert.LessOrEqual(s.T(), result[0].Price, result[1].Price)
+
+	// Unknown column falls back to creation time instead of reaching SQL
+	result, _, err = s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{SortBy: "price; drop table subscriptions", SortDesc: true})
+	assert.NoError(s.T(), err)
+	require.Len(s.T(), result, 3)
 	assert.Equal(s.T(), subs[2].ID, result[0].ID)
 }
 
 func (s *StorageIntegrationTestSuite) TestTotalSubscriptionsCost() {
 	userID := uuid.New()
 
-	sub1 := &models.Subscription{
-		ID:          uuid.New(),
-		ServiceName: "Service A",
-		Price:       100,
-		UserID:      userID,
-		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-		EndDate:     timePtr(time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)),
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+	sub1 := factory.Subscription().WithUser(userID).WithService("Service A").WithPrice(100).Ending("12-2024").Build()
+	sub2 := factory.Subscription().WithUser(userID).WithService("Service B").WithPrice(200).Starting("06-2024").Build()
+	sub3 := factory.Subscription().WithService("Service A").WithPrice(500).Ending("12-2024").Build()
+
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub1))
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub2))
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub3))
+
+	filter := models.SubscriptionFilter{UserID: &userID}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	total, err := s.storage.TotalSubscriptionsCost(s.ctx, filter, start, end)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), int64(2600), total)
+
+	nobody := uuid.New()
+	byUser, err := s.storage.TotalSubscriptionsCostByUser(s.ctx, models.SubscriptionFilter{}, []uuid.UUID{userID, sub3.UserID, nobody}, start, end)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), map[uuid.UUID]int64{userID: 2600, sub3.UserID: 6000}, byUser)
+
+	serviceName := "Service A"
+	byUser, err = s.storage.TotalSubscriptionsCostByUser(s.ctx, models.SubscriptionFilter{ServiceName: &serviceName}, []uuid.UUID{userID}, start, end)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), int64(1200), byUser[userID])
+}
+
+func (s *StorageIntegrationTestSuite) TestUserSummary() {
+	userID := uuid.New()
+	subs := []*models.Subscription{
+		factory.Subscription().WithUser(userID).WithService("Netflix").WithPrice(300).Starting("01-2024").Build(),
+		factory.Subscription().WithUser(userID).WithService("Apple One").WithPrice(300).Starting("06-2024").Ending("12-2024").Build(),
+		factory.Subscription().WithUser(userID).WithService("Adobe").WithPrice(500).Starting("01-2023").Ending("05-2024").Build(),
+		factory.Subscription().WithUser(userID).WithService("Domain").WithPrice(1000).Starting("06-2024").OneTime().Build(),
+		factory.Subscription().WithService("Spotify").WithPrice(900).Starting("01-2024").Build(),
+	}
+	for _, sub := range subs {
+		require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
 	}
-	sub2 := &models.Subscription{
+	deleted := factory.Subscription().WithUser(userID).WithService("Gone").WithPrice(5000).Starting("01-2020").Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, deleted))
+	require.NoError(s.T(), s.storage.DeleteSubscriptionByID(s.ctx, deleted.ID))
+
+	summary, err := s.storage.UserSummary(s.ctx, userID, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 3, summary.ActiveCount)
+	assert.Equal(s.T(), int64(600), summary.MonthlyCost)
+	require.NotNil(s.T(), summary.MostExpensiveService)
+	assert.Equal(s.T(), "Apple One", *summary.MostExpensiveService)
+	require.NotNil(s.T(), summary.EarliestStart)
+	assert.True(s.T(), summary.EarliestStart.Equal(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.True(s.T(), summary.LatestStart.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)))
+
+	empty, err := s.storage.UserSummary(s.ctx, uuid.New(), time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(s.T(), err)
+	assert.Zero(s.T(), empty.ActiveCount)
+	assert.Nil(s.T(), empty.MostExpensiveService)
+	assert.Nil(s.T(), empty.EarliestStart)
+}
+
+func (s *StorageIntegrationTestSuite) TestOneTimeSubscription() {
+	userID := uuid.New()
+	domain := factory.Subscription().WithUser(userID).WithService("Domain").WithPrice(1500).Starting("03-2024").OneTime().Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, domain))
+
+	retrieved, err := s.storage.GetSubscriptionByID(s.ctx, domain.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), models.TypeOneTime, retrieved.Type)
+
+	filter := models.SubscriptionFilter{UserID: &userID}
+	total, err := s.storage.TotalSubscriptionsCost(s.ctx, filter, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), int64(1500), total)
+
+	lasting := factory.Subscription().OneTime().Ending("12-2024").Build()
+	assert.Error(s.T(), s.storage.CreateSubscription(s.ctx, lasting))
+
+	recurring := factory.Subscription().Build()
+	recurring.Type = ""
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, recurring))
+	retrieved, err = s.storage.GetSubscriptionByID(s.ctx, recurring.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), models.TypeRecurring, retrieved.Type)
+}
+
+func (s *StorageIntegrationTestSuite) TestSubscriptionCredits() {
+	userID := uuid.New()
+	sub := factory.Subscription().WithUser(userID).WithPrice(200).Starting("06-2024").Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+
+	julyEnd := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	credits := []*models.SubscriptionCredit{
+		{ID: uuid.New(), SubscriptionID: sub.ID, Amount: -50, StartDate: time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC), CreatedAt: time.Now()},
+		{ID: uuid.New(), SubscriptionID: sub.ID, Amount: -20, StartDate: sub.StartDate, EndDate: &julyEnd, Description: "promo", CreatedAt: time.Now()},
+		{ID: uuid.New(), SubscriptionID: sub.ID, Percent: 15, StartDate: time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), CreatedAt: time.Now()},
+	}
+	for _, c := range credits {
+		require.NoError(s.T(), s.storage.CreateCredit(s.ctx, c))
+	}
+
+	listed, err := s.storage.ListCredits(s.ctx, sub.ID)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), listed, 3)
+	assert.Equal(s.T(), "promo", listed[0].Description) // Ordered by start date
+
+	filter := models.SubscriptionFilter{UserID: &userID}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	total, err := s.storage.TotalSubscriptionsCost(s.ctx, filter, start, end)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), int64(7*200-2*50-2*20-30), total)
+
+	byUser, err := s.storage.TotalSubscriptionsCostByUser(s.ctx, models.SubscriptionFilter{}, []uuid.UUID{userID}, start, end)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), total, byUser[userID])
+
+	inPeriod, err := s.storage.ListSubscriptionsInPeriod(s.ctx, filter, start, end)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), inPeriod, 1)
+	assert.Len(s.T(), inPeriod[0].Credits, 3)
+
+	both := &models.SubscriptionCredit{ID: uuid.New(), SubscriptionID: sub.ID, Amount: -10, Percent: 10, StartDate: sub.StartDate}
+	assert.Error(s.T(), s.storage.CreateCredit(s.ctx, both))
+
+	nov := time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC)
+	stacked := &models.SubscriptionCredit{ID: uuid.New(), SubscriptionID: sub.ID, Amount: -180, StartDate: nov, EndDate: &nov, CreatedAt: time.Now()}
+	require.NoError(s.T(), s.storage.CreateCredit(s.ctx, stacked))
+	clamped, err := s.storage.TotalSubscriptionsCost(s.ctx, filter, start, end)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), total-150, clamped) // November credits of 230 take off only the price of 200
+
+	byUser, err = s.storage.TotalSubscriptionsCostByUser(s.ctx, models.SubscriptionFilter{}, []uuid.UUID{userID}, start, end)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), clamped, byUser[userID])
+}
+
+func (s *StorageIntegrationTestSuite) TestPausedPeriods() {
+	userID := uuid.New()
+	sub := factory.Subscription().WithUser(userID).WithPrice(200).Starting("06-2024").Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+	require.NoError(s.T(), s.storage.CreateCredit(s.ctx, &models.SubscriptionCredit{ID: uuid.New(), SubscriptionID: sub.ID, Amount: -50, StartDate: sub.StartDate, CreatedAt: time.Now()}))
+
+	_, err := s.storage.ResumePausedPeriod(s.ctx, sub.ID, sub.StartDate)
+	assert.ErrorIs(s.T(), err, storage.ErrNotFound)
+
+	month := func(m time.Month) time.Time { return time.Date(2024, m, 1, 0, 0, 0, 0, time.UTC) }
+	pause := &models.PausedPeriod{ID: uuid.New(), SubscriptionID: sub.ID, StartDate: month(8), CreatedAt: time.Now()}
+	require.NoError(s.T(), s.storage.CreatePausedPeriod(s.ctx, pause))
+	again := &models.PausedPeriod{ID: uuid.New(), SubscriptionID: sub.ID, StartDate: month(9), CreatedAt: time.Now()}
+	assert.ErrorIs(s.T(), s.storage.CreatePausedPeriod(s.ctx, again), storage.ErrAlreadyExists)
+
+	resumed, err := s.storage.ResumePausedPeriod(s.ctx, sub.ID, month(9))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), month(9), *resumed.EndDate)
+	require.NoError(s.T(), s.storage.CreatePausedPeriod(s.ctx, &models.PausedPeriod{ID: uuid.New(), SubscriptionID: sub.ID, StartDate: month(11), CreatedAt: time.Now()}))
+
+	// Resumed before it started, the pause is dropped
+	dropped := &models.PausedPeriod{ID: uuid.New(), SubscriptionID: uuid.New(), StartDate: month(10), CreatedAt: time.Now()}
+	other := factory.Subscription().WithID(dropped.SubscriptionID).Starting("06-2024").Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, other))
+	require.NoError(s.T(), s.storage.CreatePausedPeriod(s.ctx, dropped))
+	_, err = s.storage.ResumePausedPeriod(s.ctx, other.ID, month(9))
+	require.NoError(s.T(), err)
+	listed, err := s.storage.ListPausedPeriods(s.ctx, other.ID)
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), listed)
+
+	listed, err = s.storage.ListPausedPeriods(s.ctx, sub.ID)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), listed, 2)
+	assert.Nil(s.T(), listed[1].EndDate)
+
+	// 06-2024..12-2024 at 150 after the credit, minus 08-2024..09-2024 and 11-2024..12-2024
+	filter := models.SubscriptionFilter{UserID: &userID}
+	total, err := s.storage.TotalSubscriptionsCost(s.ctx, filter, month(1), month(12))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), int64(3*150), total)
+
+	byUser, err := s.storage.TotalSubscriptionsCostByUser(s.ctx, models.SubscriptionFilter{}, []uuid.UUID{userID}, month(1), month(12))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), total, byUser[userID])
+
+	inPeriod, err := s.storage.ListSubscriptionsInPeriod(s.ctx, filter, month(1), month(12))
+	require.NoError(s.T(), err)
+	require.Len(s.T(), inPeriod, 1)
+	assert.Len(s.T(), inPeriod[0].Pauses, 2)
+}
+
+func (s *StorageIntegrationTestSuite) TestRenameServiceBatch() {
+	unit := &models.OrgUnit{ID: uuid.New(), Name: "Engineering", CreatedAt: time.Now()}
+	require.NoError(s.T(), s.storage.CreateOrgUnit(s.ctx, unit))
+	var inUnit []*models.Subscription
+	for _, name := range []string{"HBO Max", "hbo max", "Max"} {
+		sub := factory.Subscription().WithService(name).Build()
+		sub.OrgUnitID = &unit.ID
+		require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+		inUnit = append(inUnit, sub)
+	}
+	outside := factory.Subscription().WithService("HBO Max").Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, outside))
+
+	rename := &models.ServiceRename{ID: uuid.New(), FromName: "HBO max", ToName: "Max", OrgUnitID: &unit.ID}
+	require.NoError(s.T(), s.storage.CreateServiceRename(s.ctx, rename))
+	noCheck := func([]models.Subscription) error { return nil }
+
+	batch, err := s.storage.RenameServiceBatch(s.ctx, rename, []uuid.UUID{unit.ID}, 1, noCheck)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), batch, 1)
+	batch, err = s.storage.RenameServiceBatch(s.ctx, rename, []uuid.UUID{unit.ID}, 1, noCheck)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), batch, 1)
+	batch, err = s.storage.RenameServiceBatch(s.ctx, rename, []uuid.UUID{unit.ID}, 1, noCheck)
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), batch) // Already named Max
+	require.NoError(s.T(), s.storage.FinishServiceRename(s.ctx, rename.ID))
+
+	for _, sub := range inUnit {
+		renamed, err := s.storage.GetSubscriptionByID(s.ctx, sub.ID)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "Max", renamed.ServiceName)
+	}
+	untouched, err := s.storage.GetSubscriptionByID(s.ctx, outside.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "HBO Max", untouched.ServiceName)
+
+	var stored models.ServiceRename
+	require.NoError(s.T(), s.container.DB.First(&stored, "id = ?", rename.ID).Error)
+	assert.Equal(s.T(), int64(2), stored.Renamed)
+	assert.NotNil(s.T(), stored.FinishedAt)
+
+	rejected := errors.New("rejected")
+	_, err = s.storage.RenameServiceBatch(s.ctx, &models.ServiceRename{ID: uuid.New(), FromName: "HBO Max", ToName: "Max"}, nil, 10,
+		func([]models.Subscription) error { return rejected })
+	assert.ErrorIs(s.T(), err, rejected)
+	untouched, err = s.storage.GetSubscriptionByID(s.ctx, outside.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "HBO Max", untouched.ServiceName)
+}
+
+func (s *StorageIntegrationTestSuite) TestUpdatePlanPrice() {
+	plan := &models.Plan{ID: uuid.New(), ServiceName: "Yandex Plus", Name: "Family", Price: 499}
+	require.NoError(s.T(), s.storage.CreatePlan(s.ctx, plan))
+	dup := *plan
+	dup.ID = uuid.New()
+	assert.ErrorIs(s.T(), s.storage.CreatePlan(s.ctx, &dup), storage.ErrAlreadyExists)
+
+	userID := uuid.New()
+	following := factory.Subscription().WithUser(userID).WithExternalID("crm-1").WithPrice(499).Starting("01-2024").Build()
+	later := factory.Subscription().WithPrice(499).Starting("09-2024").Build()
+	ended := factory.Subscription().WithPrice(499).Starting("01-2023").Ending("12-2023").Build()
+	own := factory.Subscription().WithPrice(399).Build()
+	own.PlanID = &plan.ID
+	deleted := factory.Subscription().WithPrice(499).Build()
+	for _, sub := range []*models.Subscription{following, later, ended, deleted} {
+		sub.PlanID, sub.FollowPlan = &plan.ID, true
+	}
+	for _, sub := range []*models.Subscription{following, later, ended, own, deleted} {
+		require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+	}
+	require.NoError(s.T(), s.storage.DeleteSubscriptionByID(s.ctx, deleted.ID))
+
+	month := func(m time.Month) time.Time { return time.Date(2024, m, 1, 0, 0, 0, 0, time.UTC) }
+	require.NoError(s.T(), s.storage.CreateCredit(s.ctx, &models.SubscriptionCredit{ID: uuid.New(), SubscriptionID: following.ID, Amount: -50, StartDate: month(1), CreatedAt: time.Now()}))
+	july := month(7)
+	require.NoError(s.T(), s.storage.CreatePausedPeriod(s.ctx, &models.PausedPeriod{ID: uuid.New(), SubscriptionID: following.ID, StartDate: month(5), EndDate: &july, CreatedAt: time.Now()}))
+
+	filter := models.SubscriptionFilter{UserID: &userID}
+	before, err := s.storage.TotalSubscriptionsCost(s.ctx, filter, month(1), month(12))
+	require.NoError(s.T(), err)
+
+	rejected := errors.New("rejected")
+	_, _, _, err = s.storage.UpdatePlanPrice(s.ctx, plan.ID, 549, month(6), func([]models.Subscription) error { return rejected })
+	assert.ErrorIs(s.T(), err, rejected)
+	unchanged, err := s.storage.GetPlanByID(s.ctx, plan.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 499, unchanged.Price, "rejected with the propagation")
+
+	noCheck := func([]models.Subscription) error { return nil }
+	updated, previous, propagated, err := s.storage.UpdatePlanPrice(s.ctx, plan.ID, 549, month(6), noCheck)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 549, updated.Price)
+	assert.Equal(s.T(), "Family", updated.Name)
+	require.Len(s.T(), propagated, 2)
+	require.Len(s.T(), previous, 2)
+	for i := range propagated {
+		assert.Equal(s.T(), 499, previous[i].Price)
+		assert.Equal(s.T(), 549, propagated[i].Price)
+	}
+
+	// Started earlier: repriced in place, keeping its ID, external ID, credits and pauses
+	original, err := s.storage.GetSubscriptionByID(s.ctx, following.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 549, original.Price)
+	assert.True(s.T(), original.FollowPlan)
+	assert.Nil(s.T(), original.EndDate)
+	require.NotNil(s.T(), original.ExternalID)
+	assert.Equal(s.T(), "crm-1", *original.ExternalID)
+	assert.Equal(s.T(), following.Version+1, original.Version)
+
+	credits, err := s.storage.ListCredits(s.ctx, following.ID)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), credits, 1)
+	assert.Nil(s.T(), credits[0].EndDate)
+	pauses, err := s.storage.ListPausedPeriods(s.ctx, following.ID)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), pauses, 1)
+	assert.Equal(s.T(), july, pauses[0].EndDate.UTC())
+
+	// Every charged month of the year costs more, all but the paused May to July
+	after, err := s.storage.TotalSubscriptionsCost(s.ctx, filter, month(1), month(12))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), before+9*(549-499), after)
+
+	retrieved, err := s.storage.GetSubscriptionByID(s.ctx, later.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 549, retrieved.Price)
+	retrieved, err = s.storage.GetSubscriptionByID(s.ctx, ended.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 499, retrieved.Price)
+	retrieved, err = s.storage.GetSubscriptionByID(s.ctx, own.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 399, retrieved.Price)
+
+	_, _, _, err = s.storage.UpdatePlanPrice(s.ctx, uuid.New(), 1, month(6), noCheck)
+	assert.ErrorIs(s.T(), err, storage.ErrNotFound)
+}
+
+func (s *StorageIntegrationTestSuite) TestOrgUnits() {
+	company := &models.OrgUnit{ID: uuid.New(), Name: "Company"}
+	require.NoError(s.T(), s.storage.CreateOrgUnit(s.ctx, company))
+	assert.ErrorIs(s.T(), s.storage.CreateOrgUnit(s.ctx, &models.OrgUnit{ID: uuid.New(), Name: "Company"}), storage.ErrAlreadyExists, "root names are unique too")
+	marketing := &models.OrgUnit{ID: uuid.New(), ParentID: &company.ID, Name: "Marketing"}
+	require.NoError(s.T(), s.storage.CreateOrgUnit(s.ctx, marketing))
+	assert.ErrorIs(s.T(), s.storage.CreateOrgUnit(s.ctx, &models.OrgUnit{ID: uuid.New(), ParentID: &company.ID, Name: "Marketing"}), storage.ErrAlreadyExists)
+
+	units, err := s.storage.ListOrgUnits(s.ctx)
+	require.NoError(s.T(), err)
+	assert.Len(s.T(), units, 2)
+
+	own := factory.Subscription().WithPrice(100).Starting("01-2024").Build()
+	own.OrgUnitID = &company.ID
+	child := factory.Subscription().WithPrice(10).Starting("01-2024").Build()
+	child.OrgUnitID = &marketing.ID
+	moved := factory.Subscription().WithPrice(1).Starting("01-2024").Build()
+	for _, sub := range []*models.Subscription{own, child, moved} {
+		require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+	}
+	moved.OrgUnitID = &marketing.ID
+	require.NoError(s.T(), s.storage.UpdateSubscriptionByID(s.ctx, moved))
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	totals, err := s.storage.TotalSubscriptionsCostByOrgUnit(s.ctx, []uuid.UUID{company.ID, marketing.ID}, start, end)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), map[uuid.UUID]int64{company.ID: 1200, marketing.ID: 132}, totals, "units get only their own subscriptions")
+
+	ended := factory.Subscription().Starting("01-2024").Ending("06-2024").Build()
+	ended.OrgUnitID = &marketing.ID
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, ended))
+	counts, err := s.storage.CountSubscriptionsByOrgUnit(s.ctx, []uuid.UUID{company.ID, marketing.ID}, end)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), map[uuid.UUID]models.SubscriptionCounts{company.ID: {Total: 1, Active: 1}, marketing.ID: {Total: 3, Active: 2}}, counts)
+}
+
+func (s *StorageIntegrationTestSuite) TestBulkDelete() {
+	userID := uuid.New()
+	var kept []uuid.UUID
+	for i, b := range []*factory.SubscriptionBuilder{
+		factory.Subscription().WithUser(userID).WithService("Netflix").Starting("01-2024").Ending("03-2024"),
+		factory.Subscription().WithUser(userID).WithService("Netflix").Starting("04-2024").Ending("06-2024"),
+		factory.Subscription().WithUser(userID).WithService("Netflix").Starting("07-2024").Ending("12-2024"),
+		factory.Subscription().WithUser(userID).WithService("Netflix").Starting("01-2024"),
+		factory.Subscription().WithUser(userID).WithService("Spotify").Starting("01-2024").Ending("03-2024"),
+	} {
+		sub := b.Build()
+		require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+		if i >= 3 {
+			kept = append(kept, sub.ID)
+		}
+	}
+
+	end := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	serviceName := "Netflix"
+	job := &models.BulkDeleteJob{ID: uuid.New(), Status: models.BulkDeleteStatusPending, UserID: &userID, ServiceName: &serviceName, EndDate: &end}
+	require.NoError(s.T(), s.storage.CreateBulkDeleteJob(s.ctx, job))
+	assert.Equal(s.T(), int64(3), job.Matched)
+
+	claimed, err := s.storage.ClaimBulkDeleteJob(s.ctx, job.ID, time.Now().Add(-time.Minute))
+	require.NoError(s.T(), err)
+	assert.True(s.T(), claimed)
+	claimed, err = s.storage.ClaimBulkDeleteJob(s.ctx, job.ID, time.Now().Add(-time.Minute))
+	require.NoError(s.T(), err)
+	assert.False(s.T(), claimed, "a running job that isn't stale can't be claimed twice")
+
+	noCheck := func([]models.Subscription) error { return nil }
+	batch, err := s.storage.DeleteBulkDeleteBatch(s.ctx, job, 2, noCheck)
+	require.NoError(s.T(), err)
+	assert.Len(s.T(), batch, 2)
+	_, err = s.storage.DeleteBulkDeleteBatch(s.ctx, job, 2, func([]models.Subscription) error { return errors.New("rejected") })
+	assert.Error(s.T(), err)
+	batch, err = s.storage.DeleteBulkDeleteBatch(s.ctx, job, 2, noCheck)
+	require.NoError(s.T(), err)
+	assert.Len(s.T(), batch, 1, "the rejected batch was rolled back")
+	batch, err = s.storage.DeleteBulkDeleteBatch(s.ctx, job, 2, noCheck)
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), batch, "nothing left")
+	require.NoError(s.T(), s.storage.FinishBulkDeleteJob(s.ctx, job.ID, models.BulkDeleteStatusDone, nil))
+
+	stored, err := s.storage.GetBulkDeleteJobByID(s.ctx, job.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), models.BulkDeleteStatusDone, stored.Status)
+	assert.Equal(s.T(), int64(3), stored.Deleted)
+	assert.NotNil(s.T(), stored.FinishedAt)
+
+	subs, _, err := s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{UserID: &userID})
+	require.NoError(s.T(), err)
+	var remaining []uuid.UUID
+	for _, sub := range subs {
+		remaining = append(remaining, sub.ID)
+	}
+	assert.ElementsMatch(s.T(), kept, remaining)
+
+	unfinished, err := s.storage.ListUnfinishedBulkDeleteJobs(s.ctx)
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), unfinished)
+}
+
+func (s *StorageIntegrationTestSuite) TestBulkDeleteWaitsForLockedRows() {
+	userID := uuid.New()
+	sub := factory.Subscription().WithUser(userID).Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+	job := &models.BulkDeleteJob{ID: uuid.New(), Status: models.BulkDeleteStatusPending, UserID: &userID}
+	require.NoError(s.T(), s.storage.CreateBulkDeleteJob(s.ctx, job))
+
+	tx := s.container.DB.Begin()
+	require.NoError(s.T(), tx.Exec("SELECT id FROM subscriptions WHERE id = ? FOR UPDATE", sub.ID).Error)
+	deleted := make(chan []models.Subscription, 1)
+	go func() {
+		batch, err := s.storage.DeleteBulkDeleteBatch(s.ctx, job, 10, func([]models.Subscription) error { return nil })
+		assert.NoError(s.T(), err)
+		deleted <- batch
+	}()
+	select {
+	case <-deleted:
+		s.T().Fatal("batch didn't wait for the locked subscription")
+	case <-time.After(200 * time.Millisecond):
+	}
+	require.NoError(s.T(), tx.Commit().Error)
+
+	batch := <-deleted
+	require.Len(s.T(), batch, 1, "the locked subscription is deleted, not skipped")
+	assert.Equal(s.T(), sub.ID, batch[0].ID)
+}
+
+func (s *StorageIntegrationTestSuite) TestSavedViews() {
+	userID := uuid.New()
+	serviceName, sortBy, order := "Netflix", models.SortByPrice, models.OrderAsc
+	limit, minPrice := 10, 500
+	view := &models.SavedView{
 		ID:          uuid.New(),
-		ServiceName: "Service B",
-		Price:       200,
 		UserID:      userID,
-		StartDate:   time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
-		EndDate:     nil,
+		Name:        "Streaming",
+		ServiceName: &serviceName,
+		MinPrice:    &minPrice,
+		SortBy:      &sortBy,
+		Order:       &order,
+		Limit:       &limit,
 		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
 	}
-	sub3 := &models.Subscription{
-		ID:          uuid.New(),
-		ServiceName: "Service A",
-		Price:       500,
-		UserID:      uuid.New(),
-		StartDate:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
-		EndDate:     timePtr(time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)),
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+	require.NoError(s.T(), s.storage.CreateView(s.ctx, view))
+
+	retrieved, err := s.storage.GetViewByID(s.ctx, view.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "Streaming", retrieved.Name)
+	require.NotNil(s.T(), retrieved.Limit)
+	assert.Equal(s.T(), 10, *retrieved.Limit)
+	assert.Equal(s.T(), &minPrice, retrieved.MinPrice)
+	assert.Nil(s.T(), retrieved.MaxPrice)
+	assert.Equal(s.T(), &sortBy, retrieved.SortBy)
+	assert.Equal(s.T(), &order, retrieved.Order)
+
+	dup := *view
+	dup.ID = uuid.New()
+	assert.ErrorIs(s.T(), s.storage.CreateView(s.ctx, &dup), storage.ErrAlreadyExists)
+
+	views, err := s.storage.ListViews(s.ctx, userID)
+	require.NoError(s.T(), err)
+	assert.Len(s.T(), views, 1)
+
+	_, err = s.storage.GetViewByID(s.ctx, uuid.New())
+	assert.ErrorIs(s.T(), err, storage.ErrNotFound)
+}
+
+func (s *StorageIntegrationTestSuite) TestListSubscriptions_Status() {
+	userID := uuid.New()
+	month := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	cancelledAt := time.Now()
+	subs := map[string]*models.Subscription{
+		models.StatusActive:    factory.Subscription().WithUser(userID).Starting("01-2025").Ending("06-2025").Build(),
+		models.StatusUpcoming:  factory.Subscription().WithUser(userID).Starting("07-2025").Build(),
+		models.StatusExpired:   factory.Subscription().WithUser(userID).Starting("01-2025").Ending("05-2025").Build(),
+		models.StatusCancelled: factory.Subscription().WithUser(userID).Starting("07-2025").Ending("09-2025").Build(),
+		models.StatusDeleted:   factory.Subscription().WithUser(userID).Starting("01-2025").Build(),
 	}
+	subs[models.StatusCancelled].CancelledAt = &cancelledAt
+	for _, sub := range subs {
+		require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+	}
+	require.NoError(s.T(), s.storage.DeleteSubscriptionByID(s.ctx, subs[models.StatusDeleted].ID))
 
-	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub1))
-	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub2))
-	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub3))
+	for status, want := range subs {
+		result, total, err := s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{UserID: &userID, Status: &status, StatusAt: month})
+		require.NoError(s.T(), err)
+		require.Len(s.T(), result, 1, status)
+		assert.Equal(s.T(), int64(1), total)
+		assert.Equal(s.T(), want.ID, result[0].ID, status)
+		assert.Equal(s.T(), status, result[0].StatusIn(month))
+	}
+}
+
+func (s *StorageIntegrationTestSuite) TestListSubscriptionsInPeriod() {
+	userID := uuid.New()
+	subs := []*models.Subscription{
+		factory.Subscription().WithUser(userID).WithPrice(100).Build(),
+		factory.Subscription().WithUser(userID).WithService("Spotify").WithPrice(200).Starting("01-2023").Ending("06-2023").Build(),
+	}
+	for _, sub := range subs {
+		require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+	}
 
 	filter := models.SubscriptionFilter{UserID: &userID}
 	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
 	end := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	inPeriod, err := s.storage.ListSubscriptionsInPeriod(s.ctx, filter, start, end)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), inPeriod, 1)
+	assert.Equal(s.T(), subs[0].ID, inPeriod[0].ID)
 
 	total, err := s.storage.TotalSubscriptionsCost(s.ctx, filter, start, end)
-	assert.NoError(s.T(), err)
-	assert.Equal(s.T(), int64(2600), total)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), int64(1200), total)
+}
+
+func (s *StorageIntegrationTestSuite) TestListExpiringSubscriptions() {
+	userID := uuid.New()
+	lapsing := factory.Subscription().WithUser(userID).WithService("Netflix").Starting("01-2031").Ending("03-2031").Build()
+	renewed := factory.Subscription().WithUser(userID).WithService("Spotify").Starting("01-2031").Ending("02-2031").Build()
+	subs := []*models.Subscription{
+		lapsing, renewed,
+		factory.Subscription().WithUser(userID).WithService("SPOTIFY").Starting("03-2031").Build(), // Continues the one above
+		factory.Subscription().WithUser(userID).WithService("Disney").Starting("01-2031").Ending("09-2031").Build(),
+	}
+	for _, sub := range subs {
+		require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, sub))
+	}
+
+	ofUser := func(subs []models.Subscription) []uuid.UUID {
+		var ids []uuid.UUID
+		for _, sub := range subs {
+			if sub.UserID == userID {
+				ids = append(ids, sub.ID)
+			}
+		}
+		return ids
+	}
+	from := time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2031, 3, 1, 0, 0, 0, 0, time.UTC)
+	notRenewed, renewedOnly := false, true
+
+	all, err := s.storage.ListExpiringSubscriptions(s.ctx, from, to, nil)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []uuid.UUID{renewed.ID, lapsing.ID}, ofUser(all))
+
+	lapses, err := s.storage.ListExpiringSubscriptions(s.ctx, from, to, &notRenewed)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []uuid.UUID{lapsing.ID}, ofUser(lapses))
+
+	renewals, err := s.storage.ListExpiringSubscriptions(s.ctx, from, to, &renewedOnly)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []uuid.UUID{renewed.ID}, ofUser(renewals))
+}
+
+func (s *StorageIntegrationTestSuite) TestReadOnlyConnection() {
+	existing := factory.Subscription().Build()
+	require.NoError(s.T(), s.storage.CreateSubscription(s.ctx, existing))
+
+	db, err := postgres.Open(postgres.Config{Host: s.container.Host, Port: s.container.Port, User: testutils.TestDBUser,
+		Password: testutils.TestDBPassword, Database: s.container.Database, SSLMode: "disable", ReadOnly: true})
+	require.NoError(s.T(), err)
+	s.T().Cleanup(func() {
+		if pool, err := db.DB(); err == nil {
+			_ = pool.Close()
+		}
+	})
+	ro := storage.NewSubscriptionsStorage(db)
+
+	_, err = ro.GetSubscriptionByID(s.ctx, existing.ID)
+	assert.NoError(s.T(), err, "reads work on a read-only connection")
+	err = ro.CreateSubscription(s.ctx, factory.Subscription().Build())
+	assert.ErrorContains(s.T(), err, "read-only transaction", "writes fail even though the role may write")
 }
 
 func (s *StorageIntegrationTestSuite) TestConcurrentOperations() {
@@ -269,15 +1199,7 @@ func (s *StorageIntegrationTestSuite) TestConcurrentOperations() {
 
 	for i := 0; i < 10; i++ {
 		go func(idx int) {
-			sub := &models.Subscription{
-				ID:          uuid.New(),
-				ServiceName: "Service",
-				Price:       100 + idx,
-				UserID:      userID,
-				StartDate:   time.Now(),
-				CreatedAt:   time.Now(),
-				UpdatedAt:   time.Now(),
-			}
+			sub := factory.Subscription().WithUser(userID).WithService("Service").WithPrice(100 + idx).Build()
 			_ = s.storage.CreateSubscription(s.ctx, sub)
 			done <- true
 		}(i)
@@ -288,18 +1210,79 @@ func (s *StorageIntegrationTestSuite) TestConcurrentOperations() {
 	}
 
 	// Verify all were created
-	result, err := s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{UserID: &userID})
+	result, _, err := s.storage.ListSubscriptions(s.ctx, models.SubscriptionFilter{UserID: &userID})
 	assert.NoError(s.T(), err)
 	assert.Len(s.T(), result, 10)
 }
 
-func timePtr(t time.Time) *time.Time {
-	return &t
-}
-
 func TestStorageIntegrationSuite(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration tests in short mode")
 	}
 	suite.Run(t, new(StorageIntegrationTestSuite))
 }
+
+// Each parallel test gets its own database in the shared container, so full-table reads don't see each other's rows
+func TestSharedDatabaseIsolation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+
+	for i := 0; i < 4; i++ {
+		t.Run(fmt.Sprintf("db-%d", i), func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			st := storage.NewSubscriptionsStorage(testutils.NewTestDatabase(t).DB)
+			for j := 0; j < 3; j++ {
+				require.NoError(t, st.CreateSubscription(ctx, factory.Subscription().Build()))
+			}
+
+			result, _, err := st.ListSubscriptions(ctx, models.SubscriptionFilter{})
+			require.NoError(t, err)
+			assert.Len(t, result, 3)
+		})
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+	t.Parallel()
+	ctx := context.Background()
+
+	db := testutils.NewTestDatabase(t)
+	st := storage.NewSubscriptionsStorage(db.DB)
+
+	userID := uuid.New()
+	seeded := []*models.Subscription{
+		factory.Subscription().WithUser(userID).Build(),
+		factory.Subscription().WithUser(userID).WithService("Spotify").Ending("12-2024").Build(),
+	}
+	for _, sub := range seeded {
+		require.NoError(t, st.CreateSubscription(ctx, sub))
+	}
+	require.NoError(t, db.Snapshot(ctx, "seed"))
+
+	// Contaminate: add and delete rows
+	require.NoError(t, st.CreateSubscription(ctx, factory.Subscription().WithUser(userID).Build()))
+	require.NoError(t, st.DeleteSubscriptionByID(ctx, seeded[0].ID))
+
+	require.NoError(t, db.Restore(ctx, "seed"))
+
+	result, _, err := st.ListSubscriptions(ctx, models.SubscriptionFilter{UserID: &userID})
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	ids := []uuid.UUID{result[0].ID, result[1].ID}
+	assert.ElementsMatch(t, []uuid.UUID{seeded[0].ID, seeded[1].ID}, ids)
+
+	// Snapshot survives restore and can be reused by the next test
+	require.NoError(t, db.Cleanup(ctx))
+	require.NoError(t, db.Restore(ctx, "seed"))
+	result, _, err = st.ListSubscriptions(ctx, models.SubscriptionFilter{})
+	require.NoError(t, err)
+	assert.Len(t, result, 2)
+
+	assert.Error(t, db.Snapshot(ctx, "Bad-Name"))
+}
@@ -6,23 +6,17 @@ import (
 	"testing"
 
 	"bytes"
-	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"net/http/httptest"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/stretchr/testify/require"
 
-	"subscription-aggregator-service/internal/api/controllers"
-	apiModels "subscription-aggregator-service/internal/api/models"
-	"subscription-aggregator-service/internal/service"
 	"subscription-aggregator-service/internal/storage"
+	"subscription-aggregator-service/tests/factory"
 	"subscription-aggregator-service/tests/testutils"
 )
 
@@ -67,25 +61,9 @@ func TestLoad_CreateSubscriptions(t *testing.T) {
 		t.Skip("Skipping load tests in short mode")
 	}
 
-	ctx := context.Background()
-	gin.SetMode(gin.TestMode)
+	container := testutils.NewTestDatabase(t)
 
-	container, err := testutils.SetupPostgresContainer(ctx)
-	require.NoError(t, err)
-	defer container.Teardown(ctx)
-
-	err = container.RunMigrations(ctx)
-	require.NoError(t, err)
-
-	st := storage.NewSubscriptionsStorage(container.DB)
-	svc := service.NewSubscriptionService(st)
-	ctrl := controllers.NewSubscriptionController(svc)
-
-	router := gin.New()
-	router.POST("/subscriptions", ctrl.CreateSubscription)
-
-	server := httptest.NewServer(router)
-	defer server.Close()
+	baseURL := testutils.NewTestAPI(t, testutils.WithStorage(storage.NewSubscriptionsStorage(container.DB))).BaseURL
 
 	// Load test parameters
 	concurrency := 10
@@ -95,9 +73,8 @@ func TestLoad_CreateSubscriptions(t *testing.T) {
 	var (
 		successCount int64
 		errorCount   int64
-		latencies    = make([]time.Duration, 0, totalRequests)
-		latencyMu    sync.Mutex
 	)
+	rec := newRecorder(totalRequests)
 
 	start := time.Now()
 	var wg sync.WaitGroup
@@ -108,21 +85,13 @@ func TestLoad_CreateSubscriptions(t *testing.T) {
 			defer wg.Done()
 
 			for j := 0; j < requestsPerWorker; j++ {
-				req := apiModels.CreateSubscriptionRequest{
-					ServiceName: fmt.Sprintf("Service-%d-%d", workerID, j),
-					Price:       100 + j,
-					UserID:      uuid.New().String(),
-					StartDate:   "01-2024",
-				}
-				body, _ := json.Marshal(req)
+				body := factory.Subscription().WithService(fmt.Sprintf("Service-%d-%d", workerID, j)).WithPrice(100 + j).JSON()
 
 				reqStart := time.Now()
-				resp, err := http.Post(server.URL+"/subscriptions", "application/json", bytes.NewBuffer(body))
+				resp, err := http.Post(baseURL+"/subscriptions", "application/json", bytes.NewBuffer(body))
 				latency := time.Since(reqStart)
 
-				latencyMu.Lock()
-				latencies = append(latencies, latency)
-				latencyMu.Unlock()
+				rec.record(latency, resp, err)
 
 				if err != nil || resp.StatusCode != http.StatusCreated {
 					atomic.AddInt64(&errorCount, 1)
@@ -140,8 +109,9 @@ func TestLoad_CreateSubscriptions(t *testing.T) {
 	wg.Wait()
 	totalDuration := time.Since(start)
 
-	result := calculateResults(latencies, successCount, errorCount, totalDuration)
+	result := calculateResults(rec.latencies, successCount, errorCount, totalDuration)
 	t.Log(result.String())
+	writeReport(t, result, rec)
 
 	// Assertions
 	errorRate := float64(errorCount) / float64(totalRequests) * 100
@@ -160,38 +130,15 @@ func TestLoad_ListSubscriptions(t *testing.T) {
 		t.Skip("Skipping load tests in short mode")
 	}
 
-	ctx := context.Background()
-	gin.SetMode(gin.TestMode)
-
-	container, err := testutils.SetupPostgresContainer(ctx)
-	require.NoError(t, err)
-	defer container.Teardown(ctx)
-
-	err = container.RunMigrations(ctx)
-	require.NoError(t, err)
-
-	st := storage.NewSubscriptionsStorage(container.DB)
-	svc := service.NewSubscriptionService(st)
-	ctrl := controllers.NewSubscriptionController(svc)
-
-	router := gin.New()
-	router.POST("/subscriptions", ctrl.CreateSubscription)
-	router.GET("/subscriptions", ctrl.ListSubscriptions)
+	container := testutils.NewTestDatabase(t)
 
-	server := httptest.NewServer(router)
-	defer server.Close()
+	baseURL := testutils.NewTestAPI(t, testutils.WithStorage(storage.NewSubscriptionsStorage(container.DB))).BaseURL
 
 	// Pre-populate with data
-	userID := uuid.New().String()
+	userID := uuid.New()
 	for i := 0; i < 100; i++ {
-		req := apiModels.CreateSubscriptionRequest{
-			ServiceName: fmt.Sprintf("Service-%d", i),
-			Price:       100 + i,
-			UserID:      userID,
-			StartDate:   "01-2024",
-		}
-		body, _ := json.Marshal(req)
-		resp, _ := http.Post(server.URL+"/subscriptions", "application/json", bytes.NewBuffer(body))
+		body := factory.Subscription().WithUser(userID).WithService(fmt.Sprintf("Service-%d", i)).WithPrice(100 + i).JSON()
+		resp, _ := http.Post(baseURL+"/subscriptions", "application/json", bytes.NewBuffer(body))
 		if resp != nil {
 			resp.Body.Close()
 		}
@@ -205,9 +152,8 @@ func TestLoad_ListSubscriptions(t *testing.T) {
 	var (
 		successCount int64
 		errorCount   int64
-		latencies    = make([]time.Duration, 0, totalRequests)
-		latencyMu    sync.Mutex
 	)
+	rec := newRecorder(totalRequests)
 
 	start := time.Now()
 	var wg sync.WaitGroup
@@ -219,12 +165,10 @@ func TestLoad_ListSubscriptions(t *testing.T) {
 
 			for j := 0; j < requestsPerWorker; j++ {
 				reqStart := time.Now()
-				resp, err := http.Get(server.URL + "/subscriptions?user_id=" + userID)
+				resp, err := http.Get(baseURL + "/subscriptions?user_id=" + userID.String())
 				latency := time.Since(reqStart)
 
-				latencyMu.Lock()
-				latencies = append(latencies, latency)
-				latencyMu.Unlock()
+				rec.record(latency, resp, err)
 
 				if err != nil || resp.StatusCode != http.StatusOK {
 					atomic.AddInt64(&errorCount, 1)
@@ -242,8 +186,9 @@ func TestLoad_ListSubscriptions(t *testing.T) {
 	wg.Wait()
 	totalDuration := time.Since(start)
 
-	result := calculateResults(latencies, successCount, errorCount, totalDuration)
+	result := calculateResults(rec.latencies, successCount, errorCount, totalDuration)
 	t.Log(result.String())
+	writeReport(t, result, rec)
 
 	// Assertions
 	errorRate := float64(errorCount) / float64(totalRequests) * 100
@@ -258,44 +203,18 @@ func TestLoad_MixedOperations(t *testing.T) {
 		t.Skip("Skipping load tests in short mode")
 	}
 
-	ctx := context.Background()
-	gin.SetMode(gin.TestMode)
-
-	container, err := testutils.SetupPostgresContainer(ctx)
-	require.NoError(t, err)
-	defer container.Teardown(ctx)
-
-	err = container.RunMigrations(ctx)
-	require.NoError(t, err)
-
-	st := storage.NewSubscriptionsStorage(container.DB)
-	svc := service.NewSubscriptionService(st)
-	ctrl := controllers.NewSubscriptionController(svc)
-
-	router := gin.New()
-	router.POST("/subscriptions", ctrl.CreateSubscription)
-	router.GET("/subscriptions/:id", ctrl.GetSubscriptionByID)
-	router.GET("/subscriptions", ctrl.ListSubscriptions)
-	router.GET("/subscriptions/total", ctrl.TotalSubscriptionsCost)
+	container := testutils.NewTestDatabase(t)
 
-	server := httptest.NewServer(router)
-	defer server.Close()
+	baseURL := testutils.NewTestAPI(t, testutils.WithStorage(storage.NewSubscriptionsStorage(container.DB))).BaseURL
 
 	// Pre-populate
-	userID := uuid.New().String()
+	userID := uuid.New()
 	var createdIDs []string
 	var idsMu sync.Mutex
 
 	for i := 0; i < 50; i++ {
-		req := apiModels.CreateSubscriptionRequest{
-			ServiceName: fmt.Sprintf("Service-%d", i),
-			Price:       100,
-			UserID:      userID,
-			StartDate:   "01-2024",
-			EndDate:     strPtr("12-2024"),
-		}
-		body, _ := json.Marshal(req)
-		resp, _ := http.Post(server.URL+"/subscriptions", "application/json", bytes.NewBuffer(body))
+		body := factory.Subscription().WithUser(userID).WithService(fmt.Sprintf("Service-%d", i)).WithPrice(100).Ending("12-2024").JSON()
+		resp, _ := http.Post(baseURL+"/subscriptions", "application/json", bytes.NewBuffer(body))
 		if resp != nil && resp.StatusCode == http.StatusCreated {
 			var sub struct {
 				ID string `json:"id"`
@@ -313,9 +232,8 @@ func TestLoad_MixedOperations(t *testing.T) {
 	var (
 		successCount int64
 		errorCount   int64
-		latencies    = make([]time.Duration, 0, 10000)
-		latencyMu    sync.Mutex
 	)
+	rec := newRecorder(10000)
 
 	start := time.Now()
 	done := make(chan bool)
@@ -339,7 +257,7 @@ func TestLoad_MixedOperations(t *testing.T) {
 					switch {
 					case op < 4: // List
 						reqStart = time.Now()
-						resp, err = client.Get(server.URL + "/subscriptions?user_id=" + userID)
+						resp, err = client.Get(baseURL + "/subscriptions?user_id=" + userID.String())
 
 					case op < 7: // Get
 						if len(createdIDs) > 0 {
@@ -347,31 +265,23 @@ func TestLoad_MixedOperations(t *testing.T) {
 							id := createdIDs[workerID%len(createdIDs)]
 							idsMu.Unlock()
 							reqStart = time.Now()
-							resp, err = client.Get(server.URL + "/subscriptions/" + id)
+							resp, err = client.Get(baseURL + "/subscriptions/" + id)
 						}
 
 					case op < 9: // Create
-						req := apiModels.CreateSubscriptionRequest{
-							ServiceName: fmt.Sprintf("LoadTest-%d", time.Now().UnixNano()),
-							Price:       100,
-							UserID:      userID,
-							StartDate:   "01-2024",
-						}
-						body, _ := json.Marshal(req)
+						body := factory.Subscription().WithUser(userID).WithService(fmt.Sprintf("LoadTest-%d", time.Now().UnixNano())).WithPrice(100).JSON()
 						reqStart = time.Now()
-						resp, err = client.Post(server.URL+"/subscriptions", "application/json", bytes.NewBuffer(body))
+						resp, err = client.Post(baseURL+"/subscriptions", "application/json", bytes.NewBuffer(body))
 
 					default: // Total cost
 						reqStart = time.Now()
 						resp, err = client.Get(fmt.Sprintf("%s/subscriptions/total?user_id=%s&start_date=01-2024&end_date=12-2024",
-							server.URL, userID))
+							baseURL, userID))
 					}
 
 					if !reqStart.IsZero() {
 						latency := time.Since(reqStart)
-						latencyMu.Lock()
-						latencies = append(latencies, latency)
-						latencyMu.Unlock()
+						rec.record(latency, resp, err)
 
 						if err != nil || (resp != nil && resp.StatusCode >= 400) {
 							atomic.AddInt64(&errorCount, 1)
@@ -394,8 +304,9 @@ func TestLoad_MixedOperations(t *testing.T) {
 	time.Sleep(100 * time.Millisecond) // Let workers finish
 
 	totalDuration := time.Since(start)
-	result := calculateResults(latencies, successCount, errorCount, totalDuration)
+	result := calculateResults(rec.latencies, successCount, errorCount, totalDuration)
 	t.Log(result.String())
+	writeReport(t, result, rec)
 
 	// Assertions
 	totalRequests := successCount + errorCount
@@ -409,6 +320,79 @@ func TestLoad_MixedOperations(t *testing.T) {
 	}
 }
 
+// TestLoad_StorageFaults checks that failing storage turns into clean 500s under load, without hanging or leaking errors into healthy requests
+func TestLoad_StorageFaults(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping load tests in short mode")
+	}
+
+	container := testutils.NewTestDatabase(t)
+
+	chaos := testutils.NewChaosStorage(storage.NewSubscriptionsStorage(container.DB), 1,
+		testutils.Fault{Op: "ListSubscriptions", Probability: 0.1, Latency: 5 * time.Millisecond, Err: testutils.ErrInjected},
+	)
+	baseURL := testutils.NewTestAPI(t, testutils.WithStorage(chaos)).BaseURL
+
+	concurrency := 20
+	requestsPerWorker := 50
+	totalRequests := concurrency * requestsPerWorker
+
+	var (
+		successCount int64
+		failedCount  int64 // Answered with 500, expected for injected faults
+		errorCount   int64 // Anything else
+	)
+	rec := newRecorder(totalRequests)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < requestsPerWorker; j++ {
+				reqStart := time.Now()
+				resp, err := http.Get(baseURL + "/subscriptions?user_id=" + uuid.New().String())
+				latency := time.Since(reqStart)
+
+				rec.record(latency, resp, err)
+
+				switch {
+				case err != nil:
+					atomic.AddInt64(&errorCount, 1)
+				case resp.StatusCode == http.StatusOK:
+					atomic.AddInt64(&successCount, 1)
+				case resp.StatusCode == http.StatusInternalServerError:
+					atomic.AddInt64(&failedCount, 1)
+				default:
+					atomic.AddInt64(&errorCount, 1)
+				}
+
+				if resp != nil {
+					resp.Body.Close()
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	totalDuration := time.Since(start)
+
+	result := calculateResults(rec.latencies, successCount, errorCount+failedCount, totalDuration)
+	t.Log(result.String())
+	writeReport(t, result, rec)
+
+	// Assertions
+	if errorCount > 0 {
+		t.Errorf("%d requests failed with something other than 500", errorCount)
+	}
+	if injected := chaos.Injected("ListSubscriptions"); int64(injected) != failedCount {
+		t.Errorf("Got %d responses with 500, but %d faults were injected", failedCount, injected)
+	}
+}
+
 func calculateResults(latencies []time.Duration, successCount, errorCount int64, totalDuration time.Duration) LoadTestResult {
 	if len(latencies) == 0 {
 		return LoadTestResult{}
@@ -468,7 +452,3 @@ func sortDurations(d []time.Duration) {
 		}
 	}
 }
-
-func strPtr(s string) *string {
-	return &s
-}
@@ -0,0 +1,214 @@
+//go:build load
+
+package load
+
+import (
+	"testing"
+
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var reportDir = flag.String("load-report-dir", os.Getenv("LOAD_REPORT_DIR"), "write <test>.json and <test>.om reports of load tests to this directory")
+
+// reportBuckets are fixed upper bounds of latency histogram in seconds, so reports of different runs can be diffed bucket by bucket
+var reportBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// recorder collects latency and status of every request, status 0 stands for transport errors
+type recorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	statuses  map[int]int64
+}
+
+func newRecorder(capacity int) *recorder {
+	return &recorder{latencies: make([]time.Duration, 0, capacity), statuses: make(map[int]int64)}
+}
+
+func (r *recorder) record(latency time.Duration, resp *http.Response, err error) {
+	status := 0
+	if err == nil && resp != nil {
+		status = resp.StatusCode
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies = append(r.latencies, latency)
+	r.statuses[status]++
+}
+
+type reportBucket struct {
+	Le    string `json:"le"` // Upper bound in seconds or +Inf
+	Count int64  `json:"count"`
+}
+
+type report struct {
+	Test           string           `json:"test"`
+	Timestamp      time.Time        `json:"timestamp"`
+	TotalRequests  int64            `json:"total_requests"`
+	SuccessCount   int64            `json:"success_count"`
+	ErrorCount     int64            `json:"error_count"`
+	DurationSec    float64          `json:"duration_seconds"`
+	RequestsPerSec float64          `json:"requests_per_second"`
+	LatencySec     map[string]any   `json:"latency_seconds"` // avg, min, max, p50, p95, p99, sum
+	Histogram      []reportBucket   `json:"histogram"`       // Cumulative, as in OpenMetrics
+	Statuses       map[string]int64 `json:"statuses"`        // Count by HTTP status, "0" for transport errors
+}
+
+func newReport(test string, result LoadTestResult, rec *recorder) report {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	var sum time.Duration
+	counts := make([]int64, len(reportBuckets)+1)
+	for _, l := range rec.latencies {
+		sum += l
+		i := sort.SearchFloat64s(reportBuckets, l.Seconds()) // First bound >= latency
+		counts[i]++
+	}
+
+	histogram := make([]reportBucket, 0, len(counts))
+	var cumulative int64
+	for i, c := range counts {
+		cumulative += c
+		le := "+Inf"
+		if i < len(reportBuckets) {
+			le = strconv.FormatFloat(reportBuckets[i], 'g', -1, 64)
+		}
+		histogram = append(histogram, reportBucket{Le: le, Count: cumulative})
+	}
+
+	statuses := make(map[string]int64, len(rec.statuses))
+	for status, n := range rec.statuses {
+		statuses[strconv.Itoa(status)] = n
+	}
+
+	return report{
+		Test:           test,
+		Timestamp:      time.Now().UTC(),
+		TotalRequests:  result.TotalRequests,
+		SuccessCount:   result.SuccessCount,
+		ErrorCount:     result.ErrorCount,
+		DurationSec:    result.TotalDuration.Seconds(),
+		RequestsPerSec: result.RequestsPerSec,
+		LatencySec: map[string]any{
+			"avg": result.AvgLatency.Seconds(),
+			"min": result.MinLatency.Seconds(),
+			"max": result.MaxLatency.Seconds(),
+			"p50": result.P50Latency.Seconds(),
+			"p95": result.P95Latency.Seconds(),
+			"p99": result.P99Latency.Seconds(),
+			"sum": sum.Seconds(),
+		},
+		Histogram: histogram,
+		Statuses:  statuses,
+	}
+}
+
+// openMetrics renders the report in OpenMetrics text format
+func (r report) openMetrics() string {
+	var b strings.Builder
+	label := fmt.Sprintf("test=%q", r.Test)
+
+	b.WriteString("# TYPE load_request_duration_seconds histogram\n")
+	b.WriteString("# UNIT load_request_duration_seconds seconds\n")
+	b.WriteString("# HELP load_request_duration_seconds Latency of requests made by the load test.\n")
+	for _, bucket := range r.Histogram {
+		fmt.Fprintf(&b, "load_request_duration_seconds_bucket{%s,le=%q} %d\n", label, bucket.Le, bucket.Count)
+	}
+	fmt.Fprintf(&b, "load_request_duration_seconds_sum{%s} %g\n", label, r.LatencySec["sum"])
+	fmt.Fprintf(&b, "load_request_duration_seconds_count{%s} %d\n", label, r.TotalRequests)
+
+	b.WriteString("# TYPE load_requests counter\n")
+	b.WriteString("# HELP load_requests Requests made by the load test by HTTP status, 0 for transport errors.\n")
+	statuses := make([]string, 0, len(r.Statuses))
+	for status := range r.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(&b, "load_requests_total{%s,status=%q} %d\n", label, status, r.Statuses[status])
+	}
+
+	b.WriteString("# TYPE load_requests_per_second gauge\n")
+	fmt.Fprintf(&b, "load_requests_per_second{%s} %g\n", label, r.RequestsPerSec)
+
+	for _, q := range []string{"p50", "p95", "p99"} {
+		name := "load_request_duration_" + q + "_seconds"
+		fmt.Fprintf(&b, "# TYPE %s gauge\n# UNIT %s seconds\n%s{%s} %g\n", name, name, name, label, r.LatencySec[q])
+	}
+
+	b.WriteString("# EOF\n")
+	return b.String()
+}
+
+// writeReport saves JSON and OpenMetrics reports of the test if -load-report-dir or LOAD_REPORT_DIR is set
+func writeReport(t *testing.T, result LoadTestResult, rec *recorder) {
+	t.Helper()
+	if *reportDir == "" {
+		return
+	}
+
+	r := newReport(t.Name(), result, rec)
+	if err := os.MkdirAll(*reportDir, 0o755); err != nil {
+		t.Errorf("failed to create report directory: %v", err)
+		return
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		t.Errorf("failed to marshal report: %v", err)
+		return
+	}
+	base := filepath.Join(*reportDir, strings.ReplaceAll(t.Name(), "/", "_"))
+	if err = os.WriteFile(base+".json", data, 0o644); err != nil {
+		t.Errorf("failed to write JSON report: %v", err)
+	}
+	if err = os.WriteFile(base+".om", []byte(r.openMetrics()), 0o644); err != nil {
+		t.Errorf("failed to write OpenMetrics report: %v", err)
+	}
+}
+
+func TestReport(t *testing.T) {
+	rec := newRecorder(4)
+	rec.record(500*time.Microsecond, &http.Response{StatusCode: http.StatusOK}, nil)
+	rec.record(3*time.Millisecond, &http.Response{StatusCode: http.StatusOK}, nil)
+	rec.record(30*time.Second, &http.Response{StatusCode: http.StatusInternalServerError}, nil)
+	rec.record(time.Millisecond, nil, fmt.Errorf("connection refused"))
+
+	r := newReport("TestX", LoadTestResult{TotalRequests: 4, SuccessCount: 2, ErrorCount: 2}, rec)
+
+	wantCumulative := map[string]int64{"0.001": 2, "0.0025": 2, "0.005": 3, "10": 3, "+Inf": 4}
+	for _, bucket := range r.Histogram {
+		if want, ok := wantCumulative[bucket.Le]; ok && bucket.Count != want {
+			t.Errorf("bucket le=%s count = %d, want %d", bucket.Le, bucket.Count, want)
+		}
+	}
+	if r.Statuses["200"] != 2 || r.Statuses["500"] != 1 || r.Statuses["0"] != 1 {
+		t.Errorf("Statuses = %v", r.Statuses)
+	}
+
+	om := r.openMetrics()
+	for _, want := range []string{
+		`load_request_duration_seconds_bucket{test="TestX",le="+Inf"} 4`,
+		`load_request_duration_seconds_count{test="TestX"} 4`,
+		`load_requests_total{test="TestX",status="0"} 1`,
+		`load_requests_total{test="TestX",status="500"} 1`,
+	} {
+		if !strings.Contains(om, want) {
+			t.Errorf("OpenMetrics output misses %q:\n%s", want, om)
+		}
+	}
+	if !strings.HasSuffix(om, "# EOF\n") {
+		t.Error("OpenMetrics output must end with # EOF")
+	}
+}
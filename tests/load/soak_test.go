@@ -0,0 +1,215 @@
+//go:build load
+
+package load
+
+import (
+	"testing"
+
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"subscription-aggregator-service/internal/storage"
+	"subscription-aggregator-service/tests/factory"
+	"subscription-aggregator-service/tests/testutils"
+)
+
+var (
+	soakDuration = flag.Duration("soak", 0, "run TestLoad_Soak for this long, e.g. -soak=2h (skipped when 0)")
+	soakInterval = flag.Duration("soak-sample", time.Minute, "how often TestLoad_Soak samples resource usage")
+	soakWorkers  = flag.Int("soak-workers", 8, "concurrent clients in TestLoad_Soak")
+)
+
+// soakWarmup is the share of samples ignored while pools, caches and the heap settle
+const soakWarmup = 0.2
+
+// soakWindows is how many consecutive windows of samples have to keep growing to call it a leak
+const soakWindows = 4
+
+type soakSample struct {
+	at          time.Duration
+	goroutines  int
+	heapInuse   uint64
+	openConns   int // database/sql pool
+	serverConns int // pg_stat_activity for the test database
+}
+
+// TestLoad_Soak keeps steady mixed load for -soak and fails if goroutines, heap or DB connections grow monotonically.
+//
+//	go test ./tests/load/... -tags=load -run TestLoad_Soak -soak=2h -soak-sample=1m -v
+func TestLoad_Soak(t *testing.T) {
+	if *soakDuration == 0 {
+		t.Skip("Soak test is disabled, set -soak to run it")
+	}
+	if need := time.Duration(soakWindows/(1-soakWarmup)+1) * *soakInterval; *soakDuration < need {
+		t.Fatalf("-soak=%v is too short to detect growth with -soak-sample=%v, need at least %v", *soakDuration, *soakInterval, need)
+	}
+
+	container := testutils.NewTestDatabase(t)
+	sqlDB, err := container.DB.DB()
+	if err != nil {
+		t.Fatalf("failed to get sql.DB: %v", err)
+	}
+	baseURL := testutils.NewTestAPI(t, testutils.WithStorage(storage.NewSubscriptionsStorage(container.DB))).BaseURL
+
+	ctx, cancel := context.WithTimeout(context.Background(), *soakDuration)
+	defer cancel()
+
+	var (
+		wg              sync.WaitGroup
+		requests, fails int64
+		countersMu      sync.Mutex
+	)
+	for i := 0; i < *soakWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client := &http.Client{Timeout: 10 * time.Second}
+			userID := uuid.New()
+			for n := 0; ctx.Err() == nil; n++ {
+				ok := soakRequest(client, baseURL, userID, n)
+				countersMu.Lock()
+				requests++
+				if !ok {
+					fails++
+				}
+				countersMu.Unlock()
+			}
+		}()
+	}
+
+	var samples []soakSample
+	start := time.Now()
+	ticker := time.NewTicker(*soakInterval)
+	defer ticker.Stop()
+	for done := false; !done; {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			done = true
+		}
+
+		s := soakSample{at: time.Since(start).Round(time.Second), goroutines: runtime.NumGoroutine(), openConns: sqlDB.Stats().OpenConnections}
+		runtime.GC()
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		s.heapInuse = mem.HeapInuse
+		container.DB.Raw("SELECT count(*) FROM pg_stat_activity WHERE datname = current_database()").Scan(&s.serverConns)
+		samples = append(samples, s)
+
+		countersMu.Lock()
+		t.Logf("soak %v: requests=%d failed=%d goroutines=%d heap_inuse=%dKiB pool_conns=%d server_conns=%d",
+			s.at, requests, fails, s.goroutines, s.heapInuse/1024, s.openConns, s.serverConns)
+		countersMu.Unlock()
+	}
+	wg.Wait()
+
+	if fails*100 > requests {
+		t.Errorf("Error rate too high: %d of %d requests failed", fails, requests)
+	}
+
+	metrics := map[string]func(soakSample) float64{
+		"goroutines":   func(s soakSample) float64 { return float64(s.goroutines) },
+		"heap_inuse":   func(s soakSample) float64 { return float64(s.heapInuse) },
+		"pool_conns":   func(s soakSample) float64 { return float64(s.openConns) },
+		"server_conns": func(s soakSample) float64 { return float64(s.serverConns) },
+	}
+	for name, get := range metrics {
+		series := make([]float64, len(samples))
+		for i, s := range samples {
+			series[i] = get(s)
+		}
+		if leak, detail := detectGrowth(series); leak {
+			t.Errorf("Possible %s leak: %s", name, detail)
+		}
+	}
+}
+
+// soakRequest runs one step of the mixed scenario and reports whether it got the expected status
+func soakRequest(client *http.Client, baseURL string, userID uuid.UUID, n int) bool {
+	var (
+		resp *http.Response
+		err  error
+		want = http.StatusOK
+	)
+	switch n % 4 {
+	case 0:
+		body := factory.Subscription().WithUser(userID).WithService(fmt.Sprintf("Soak-%d", n%50)).JSON()
+		resp, err = client.Post(baseURL+"/subscriptions", "application/json", bytes.NewBuffer(body))
+		want = http.StatusCreated
+	case 1:
+		resp, err = client.Get(baseURL + "/subscriptions?limit=20&user_id=" + userID.String())
+	case 2:
+		resp, err = client.Get(baseURL + "/subscriptions/total?start_date=01-2024&end_date=12-2024&user_id=" + userID.String())
+	default:
+		resp, err = client.Get(baseURL + "/subscriptions/" + uuid.NewString())
+		want = http.StatusNotFound
+	}
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == want
+}
+
+// detectGrowth drops warm-up samples, splits the rest into soakWindows windows and reports a leak
+// if every window's average is above the previous one and the total growth is over 10%.
+// Steady usage fluctuates between windows, a leak only goes up.
+func detectGrowth(series []float64) (bool, string) {
+	series = series[int(float64(len(series))*soakWarmup):]
+	if len(series) < soakWindows {
+		return false, ""
+	}
+
+	size := len(series) / soakWindows
+	avgs := make([]float64, soakWindows)
+	for w := range avgs {
+		window := series[w*size : (w+1)*size]
+		if w == soakWindows-1 {
+			window = series[w*size:]
+		}
+		for _, v := range window {
+			avgs[w] += v
+		}
+		avgs[w] /= float64(len(window))
+	}
+
+	for w := 1; w < soakWindows; w++ {
+		if avgs[w] <= avgs[w-1] {
+			return false, ""
+		}
+	}
+	if avgs[soakWindows-1] <= avgs[0]*1.1 {
+		return false, ""
+	}
+	return true, fmt.Sprintf("window averages grew monotonically %v", avgs)
+}
+
+func TestDetectGrowth(t *testing.T) {
+	tests := []struct {
+		name   string
+		series []float64
+		want   bool
+	}{
+		{"steady", []float64{50, 52, 49, 51, 50, 53, 48, 50, 51, 49}, false},
+		{"growing", []float64{50, 55, 60, 65, 70, 75, 80, 85, 90, 95}, true},
+		{"growth in warm-up only", []float64{10, 50, 50, 51, 50, 50, 49, 50, 50, 50}, false},
+		{"slow drift within tolerance", []float64{100, 100, 101, 101, 102, 102, 103, 103, 104, 104}, false},
+		{"too few samples", []float64{1, 2, 3}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := detectGrowth(tt.series); got != tt.want {
+				t.Errorf("detectGrowth(%v) = %v, want %v", tt.series, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+//go:build queryplan
+
+package queryplan
+
+import (
+	"testing"
+
+	"context"
+	"encoding/json"
+	"flag"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"subscription-aggregator-service/internal/models"
+	"subscription-aggregator-service/internal/storage"
+	"subscription-aggregator-service/tests/testutils"
+)
+
+var (
+	planRows     = flag.Int("plan-rows", 200_000, "subscriptions seeded before checking query plans")
+	planUsers    = flag.Int("plan-users", 20_000, "distinct users among seeded subscriptions")
+	planServices = flag.Int("plan-services", 500, "distinct service names among seeded subscriptions, named \"Service 000\" and on")
+)
+
+// seedSQL spreads subscriptions evenly over users, services and five years of start dates, a third of them open-ended.
+// User IDs are 00000000-0000-0000-0000-000000000000 and on, so tests can pick one without reading it back.
+const seedSQL = `
+	INSERT INTO subscriptions (service_name, price, user_id, start_date, end_date)
+	SELECT
+		'Service ' || lpad((i % ?)::text, 3, '0'),
+		100 + i % 1000,
+		('00000000-0000-0000-0000-' || lpad((i % ?)::text, 12, '0'))::uuid,
+		(date '2020-01-01' + make_interval(months => i % 60))::date,
+		CASE WHEN i % 3 = 0 THEN NULL ELSE (date '2020-01-01' + make_interval(months => i % 60 + i % 24))::date END
+	FROM generate_series(1, ?) AS i
+`
+
+// statementRecorder is a GORM logger keeping every executed statement with its arguments inlined
+type statementRecorder struct {
+	mu  sync.Mutex
+	sql []string
+}
+
+func (r *statementRecorder) LogMode(logger.LogLevel) logger.Interface { return r }
+func (r *statementRecorder) Info(context.Context, string, ...any)     {}
+func (r *statementRecorder) Warn(context.Context, string, ...any)     {}
+func (r *statementRecorder) Error(context.Context, string, ...any)    {}
+
+func (r *statementRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sql = append(r.sql, sql)
+}
+
+func (r *statementRecorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sql := r.sql
+	r.sql = nil
+	return sql
+}
+
+// planNode is a node of EXPLAIN (FORMAT JSON) output
+type planNode struct {
+	NodeType     string     `json:"Node Type"`
+	RelationName string     `json:"Relation Name"`
+	Plans        []planNode `json:"Plans"`
+}
+
+// seqScans returns relations the plan reads with a sequential scan
+func (n planNode) seqScans() []string {
+	var tables []string
+	if n.NodeType == "Seq Scan" {
+		tables = append(tables, n.RelationName)
+	}
+	for _, child := range n.Plans {
+		tables = append(tables, child.seqScans()...)
+	}
+	return tables
+}
+
+// TestQueryPlans runs list and total cost queries of the real storage against a large seeded table,
+// EXPLAINs every statement they execute and fails if any of them reads subscriptions with a sequential scan.
+// A new filter in SubscriptionFilter or report query should get a case here, along with an index if it fails.
+//
+//	go test ./tests/queryplan/... -tags=queryplan -v
+func TestQueryPlans(t *testing.T) {
+	ctx := context.Background()
+	container := testutils.NewTestDatabase(t)
+
+	if err := container.DB.WithContext(ctx).Exec(seedSQL, *planServices, *planUsers, *planRows).Error; err != nil {
+		t.Fatalf("failed to seed subscriptions: %v", err)
+	}
+	if err := container.DB.WithContext(ctx).Exec("ANALYZE").Error; err != nil {
+		t.Fatalf("failed to analyze: %v", err)
+	}
+
+	recorder := &statementRecorder{}
+	st := storage.NewSubscriptionsStorage(container.DB.Session(&gorm.Session{Logger: recorder}))
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000042")
+	serviceName, serviceNameLower, servicePrefix := "Service 042", "service 042", "Service 04*"
+	activeAt := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	createdAfter := time.Now().Add(-time.Hour)
+	minPrice, maxPrice, limit := 500, 600, 20
+	status := models.StatusExpired
+	startDate, endDate := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	filters := []struct {
+		name   string
+		filter models.SubscriptionFilter
+	}{
+		{name: "user", filter: models.SubscriptionFilter{UserID: &userID}},
+		{name: "user and service", filter: models.SubscriptionFilter{UserID: &userID, ServiceName: &serviceName}},
+		{name: "service", filter: models.SubscriptionFilter{ServiceName: &serviceName}},
+		{name: "service case-insensitive", filter: models.SubscriptionFilter{ServiceName: &serviceNameLower, ServiceNameFold: true}},
+		{name: "service pattern", filter: models.SubscriptionFilter{ServiceNameLike: &servicePrefix}},
+		{name: "user active at", filter: models.SubscriptionFilter{UserID: &userID, ActiveAt: &activeAt}},
+		{name: "user created after", filter: models.SubscriptionFilter{UserID: &userID, CreatedAfter: &createdAfter}},
+		{name: "user status", filter: models.SubscriptionFilter{UserID: &userID, Status: &status, StatusAt: activeAt}},
+		{name: "user price range", filter: models.SubscriptionFilter{UserID: &userID, MinPrice: &minPrice, MaxPrice: &maxPrice}},
+		{name: "service sorted by price", filter: models.SubscriptionFilter{ServiceName: &serviceName, SortBy: models.SortByPrice, SortDesc: true}},
+	}
+
+	for _, tt := range filters {
+		t.Run("list by "+tt.name, func(t *testing.T) {
+			filter := tt.filter
+			filter.Limit = &limit
+			if _, _, err := st.ListSubscriptions(ctx, filter); err != nil {
+				t.Fatalf("ListSubscriptions() error = %v", err)
+			}
+			checkPlans(t, container.DB, recorder.take())
+		})
+		t.Run("total by "+tt.name, func(t *testing.T) {
+			if _, err := st.TotalSubscriptionsCost(ctx, tt.filter, startDate, endDate); err != nil {
+				t.Fatalf("TotalSubscriptionsCost() error = %v", err)
+			}
+			checkPlans(t, container.DB, recorder.take())
+		})
+	}
+
+	t.Run("expiring without renewal", func(t *testing.T) {
+		renewed := false
+		if _, err := st.ListExpiringSubscriptions(ctx, startDate, startDate.AddDate(0, 2, 0), &renewed); err != nil {
+			t.Fatalf("ListExpiringSubscriptions() error = %v", err)
+		}
+		checkPlans(t, container.DB, recorder.take())
+	})
+}
+
+// checkPlans EXPLAINs each statement and fails on a sequential scan of subscriptions.
+// Other tables are left alone, the planner rightly scans small ones like subscription_credits in full.
+func checkPlans(t *testing.T, db *gorm.DB, statements []string) {
+	t.Helper()
+	if len(statements) == 0 {
+		t.Fatal("no statements recorded")
+	}
+	for _, sql := range statements {
+		var raw string
+		if err := db.Raw("EXPLAIN (FORMAT JSON) " + sql).Row().Scan(&raw); err != nil {
+			t.Fatalf("failed to explain %s: %v", sql, err)
+		}
+		var plans []struct {
+			Plan planNode `json:"Plan"`
+		}
+		if err := json.Unmarshal([]byte(raw), &plans); err != nil || len(plans) == 0 {
+			t.Fatalf("failed to parse plan of %s: %v", sql, err)
+		}
+		for _, table := range plans[0].Plan.seqScans() {
+			if table == "subscriptions" {
+				t.Errorf("sequential scan of subscriptions in\n%s\nplan:\n%s", strings.TrimSpace(sql), raw)
+			}
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package testutils
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+
+	"subscription-aggregator-service/internal/api"
+	"subscription-aggregator-service/internal/api/controllers"
+	"subscription-aggregator-service/internal/service"
+	"subscription-aggregator-service/internal/storage"
+)
+
+// DefaultBasePath matches the default of app.api.base_path
+const DefaultBasePath = "/api/v1"
+
+// TestAPI is the production router, with the same global and config-driven middlewares and routes, served by httptest
+type TestAPI struct {
+	Engine  *gin.Engine
+	Server  *httptest.Server
+	BaseURL string // Server URL with base path, e.g. http://127.0.0.1:41234/api/v1
+}
+
+type APIOption func(*apiOptions)
+
+type apiOptions struct {
+	service     service.SubscriptionService
+	basePath    string
+	middlewares []gin.HandlerFunc
+	config      map[string]any
+}
+
+// WithService serves given service, usually a mock
+func WithService(svc service.SubscriptionService) APIOption {
+	return func(o *apiOptions) { o.service = svc }
+}
+
+// WithStorage serves the real service on top of given storage, e.g. a test database or ChaosStorage
+func WithStorage(st storage.SubscriptionStorage) APIOption {
+	return func(o *apiOptions) { o.service = service.NewSubscriptionService(st) }
+}
+
+func WithBasePath(path string) APIOption {
+	return func(o *apiOptions) { o.basePath = path }
+}
+
+// WithMiddleware adds middlewares to the API group after the ones enabled in config
+func WithMiddleware(mws ...gin.HandlerFunc) APIOption {
+	return func(o *apiOptions) { o.middlewares = append(o.middlewares, mws...) }
+}
+
+// WithConfig sets a config key for the lifetime of the test, e.g. to enable HMAC auth.
+// Config is global, so tests using it must not run in parallel with each other.
+func WithConfig(key string, value any) APIOption {
+	return func(o *apiOptions) { o.config[key] = value }
+}
+
+// NewTestAPI builds the router, starts a server and closes it when the test ends.
+// One of WithService or WithStorage is required.
+func NewTestAPI(t testing.TB, opts ...APIOption) *TestAPI {
+	t.Helper()
+
+	o := &apiOptions{basePath: DefaultBasePath, config: make(map[string]any)}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.service == nil {
+		t.Fatal("NewTestAPI: service is not set, use WithService or WithStorage")
+	}
+
+	for key, value := range o.config {
+		prev := viper.Get(key)
+		viper.Set(key, value)
+		t.Cleanup(func() { viper.Set(key, prev) })
+	}
+
+	gin.SetMode(gin.TestMode)
+	engine := api.NewEngine(o.basePath)
+	base := engine.Group(o.basePath, append(api.Middlewares(o.basePath), o.middlewares...)...)
+	controllers.NewSubscriptionController(o.service).RegisterRoutes(base)
+
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+
+	return &TestAPI{Engine: engine, Server: server, BaseURL: server.URL + o.basePath}
+}
@@ -0,0 +1,66 @@
+package testutils
+
+import (
+	"testing"
+
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	apiModels "subscription-aggregator-service/internal/api/models"
+	"subscription-aggregator-service/internal/config"
+	"subscription-aggregator-service/internal/service"
+	"subscription-aggregator-service/internal/utils/request"
+)
+
+// stubService answers ListSubscriptions, other methods panic via the nil embedded interface
+type stubService struct {
+	service.SubscriptionService
+}
+
+func (s *stubService) ListSubscriptions(ctx context.Context, req apiModels.ListSubscriptionsRequest) (*apiModels.ListSubscriptionsResponse, error) {
+	return &apiModels.ListSubscriptionsResponse{Items: []apiModels.SubscriptionResponse{}}, nil
+}
+
+func TestNewTestAPI(t *testing.T) {
+	called := false
+	api := NewTestAPI(t,
+		WithService(&stubService{}),
+		WithMiddleware(func(c *gin.Context) { called = true }),
+	)
+
+	resp, err := http.Get(api.BaseURL + "/subscriptions")
+	if err != nil {
+		t.Fatalf("GET /subscriptions error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if !called {
+		t.Error("extra middleware was not called")
+	}
+	if resp.Header.Get(request.HeaderName) == "" {
+		t.Error("global middlewares are missing, no request ID in response")
+	}
+}
+
+func TestNewTestAPIConfigDrivenMiddlewares(t *testing.T) {
+	api := NewTestAPI(t,
+		WithService(&stubService{}),
+		WithConfig(config.AuthHmacEnabled, true),
+		WithConfig(config.AuthHmacKeys, map[string]string{"client": "secret"}),
+	)
+
+	resp, err := http.Get(api.BaseURL + "/subscriptions")
+	if err != nil {
+		t.Fatalf("GET /subscriptions error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unsigned request status = %d, want 401 with HMAC enabled", resp.StatusCode)
+	}
+}
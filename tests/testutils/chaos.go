@@ -0,0 +1,448 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"subscription-aggregator-service/internal/models"
+	"subscription-aggregator-service/internal/storage"
+)
+
+// ErrInjected is a convenient Fault.Err for tests that only care that storage failed
+var ErrInjected = errors.New("injected storage failure")
+
+// Fault describes misbehaviour of a storage operation
+type Fault struct {
+	Op          string        // Storage method name, e.g. "ListSubscriptions"; empty matches every operation
+	Probability float64       // Chance in [0, 1] that the fault fires on a call
+	Latency     time.Duration // Delay before the call, cut short if ctx is done
+	Err         error         // Returned instead of calling storage
+	Hang        bool          // Block until ctx is done and return ctx.Err(), as a stuck query would
+}
+
+// ChaosStorage wraps a storage and injects faults into its calls, for resilience tests.
+// All matching faults fire in order: latencies add up, the first Err or Hang ends the call.
+type ChaosStorage struct {
+	next storage.SubscriptionStorage
+
+	mu       sync.Mutex
+	rnd      *rand.Rand
+	faults   []Fault
+	injected map[string]int
+}
+
+// NewChaosStorage wraps next, same seed gives same sequence of fired faults for the same sequence of calls
+func NewChaosStorage(next storage.SubscriptionStorage, seed uint64, faults ...Fault) *ChaosStorage {
+	return &ChaosStorage{
+		next:     next,
+		rnd:      rand.New(rand.NewPCG(seed, seed)),
+		faults:   faults,
+		injected: make(map[string]int),
+	}
+}
+
+// SetFaults replaces active faults, e.g. to let storage recover mid-test
+func (c *ChaosStorage) SetFaults(faults ...Fault) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = faults
+}
+
+// Injected returns how many calls of op were hit by a fault, empty op counts all
+func (c *ChaosStorage) Injected(op string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if op != "" {
+		return c.injected[op]
+	}
+	total := 0
+	for _, n := range c.injected {
+		total += n
+	}
+	return total
+}
+
+// fire picks faults for this call under lock, so the random sequence doesn't depend on timing
+func (c *ChaosStorage) fire(op string) []Fault {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var fired []Fault
+	for _, f := range c.faults {
+		if (f.Op == "" || f.Op == op) && c.rnd.Float64() < f.Probability {
+			fired = append(fired, f)
+		}
+	}
+	if len(fired) > 0 {
+		c.injected[op]++
+	}
+	return fired
+}
+
+func (c *ChaosStorage) inject(ctx context.Context, op string) error {
+	for _, f := range c.fire(op) {
+		if f.Latency > 0 {
+			select {
+			case <-time.After(f.Latency):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if f.Hang {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		if f.Err != nil {
+			return f.Err
+		}
+	}
+	return nil
+}
+
+func (c *ChaosStorage) CreateSubscription(ctx context.Context, s *models.Subscription) error {
+	if err := c.inject(ctx, "CreateSubscription"); err != nil {
+		return err
+	}
+	return c.next.CreateSubscription(ctx, s)
+}
+
+func (c *ChaosStorage) CreateSubscriptionIfAbsent(ctx context.Context, s *models.Subscription) (*models.Subscription, bool, error) {
+	if err := c.inject(ctx, "CreateSubscriptionIfAbsent"); err != nil {
+		return nil, false, err
+	}
+	return c.next.CreateSubscriptionIfAbsent(ctx, s)
+}
+
+func (c *ChaosStorage) CreateSubscriptions(ctx context.Context, subs []*models.Subscription) error {
+	if err := c.inject(ctx, "CreateSubscriptions"); err != nil {
+		return err
+	}
+	return c.next.CreateSubscriptions(ctx, subs)
+}
+
+func (c *ChaosStorage) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	if err := c.inject(ctx, "GetSubscriptionByID"); err != nil {
+		return nil, err
+	}
+	return c.next.GetSubscriptionByID(ctx, id)
+}
+
+func (c *ChaosStorage) UpdateSubscriptionsPrice(ctx context.Context, filter models.SubscriptionFilter, price int, month time.Time, check func(before []models.Subscription) error) ([]models.Subscription, []models.Subscription, error) {
+	if err := c.inject(ctx, "UpdateSubscriptionsPrice"); err != nil {
+		return nil, nil, err
+	}
+	return c.next.UpdateSubscriptionsPrice(ctx, filter, price, month, check)
+}
+
+func (c *ChaosStorage) SubscriptionExistsByID(ctx context.Context, id uuid.UUID) (bool, error) {
+	if err := c.inject(ctx, "SubscriptionExistsByID"); err != nil {
+		return false, err
+	}
+	return c.next.SubscriptionExistsByID(ctx, id)
+}
+
+func (c *ChaosStorage) FindOverlappingSubscription(ctx context.Context, s *models.Subscription) (*models.Subscription, error) {
+	if err := c.inject(ctx, "FindOverlappingSubscription"); err != nil {
+		return nil, err
+	}
+	return c.next.FindOverlappingSubscription(ctx, s)
+}
+
+func (c *ChaosStorage) UpdateSubscriptionByID(ctx context.Context, s *models.Subscription) error {
+	if err := c.inject(ctx, "UpdateSubscriptionByID"); err != nil {
+		return err
+	}
+	return c.next.UpdateSubscriptionByID(ctx, s)
+}
+
+func (c *ChaosStorage) DeleteSubscriptionByID(ctx context.Context, id uuid.UUID) error {
+	if err := c.inject(ctx, "DeleteSubscriptionByID"); err != nil {
+		return err
+	}
+	return c.next.DeleteSubscriptionByID(ctx, id)
+}
+
+func (c *ChaosStorage) DeleteSubscriptionWithUndo(ctx context.Context, id uuid.UUID, expiresAt time.Time) (*models.UndoToken, error) {
+	if err := c.inject(ctx, "DeleteSubscriptionWithUndo"); err != nil {
+		return nil, err
+	}
+	return c.next.DeleteSubscriptionWithUndo(ctx, id, expiresAt)
+}
+
+func (c *ChaosStorage) UndoDeletion(ctx context.Context, token uuid.UUID, check func(sub *models.Subscription) error) (*models.Subscription, error) {
+	if err := c.inject(ctx, "UndoDeletion"); err != nil {
+		return nil, err
+	}
+	return c.next.UndoDeletion(ctx, token, check)
+}
+
+func (c *ChaosStorage) PurgeSubscription(ctx context.Context, id uuid.UUID) (*models.PurgeRecord, error) {
+	if err := c.inject(ctx, "PurgeSubscription"); err != nil {
+		return nil, err
+	}
+	return c.next.PurgeSubscription(ctx, id)
+}
+
+func (c *ChaosStorage) PurgeUserData(ctx context.Context, userID uuid.UUID) (*models.PurgeRecord, error) {
+	if err := c.inject(ctx, "PurgeUserData"); err != nil {
+		return nil, err
+	}
+	return c.next.PurgeUserData(ctx, userID)
+}
+
+func (c *ChaosStorage) CreateBulkDeleteJob(ctx context.Context, job *models.BulkDeleteJob) error {
+	if err := c.inject(ctx, "CreateBulkDeleteJob"); err != nil {
+		return err
+	}
+	return c.next.CreateBulkDeleteJob(ctx, job)
+}
+
+func (c *ChaosStorage) GetBulkDeleteJobByID(ctx context.Context, id uuid.UUID) (*models.BulkDeleteJob, error) {
+	if err := c.inject(ctx, "GetBulkDeleteJobByID"); err != nil {
+		return nil, err
+	}
+	return c.next.GetBulkDeleteJobByID(ctx, id)
+}
+
+func (c *ChaosStorage) ListUnfinishedBulkDeleteJobs(ctx context.Context) ([]models.BulkDeleteJob, error) {
+	if err := c.inject(ctx, "ListUnfinishedBulkDeleteJobs"); err != nil {
+		return nil, err
+	}
+	return c.next.ListUnfinishedBulkDeleteJobs(ctx)
+}
+
+func (c *ChaosStorage) ClaimBulkDeleteJob(ctx context.Context, id uuid.UUID, staleBefore time.Time) (bool, error) {
+	if err := c.inject(ctx, "ClaimBulkDeleteJob"); err != nil {
+		return false, err
+	}
+	return c.next.ClaimBulkDeleteJob(ctx, id, staleBefore)
+}
+
+func (c *ChaosStorage) DeleteBulkDeleteBatch(ctx context.Context, job *models.BulkDeleteJob, limit int, check func(batch []models.Subscription) error) ([]models.Subscription, error) {
+	if err := c.inject(ctx, "DeleteBulkDeleteBatch"); err != nil {
+		return nil, err
+	}
+	return c.next.DeleteBulkDeleteBatch(ctx, job, limit, check)
+}
+
+func (c *ChaosStorage) FinishBulkDeleteJob(ctx context.Context, id uuid.UUID, status string, errMsg *string) error {
+	if err := c.inject(ctx, "FinishBulkDeleteJob"); err != nil {
+		return err
+	}
+	return c.next.FinishBulkDeleteJob(ctx, id, status, errMsg)
+}
+
+func (c *ChaosStorage) SyncUserSubscriptions(ctx context.Context, userID uuid.UUID, plan func(current []models.Subscription) (*models.SyncPlan, error)) (*models.SyncPlan, error) {
+	if err := c.inject(ctx, "SyncUserSubscriptions"); err != nil {
+		return nil, err
+	}
+	return c.next.SyncUserSubscriptions(ctx, userID, plan)
+}
+
+func (c *ChaosStorage) ListSubscriptions(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, int64, error) {
+	if err := c.inject(ctx, "ListSubscriptions"); err != nil {
+		return nil, 0, err
+	}
+	return c.next.ListSubscriptions(ctx, filter)
+}
+
+func (c *ChaosStorage) TotalSubscriptionsCost(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time) (int64, error) {
+	if err := c.inject(ctx, "TotalSubscriptionsCost"); err != nil {
+		return 0, err
+	}
+	return c.next.TotalSubscriptionsCost(ctx, filter, startDate, endDate)
+}
+
+func (c *ChaosStorage) TotalSubscriptionsCostByUser(ctx context.Context, filter models.SubscriptionFilter, userIDs []uuid.UUID, startDate, endDate time.Time) (map[uuid.UUID]int64, error) {
+	if err := c.inject(ctx, "TotalSubscriptionsCostByUser"); err != nil {
+		return nil, err
+	}
+	return c.next.TotalSubscriptionsCostByUser(ctx, filter, userIDs, startDate, endDate)
+}
+
+func (c *ChaosStorage) ListSubscriptionsInPeriod(ctx context.Context, filter models.SubscriptionFilter, startDate, endDate time.Time) ([]models.Subscription, error) {
+	if err := c.inject(ctx, "ListSubscriptionsInPeriod"); err != nil {
+		return nil, err
+	}
+	return c.next.ListSubscriptionsInPeriod(ctx, filter, startDate, endDate)
+}
+
+func (c *ChaosStorage) ListExpiringSubscriptions(ctx context.Context, from, to time.Time, renewed *bool) ([]models.Subscription, error) {
+	if err := c.inject(ctx, "ListExpiringSubscriptions"); err != nil {
+		return nil, err
+	}
+	return c.next.ListExpiringSubscriptions(ctx, from, to, renewed)
+}
+
+func (c *ChaosStorage) UserSummary(ctx context.Context, userID uuid.UUID, month time.Time) (*models.UserSummary, error) {
+	if err := c.inject(ctx, "UserSummary"); err != nil {
+		return nil, err
+	}
+	return c.next.UserSummary(ctx, userID, month)
+}
+
+func (c *ChaosStorage) SuggestServiceNames(ctx context.Context, prefix string, userID *uuid.UUID, limit int) ([]string, error) {
+	if err := c.inject(ctx, "SuggestServiceNames"); err != nil {
+		return nil, err
+	}
+	return c.next.SuggestServiceNames(ctx, prefix, userID, limit)
+}
+
+func (c *ChaosStorage) CreateView(ctx context.Context, v *models.SavedView) error {
+	if err := c.inject(ctx, "CreateView"); err != nil {
+		return err
+	}
+	return c.next.CreateView(ctx, v)
+}
+
+func (c *ChaosStorage) GetViewByID(ctx context.Context, id uuid.UUID) (*models.SavedView, error) {
+	if err := c.inject(ctx, "GetViewByID"); err != nil {
+		return nil, err
+	}
+	return c.next.GetViewByID(ctx, id)
+}
+
+func (c *ChaosStorage) ListViews(ctx context.Context, userID uuid.UUID) ([]models.SavedView, error) {
+	if err := c.inject(ctx, "ListViews"); err != nil {
+		return nil, err
+	}
+	return c.next.ListViews(ctx, userID)
+}
+
+func (c *ChaosStorage) CreatePlan(ctx context.Context, p *models.Plan) error {
+	if err := c.inject(ctx, "CreatePlan"); err != nil {
+		return err
+	}
+	return c.next.CreatePlan(ctx, p)
+}
+
+func (c *ChaosStorage) GetPlanByID(ctx context.Context, id uuid.UUID) (*models.Plan, error) {
+	if err := c.inject(ctx, "GetPlanByID"); err != nil {
+		return nil, err
+	}
+	return c.next.GetPlanByID(ctx, id)
+}
+
+func (c *ChaosStorage) ListPlans(ctx context.Context) ([]models.Plan, error) {
+	if err := c.inject(ctx, "ListPlans"); err != nil {
+		return nil, err
+	}
+	return c.next.ListPlans(ctx)
+}
+
+func (c *ChaosStorage) UpdatePlanPrice(ctx context.Context, id uuid.UUID, price int, month time.Time, check func(before []models.Subscription) error) (*models.Plan, []models.Subscription, []models.Subscription, error) {
+	if err := c.inject(ctx, "UpdatePlanPrice"); err != nil {
+		return nil, nil, nil, err
+	}
+	return c.next.UpdatePlanPrice(ctx, id, price, month, check)
+}
+
+func (c *ChaosStorage) CreateOrgUnit(ctx context.Context, u *models.OrgUnit) error {
+	if err := c.inject(ctx, "CreateOrgUnit"); err != nil {
+		return err
+	}
+	return c.next.CreateOrgUnit(ctx, u)
+}
+
+func (c *ChaosStorage) GetOrgUnitByID(ctx context.Context, id uuid.UUID) (*models.OrgUnit, error) {
+	if err := c.inject(ctx, "GetOrgUnitByID"); err != nil {
+		return nil, err
+	}
+	return c.next.GetOrgUnitByID(ctx, id)
+}
+
+func (c *ChaosStorage) ListOrgUnits(ctx context.Context) ([]models.OrgUnit, error) {
+	if err := c.inject(ctx, "ListOrgUnits"); err != nil {
+		return nil, err
+	}
+	return c.next.ListOrgUnits(ctx)
+}
+
+func (c *ChaosStorage) TotalSubscriptionsCostByOrgUnit(ctx context.Context, unitIDs []uuid.UUID, startDate, endDate time.Time) (map[uuid.UUID]int64, error) {
+	if err := c.inject(ctx, "TotalSubscriptionsCostByOrgUnit"); err != nil {
+		return nil, err
+	}
+	return c.next.TotalSubscriptionsCostByOrgUnit(ctx, unitIDs, startDate, endDate)
+}
+
+func (c *ChaosStorage) CountSubscriptionsByOrgUnit(ctx context.Context, unitIDs []uuid.UUID, month time.Time) (map[uuid.UUID]models.SubscriptionCounts, error) {
+	if err := c.inject(ctx, "CountSubscriptionsByOrgUnit"); err != nil {
+		return nil, err
+	}
+	return c.next.CountSubscriptionsByOrgUnit(ctx, unitIDs, month)
+}
+
+func (c *ChaosStorage) CreateCredit(ctx context.Context, credit *models.SubscriptionCredit) error {
+	if err := c.inject(ctx, "CreateCredit"); err != nil {
+		return err
+	}
+	return c.next.CreateCredit(ctx, credit)
+}
+
+func (c *ChaosStorage) ListCredits(ctx context.Context, subscriptionID uuid.UUID) ([]models.SubscriptionCredit, error) {
+	if err := c.inject(ctx, "ListCredits"); err != nil {
+		return nil, err
+	}
+	return c.next.ListCredits(ctx, subscriptionID)
+}
+
+func (c *ChaosStorage) CreatePausedPeriod(ctx context.Context, p *models.PausedPeriod) error {
+	if err := c.inject(ctx, "CreatePausedPeriod"); err != nil {
+		return err
+	}
+	return c.next.CreatePausedPeriod(ctx, p)
+}
+
+func (c *ChaosStorage) ResumePausedPeriod(ctx context.Context, subscriptionID uuid.UUID, endDate time.Time) (*models.PausedPeriod, error) {
+	if err := c.inject(ctx, "ResumePausedPeriod"); err != nil {
+		return nil, err
+	}
+	return c.next.ResumePausedPeriod(ctx, subscriptionID, endDate)
+}
+
+func (c *ChaosStorage) ListPausedPeriods(ctx context.Context, subscriptionID uuid.UUID) ([]models.PausedPeriod, error) {
+	if err := c.inject(ctx, "ListPausedPeriods"); err != nil {
+		return nil, err
+	}
+	return c.next.ListPausedPeriods(ctx, subscriptionID)
+}
+
+func (c *ChaosStorage) CreateServiceRename(ctx context.Context, r *models.ServiceRename) error {
+	if err := c.inject(ctx, "CreateServiceRename"); err != nil {
+		return err
+	}
+	return c.next.CreateServiceRename(ctx, r)
+}
+
+func (c *ChaosStorage) RenameServiceBatch(ctx context.Context, r *models.ServiceRename, unitIDs []uuid.UUID, limit int, check func(batch []models.Subscription) error) ([]models.Subscription, error) {
+	if err := c.inject(ctx, "RenameServiceBatch"); err != nil {
+		return nil, err
+	}
+	return c.next.RenameServiceBatch(ctx, r, unitIDs, limit, check)
+}
+
+func (c *ChaosStorage) FinishServiceRename(ctx context.Context, id uuid.UUID) error {
+	if err := c.inject(ctx, "FinishServiceRename"); err != nil {
+		return err
+	}
+	return c.next.FinishServiceRename(ctx, id)
+}
+
+func (c *ChaosStorage) CheckIntegrity(ctx context.Context) (bool, error) {
+	if err := c.inject(ctx, "CheckIntegrity"); err != nil {
+		return false, err
+	}
+	return c.next.CheckIntegrity(ctx)
+}
+
+func (c *ChaosStorage) ListIntegrityFindings(ctx context.Context) ([]models.IntegrityFinding, error) {
+	if err := c.inject(ctx, "ListIntegrityFindings"); err != nil {
+		return nil, err
+	}
+	return c.next.ListIntegrityFindings(ctx)
+}
@@ -0,0 +1,99 @@
+package testutils
+
+import (
+	"testing"
+
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"subscription-aggregator-service/internal/models"
+	"subscription-aggregator-service/internal/storage"
+)
+
+// stubStorage answers GetSubscriptionByID, other methods panic via the nil embedded interface
+type stubStorage struct {
+	storage.SubscriptionStorage
+	calls int
+}
+
+func (s *stubStorage) GetSubscriptionByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	s.calls++
+	return &models.Subscription{ID: id}, nil
+}
+
+func TestChaosStorageErrors(t *testing.T) {
+	stub := &stubStorage{}
+	chaos := NewChaosStorage(stub, 1, Fault{Op: "GetSubscriptionByID", Probability: 1, Err: ErrInjected})
+
+	if _, err := chaos.GetSubscriptionByID(context.Background(), uuid.New()); !errors.Is(err, ErrInjected) {
+		t.Errorf("GetSubscriptionByID() error = %v, want ErrInjected", err)
+	}
+	if stub.calls != 0 {
+		t.Errorf("storage called %d times, want 0 when fault fires", stub.calls)
+	}
+
+	chaos.SetFaults()
+	if _, err := chaos.GetSubscriptionByID(context.Background(), uuid.New()); err != nil {
+		t.Errorf("GetSubscriptionByID() after recovery error = %v", err)
+	}
+	if stub.calls != 1 || chaos.Injected("GetSubscriptionByID") != 1 || chaos.Injected("") != 1 {
+		t.Errorf("calls = %d, injected = %d, want 1 and 1", stub.calls, chaos.Injected(""))
+	}
+}
+
+func TestChaosStorageOtherOpsUntouched(t *testing.T) {
+	stub := &stubStorage{}
+	chaos := NewChaosStorage(stub, 1, Fault{Op: "ListSubscriptions", Probability: 1, Err: ErrInjected})
+
+	if _, err := chaos.GetSubscriptionByID(context.Background(), uuid.New()); err != nil {
+		t.Errorf("GetSubscriptionByID() error = %v, fault is for another op", err)
+	}
+}
+
+func TestChaosStorageProbabilityIsDeterministic(t *testing.T) {
+	run := func() (int, int) {
+		stub := &stubStorage{}
+		chaos := NewChaosStorage(stub, 42, Fault{Probability: 0.3, Err: ErrInjected})
+		for i := 0; i < 1000; i++ {
+			_, _ = chaos.GetSubscriptionByID(context.Background(), uuid.New())
+		}
+		return chaos.Injected(""), stub.calls
+	}
+
+	injected, calls := run()
+	if injected < 250 || injected > 350 {
+		t.Errorf("injected %d of 1000 calls, want about 300", injected)
+	}
+	if injected+calls != 1000 {
+		t.Errorf("injected %d + passed %d != 1000", injected, calls)
+	}
+	if again, _ := run(); again != injected {
+		t.Errorf("same seed injected %d then %d faults", injected, again)
+	}
+}
+
+func TestChaosStorageLatencyAndHang(t *testing.T) {
+	stub := &stubStorage{}
+	chaos := NewChaosStorage(stub, 1, Fault{Probability: 1, Latency: 20 * time.Millisecond})
+
+	start := time.Now()
+	if _, err := chaos.GetSubscriptionByID(context.Background(), uuid.New()); err != nil {
+		t.Fatalf("GetSubscriptionByID() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("call took %v, want at least the injected latency", elapsed)
+	}
+
+	chaos.SetFaults(Fault{Probability: 1, Hang: true})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := chaos.GetSubscriptionByID(ctx, uuid.New()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetSubscriptionByID() error = %v, want DeadlineExceeded", err)
+	}
+	if stub.calls != 1 {
+		t.Errorf("storage called %d times, want 1", stub.calls)
+	}
+}
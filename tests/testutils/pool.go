@@ -0,0 +1,94 @@
+package testutils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// SharedContainerName is the name every test package uses to find the shared container.
+// Reused containers are still reaped by Ryuk once the last `go test` process of the session exits.
+const SharedContainerName = "subscription-aggregator-test-postgres"
+
+var shared struct {
+	once sync.Once
+	pc   *PostgresContainer
+	err  error
+}
+
+// SharedPostgres returns the container shared by all test packages.
+// It's started once per process and reused by name across packages, so only the first one pays for startup.
+// Don't Teardown or Cleanup it directly, use CreateDatabase or NewTestDatabase instead.
+func SharedPostgres(ctx context.Context) (*PostgresContainer, error) {
+	shared.once.Do(func() {
+		shared.pc, shared.err = startPostgres(ctx, testcontainers.WithReuseByName(SharedContainerName))
+	})
+	return shared.pc, shared.err
+}
+
+// CreateDatabase creates an empty database in the same container, safe to call concurrently
+func (pc *PostgresContainer) CreateDatabase(ctx context.Context) (*PostgresContainer, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("failed to generate database name: %w", err)
+	}
+	name := "test_" + hex.EncodeToString(suffix)
+
+	if err := pc.DB.WithContext(ctx).Exec(fmt.Sprintf("CREATE DATABASE %s", name)).Error; err != nil {
+		return nil, fmt.Errorf("failed to create database %s: %w", name, err)
+	}
+
+	db := &PostgresContainer{
+		Container: pc.Container,
+		Host:      pc.Host,
+		Port:      pc.Port,
+		Database:  name,
+		parent:    pc,
+	}
+	var err error
+	if db.DB, err = openDB(db.ConnectionString()); err != nil {
+		_ = db.dropDatabase(ctx)
+		return nil, err
+	}
+	return db, nil
+}
+
+func (pc *PostgresContainer) dropDatabase(ctx context.Context) error {
+	if pc.DB != nil {
+		if sqlDB, err := pc.DB.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	}
+	if err := pc.parent.DB.WithContext(ctx).Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s WITH (FORCE)", pc.Database)).Error; err != nil {
+		return fmt.Errorf("failed to drop database %s: %w", pc.Database, err)
+	}
+	return nil
+}
+
+// NewTestDatabase gives the test its own migrated database in the shared container and drops it when the test ends.
+// Tests using it may call t.Parallel().
+func NewTestDatabase(t testing.TB) *PostgresContainer {
+	t.Helper()
+	ctx := context.Background()
+
+	pc, err := SharedPostgres(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup shared postgres container: %v", err)
+	}
+
+	db, err := pc.CreateDatabase(ctx)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	t.Cleanup(func() { _ = db.Teardown(context.Background()) })
+
+	if err = db.RunMigrations(ctx); err != nil {
+		t.Fatalf("%v", err)
+	}
+	return db
+}
@@ -3,6 +3,7 @@ package testutils
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/testcontainers/testcontainers-go"
@@ -11,6 +12,8 @@ import (
 	pgdriver "gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+
+	"subscription-aggregator-service/migrations"
 )
 
 const (
@@ -19,27 +22,36 @@ const (
 	TestDBPassword = "testpass"
 )
 
-// PostgresContainer wraps testcontainers postgres container
+// PostgresContainer wraps testcontainers postgres container.
+// When created by CreateDatabase it points to a separate database inside its parent's container.
 type PostgresContainer struct {
 	Container testcontainers.Container
 	Host      string
 	Port      string
+	Database  string
 	DB        *gorm.DB
+
+	parent *PostgresContainer
 }
 
 // SetupPostgresContainer creates a new PostgreSQL container for testing
 func SetupPostgresContainer(ctx context.Context) (*PostgresContainer, error) {
-	container, err := tcpostgres.Run(ctx,
-		"postgres:15-alpine",
+	return startPostgres(ctx)
+}
+
+func startPostgres(ctx context.Context, opts ...testcontainers.ContainerCustomizer) (*PostgresContainer, error) {
+	opts = append([]testcontainers.ContainerCustomizer{
 		tcpostgres.WithDatabase(TestDBName),
 		tcpostgres.WithUsername(TestDBUser),
 		tcpostgres.WithPassword(TestDBPassword),
 		testcontainers.WithWaitStrategy(
 			wait.ForLog("database system is ready to accept connections").
 				WithOccurrence(2).
-				WithStartupTimeout(30*time.Second),
+				WithStartupTimeout(30 * time.Second),
 		),
-	)
+	}, opts...)
+
+	container, err := tcpostgres.Run(ctx, "postgres:15-alpine", opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to start postgres container: %w", err)
 	}
@@ -54,62 +66,56 @@ func SetupPostgresContainer(ctx context.Context) (*PostgresContainer, error) {
 		return nil, fmt.Errorf("failed to get mapped port: %w", err)
 	}
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, mappedPort.Port(), TestDBUser, TestDBPassword, TestDBName)
+	pc := &PostgresContainer{
+		Container: container,
+		Host:      host,
+		Port:      mappedPort.Port(),
+		Database:  TestDBName,
+	}
+	if pc.DB, err = openDB(pc.ConnectionString()); err != nil {
+		return nil, err
+	}
+	return pc, nil
+}
 
+func openDB(dsn string) (*gorm.DB, error) {
 	db, err := gorm.Open(pgdriver.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
-
-	return &PostgresContainer{
-		Container: container,
-		Host:      host,
-		Port:      mappedPort.Port(),
-		DB:        db,
-	}, nil
+	return db, nil
 }
 
-// RunMigrations executes the database migrations
+// RunMigrations applies the embedded goose migrations, so tests run against the real schema
 func (pc *PostgresContainer) RunMigrations(ctx context.Context) error {
-	// Create extension
-	if err := pc.DB.Exec("CREATE EXTENSION IF NOT EXISTS pgcrypto").Error; err != nil {
-		return fmt.Errorf("failed to create pgcrypto extension: %w", err)
+	scripts, err := migrations.UpScripts()
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
 	}
-
-	// Create subscriptions table
-	createTableSQL := `
-		CREATE TABLE IF NOT EXISTS subscriptions (
-			id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
-			service_name text NOT NULL,
-			price integer NOT NULL CHECK (price >= 0),
-			user_id uuid NOT NULL,
-			start_date date NOT NULL,
-			end_date date NULL,
-			created_at timestamptz NOT NULL DEFAULT now(),
-			updated_at timestamptz NOT NULL DEFAULT now(),
-			deleted_at timestamptz NULL
-		);
-		CREATE INDEX IF NOT EXISTS idx_subscriptions_user_id ON subscriptions(user_id);
-		CREATE INDEX IF NOT EXISTS idx_subscriptions_service_name ON subscriptions(service_name);
-		CREATE INDEX IF NOT EXISTS idx_subscriptions_deleted_at ON subscriptions(deleted_at);
-	`
-	if err := pc.DB.Exec(createTableSQL).Error; err != nil {
-		return fmt.Errorf("failed to create subscriptions table: %w", err)
+	for _, script := range scripts {
+		if err = pc.DB.WithContext(ctx).Exec(script).Error; err != nil {
+			return fmt.Errorf("failed to apply migrations: %w", err)
+		}
 	}
-
 	return nil
 }
 
 // Cleanup removes all data from tables
 func (pc *PostgresContainer) Cleanup(ctx context.Context) error {
-	return pc.DB.Exec("TRUNCATE TABLE subscriptions").Error
+	tables, err := pc.tables(ctx)
+	if err != nil {
+		return err
+	}
+	return pc.DB.WithContext(ctx).Exec("TRUNCATE TABLE " + strings.Join(tables, ", ")).Error
 }
 
-// Teardown stops and removes the container
+// Teardown stops and removes the container, or drops the database if it was created by CreateDatabase
 func (pc *PostgresContainer) Teardown(ctx context.Context) error {
+	if pc.parent != nil {
+		return pc.dropDatabase(ctx)
+	}
 	if pc.Container != nil {
 		return pc.Container.Terminate(ctx)
 	}
@@ -119,5 +125,5 @@ func (pc *PostgresContainer) Teardown(ctx context.Context) error {
 // ConnectionString returns the PostgreSQL connection string
 func (pc *PostgresContainer) ConnectionString() string {
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		pc.Host, pc.Port, TestDBUser, TestDBPassword, TestDBName)
+		pc.Host, pc.Port, TestDBUser, TestDBPassword, pc.Database)
 }
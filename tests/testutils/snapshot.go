@@ -0,0 +1,83 @@
+package testutils
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var snapshotName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Snapshot copies current rows of every application table into schema "snapshot_<name>", replacing an older snapshot of the same name.
+// Seed expensive fixtures once, snapshot them, then Restore in SetupTest instead of Cleanup.
+func (pc *PostgresContainer) Snapshot(ctx context.Context, name string) error {
+	schema, err := snapshotSchema(name)
+	if err != nil {
+		return err
+	}
+	tables, err := pc.tables(ctx)
+	if err != nil {
+		return err
+	}
+
+	return pc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err = tx.Exec(fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", schema)).Error; err != nil {
+			return fmt.Errorf("failed to drop old snapshot %s: %w", name, err)
+		}
+		if err = tx.Exec(fmt.Sprintf("CREATE SCHEMA %s", schema)).Error; err != nil {
+			return fmt.Errorf("failed to create snapshot %s: %w", name, err)
+		}
+		for _, table := range tables {
+			if err = tx.Exec(fmt.Sprintf("CREATE TABLE %s.%s AS TABLE public.%s", schema, table, table)).Error; err != nil {
+				return fmt.Errorf("failed to snapshot table %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Restore brings every application table back to the state captured by Snapshot.
+// Rows written since then are removed, so tests sharing a fixture can't see each other's changes.
+func (pc *PostgresContainer) Restore(ctx context.Context, name string) error {
+	schema, err := snapshotSchema(name)
+	if err != nil {
+		return err
+	}
+	tables, err := pc.tables(ctx)
+	if err != nil {
+		return err
+	}
+
+	return pc.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err = tx.Exec("TRUNCATE TABLE " + strings.Join(tables, ", ")).Error; err != nil {
+			return fmt.Errorf("failed to truncate tables: %w", err)
+		}
+		for _, table := range tables {
+			if err = tx.Exec(fmt.Sprintf("INSERT INTO public.%s SELECT * FROM %s.%s", table, schema, table)).Error; err != nil {
+				return fmt.Errorf("failed to restore table %s from snapshot %s: %w", table, name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// tables lists application tables, i.e. everything migrations created in the public schema
+func (pc *PostgresContainer) tables(ctx context.Context) ([]string, error) {
+	var tables []string
+	err := pc.DB.WithContext(ctx).Raw(`SELECT table_name FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_type = 'BASE TABLE' ORDER BY table_name`).Scan(&tables).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	return tables, nil
+}
+
+func snapshotSchema(name string) (string, error) {
+	if !snapshotName.MatchString(name) {
+		return "", fmt.Errorf("invalid snapshot name %q, expected lowercase letters, digits and underscores", name)
+	}
+	return "snapshot_" + name, nil
+}